@@ -19,7 +19,8 @@ func handleRunCmd(
 	debug *bool,
 	navigateResource *string,
 	fuzzerDurationMinutes *int,
-	tenantID *string) int {
+	tenantID *string,
+	dashboard *string) int {
 
 	if demo != nil && *demo {
 		settings.HideGuids = true
@@ -45,6 +46,10 @@ func handleRunCmd(
 		settings.TenantID = *tenantID
 	}
 
+	if dashboard != nil {
+		settings.Dashboard = *dashboard
+	}
+
 	run(settings)
 	return 0
 }
@@ -76,6 +81,7 @@ func handleCommandAndArgs() {
 	runNavigate := runCmd.String("navigate", "", "navigate to resource")
 	runFuzzer := runCmd.Int("fuzzer", -1, "run fuzzer (optionally specify the duration in minutes)")
 	runTenantID := runCmd.String("tenant-id", "", "(optional) specify the tenant id to get an access token for (see `az")
+	runDashboard := runCmd.String("dashboard", "", "(optional) show a saved metrics dashboard on startup")
 
 	// Version command
 	versionCmd := flag.NewFlagSet("version", flag.ExitOnError)
@@ -166,7 +172,7 @@ func handleCommandAndArgs() {
 		}())
 	}
 	if runCmd.Parsed() {
-		os.Exit(handleRunCmd(&settings, runDemo, runDebug, runNavigate, runFuzzer, runTenantID))
+		os.Exit(handleRunCmd(&settings, runDemo, runDebug, runNavigate, runFuzzer, runTenantID, runDashboard))
 	}
 
 	// If no command was parsed, fallback to usage
@@ -61,6 +61,18 @@ func run(settings *config.Settings) {
 	// resources in Azure
 	expanders.InitializeExpanders(armClient)
 
+	// Load any swagger specs that the user has configured to add to the compiled-in specs
+	err = expanders.LoadRuntimeSwaggerSpecs(armClient)
+	if err != nil {
+		fmt.Println(err.Error())
+	}
+
+	// Use the api-versions that the user has selected for resource types
+	err = expanders.LoadAPIVersionOverrides()
+	if err != nil {
+		fmt.Println(err.Error())
+	}
+
 	// Start up gocui and configure some settings
 	g, err := gocui.NewGui(gocui.OutputNormal)
 	if err != nil {
@@ -84,10 +96,10 @@ func run(settings *config.Settings) {
 
 	// Create the views we'll use to display information and
 	// bind up all the keys use to interact with the views
-	list := setupViewsAndKeybindings(ctx, g, settings, armClient)
+	list, content := setupViewsAndKeybindings(ctx, g, settings, armClient)
 
 	// Start a go routine to populate the list with root of the nodes
-	startPopulatingList(ctx, g, list, armClient)
+	startPopulatingList(ctx, g, list, armClient, settings)
 
 	// Start a go routine to handling automated naviging to an item via the
 	// `--navigate` command
@@ -95,6 +107,13 @@ func run(settings *config.Settings) {
 		automation.NavigateTo(list, settings.NavigateToID)
 	}
 
+	// Redraw metrics dashboards while they're shown and show the
+	// dashboard requested via the `--dashboard` command
+	views.StartMetricsDashboardRefresh(ctx, content)
+	if settings.Dashboard != "" {
+		automation.ShowDashboard(ctx, content, settings.Dashboard)
+	}
+
 	if settings.FuzzerEnabled {
 		automation.StartAutomatedFuzzer(list, settings, g)
 	}
@@ -148,7 +167,7 @@ func configureTracing(settings *config.Settings) (context.Context, opentracing.S
 	return ctx, span
 }
 
-func startPopulatingList(ctx context.Context, g *gocui.Gui, list *views.ListWidget, armClient *armclient.Client) {
+func startPopulatingList(ctx context.Context, g *gocui.Gui, list *views.ListWidget, armClient *armclient.Client, settings *config.Settings) {
 	go func() {
 		defer errorhandling.RecoveryWithCleanup()
 
@@ -166,26 +185,40 @@ func startPopulatingList(ctx context.Context, g *gocui.Gui, list *views.ListWidg
 		g.Update(func(gui *gocui.Gui) error {
 			g.SetCurrentView("listWidget")
 
-			// Create an empty tentant TreeNode. This by default expands
-			// to show the current tenants subscriptions
-			newContent, newItems, err := expanders.ExpandItem(ctx, &expanders.TreeNode{
-				ItemType:  expanders.TentantItemType,
-				ID:        "AvailableSubscriptions",
+			// Start from the list of tenants the user can access unless a tenant
+			// was specified or we're navigating to a resource (resource IDs
+			// don't include the tenant so navigation starts from the subscriptions)
+			rootNode := &expanders.TreeNode{
+				ItemType:  expanders.TenantListItemType,
+				ID:        "AvailableTenants",
 				ExpandURL: expanders.ExpandURLNotSupported,
-			})
+			}
+			title := "Tenants"
+			if settings.TenantID != "" || settings.NavigateToID != "" {
+				// Create an empty tentant TreeNode. This by default expands
+				// to show the current tenants subscriptions
+				rootNode = &expanders.TreeNode{
+					ItemType:  expanders.TentantItemType,
+					ID:        "AvailableSubscriptions",
+					ExpandURL: expanders.ExpandURLNotSupported,
+				}
+				title = "Subscriptions"
+			}
+
+			newContent, newItems, err := expanders.ExpandItem(ctx, rootNode)
 
 			if err != nil {
 				panic(err)
 			}
 
-			list.Navigate(newItems, newContent, "Subscriptions")
+			list.Navigate(newItems, newContent, title)
 
 			return nil
 		})
 	}()
 }
 
-func setupViewsAndKeybindings(ctx context.Context, g *gocui.Gui, settings *config.Settings, client *armclient.Client) *views.ListWidget {
+func setupViewsAndKeybindings(ctx context.Context, g *gocui.Gui, settings *config.Settings, client *armclient.Client) (*views.ListWidget, *views.ItemWidget) {
 	maxX, maxY := g.Size()
 	// Padding
 	maxX = maxX - 2
@@ -210,6 +243,26 @@ func setupViewsAndKeybindings(ctx context.Context, g *gocui.Gui, settings *confi
 
 	commandPanelFilterCommand := keybindings.NewCommandPanelFilterHandler(commandPanel, list)
 	commandPanelAzureSearchQueryCommand := keybindings.NewCommandPanelAzureSearchQueryHandler(commandPanel, content, list)
+	commandPanelCosmosDBQueryCommand := keybindings.NewCommandPanelCosmosDBQueryHandler(commandPanel, content, list, ctx)
+	commandPanelMetricsQueryCommand := keybindings.NewCommandPanelMetricsQueryHandler(commandPanel, content, status, ctx)
+	listPinMetricCommand := keybindings.NewListPinMetricHandler(content, status)
+	listComparePinnedMetricsCommand := keybindings.NewListComparePinnedMetricsHandler(content, ctx)
+	listClearPinnedMetricsCommand := keybindings.NewListClearPinnedMetricsHandler(status)
+	commandPanelSaveMetricsDashboardCommand := keybindings.NewCommandPanelSaveMetricsDashboardHandler(commandPanel, status)
+	commandPanelOpenMetricsDashboardCommand := keybindings.NewCommandPanelOpenMetricsDashboardHandler(commandPanel, content, status, ctx)
+	commandPanelLogQueryCommand := keybindings.NewCommandPanelLogQueryHandler(commandPanel, list, content, ctx)
+	commandPanelLogQueryOptionsCommand := keybindings.NewCommandPanelLogQueryOptionsHandler(commandPanel, content, status, ctx)
+	commandPanelLogQueryExportCommand := keybindings.NewCommandPanelLogQueryExportHandler(commandPanel, content, status)
+	logFollower := views.NewLogFollower(content)
+	commandPanelFollowLogsCommand := keybindings.NewCommandPanelFollowLogsHandler(commandPanel, list, content, logFollower, ctx)
+	toggleFollowLogsPausedCommand := keybindings.NewToggleFollowLogsPausedHandler(logFollower, status)
+	commandPanelFilterFollowedLogsCommand := keybindings.NewCommandPanelFilterFollowedLogsHandler(commandPanel, logFollower)
+	commandPanelPurgeRegistryTagsCommand := keybindings.NewCommandPanelPurgeRegistryTagsHandler(commandPanel, list, content, status, ctx)
+	commandPanelContainerExecCommand := keybindings.NewCommandPanelContainerExecHandler(commandPanel, list, status, g, ctx)
+	commandPanelRunVirtualMachineCommandCommand := keybindings.NewCommandPanelRunVirtualMachineCommandHandler(commandPanel, list, content, status, ctx)
+	commandPanelSetAppServiceSettingCommand := keybindings.NewCommandPanelSetAppServiceSettingHandler(commandPanel, list, status, ctx)
+	commandPanelVerifyIPFlowCommand := keybindings.NewCommandPanelVerifyIPFlowHandler(commandPanel, list, content, status, ctx)
+	commandPanelSelectAPIVersionCommand := keybindings.NewCommandPanelSelectAPIVersionHandler(commandPanel, list, content, status, ctx)
 
 	listActionsCommand := keybindings.NewListActionsHandler(list, ctx)
 	listOpenCommand := keybindings.NewListOpenHandler(list, ctx)
@@ -221,6 +274,25 @@ func setupViewsAndKeybindings(ctx context.Context, g *gocui.Gui, settings *confi
 		commandPanelFilterCommand,
 		copyCommand,
 		commandPanelAzureSearchQueryCommand,
+		commandPanelCosmosDBQueryCommand,
+		commandPanelMetricsQueryCommand,
+		listPinMetricCommand,
+		listComparePinnedMetricsCommand,
+		listClearPinnedMetricsCommand,
+		commandPanelSaveMetricsDashboardCommand,
+		commandPanelOpenMetricsDashboardCommand,
+		commandPanelLogQueryCommand,
+		commandPanelLogQueryOptionsCommand,
+		commandPanelLogQueryExportCommand,
+		commandPanelFollowLogsCommand,
+		toggleFollowLogsPausedCommand,
+		commandPanelFilterFollowedLogsCommand,
+		commandPanelPurgeRegistryTagsCommand,
+		commandPanelContainerExecCommand,
+		commandPanelRunVirtualMachineCommandCommand,
+		commandPanelSetAppServiceSettingCommand,
+		commandPanelVerifyIPFlowCommand,
+		commandPanelSelectAPIVersionCommand,
 		listActionsCommand,
 		listOpenCommand,
 		listUpdateCommand,
@@ -275,6 +347,25 @@ func setupViewsAndKeybindings(ctx context.Context, g *gocui.Gui, settings *confi
 	keybindings.AddHandler(keybindings.NewListHomeHandler(list))
 	keybindings.AddHandler(keybindings.NewListClearFilterHandler(list))
 	keybindings.AddHandler(commandPanelAzureSearchQueryCommand)
+	keybindings.AddHandler(commandPanelCosmosDBQueryCommand)
+	keybindings.AddHandler(commandPanelMetricsQueryCommand)
+	keybindings.AddHandler(listPinMetricCommand)
+	keybindings.AddHandler(listComparePinnedMetricsCommand)
+	keybindings.AddHandler(listClearPinnedMetricsCommand)
+	keybindings.AddHandler(commandPanelSaveMetricsDashboardCommand)
+	keybindings.AddHandler(commandPanelOpenMetricsDashboardCommand)
+	keybindings.AddHandler(commandPanelLogQueryCommand)
+	keybindings.AddHandler(commandPanelLogQueryOptionsCommand)
+	keybindings.AddHandler(commandPanelLogQueryExportCommand)
+	keybindings.AddHandler(commandPanelFollowLogsCommand)
+	keybindings.AddHandler(toggleFollowLogsPausedCommand)
+	keybindings.AddHandler(commandPanelFilterFollowedLogsCommand)
+	keybindings.AddHandler(commandPanelPurgeRegistryTagsCommand)
+	keybindings.AddHandler(commandPanelContainerExecCommand)
+	keybindings.AddHandler(commandPanelRunVirtualMachineCommandCommand)
+	keybindings.AddHandler(commandPanelSetAppServiceSettingCommand)
+	keybindings.AddHandler(commandPanelVerifyIPFlowCommand)
+	keybindings.AddHandler(commandPanelSelectAPIVersionCommand)
 	keybindings.AddHandler(listCopyItemIDCommand)
 	if settings.EnableTracing {
 		keybindings.AddHandler(listDebugCopyItemDataCommand)
@@ -303,5 +394,5 @@ func setupViewsAndKeybindings(ctx context.Context, g *gocui.Gui, settings *confi
 	notifications.ConfirmDeleteKeyBinding = strings.Join(keyBindings["confirmdelete"], ",")
 	notifications.ClearPendingDeletesKeyBinding = strings.Join(keyBindings["clearpendingdeletes"], ",")
 
-	return list
+	return list, content
 }
@@ -41,17 +41,16 @@ func main() {
 	fmt.Println("*******************************************")
 	fmt.Println("  Processing ARM Specs ")
 	fmt.Println("*******************************************")
-	config := getARMConfig()
+	config := swagger.GetARMConfig()
 	paths := loadARMSwagger(config)
 	writeOutput(paths, config, "./internal/pkg/expanders/swagger-armspecs.generated.go", "SwaggerAPISetARMResources")
 	fmt.Println()
 
 	fmt.Println("*******************************************")
-	fmt.Println("  Processing Azure Search Data-plane Specs ")
+	fmt.Println("  Processing Data-plane API Sets ")
 	fmt.Println("*******************************************")
-	config = getAzureSearchDataPlaneConfig()
-	paths = loadAzureSearchDataPlaneSpecs(config)
-	writeOutput(paths, config, "./internal/pkg/expanders/search.generated.go", "AzureSearchServiceExpander")
+	dataPlaneAPISets := loadDataPlaneAPISets("./cmd/swagger-codegen/dataplane-apisets.json")
+	writeDataPlaneOutput(dataPlaneAPISets, "./internal/pkg/expanders/dataPlane.generated.go")
 	fmt.Println()
 }
 
@@ -137,113 +136,74 @@ func loadARMSwagger(config *swagger.Config) []*swagger.Path {
 	return paths
 }
 
-// getARMConfig returns the config for ARM Swagger processing
-func getARMConfig() *swagger.Config {
-	config := &swagger.Config{
-		Overrides: map[string]swagger.PathOverride{
-			// App Service patches
-			"/subscriptions/{subscriptionId}/resourceGroups/{resourceGroupName}/providers/Microsoft.Web/sites/{name}/config/appsettings/list": {
-				Path:    "/subscriptions/{subscriptionId}/resourceGroups/{resourceGroupName}/providers/Microsoft.Web/sites/{name}/config/appsettings",
-				GetVerb: "post",
-			},
-			"/subscriptions/{subscriptionId}/resourceGroups/{resourceGroupName}/providers/Microsoft.Web/sites/{name}/config/authsettings/list": {
-				Path:    "/subscriptions/{subscriptionId}/resourceGroups/{resourceGroupName}/providers/Microsoft.Web/sites/{name}/config/authsettings",
-				GetVerb: "post",
-			},
-			"/subscriptions/{subscriptionId}/resourceGroups/{resourceGroupName}/providers/Microsoft.Web/sites/{name}/config/azurestorageaccounts/list": {
-				Path:    "/subscriptions/{subscriptionId}/resourceGroups/{resourceGroupName}/providers/Microsoft.Web/sites/{name}/config/azurestorageaccounts",
-				GetVerb: "post",
-			},
-			"/subscriptions/{subscriptionId}/resourceGroups/{resourceGroupName}/providers/Microsoft.Web/sites/{name}/config/backup/list": {
-				Path:    "/subscriptions/{subscriptionId}/resourceGroups/{resourceGroupName}/providers/Microsoft.Web/sites/{name}/config/backup",
-				GetVerb: "post",
-			},
-			"/subscriptions/{subscriptionId}/resourceGroups/{resourceGroupName}/providers/Microsoft.Web/sites/{name}/config/connectionstrings/list": {
-				Path:    "/subscriptions/{subscriptionId}/resourceGroups/{resourceGroupName}/providers/Microsoft.Web/sites/{name}/config/connectionstrings",
-				GetVerb: "post",
-			},
-			"/subscriptions/{subscriptionId}/resourceGroups/{resourceGroupName}/providers/Microsoft.Web/sites/{name}/config/metadata/list": {
-				Path:    "/subscriptions/{subscriptionId}/resourceGroups/{resourceGroupName}/providers/Microsoft.Web/sites/{name}/config/metadata",
-				GetVerb: "post",
-			},
-			"/subscriptions/{subscriptionId}/resourceGroups/{resourceGroupName}/providers/Microsoft.Web/sites/{name}/config/publishingcredentials/list": {
-				Path:    "/subscriptions/{subscriptionId}/resourceGroups/{resourceGroupName}/providers/Microsoft.Web/sites/{name}/config/publishingcredentials",
-				GetVerb: "post",
-			},
-			"/subscriptions/{subscriptionId}/resourceGroups/{resourceGroupName}/providers/Microsoft.Web/sites/{name}/config/pushsettings/list": {
-				Path:    "/subscriptions/{subscriptionId}/resourceGroups/{resourceGroupName}/providers/Microsoft.Web/sites/{name}/config/pushsettings",
-				GetVerb: "post",
-			},
-			// Search patches
-			"/subscriptions/{subscriptionId}/resourceGroups/{resourceGroupName}/providers/Microsoft.Search/searchServices/{searchServiceName}/listAdminKeys": {
-				Path:    "/subscriptions/{subscriptionId}/resourceGroups/{resourceGroupName}/providers/Microsoft.Search/searchServices/{searchServiceName}/listAdminKeys", // no change to path
-				GetVerb: "post",
-			},
-			// VM Scale Sets patches
-			"/subscriptions/{subscriptionId}/resourceGroups/{resourceGroupName}/providers/Microsoft.Compute/virtualMachineScaleSets/{virtualMachineScaleSetName}/virtualMachines": {
-				Path:        "/subscriptions/{subscriptionId}/resourceGroups/{resourceGroupName}/providers/Microsoft.Compute/virtualMachineScaleSets/{vmScaleSetName}/virtualMachines",
-				RewritePath: true,
-			},
-			"/subscriptions/{subscriptionId}/resourceGroups/{resourceGroupName}/providers/Microsoft.Compute/virtualMachineScaleSets/{vmScaleSetName}/virtualmachines/{instanceId}": {
-				Path:        "/subscriptions/{subscriptionId}/resourceGroups/{resourceGroupName}/providers/Microsoft.Compute/virtualMachineScaleSets/{vmScaleSetName}/virtualMachines/{instanceId}",
-				RewritePath: true,
-			},
-			"/subscriptions/{subscriptionId}/resourceGroups/{resourceGroupName}/providers/Microsoft.Compute/virtualMachineScaleSets/{vmScaleSetName}/virtualmachines/{instanceId}/instanceView": {
-				Path:        "/subscriptions/{subscriptionId}/resourceGroups/{resourceGroupName}/providers/Microsoft.Compute/virtualMachineScaleSets/{vmScaleSetName}/virtualMachines/{instanceId}/instanceView",
-				RewritePath: true,
-			},
-			"/subscriptions/{subscriptionId}/resourceGroups/{resourceGroupName}/providers/Microsoft.Compute/virtualMachineScaleSets/{virtualMachineScaleSetName}/publicipaddresses": {
-				Path:        "/subscriptions/{subscriptionId}/resourceGroups/{resourceGroupName}/providers/Microsoft.Compute/virtualMachineScaleSets/{vmScaleSetName}/publicipaddresses",
-				RewritePath: true,
-			},
-			"/subscriptions/{subscriptionId}/resourceGroups/{resourceGroupName}/providers/Microsoft.Compute/virtualMachineScaleSets/{virtualMachineScaleSetName}/virtualMachines/{virtualmachineIndex}/networkInterfaces/{networkInterfaceName}/ipconfigurations/{ipConfigurationName}/publicipaddresses": {
-				Path:        "/subscriptions/{subscriptionId}/resourceGroups/{resourceGroupName}/providers/Microsoft.Compute/virtualMachineScaleSets/{vmScaleSetName}/virtualMachines/{virtualmachineIndex}/networkInterfaces/{networkInterfaceName}/ipconfigurations/{ipConfigurationName}/publicipaddresses",
-				RewritePath: true,
-			},
-		},
-	}
-	return config
+// DataPlaneAPISet holds the config and paths for a data-plane API set from dataplane-apisets.json
+type DataPlaneAPISet struct {
+	Config swagger.DataPlaneConfig
+	Paths  []*swagger.Path
 }
 
-// getARMConfig returns the config for ARM Swagger processing
-func getAzureSearchDataPlaneConfig() *swagger.Config {
-	return &swagger.Config{
-		Overrides: map[string]swagger.PathOverride{
-			"/indexes('{indexName}')/docs('{key}')": {
-				PutPath:    "/indexes('{indexName}')/docs/index", // update requires POST, but the APISet will handle that
-				DeletePath: "/indexes('{indexName}')/docs/index", // update requires POST, but the APISet will handle that
-			},
-		},
+// loadDataPlaneAPISets loads the specs for each API set in the config file
+func loadDataPlaneAPISets(configPath string) []DataPlaneAPISet {
+	buf, err := ioutil.ReadFile(configPath)
+	if err != nil {
+		panic(err)
+	}
+	var configs []swagger.DataPlaneConfig
+	err = json.Unmarshal(buf, &configs)
+	if err != nil {
+		panic(fmt.Errorf("Error parsing %s: %s", configPath, err))
 	}
-}
-func loadAzureSearchDataPlaneSpecs(config *swagger.Config) []*swagger.Path {
-	var paths []*swagger.Path
 
-	directoryNames := []string{"Microsoft.Azure.Search.Service", "Microsoft.Azure.Search.Data"} // need to control the document load order
-	for _, directoryName := range directoryNames {
-		swaggerPath := getFirstNonCommonPath(getFirstNonCommonPath(fmt.Sprintf("swagger-specs/search/data-plane/%s", directoryName)))
-		swaggerFileInfos, err := ioutil.ReadDir(swaggerPath)
-		if err != nil {
-			panic(err)
+	apiSets := []DataPlaneAPISet{}
+	for _, dataPlaneConfig := range configs {
+		if dataPlaneConfig.Name == "" || dataPlaneConfig.ResourceTemplateURL == "" || dataPlaneConfig.Endpoint == "" {
+			panic(fmt.Errorf("Data-plane API set %q must set name, resourceTemplateURL and endpoint", dataPlaneConfig.Name))
 		}
-		for _, swaggerFileInfo := range swaggerFileInfos {
-			if !swaggerFileInfo.IsDir() && strings.HasSuffix(swaggerFileInfo.Name(), ".json") {
-				print(fmt.Sprintf("\tprocessing %s/%s\n", swaggerPath, swaggerFileInfo.Name()))
-				doc := loadDoc(swaggerPath + "/" + swaggerFileInfo.Name())
-				pathPrefix := ""
-				if swaggerFileInfo.Name() == "searchindex.json" {
-					// searchindex.json uses a custom property to set a base URL that the paths in that file are relative to
-					// I couldn't find a way to retrieve it with the swagger library so adding some config here
-					pathPrefix = "/indexes('{indexName}')"
-				}
-				paths, err = swagger.MergeSwaggerDoc(paths, config, doc, true, pathPrefix)
-				if err != nil {
-					panic(err)
-				}
+		switch dataPlaneConfig.Auth.Type {
+		case swagger.DataPlaneAuthAAD:
+			if dataPlaneConfig.Auth.Audience == "" {
+				panic(fmt.Errorf("Data-plane API set %q must set the audience for aad auth", dataPlaneConfig.Name))
+			}
+		case swagger.DataPlaneAuthListKeys:
+			if dataPlaneConfig.Auth.ListKeysPath == "" || dataPlaneConfig.Auth.KeyProperty == "" || dataPlaneConfig.Auth.Header == "" {
+				panic(fmt.Errorf("Data-plane API set %q must set listKeysPath, keyProperty and header for listKeys auth", dataPlaneConfig.Name))
+			}
+		case swagger.DataPlaneAuthHMAC:
+			if dataPlaneConfig.Auth.ListKeysPath == "" || dataPlaneConfig.Auth.KeyProperty == "" || dataPlaneConfig.Auth.CredentialProperty == "" {
+				panic(fmt.Errorf("Data-plane API set %q must set listKeysPath, keyProperty and credentialProperty for hmac auth", dataPlaneConfig.Name))
 			}
+		default:
+			panic(fmt.Errorf("Data-plane API set %q has unsupported auth type %q", dataPlaneConfig.Name, dataPlaneConfig.Auth.Type))
 		}
+
+		inputFiles := dataPlaneConfig.InputFiles
+		if len(inputFiles) == 0 {
+			apiSetBuf, err := ioutil.ReadFile(dataPlaneConfig.SpecFolder + "/api-set.json")
+			if err != nil {
+				panic(err)
+			}
+			var apiSet APISet
+			err = json.Unmarshal(apiSetBuf, &apiSet)
+			if err != nil {
+				panic(err)
+			}
+			inputFiles = apiSet.InputFiles
+		}
+
+		config := &swagger.Config{Overrides: dataPlaneConfig.Overrides}
+		var paths []*swagger.Path
+		for _, inputFile := range inputFiles {
+			swaggerPath := dataPlaneConfig.SpecFolder + "/" + inputFile
+			print(fmt.Sprintf("\tprocessing %s\n", swaggerPath))
+			doc := loadDoc(swaggerPath)
+			paths, err = swagger.MergeSwaggerDoc(paths, config, doc, true, dataPlaneConfig.PathPrefixes[inputFile])
+			if err != nil {
+				panic(err)
+			}
+		}
+		apiSets = append(apiSets, DataPlaneAPISet{Config: dataPlaneConfig, Paths: paths})
 	}
-	return paths
+	return apiSets
 }
 
 func loadDoc(path string) *loads.Document {
@@ -274,6 +234,32 @@ func writeOutput(paths []*swagger.Path, config *swagger.Config, filename string,
 
 	writeTemplate(writer, paths, config, structName)
 }
+func writeDataPlaneOutput(apiSets []DataPlaneAPISet, filename string) {
+	writer, err := os.Create(filename)
+	if err != nil {
+		panic(fmt.Errorf("Error opening file: %s", err))
+	}
+	defer func() {
+		err := writer.Close()
+		if err != nil {
+			panic(fmt.Errorf("Failed to close output file: %s", err))
+		}
+	}()
+
+	funcMap := template.FuncMap{
+		"upper": strings.ToUpper,
+	}
+	t := template.Must(template.New("code-gen").Funcs(funcMap).Parse(dataPlaneTmpl))
+
+	type Context struct {
+		APISets []DataPlaneAPISet
+	}
+	err = t.Execute(writer, Context{APISets: apiSets})
+	if err != nil {
+		panic(err)
+	}
+}
+
 func writeTemplate(w io.Writer, paths []*swagger.Path, config *swagger.Config, structName string) {
 
 	funcMap := template.FuncMap{
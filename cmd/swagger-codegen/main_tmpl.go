@@ -1,6 +1,7 @@
 package main
 
-const tmpl = `
+// pathTmpl defines the templates for rendering swagger paths as ResourceTypes
+const pathTmpl = `
 {{ define "Path" -}}{{if .Operations.Get.Permitted}}
 { 
 	Display: "{{ .Name}}",
@@ -19,7 +20,9 @@ const tmpl = `
 	SubResources: {{template "PathList" .SubPaths}},{{end}}
 },{{end }}{{ end }}
 {{define "PathList"}}[]swagger.ResourceType{ {{range .}}{{template "Path" .}}{{end}} } {{end}}
-package expanders
+`
+
+const tmpl = pathTmpl + `package expanders
 
 import (
 	"github.com/lawrencegripper/azbrowse/pkg/endpoints"	
@@ -31,3 +34,40 @@ func (e *{{ .StructName }}) loadResourceTypes() []swagger.ResourceType {
 
 }
 `
+
+const dataPlaneTmpl = pathTmpl + `
+package expanders
+
+import (
+	"github.com/lawrencegripper/azbrowse/pkg/armclient"
+	"github.com/lawrencegripper/azbrowse/pkg/endpoints"
+	"github.com/lawrencegripper/azbrowse/pkg/swagger"
+)
+
+// getDataPlaneExpanders returns an expander for each of the data-plane API sets in cmd/swagger-codegen/dataplane-apisets.json
+func getDataPlaneExpanders(client *armclient.Client) []Expander {
+	return []Expander{ {{range .APISets}}
+		NewDataPlaneExpander(client, swagger.DataPlaneConfig{
+			Name: {{printf "%q" .Config.Name}},
+			SpecFolder: {{printf "%q" .Config.SpecFolder}},
+			ResourceTemplateURL: {{printf "%q" .Config.ResourceTemplateURL}},
+			ResourceAPIVersion: {{printf "%q" .Config.ResourceAPIVersion}},
+			NodeName: {{printf "%q" .Config.NodeName}},
+			Endpoint: {{printf "%q" .Config.Endpoint}},
+			Auth: swagger.DataPlaneAuth{
+				Type: {{printf "%q" .Config.Auth.Type}},{{if .Config.Auth.Audience}}
+				Audience: {{printf "%q" .Config.Auth.Audience}},{{end}}{{if .Config.Auth.ListKeysPath}}
+				ListKeysPath: {{printf "%q" .Config.Auth.ListKeysPath}},{{end}}{{if .Config.Auth.KeyProperty}}
+				KeyProperty: {{printf "%q" .Config.Auth.KeyProperty}},{{end}}{{if .Config.Auth.CredentialProperty}}
+				CredentialProperty: {{printf "%q" .Config.Auth.CredentialProperty}},{{end}}{{if .Config.Auth.Header}}
+				Header: {{printf "%q" .Config.Auth.Header}},{{end}}
+			},
+		}, load{{.Config.Name}}DataPlaneResourceTypes),{{end}}
+	}
+}
+{{range .APISets}}
+func load{{.Config.Name}}DataPlaneResourceTypes() []swagger.ResourceType {
+	return  {{template "PathList" .Paths }}
+}
+{{end}}
+`
@@ -38,6 +38,7 @@ require (
 	github.com/stuartleeks/colorjson v0.0.0-20190711214622-761cbd7eeca6
 	github.com/stuartleeks/gocui v0.4.1
 	github.com/valyala/fastjson v1.4.0
+	golang.org/x/net v0.0.0-20190311183353-d8887717615a
 	golang.org/x/oauth2 v0.0.0-20181203162652-d668ce993890 // indirect
 	golang.org/x/time v0.0.0-20191024005414-555d28b269f0
 	golang.org/x/tools v0.0.0-20190405180640-052fc3cfdbc2 // indirect
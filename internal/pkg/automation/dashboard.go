@@ -0,0 +1,26 @@
+package automation
+
+import (
+	"context"
+
+	"github.com/lawrencegripper/azbrowse/internal/pkg/errorhandling"
+	"github.com/lawrencegripper/azbrowse/internal/pkg/eventing"
+	"github.com/lawrencegripper/azbrowse/internal/pkg/views"
+)
+
+// ShowDashboard draws the named metrics dashboard in the item widget
+// once the list has been populated with the root nodes
+func ShowDashboard(ctx context.Context, content *views.ItemWidget, name string) {
+	go func() {
+		// recover from panic, if one occurrs, and leave terminal usable
+		defer errorhandling.RecoveryWithCleanup()
+
+		navigatedChannel := eventing.SubscribeToTopic("list.navigated")
+		<-navigatedChannel
+		eventing.Unsubscribe(navigatedChannel)
+
+		if err := views.ShowMetricsDashboard(ctx, content, name); err != nil {
+			eventing.SendFailureStatusFromError("Failed to open dashboard '"+name+"'", err)
+		}
+	}()
+}
@@ -5,6 +5,7 @@ import (
 	"io/ioutil"
 	"os"
 	"os/user"
+	"strings"
 )
 
 // Settings to enable different behavior on startup
@@ -16,12 +17,15 @@ type Settings struct {
 	FuzzerDurationMinutes int
 	TenantID              string // the tenant ID to get an access token for from `az account get-access-token`
 	ShouldRender          bool
+	Dashboard             string // the name of a saved metrics dashboard to show on startup
 }
 
 // Config represents the user configuration options
 type Config struct {
 	KeyBindings map[string]interface{} `json:"keyBindings,omitempty"`
 	Editor      EditorConfig           `json:"editor,omitempty"`
+	Swagger     SwaggerConfig          `json:"swagger,omitempty"`
+	APIVersions map[string]string      `json:"apiVersions,omitempty"` // The api-version to use for a resource type (e.g. "Microsoft.Web/sites") in place of the latest non-preview version
 }
 
 // EditorConfig represents the user options for external editor
@@ -32,16 +36,19 @@ type EditorConfig struct {
 	RevertToStandardBuffer  bool          `json:"revertToStandardBuffer,omitempty"`  // Set to true to revert to standard buffer while editing (e.g. for terminal-based editors)
 }
 
+// SwaggerConfig represents the user options for loading swagger specs at runtime (in addition to the compiled-in specs)
+type SwaggerConfig struct {
+	SpecFolder string `json:"specFolder,omitempty"` // A folder of swagger specs to load, e.g. a clone of the resource-manager specs from azure-rest-api-specs
+	BundleURL  string `json:"bundleURL,omitempty"`  // The URL of a zip of swagger specs to download and load
+}
+
 // CommandConfig respresents the options for launching a command
 type CommandConfig struct {
 	Executable string   `json:"executable,omitempty"` // The program to run
 	Arguments  []string `json:"args,omitempty"`       // The arguments to pass to the executable (filename will automatically be appended)
 }
 
-// Load the user configuration settings
-func Load() (Config, error) {
-	var config Config
-
+func getConfigLocation() string {
 	configLocation := os.Getenv("AZBROWSE_SETTINGS_PATH")
 	if configLocation == "" {
 		configLocation = "/root/.azbrowse-settings.json"
@@ -50,6 +57,14 @@ func Load() (Config, error) {
 			configLocation = user.HomeDir + "/.azbrowse-settings.json"
 		}
 	}
+	return configLocation
+}
+
+// Load the user configuration settings
+func Load() (Config, error) {
+	var config Config
+
+	configLocation := getConfigLocation()
 	_, err := os.Stat(configLocation)
 	if err != nil {
 		// don't error on no config file
@@ -67,6 +82,47 @@ func Load() (Config, error) {
 	return config, nil
 }
 
+// SetAPIVersion saves the api-version to use for a resource type in the user configuration settings.
+// Pass an empty apiVersion to remove the setting. Other settings in the file are left as-is
+func SetAPIVersion(armType string, apiVersion string) error {
+	configLocation := getConfigLocation()
+	settings := map[string]interface{}{}
+	bytes, err := ioutil.ReadFile(configLocation)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err == nil {
+		if err := json.Unmarshal(bytes, &settings); err != nil {
+			return err
+		}
+	}
+
+	apiVersions, _ := settings["apiVersions"].(map[string]interface{})
+	if apiVersions == nil {
+		apiVersions = map[string]interface{}{}
+	}
+	for key := range apiVersions {
+		// resource types are case-insensitive so replace any existing setting
+		if strings.EqualFold(key, armType) {
+			delete(apiVersions, key)
+		}
+	}
+	if apiVersion != "" {
+		apiVersions[armType] = apiVersion
+	}
+	if len(apiVersions) == 0 {
+		delete(settings, "apiVersions")
+	} else {
+		settings["apiVersions"] = apiVersions
+	}
+
+	bytes, err = json.MarshalIndent(settings, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(configLocation, bytes, 0600)
+}
+
 var (
 	debuggingEnabled = false
 )
@@ -2,11 +2,61 @@ package expanders
 
 import (
 	"context"
+	"fmt"
+	"time"
 
 	"github.com/lawrencegripper/azbrowse/internal/pkg/eventing"
 	"github.com/lawrencegripper/azbrowse/pkg/armclient"
 )
 
+// PendingActionTopic is the topic used to publish actions which must be confirmed in the notifications panel before they are run
+const PendingActionTopic = "action.pending"
+
+// ConfirmedActionRunner is implemented by expanders with actions that are only run once confirmed
+type ConfirmedActionRunner interface {
+	RunConfirmedAction(ctx context.Context, action *TreeNode) error
+}
+
+// ConfirmedActionTimeoutProvider can be implemented by a ConfirmedActionRunner with actions that take longer
+// than DefaultConfirmedActionTimeout to run, e.g. draining a node
+type ConfirmedActionTimeoutProvider interface {
+	ConfirmedActionTimeout(action *TreeNode) time.Duration
+}
+
+// DefaultConfirmedActionTimeout is how long a confirmed action can take to run unless the expander provides a timeout
+const DefaultConfirmedActionTimeout = 60 * time.Second
+
+// RequestActionConfirmation queues the action in the notifications panel and returns the response to show while
+// waiting for confirmation. The expander must implement ConfirmedActionRunner to run the action once confirmed
+func RequestActionConfirmation(expander Expander, action *TreeNode) ExpanderResponse {
+	action.Expander = expander
+	eventing.Publish(PendingActionTopic, action)
+	return ExpanderResponse{
+		Response:     "Confirm the action in the notifications panel to run it:\n\n  " + action.Name,
+		ResponseType: ResponsePlainText,
+	}
+}
+
+// RunConfirmedAction runs an action which has been confirmed, cancelling it if it takes longer than the action's timeout
+func RunConfirmedAction(ctx context.Context, action *TreeNode) error {
+	runner, ok := action.Expander.(ConfirmedActionRunner)
+	if !ok {
+		return fmt.Errorf("Action `%s` can't be run", action.Name)
+	}
+	ctx, cancel := context.WithTimeout(ctx, getConfirmedActionTimeout(action))
+	defer cancel()
+	return runner.RunConfirmedAction(WithNodeTenant(ctx, action), action)
+}
+
+func getConfirmedActionTimeout(action *TreeNode) time.Duration {
+	if provider, ok := action.Expander.(ConfirmedActionTimeoutProvider); ok {
+		if timeout := provider.ConfirmedActionTimeout(action); timeout > 0 {
+			return timeout
+		}
+	}
+	return DefaultConfirmedActionTimeout
+}
+
 // ActionExpander handles actions
 type ActionExpander struct {
 	ExpanderBase
@@ -22,9 +72,10 @@ func (e *ActionExpander) Name() string {
 	return "ActionExpander"
 }
 
-// DoesExpand checks if it is an action
+// DoesExpand checks if it is an ARM action. Actions listed by other expanders
+// don't have an ExpandURL and are expanded by the expander that listed them
 func (e *ActionExpander) DoesExpand(ctx context.Context, currentItem *TreeNode) (bool, error) {
-	if currentItem.ItemType == ActionType {
+	if currentItem.ItemType == ActionType && currentItem.ExpandURL != ExpandURLNotSupported {
 		return true, nil
 	}
 
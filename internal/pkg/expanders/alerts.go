@@ -0,0 +1,546 @@
+package expanders
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lawrencegripper/azbrowse/internal/pkg/eventing"
+	"github.com/lawrencegripper/azbrowse/internal/pkg/style"
+	"github.com/lawrencegripper/azbrowse/pkg/armclient"
+	"github.com/nbio/st"
+	"gopkg.in/h2non/gock.v1"
+)
+
+const (
+	alertsNamespace = "alerts"
+
+	alertsResourceType = "alerts.resource"
+	alertsActiveType   = "alerts.active"
+	alertsRuleType     = "alerts.rule"
+	alertsAlertType    = "alerts.alert"
+
+	alertsManagementAPIVersion  = "2019-05-05-preview"
+	metricAlertsAPIVersion      = "2018-03-01"
+	activityLogAlertsAPIVersion = "2017-04-01"
+
+	metricAlertRuleKind      = "metric"
+	activityLogAlertRuleKind = "activityLog"
+)
+
+type alertRuleListResponse struct {
+	Value []alertRule `json:"value"`
+}
+
+type alertRule struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	Properties struct {
+		Description string   `json:"description"`
+		Severity    *int     `json:"severity"`
+		Enabled     bool     `json:"enabled"`
+		Scopes      []string `json:"scopes"`
+	} `json:"properties"`
+}
+
+type alertListResponse struct {
+	Value    []alert `json:"value"`
+	NextLink string  `json:"nextLink"`
+}
+
+type alert struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	Properties struct {
+		Essentials struct {
+			Severity         string    `json:"severity"`
+			SignalType       string    `json:"signalType"`
+			AlertState       string    `json:"alertState"`
+			MonitorCondition string    `json:"monitorCondition"`
+			TargetResource   string    `json:"targetResource"`
+			AlertRule        string    `json:"alertRule"`
+			StartDateTime    time.Time `json:"startDateTime"`
+		} `json:"essentials"`
+	} `json:"properties"`
+}
+
+// Check interface
+var _ Expander = &AlertsExpander{}
+
+// AlertsExpander shows the alert rules targeting a resource along with the alerts they have fired
+// and the active alerts in a subscription
+type AlertsExpander struct {
+	ExpanderBase
+	client *armclient.Client
+}
+
+func (e *AlertsExpander) setClient(c *armclient.Client) {
+	e.client = c
+}
+
+// Name returns the name of the expander
+func (e *AlertsExpander) Name() string {
+	return "AlertsExpander"
+}
+
+// DoesExpand checks if this is a resource, subscription or alerts node
+func (e *AlertsExpander) DoesExpand(ctx context.Context, currentItem *TreeNode) (bool, error) {
+	if currentItem.ItemType == ResourceType || currentItem.ItemType == SubscriptionType {
+		return true, nil
+	}
+	if currentItem.Namespace == alertsNamespace {
+		return true, nil
+	}
+	return false, nil
+}
+
+// Expand adds the alerts nodes to resources and subscriptions and expands them
+func (e *AlertsExpander) Expand(ctx context.Context, currentItem *TreeNode) ExpanderResult {
+	switch currentItem.ItemType {
+	case ResourceType:
+		return e.newPlaceholderResult(currentItem, alertsResourceType, "Alerts", currentItem.ID+"/<alerts>")
+	case SubscriptionType:
+		return e.newPlaceholderResult(currentItem, alertsActiveType, "Active alerts", currentItem.ID+"/<activeAlerts>")
+	case alertsResourceType:
+		return e.expandResourceAlerts(ctx, currentItem)
+	case alertsActiveType:
+		return e.expandActiveAlerts(ctx, currentItem)
+	case ActionType:
+		return e.executeAction(ctx, currentItem)
+	}
+
+	return ExpanderResult{
+		Err:               fmt.Errorf("Error - unhandled Expand"),
+		Response:          ExpanderResponse{Response: "Error!"},
+		SourceDescription: "AlertsExpander request",
+	}
+}
+
+func (e *AlertsExpander) newPlaceholderResult(currentItem *TreeNode, itemType string, name string, id string) ExpanderResult {
+	return ExpanderResult{
+		Nodes: []*TreeNode{
+			{
+				Parentid:       currentItem.ID,
+				ID:             id,
+				Namespace:      alertsNamespace,
+				Name:           name,
+				Display:        style.Subtle("[Alerts]") + "\n  " + name,
+				ItemType:       itemType,
+				ExpandURL:      ExpandURLNotSupported,
+				SubscriptionID: currentItem.SubscriptionID,
+				Metadata: map[string]string{
+					"ResourceID":            currentItem.ID,
+					"SuppressSwaggerExpand": "true",
+					"SuppressGenericExpand": "true",
+				},
+			},
+		},
+		SourceDescription: "AlertsExpander request",
+		IsPrimaryResponse: false,
+	}
+}
+
+// expandResourceAlerts lists the metric and activity log alert rules that target the resource
+// with the state of the latest alert they fired followed by the open alerts for the resource
+func (e *AlertsExpander) expandResourceAlerts(ctx context.Context, currentItem *TreeNode) ExpanderResult {
+	resourceID := currentItem.Metadata["ResourceID"]
+	subscriptionID := getSubscriptionIDFromResourceID(resourceID)
+
+	alerts, err := e.getAlerts(ctx, subscriptionID, "targetResource="+url.QueryEscape(resourceID))
+	if err != nil {
+		return e.errorResult(err)
+	}
+	latestAlertByRule := map[string]alert{}
+	for _, a := range alerts {
+		ruleID := strings.ToLower(a.Properties.Essentials.AlertRule)
+		latest, ok := latestAlertByRule[ruleID]
+		if !ok || a.Properties.Essentials.StartDateTime.After(latest.Properties.Essentials.StartDateTime) {
+			latestAlertByRule[ruleID] = a
+		}
+	}
+
+	newItems := []*TreeNode{}
+	ruleSources := []struct {
+		kind       string
+		provider   string
+		apiVersion string
+	}{
+		{metricAlertRuleKind, "metricAlerts", metricAlertsAPIVersion},
+		{activityLogAlertRuleKind, "activityLogAlerts", activityLogAlertsAPIVersion},
+	}
+	for _, source := range ruleSources {
+		data, err := e.client.DoRequest(ctx, "GET", subscriptionID+"/providers/Microsoft.Insights/"+source.provider+"?api-version="+source.apiVersion)
+		if err != nil {
+			return e.errorResult(fmt.Errorf("Failed to list %s: %s", source.provider, err))
+		}
+		var rules alertRuleListResponse
+		err = json.Unmarshal([]byte(data), &rules)
+		if err != nil {
+			return e.errorResult(fmt.Errorf("Error unmarshalling %s: %s", source.provider, err))
+		}
+		for _, rule := range rules.Value {
+			if !alertRuleTargetsResource(rule, resourceID) {
+				continue
+			}
+			latest, hasFired := latestAlertByRule[strings.ToLower(rule.ID)]
+			newItems = append(newItems, e.newRuleNode(currentItem, rule, source.kind, source.apiVersion, latest, hasFired))
+		}
+	}
+
+	for _, a := range alerts {
+		if a.Properties.Essentials.AlertState == "Closed" {
+			continue
+		}
+		newItems = append(newItems, e.newAlertNode(currentItem, a))
+	}
+
+	return ExpanderResult{
+		Response:          ExpanderResponse{Response: fmt.Sprintf("Found %d alert rules and alerts for %s", len(newItems), resourceID), ResponseType: ResponsePlainText},
+		Nodes:             newItems,
+		SourceDescription: "AlertsExpander request",
+		IsPrimaryResponse: true,
+	}
+}
+
+// expandActiveAlerts lists the fired alerts in the subscription which haven't been closed, most severe first
+func (e *AlertsExpander) expandActiveAlerts(ctx context.Context, currentItem *TreeNode) ExpanderResult {
+	alerts, err := e.getAlerts(ctx, currentItem.Metadata["ResourceID"], "monitorCondition=Fired")
+	if err != nil {
+		return e.errorResult(err)
+	}
+
+	active := []alert{}
+	for _, a := range alerts {
+		if a.Properties.Essentials.AlertState != "Closed" {
+			active = append(active, a)
+		}
+	}
+	// Severities are named Sev0 (critical) to Sev4 (verbose) so sort by name
+	sort.SliceStable(active, func(i, j int) bool {
+		essentialsI := active[i].Properties.Essentials
+		essentialsJ := active[j].Properties.Essentials
+		if essentialsI.Severity != essentialsJ.Severity {
+			return essentialsI.Severity < essentialsJ.Severity
+		}
+		return essentialsI.StartDateTime.After(essentialsJ.StartDateTime)
+	})
+
+	newItems := []*TreeNode{}
+	for _, a := range active {
+		newItems = append(newItems, e.newAlertNode(currentItem, a))
+	}
+
+	return ExpanderResult{
+		Response:          ExpanderResponse{Response: fmt.Sprintf("Found %d active alerts", len(newItems)), ResponseType: ResponsePlainText},
+		Nodes:             newItems,
+		SourceDescription: "AlertsExpander request",
+		IsPrimaryResponse: true,
+	}
+}
+
+// getAlerts lists the alerts in the subscription matching the query, following any next links
+func (e *AlertsExpander) getAlerts(ctx context.Context, subscriptionID string, query string) ([]alert, error) {
+	alerts := []alert{}
+	nextURL := subscriptionID + "/providers/Microsoft.AlertsManagement/alerts?api-version=" + alertsManagementAPIVersion + "&" + query
+	for nextURL != "" {
+		data, err := e.client.DoRequest(ctx, "GET", nextURL)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to list alerts: %s", err)
+		}
+		var response alertListResponse
+		err = json.Unmarshal([]byte(data), &response)
+		if err != nil {
+			return nil, fmt.Errorf("Error unmarshalling alerts: %s", err)
+		}
+		alerts = append(alerts, response.Value...)
+		nextURL, err = getNextLinkPath(response.NextLink)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return alerts, nil
+}
+
+func (e *AlertsExpander) newRuleNode(currentItem *TreeNode, rule alertRule, kind string, apiVersion string, latest alert, hasFired bool) *TreeNode {
+	details := []string{}
+	if kind == metricAlertRuleKind {
+		details = append(details, "Metric rule")
+	} else {
+		details = append(details, "Activity log rule")
+	}
+	if rule.Properties.Severity != nil {
+		details = append(details, fmt.Sprintf("Sev%d", *rule.Properties.Severity))
+	}
+	if !rule.Properties.Enabled {
+		details = append(details, "Disabled")
+	}
+
+	state := "No recent alerts"
+	statusIndicator := "☼"
+	if hasFired {
+		essentials := latest.Properties.Essentials
+		state = essentials.MonitorCondition + " (" + essentials.AlertState + ") at " + essentials.StartDateTime.Local().Format("2006-01-02 15:04")
+		if essentials.MonitorCondition == "Fired" {
+			statusIndicator = "⛈"
+		}
+	}
+	if !rule.Properties.Enabled {
+		statusIndicator = "⛔"
+	}
+
+	return &TreeNode{
+		Parentid:        currentItem.ID,
+		ID:              rule.ID,
+		Namespace:       alertsNamespace,
+		Name:            rule.Name,
+		Display:         rule.Name + "\n  " + style.Subtle(strings.Join(details, " · ")) + "\n  " + style.Subtle(state),
+		ItemType:        alertsRuleType,
+		ExpandURL:       rule.ID + "?api-version=" + apiVersion,
+		SubscriptionID:  currentItem.SubscriptionID,
+		StatusIndicator: statusIndicator,
+		Metadata: map[string]string{
+			"RuleKind":              kind,
+			"Enabled":               fmt.Sprintf("%t", rule.Properties.Enabled),
+			"APIVersion":            apiVersion,
+			"SuppressSwaggerExpand": "true",
+		},
+	}
+}
+
+func (e *AlertsExpander) newAlertNode(currentItem *TreeNode, a alert) *TreeNode {
+	essentials := a.Properties.Essentials
+	targetName := essentials.TargetResource[strings.LastIndex(essentials.TargetResource, "/")+1:]
+	statusIndicator := "☼"
+	if essentials.MonitorCondition == "Fired" {
+		statusIndicator = "⛈"
+	}
+	return &TreeNode{
+		Parentid:        currentItem.ID,
+		ID:              a.ID,
+		Namespace:       alertsNamespace,
+		Name:            a.Name,
+		Display:         essentials.Severity + " " + a.Name + "\n  " + style.Subtle(essentials.MonitorCondition+" ("+essentials.AlertState+") · "+targetName) + "\n  " + style.Subtle("Started: "+essentials.StartDateTime.Local().Format("2006-01-02 15:04")),
+		ItemType:        alertsAlertType,
+		ExpandURL:       a.ID + "?api-version=" + alertsManagementAPIVersion,
+		SubscriptionID:  currentItem.SubscriptionID,
+		StatusIndicator: statusIndicator,
+		Metadata: map[string]string{
+			"AlertState":            essentials.AlertState,
+			"SuppressSwaggerExpand": "true",
+		},
+	}
+}
+
+// HasActions checks if the item is an alert rule or alert
+func (e *AlertsExpander) HasActions(ctx context.Context, currentItem *TreeNode) (bool, error) {
+	return currentItem.Namespace == alertsNamespace &&
+		(currentItem.ItemType == alertsRuleType || currentItem.ItemType == alertsAlertType), nil
+}
+
+// ListActions returns the enable/disable actions for rules and acknowledge/close actions for alerts
+func (e *AlertsExpander) ListActions(ctx context.Context, currentItem *TreeNode) ListActionsResult {
+	nodes := []*TreeNode{}
+	switch currentItem.ItemType {
+	case alertsRuleType:
+		if currentItem.Metadata["Enabled"] == "true" {
+			nodes = append(nodes, e.newActionNode(currentItem, "disable", "Disable rule"))
+		} else {
+			nodes = append(nodes, e.newActionNode(currentItem, "enable", "Enable rule"))
+		}
+	case alertsAlertType:
+		if currentItem.Metadata["AlertState"] == "New" {
+			nodes = append(nodes, e.newActionNode(currentItem, "acknowledge", "Acknowledge alert"))
+		}
+		nodes = append(nodes, e.newActionNode(currentItem, "close", "Close alert"))
+	}
+	return ListActionsResult{
+		Nodes:             nodes,
+		SourceDescription: "AlertsExpander request",
+	}
+}
+
+func (e *AlertsExpander) newActionNode(currentItem *TreeNode, actionID string, display string) *TreeNode {
+	return &TreeNode{
+		Parentid:  currentItem.ID,
+		ID:        currentItem.ID + "/<action:" + actionID + ">",
+		Namespace: alertsNamespace,
+		Name:      display,
+		Display:   display,
+		ItemType:  ActionType,
+		ExpandURL: ExpandURLNotSupported,
+		Metadata: map[string]string{
+			"ActionID":              actionID,
+			"TargetID":              currentItem.ID,
+			"APIVersion":            currentItem.Metadata["APIVersion"],
+			"SuppressSwaggerExpand": "true",
+			"SuppressGenericExpand": "true",
+		},
+	}
+}
+
+func (e *AlertsExpander) executeAction(ctx context.Context, currentItem *TreeNode) ExpanderResult {
+	targetID := currentItem.Metadata["TargetID"]
+
+	var method, path, body, message string
+	switch currentItem.Metadata["ActionID"] {
+	case "enable", "disable":
+		enabled := currentItem.Metadata["ActionID"] == "enable"
+		method = "PATCH"
+		path = targetID + "?api-version=" + currentItem.Metadata["APIVersion"]
+		body = fmt.Sprintf(`{"properties":{"enabled":%t}}`, enabled)
+		message = currentItem.Metadata["ActionID"] + "d rule " + targetID
+	case "acknowledge", "close":
+		newState := "Acknowledged"
+		if currentItem.Metadata["ActionID"] == "close" {
+			newState = "Closed"
+		}
+		method = "POST"
+		path = targetID + "/changestate?api-version=" + alertsManagementAPIVersion + "&newState=" + newState
+		message = "Changed alert state to " + newState + ": " + targetID
+	default:
+		return e.errorResult(fmt.Errorf("Unhandled action: %s", currentItem.Metadata["ActionID"]))
+	}
+
+	status, _ := eventing.SendStatusEvent(&eventing.StatusEvent{
+		InProgress: true,
+		IsToast:    true,
+		Message:    currentItem.Name + ": " + targetID,
+	})
+	data, err := e.client.DoRequestWithBody(ctx, method, path, body)
+	if err != nil {
+		status.Failure = true
+		status.Message = "Failed to " + strings.ToLower(currentItem.Name) + ": " + err.Error()
+	} else {
+		status.Message = strings.ToUpper(message[:1]) + message[1:]
+	}
+	status.Done()
+
+	return ExpanderResult{
+		Err:               err,
+		Response:          ExpanderResponse{Response: data, ResponseType: ResponseJSON},
+		SourceDescription: "AlertsExpander request",
+		IsPrimaryResponse: true,
+	}
+}
+
+func (e *AlertsExpander) errorResult(err error) ExpanderResult {
+	return ExpanderResult{
+		Err:               err,
+		Response:          ExpanderResponse{Response: err.Error(), ResponseType: ResponsePlainText},
+		SourceDescription: "AlertsExpander request",
+		IsPrimaryResponse: true,
+	}
+}
+
+// alertRuleTargetsResource checks whether one of the rule's scopes is the resource or contains it
+func alertRuleTargetsResource(rule alertRule, resourceID string) bool {
+	resourceID = strings.ToLower(resourceID)
+	for _, scope := range rule.Properties.Scopes {
+		scope = strings.ToLower(strings.TrimSuffix(scope, "/"))
+		if scope == resourceID || strings.HasPrefix(resourceID, scope+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// getSubscriptionIDFromResourceID returns the "/subscriptions/{id}" prefix of the resource ID
+func getSubscriptionIDFromResourceID(resourceID string) string {
+	parts := strings.Split(resourceID, "/")
+	if len(parts) < 3 {
+		return resourceID
+	}
+	return strings.Join(parts[0:3], "/")
+}
+
+func (e *AlertsExpander) testCases() (bool, *[]expanderTestCase) {
+	const subscriptionID = "/subscriptions/00000000-0000-0000-0000-000000000000"
+	const resourceID = subscriptionID + "/resourceGroups/stable/providers/Microsoft.Web/sites/testsite"
+
+	resourceGockConfig := func(t *testing.T) {
+		gock.New("https://management.azure.com").
+			Get(subscriptionID+"/providers/Microsoft.AlertsManagement/alerts").
+			MatchParam("targetResource", resourceID).
+			Reply(200).
+			File("./testdata/armsamples/alerts/resourceAlerts.json")
+		gock.New("https://management.azure.com").
+			Get(subscriptionID + "/providers/Microsoft.Insights/metricAlerts").
+			Reply(200).
+			File("./testdata/armsamples/alerts/metricAlerts.json")
+		gock.New("https://management.azure.com").
+			Get(subscriptionID + "/providers/Microsoft.Insights/activityLogAlerts").
+			Reply(200).
+			File("./testdata/armsamples/alerts/activityLogAlerts.json")
+	}
+	activeGockConfig := func(t *testing.T) {
+		gock.New("https://management.azure.com").
+			Get(subscriptionID+"/providers/Microsoft.AlertsManagement/alerts").
+			MatchParam("monitorCondition", "Fired").
+			Reply(200).
+			File("./testdata/armsamples/alerts/activeAlerts.json")
+	}
+
+	return true, &[]expanderTestCase{
+		{
+			name: "Resource->Alerts",
+			nodeToExpand: &TreeNode{
+				ID:        resourceID + "/<alerts>",
+				Namespace: alertsNamespace,
+				ItemType:  alertsResourceType,
+				ExpandURL: ExpandURLNotSupported,
+				Metadata:  map[string]string{"ResourceID": resourceID},
+			},
+			configureGockFunc: &resourceGockConfig,
+			treeNodeCheckerFunc: func(t *testing.T, r ExpanderResult) {
+				st.Expect(t, r.Err, nil)
+				st.Expect(t, len(r.Nodes), 4)
+
+				// metric rule targeting the site has fired
+				st.Expect(t, r.Nodes[0].Name, "high-cpu")
+				st.Expect(t, r.Nodes[0].ItemType, alertsRuleType)
+				st.Expect(t, r.Nodes[0].StatusIndicator, "⛈")
+				st.Expect(t, strings.Contains(r.Nodes[0].Display, "Fired (New)"), true)
+
+				// rule scopes are matched ignoring case
+				st.Expect(t, r.Nodes[1].Name, "slow-responses")
+				st.Expect(t, strings.Contains(r.Nodes[1].Display, "Resolved (Closed)"), true)
+
+				// activity log rule scoped to the resource group
+				st.Expect(t, r.Nodes[2].Name, "rg-restarts")
+				st.Expect(t, r.Nodes[2].Metadata["Enabled"], "false")
+				st.Expect(t, r.Nodes[2].StatusIndicator, "⛔")
+
+				// open alert for the resource
+				st.Expect(t, r.Nodes[3].ItemType, alertsAlertType)
+				st.Expect(t, r.Nodes[3].Name, "high-cpu")
+
+				actions := (&AlertsExpander{}).ListActions(context.Background(), r.Nodes[3])
+				st.Expect(t, len(actions.Nodes), 2)
+				st.Expect(t, actions.Nodes[0].Name, "Acknowledge alert")
+			},
+		},
+		{
+			name: "Subscription->ActiveAlerts",
+			nodeToExpand: &TreeNode{
+				ID:        subscriptionID + "/<activeAlerts>",
+				Namespace: alertsNamespace,
+				ItemType:  alertsActiveType,
+				ExpandURL: ExpandURLNotSupported,
+				Metadata:  map[string]string{"ResourceID": subscriptionID},
+			},
+			configureGockFunc: &activeGockConfig,
+			treeNodeCheckerFunc: func(t *testing.T, r ExpanderResult) {
+				st.Expect(t, r.Err, nil)
+				// closed alerts are excluded and the rest are sorted by severity
+				st.Expect(t, len(r.Nodes), 2)
+				st.Expect(t, r.Nodes[0].Name, "db-down")
+				st.Expect(t, r.Nodes[1].Name, "high-cpu")
+			},
+		},
+	}
+}
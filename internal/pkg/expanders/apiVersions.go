@@ -0,0 +1,193 @@
+package expanders
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/lawrencegripper/azbrowse/internal/pkg/config"
+	"github.com/lawrencegripper/azbrowse/pkg/armclient"
+)
+
+const (
+	// APIVersionOptionRefresh is the ID of the option to refresh the available api-versions from ARM
+	APIVersionOptionRefresh = "refresh"
+	apiVersionOptionDefault = "default"
+	apiVersionOptionPrefix  = "version:"
+)
+
+var apiVersionQueryRegex = regexp.MustCompile(`(?i)([?&]api-version=)[^&]*`)
+
+// APIVersionOption is an api-version that can be selected for a node
+type APIVersionOption struct {
+	ID          string
+	DisplayText string
+}
+
+// LoadAPIVersionOverrides applies the api-versions selected for resource types in the user's settings
+func LoadAPIVersionOverrides() error {
+	userConfig, err := config.Load()
+	if err != nil {
+		return err
+	}
+	armclient.SetAPIVersionOverrides(userConfig.APIVersions)
+	return nil
+}
+
+// GetNodeARMType returns the ARM resource type of the node (e.g. Microsoft.Web/sites/slots) or "" if it isn't an ARM resource
+func GetNodeARMType(node *TreeNode) string {
+	if node == nil {
+		return ""
+	}
+	if node.ArmType != "" {
+		return node.ArmType
+	}
+	return getARMTypeFromURL(node.ExpandURL)
+}
+
+// CanSelectAPIVersion returns true if the node is requested from ARM with an api-version that can be changed
+func CanSelectAPIVersion(node *TreeNode) bool {
+	if node == nil || !apiVersionQueryRegex.MatchString(node.ExpandURL) {
+		return false
+	}
+	armType := GetNodeARMType(node)
+	if armType == "" {
+		return false
+	}
+	_, err := armclient.GetAPIVersions(armType)
+	return err == nil
+}
+
+// GetAPIVersionOptions returns the api-versions available for the node's resource type along with options
+// to go back to the default api-version and to refresh the available api-versions
+func GetAPIVersionOptions(node *TreeNode) ([]APIVersionOption, error) {
+	armType := GetNodeARMType(node)
+	apiVersions, err := armclient.GetAPIVersions(armType)
+	if err != nil {
+		return []APIVersionOption{}, err
+	}
+
+	current := getURLAPIVersion(node.ExpandURL)
+	options := []APIVersionOption{}
+	for _, apiVersion := range apiVersions {
+		display := apiVersion
+		if armclient.IsPreviewAPIVersion(apiVersion) {
+			display += " (preview)"
+		}
+		if strings.EqualFold(apiVersion, current) {
+			display += " (current)"
+		}
+		options = append(options, APIVersionOption{
+			ID:          apiVersionOptionPrefix + apiVersion,
+			DisplayText: display,
+		})
+	}
+	if armclient.GetAPIVersionOverride(armType) != "" {
+		options = append(options, APIVersionOption{
+			ID:          apiVersionOptionDefault,
+			DisplayText: "Use the default api-version for " + armType,
+		})
+	}
+	options = append(options, APIVersionOption{
+		ID:          APIVersionOptionRefresh,
+		DisplayText: "Refresh the available api-versions",
+	})
+	return options, nil
+}
+
+// ApplyAPIVersionOption updates the node to use either the selected api-version option or the api-version entered by the user.
+// The api-version is saved in the user's settings and used for all resources of the same type
+func ApplyAPIVersionOption(node *TreeNode, optionID string, text string) error {
+	switch {
+	case optionID == "":
+		apiVersion := strings.TrimSpace(text)
+		if apiVersion == "" {
+			return fmt.Errorf("An api-version is required")
+		}
+		return SetNodeAPIVersion(node, apiVersion)
+	case optionID == apiVersionOptionDefault:
+		return SetNodeAPIVersion(node, "")
+	case strings.HasPrefix(optionID, apiVersionOptionPrefix):
+		return SetNodeAPIVersion(node, strings.TrimPrefix(optionID, apiVersionOptionPrefix))
+	}
+	return fmt.Errorf("Unknown option '%s'", optionID)
+}
+
+// SetNodeAPIVersion sets the api-version to use for the node's resource type and updates the node to use it.
+// Pass an empty apiVersion to go back to the default api-version
+func SetNodeAPIVersion(node *TreeNode, apiVersion string) error {
+	armType := GetNodeARMType(node)
+	if armType == "" {
+		return fmt.Errorf("Unable to determine the resource type for %s", node.Name)
+	}
+
+	armclient.SetAPIVersionOverride(armType, apiVersion)
+	urlAPIVersion := apiVersion
+	if urlAPIVersion == "" {
+		var err error
+		urlAPIVersion, err = armclient.GetAPIVersion(armType)
+		if err != nil {
+			return err
+		}
+	}
+	node.ExpandURL = setURLAPIVersion(node.ExpandURL, urlAPIVersion)
+
+	err := config.SetAPIVersion(armType, apiVersion)
+	if err != nil {
+		return fmt.Errorf("Failed to save the api-version to the settings: %s", err)
+	}
+	return nil
+}
+
+// applyAPIVersionOverride replaces the api-version in an ARM URL if an api-version has been selected for the resource type
+func applyAPIVersionOverride(resourceURL string) string {
+	armType := getARMTypeFromURL(resourceURL)
+	if armType == "" {
+		return resourceURL
+	}
+	if apiVersion := armclient.GetAPIVersionOverride(armType); apiVersion != "" {
+		return setURLAPIVersion(resourceURL, apiVersion)
+	}
+	return resourceURL
+}
+
+// getARMTypeFromURL returns the resource type for an ARM URL, e.g. Microsoft.Web/sites/slots for
+// /subscriptions/{id}/resourceGroups/{rg}/providers/Microsoft.Web/sites/{site}/slots/{slot}
+func getARMTypeFromURL(resourceURL string) string {
+	path := stripQueryString(resourceURL)
+	if !strings.HasPrefix(strings.ToLower(path), "/subscriptions/") {
+		return ""
+	}
+	// use the last provider for extension resources
+	index := strings.LastIndex(strings.ToLower(path), "/providers/")
+	if index < 0 {
+		return ""
+	}
+	segments := strings.Split(strings.Trim(path[index+len("/providers/"):], "/"), "/")
+	if len(segments) < 2 {
+		return ""
+	}
+	armType := segments[0]
+	for i := 1; i < len(segments); i += 2 {
+		armType += "/" + segments[i]
+	}
+	return armType
+}
+
+func getURLAPIVersion(resourceURL string) string {
+	match := apiVersionQueryRegex.FindString(resourceURL)
+	if match == "" {
+		return ""
+	}
+	return match[strings.Index(match, "=")+1:]
+}
+
+func setURLAPIVersion(resourceURL string, apiVersion string) string {
+	if apiVersionQueryRegex.MatchString(resourceURL) {
+		return apiVersionQueryRegex.ReplaceAllString(resourceURL, "${1}"+apiVersion)
+	}
+	if strings.Contains(resourceURL, "?") {
+		return resourceURL + "&api-version=" + apiVersion
+	}
+	return resourceURL + "?api-version=" + apiVersion
+}
@@ -0,0 +1,92 @@
+package expanders
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lawrencegripper/azbrowse/internal/pkg/config"
+	"github.com/lawrencegripper/azbrowse/pkg/armclient"
+	"github.com/nbio/st"
+	"gopkg.in/h2non/gock.v1"
+)
+
+const testSiteID = "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/stable/providers/Microsoft.Web/sites/testsite"
+
+func Test_getARMTypeFromURL(t *testing.T) {
+	st.Expect(t, getARMTypeFromURL(testSiteID+"?api-version=2019-08-01"), "Microsoft.Web/sites")
+	st.Expect(t, getARMTypeFromURL(testSiteID+"/slots/staging"), "Microsoft.Web/sites/slots")
+	st.Expect(t, getARMTypeFromURL("/subscriptions/00000000-0000-0000-0000-000000000000/providers/Microsoft.Web/sites"), "Microsoft.Web/sites")
+	st.Expect(t, getARMTypeFromURL(testSiteID+"/providers/Microsoft.Insights/diagnosticSettings/test"), "Microsoft.Insights/diagnosticSettings")
+	st.Expect(t, getARMTypeFromURL("/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/stable"), "")
+	st.Expect(t, getARMTypeFromURL("https://test.search.windows.net/indexes"), "")
+}
+
+func Test_setURLAPIVersion(t *testing.T) {
+	st.Expect(t, setURLAPIVersion(testSiteID+"?api-version=2019-08-01", "2020-01-01"), testSiteID+"?api-version=2020-01-01")
+	st.Expect(t, setURLAPIVersion(testSiteID+"?$top=5&api-version=2019-08-01&$skip=1", "2020-01-01"), testSiteID+"?$top=5&api-version=2020-01-01&$skip=1")
+	st.Expect(t, setURLAPIVersion(testSiteID+"?$top=5", "2020-01-01"), testSiteID+"?$top=5&api-version=2020-01-01")
+	st.Expect(t, setURLAPIVersion(testSiteID, "2020-01-01"), testSiteID+"?api-version=2020-01-01")
+	st.Expect(t, getURLAPIVersion(testSiteID+"?API-Version=2019-08-01&$top=5"), "2019-08-01")
+}
+
+func Test_SetNodeAPIVersion(t *testing.T) {
+	defer gock.Off()
+	defer armclient.SetAPIVersionOverrides(nil)
+
+	folder, err := ioutil.TempDir("", "azbrowse-settings")
+	st.Expect(t, err, nil)
+	defer os.RemoveAll(folder) //nolint: errcheck
+	settingsPath := filepath.Join(folder, "settings.json")
+	st.Expect(t, ioutil.WriteFile(settingsPath, []byte(`{"editor": {"tempDir": "/tmp"}}`), 0600), nil)
+	os.Setenv("AZBROWSE_SETTINGS_PATH", settingsPath) //nolint: errcheck
+	defer os.Unsetenv("AZBROWSE_SETTINGS_PATH")       //nolint: errcheck
+
+	httpClient := &http.Client{Transport: &http.Transport{}}
+	gock.InterceptClient(httpClient)
+	gock.New("https://management.azure.com").
+		Get("/providers").
+		Reply(200).
+		File("testdata/armsamples/providers/response.json")
+	client := armclient.NewClientFromConfig(httpClient, DummyTokenFunc(), 5000)
+	st.Expect(t, client.RefreshResourceAPILookup(context.Background()), nil)
+
+	node := &TreeNode{
+		Name:      "testsite",
+		ExpandURL: testSiteID + "?api-version=2019-08-01",
+		ArmType:   "Microsoft.Web/sites",
+	}
+	st.Expect(t, CanSelectAPIVersion(node), true)
+	options, err := GetAPIVersionOptions(node)
+	st.Expect(t, err, nil)
+	st.Expect(t, options[0], APIVersionOption{ID: "version:2019-08-01", DisplayText: "2019-08-01 (current)"})
+	st.Expect(t, options[len(options)-1].ID, APIVersionOptionRefresh)
+
+	// selecting a preview version updates the node and saves the setting
+	st.Expect(t, ApplyAPIVersionOption(node, "version:2015-08-01-preview", ""), nil)
+	st.Expect(t, node.ExpandURL, testSiteID+"?api-version=2015-08-01-preview")
+	apiVersion, err := armclient.GetAPIVersion("Microsoft.Web/sites")
+	st.Expect(t, err, nil)
+	st.Expect(t, apiVersion, "2015-08-01-preview")
+	userConfig, err := config.Load()
+	st.Expect(t, err, nil)
+	st.Expect(t, userConfig.APIVersions, map[string]string{"Microsoft.Web/sites": "2015-08-01-preview"})
+	st.Expect(t, userConfig.Editor.TempDir, "/tmp")
+
+	// the selected version is used for swagger URLs for the same type
+	st.Expect(t, applyAPIVersionOverride(testSiteID+"/slots/staging?api-version=2019-08-01"), testSiteID+"/slots/staging?api-version=2019-08-01")
+	st.Expect(t, applyAPIVersionOverride(testSiteID+"?api-version=2019-08-01"), testSiteID+"?api-version=2015-08-01-preview")
+
+	// going back to the default removes the setting
+	options, err = GetAPIVersionOptions(node)
+	st.Expect(t, err, nil)
+	st.Expect(t, options[len(options)-2].ID, "default")
+	st.Expect(t, ApplyAPIVersionOption(node, "default", ""), nil)
+	st.Expect(t, node.ExpandURL, testSiteID+"?api-version=2019-08-01")
+	userConfig, err = config.Load()
+	st.Expect(t, err, nil)
+	st.Expect(t, len(userConfig.APIVersions), 0)
+}
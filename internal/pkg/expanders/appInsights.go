@@ -42,7 +42,7 @@ func (e *AppInsightsExpander) Name() string {
 func (e *AppInsightsExpander) DoesExpand(ctx context.Context, currentItem *TreeNode) (bool, error) {
 	swaggerResourceType := currentItem.SwaggerResourceType
 	if currentItem.ItemType == "resource" && swaggerResourceType != nil {
-		if swaggerResourceType.Endpoint.TemplateURL == "/subscriptions/{subscriptionId}/resourceGroups/{resourceGroupName}/providers/Microsoft.Insights/components/{resourceName}" {
+		if swaggerResourceType.Endpoint.TemplateURL == appInsightsTemplateURL {
 			return true, nil
 		}
 	}
@@ -58,7 +58,7 @@ func (e *AppInsightsExpander) Expand(ctx context.Context, currentItem *TreeNode)
 	swaggerResourceType := currentItem.SwaggerResourceType
 	if currentItem.Namespace != "AppInsights" &&
 		swaggerResourceType != nil &&
-		swaggerResourceType.Endpoint.TemplateURL == "/subscriptions/{subscriptionId}/resourceGroups/{resourceGroupName}/providers/Microsoft.Insights/components/{resourceName}" {
+		swaggerResourceType.Endpoint.TemplateURL == appInsightsTemplateURL {
 		newItems := []*TreeNode{}
 		resourceAPIVersion, err := armclient.GetAPIVersion(currentItem.ArmType)
 		if err != nil {
@@ -150,6 +150,9 @@ func (e *AppInsightsExpander) expandAnalyticsItems(ctx context.Context, currentI
 			ExpandURL: appInsightsID + "/" + collectionName + "/item?api-version=" + resourceAPIVersion + "&id=" + item.ID,
 			DeleteURL: appInsightsID + "/" + collectionName + "/item?api-version=" + resourceAPIVersion + "&id=" + item.ID,
 			Display:   style.Subtle("["+item.Type+" - "+item.Scope+"]") + "\n " + item.Name,
+			Metadata: map[string]string{
+				"AppInsightsID": appInsightsID,
+			},
 		}
 		newItems = append(newItems, &newItem)
 	}
@@ -176,6 +179,18 @@ func (e *AppInsightsExpander) expandAnalyticsItem(ctx context.Context, currentIt
 		}
 	}
 
+	// Saved queries can be run against the component
+	var item analyticsItem
+	err = json.Unmarshal([]byte(data), &item)
+	if err == nil && item.Type == "query" && item.Content != "" {
+		queryNode := NewLogQueryNode(currentItem.Metadata["AppInsightsID"], item.Content)
+		queryNode.ID = currentItem.ID + "/<run>"
+		queryNode.Parentid = currentItem.ID
+		queryNode.Name = "Run query"
+		queryNode.Display = "Run query"
+		newItems = append(newItems, queryNode)
+	}
+
 	return ExpanderResult{
 		IsPrimaryResponse: true,
 		Nodes:             newItems,
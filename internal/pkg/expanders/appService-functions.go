@@ -0,0 +1,188 @@
+package expanders
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/lawrencegripper/azbrowse/internal/pkg/style"
+)
+
+const (
+	appServiceFunctionsType = "appService.functions"
+	appServiceFunctionType  = "appService.function"
+)
+
+type appServiceFunction struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	Properties struct {
+		Name   string `json:"name"`
+		Config struct {
+			Bindings []struct {
+				Type      string `json:"type"`
+				Direction string `json:"direction"`
+				Name      string `json:"name"`
+			} `json:"bindings"`
+		} `json:"config"`
+		InvokeURLTemplate string `json:"invoke_url_template"`
+		TestData          string `json:"test_data"`
+		IsDisabled        bool   `json:"isDisabled"`
+	} `json:"properties"`
+}
+
+// triggerType returns the type of the function's trigger binding, e.g. httpTrigger
+func (f appServiceFunction) triggerType() string {
+	for _, binding := range f.Properties.Config.Bindings {
+		if strings.HasSuffix(binding.Type, "Trigger") {
+			return binding.Type
+		}
+	}
+	return ""
+}
+
+// expandFunctions lists the functions in a function app
+func (e *AppServiceExpander) expandFunctions(ctx context.Context, currentItem *TreeNode) ExpanderResult {
+	data, err := e.armClient.DoRequest(ctx, "GET", currentItem.Metadata["AppID"]+"/functions?api-version="+appServiceAPIVersion)
+	if err != nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Failed to list functions: %s", err),
+			SourceDescription: "AppServiceExpander request",
+		}
+	}
+	var response struct {
+		Value []appServiceFunction `json:"value"`
+	}
+	err = json.Unmarshal([]byte(data), &response)
+	if err != nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Error unmarshalling functions: %s", err),
+			SourceDescription: "AppServiceExpander request",
+		}
+	}
+
+	newItems := []*TreeNode{}
+	for _, function := range response.Value {
+		name := function.Properties.Name
+		if name == "" {
+			name = function.Name[strings.LastIndex(function.Name, "/")+1:]
+		}
+		node := e.newNode(currentItem, appServiceFunctionType, function.ID, name, name+" "+style.Subtle("["+function.triggerType()+"]"))
+		node.Metadata["FunctionName"] = name
+		node.Metadata["TriggerType"] = function.triggerType()
+		node.Metadata["InvokeURL"] = function.Properties.InvokeURLTemplate
+		node.Metadata["TestData"] = function.Properties.TestData
+		if function.Properties.IsDisabled {
+			node.StatusIndicator = DrawStatus("Suspended")
+			node.Display += " " + style.Warning("(disabled)")
+		}
+		newItems = append(newItems, node)
+	}
+
+	return ExpanderResult{
+		Nodes:             newItems,
+		Response:          ExpanderResponse{Response: data, ResponseType: ResponseJSON},
+		SourceDescription: "AppServiceExpander request",
+		IsPrimaryResponse: true,
+	}
+}
+
+// expandFunction shows the function's bindings, invoke URL and test data
+func (e *AppServiceExpander) expandFunction(ctx context.Context, currentItem *TreeNode) ExpanderResult {
+	data, err := e.armClient.DoRequest(ctx, "GET", currentItem.ID+"?api-version="+appServiceAPIVersion)
+	if err != nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Failed to get function: %s", err),
+			SourceDescription: "AppServiceExpander request",
+		}
+	}
+	return ExpanderResult{
+		Response:          ExpanderResponse{Response: data, ResponseType: ResponseJSON},
+		SourceDescription: "AppServiceExpander request",
+		IsPrimaryResponse: true,
+	}
+}
+
+// invokeFunction calls the function with its test data. HTTP triggered functions are called on their URL
+// with the function key, other functions are run through the admin API with the host's master key
+func (e *AppServiceExpander) invokeFunction(ctx context.Context, currentItem *TreeNode) ExpanderResult {
+	appID := currentItem.Metadata["AppID"]
+	functionName := currentItem.Metadata["FunctionName"]
+
+	var url, keyURL, keyName string
+	var body []byte
+	if currentItem.Metadata["TriggerType"] == "httpTrigger" {
+		url = currentItem.Metadata["InvokeURL"]
+		keyURL = appID + "/functions/" + functionName + "/listkeys?api-version=" + appServiceAPIVersion
+		keyName = "default"
+		body = []byte(currentItem.Metadata["TestData"])
+	} else {
+		url = "https://" + currentItem.Metadata["DefaultHostName"] + "/admin/functions/" + functionName
+		keyURL = appID + "/host/default/listkeys?api-version=" + appServiceAPIVersion
+		keyName = "masterKey"
+		var err error
+		body, err = json.Marshal(map[string]string{"input": currentItem.Metadata["TestData"]})
+		if err != nil {
+			return ExpanderResult{
+				Err:               err,
+				SourceDescription: "AppServiceExpander request",
+			}
+		}
+	}
+
+	data, err := e.armClient.DoRequest(ctx, "POST", keyURL)
+	if err != nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Failed to get function keys: %s", err),
+			SourceDescription: "AppServiceExpander request",
+		}
+	}
+	var keys map[string]interface{}
+	err = json.Unmarshal([]byte(data), &keys)
+	if err != nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Error unmarshalling function keys: %s", err),
+			SourceDescription: "AppServiceExpander request",
+		}
+	}
+	key, _ := keys[keyName].(string)
+
+	req, err := http.NewRequest("POST", url, bytes.NewReader(body))
+	if err != nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Failed to create request: %s", err),
+			SourceDescription: "AppServiceExpander request",
+		}
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-functions-key", key)
+	response, err := e.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Failed to invoke function: %s", err),
+			SourceDescription: "AppServiceExpander request",
+		}
+	}
+	defer response.Body.Close() //nolint: errcheck
+	responseBody, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Failed to read function response: %s", err),
+			SourceDescription: "AppServiceExpander request",
+		}
+	}
+
+	content := style.Title("Invoked "+functionName) + "\n\n" +
+		"Status: " + response.Status + "\n" +
+		"Payload: " + string(body) + "\n\n" +
+		string(responseBody)
+	return ExpanderResult{
+		Response:          ExpanderResponse{Response: content, ResponseType: ResponsePlainText},
+		SourceDescription: "AppServiceExpander request",
+		IsPrimaryResponse: true,
+	}
+}
@@ -0,0 +1,113 @@
+package expanders
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+const (
+	appServiceFileType      = "appService.file"
+	appServiceLogStreamType = "appService.logStream"
+
+	// appServiceFilesRoot is the path of the app's content in the Kudu virtual file system
+	appServiceFilesRoot = "site/wwwroot/"
+)
+
+type appServiceVFSEntry struct {
+	Name  string `json:"name"`
+	Size  int64  `json:"size"`
+	MTime string `json:"mtime"`
+	Mime  string `json:"mime"`
+}
+
+// newFileNode creates a node for a file or directory in the Kudu virtual file system, directory paths end with '/'
+func (e *AppServiceExpander) newFileNode(currentItem *TreeNode, path string, name string, isDirectory bool) *TreeNode {
+	if isDirectory && !strings.HasSuffix(path, "/") {
+		path += "/"
+	}
+	node := e.newNode(currentItem, appServiceFileType, currentItem.Metadata["AppID"]+"/<files>/"+path, name, name)
+	node.Metadata["Path"] = path
+	if isDirectory {
+		node.Display = name + "/"
+		node.Metadata["IsDirectory"] = "true"
+	}
+	return node
+}
+
+// expandFile lists a directory or shows the content of a file using the Kudu VFS API
+func (e *AppServiceExpander) expandFile(ctx context.Context, currentItem *TreeNode) ExpanderResult {
+	url := "https://" + currentItem.Metadata["SCMHostName"] + "/api/vfs/" + currentItem.Metadata["Path"]
+	buf, err := e.doKuduRequestForBody(ctx, "GET", url)
+	if err != nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Failed to get %s: %s", currentItem.Metadata["Path"], err),
+			SourceDescription: "AppServiceExpander request",
+		}
+	}
+
+	if currentItem.Metadata["IsDirectory"] != "true" {
+		return ExpanderResult{
+			Response:          ExpanderResponse{Response: string(buf), ResponseType: ResponsePlainText},
+			SourceDescription: "AppServiceExpander request",
+			IsPrimaryResponse: true,
+		}
+	}
+
+	var entries []appServiceVFSEntry
+	err = json.Unmarshal(buf, &entries)
+	if err != nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Error unmarshalling directory listing: %s", err),
+			SourceDescription: "AppServiceExpander request",
+		}
+	}
+	newItems := []*TreeNode{}
+	for _, entry := range entries {
+		isDirectory := entry.Mime == "inode/directory"
+		newItems = append(newItems, e.newFileNode(currentItem, currentItem.Metadata["Path"]+entry.Name, entry.Name, isDirectory))
+	}
+	return ExpanderResult{
+		Nodes:             newItems,
+		Response:          ExpanderResponse{Response: string(buf), ResponseType: ResponseJSON},
+		SourceDescription: "AppServiceExpander request",
+		IsPrimaryResponse: true,
+	}
+}
+
+// CanFollowLogs returns true for the log stream node
+func (e *AppServiceExpander) CanFollowLogs(node *TreeNode) bool {
+	return node.Namespace == appServiceNamespace && node.ItemType == appServiceLogStreamType
+}
+
+// CanFollowPreviousLogs returns false as the log stream only includes new log entries
+func (e *AppServiceExpander) CanFollowPreviousLogs(node *TreeNode) bool {
+	return false
+}
+
+// FollowLogs streams the application logs from the Kudu log stream endpoint
+func (e *AppServiceExpander) FollowLogs(ctx context.Context, node *TreeNode, options LogFollowOptions, lines chan<- string) error {
+	response, err := e.doKuduRequest(ctx, "GET", "https://"+node.Metadata["SCMHostName"]+"/api/logstream")
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil
+		}
+		return fmt.Errorf("Failed to open log stream: %s", err)
+	}
+	defer response.Body.Close() //nolint: errcheck
+
+	scanner := bufio.NewScanner(response.Body)
+	for scanner.Scan() {
+		select {
+		case lines <- scanner.Text():
+		case <-ctx.Done():
+			return nil
+		}
+	}
+	if ctx.Err() != nil {
+		return nil
+	}
+	return scanner.Err()
+}
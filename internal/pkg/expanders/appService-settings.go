@@ -0,0 +1,196 @@
+package expanders
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/lawrencegripper/azbrowse/internal/pkg/style"
+)
+
+const (
+	appServiceSettingsType          = "appService.appSettings"
+	appServiceSettingType           = "appService.appSetting"
+	appServiceConnectionStringsType = "appService.connectionStrings"
+	appServiceConnectionStringType  = "appService.connectionString"
+
+	appServiceMaskedValue = "••••••••"
+)
+
+type appServiceConnectionString struct {
+	Value string `json:"value"`
+	Type  string `json:"type"`
+}
+
+// expandSettings lists the app settings or connection strings for the app with the values masked
+func (e *AppServiceExpander) expandSettings(ctx context.Context, currentItem *TreeNode) ExpanderResult {
+	settings, connectionStrings, err := e.getSettings(ctx, currentItem)
+	if err != nil {
+		return ExpanderResult{
+			Err:               err,
+			SourceDescription: "AppServiceExpander request",
+		}
+	}
+
+	names := []string{}
+	for name := range settings {
+		names = append(names, name)
+	}
+	for name := range connectionStrings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	newItems := []*TreeNode{}
+	lines := []string{style.Title(currentItem.Name), ""}
+	for _, name := range names {
+		var node *TreeNode
+		if currentItem.ItemType == appServiceSettingsType {
+			node = e.newNode(currentItem, appServiceSettingType, currentItem.ID+"/"+name, name, name+" "+style.Subtle(appServiceMaskedValue))
+			node.Metadata["Value"] = settings[name]
+		} else {
+			connectionString := connectionStrings[name]
+			node = e.newNode(currentItem, appServiceConnectionStringType, currentItem.ID+"/"+name, name, name+" "+style.Subtle("("+connectionString.Type+") "+appServiceMaskedValue))
+			node.Metadata["Value"] = connectionString.Value
+		}
+		newItems = append(newItems, node)
+		lines = append(lines, fmt.Sprintf("%-40s %s", name, appServiceMaskedValue))
+	}
+	lines = append(lines, "", style.Subtle("Expand a setting to show its value. Use the 'Set app service setting' command to add, change or remove settings"))
+
+	return ExpanderResult{
+		Nodes:             newItems,
+		Response:          ExpanderResponse{Response: strings.Join(lines, "\n"), ResponseType: ResponsePlainText},
+		SourceDescription: "AppServiceExpander request",
+		IsPrimaryResponse: true,
+	}
+}
+
+// expandSetting reveals the value of a single setting
+func (e *AppServiceExpander) expandSetting(ctx context.Context, currentItem *TreeNode) ExpanderResult {
+	return ExpanderResult{
+		Response:          ExpanderResponse{Response: currentItem.Metadata["Value"], ResponseType: ResponsePlainText},
+		SourceDescription: "AppServiceExpander request",
+		IsPrimaryResponse: true,
+	}
+}
+
+// getSettings returns the app settings or the connection strings for the node, depending on the node type
+func (e *AppServiceExpander) getSettings(ctx context.Context, currentItem *TreeNode) (map[string]string, map[string]appServiceConnectionString, error) {
+	isConnectionStrings := isAppServiceConnectionStrings(currentItem)
+	listURL := currentItem.Metadata["AppID"] + "/config/appsettings/list?api-version=" + appServiceAPIVersion
+	if isConnectionStrings {
+		listURL = currentItem.Metadata["AppID"] + "/config/connectionstrings/list?api-version=" + appServiceAPIVersion
+	}
+	data, err := e.armClient.DoRequest(ctx, "POST", listURL)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Failed to list settings: %s", err)
+	}
+
+	if isConnectionStrings {
+		var response struct {
+			Properties map[string]appServiceConnectionString `json:"properties"`
+		}
+		err = json.Unmarshal([]byte(data), &response)
+		if err != nil {
+			return nil, nil, fmt.Errorf("Error unmarshalling connection strings: %s", err)
+		}
+		return nil, response.Properties, nil
+	}
+
+	var response struct {
+		Properties map[string]string `json:"properties"`
+	}
+	err = json.Unmarshal([]byte(data), &response)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Error unmarshalling app settings: %s", err)
+	}
+	return response.Properties, nil, nil
+}
+
+func isAppServiceConnectionStrings(node *TreeNode) bool {
+	return node.ItemType == appServiceConnectionStringsType || node.ItemType == appServiceConnectionStringType
+}
+
+// setSetting adds, updates or removes a setting. The settings are replaced as a whole by the API so the
+// current settings are read first and the change is applied to them
+func (e *AppServiceExpander) setSetting(ctx context.Context, node *TreeNode, name string, value string, remove bool) error {
+	settings, connectionStrings, err := e.getSettings(ctx, node)
+	if err != nil {
+		return err
+	}
+
+	var properties interface{}
+	putURL := node.Metadata["AppID"] + "/config/appsettings?api-version=" + appServiceAPIVersion
+	if isAppServiceConnectionStrings(node) {
+		putURL = node.Metadata["AppID"] + "/config/connectionstrings?api-version=" + appServiceAPIVersion
+		if connectionStrings == nil {
+			connectionStrings = map[string]appServiceConnectionString{}
+		}
+		if remove {
+			delete(connectionStrings, name)
+		} else {
+			connectionString, ok := connectionStrings[name]
+			if !ok {
+				connectionString.Type = "Custom"
+			}
+			connectionString.Value = value
+			connectionStrings[name] = connectionString
+		}
+		properties = connectionStrings
+	} else {
+		if settings == nil {
+			settings = map[string]string{}
+		}
+		if remove {
+			delete(settings, name)
+		} else {
+			settings[name] = value
+		}
+		properties = settings
+	}
+
+	body, err := json.Marshal(map[string]interface{}{"properties": properties})
+	if err != nil {
+		return err
+	}
+	_, err = e.armClient.DoRequestWithBody(ctx, "PUT", putURL, string(body))
+	if err != nil {
+		return fmt.Errorf("Failed to update settings: %s", err)
+	}
+	return nil
+}
+
+func getAppServiceExpander() *AppServiceExpander {
+	for _, h := range getRegisteredExpanders() {
+		if expander, ok := h.(*AppServiceExpander); ok {
+			return expander
+		}
+	}
+	return nil
+}
+
+// CanSetAppServiceSetting returns true for the app settings and connection strings nodes and the settings under them
+func CanSetAppServiceSetting(node *TreeNode) bool {
+	if node == nil || node.Namespace != appServiceNamespace || getAppServiceExpander() == nil {
+		return false
+	}
+	switch node.ItemType {
+	case appServiceSettingsType, appServiceSettingType, appServiceConnectionStringsType, appServiceConnectionStringType:
+		return true
+	}
+	return false
+}
+
+// SetAppServiceSetting adds or updates the app setting or connection string for the node, or removes it if remove is set
+func SetAppServiceSetting(ctx context.Context, node *TreeNode, name string, value string, remove bool) error {
+	if !CanSetAppServiceSetting(node) {
+		return fmt.Errorf("Settings can't be changed for %s", node.Name)
+	}
+	if name == "" {
+		return fmt.Errorf("Setting name is required")
+	}
+	return getAppServiceExpander().setSetting(WithNodeTenant(ctx, node), node, name, value, remove)
+}
@@ -0,0 +1,609 @@
+package expanders
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/lawrencegripper/azbrowse/internal/pkg/style"
+	"github.com/lawrencegripper/azbrowse/pkg/armclient"
+	"github.com/nbio/st"
+	"gopkg.in/h2non/gock.v1"
+)
+
+const (
+	appServiceNamespace  = "appService"
+	appServiceAPIVersion = "2019-08-01"
+
+	appServiceSwapDiffsType = "appService.swapDiffs"
+)
+
+// appServiceIDRegex matches the IDs of web and function apps and their deployment slots, capturing the app ID and slot name
+var appServiceIDRegex = regexp.MustCompile(`(?i)^(/subscriptions/[^/]+/resourceGroups/[^/]+/providers/Microsoft\.Web/sites/[^/]+)(?:/slots/([^/]+))?$`)
+
+// appServiceSwapActions maps the slot swap actions to their display names
+var appServiceSwapActions = []struct {
+	action  string
+	display string
+}{
+	{"swap", "Swap with production"},
+	{"previewSwap", "Swap with preview (apply production settings to slot)"},
+	{"cancelSwap", "Cancel swap with preview"},
+}
+
+type appServiceSite struct {
+	Kind       string `json:"kind"`
+	Properties struct {
+		State             string   `json:"state"`
+		DefaultHostName   string   `json:"defaultHostName"`
+		EnabledHostNames  []string `json:"enabledHostNames"`
+		HostNameSslStates []struct {
+			Name     string `json:"name"`
+			HostType string `json:"hostType"`
+		} `json:"hostNameSslStates"`
+	} `json:"properties"`
+}
+
+// scmHostName returns the host name of the Kudu (SCM) site for the app
+func (s appServiceSite) scmHostName() string {
+	for _, state := range s.Properties.HostNameSslStates {
+		if state.HostType == "Repository" {
+			return state.Name
+		}
+	}
+	for _, hostName := range s.Properties.EnabledHostNames {
+		if strings.Contains(hostName, ".scm.") {
+			return hostName
+		}
+	}
+	return ""
+}
+
+type appServiceSlotDifferences struct {
+	Value []struct {
+		Properties struct {
+			SettingType string `json:"settingType"`
+			DiffRule    string `json:"diffRule"`
+			SettingName string `json:"settingName"`
+			Description string `json:"description"`
+		} `json:"properties"`
+	} `json:"value"`
+}
+
+// Check interface
+var _ Expander = &AppServiceExpander{}
+var _ ConfirmedActionRunner = &AppServiceExpander{}
+var _ LogFollower = &AppServiceExpander{}
+
+// AppServiceExpander adds the operational views for web and function apps: settings, log streaming,
+// the Kudu file browser, functions and deployment slot swaps
+type AppServiceExpander struct {
+	ExpanderBase
+	client    *http.Client
+	armClient *armclient.Client
+}
+
+// NewAppServiceExpander creates a new instance of AppServiceExpander
+func NewAppServiceExpander(armclient *armclient.Client) *AppServiceExpander {
+	return &AppServiceExpander{
+		client:    &http.Client{},
+		armClient: armclient,
+	}
+}
+
+func (e *AppServiceExpander) setClient(c *armclient.Client) {
+	e.armClient = c
+}
+
+// Name returns the name of the expander
+func (e *AppServiceExpander) Name() string {
+	return "AppServiceExpander"
+}
+
+// isAppService returns true for web and function apps and their deployment slots
+func isAppService(node *TreeNode) bool {
+	if node == nil || (node.ItemType != ResourceType && node.ItemType != SubResourceType) {
+		return false
+	}
+	return appServiceIDRegex.MatchString(node.ID)
+}
+
+// getAppServiceSlot returns the app ID and slot name for the node, the slot name is empty for the production slot
+func getAppServiceSlot(appID string) (string, string) {
+	match := appServiceIDRegex.FindStringSubmatch(appID)
+	if match == nil {
+		return appID, ""
+	}
+	return match[1], match[2]
+}
+
+// DoesExpand checks if this is a web or function app or one of the nodes added for it
+func (e *AppServiceExpander) DoesExpand(ctx context.Context, currentItem *TreeNode) (bool, error) {
+	if currentItem.Namespace == appServiceNamespace {
+		return true, nil
+	}
+	return isAppService(currentItem), nil
+}
+
+// Expand adds the operational nodes to an app and expands them
+func (e *AppServiceExpander) Expand(ctx context.Context, currentItem *TreeNode) ExpanderResult {
+	switch currentItem.ItemType {
+	case appServiceSettingsType, appServiceConnectionStringsType:
+		return e.expandSettings(ctx, currentItem)
+	case appServiceSettingType, appServiceConnectionStringType:
+		return e.expandSetting(ctx, currentItem)
+	case appServiceLogStreamType:
+		return ExpanderResult{
+			Response:          ExpanderResponse{Response: "Use the 'Follow logs' command to stream the application logs for " + currentItem.Metadata["AppName"], ResponseType: ResponsePlainText},
+			SourceDescription: "AppServiceExpander request",
+			IsPrimaryResponse: true,
+		}
+	case appServiceFileType:
+		return e.expandFile(ctx, currentItem)
+	case appServiceFunctionsType:
+		return e.expandFunctions(ctx, currentItem)
+	case appServiceFunctionType:
+		return e.expandFunction(ctx, currentItem)
+	case appServiceSwapDiffsType:
+		return e.expandSwapDiffs(ctx, currentItem)
+	case ActionType:
+		if currentItem.Metadata["ActionID"] == "invoke" {
+			return e.invokeFunction(ctx, currentItem)
+		}
+		return ExpanderResult{
+			Response:          RequestActionConfirmation(e, currentItem),
+			SourceDescription: "AppServiceExpander action",
+			IsPrimaryResponse: true,
+		}
+	}
+
+	if isAppService(currentItem) {
+		return e.expandApp(ctx, currentItem)
+	}
+
+	return ExpanderResult{
+		Err:               fmt.Errorf("Error - unhandled Expand"),
+		Response:          ExpanderResponse{Response: "Error!"},
+		SourceDescription: "AppServiceExpander request",
+	}
+}
+
+// newNode creates a node for the app, copying the app's metadata so that child nodes can make requests against the app
+func (e *AppServiceExpander) newNode(currentItem *TreeNode, itemType string, id string, name string, display string) *TreeNode {
+	metadata := map[string]string{
+		"SuppressSwaggerExpand": "true",
+		"SuppressGenericExpand": "true",
+	}
+	for _, key := range []string{"AppID", "AppName", "SCMHostName", "DefaultHostName"} {
+		metadata[key] = currentItem.Metadata[key]
+	}
+	return &TreeNode{
+		Parentid:       currentItem.ID,
+		ID:             id,
+		Namespace:      appServiceNamespace,
+		Name:           name,
+		Display:        display,
+		ItemType:       itemType,
+		ExpandURL:      ExpandURLNotSupported,
+		SubscriptionID: currentItem.SubscriptionID,
+		Metadata:       metadata,
+	}
+}
+
+// expandApp adds nodes for the settings, log stream, files and functions of the app and the swap differences for slots
+func (e *AppServiceExpander) expandApp(ctx context.Context, currentItem *TreeNode) ExpanderResult {
+	data, err := e.armClient.DoRequest(ctx, "GET", currentItem.ID+"?api-version="+appServiceAPIVersion)
+	if err != nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Failed to get app: %s", err),
+			SourceDescription: "AppServiceExpander request",
+		}
+	}
+	var site appServiceSite
+	err = json.Unmarshal([]byte(data), &site)
+	if err != nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Error unmarshalling app: %s", err),
+			SourceDescription: "AppServiceExpander request",
+		}
+	}
+
+	// Copy the node so that the metadata used by the child nodes doesn't change the app node
+	app := *currentItem
+	app.Metadata = map[string]string{
+		"AppID":           currentItem.ID,
+		"AppName":         currentItem.Name,
+		"SCMHostName":     site.scmHostName(),
+		"DefaultHostName": site.Properties.DefaultHostName,
+	}
+
+	newItems := []*TreeNode{
+		e.newNode(&app, appServiceSettingsType, currentItem.ID+"/<appsettings>", "App settings", "App settings"),
+		e.newNode(&app, appServiceConnectionStringsType, currentItem.ID+"/<connectionstrings>", "Connection strings", "Connection strings"),
+	}
+	if app.Metadata["SCMHostName"] != "" {
+		newItems = append(newItems,
+			e.newNode(&app, appServiceLogStreamType, currentItem.ID+"/<logstream>", "Log stream", "Log stream"),
+			e.newFileNode(&app, appServiceFilesRoot, "Files (Kudu)", true))
+	}
+	if strings.Contains(site.Kind, "functionapp") {
+		newItems = append(newItems, e.newNode(&app, appServiceFunctionsType, currentItem.ID+"/<functions>", "Functions", "Functions"))
+	}
+	if _, slot := getAppServiceSlot(currentItem.ID); slot != "" {
+		newItems = append(newItems, e.newNode(&app, appServiceSwapDiffsType, currentItem.ID+"/<swapdiffs>", "Swap differences", "Swap differences (with production)"))
+	}
+
+	return ExpanderResult{
+		Nodes:             newItems,
+		SourceDescription: "AppServiceExpander request",
+	}
+}
+
+// expandSwapDiffs shows the settings that change when the slot is swapped with production
+func (e *AppServiceExpander) expandSwapDiffs(ctx context.Context, currentItem *TreeNode) ExpanderResult {
+	appID, slot := getAppServiceSlot(currentItem.Metadata["AppID"])
+	body, err := json.Marshal(map[string]string{"targetSlot": slot})
+	if err != nil {
+		return ExpanderResult{
+			Err:               err,
+			SourceDescription: "AppServiceExpander request",
+		}
+	}
+	data, err := e.armClient.DoRequestWithBody(ctx, "POST", appID+"/slotsdiffs?api-version="+appServiceAPIVersion, string(body))
+	if err != nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Failed to get swap differences: %s", err),
+			SourceDescription: "AppServiceExpander request",
+		}
+	}
+	var differences appServiceSlotDifferences
+	err = json.Unmarshal([]byte(data), &differences)
+	if err != nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Error unmarshalling swap differences: %s", err),
+			SourceDescription: "AppServiceExpander request",
+		}
+	}
+
+	lines := []string{style.Title("Swap differences between " + slot + " and production"), ""}
+	if len(differences.Value) == 0 {
+		lines = append(lines, "No differences")
+	}
+	for _, difference := range differences.Value {
+		properties := difference.Properties
+		lines = append(lines, fmt.Sprintf("%-18s %-30s %s", properties.SettingType, properties.SettingName, style.Subtle(properties.DiffRule)))
+		if properties.Description != "" {
+			lines = append(lines, "  "+style.Subtle(properties.Description))
+		}
+	}
+	return ExpanderResult{
+		Response:          ExpanderResponse{Response: strings.Join(lines, "\n"), ResponseType: ResponsePlainText},
+		SourceDescription: "AppServiceExpander request",
+		IsPrimaryResponse: true,
+	}
+}
+
+// HasActions checks if the item is a deployment slot or a function
+func (e *AppServiceExpander) HasActions(ctx context.Context, currentItem *TreeNode) (bool, error) {
+	if currentItem.ItemType == appServiceFunctionType {
+		return true, nil
+	}
+	if !isAppService(currentItem) {
+		return false, nil
+	}
+	_, slot := getAppServiceSlot(currentItem.ID)
+	return slot != "", nil
+}
+
+// ListActions returns the swap actions for a deployment slot and the invoke action for a function
+func (e *AppServiceExpander) ListActions(ctx context.Context, currentItem *TreeNode) ListActionsResult {
+	nodes := []*TreeNode{}
+	if currentItem.ItemType == appServiceFunctionType {
+		nodes = append(nodes, e.newAction(currentItem, "invoke", "Invoke with test payload", "Invoke function "+currentItem.Name+" with its test payload"))
+	} else {
+		for _, swapAction := range appServiceSwapActions {
+			nodes = append(nodes, e.newAction(currentItem, swapAction.action, swapAction.display, swapAction.display+" for slot "+currentItem.Name))
+		}
+	}
+	return ListActionsResult{
+		Nodes:             nodes,
+		SourceDescription: "AppServiceExpander actions",
+	}
+}
+
+func (e *AppServiceExpander) newAction(currentItem *TreeNode, actionID string, display string, description string) *TreeNode {
+	node := e.newNode(currentItem, ActionType, currentItem.ID+"/<actions>/"+actionID, description, display)
+	if isAppService(currentItem) {
+		node.Metadata["AppID"] = currentItem.ID
+	}
+	node.Metadata["ActionID"] = actionID
+	for _, key := range []string{"FunctionName", "TriggerType", "InvokeURL", "TestData"} {
+		node.Metadata[key] = currentItem.Metadata[key]
+	}
+	return node
+}
+
+// RunConfirmedAction runs a slot swap action once it has been confirmed in the notifications panel
+func (e *AppServiceExpander) RunConfirmedAction(ctx context.Context, action *TreeNode) error {
+	appID, slot := getAppServiceSlot(action.Metadata["AppID"])
+	if slot == "" {
+		return fmt.Errorf("Slot not found for %s", action.Metadata["AppID"])
+	}
+	body, err := json.Marshal(map[string]interface{}{"targetSlot": slot, "preserveVnet": true})
+	if err != nil {
+		return err
+	}
+	switch action.Metadata["ActionID"] {
+	case "swap":
+		_, err = e.armClient.DoRequestWithBody(ctx, "POST", appID+"/slotsswap?api-version="+appServiceAPIVersion, string(body))
+	case "previewSwap":
+		// Applies the production slot's settings to the slot so it can be tested before completing the swap
+		_, err = e.armClient.DoRequestWithBody(ctx, "POST", appID+"/applySlotConfig?api-version="+appServiceAPIVersion, string(body))
+	case "cancelSwap":
+		_, err = e.armClient.DoRequest(ctx, "POST", appID+"/resetSlotConfig?api-version="+appServiceAPIVersion)
+	default:
+		return fmt.Errorf("Unhandled action: %s", action.Metadata["ActionID"])
+	}
+	return err
+}
+
+// doKuduRequest makes a request to the Kudu (SCM) site for the app, which accepts ARM tokens
+func (e *AppServiceExpander) doKuduRequest(ctx context.Context, method string, url string) (*http.Response, error) {
+	token, err := e.armClient.ForContext(ctx).GetToken()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get token: %s", err)
+	}
+	req, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create request: %s", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token.AccessToken)
+	response, err := e.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("Request failed: %s", err)
+	}
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		response.Body.Close() //nolint: errcheck
+		return nil, fmt.Errorf("Request to %s failed: %s", url, response.Status)
+	}
+	return response, nil
+}
+
+func (e *AppServiceExpander) doKuduRequestForBody(ctx context.Context, method string, url string) ([]byte, error) {
+	response, err := e.doKuduRequest(ctx, method, url)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close() //nolint: errcheck
+	buf, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read body: %s", err)
+	}
+	return buf, nil
+}
+
+func (e *AppServiceExpander) testCases() (bool, *[]expanderTestCase) {
+	const appID = "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/stable/providers/Microsoft.Web/sites/testsite"
+	const slotID = appID + "/slots/staging"
+	const scmURL = "https://testsite.scm.azurewebsites.net"
+
+	appNode := &TreeNode{
+		ID:        appID,
+		Name:      "testsite",
+		ItemType:  ResourceType,
+		ExpandURL: appID + "?api-version=" + appServiceAPIVersion,
+		Metadata:  map[string]string{},
+	}
+	app := *appNode
+	app.Metadata = map[string]string{
+		"AppID":           appID,
+		"AppName":         "testsite",
+		"SCMHostName":     "testsite.scm.azurewebsites.net",
+		"DefaultHostName": "testsite.azurewebsites.net",
+	}
+	settingsNode := e.newNode(&app, appServiceSettingsType, appID+"/<appsettings>", "App settings", "App settings")
+	connectionStringsNode := e.newNode(&app, appServiceConnectionStringsType, appID+"/<connectionstrings>", "Connection strings", "Connection strings")
+	filesNode := e.newFileNode(&app, appServiceFilesRoot, "Files (Kudu)", true)
+	fileNode := e.newFileNode(&app, appServiceFilesRoot+"hostingstart.html", "hostingstart.html", false)
+	functionsNode := e.newNode(&app, appServiceFunctionsType, appID+"/<functions>", "Functions", "Functions")
+	slotNode := &TreeNode{
+		ID:       slotID,
+		Name:     "testsite/staging",
+		ItemType: SubResourceType,
+	}
+	swapAction := e.ListActions(context.Background(), slotNode).Nodes[0]
+	functionNode := e.newNode(&app, appServiceFunctionType, appID+"/functions/HttpTrigger", "HttpTrigger", "HttpTrigger")
+	functionNode.Metadata["FunctionName"] = "HttpTrigger"
+	functionNode.Metadata["TriggerType"] = "httpTrigger"
+	functionNode.Metadata["InvokeURL"] = "https://testsite.azurewebsites.net/api/httptrigger"
+	functionNode.Metadata["TestData"] = `{"name":"azbrowse"}`
+	invokeAction := e.ListActions(context.Background(), functionNode).Nodes[0]
+
+	appGockConfig := func(t *testing.T) {
+		gock.New("https://management.azure.com").
+			Get(appID).
+			Reply(200).
+			File("./testdata/armsamples/appservice/site.json")
+	}
+	settingsGockConfig := func(t *testing.T) {
+		gock.New("https://management.azure.com").
+			Post(appID + "/config/appsettings/list").
+			Reply(200).
+			File("./testdata/armsamples/appservice/appSettings.json")
+	}
+	setSettingGockConfig := func(t *testing.T) {
+		settingsGockConfig(t)
+		settingsGockConfig(t)
+		gock.New("https://management.azure.com").
+			Put(appID + "/config/appsettings").
+			JSON(map[string]interface{}{"properties": map[string]string{
+				"FUNCTIONS_EXTENSION_VERSION": "~3",
+				"FUNCTIONS_WORKER_RUNTIME":    "dotnet",
+				"AzureWebJobsStorage":         "DefaultEndpointsProtocol=https;AccountName=teststorage;AccountKey=c2VjcmV0",
+				"NEW_SETTING":                 "new value",
+			}}).
+			Reply(200).
+			File("./testdata/armsamples/appservice/appSettings.json")
+	}
+	connectionStringsGockConfig := func(t *testing.T) {
+		gock.New("https://management.azure.com").
+			Post(appID + "/config/connectionstrings/list").
+			Reply(200).
+			File("./testdata/armsamples/appservice/connectionStrings.json")
+	}
+	filesGockConfig := func(t *testing.T) {
+		gock.New(scmURL).
+			Get("/api/vfs/site/wwwroot/").
+			MatchHeader("Authorization", "^Bearer ").
+			Reply(200).
+			File("./testdata/armsamples/appservice/vfs.json")
+	}
+	fileGockConfig := func(t *testing.T) {
+		gock.New(scmURL).
+			Get("/api/vfs/site/wwwroot/hostingstart.html").
+			Reply(200).
+			BodyString("<html><body>Hello</body></html>")
+	}
+	functionsGockConfig := func(t *testing.T) {
+		gock.New("https://management.azure.com").
+			Get(appID + "/functions").
+			Reply(200).
+			File("./testdata/armsamples/appservice/functions.json")
+	}
+	invokeGockConfig := func(t *testing.T) {
+		gock.New("https://management.azure.com").
+			Post(appID + "/functions/HttpTrigger/listkeys").
+			Reply(200).
+			JSON(map[string]string{"default": "functionkey"})
+		gock.New("https://testsite.azurewebsites.net").
+			Post("/api/httptrigger").
+			MatchHeader("x-functions-key", "functionkey").
+			JSON(map[string]string{"name": "azbrowse"}).
+			Reply(200).
+			BodyString("Hello, azbrowse")
+	}
+	swapGockConfig := func(t *testing.T) {
+		gock.New("https://management.azure.com").
+			Post(appID + "/slotsswap").
+			JSON(map[string]interface{}{"targetSlot": "staging", "preserveVnet": true}).
+			Reply(202)
+	}
+
+	return true, &[]expanderTestCase{
+		{
+			name:              "AppService->App",
+			nodeToExpand:      appNode,
+			configureGockFunc: &appGockConfig,
+			treeNodeCheckerFunc: func(t *testing.T, r ExpanderResult) {
+				st.Expect(t, r.Err, nil)
+				st.Expect(t, len(r.Nodes), 5)
+				st.Expect(t, r.Nodes[0].ItemType, appServiceSettingsType)
+				st.Expect(t, r.Nodes[1].ItemType, appServiceConnectionStringsType)
+				st.Expect(t, r.Nodes[2].ItemType, appServiceLogStreamType)
+				st.Expect(t, r.Nodes[3].ItemType, appServiceFileType)
+				st.Expect(t, r.Nodes[3].Metadata["SCMHostName"], "testsite.scm.azurewebsites.net")
+				st.Expect(t, r.Nodes[4].ItemType, appServiceFunctionsType)
+				st.Expect(t, e.CanFollowLogs(r.Nodes[2]), true)
+			},
+		},
+		{
+			name:              "AppService->AppSettingsMasked",
+			nodeToExpand:      settingsNode,
+			configureGockFunc: &settingsGockConfig,
+			treeNodeCheckerFunc: func(t *testing.T, r ExpanderResult) {
+				st.Expect(t, r.Err, nil)
+				st.Expect(t, len(r.Nodes), 3)
+				st.Expect(t, r.Nodes[0].Name, "AzureWebJobsStorage")
+				st.Expect(t, strings.Contains(r.Nodes[0].Display, "AccountKey"), false)
+				st.Expect(t, strings.Contains(r.Response.Response, "AccountKey"), false)
+
+				revealed := e.expandSetting(context.Background(), r.Nodes[2])
+				st.Expect(t, revealed.Response.Response, "dotnet")
+			},
+		},
+		{
+			name:              "AppService->SetAppSetting",
+			nodeToExpand:      settingsNode,
+			configureGockFunc: &setSettingGockConfig,
+			treeNodeCheckerFunc: func(t *testing.T, r ExpanderResult) {
+				st.Expect(t, r.Err, nil)
+				err := e.setSetting(context.Background(), settingsNode, "NEW_SETTING", "new value", false)
+				st.Expect(t, err, nil)
+			},
+		},
+		{
+			name:              "AppService->ConnectionStrings",
+			nodeToExpand:      connectionStringsNode,
+			configureGockFunc: &connectionStringsGockConfig,
+			treeNodeCheckerFunc: func(t *testing.T, r ExpanderResult) {
+				st.Expect(t, r.Err, nil)
+				st.Expect(t, len(r.Nodes), 1)
+				st.Expect(t, r.Nodes[0].Name, "Database")
+				st.Expect(t, strings.Contains(r.Nodes[0].Display, "SQLAzure"), true)
+				st.Expect(t, strings.Contains(r.Response.Response, "Password"), false)
+			},
+		},
+		{
+			name:              "AppService->FilesDirectory",
+			nodeToExpand:      filesNode,
+			configureGockFunc: &filesGockConfig,
+			treeNodeCheckerFunc: func(t *testing.T, r ExpanderResult) {
+				st.Expect(t, r.Err, nil)
+				st.Expect(t, len(r.Nodes), 2)
+				st.Expect(t, r.Nodes[0].Name, "css")
+				st.Expect(t, r.Nodes[0].Metadata["Path"], "site/wwwroot/css/")
+				st.Expect(t, r.Nodes[1].Name, "hostingstart.html")
+				st.Expect(t, r.Nodes[1].Metadata["Path"], "site/wwwroot/hostingstart.html")
+			},
+		},
+		{
+			name:              "AppService->File",
+			nodeToExpand:      fileNode,
+			configureGockFunc: &fileGockConfig,
+			treeNodeCheckerFunc: func(t *testing.T, r ExpanderResult) {
+				st.Expect(t, r.Err, nil)
+				st.Expect(t, r.Response.Response, "<html><body>Hello</body></html>")
+			},
+		},
+		{
+			name:              "AppService->Functions",
+			nodeToExpand:      functionsNode,
+			configureGockFunc: &functionsGockConfig,
+			treeNodeCheckerFunc: func(t *testing.T, r ExpanderResult) {
+				st.Expect(t, r.Err, nil)
+				st.Expect(t, len(r.Nodes), 2)
+				st.Expect(t, r.Nodes[0].Name, "HttpTrigger")
+				st.Expect(t, strings.Contains(r.Nodes[0].Display, "httpTrigger"), true)
+				st.Expect(t, r.Nodes[1].Name, "QueueTrigger")
+				st.Expect(t, r.Nodes[1].StatusIndicator, DrawStatus("Suspended"))
+			},
+		},
+		{
+			name:              "AppService->InvokeFunction",
+			nodeToExpand:      invokeAction,
+			configureGockFunc: &invokeGockConfig,
+			treeNodeCheckerFunc: func(t *testing.T, r ExpanderResult) {
+				st.Expect(t, r.Err, nil)
+				st.Expect(t, strings.Contains(r.Response.Response, "Status: 200 OK"), true)
+				st.Expect(t, strings.Contains(r.Response.Response, "Hello, azbrowse"), true)
+			},
+		},
+		{
+			name:              "AppService->SwapConfirmed",
+			nodeToExpand:      swapAction,
+			configureGockFunc: &swapGockConfig,
+			treeNodeCheckerFunc: func(t *testing.T, r ExpanderResult) {
+				st.Expect(t, r.Err, nil)
+				st.Expect(t, strings.Contains(r.Response.Response, "Swap with production for slot testsite/staging"), true)
+
+				err := RunConfirmedAction(context.Background(), swapAction)
+				st.Expect(t, err, nil)
+			},
+		},
+	}
+}
@@ -0,0 +1,70 @@
+package expanders
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/lawrencegripper/azbrowse/pkg/armclient"
+)
+
+// doARMRequestAndWait makes an ARM request to the path and, if the operation runs asynchronously, polls the Location
+// header until the result is returned. The request is made with DoRawRequest so the async tracker doesn't
+// also follow the operation. This blocks until the operation completes or the context is cancelled
+func doARMRequestAndWait(ctx context.Context, client *armclient.Client, method string, path string, body []byte, pollInterval time.Duration) ([]byte, error) {
+	response, responseBody, err := doRawARMRequest(ctx, client, method, path, body)
+	if err != nil {
+		return nil, err
+	}
+
+	pollURI := response.Header.Get("Location")
+	for response.StatusCode == http.StatusAccepted {
+		if pollURI == "" {
+			return nil, fmt.Errorf("Failed to find the poll location for the operation")
+		}
+		interval := pollInterval
+		if retryAfter, err := strconv.Atoi(response.Header.Get("Retry-After")); err == nil {
+			interval = time.Duration(retryAfter) * time.Second
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(interval):
+		}
+		response, responseBody, err = doRawARMRequest(ctx, client, "GET", pollURI, nil)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to get operation result: %s", err)
+		}
+	}
+	return responseBody, nil
+}
+
+// doRawARMRequest makes an ARM request returning the response so that the async operation headers can be read.
+// The path can be relative to the ARM endpoint or, as for the poll location, a full ARM URL
+func doRawARMRequest(ctx context.Context, client *armclient.Client, method string, path string, body []byte) (*http.Response, []byte, error) {
+	requestURL, err := armclient.GetRequestURL(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	req, err := http.NewRequest(method, requestURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, nil, fmt.Errorf("Failed to create request: %s", err)
+	}
+	response, err := client.DoRawRequest(ctx, req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Request failed: %s", err)
+	}
+	defer response.Body.Close() //nolint: errcheck
+	buf, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Failed to read body: %s", err)
+	}
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return nil, nil, fmt.Errorf("Request returned a non-success status code of %v: %s", response.StatusCode, string(buf))
+	}
+	return response, buf, nil
+}
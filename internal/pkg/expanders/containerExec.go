@@ -0,0 +1,133 @@
+package expanders
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"golang.org/x/net/websocket"
+)
+
+// ContainerExecOptions controls the command run in a container
+type ContainerExecOptions struct {
+	Command string
+	// Rows and Cols set the size of the container's terminal
+	Rows int
+	Cols int
+}
+
+// ContainerExecSession is an interactive session with a container over a websocket
+type ContainerExecSession struct {
+	WebSocketURI string
+	Password     string
+}
+
+// ContainerExecutor is implemented by expanders that can run interactive commands in containers
+type ContainerExecutor interface {
+	CanExec(node *TreeNode) bool
+	StartExec(ctx context.Context, node *TreeNode, options ContainerExecOptions) (*ContainerExecSession, error)
+}
+
+// CanExec returns true if commands can be run in the container for the node
+func CanExec(node *TreeNode) bool {
+	return getContainerExecutor(node) != nil
+}
+
+// StartExec starts running the command in the container for the node and returns the session to connect to
+func StartExec(ctx context.Context, node *TreeNode, options ContainerExecOptions) (*ContainerExecSession, error) {
+	executor := getContainerExecutor(node)
+	if executor == nil {
+		return nil, fmt.Errorf("Commands can't be run for %s", node.Name)
+	}
+	return executor.StartExec(WithNodeTenant(ctx, node), node, options)
+}
+
+func getContainerExecutor(node *TreeNode) ContainerExecutor {
+	if node == nil {
+		return nil
+	}
+	for _, h := range getRegisteredExpanders() {
+		if executor, ok := h.(ContainerExecutor); ok && executor.CanExec(node) {
+			return executor
+		}
+	}
+	return nil
+}
+
+// Run connects to the session and relays stdin to the container and the container output to stdout until
+// the session is closed. Reads from stdin can't be cancelled so once the session is closed Run waits
+// for one more read (e.g. a key press) to avoid a pending read taking input intended for the caller
+func (s *ContainerExecSession) Run(ctx context.Context, stdin io.Reader, stdout io.Writer) error {
+	config, err := websocket.NewConfig(s.WebSocketURI, "http://localhost/")
+	if err != nil {
+		return fmt.Errorf("Invalid websocket URI: %s", err)
+	}
+	ws, err := websocket.DialConfig(config)
+	if err != nil {
+		return fmt.Errorf("Failed to connect to container: %s", err)
+	}
+	defer ws.Close() //nolint: errcheck
+
+	// The session must be authenticated with the password before any input is sent
+	err = websocket.Message.Send(ws, s.Password)
+	if err != nil {
+		return fmt.Errorf("Failed to authenticate with container: %s", err)
+	}
+
+	closed := make(chan struct{})
+	outputDone := make(chan error, 1)
+	go func() {
+		for {
+			var output []byte
+			err := websocket.Message.Receive(ws, &output)
+			if err != nil {
+				if err == io.EOF {
+					err = nil
+				}
+				outputDone <- err
+				return
+			}
+			_, err = stdout.Write(output)
+			if err != nil {
+				outputDone <- err
+				return
+			}
+		}
+	}()
+
+	inputDone := make(chan struct{})
+	go func() {
+		defer close(inputDone)
+		buf := make([]byte, 1024)
+		for {
+			n, err := stdin.Read(buf)
+			select {
+			case <-closed:
+				return
+			default:
+			}
+			if err != nil {
+				return
+			}
+			if err := websocket.Message.Send(ws, string(buf[:n])); err != nil {
+				return
+			}
+		}
+	}()
+
+	select {
+	case err = <-outputDone:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	close(closed)
+	_ = ws.Close()
+
+	select {
+	case <-inputDone:
+	default:
+		fmt.Fprint(stdout, "\r\nSession closed, press any key to return to azbrowse") //nolint: errcheck
+		<-inputDone
+	}
+	return err
+}
@@ -0,0 +1,131 @@
+package expanders
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lawrencegripper/azbrowse/pkg/armclient"
+	"github.com/nbio/st"
+	"golang.org/x/net/websocket"
+	"gopkg.in/h2non/gock.v1"
+)
+
+// lockedBuffer is written to by the session while the test reads it
+type lockedBuffer struct {
+	mutex sync.Mutex
+	buf   bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buf.String()
+}
+
+func waitForOutput(t *testing.T, output *lockedBuffer, expected string) {
+	for i := 0; i < 100; i++ {
+		if strings.Contains(output.String(), expected) {
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("Timed out waiting for %q, got %q", expected, output.String())
+}
+
+// newEchoServer returns a websocket server which checks the password then echoes messages until it receives "exit"
+func newEchoServer(t *testing.T, password string) *httptest.Server {
+	return httptest.NewServer(websocket.Handler(func(ws *websocket.Conn) {
+		var message string
+		if err := websocket.Message.Receive(ws, &message); err != nil || message != password {
+			t.Errorf("Expected password %q, got %q (%v)", password, message, err)
+			return
+		}
+		for {
+			if err := websocket.Message.Receive(ws, &message); err != nil {
+				return
+			}
+			if message == "exit" {
+				return
+			}
+			if err := websocket.Message.Send(ws, "echo: "+message); err != nil {
+				return
+			}
+		}
+	}))
+}
+
+func Test_ContainerExecSession_RelaysInputAndOutput(t *testing.T) {
+	server := newEchoServer(t, "secret")
+	defer server.Close()
+
+	stdin, stdinWriter := io.Pipe()
+	output := &lockedBuffer{}
+	session := &ContainerExecSession{
+		WebSocketURI: "ws" + strings.TrimPrefix(server.URL, "http"),
+		Password:     "secret",
+	}
+
+	done := make(chan error)
+	go func() {
+		done <- session.Run(context.Background(), stdin, output)
+	}()
+
+	_, _ = stdinWriter.Write([]byte("ls"))
+	waitForOutput(t, output, "echo: ls")
+
+	_, _ = stdinWriter.Write([]byte("exit"))
+	waitForOutput(t, output, "Session closed, press any key to return to azbrowse")
+
+	// the key press that returns to azbrowse isn't sent to the closed session
+	_, _ = stdinWriter.Write([]byte("q"))
+	select {
+	case err := <-done:
+		st.Expect(t, err, nil)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for session to end")
+	}
+	st.Expect(t, strings.Contains(output.String(), "echo: q"), false)
+}
+
+func Test_ContainerInstanceExpander_StartExec(t *testing.T) {
+	const containerGroupID = "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/stable/providers/Microsoft.ContainerInstance/containerGroups/testgroup"
+	defer gock.Off()
+	gock.New("https://management.azure.com").
+		Post(containerGroupID+"/containers/web/exec").
+		MatchParam("api-version", "2018-10-01").
+		JSON(map[string]interface{}{"command": "/bin/sh", "terminalSize": map[string]int{"rows": 40, "cols": 120}}).
+		Reply(200).
+		JSON(map[string]string{"webSocketUri": "wss://bridge.westeurope.azurecontainer.io/exec/testgroup/web", "password": "secret"})
+
+	httpClient := &http.Client{Transport: &http.Transport{}}
+	gock.InterceptClient(httpClient)
+	expander := &ContainerInstanceExpander{client: armclient.NewClientFromConfig(httpClient, DummyTokenFunc(), 5000)}
+
+	node := &TreeNode{
+		ID:               containerGroupID + "/web",
+		Parentid:         containerGroupID,
+		ExpandURL:        containerGroupID + "/containers/web/logs?tail=400&api-version=2018-10-01",
+		ExpandReturnType: "containerInstance.logs",
+		Metadata:         map[string]string{"ContainerName": "web"},
+	}
+	st.Expect(t, expander.CanExec(node), true)
+
+	session, err := expander.StartExec(context.Background(), node, ContainerExecOptions{Command: "/bin/sh", Rows: 40, Cols: 120})
+	st.Expect(t, err, nil)
+	st.Expect(t, session.WebSocketURI, "wss://bridge.westeurope.azurecontainer.io/exec/testgroup/web")
+	st.Expect(t, session.Password, "secret")
+	st.Expect(t, gock.IsDone(), true)
+}
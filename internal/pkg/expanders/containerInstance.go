@@ -4,7 +4,9 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"net/url"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/lawrencegripper/azbrowse/internal/pkg/eventing"
@@ -15,8 +17,14 @@ import (
 const containerInstanceTemplate = "/subscriptions/{subscriptionId}/resourceGroups/{resourceGroupName}/providers/Microsoft.ContainerInstance/containerGroups/{containerGroupName}"
 const containerInstanceNamespace = "containerInstance"
 
+// containerInstanceLogPollInterval is how often the logs are requested when following container instance logs
+// as the container instance API doesn't support streaming
+const containerInstanceLogPollInterval = time.Second * 5
+
 // Check interface
 var _ Expander = &ContainerInstanceExpander{}
+var _ LogFollower = &ContainerInstanceExpander{}
+var _ ContainerExecutor = &ContainerInstanceExpander{}
 
 // ContainerInstanceExpander expands the data-plane aspects of a Container Instance
 type ContainerInstanceExpander struct {
@@ -148,6 +156,100 @@ func (e *ContainerInstanceExpander) expandLogs(ctx context.Context, currentItem
 	}
 }
 
+// CanFollowLogs returns true for container log nodes
+func (e *ContainerInstanceExpander) CanFollowLogs(node *TreeNode) bool {
+	return node.ExpandReturnType == "containerInstance.logs"
+}
+
+// CanFollowPreviousLogs returns false as the container instance API only returns logs for the current instance
+func (e *ContainerInstanceExpander) CanFollowPreviousLogs(node *TreeNode) bool {
+	return false
+}
+
+// FollowLogs polls the container logs and sends lines that haven't been seen before
+func (e *ContainerInstanceExpander) FollowLogs(ctx context.Context, node *TreeNode, options LogFollowOptions, lines chan<- string) error {
+	seen := []string{}
+	for {
+		data, err := e.client.DoRequest(ctx, "GET", node.ExpandURL)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		var containerLogResponse ContainerLogResponse
+		err = json.Unmarshal([]byte(data), &containerLogResponse)
+		if err != nil {
+			return fmt.Errorf("Error unmarshalling container logs: %s", err)
+		}
+
+		current := strings.Split(strings.TrimRight(containerLogResponse.Content, "\n"), "\n")
+		if containerLogResponse.Content == "" {
+			current = []string{}
+		}
+		for _, line := range newLogLines(seen, current) {
+			select {
+			case lines <- line:
+			case <-ctx.Done():
+				return nil
+			}
+		}
+		seen = current
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(containerInstanceLogPollInterval):
+		}
+	}
+}
+
+// CanExec returns true for container nodes
+func (e *ContainerInstanceExpander) CanExec(node *TreeNode) bool {
+	return node.ExpandReturnType == "containerInstance.logs" && node.Metadata["ContainerName"] != ""
+}
+
+// StartExec starts the command in the container and returns the websocket to connect to
+func (e *ContainerInstanceExpander) StartExec(ctx context.Context, node *TreeNode, options ContainerExecOptions) (*ContainerExecSession, error) {
+	logsURL, err := url.Parse(node.ExpandURL)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse container URL: %s", err)
+	}
+	execURL := node.Parentid + "/containers/" + node.Metadata["ContainerName"] + "/exec?api-version=" + logsURL.Query().Get("api-version")
+
+	request := containerExecRequest{Command: options.Command}
+	request.TerminalSize.Rows = options.Rows
+	request.TerminalSize.Cols = options.Cols
+	body, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+	data, err := e.client.DoRequestWithBody(ctx, "POST", execURL, string(body))
+	if err != nil {
+		return nil, fmt.Errorf("Failed to start command in container: %s", err)
+	}
+	var response struct {
+		WebSocketURI string `json:"webSocketUri"`
+		Password     string `json:"password"`
+	}
+	err = json.Unmarshal([]byte(data), &response)
+	if err != nil {
+		return nil, fmt.Errorf("Error unmarshalling exec response: %s", err)
+	}
+	if response.WebSocketURI == "" {
+		return nil, fmt.Errorf("Exec response didn't include a websocket: %s", data)
+	}
+	return &ContainerExecSession{WebSocketURI: response.WebSocketURI, Password: response.Password}, nil
+}
+
+type containerExecRequest struct {
+	Command      string `json:"command"`
+	TerminalSize struct {
+		Rows int `json:"rows"`
+		Cols int `json:"cols"`
+	} `json:"terminalSize"`
+}
+
 // ContainerLogResponse for container logs
 type ContainerLogResponse struct {
 	Content string `json:"content"`
@@ -0,0 +1,425 @@
+package expanders
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lawrencegripper/azbrowse/internal/pkg/eventing"
+	"github.com/lawrencegripper/azbrowse/internal/pkg/style"
+)
+
+const (
+	containerRegistryTagsByTimeType        = "containerRegistry.repository.tagsByTime"
+	containerRegistryUntaggedManifestsType = "containerRegistry.repository.untaggedManifests"
+	containerRegistryPurgeType             = "containerRegistry.repository.purge"
+
+	containerRegistryPageSize = 100
+	// containerRegistryPurgeTimeoutPerTag is added to the default timeout for each tag (and manifest) deleted by a purge
+	containerRegistryPurgeTimeoutPerTag = 5 * time.Second
+)
+
+type registryTag struct {
+	Name           string `json:"name"`
+	Digest         string `json:"digest"`
+	CreatedTime    string `json:"createdTime"`
+	LastUpdateTime string `json:"lastUpdateTime"`
+}
+
+type registryTagsResponse struct {
+	Tags []registryTag `json:"tags"`
+}
+
+type registryManifestAttributes struct {
+	Digest         string   `json:"digest"`
+	ImageSize      int64    `json:"imageSize"`
+	LastUpdateTime string   `json:"lastUpdateTime"`
+	Architecture   string   `json:"architecture"`
+	OS             string   `json:"os"`
+	Tags           []string `json:"tags"`
+}
+
+type registryManifestsResponse struct {
+	Manifests []registryManifestAttributes `json:"manifests"`
+}
+
+func (t registryTag) lastUpdated() time.Time {
+	lastUpdated, _ := time.Parse(time.RFC3339, t.LastUpdateTime)
+	return lastUpdated
+}
+
+// NewContainerRegistryPurgeNode returns a node which previews purging the tags in the repository
+// last updated more than olderThanDays ago with names matching the pattern
+func NewContainerRegistryPurgeNode(repositoryNode *TreeNode, olderThanDays int, pattern string) (*TreeNode, error) {
+	if olderThanDays < 0 {
+		return nil, fmt.Errorf("Number of days must not be negative")
+	}
+	if _, err := compilePurgePattern(pattern); err != nil {
+		return nil, fmt.Errorf("Invalid tag pattern: %s", err)
+	}
+	return &TreeNode{
+		Parentid:  repositoryNode.ID,
+		ID:        repositoryNode.ID + "/<purge>",
+		Namespace: "containerRegistry",
+		Name:      fmt.Sprintf("Purge tags older than %d days matching %s", olderThanDays, pattern),
+		Display:   "Purge preview",
+		ItemType:  containerRegistryPurgeType,
+		ExpandURL: ExpandURLNotSupported,
+		TenantID:  repositoryNode.TenantID,
+		Metadata: map[string]string{
+			"loginServer":   repositoryNode.Metadata["loginServer"],
+			"repository":    repositoryNode.Metadata["repository"],
+			"RegistryID":    repositoryNode.Metadata["RegistryID"],
+			"olderThanDays": strconv.Itoa(olderThanDays),
+			"pattern":       pattern,
+		},
+	}, nil
+}
+
+// IsContainerRegistryRepository returns true if tags in the node can be purged
+func IsContainerRegistryRepository(node *TreeNode) bool {
+	return node != nil && node.Namespace == "containerRegistry" && node.ItemType == "containerRegistry.repository"
+}
+
+// compilePurgePattern compiles the pattern so that it must match the whole tag name
+// to avoid unexpectedly deleting tags that contain the pattern
+func compilePurgePattern(pattern string) (*regexp.Regexp, error) {
+	return regexp.Compile("^(?:" + pattern + ")$")
+}
+
+// selectTagsToPurge returns the tags last updated before the cutoff with names matching the pattern, oldest first
+func selectTagsToPurge(tags []registryTag, cutoff time.Time, pattern *regexp.Regexp) []registryTag {
+	selected := []registryTag{}
+	for _, tag := range tags {
+		lastUpdated := tag.lastUpdated()
+		if !lastUpdated.IsZero() && lastUpdated.Before(cutoff) && pattern.MatchString(tag.Name) {
+			selected = append(selected, tag)
+		}
+	}
+	sort.SliceStable(selected, func(i, j int) bool {
+		return selected[i].lastUpdated().Before(selected[j].lastUpdated())
+	})
+	return selected
+}
+
+// listTags returns all tags in the repository, following the pages of results
+func (e *ContainerRegistryExpander) listTags(ctx context.Context, loginServer string, repository string, accessToken string) ([]registryTag, error) {
+	tags := []registryTag{}
+	last := ""
+	for {
+		query := url.Values{}
+		query.Set("n", strconv.Itoa(containerRegistryPageSize))
+		if last != "" {
+			query.Set("last", last)
+		}
+		responseBuf, err := e.doRequest(ctx, "GET", fmt.Sprintf("https://%s/acr/v1/%s/_tags?%s", loginServer, repository, query.Encode()), accessToken)
+		if err != nil {
+			return nil, err
+		}
+		var response registryTagsResponse
+		err = json.Unmarshal(responseBuf, &response)
+		if err != nil {
+			return nil, fmt.Errorf("Error unmarshalling tags response: %s, %s", err, string(responseBuf))
+		}
+		if len(response.Tags) == 0 {
+			return tags, nil
+		}
+		tags = append(tags, response.Tags...)
+		last = response.Tags[len(response.Tags)-1].Name
+	}
+}
+
+// listManifests returns all manifests in the repository, following the pages of results
+func (e *ContainerRegistryExpander) listManifests(ctx context.Context, loginServer string, repository string, accessToken string) ([]registryManifestAttributes, error) {
+	manifests := []registryManifestAttributes{}
+	last := ""
+	for {
+		query := url.Values{}
+		query.Set("n", strconv.Itoa(containerRegistryPageSize))
+		if last != "" {
+			query.Set("last", last)
+		}
+		responseBuf, err := e.doRequest(ctx, "GET", fmt.Sprintf("https://%s/acr/v1/%s/_manifests?%s", loginServer, repository, query.Encode()), accessToken)
+		if err != nil {
+			return nil, err
+		}
+		var response registryManifestsResponse
+		err = json.Unmarshal(responseBuf, &response)
+		if err != nil {
+			return nil, fmt.Errorf("Error unmarshalling manifests response: %s, %s", err, string(responseBuf))
+		}
+		if len(response.Manifests) == 0 {
+			return manifests, nil
+		}
+		manifests = append(manifests, response.Manifests...)
+		last = response.Manifests[len(response.Manifests)-1].Digest
+	}
+}
+
+func (e *ContainerRegistryExpander) getCreateHousekeepingNodeFunc(loginServer string, repository string, title string, itemType string) createItemNode {
+	return func(currentItem *TreeNode, item string) *TreeNode {
+		return &TreeNode{
+			Parentid:  currentItem.ID,
+			ID:        currentItem.ID + "/" + title,
+			Namespace: "containerRegistry",
+			Name:      title,
+			Display:   title,
+			ItemType:  itemType,
+			ExpandURL: ExpandURLNotSupported,
+			Metadata: map[string]string{
+				"loginServer": loginServer,
+				"repository":  repository,
+				"RegistryID":  currentItem.Metadata["RegistryID"],
+			},
+		}
+	}
+}
+
+// expandTagsByTime lists all tags with the most recently updated first
+func (e *ContainerRegistryExpander) expandTagsByTime(ctx context.Context, currentItem *TreeNode) ExpanderResult {
+	loginServer := currentItem.Metadata["loginServer"]
+	repository := currentItem.Metadata["repository"]
+
+	accessToken, err := e.getRegistryToken(ctx, loginServer, fmt.Sprintf("repository:%s:metadata_read", repository))
+	if err != nil {
+		return ExpanderResult{
+			Err:               err,
+			SourceDescription: "ContainerRegistryExpander request",
+		}
+	}
+	tags, err := e.listTags(ctx, loginServer, repository, accessToken)
+	if err != nil {
+		return ExpanderResult{
+			Err:               err,
+			SourceDescription: "ContainerRegistryExpander request",
+		}
+	}
+	sort.SliceStable(tags, func(i, j int) bool {
+		return tags[i].lastUpdated().After(tags[j].lastUpdated())
+	})
+
+	createTagNode := e.getCreateTagNodeFunc(loginServer, repository)
+	newItems := []*TreeNode{}
+	for _, tag := range tags {
+		node := createTagNode(currentItem, tag.Name)
+		node.Display = tag.Name + "\n   " + style.Subtle("Updated: "+tag.LastUpdateTime)
+		newItems = append(newItems, node)
+	}
+
+	return ExpanderResult{
+		Err:               nil,
+		Response:          ExpanderResponse{Response: fmt.Sprintf("%d tags in %s, most recently updated first", len(tags), repository), ResponseType: ResponsePlainText},
+		SourceDescription: "ContainerRegistryExpander request",
+		Nodes:             newItems,
+		IsPrimaryResponse: true,
+	}
+}
+
+// expandUntaggedManifests lists the manifests which no longer have any tags (e.g. after a tag is pushed again)
+func (e *ContainerRegistryExpander) expandUntaggedManifests(ctx context.Context, currentItem *TreeNode) ExpanderResult {
+	loginServer := currentItem.Metadata["loginServer"]
+	repository := currentItem.Metadata["repository"]
+
+	accessToken, err := e.getRegistryToken(ctx, loginServer, fmt.Sprintf("repository:%s:metadata_read", repository))
+	if err != nil {
+		return ExpanderResult{
+			Err:               err,
+			SourceDescription: "ContainerRegistryExpander request",
+		}
+	}
+	manifests, err := e.listManifests(ctx, loginServer, repository, accessToken)
+	if err != nil {
+		return ExpanderResult{
+			Err:               err,
+			SourceDescription: "ContainerRegistryExpander request",
+		}
+	}
+
+	createManifestNode := e.getCreateManifestNodeFunc(loginServer, repository)
+	newItems := []*TreeNode{}
+	size := int64(0)
+	for _, manifest := range manifests {
+		if len(manifest.Tags) > 0 {
+			continue
+		}
+		node := createManifestNode(currentItem, manifest.Digest)
+		node.Display = manifest.Digest + "\n   " + style.Subtle(fmt.Sprintf("Updated: %s  Size: %s", manifest.LastUpdateTime, formatBytes(manifest.ImageSize)))
+		newItems = append(newItems, node)
+		size += manifest.ImageSize
+	}
+
+	return ExpanderResult{
+		Err:               nil,
+		Response:          ExpanderResponse{Response: fmt.Sprintf("%d untagged manifests in %s (%s)", len(newItems), repository, formatBytes(size)), ResponseType: ResponsePlainText},
+		SourceDescription: "ContainerRegistryExpander request",
+		Nodes:             newItems,
+		IsPrimaryResponse: true,
+	}
+}
+
+// expandPurge previews the tags that would be purged. Nothing is deleted until one of the
+// purge actions is selected and confirmed in the notifications panel
+func (e *ContainerRegistryExpander) expandPurge(ctx context.Context, currentItem *TreeNode) ExpanderResult {
+	loginServer := currentItem.Metadata["loginServer"]
+	repository := currentItem.Metadata["repository"]
+	olderThanDays, _ := strconv.Atoi(currentItem.Metadata["olderThanDays"])
+	pattern, err := compilePurgePattern(currentItem.Metadata["pattern"])
+	if err != nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Invalid tag pattern: %s", err),
+			SourceDescription: "ContainerRegistryExpander request",
+		}
+	}
+
+	accessToken, err := e.getRegistryToken(ctx, loginServer, fmt.Sprintf("repository:%s:metadata_read", repository))
+	if err != nil {
+		return ExpanderResult{
+			Err:               err,
+			SourceDescription: "ContainerRegistryExpander request",
+		}
+	}
+	tags, err := e.listTags(ctx, loginServer, repository, accessToken)
+	if err != nil {
+		return ExpanderResult{
+			Err:               err,
+			SourceDescription: "ContainerRegistryExpander request",
+		}
+	}
+
+	cutoff := time.Now().AddDate(0, 0, -olderThanDays)
+	selected := selectTagsToPurge(tags, cutoff, pattern)
+
+	lines := []string{
+		style.Title(currentItem.Name),
+		"",
+		fmt.Sprintf("Dry run: %d of %d tags in %s would be deleted", len(selected), len(tags), repository),
+		"",
+	}
+	for _, tag := range selected {
+		lines = append(lines, fmt.Sprintf("  %-40s %s  %s", tag.Name, tag.LastUpdateTime, style.Subtle(tag.Digest)))
+	}
+	if len(selected) == 0 {
+		return ExpanderResult{
+			Response:          ExpanderResponse{Response: strings.Join(lines, "\n"), ResponseType: ResponsePlainText},
+			SourceDescription: "ContainerRegistryExpander request",
+			IsPrimaryResponse: true,
+		}
+	}
+	lines = append(lines, "", style.Subtle("Select a purge action and confirm it in the notifications panel to delete the tags"))
+
+	tagNames := []string{}
+	digests := []string{}
+	for _, tag := range selected {
+		tagNames = append(tagNames, tag.Name)
+		digests = append(digests, tag.Digest)
+	}
+	newItems := []*TreeNode{
+		e.newPurgeAction(currentItem, "purge", fmt.Sprintf("Delete %d tags", len(selected)), tagNames, digests),
+		e.newPurgeAction(currentItem, "purgeWithManifests", fmt.Sprintf("Delete %d tags and the manifests left untagged", len(selected)), tagNames, digests),
+	}
+
+	return ExpanderResult{
+		Response:          ExpanderResponse{Response: strings.Join(lines, "\n"), ResponseType: ResponsePlainText},
+		SourceDescription: "ContainerRegistryExpander request",
+		Nodes:             newItems,
+		IsPrimaryResponse: true,
+	}
+}
+
+// newPurgeAction returns an action to delete the tags previewed. Tag names can't contain commas so are stored as a list
+func (e *ContainerRegistryExpander) newPurgeAction(currentItem *TreeNode, actionID string, display string, tags []string, digests []string) *TreeNode {
+	return &TreeNode{
+		Parentid:  currentItem.ID,
+		ID:        currentItem.ID + "/<actions>/" + actionID,
+		Namespace: "containerRegistry",
+		Name:      display + " from " + currentItem.Metadata["repository"],
+		Display:   display,
+		ItemType:  ActionType,
+		ExpandURL: ExpandURLNotSupported,
+		TenantID:  currentItem.TenantID,
+		Metadata: map[string]string{
+			"ActionID":    actionID,
+			"loginServer": currentItem.Metadata["loginServer"],
+			"repository":  currentItem.Metadata["repository"],
+			"RegistryID":  currentItem.Metadata["RegistryID"],
+			"tags":        strings.Join(tags, ","),
+			"digests":     strings.Join(digests, ","),
+		},
+	}
+}
+
+var _ ConfirmedActionRunner = &ContainerRegistryExpander{}
+var _ ConfirmedActionTimeoutProvider = &ContainerRegistryExpander{}
+
+// ConfirmedActionTimeout allows for the number of deletes made by the purge as each tag and manifest is deleted in turn
+func (e *ContainerRegistryExpander) ConfirmedActionTimeout(action *TreeNode) time.Duration {
+	deletes := len(strings.Split(action.Metadata["tags"], ","))
+	if action.Metadata["ActionID"] == "purgeWithManifests" {
+		deletes += len(strings.Split(action.Metadata["digests"], ","))
+	}
+	return DefaultConfirmedActionTimeout + time.Duration(deletes)*containerRegistryPurgeTimeoutPerTag
+}
+
+// RunConfirmedAction deletes the tags previewed for a purge, reporting progress with status events
+func (e *ContainerRegistryExpander) RunConfirmedAction(ctx context.Context, action *TreeNode) error {
+	loginServer := action.Metadata["loginServer"]
+	repository := action.Metadata["repository"]
+	tags := strings.Split(action.Metadata["tags"], ",")
+
+	status, _ := eventing.SendStatusEvent(&eventing.StatusEvent{
+		InProgress: true,
+		Message:    fmt.Sprintf("Purging %d tags from %s", len(tags), repository),
+	})
+	defer status.Done()
+
+	createTagNode := e.getCreateTagNodeFunc(loginServer, repository)
+	failures := []string{}
+	for i, tag := range tags {
+		status.Message = fmt.Sprintf("Deleting tag %s from %s (%d/%d)", tag, repository, i+1, len(tags))
+		status.Update()
+		if _, err := e.deleteRepositoryTag(ctx, createTagNode(action, tag)); err != nil {
+			failures = append(failures, tag)
+		}
+	}
+	if len(failures) > 0 {
+		return fmt.Errorf("Failed to delete tags: %s", strings.Join(failures, ", "))
+	}
+	if action.Metadata["ActionID"] != "purgeWithManifests" {
+		return nil
+	}
+
+	// Only delete manifests that were tagged by the purged tags, as deleting a manifest deletes any tags that still reference it
+	purged := map[string]bool{}
+	for _, digest := range strings.Split(action.Metadata["digests"], ",") {
+		purged[digest] = true
+	}
+	accessToken, err := e.getRegistryToken(ctx, loginServer, fmt.Sprintf("repository:%s:metadata_read", repository))
+	if err != nil {
+		return err
+	}
+	manifests, err := e.listManifests(ctx, loginServer, repository, accessToken)
+	if err != nil {
+		return fmt.Errorf("Tags deleted but failed to list manifests: %s", err)
+	}
+	createManifestNode := e.getCreateManifestNodeFunc(loginServer, repository)
+	for _, manifest := range manifests {
+		if !purged[manifest.Digest] || len(manifest.Tags) > 0 {
+			continue
+		}
+		status.Message = fmt.Sprintf("Deleting manifest %s from %s", manifest.Digest, repository)
+		status.Update()
+		if _, err := e.deleteRepositoryManifest(ctx, createManifestNode(action, manifest.Digest)); err != nil {
+			failures = append(failures, manifest.Digest)
+		}
+	}
+	if len(failures) > 0 {
+		return fmt.Errorf("Tags deleted but failed to delete manifests: %s", strings.Join(failures, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,358 @@
+package expanders
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/lawrencegripper/azbrowse/internal/pkg/eventing"
+	"github.com/lawrencegripper/azbrowse/internal/pkg/style"
+)
+
+const (
+	containerRegistryImageType = "containerRegistry.repository.image"
+
+	// containerRegistryVulnerabilityAssessment is the Microsoft Defender assessment with the vulnerability findings for registry images
+	containerRegistryVulnerabilityAssessment = "dbd0cb49-b563-45e7-9724-889e799fa648"
+	securityAssessmentsAPIVersion            = "2019-01-01-preview"
+)
+
+// registryManifestAccept lists the manifest media types supported, including multi-arch indexes
+var registryManifestAccept = strings.Join([]string{
+	"application/vnd.docker.distribution.manifest.v2+json",
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+	"application/vnd.oci.image.manifest.v1+json",
+	"application/vnd.oci.image.index.v1+json",
+}, ", ")
+
+type registryDescriptor struct {
+	MediaType string `json:"mediaType"`
+	Digest    string `json:"digest"`
+	Size      int64  `json:"size"`
+	Platform  *struct {
+		Architecture string `json:"architecture"`
+		OS           string `json:"os"`
+		Variant      string `json:"variant"`
+	} `json:"platform"`
+}
+
+// registryManifest holds an image manifest or, for multi-arch images, an index of the manifests for each platform
+type registryManifest struct {
+	MediaType string               `json:"mediaType"`
+	Config    *registryDescriptor  `json:"config"`
+	Layers    []registryDescriptor `json:"layers"`
+	Manifests []registryDescriptor `json:"manifests"`
+}
+
+func (m registryManifest) isIndex() bool {
+	return len(m.Manifests) > 0
+}
+
+type registryImageConfig struct {
+	Architecture string `json:"architecture"`
+	OS           string `json:"os"`
+	Variant      string `json:"variant"`
+	Created      string `json:"created"`
+	Config       struct {
+		Labels map[string]string `json:"Labels"`
+	} `json:"config"`
+	History []struct {
+		Created    string `json:"created"`
+		CreatedBy  string `json:"created_by"`
+		Comment    string `json:"comment"`
+		EmptyLayer bool   `json:"empty_layer"`
+	} `json:"history"`
+}
+
+type registryVulnerabilityListResponse struct {
+	Value    []registryVulnerability `json:"value"`
+	NextLink string                  `json:"nextLink"`
+}
+
+type registryVulnerability struct {
+	Properties struct {
+		ID          string `json:"id"`
+		DisplayName string `json:"displayName"`
+		Status      struct {
+			Code     string `json:"code"`
+			Severity string `json:"severity"`
+		} `json:"status"`
+		AdditionalData struct {
+			RepositoryName string `json:"repositoryName"`
+			ImageDigest    string `json:"imageDigest"`
+			Patchable      bool   `json:"patchable"`
+			Cve            []struct {
+				Title string `json:"title"`
+			} `json:"cve"`
+		} `json:"additionalData"`
+	} `json:"properties"`
+}
+
+func (e *ContainerRegistryExpander) getCreateImageNodeFunc(loginServer string, repository string, title string) createItemNode {
+	return func(currentItem *TreeNode, reference string) *TreeNode {
+		return &TreeNode{
+			Parentid:  currentItem.ID,
+			ID:        currentItem.ID + "/<image>/" + reference,
+			Namespace: "containerRegistry",
+			Name:      title,
+			Display:   title,
+			ItemType:  containerRegistryImageType,
+			ExpandURL: ExpandURLNotSupported,
+			Metadata: map[string]string{
+				"loginServer": loginServer,
+				"repository":  repository,
+				"reference":   reference,
+				"RegistryID":  currentItem.Metadata["RegistryID"],
+			},
+		}
+	}
+}
+
+// expandImage shows the size, platform, labels, history and vulnerabilities for an image.
+// For multi-arch images the platforms are listed with a node to show the details for each
+func (e *ContainerRegistryExpander) expandImage(ctx context.Context, currentItem *TreeNode) ExpanderResult {
+	loginServer := currentItem.Metadata["loginServer"]
+	repository := currentItem.Metadata["repository"]
+	reference := currentItem.Metadata["reference"]
+
+	accessToken, err := e.getRegistryToken(ctx, loginServer, fmt.Sprintf("repository:%s:pull", repository))
+	if err != nil {
+		return ExpanderResult{
+			Err:               err,
+			SourceDescription: "ContainerRegistryExpander request",
+		}
+	}
+
+	responseBuf, headers, err := e.doRequestWithAccept(ctx, "GET", fmt.Sprintf("https://%s/v2/%s/manifests/%s", loginServer, repository, reference), accessToken, registryManifestAccept)
+	if err != nil {
+		return ExpanderResult{
+			Err:               err,
+			SourceDescription: "ContainerRegistryExpander request",
+		}
+	}
+	var manifest registryManifest
+	err = json.Unmarshal(responseBuf, &manifest)
+	if err != nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Error unmarshalling manifest response: %s, %s", err, string(responseBuf)),
+			SourceDescription: "ContainerRegistryExpander request",
+		}
+	}
+	digest := headers.Get("Docker-Content-Digest")
+	if digest == "" {
+		digest = reference
+	}
+
+	var config *registryImageConfig
+	newItems := []*TreeNode{}
+	if manifest.isIndex() {
+		for _, child := range manifest.Manifests {
+			newItems = append(newItems, e.getCreateImageNodeFunc(loginServer, repository, child.platform())(currentItem, child.Digest))
+		}
+	} else if manifest.Config != nil {
+		configBuf, err := e.doRequest(ctx, "GET", fmt.Sprintf("https://%s/v2/%s/blobs/%s", loginServer, repository, manifest.Config.Digest), accessToken)
+		if err != nil {
+			return ExpanderResult{
+				Err:               err,
+				SourceDescription: "ContainerRegistryExpander request",
+			}
+		}
+		config = &registryImageConfig{}
+		err = json.Unmarshal(configBuf, config)
+		if err != nil {
+			return ExpanderResult{
+				Err:               fmt.Errorf("Error unmarshalling image config: %s", err),
+				SourceDescription: "ContainerRegistryExpander request",
+			}
+		}
+	}
+
+	// Vulnerability findings are only available when Microsoft Defender scanning is enabled for the registry
+	vulnerabilities, err := e.getVulnerabilities(ctx, currentItem.Metadata["RegistryID"], repository, digest)
+	if err != nil {
+		eventing.SendFailureStatus("Failed to get vulnerability findings: " + err.Error())
+	}
+
+	return ExpanderResult{
+		Err:               nil,
+		Response:          ExpanderResponse{Response: renderRegistryImage(repository+":"+reference, digest, manifest, config, vulnerabilities), ResponseType: ResponsePlainText},
+		SourceDescription: "ContainerRegistryExpander request",
+		Nodes:             newItems,
+		IsPrimaryResponse: true,
+	}
+}
+
+// getVulnerabilities returns the unhealthy vulnerability findings for the image, or nil if the registry hasn't been assessed
+func (e *ContainerRegistryExpander) getVulnerabilities(ctx context.Context, registryID string, repository string, digest string) ([]registryVulnerability, error) {
+	if registryID == "" {
+		return nil, nil
+	}
+	registryVulnerabilities, err := e.getRegistryVulnerabilities(ctx, stripQueryString(registryID))
+	if err != nil || registryVulnerabilities == nil {
+		return nil, err
+	}
+	vulnerabilities := []registryVulnerability{}
+	for _, vulnerability := range registryVulnerabilities {
+		if vulnerability.Properties.AdditionalData.RepositoryName == repository &&
+			vulnerability.Properties.AdditionalData.ImageDigest == digest {
+			vulnerabilities = append(vulnerabilities, vulnerability)
+		}
+	}
+	return vulnerabilities, nil
+}
+
+// getRegistryVulnerabilities returns the unhealthy vulnerability findings for every image in the registry, or nil if the
+// registry hasn't been assessed. The assessments can only be listed for the whole registry so the result is cached
+func (e *ContainerRegistryExpander) getRegistryVulnerabilities(ctx context.Context, registryID string) ([]registryVulnerability, error) {
+	e.vulnerabilitiesLock.Lock()
+	defer e.vulnerabilitiesLock.Unlock()
+
+	if vulnerabilities, ok := e.vulnerabilities[registryID]; ok {
+		return vulnerabilities, nil
+	}
+
+	vulnerabilities := []registryVulnerability{}
+	nextURL := registryID + "/providers/Microsoft.Security/assessments/" + containerRegistryVulnerabilityAssessment + "/subAssessments?api-version=" + securityAssessmentsAPIVersion
+	for nextURL != "" {
+		data, err := e.armClient.DoRequest(ctx, "GET", nextURL)
+		if err != nil {
+			// the assessment isn't found when Microsoft Defender scanning isn't enabled for the registry
+			if strings.Contains(err.Error(), "status code of 404") {
+				vulnerabilities = nil
+				break
+			}
+			return nil, err
+		}
+		var response registryVulnerabilityListResponse
+		err = json.Unmarshal([]byte(data), &response)
+		if err != nil {
+			return nil, fmt.Errorf("Error unmarshalling vulnerability assessments: %s", err)
+		}
+		for _, vulnerability := range response.Value {
+			if vulnerability.Properties.Status.Code == "Unhealthy" {
+				vulnerabilities = append(vulnerabilities, vulnerability)
+			}
+		}
+		nextURL, err = getNextLinkPath(response.NextLink)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if e.vulnerabilities == nil {
+		e.vulnerabilities = map[string][]registryVulnerability{}
+	}
+	e.vulnerabilities[registryID] = vulnerabilities
+	return vulnerabilities, nil
+}
+
+func (d registryDescriptor) platform() string {
+	if d.Platform == nil {
+		return d.Digest
+	}
+	platform := d.Platform.OS + "/" + d.Platform.Architecture
+	if d.Platform.Variant != "" {
+		platform += "/" + d.Platform.Variant
+	}
+	return platform
+}
+
+var registryVulnerabilitySeverities = map[string]int{"High": 0, "Medium": 1, "Low": 2}
+
+// renderRegistryImage shows the image details as text. config is nil for multi-arch indexes
+// and vulnerabilities is nil when the registry hasn't been assessed
+func renderRegistryImage(name string, digest string, manifest registryManifest, config *registryImageConfig, vulnerabilities []registryVulnerability) string {
+	lines := []string{
+		style.Title(name),
+		"",
+		"Digest:     " + digest,
+		"Media type: " + manifest.MediaType,
+	}
+
+	if manifest.isIndex() {
+		lines = append(lines, "", style.Title("Platforms:"))
+		for _, child := range manifest.Manifests {
+			lines = append(lines, fmt.Sprintf("  %-20s %10s  %s", child.platform(), formatBytes(child.Size), child.Digest))
+		}
+		lines = append(lines, "", style.Subtle("Expand a platform to see its layers, labels and history"))
+	}
+
+	if config != nil {
+		platform := config.OS + "/" + config.Architecture
+		if config.Variant != "" {
+			platform += "/" + config.Variant
+		}
+		// the compressed size is the size of the layers and config pulled from the registry
+		size := manifest.Config.Size
+		for _, layer := range manifest.Layers {
+			size += layer.Size
+		}
+		lines = append(lines,
+			"Created:    "+config.Created,
+			"OS/Arch:    "+platform,
+			fmt.Sprintf("Size:       %s compressed (%d layers)", formatBytes(size), len(manifest.Layers)))
+
+		lines = append(lines, "", style.Title("Labels:"))
+		if len(config.Config.Labels) == 0 {
+			lines = append(lines, style.Subtle("  none"))
+		}
+		labels := []string{}
+		for label := range config.Config.Labels {
+			labels = append(labels, label)
+		}
+		sort.Strings(labels)
+		for _, label := range labels {
+			lines = append(lines, "  "+label+": "+config.Config.Labels[label])
+		}
+
+		lines = append(lines, "", style.Title("Layers:"))
+		for _, layer := range manifest.Layers {
+			lines = append(lines, fmt.Sprintf("  %10s  %s", formatBytes(layer.Size), layer.Digest))
+		}
+
+		lines = append(lines, "", style.Title("History:"))
+		for _, history := range config.History {
+			createdBy := strings.TrimSpace(strings.TrimPrefix(history.CreatedBy, "/bin/sh -c #(nop)"))
+			if history.Comment != "" {
+				createdBy += " " + style.Subtle("("+history.Comment+")")
+			}
+			line := "  " + history.Created + "  " + createdBy
+			if history.EmptyLayer {
+				line = style.Subtle("  "+history.Created+"  ") + createdBy
+			}
+			lines = append(lines, line)
+		}
+	}
+
+	if vulnerabilities != nil {
+		lines = append(lines, "", style.Title("Vulnerabilities (Microsoft Defender):"))
+		if len(vulnerabilities) == 0 {
+			lines = append(lines, "  No vulnerabilities found")
+		}
+		sort.SliceStable(vulnerabilities, func(i, j int) bool {
+			return registryVulnerabilitySeverities[vulnerabilities[i].Properties.Status.Severity] < registryVulnerabilitySeverities[vulnerabilities[j].Properties.Status.Severity]
+		})
+		for _, vulnerability := range vulnerabilities {
+			properties := vulnerability.Properties
+			cves := []string{}
+			for _, cve := range properties.AdditionalData.Cve {
+				cves = append(cves, cve.Title)
+			}
+			line := fmt.Sprintf("  %-6s %s", properties.Status.Severity, properties.DisplayName)
+			if len(cves) > 0 {
+				line += " " + style.Subtle("("+strings.Join(cves, ", ")+")")
+			}
+			if properties.AdditionalData.Patchable {
+				line += " - patch available"
+			}
+			if properties.Status.Severity == "High" {
+				line = style.Warning(line)
+			}
+			lines = append(lines, line)
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
@@ -8,9 +8,13 @@ import (
 	"io/ioutil"
 	"net/http"
 	"strings"
+	"sync"
+	"testing"
 
 	"github.com/lawrencegripper/azbrowse/internal/pkg/tracing"
 	"github.com/lawrencegripper/azbrowse/pkg/armclient"
+	"github.com/nbio/st"
+	"gopkg.in/h2non/gock.v1"
 )
 
 type containerRegistryResponse struct {
@@ -32,6 +36,9 @@ var _ Expander = &ContainerRegistryExpander{}
 
 func (e *ContainerRegistryExpander) setClient(c *armclient.Client) {
 	e.armClient = c
+	e.vulnerabilitiesLock.Lock()
+	e.vulnerabilities = nil
+	e.vulnerabilitiesLock.Unlock()
 }
 
 // ContainerRegistryExpander expands Tthe data-plane aspects of a Container Registry
@@ -39,6 +46,10 @@ type ContainerRegistryExpander struct {
 	ExpanderBase
 	client    *http.Client
 	armClient *armclient.Client
+
+	// vulnerability findings are listed for the whole registry so are cached by registry ID for the session
+	vulnerabilitiesLock sync.Mutex
+	vulnerabilities     map[string][]registryVulnerability
 }
 
 // Name returns the name of the expander
@@ -104,6 +115,20 @@ func (e *ContainerRegistryExpander) Expand(ctx context.Context, currentItem *Tre
 		return e.expandRepositoryManifests(ctx, currentItem)
 	} else if currentItem.ItemType == "containerRegistry.repository.manifest" {
 		return e.expandRepositoryManifest(ctx, currentItem)
+	} else if currentItem.ItemType == containerRegistryImageType {
+		return e.expandImage(ctx, currentItem)
+	} else if currentItem.ItemType == containerRegistryTagsByTimeType {
+		return e.expandTagsByTime(ctx, currentItem)
+	} else if currentItem.ItemType == containerRegistryUntaggedManifestsType {
+		return e.expandUntaggedManifests(ctx, currentItem)
+	} else if currentItem.ItemType == containerRegistryPurgeType {
+		return e.expandPurge(ctx, currentItem)
+	} else if currentItem.ItemType == ActionType {
+		return ExpanderResult{
+			Response:          RequestActionConfirmation(e, currentItem),
+			SourceDescription: "ContainerRegistryExpander action",
+			IsPrimaryResponse: true,
+		}
 	}
 
 	return ExpanderResult{
@@ -158,6 +183,7 @@ func (e *ContainerRegistryExpander) expandRepositories(ctx context.Context, curr
 				Metadata: map[string]string{
 					"loginServer": loginServer,
 					"repository":  item,
+					"RegistryID":  registryID,
 				},
 			}
 		},
@@ -204,6 +230,8 @@ func (e *ContainerRegistryExpander) expandRepository(ctx context.Context, curren
 	newItems := []*TreeNode{
 		e.getCreateTagsNodeFunc(loginServer, repository, "Tags")(currentItem, ""),
 		e.getCreateManifestsNodeFunc(loginServer, repository, "Manifests")(currentItem, ""),
+		e.getCreateHousekeepingNodeFunc(loginServer, repository, "Tags (by last updated)", containerRegistryTagsByTimeType)(currentItem, ""),
+		e.getCreateHousekeepingNodeFunc(loginServer, repository, "Untagged manifests", containerRegistryUntaggedManifestsType)(currentItem, ""),
 	}
 
 	return ExpanderResult{
@@ -279,7 +307,10 @@ func (e *ContainerRegistryExpander) expandRepositoryTag(ctx context.Context, cur
 
 	tagElement := jsonResponse["tag"].(map[string]interface{})
 	digest := tagElement["digest"].(string)
-	newItems := []*TreeNode{e.getCreateManifestNodeFunc(loginServer, repository)(currentItem, digest)}
+	newItems := []*TreeNode{
+		e.getCreateImageNodeFunc(loginServer, repository, "Image details")(currentItem, tag),
+		e.getCreateManifestNodeFunc(loginServer, repository)(currentItem, digest),
+	}
 
 	return ExpanderResult{
 		Err:               nil,
@@ -372,6 +403,7 @@ func (e *ContainerRegistryExpander) getCreateManifestNodeFunc(loginServer string
 				"loginServer": loginServer,
 				"repository":  repository,
 				"digest":      item,
+				"RegistryID":  currentItem.Metadata["RegistryID"],
 			},
 		}
 	}
@@ -392,6 +424,7 @@ func (e *ContainerRegistryExpander) getCreateTagNodeFunc(loginServer string, rep
 				"loginServer": loginServer,
 				"repository":  repository,
 				"tag":         item,
+				"RegistryID":  currentItem.Metadata["RegistryID"],
 			},
 		}
 	}
@@ -411,6 +444,7 @@ func (e *ContainerRegistryExpander) getCreateManifestsNodeFunc(loginServer strin
 				"loginServer": loginServer,
 				"repository":  repository,
 				"lastItem":    lastItem,
+				"RegistryID":  currentItem.Metadata["RegistryID"],
 			},
 		}
 	}
@@ -430,6 +464,7 @@ func (e *ContainerRegistryExpander) getCreateTagsNodeFunc(loginServer string, re
 				"loginServer": loginServer,
 				"repository":  repository,
 				"lastItem":    lastItem,
+				"RegistryID":  currentItem.Metadata["RegistryID"],
 			},
 		}
 	}
@@ -549,31 +584,40 @@ func (e *ContainerRegistryExpander) getItemsForURL(ctx context.Context, url stri
 }
 
 func (e *ContainerRegistryExpander) doRequest(ctx context.Context, verb string, url string, accessToken string) ([]byte, error) {
+	buf, _, err := e.doRequestWithAccept(ctx, verb, url, accessToken, "")
+	return buf, err
+}
+
+// doRequestWithAccept makes a request with the Accept header set (if not empty) and returns the response headers with the body
+func (e *ContainerRegistryExpander) doRequestWithAccept(ctx context.Context, verb string, url string, accessToken string, accept string) ([]byte, http.Header, error) {
 	span, _ := tracing.StartSpanFromContext(ctx, "doRequest(containerregistry):"+url, tracing.SetTag("url", url))
 	defer span.Finish()
 
 	req, err := http.NewRequest(verb, url, nil)
 	if err != nil {
-		return []byte{}, fmt.Errorf("Failed to create request: %s", err)
+		return []byte{}, nil, fmt.Errorf("Failed to create request: %s", err)
 	}
 	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", accessToken))
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
 
 	response, err := e.client.Do(req.WithContext(ctx))
 	if err != nil {
-		return []byte{}, fmt.Errorf("Request failed: %s", err)
+		return []byte{}, nil, fmt.Errorf("Request failed: %s", err)
 	}
 
 	if response.StatusCode < 200 || response.StatusCode >= 300 {
-		return []byte{}, fmt.Errorf("DoRequest failed %v for '%s'", response.StatusCode, url)
+		return []byte{}, nil, fmt.Errorf("DoRequest failed %v for '%s'", response.StatusCode, url)
 	}
 
 	defer response.Body.Close() //nolint: errcheck
 	buf, err := ioutil.ReadAll(response.Body)
 	if err != nil {
-		return []byte{}, fmt.Errorf("Failed to read body: %s", err)
+		return []byte{}, nil, fmt.Errorf("Failed to read body: %s", err)
 	}
 
-	return buf, nil
+	return buf, response.Header, nil
 }
 
 func (e *ContainerRegistryExpander) getLoginServer(ctx context.Context, registryID string) (string, error) {
@@ -680,5 +724,233 @@ func (e *ContainerRegistryExpander) getRegistryToken(ctx context.Context, loginS
 }
 
 func (e *ContainerRegistryExpander) testCases() (bool, *[]expanderTestCase) {
-	return false, nil
+	const registryID = "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/stable/providers/Microsoft.ContainerRegistry/registries/testregistry"
+	const loginServer = "testregistry.azurecr.io"
+	const registryURL = "https://" + loginServer
+	const imageDigest = "sha256:8d4d2c3e1f0a9b8c7d6e5f4a3b2c1d0e9f8a7b6c5d4e3f2a1b0c9d8e7f6a5b4c"
+
+	tagNode := &TreeNode{
+		ID:        registryID + "/<repositories>/web/Tags/v1",
+		Namespace: "containerRegistry",
+		Name:      "v1",
+		ItemType:  "containerRegistry.repository.tag",
+		ExpandURL: ExpandURLNotSupported,
+		Metadata: map[string]string{
+			"loginServer": loginServer,
+			"repository":  "web",
+			"tag":         "v1",
+			"RegistryID":  registryID + "?api-version=2019-05-01",
+		},
+	}
+	imageNode := e.getCreateImageNodeFunc(loginServer, "web", "Image details")(tagNode, "v1")
+
+	// a registry token is requested for each operation
+	addRegistryTokenMocks := func(times int) {
+		gock.New(registryURL).
+			Get("/v2").
+			Times(times).
+			Reply(401).
+			SetHeader("WWW-Authenticate", `Bearer realm="https://testregistry.azurecr.io/oauth2/token",service="testregistry.azurecr.io"`)
+		gock.New(registryURL).
+			Post("/oauth2/exchange").
+			Times(times).
+			Reply(200).
+			JSON(map[string]string{"refresh_token": "refresh"})
+		gock.New(registryURL).
+			Post("/oauth2/token").
+			Times(times).
+			Reply(200).
+			JSON(map[string]string{"access_token": "access"})
+	}
+	imageGockConfig := func(t *testing.T) {
+		addRegistryTokenMocks(1)
+		gock.New(registryURL).
+			Get("/v2/web/manifests/v1").
+			MatchHeader("Accept", "application/vnd.oci.image.index.v1\\+json").
+			Reply(200).
+			SetHeader("Docker-Content-Digest", imageDigest).
+			File("./testdata/armsamples/containerregistry/manifest.json")
+		gock.New(registryURL).
+			Get("/v2/web/blobs/sha256:4b5e1f6c0d6a9c2e7a8f3b1d2c4e6f8a0b2c4d6e8f0a1b3c5d7e9f1a3b5c7d9e").
+			Reply(200).
+			File("./testdata/armsamples/containerregistry/config.json")
+		gock.New("https://management.azure.com").
+			Get(registryID + "/providers/Microsoft.Security/assessments/dbd0cb49-b563-45e7-9724-889e799fa648/subAssessments").
+			Reply(200).
+			File("./testdata/armsamples/containerregistry/subAssessments.json")
+	}
+	indexGockConfig := func(t *testing.T) {
+		addRegistryTokenMocks(1)
+		gock.New(registryURL).
+			Get("/v2/web/manifests/v1").
+			Reply(200).
+			File("./testdata/armsamples/containerregistry/index.json")
+		gock.New("https://management.azure.com").
+			Get(registryID + "/providers/Microsoft.Security/assessments/dbd0cb49-b563-45e7-9724-889e799fa648/subAssessments").
+			Reply(404)
+	}
+
+	repositoryNode := &TreeNode{
+		ID:        registryID + "/<repositories>/web",
+		Namespace: "containerRegistry",
+		Name:      "web",
+		ItemType:  "containerRegistry.repository",
+		ExpandURL: ExpandURLNotSupported,
+		Metadata: map[string]string{
+			"loginServer": loginServer,
+			"repository":  "web",
+			"RegistryID":  registryID,
+		},
+	}
+	addTagsMocks := func() {
+		gock.New(registryURL).
+			Get("/acr/v1/web/_tags").
+			MatchParam("n", "100").
+			Reply(200).
+			File("./testdata/armsamples/containerregistry/tags.json")
+		gock.New(registryURL).
+			Get("/acr/v1/web/_tags").
+			MatchParam("last", "v1").
+			Reply(200).
+			JSON(map[string]interface{}{"tags": []interface{}{}})
+	}
+	addManifestsMocks := func(file string, last string) {
+		gock.New(registryURL).
+			Get("/acr/v1/web/_manifests").
+			MatchParam("n", "100").
+			Reply(200).
+			File(file)
+		gock.New(registryURL).
+			Get("/acr/v1/web/_manifests").
+			MatchParam("last", last).
+			Reply(200).
+			JSON(map[string]interface{}{"manifests": []interface{}{}})
+	}
+	tagsGockConfig := func(t *testing.T) {
+		addRegistryTokenMocks(1)
+		addTagsMocks()
+	}
+	untaggedGockConfig := func(t *testing.T) {
+		addRegistryTokenMocks(1)
+		addManifestsMocks("./testdata/armsamples/containerregistry/manifests.json", "sha256:e000000000000000000000000000000000000000000000000000000000000000")
+	}
+	purgeNode, _ := NewContainerRegistryPurgeNode(repositoryNode, 30, "dev-.*")
+	purgeAction := e.newPurgeAction(purgeNode, "purgeWithManifests", "Delete 2 tags and the manifests left untagged",
+		[]string{"dev-100", "dev-123"},
+		[]string{"sha256:d100000000000000000000000000000000000000000000000000000000000000", "sha256:d123000000000000000000000000000000000000000000000000000000000000"})
+	purgeGockConfig := func(t *testing.T) {
+		addRegistryTokenMocks(4)
+		gock.New(registryURL).
+			Delete("/acr/v1/web/_tags/dev-100").
+			Reply(202)
+		gock.New(registryURL).
+			Delete("/acr/v1/web/_tags/dev-123").
+			Reply(202)
+		addManifestsMocks("./testdata/armsamples/containerregistry/manifestsAfterPurge.json", "sha256:e000000000000000000000000000000000000000000000000000000000000000")
+		// dev-123's manifest is still tagged and the other untagged manifest wasn't tagged by a purged tag
+		gock.New(registryURL).
+			Delete("/v2/web/manifests/sha256:d100000000000000000000000000000000000000000000000000000000000000").
+			Reply(202)
+	}
+
+	return true, &[]expanderTestCase{
+		{
+			name:              "Repository->TagsByLastUpdated",
+			nodeToExpand:      e.getCreateHousekeepingNodeFunc(loginServer, "web", "Tags (by last updated)", containerRegistryTagsByTimeType)(repositoryNode, ""),
+			configureGockFunc: &tagsGockConfig,
+			treeNodeCheckerFunc: func(t *testing.T, r ExpanderResult) {
+				st.Expect(t, r.Err, nil)
+				st.Expect(t, len(r.Nodes), 5)
+				names := []string{}
+				for _, node := range r.Nodes {
+					names = append(names, node.Name)
+				}
+				st.Expect(t, names, []string{"dev-124", "latest", "dev-123", "dev-100", "v1"})
+				st.Expect(t, r.Nodes[0].ItemType, "containerRegistry.repository.tag")
+			},
+		},
+		{
+			name:              "Repository->UntaggedManifests",
+			nodeToExpand:      e.getCreateHousekeepingNodeFunc(loginServer, "web", "Untagged manifests", containerRegistryUntaggedManifestsType)(repositoryNode, ""),
+			configureGockFunc: &untaggedGockConfig,
+			treeNodeCheckerFunc: func(t *testing.T, r ExpanderResult) {
+				st.Expect(t, r.Err, nil)
+				st.Expect(t, len(r.Nodes), 1)
+				st.Expect(t, r.Nodes[0].Name, "sha256:e000000000000000000000000000000000000000000000000000000000000000")
+				st.Expect(t, r.Nodes[0].ItemType, "containerRegistry.repository.manifest")
+				st.Expect(t, r.Response.Response, "1 untagged manifests in web (2.0 MB)")
+			},
+		},
+		{
+			name:              "Repository->PurgePreview",
+			nodeToExpand:      purgeNode,
+			configureGockFunc: &tagsGockConfig,
+			treeNodeCheckerFunc: func(t *testing.T, r ExpanderResult) {
+				st.Expect(t, r.Err, nil)
+				st.Expect(t, strings.Contains(r.Response.Response, "Dry run: 2 of 5 tags in web would be deleted"), true)
+				// oldest first
+				st.Expect(t, strings.Index(r.Response.Response, "dev-100") < strings.Index(r.Response.Response, "dev-123"), true)
+				st.Expect(t, strings.Contains(r.Response.Response, "dev-124"), false)
+
+				st.Expect(t, len(r.Nodes), 2)
+				st.Expect(t, r.Nodes[0].ItemType, ActionType)
+				st.Expect(t, r.Nodes[0].Metadata["tags"], "dev-100,dev-123")
+				st.Expect(t, r.Nodes[1].Metadata["ActionID"], "purgeWithManifests")
+			},
+		},
+		{
+			name:              "Purge->Confirmed",
+			nodeToExpand:      purgeAction,
+			configureGockFunc: &purgeGockConfig,
+			treeNodeCheckerFunc: func(t *testing.T, r ExpanderResult) {
+				st.Expect(t, r.Err, nil)
+
+				err := RunConfirmedAction(context.Background(), purgeAction)
+				st.Expect(t, err, nil)
+			},
+		},
+		{
+			name:              "Tag->ImageDetails",
+			nodeToExpand:      imageNode,
+			configureGockFunc: &imageGockConfig,
+			treeNodeCheckerFunc: func(t *testing.T, r ExpanderResult) {
+				st.Expect(t, r.Err, nil)
+				st.Expect(t, len(r.Nodes), 0)
+
+				response := r.Response.Response
+				st.Expect(t, strings.Contains(response, "Digest:     "+imageDigest), true)
+				st.Expect(t, strings.Contains(response, "OS/Arch:    linux/amd64"), true)
+				// layers (2797612 + 1048576) + config (1512)
+				st.Expect(t, strings.Contains(response, "Size:       3.7 MB compressed (2 layers)"), true)
+				st.Expect(t, strings.Contains(response, "maintainer: contoso"), true)
+				st.Expect(t, strings.Contains(response, "COPY web /app/web # buildkit"), true)
+
+				// only the findings for the image are shown, highest severity first
+				st.Expect(t, strings.Count(response, "Alpine Linux Security Update"), 2)
+				st.Expect(t, strings.Index(response, "musl") < strings.Index(response, "openssl"), true)
+				st.Expect(t, strings.Contains(response, "CVE-2020-1967"), true)
+
+				// the findings for the registry are cached so other images don't list them again
+				vulnerabilities, err := e.getVulnerabilities(context.Background(), registryID, "web", imageDigest)
+				st.Expect(t, err, nil)
+				st.Expect(t, len(vulnerabilities), 2)
+			},
+		},
+		{
+			name:              "Tag->ImageDetails(MultiArch)",
+			nodeToExpand:      imageNode,
+			configureGockFunc: &indexGockConfig,
+			treeNodeCheckerFunc: func(t *testing.T, r ExpanderResult) {
+				st.Expect(t, r.Err, nil)
+				st.Expect(t, len(r.Nodes), 2)
+				st.Expect(t, r.Nodes[0].Name, "linux/amd64")
+				st.Expect(t, r.Nodes[1].Name, "linux/arm/v7")
+				st.Expect(t, r.Nodes[1].Metadata["reference"], "sha256:c2b8d4a5f6e7b8c9d0e1f2a3b4c5d6e7f8a9b0c1d2e3f4a5b6c7d8e9f0a1b2c3")
+				st.Expect(t, r.Nodes[1].Metadata["RegistryID"], registryID+"?api-version=2019-05-01")
+
+				// the registry hasn't been assessed
+				st.Expect(t, strings.Contains(r.Response.Response, "Vulnerabilities"), false)
+			},
+		},
+	}
 }
@@ -0,0 +1,344 @@
+package expanders
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	kubernetesDefaultGracePeriod = 30
+	// kubernetesScaleReplicasRange is how far either side of the current number of replicas the scale options go
+	kubernetesScaleReplicasRange = 5
+	// kubernetesDrainTimeout is how long draining a node can take as each pod is evicted in turn
+	// and then waited on until it has terminated
+	kubernetesDrainTimeout = 10 * time.Minute
+	// kubernetesDrainPollInterval is how often the pods on a node are listed while waiting for evicted pods to terminate
+	kubernetesDrainPollInterval = 5 * time.Second
+)
+
+type kubernetesPodList struct {
+	Items []kubernetesPod `yaml:"items"`
+}
+
+type kubernetesPod struct {
+	Metadata struct {
+		Name            string            `yaml:"name"`
+		Namespace       string            `yaml:"namespace"`
+		Annotations     map[string]string `yaml:"annotations"`
+		OwnerReferences []struct {
+			Kind string `yaml:"kind"`
+		} `yaml:"ownerReferences"`
+	} `yaml:"metadata"`
+}
+
+var _ ConfirmedActionRunner = &AzureKubernetesServiceExpander{}
+var _ ConfirmedActionTimeoutProvider = &AzureKubernetesServiceExpander{}
+
+// HasActions checks if the item is a Kubernetes object with actions
+func (e *AzureKubernetesServiceExpander) HasActions(ctx context.Context, currentItem *TreeNode) (bool, error) {
+	switch e.getEventsKind(currentItem) {
+	case "Deployment", "StatefulSet", "DaemonSet", "Node", "Pod":
+		return true, nil
+	}
+	return false, nil
+}
+
+// ListActions returns the scale and rollout actions for workloads, cordon and drain for nodes and delete for pods
+func (e *AzureKubernetesServiceExpander) ListActions(ctx context.Context, currentItem *TreeNode) ListActionsResult {
+	kind := e.getEventsKind(currentItem)
+	name := currentItem.Name
+	objectName := strings.ToLower(kind) + " " + name
+
+	nodes := []*TreeNode{}
+	switch kind {
+	case "Deployment", "StatefulSet":
+		nodes = append(nodes,
+			e.newAction(currentItem, kind, "scale", "Scale", "Scale "+objectName),
+			e.newAction(currentItem, kind, "restart", "Restart rollout", "Restart rollout of "+objectName))
+	case "DaemonSet":
+		nodes = append(nodes,
+			e.newAction(currentItem, kind, "restart", "Restart rollout", "Restart rollout of "+objectName))
+	case "Node":
+		nodes = append(nodes,
+			e.newAction(currentItem, kind, "cordon", "Cordon", "Cordon "+objectName),
+			e.newAction(currentItem, kind, "uncordon", "Uncordon", "Uncordon "+objectName),
+			e.newAction(currentItem, kind, "drain", "Drain", "Drain "+objectName+" (cordon and evict pods)"))
+	case "Pod":
+		gracePeriod := strconv.Itoa(kubernetesDefaultGracePeriod)
+		nodes = append(nodes,
+			e.newAction(currentItem, kind, "delete:"+gracePeriod, "Delete ("+gracePeriod+"s grace period)", "Delete "+objectName+" ("+gracePeriod+"s grace period)"),
+			e.newAction(currentItem, kind, "delete:0", "Delete immediately", "Delete "+objectName+" immediately (no grace period)"))
+	}
+	return ListActionsResult{
+		Nodes:             nodes,
+		SourceDescription: "AzureKubernetesServiceExpander actions",
+	}
+}
+
+func (e *AzureKubernetesServiceExpander) newAction(currentItem *TreeNode, kind string, actionID string, display string, description string) *TreeNode {
+	return &TreeNode{
+		Parentid:  currentItem.ID,
+		ID:        currentItem.ID + "/<actions>/" + actionID,
+		Namespace: "AzureKubernetesService",
+		Name:      description,
+		Display:   display,
+		ItemType:  ActionType,
+		ExpandURL: ExpandURLNotSupported,
+		Metadata: map[string]string{
+			"ActionID":              actionID,
+			"Kind":                  kind,
+			"ObjectName":            currentItem.Name,
+			"ObjectURL":             strings.Split(currentItem.ExpandURL, "?")[0],
+			"SwaggerAPISetID":       currentItem.Metadata["SwaggerAPISetID"],
+			"SuppressSwaggerExpand": "true",
+			"SuppressGenericExpand": "true",
+		},
+	}
+}
+
+// expandAction lists the replica counts for the scale action and queues other actions for confirmation
+func (e *AzureKubernetesServiceExpander) expandAction(ctx context.Context, currentItem *TreeNode) ExpanderResult {
+	if currentItem.Metadata["ActionID"] != "scale" {
+		return ExpanderResult{
+			Response:          RequestActionConfirmation(e, currentItem),
+			SourceDescription: "AzureKubernetesServiceExpander action",
+			IsPrimaryResponse: true,
+		}
+	}
+
+	apiSet := e.getAPISetForNode(currentItem)
+	if apiSet == nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Kubernetes API not found for %s", currentItem.ID),
+			SourceDescription: "AzureKubernetesServiceExpander action",
+		}
+	}
+	current, err := apiSet.getReplicas(ctx, currentItem.Metadata["ObjectURL"])
+	if err != nil {
+		return ExpanderResult{
+			Err:               err,
+			SourceDescription: "AzureKubernetesServiceExpander action",
+		}
+	}
+
+	nodes := []*TreeNode{}
+	objectName := strings.ToLower(currentItem.Metadata["Kind"]) + " " + currentItem.Metadata["ObjectName"]
+	minReplicas := current - kubernetesScaleReplicasRange
+	if minReplicas < 0 {
+		minReplicas = 0
+	}
+	for replicas := minReplicas; replicas <= current+kubernetesScaleReplicasRange; replicas++ {
+		display := fmt.Sprintf("%d replicas", replicas)
+		if replicas == current {
+			display += " (current)"
+		}
+		action := e.newAction(currentItem, currentItem.Metadata["Kind"], fmt.Sprintf("scale:%d", replicas),
+			display,
+			fmt.Sprintf("Scale %s to %d replicas", objectName, replicas))
+		action.Metadata["ObjectName"] = currentItem.Metadata["ObjectName"]
+		action.Metadata["ObjectURL"] = currentItem.Metadata["ObjectURL"]
+		nodes = append(nodes, action)
+	}
+	return ExpanderResult{
+		Response:          ExpanderResponse{Response: fmt.Sprintf("Select the number of replicas for %s (currently %d)", objectName, current), ResponseType: ResponsePlainText},
+		Nodes:             nodes,
+		SourceDescription: "AzureKubernetesServiceExpander action",
+		IsPrimaryResponse: true,
+	}
+}
+
+// ConfirmedActionTimeout allows longer for draining a node than the other actions
+func (e *AzureKubernetesServiceExpander) ConfirmedActionTimeout(action *TreeNode) time.Duration {
+	if action.Metadata["ActionID"] == "drain" {
+		return kubernetesDrainTimeout
+	}
+	return DefaultConfirmedActionTimeout
+}
+
+// RunConfirmedAction runs a Kubernetes action once it has been confirmed in the notifications panel
+func (e *AzureKubernetesServiceExpander) RunConfirmedAction(ctx context.Context, action *TreeNode) error {
+	apiSet := e.getAPISetForNode(action)
+	if apiSet == nil {
+		return fmt.Errorf("Kubernetes API not found for %s", action.ID)
+	}
+	objectURL := action.Metadata["ObjectURL"]
+
+	parts := strings.SplitN(action.Metadata["ActionID"], ":", 2)
+	switch parts[0] {
+	case "scale":
+		if len(parts) != 2 {
+			return fmt.Errorf("Number of replicas not specified")
+		}
+		replicas, err := strconv.Atoi(parts[1])
+		if err != nil {
+			return fmt.Errorf("Invalid number of replicas: %s", parts[1])
+		}
+		return apiSet.patch(ctx, objectURL+"/scale", "application/merge-patch+json", map[string]interface{}{
+			"spec": map[string]interface{}{"replicas": replicas},
+		})
+	case "restart":
+		// Changing an annotation on the pod template triggers a rollout in the same way as `kubectl rollout restart`
+		return apiSet.patch(ctx, objectURL, "application/strategic-merge-patch+json", map[string]interface{}{
+			"spec": map[string]interface{}{
+				"template": map[string]interface{}{
+					"metadata": map[string]interface{}{
+						"annotations": map[string]string{
+							"kubectl.kubernetes.io/restartedAt": time.Now().Format(time.RFC3339),
+						},
+					},
+				},
+			},
+		})
+	case "cordon", "uncordon":
+		return apiSet.setUnschedulable(ctx, objectURL, parts[0] == "cordon")
+	case "drain":
+		return apiSet.drainNode(ctx, objectURL, action.Metadata["ObjectName"])
+	case "delete":
+		gracePeriod := strconv.Itoa(kubernetesDefaultGracePeriod)
+		if len(parts) == 2 {
+			gracePeriod = parts[1]
+		}
+		_, err := apiSet.doRequest(ctx, "DELETE", apiSet.serverURL+objectURL+"?gracePeriodSeconds="+gracePeriod)
+		return err
+	}
+	return fmt.Errorf("Unhandled action: %s", action.Metadata["ActionID"])
+}
+
+func (c SwaggerAPISetContainerService) patch(ctx context.Context, path string, contentType string, patch interface{}) error {
+	body, err := json.Marshal(patch)
+	if err != nil {
+		return err
+	}
+	_, err = c.doRequestWithContentType(ctx, "PATCH", c.serverURL+path, contentType, string(body))
+	return err
+}
+
+// getReplicas returns the desired number of replicas (spec.replicas) for a Deployment or StatefulSet
+func (c SwaggerAPISetContainerService) getReplicas(ctx context.Context, objectURL string) (int, error) {
+	data, err := c.doRequest(ctx, "GET", c.serverURL+objectURL)
+	if err != nil {
+		return 0, fmt.Errorf("Failed to get the current number of replicas: %s", err)
+	}
+	var item kubernetesItem
+	err = yaml.Unmarshal([]byte(data), &item)
+	if err != nil {
+		return 0, fmt.Errorf("Error parsing YAML response: %s", err)
+	}
+	if item.Spec.Replicas == nil {
+		// replicas defaults to 1 when not set
+		return 1, nil
+	}
+	return *item.Spec.Replicas, nil
+}
+
+func (c SwaggerAPISetContainerService) setUnschedulable(ctx context.Context, nodeURL string, unschedulable bool) error {
+	return c.patch(ctx, nodeURL, "application/merge-patch+json", map[string]interface{}{
+		"spec": map[string]interface{}{"unschedulable": unschedulable},
+	})
+}
+
+// drainNode cordons the node and evicts its pods in the same way as `kubectl drain --ignore-daemonsets`,
+// returning once the evicted pods have terminated. Evictions respect pod disruption budgets so pods that
+// can't be evicted are reported in the error
+func (c SwaggerAPISetContainerService) drainNode(ctx context.Context, nodeURL string, nodeName string) error {
+	err := c.setUnschedulable(ctx, nodeURL, true)
+	if err != nil {
+		return fmt.Errorf("Failed to cordon node: %s", err)
+	}
+
+	pods, err := c.listNodePods(ctx, nodeName)
+	if err != nil {
+		return err
+	}
+
+	failures := []string{}
+	evicted := map[string]bool{}
+	for _, pod := range pods.Items {
+		if pod.isDaemonSetOrMirrorPod() {
+			continue
+		}
+		eviction, err := json.Marshal(map[string]interface{}{
+			"apiVersion": "policy/v1",
+			"kind":       "Eviction",
+			"metadata": map[string]string{
+				"name":      pod.Metadata.Name,
+				"namespace": pod.Metadata.Namespace,
+			},
+		})
+		if err != nil {
+			return err
+		}
+		evictionURL := c.serverURL + "/api/v1/namespaces/" + pod.Metadata.Namespace + "/pods/" + pod.Metadata.Name + "/eviction"
+		_, err = c.doRequestWithContentType(ctx, "POST", evictionURL, "application/json", string(eviction))
+		if err != nil {
+			failures = append(failures, pod.Metadata.Namespace+"/"+pod.Metadata.Name)
+			continue
+		}
+		evicted[pod.Metadata.Namespace+"/"+pod.Metadata.Name] = true
+	}
+	if len(failures) > 0 {
+		return fmt.Errorf("Node cordoned but failed to evict pods: %s", strings.Join(failures, ", "))
+	}
+	return c.waitForEvictedPods(ctx, nodeName, evicted)
+}
+
+// waitForEvictedPods lists the pods on the node until none of the evicted pods are left.
+// An evicted pod stays on the node until it has terminated, which can take up to its termination grace period
+func (c SwaggerAPISetContainerService) waitForEvictedPods(ctx context.Context, nodeName string, evicted map[string]bool) error {
+	for {
+		pods, err := c.listNodePods(ctx, nodeName)
+		if err != nil {
+			return err
+		}
+		remaining := []string{}
+		for _, pod := range pods.Items {
+			if evicted[pod.Metadata.Namespace+"/"+pod.Metadata.Name] {
+				remaining = append(remaining, pod.Metadata.Namespace+"/"+pod.Metadata.Name)
+			}
+		}
+		if len(remaining) == 0 {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("Node cordoned and pods evicted but these pods hadn't terminated when the drain timed out: %s", strings.Join(remaining, ", "))
+		case <-time.After(kubernetesDrainPollInterval):
+		}
+	}
+}
+
+func (c SwaggerAPISetContainerService) listNodePods(ctx context.Context, nodeName string) (kubernetesPodList, error) {
+	query := url.Values{}
+	query.Set("fieldSelector", "spec.nodeName="+nodeName)
+	data, err := c.doRequest(ctx, "GET", c.serverURL+"/api/v1/pods?"+query.Encode())
+	if err != nil {
+		return kubernetesPodList{}, fmt.Errorf("Failed to list pods on node: %s", err)
+	}
+	var pods kubernetesPodList
+	err = yaml.Unmarshal([]byte(data), &pods)
+	if err != nil {
+		return kubernetesPodList{}, fmt.Errorf("Error parsing YAML response: %s", err)
+	}
+	return pods, nil
+}
+
+// isDaemonSetOrMirrorPod returns true for pods that are skipped when draining a node
+// as they would be recreated on the node (DaemonSet) or can't be deleted through the API (mirror pods)
+func (pod kubernetesPod) isDaemonSetOrMirrorPod() bool {
+	if _, ok := pod.Metadata.Annotations["kubernetes.io/config.mirror"]; ok {
+		return true
+	}
+	for _, owner := range pod.Metadata.OwnerReferences {
+		if owner.Kind == "DaemonSet" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,332 @@
+package expanders
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/lawrencegripper/azbrowse/internal/pkg/style"
+)
+
+const kubernetesEventsType = "AzureKubernetesService.events"
+
+// kubernetesEventKinds maps the resource names in the API paths to the kind used in events
+var kubernetesEventKinds = map[string]string{
+	"pods":                   "Pod",
+	"services":               "Service",
+	"deployments":            "Deployment",
+	"replicasets":            "ReplicaSet",
+	"statefulsets":           "StatefulSet",
+	"daemonsets":             "DaemonSet",
+	"jobs":                   "Job",
+	"cronjobs":               "CronJob",
+	"persistentvolumeclaims": "PersistentVolumeClaim",
+	"nodes":                  "Node",
+}
+
+// kubernetesObjectTemplateRegex matches the template URLs for individual objects, capturing the resource name (e.g. pods)
+var kubernetesObjectTemplateRegex = regexp.MustCompile(`^/apis?(?:/[^/{}]+)+?(?:/namespaces/\{namespace\})?/([a-z]+)/\{name\}$`)
+
+// kubernetesStatus holds the status fields used to summarise pods, workloads and nodes
+type kubernetesStatus struct {
+	Phase             string `yaml:"phase"`
+	Reason            string `yaml:"reason"`
+	ContainerStatuses []struct {
+		Ready        bool `yaml:"ready"`
+		RestartCount int  `yaml:"restartCount"`
+		State        struct {
+			Waiting *struct {
+				Reason string `yaml:"reason"`
+			} `yaml:"waiting"`
+			Terminated *struct {
+				Reason string `yaml:"reason"`
+			} `yaml:"terminated"`
+		} `yaml:"state"`
+	} `yaml:"containerStatuses"`
+	ReadyReplicas int `yaml:"readyReplicas"`
+	Conditions    []struct {
+		Type   string `yaml:"type"`
+		Status string `yaml:"status"`
+	} `yaml:"conditions"`
+}
+
+type kubernetesEventList struct {
+	Items []kubernetesEvent `yaml:"items"`
+}
+
+type kubernetesEvent struct {
+	Type           string `yaml:"type"`
+	Reason         string `yaml:"reason"`
+	Message        string `yaml:"message"`
+	Count          int    `yaml:"count"`
+	FirstTimestamp string `yaml:"firstTimestamp"`
+	LastTimestamp  string `yaml:"lastTimestamp"`
+	EventTime      string `yaml:"eventTime"`
+}
+
+// summarize returns the display text and status indicator for an item in a list of the given kind (e.g. PodList)
+func (item kubernetesItem) summarize(listKind string) (string, string) {
+	switch listKind {
+	case "PodList":
+		return item.summarizePod()
+	case "DeploymentList", "ReplicaSetList", "StatefulSetList":
+		return item.summarizeReplicas()
+	case "NodeList":
+		return item.summarizeNode()
+	}
+	return item.Metadata.Name, ""
+}
+
+func (item kubernetesItem) summarizePod() (string, string) {
+	status := item.Status.Phase
+	if item.Status.Reason != "" {
+		status = item.Status.Reason // e.g. Evicted
+	}
+	restarts := 0
+	ready := 0
+	for _, container := range item.Status.ContainerStatuses {
+		restarts += container.RestartCount
+		if container.Ready {
+			ready++
+		}
+		// a container that isn't running gives a more useful status than the pod phase (e.g. CrashLoopBackOff)
+		if container.State.Waiting != nil && container.State.Waiting.Reason != "" {
+			status = container.State.Waiting.Reason
+		} else if container.State.Terminated != nil && container.State.Terminated.Reason != "" && status == "Running" {
+			status = container.State.Terminated.Reason
+		}
+	}
+	if item.Metadata.DeletionTimestamp != "" {
+		status = "Terminating"
+	}
+
+	indicator := ""
+	switch status {
+	case "Running":
+		if ready < len(item.Status.ContainerStatuses) {
+			indicator = "⚠"
+		}
+	case "Pending", "ContainerCreating", "PodInitializing":
+		indicator = "⌛"
+	case "Succeeded", "Completed":
+		indicator = "☼"
+	case "Terminating":
+		indicator = "☠"
+	default:
+		indicator = "⛈"
+	}
+
+	display := item.Metadata.Name +
+		"\n   " + style.Subtle(fmt.Sprintf("Status: %s  Ready: %d/%d  Restarts: %d", status, ready, len(item.Status.ContainerStatuses), restarts))
+	if item.Spec.NodeName != "" {
+		display += "\n   " + style.Subtle("Node: "+item.Spec.NodeName)
+	}
+	return display, indicator
+}
+
+func (item kubernetesItem) summarizeReplicas() (string, string) {
+	desired := 1 // the API defaults replicas to 1 when not specified
+	if item.Spec.Replicas != nil {
+		desired = *item.Spec.Replicas
+	}
+	ready := item.Status.ReadyReplicas
+
+	indicator := ""
+	if ready < desired {
+		indicator = "⚠"
+		if ready == 0 {
+			indicator = "⛈"
+		}
+	}
+	return item.Metadata.Name + "\n   " + style.Subtle(fmt.Sprintf("Ready: %d/%d", ready, desired)), indicator
+}
+
+func (item kubernetesItem) summarizeNode() (string, string) {
+	problems := []string{}
+	indicator := ""
+	ready := false
+	for _, condition := range item.Status.Conditions {
+		if condition.Type == "Ready" {
+			ready = condition.Status == "True"
+			continue
+		}
+		// the other conditions (e.g. MemoryPressure, DiskPressure) indicate a problem when true
+		if condition.Status == "True" {
+			problems = append(problems, condition.Type)
+			indicator = "⚠"
+		}
+	}
+	if !ready {
+		problems = append([]string{"NotReady"}, problems...)
+		indicator = "⛈"
+	}
+	conditions := "Ready"
+	if len(problems) > 0 {
+		conditions = strings.Join(problems, ", ")
+	}
+	return item.Metadata.Name + "\n   " + style.Subtle("Conditions: "+conditions), indicator
+}
+
+// getKubernetesEventKind returns the kind for objects matching the template URL or "" if events aren't shown for the object
+func getKubernetesEventKind(templateURL string) string {
+	if strings.Contains(templateURL, "/watch/") {
+		return ""
+	}
+	match := kubernetesObjectTemplateRegex.FindStringSubmatch(templateURL)
+	if match == nil {
+		return ""
+	}
+	return kubernetesEventKinds[match[1]]
+}
+
+// newKubernetesEventsNode returns a node listing the events for the object node
+func newKubernetesEventsNode(node *TreeNode, kind string) *TreeNode {
+	values := node.SwaggerResourceType.Endpoint.Match(node.ExpandURL).Values
+	return &TreeNode{
+		Parentid:  node.ID,
+		ID:        node.ID + "/<events>",
+		Namespace: "AzureKubernetesService",
+		Name:      "Events",
+		Display:   "Events",
+		ItemType:  kubernetesEventsType,
+		ExpandURL: ExpandURLNotSupported,
+		Metadata: map[string]string{
+			"SwaggerAPISetID":         node.Metadata["SwaggerAPISetID"],
+			"InvolvedObjectKind":      kind,
+			"InvolvedObjectName":      values["name"],
+			"InvolvedObjectNamespace": values["namespace"],
+			"SuppressSwaggerExpand":   "true",
+			"SuppressGenericExpand":   "true",
+		},
+	}
+}
+
+// getEvents returns the events for the object. Events for objects that aren't namespaced (e.g. nodes)
+// are searched for across all namespaces
+func (c SwaggerAPISetContainerService) getEvents(ctx context.Context, kind string, name string, namespace string) ([]kubernetesEvent, error) {
+	path := "/api/v1/events"
+	if namespace != "" {
+		path = "/api/v1/namespaces/" + namespace + "/events"
+	}
+	query := url.Values{}
+	query.Set("fieldSelector", "involvedObject.kind="+kind+",involvedObject.name="+name)
+
+	data, err := c.doRequest(ctx, "GET", c.serverURL+path+"?"+query.Encode())
+	if err != nil {
+		return nil, err
+	}
+	var eventList kubernetesEventList
+	err = yaml.Unmarshal([]byte(data), &eventList)
+	if err != nil {
+		return nil, fmt.Errorf("Error parsing YAML response: %s", err)
+	}
+	return eventList.Items, nil
+}
+
+func (event kubernetesEvent) lastSeen() time.Time {
+	for _, value := range []string{event.LastTimestamp, event.EventTime, event.FirstTimestamp} {
+		if t, err := time.Parse(time.RFC3339, value); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// renderKubernetesEvents shows the events as a table with the most recent first
+func renderKubernetesEvents(events []kubernetesEvent, now time.Time) string {
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].lastSeen().After(events[j].lastSeen())
+	})
+
+	rows := [][]string{{"LAST SEEN", "TYPE", "REASON", "COUNT", "MESSAGE"}}
+	for _, event := range events {
+		lastSeen := "?"
+		if t := event.lastSeen(); !t.IsZero() {
+			lastSeen = formatKubernetesAge(now.Sub(t))
+		}
+		count := event.Count
+		if count == 0 {
+			count = 1
+		}
+		rows = append(rows, []string{lastSeen, event.Type, event.Reason, strconv.Itoa(count), strings.TrimSpace(event.Message)})
+	}
+
+	widths := make([]int, len(rows[0]))
+	for _, row := range rows {
+		for i, cell := range row {
+			if utf8.RuneCountInString(cell) > widths[i] {
+				widths[i] = utf8.RuneCountInString(cell)
+			}
+		}
+	}
+	lines := []string{}
+	for rowIndex, row := range rows {
+		line := ""
+		for i, cell := range row {
+			if i < len(row)-1 {
+				cell += strings.Repeat(" ", widths[i]-utf8.RuneCountInString(cell)+2)
+			}
+			line += cell
+		}
+		switch {
+		case rowIndex == 0:
+			line = style.Header(line)
+		case events[rowIndex-1].Type == "Warning":
+			line = style.Warning(line)
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// formatKubernetesAge formats the duration in the style of kubectl (e.g. 45s, 12m, 3h, 2d)
+func formatKubernetesAge(age time.Duration) string {
+	switch {
+	case age < time.Minute:
+		return fmt.Sprintf("%ds", int(age.Seconds()))
+	case age < time.Hour:
+		return fmt.Sprintf("%dm", int(age.Minutes()))
+	case age < 48*time.Hour:
+		return fmt.Sprintf("%dh", int(age.Hours()))
+	}
+	return fmt.Sprintf("%dd", int(age.Hours()/24))
+}
+
+func (e *AzureKubernetesServiceExpander) expandEvents(ctx context.Context, currentItem *TreeNode) ExpanderResult {
+	apiSet := e.getAPISetForNode(currentItem)
+	if apiSet == nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Kubernetes API not found for %s", currentItem.ID),
+			SourceDescription: "AzureKubernetesServiceExpander events",
+			IsPrimaryResponse: true,
+		}
+	}
+	kind := currentItem.Metadata["InvolvedObjectKind"]
+	name := currentItem.Metadata["InvolvedObjectName"]
+	events, err := apiSet.getEvents(ctx, kind, name, currentItem.Metadata["InvolvedObjectNamespace"])
+	if err != nil {
+		return ExpanderResult{
+			Err:               err,
+			SourceDescription: "AzureKubernetesServiceExpander events",
+			IsPrimaryResponse: true,
+		}
+	}
+
+	response := fmt.Sprintf("No events found for %s %s (events are only kept for a short time)", kind, name)
+	if len(events) > 0 {
+		response = style.Title(fmt.Sprintf("Events for %s %s", kind, name)) + "\n\n" + renderKubernetesEvents(events, time.Now())
+	}
+	return ExpanderResult{
+		Response:          ExpanderResponse{Response: response, ResponseType: ResponsePlainText},
+		SourceDescription: "AzureKubernetesServiceExpander events",
+		IsPrimaryResponse: true,
+	}
+}
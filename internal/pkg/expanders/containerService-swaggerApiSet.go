@@ -1,11 +1,13 @@
 package expanders
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
 
@@ -15,13 +17,20 @@ import (
 )
 
 type kubernetesListResponse struct {
+	Kind  string           `yaml:"kind"`
 	Items []kubernetesItem `json:"items"`
 }
 type kubernetesItem struct {
 	Metadata struct {
-		Name     string `yaml:"name"`
-		SelfLink string `yaml:"selfLink"`
+		Name              string `yaml:"name"`
+		SelfLink          string `yaml:"selfLink"`
+		DeletionTimestamp string `yaml:"deletionTimestamp"`
 	} `yaml:"metadata"`
+	Spec struct {
+		NodeName string `yaml:"nodeName"`
+		Replicas *int   `yaml:"replicas"`
+	} `yaml:"spec"`
+	Status kubernetesStatus `yaml:"status"`
 }
 type podResponse struct {
 	Spec struct {
@@ -34,6 +43,8 @@ type podResponse struct {
 var _ SwaggerAPISet = SwaggerAPISetContainerService{}
 var maxTailLines = 100
 
+const podLogTemplateURL = "/api/v1/namespaces/{namespace}/pods/{name}/log"
+
 // SwaggerAPISetContainerService holds the config for working with an AKS cluster API
 type SwaggerAPISetContainerService struct {
 	resourceTypes []swagger.ResourceType
@@ -79,13 +90,17 @@ func (c SwaggerAPISetContainerService) doRequest(ctx context.Context, verb strin
 }
 
 func (c SwaggerAPISetContainerService) doRequestWithBody(ctx context.Context, verb string, url string, body string) (string, error) {
+	return c.doRequestWithContentType(ctx, verb, url, "application/yaml", body)
+}
+
+func (c SwaggerAPISetContainerService) doRequestWithContentType(ctx context.Context, verb string, url string, contentType string, body string) (string, error) {
 	request, err := http.NewRequest(verb, url, bytes.NewReader([]byte(body)))
 	if err != nil {
 		err = fmt.Errorf("Failed to create request" + err.Error() + url)
 		return "", err
 	}
 
-	request.Header.Set("Content-Type", "application/yaml")
+	request.Header.Set("Content-Type", contentType)
 	request.Header.Set("Accept", "application/yaml")
 	response, err := c.httpClient.Do(request.WithContext(ctx))
 	if err != nil {
@@ -108,7 +123,7 @@ func (c SwaggerAPISetContainerService) doRequestWithBody(ctx context.Context, ve
 // ExpandResource returns metadata about child resources of the specified resource node
 func (c SwaggerAPISetContainerService) ExpandResource(ctx context.Context, currentItem *TreeNode, resourceType swagger.ResourceType) (APISetExpandResponse, error) {
 
-	if resourceType.Endpoint.TemplateURL == "/api/v1/namespaces/{namespace}/pods/{name}/log" {
+	if resourceType.Endpoint.TemplateURL == podLogTemplateURL {
 		if !strings.Contains(currentItem.ExpandURL, "?") { // we haven't already set the container name/tailLines!
 
 			logURL := c.serverURL + currentItem.ExpandURL
@@ -185,6 +200,7 @@ func (c SwaggerAPISetContainerService) ExpandResource(ctx context.Context, curre
 				return APISetExpandResponse{Response: data}, err
 			}
 			name := item.Metadata.Name
+			display, statusIndicator := item.summarize(listResponse.Kind)
 			deleteURL := ""
 			if subResourceType.DeleteEndpoint != nil {
 				subResourceTemplateValues := subResourceType.Endpoint.Match(subResourceURL).Values
@@ -195,11 +211,13 @@ func (c SwaggerAPISetContainerService) ExpandResource(ctx context.Context, curre
 				}
 			}
 			subResource := SubResource{
-				ID:           c.clusterID + subResourceURL,
-				Name:         name,
-				ResourceType: *subResourceType,
-				ExpandURL:    subResourceURL,
-				DeleteURL:    deleteURL,
+				ID:              c.clusterID + subResourceURL,
+				Name:            name,
+				Display:         display,
+				StatusIndicator: statusIndicator,
+				ResourceType:    *subResourceType,
+				ExpandURL:       subResourceURL,
+				DeleteURL:       deleteURL,
 			}
 			subResources = append(subResources, subResource)
 		}
@@ -212,6 +230,55 @@ func (c SwaggerAPISetContainerService) ExpandResource(ctx context.Context, curre
 	}, nil
 }
 
+// followPodLogs streams the pod logs using the follow option so that lines are sent as they are written.
+// The logs for the previous container instance can't be followed so are returned once
+func (c SwaggerAPISetContainerService) followPodLogs(ctx context.Context, expandURL string, previous bool, lines chan<- string) error {
+	logURL, err := url.Parse(c.serverURL + expandURL)
+	if err != nil {
+		return fmt.Errorf("Failed to parse log URL: %s", err)
+	}
+	query := logURL.Query()
+	if query.Get("tailLines") == "" {
+		query.Set("tailLines", strconv.Itoa(maxTailLines))
+	}
+	if previous {
+		query.Set("previous", "true")
+	} else {
+		query.Set("follow", "true")
+	}
+	logURL.RawQuery = query.Encode()
+
+	request, err := http.NewRequest("GET", logURL.String(), nil)
+	if err != nil {
+		return fmt.Errorf("Failed to create request: %s", err)
+	}
+	response, err := c.httpClient.Do(request.WithContext(ctx))
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil
+		}
+		return fmt.Errorf("Failed to request logs: %s", err)
+	}
+	defer response.Body.Close() //nolint: errcheck
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		buf, _ := ioutil.ReadAll(response.Body)
+		return fmt.Errorf("Response failed with %s: %s", response.Status, string(buf))
+	}
+
+	scanner := bufio.NewScanner(response.Body)
+	for scanner.Scan() {
+		select {
+		case lines <- scanner.Text():
+		case <-ctx.Done():
+			return nil
+		}
+	}
+	if err = scanner.Err(); err != nil && ctx.Err() == nil {
+		return fmt.Errorf("Failed reading logs: %s", err)
+	}
+	return nil
+}
+
 // Delete attempts to delete the item. Returns true if deleted, false if not handled, an error if an error occurred attempting to delete
 func (c SwaggerAPISetContainerService) Delete(ctx context.Context, item *TreeNode) (bool, error) {
 	if item.DeleteURL == "" {
@@ -10,14 +10,19 @@ import (
 	"io/ioutil"
 	"net/http"
 	"os"
+	"strings"
+	"testing"
 
 	"gopkg.in/yaml.v2"
 
 	"github.com/go-openapi/loads"
+	"github.com/nbio/st"
+	"gopkg.in/h2non/gock.v1"
 
 	azbrowse_config "github.com/lawrencegripper/azbrowse/internal/pkg/config"
 
 	"github.com/lawrencegripper/azbrowse/pkg/armclient"
+	"github.com/lawrencegripper/azbrowse/pkg/endpoints"
 	"github.com/lawrencegripper/azbrowse/pkg/swagger"
 )
 
@@ -51,6 +56,7 @@ type kubeConfigResponse struct {
 
 // Check interface
 var _ Expander = &AzureKubernetesServiceExpander{}
+var _ LogFollower = &AzureKubernetesServiceExpander{}
 
 // AzureKubernetesServiceExpander expands the kubernetes aspects of AKS
 type AzureKubernetesServiceExpander struct {
@@ -78,9 +84,24 @@ func (e *AzureKubernetesServiceExpander) DoesExpand(ctx context.Context, current
 	if currentItem.Namespace == "AzureKubernetesService" {
 		return true, nil
 	}
+	if e.getEventsKind(currentItem) != "" {
+		return true, nil
+	}
 	return false, nil
 }
 
+// getEventsKind returns the kind of the Kubernetes object for the node if events can be listed for it
+func (e *AzureKubernetesServiceExpander) getEventsKind(currentItem *TreeNode) string {
+	if currentItem.Namespace != "swagger" || currentItem.SwaggerResourceType == nil {
+		return ""
+	}
+	kind := getKubernetesEventKind(currentItem.SwaggerResourceType.Endpoint.TemplateURL)
+	if kind == "" || e.getAPISetForNode(currentItem) == nil {
+		return ""
+	}
+	return kind
+}
+
 // Expand returns ManagementPolicies in the StorageAccount
 func (e *AzureKubernetesServiceExpander) Expand(ctx context.Context, currentItem *TreeNode) ExpanderResult {
 
@@ -117,6 +138,23 @@ func (e *AzureKubernetesServiceExpander) Expand(ctx context.Context, currentItem
 		return e.expandKubernetesAPIRoot(ctx, currentItem)
 	}
 
+	if currentItem.Namespace == "AzureKubernetesService" && currentItem.ItemType == ActionType {
+		return e.expandAction(ctx, currentItem)
+	}
+
+	if currentItem.Namespace == "AzureKubernetesService" && currentItem.ItemType == kubernetesEventsType {
+		return e.expandEvents(ctx, currentItem)
+	}
+
+	if kind := e.getEventsKind(currentItem); kind != "" {
+		return ExpanderResult{
+			Response:          ExpanderResponse{Response: ""}, // Swagger expander will supply the response
+			SourceDescription: "AzureKubernetesServiceExpander request",
+			Nodes:             []*TreeNode{newKubernetesEventsNode(currentItem, kind)},
+			IsPrimaryResponse: false,
+		}
+	}
+
 	return ExpanderResult{
 		Err:               fmt.Errorf("Error - unhandled Expand"),
 		Response:          ExpanderResponse{Response: "Error!"},
@@ -124,6 +162,44 @@ func (e *AzureKubernetesServiceExpander) Expand(ctx context.Context, currentItem
 	}
 }
 
+// CanFollowLogs returns true for pod log nodes under the Kubernetes API
+func (e *AzureKubernetesServiceExpander) CanFollowLogs(node *TreeNode) bool {
+	if node.SwaggerResourceType == nil || node.SwaggerResourceType.Endpoint.TemplateURL != podLogTemplateURL {
+		return false
+	}
+	return e.getAPISetForNode(node) != nil
+}
+
+// CanFollowPreviousLogs returns true for pod log nodes as Kubernetes keeps the logs of the previous container instance
+func (e *AzureKubernetesServiceExpander) CanFollowPreviousLogs(node *TreeNode) bool {
+	return e.CanFollowLogs(node)
+}
+
+// FollowLogs streams the pod logs, or returns the logs from the previous container instance if options.Previous is set
+func (e *AzureKubernetesServiceExpander) FollowLogs(ctx context.Context, node *TreeNode, options LogFollowOptions, lines chan<- string) error {
+	apiSet := e.getAPISetForNode(node)
+	if apiSet == nil {
+		return fmt.Errorf("Kubernetes API not found for %s", node.ID)
+	}
+	return apiSet.followPodLogs(ctx, node.ExpandURL, options.Previous, lines)
+}
+
+// getAPISetForNode returns the Kubernetes API set for nodes under the Kubernetes API or nil for other nodes
+func (e *AzureKubernetesServiceExpander) getAPISetForNode(node *TreeNode) *SwaggerAPISetContainerService {
+	if node.Metadata["SwaggerAPISetID"] == "" || GetSwaggerResourceExpander() == nil {
+		return nil
+	}
+	swaggerAPISet := GetSwaggerResourceExpander().GetAPISet(node.Metadata["SwaggerAPISetID"])
+	if swaggerAPISet == nil {
+		return nil
+	}
+	apiSet, ok := (*swaggerAPISet).(SwaggerAPISetContainerService)
+	if !ok {
+		return nil
+	}
+	return &apiSet
+}
+
 func (e *AzureKubernetesServiceExpander) expandKubernetesAPIRoot(ctx context.Context, currentItem *TreeNode) ExpanderResult {
 
 	clusterID := currentItem.Metadata["ClusterID"]
@@ -364,5 +440,171 @@ func (e *AzureKubernetesServiceExpander) getClusterConfig(ctx context.Context, c
 }
 
 func (e *AzureKubernetesServiceExpander) testCases() (bool, *[]expanderTestCase) {
-	return false, nil
+	podResourceType := swagger.ResourceType{
+		Display:  "{name}",
+		Endpoint: endpoints.MustGetEndpointInfoFromURL("/api/v1/namespaces/{namespace}/pods/{name}", ""),
+	}
+	podNode := &TreeNode{
+		ID:                  testKubernetesAPISetID + "/api/v1/namespaces/default/pods/worker-7f9b8d5c4-fghij",
+		Namespace:           "swagger",
+		Name:                "worker-7f9b8d5c4-fghij",
+		ItemType:            SubResourceType,
+		ExpandURL:           "/api/v1/namespaces/default/pods/worker-7f9b8d5c4-fghij",
+		SwaggerResourceType: &podResourceType,
+		Metadata: map[string]string{
+			"SwaggerAPISetID": testKubernetesAPISetID,
+		},
+	}
+	podGockConfig := func(t *testing.T) {
+		addTestKubernetesAPISet()
+	}
+	deploymentResourceType := swagger.ResourceType{
+		Display:  "{name}",
+		Endpoint: endpoints.MustGetEndpointInfoFromURL("/apis/apps/v1/namespaces/{namespace}/deployments/{name}", ""),
+	}
+	deploymentNode := &TreeNode{
+		ID:                  testKubernetesAPISetID + "/apis/apps/v1/namespaces/default/deployments/web",
+		Namespace:           "swagger",
+		Name:                "web",
+		ItemType:            SubResourceType,
+		ExpandURL:           "/apis/apps/v1/namespaces/default/deployments/web",
+		SwaggerResourceType: &deploymentResourceType,
+		Metadata: map[string]string{
+			"SwaggerAPISetID": testKubernetesAPISetID,
+		},
+	}
+	scaleAction := e.newAction(deploymentNode, "Deployment", "scale:3", "3 replicas", "Scale deployment web to 3 replicas")
+	scaleGockConfig := func(t *testing.T) {
+		addTestKubernetesAPISet()
+		gock.New(testKubernetesServer).
+			Patch("/apis/apps/v1/namespaces/default/deployments/web/scale").
+			AddMatcher(matchPatchBody("application/merge-patch+json", `{"spec":{"replicas":3}}`)).
+			Reply(200)
+	}
+
+	nodeResourceType := swagger.ResourceType{
+		Display:  "{name}",
+		Endpoint: endpoints.MustGetEndpointInfoFromURL("/api/v1/nodes/{name}", ""),
+	}
+	nodeNode := &TreeNode{
+		ID:                  testKubernetesAPISetID + "/api/v1/nodes/aks-nodepool1-12345678-0",
+		Namespace:           "swagger",
+		Name:                "aks-nodepool1-12345678-0",
+		ItemType:            SubResourceType,
+		ExpandURL:           "/api/v1/nodes/aks-nodepool1-12345678-0",
+		SwaggerResourceType: &nodeResourceType,
+		Metadata: map[string]string{
+			"SwaggerAPISetID": testKubernetesAPISetID,
+		},
+	}
+	scaleReplicasGockConfig := func(t *testing.T) {
+		addTestKubernetesAPISet()
+		gock.New(testKubernetesServer).
+			Get("/apis/apps/v1/namespaces/default/deployments/web$").
+			Reply(200).
+			BodyString("kind: Deployment\nmetadata:\n  name: web\nspec:\n  replicas: 2\n")
+	}
+
+	drainAction := e.newAction(nodeNode, "Node", "drain", "Drain", "Drain node aks-nodepool1-12345678-0 (cordon and evict pods)")
+	drainGockConfig := func(t *testing.T) {
+		addTestKubernetesAPISet()
+		gock.New(testKubernetesServer).
+			Patch("/api/v1/nodes/aks-nodepool1-12345678-0").
+			AddMatcher(matchPatchBody("application/merge-patch+json", `{"spec":{"unschedulable":true}}`)).
+			Reply(200)
+		gock.New(testKubernetesServer).
+			Get("/api/v1/pods").
+			MatchParam("fieldSelector", "spec.nodeName=aks-nodepool1-12345678-0").
+			Reply(200).
+			File("./testdata/armsamples/kubernetes/nodepods.yaml")
+		// the DaemonSet and mirror pods are skipped so only the one eviction is expected
+		gock.New(testKubernetesServer).
+			Post("/api/v1/namespaces/default/pods/web-5d8f7c6b9-abcde/eviction").
+			AddMatcher(matchPatchBody("application/json", `{"apiVersion":"policy/v1","kind":"Eviction","metadata":{"name":"web-5d8f7c6b9-abcde","namespace":"default"}}`)).
+			Reply(201)
+		// the drain completes once the evicted pod has gone from the node
+		gock.New(testKubernetesServer).
+			Get("/api/v1/pods").
+			MatchParam("fieldSelector", "spec.nodeName=aks-nodepool1-12345678-0").
+			Reply(200).
+			BodyString("kind: PodList\nitems:\n- metadata:\n    name: kube-proxy-x7k2p\n    namespace: kube-system\n")
+	}
+
+	eventsGockConfig := func(t *testing.T) {
+		addTestKubernetesAPISet()
+		gock.New(testKubernetesServer).
+			Get("/api/v1/namespaces/default/events").
+			MatchParam("fieldSelector", "involvedObject.kind=Pod,involvedObject.name=worker-7f9b8d5c4-fghij").
+			Reply(200).
+			File("./testdata/armsamples/kubernetes/events.yaml")
+	}
+
+	return true, &[]expanderTestCase{
+		{
+			name:              "Pod->EventsNode",
+			nodeToExpand:      podNode,
+			configureGockFunc: &podGockConfig,
+			treeNodeCheckerFunc: func(t *testing.T, r ExpanderResult) {
+				st.Expect(t, r.Err, nil)
+				st.Expect(t, len(r.Nodes), 1)
+				st.Expect(t, r.Nodes[0].Name, "Events")
+				st.Expect(t, r.Nodes[0].Metadata["InvolvedObjectKind"], "Pod")
+				st.Expect(t, r.Nodes[0].Metadata["InvolvedObjectName"], "worker-7f9b8d5c4-fghij")
+				st.Expect(t, r.Nodes[0].Metadata["InvolvedObjectNamespace"], "default")
+			},
+		},
+		{
+			name:              "Pod->Events",
+			nodeToExpand:      newKubernetesEventsNode(podNode, "Pod"),
+			configureGockFunc: &eventsGockConfig,
+			treeNodeCheckerFunc: func(t *testing.T, r ExpanderResult) {
+				st.Expect(t, r.Err, nil)
+				lines := strings.Split(r.Response.Response, "\n")
+				st.Expect(t, len(lines), 5)
+				// most recent first
+				st.Expect(t, strings.Contains(lines[3], "BackOff"), true)
+				st.Expect(t, strings.Contains(lines[3], "30"), true)
+				st.Expect(t, strings.Contains(lines[4], "Pulled"), true)
+			},
+		},
+		{
+			name:              "Deployment->ScaleReplicas",
+			nodeToExpand:      e.newAction(deploymentNode, "Deployment", "scale", "Scale", "Scale deployment web"),
+			configureGockFunc: &scaleReplicasGockConfig,
+			treeNodeCheckerFunc: func(t *testing.T, r ExpanderResult) {
+				st.Expect(t, r.Err, nil)
+				// 0 to 2+kubernetesScaleReplicasRange as the range is capped at 0
+				st.Expect(t, len(r.Nodes), 3+kubernetesScaleReplicasRange)
+				st.Expect(t, r.Nodes[2].Display, "2 replicas (current)")
+				st.Expect(t, r.Nodes[3].Name, "Scale deployment web to 3 replicas")
+				st.Expect(t, r.Nodes[3].Metadata["ActionID"], "scale:3")
+				st.Expect(t, r.Nodes[3].Metadata["ObjectURL"], "/apis/apps/v1/namespaces/default/deployments/web")
+			},
+		},
+		{
+			name:              "Deployment->ScaleConfirmed",
+			nodeToExpand:      scaleAction,
+			configureGockFunc: &scaleGockConfig,
+			treeNodeCheckerFunc: func(t *testing.T, r ExpanderResult) {
+				st.Expect(t, r.Err, nil)
+				st.Expect(t, len(r.Nodes), 0)
+				st.Expect(t, strings.Contains(r.Response.Response, "Scale deployment web to 3 replicas"), true)
+
+				err := RunConfirmedAction(context.Background(), scaleAction)
+				st.Expect(t, err, nil)
+			},
+		},
+		{
+			name:              "Node->DrainConfirmed",
+			nodeToExpand:      drainAction,
+			configureGockFunc: &drainGockConfig,
+			treeNodeCheckerFunc: func(t *testing.T, r ExpanderResult) {
+				st.Expect(t, r.Err, nil)
+				st.Expect(t, getConfirmedActionTimeout(drainAction), kubernetesDrainTimeout)
+
+				err := RunConfirmedAction(context.Background(), drainAction)
+				st.Expect(t, err, nil)
+			},
+		},
+	}
 }
@@ -0,0 +1,52 @@
+package expanders
+
+import (
+	"github.com/lawrencegripper/azbrowse/pkg/endpoints"
+	"github.com/lawrencegripper/azbrowse/pkg/swagger"
+)
+
+// There is no data-plane spec for Cosmos DB in swagger-specs so the
+// resource types are declared here in the same shape as the generated code.
+// The REST API version is sent in the x-ms-version header so the endpoints
+// don't have an api-version
+const (
+	cosmosDBContainerTemplateURL = "/dbs/{databaseId}/colls/{containerId}"
+	cosmosDBDocumentsTemplateURL = "/dbs/{databaseId}/colls/{containerId}/docs"
+	cosmosDBDocumentTemplateURL  = "/dbs/{databaseId}/colls/{containerId}/docs/{documentId}"
+)
+
+func (e *CosmosDBExpander) loadResourceTypes() []swagger.ResourceType {
+	return []swagger.ResourceType{
+		{
+			Display:  "databases",
+			Endpoint: endpoints.MustGetEndpointInfoFromURL("/dbs", ""),
+			SubResources: []swagger.ResourceType{
+				{
+					Display:  "{databaseId}",
+					Endpoint: endpoints.MustGetEndpointInfoFromURL("/dbs/{databaseId}", ""),
+					Children: []swagger.ResourceType{
+						{
+							Display:  "containers",
+							Endpoint: endpoints.MustGetEndpointInfoFromURL("/dbs/{databaseId}/colls", ""),
+							SubResources: []swagger.ResourceType{
+								{
+									Display:  "{containerId}",
+									Endpoint: endpoints.MustGetEndpointInfoFromURL(cosmosDBContainerTemplateURL, ""),
+									Children: []swagger.ResourceType{
+										{
+											Display:  "documents",
+											Endpoint: endpoints.MustGetEndpointInfoFromURL(cosmosDBDocumentsTemplateURL, ""),
+											SubResources: []swagger.ResourceType{
+												{
+													Display:        "{documentId}",
+													Endpoint:       endpoints.MustGetEndpointInfoFromURL(cosmosDBDocumentTemplateURL, ""),
+													DeleteEndpoint: endpoints.MustGetEndpointInfoFromURL(cosmosDBDocumentTemplateURL, ""),
+													PutEndpoint:    endpoints.MustGetEndpointInfoFromURL(cosmosDBDocumentTemplateURL, ""),
+												}},
+										}},
+								}},
+						}},
+				}},
+		},
+	}
+}
@@ -0,0 +1,458 @@
+package expanders
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lawrencegripper/azbrowse/pkg/swagger"
+)
+
+const (
+	cosmosDBAPIVersion = "2018-12-31"
+	cosmosDBPageSize   = 50
+)
+
+type cosmosDBListResponse struct {
+	Databases           []json.RawMessage `json:"Databases"`
+	DocumentCollections []json.RawMessage `json:"DocumentCollections"`
+	Documents           []json.RawMessage `json:"Documents"`
+}
+
+type cosmosDBContainer struct {
+	PartitionKey struct {
+		Paths []string `json:"paths"`
+	} `json:"partitionKey"`
+}
+
+// cosmosDBStatusError is returned when the data-plane responds with a non-success status code
+type cosmosDBStatusError struct {
+	StatusCode int
+	Status     string
+	URL        string
+	Body       string
+}
+
+func (e cosmosDBStatusError) Error() string {
+	return fmt.Sprintf("Response failed with %s (%s): %s", e.Status, e.URL, e.Body)
+}
+
+var _ SwaggerAPISet = SwaggerAPISetCosmosDB{}
+
+// SwaggerAPISetCosmosDB holds the config for working with the data-plane of a Cosmos DB (SQL API) account
+type SwaggerAPISetCosmosDB struct {
+	resourceTypes []swagger.ResourceType
+	httpClient    http.Client
+	accountID     string // ARM resource ID for the account (/subscriptions/....)
+	endpoint      string // https://<name>.documents.azure.com
+	masterKey     []byte
+}
+
+// NewSwaggerAPISetCosmosDB creates a new SwaggerAPISetCosmosDB
+func NewSwaggerAPISetCosmosDB(resourceTypes []swagger.ResourceType, accountID string, endpoint string, masterKey []byte) SwaggerAPISetCosmosDB {
+	c := SwaggerAPISetCosmosDB{}
+	c.resourceTypes = resourceTypes
+	c.httpClient = http.Client{}
+	c.accountID = accountID
+	c.endpoint = strings.TrimSuffix(strings.TrimSuffix(endpoint, "/"), ":443")
+	c.masterKey = masterKey
+	return c
+}
+
+// ID returns the ID for the APISet
+func (c SwaggerAPISetCosmosDB) ID() string {
+	return c.accountID
+}
+
+// MatchChildNodesByName indicates whether child nodes should be matched by name (or position)
+func (c SwaggerAPISetCosmosDB) MatchChildNodesByName() bool {
+	return true
+}
+
+// AppliesToNode is called by the Swagger exapnder to test whether the node applies to this APISet
+func (c SwaggerAPISetCosmosDB) AppliesToNode(node *TreeNode) bool {
+	// this function is only called for nodes that don't have the SwaggerAPISetID set
+	// this should never happen for cosmos nodes
+	return false
+}
+
+// GetResourceTypes returns the ResourceTypes for the API Set
+func (c SwaggerAPISetCosmosDB) GetResourceTypes() []swagger.ResourceType {
+	return c.resourceTypes
+}
+
+// DoRequest makes a request against the account endpoint, signing it with the account key
+// The response headers are returned as paging and concurrency information is passed in headers
+func (c SwaggerAPISetCosmosDB) DoRequest(ctx context.Context, verb string, path string, headers map[string]string, body string) (string, http.Header, error) {
+	// Requests are signed using the (unescaped) resource type and link, e.g. "docs" and "dbs/db1/colls/coll1"
+	resourceType, resourceLink, err := cosmosDBResourceTypeAndLink(path)
+	if err != nil {
+		return "", nil, err
+	}
+	date := strings.ToLower(time.Now().UTC().Format(http.TimeFormat))
+	stringToSign := strings.ToLower(verb) + "\n" + resourceType + "\n" + resourceLink + "\n" + date + "\n\n"
+	mac := hmac.New(sha256.New, c.masterKey)
+	_, _ = mac.Write([]byte(stringToSign))
+	signature := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+
+	requestURL := c.endpoint + path
+	request, err := http.NewRequest(verb, requestURL, bytes.NewReader([]byte(body)))
+	if err != nil {
+		return "", nil, fmt.Errorf("Failed to create request: %s (%s)", err, requestURL)
+	}
+	request.Header.Set("Authorization", url.QueryEscape("type=master&ver=1.0&sig="+signature))
+	request.Header.Set("x-ms-date", date)
+	request.Header.Set("x-ms-version", cosmosDBAPIVersion)
+	request.Header.Set("Accept", "application/json")
+	if body != "" {
+		request.Header.Set("Content-Type", "application/json")
+	}
+	for name, value := range headers {
+		request.Header.Set(name, value)
+	}
+
+	response, err := c.httpClient.Do(request.WithContext(ctx))
+	if err != nil {
+		return "", nil, fmt.Errorf("Failed: %s (%s)", err, requestURL)
+	}
+	defer response.Body.Close() //nolint: errcheck
+	buf, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return "", nil, fmt.Errorf("Failed to read body: %s", err)
+	}
+	data := string(buf)
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return "", response.Header, cosmosDBStatusError{StatusCode: response.StatusCode, Status: response.Status, URL: requestURL, Body: data}
+	}
+	return data, response.Header, nil
+}
+
+// ExpandResource returns metadata about child resources of the specified resource node
+func (c SwaggerAPISetCosmosDB) ExpandResource(ctx context.Context, currentItem *TreeNode, resourceType swagger.ResourceType) (APISetExpandResponse, error) {
+
+	if len(resourceType.SubResources) == 0 {
+		return c.expandItem(ctx, currentItem, resourceType)
+	}
+	if len(resourceType.SubResources) > 1 {
+		return APISetExpandResponse{}, fmt.Errorf("Only expecting a single SubResource type")
+	}
+
+	templateValues := resourceType.Endpoint.Match(currentItem.ExpandURL).Values
+	isDocuments := resourceType.Endpoint.TemplateURL == cosmosDBDocumentsTemplateURL
+	query := currentItem.Metadata["Query"]
+
+	verb := "GET"
+	body := ""
+	headers := map[string]string{}
+	partitionKeyPaths := []string{}
+	if isDocuments {
+		var err error
+		partitionKeyPaths, err = c.getPartitionKeyPaths(ctx, currentItem, templateValues)
+		if err != nil {
+			return APISetExpandResponse{}, err
+		}
+		headers["x-ms-max-item-count"] = strconv.Itoa(cosmosDBPageSize)
+		if continuation := currentItem.Metadata["Continuation"]; continuation != "" {
+			headers["x-ms-continuation"] = continuation
+		}
+		if query != "" {
+			queryBody, err := json.Marshal(map[string]interface{}{
+				"query":      query,
+				"parameters": []interface{}{},
+			})
+			if err != nil {
+				return APISetExpandResponse{}, fmt.Errorf("Error building query: %s", err)
+			}
+			verb = "POST"
+			body = string(queryBody)
+			headers["Content-Type"] = "application/query+json"
+			headers["x-ms-documentdb-isquery"] = "True"
+			headers["x-ms-documentdb-query-enablecrosspartition"] = "True"
+		}
+	}
+
+	data, responseHeaders, err := c.DoRequest(ctx, verb, currentItem.ExpandURL, headers, body)
+	if err != nil {
+		return APISetExpandResponse{Response: err.Error(), ResponseType: ResponsePlainText}, fmt.Errorf("Failed to make request: %s", err)
+	}
+
+	var listResponse cosmosDBListResponse
+	err = json.Unmarshal([]byte(data), &listResponse)
+	if err != nil {
+		return APISetExpandResponse{Response: data, ResponseType: ResponseJSON}, fmt.Errorf("Error parsing response: %s", err)
+	}
+	items := append(append(listResponse.Databases, listResponse.DocumentCollections...), listResponse.Documents...)
+
+	subResourceType := resourceType.SubResources[0]
+	subResourceEndpoint := subResourceType.Endpoint
+	newTemplateName := subResourceEndpoint.URLSegments[len(subResourceEndpoint.URLSegments)-1].Name
+
+	subResources := []SubResource{}
+	for _, itemJSON := range items {
+		var item map[string]interface{}
+		name := ""
+		if json.Unmarshal(itemJSON, &item) == nil {
+			name, _ = item["id"].(string)
+		}
+		if name == "" {
+			// Query projections (e.g. SELECT VALUE COUNT(1) FROM c) don't return
+			// documents so the results are only shown in the response
+			continue
+		}
+
+		templateValues[newTemplateName] = url.PathEscape(name)
+		subResourceURL, err := subResourceEndpoint.BuildURL(templateValues)
+		if err != nil {
+			return APISetExpandResponse{}, fmt.Errorf("Error building subresource URL: %s", err)
+		}
+		deleteURL := ""
+		if subResourceType.DeleteEndpoint != nil {
+			deleteURL, err = subResourceType.DeleteEndpoint.BuildURL(templateValues)
+			if err != nil {
+				return APISetExpandResponse{}, fmt.Errorf("Error building subresource delete url '%s': %s", subResourceType.DeleteEndpoint.TemplateURL, err)
+			}
+		}
+
+		id := c.accountID + subResourceURL
+		if query != "" {
+			// Keep query results distinct from the nodes in the document list
+			id = currentItem.ID + "/" + name
+		}
+		metadata := map[string]string{}
+		if isDocuments && len(partitionKeyPaths) > 0 {
+			metadata["PartitionKey"] = cosmosDBPartitionKeyValue(item, partitionKeyPaths)
+		}
+
+		subResources = append(subResources, SubResource{
+			ID:           id,
+			Name:         name,
+			ResourceType: subResourceType,
+			ExpandURL:    subResourceURL,
+			DeleteURL:    deleteURL,
+			Metadata:     metadata,
+		})
+	}
+
+	continuation := responseHeaders.Get("x-ms-continuation")
+	if isDocuments && continuation != "" {
+		partitionKeyPathsJSON, _ := json.Marshal(partitionKeyPaths)
+		subResources = append(subResources, SubResource{
+			ID:           currentItem.ID + "/more",
+			Name:         "more...",
+			ResourceType: resourceType,
+			ExpandURL:    currentItem.ExpandURL,
+			Metadata: map[string]string{
+				"Continuation":      continuation,
+				"Query":             query,
+				"PartitionKeyPaths": string(partitionKeyPathsJSON),
+			},
+		})
+	}
+
+	return APISetExpandResponse{
+		Response:     data,
+		ResponseType: ResponseJSON,
+		SubResources: subResources,
+	}, nil
+}
+
+func (c SwaggerAPISetCosmosDB) expandItem(ctx context.Context, currentItem *TreeNode, resourceType swagger.ResourceType) (APISetExpandResponse, error) {
+	data, _, err := c.DoRequest(ctx, "GET", currentItem.ExpandURL, cosmosDBPartitionKeyHeaders(currentItem), "")
+	if err != nil {
+		return APISetExpandResponse{Response: err.Error(), ResponseType: ResponsePlainText}, fmt.Errorf("Failed to make request: %s", err)
+	}
+
+	response := APISetExpandResponse{
+		Response:     data,
+		ResponseType: ResponseJSON,
+	}
+	if resourceType.Endpoint.TemplateURL == cosmosDBContainerTemplateURL {
+		// Pass the partition key definition to the documents node to avoid looking it up again
+		partitionKeyPaths, err := parseCosmosDBPartitionKeyPaths(data)
+		if err != nil {
+			return response, err
+		}
+		response.ChildMetadata = map[string]string{
+			"PartitionKeyPaths": partitionKeyPaths,
+		}
+	}
+	return response, nil
+}
+
+// getPartitionKeyPaths returns the partition key paths for the container that a documents node belongs to
+func (c SwaggerAPISetCosmosDB) getPartitionKeyPaths(ctx context.Context, currentItem *TreeNode, templateValues map[string]string) ([]string, error) {
+	partitionKeyPathsJSON, ok := currentItem.Metadata["PartitionKeyPaths"]
+	if !ok {
+		containerURL := "/dbs/" + templateValues["databaseId"] + "/colls/" + templateValues["containerId"]
+		data, _, err := c.DoRequest(ctx, "GET", containerURL, nil, "")
+		if err != nil {
+			return []string{}, fmt.Errorf("Failed to get container: %s", err)
+		}
+		partitionKeyPathsJSON, err = parseCosmosDBPartitionKeyPaths(data)
+		if err != nil {
+			return []string{}, err
+		}
+	}
+	partitionKeyPaths := []string{}
+	err := json.Unmarshal([]byte(partitionKeyPathsJSON), &partitionKeyPaths)
+	if err != nil {
+		return []string{}, fmt.Errorf("Error parsing partition key paths: %s", err)
+	}
+	return partitionKeyPaths, nil
+}
+
+// Delete attempts to delete the item. Returns true if deleted, false if not handled, an error if an error occurred attempting to delete
+func (c SwaggerAPISetCosmosDB) Delete(ctx context.Context, item *TreeNode) (bool, error) {
+	if item.DeleteURL == "" {
+		return false, fmt.Errorf("Item cannot be deleted (No DeleteURL)")
+	}
+
+	_, _, err := c.DoRequest(ctx, "DELETE", item.DeleteURL, cosmosDBPartitionKeyHeaders(item), "")
+	if err != nil {
+		err = fmt.Errorf("Failed to delete: %s (%s)", err.Error(), item.DeleteURL)
+		return false, err
+	}
+	return true, nil
+}
+
+// Update replaces the document with the new content. The _etag from the content is sent
+// as If-Match so that the update fails if the document has changed since it was loaded
+func (c SwaggerAPISetCosmosDB) Update(ctx context.Context, item *TreeNode, content string) error {
+	matchResult := item.SwaggerResourceType.Endpoint.Match(item.ExpandURL)
+	if !matchResult.IsMatch {
+		return fmt.Errorf("item.ExpandURL didn't match current Endpoint")
+	}
+
+	url, err := item.SwaggerResourceType.PutEndpoint.BuildURL(matchResult.Values)
+	if err != nil {
+		return fmt.Errorf("Error building PUT url: %s", err)
+	}
+
+	var document map[string]interface{}
+	err = json.Unmarshal([]byte(content), &document)
+	if err != nil {
+		return fmt.Errorf("Error parsing content: %s", err)
+	}
+
+	headers := cosmosDBPartitionKeyHeaders(item)
+	if etag, ok := document["_etag"].(string); ok && etag != "" {
+		headers["If-Match"] = etag
+	}
+
+	_, _, err = c.DoRequest(ctx, "PUT", url, headers, content)
+	if statusErr, ok := err.(cosmosDBStatusError); ok && statusErr.StatusCode == http.StatusPreconditionFailed {
+		return fmt.Errorf("The document has been changed since it was loaded (_etag mismatch). Refresh and try again")
+	}
+	if err != nil {
+		return fmt.Errorf("Error from PUT: %s", err)
+	}
+	return nil
+}
+
+// NewCosmosDBQueryNode returns a node which runs a SQL query against the container for a documents node
+func NewCosmosDBQueryNode(documentsNode *TreeNode, query string) *TreeNode {
+	metadata := map[string]string{}
+	for key, value := range documentsNode.Metadata {
+		metadata[key] = value
+	}
+	delete(metadata, "Continuation")
+	metadata["Query"] = query
+
+	return &TreeNode{
+		Parentid:            documentsNode.ID,
+		ID:                  documentsNode.ID + "/<query>",
+		Namespace:           documentsNode.Namespace,
+		Name:                "query",
+		Display:             query,
+		ExpandURL:           documentsNode.ExpandURL,
+		ItemType:            documentsNode.ItemType,
+		SwaggerResourceType: documentsNode.SwaggerResourceType,
+		TenantID:            documentsNode.TenantID,
+		Metadata:            metadata,
+	}
+}
+
+// cosmosDBResourceTypeAndLink returns the resource type and link used to sign a request for a path
+// Paths with an even number of segments address a resource (e.g. /dbs/db1 -> "dbs", "dbs/db1")
+// and paths with an odd number address a feed (e.g. /dbs/db1/colls -> "colls", "dbs/db1")
+func cosmosDBResourceTypeAndLink(path string) (string, string, error) {
+	if i := strings.Index(path, "?"); i >= 0 {
+		path = path[:i]
+	}
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	for i, segment := range segments {
+		unescaped, err := url.PathUnescape(segment)
+		if err != nil {
+			return "", "", fmt.Errorf("Error unescaping path '%s': %s", path, err)
+		}
+		segments[i] = unescaped
+	}
+	if len(segments)%2 == 1 {
+		return segments[len(segments)-1], strings.Join(segments[:len(segments)-1], "/"), nil
+	}
+	return segments[len(segments)-2], strings.Join(segments, "/"), nil
+}
+
+// parseCosmosDBPartitionKeyPaths returns the partition key paths from a container as a JSON array
+func parseCosmosDBPartitionKeyPaths(containerJSON string) (string, error) {
+	var container cosmosDBContainer
+	err := json.Unmarshal([]byte(containerJSON), &container)
+	if err != nil {
+		return "", fmt.Errorf("Error parsing container: %s", err)
+	}
+	paths := container.PartitionKey.Paths
+	if paths == nil {
+		paths = []string{}
+	}
+	pathsJSON, err := json.Marshal(paths)
+	if err != nil {
+		return "", fmt.Errorf("Error marshalling partition key paths: %s", err)
+	}
+	return string(pathsJSON), nil
+}
+
+// cosmosDBPartitionKeyValue returns the partition key header value for a document,
+// e.g. ["tenant1"] for a document with {"tenantId": "tenant1"} and paths ["/tenantId"]
+func cosmosDBPartitionKeyValue(document map[string]interface{}, partitionKeyPaths []string) string {
+	values := []interface{}{}
+	for _, path := range partitionKeyPaths {
+		var value interface{} = document
+		found := true
+		for _, property := range strings.Split(strings.TrimPrefix(path, "/"), "/") {
+			object, ok := value.(map[string]interface{})
+			if !ok {
+				found = false
+				break
+			}
+			value, found = object[property]
+			if !found {
+				break
+			}
+		}
+		if !found {
+			// Documents without a value for the partition key are stored under the 'undefined' key
+			value = map[string]interface{}{}
+		}
+		values = append(values, value)
+	}
+	valuesJSON, _ := json.Marshal(values)
+	return string(valuesJSON)
+}
+
+func cosmosDBPartitionKeyHeaders(item *TreeNode) map[string]string {
+	headers := map[string]string{}
+	if partitionKey := item.Metadata["PartitionKey"]; partitionKey != "" {
+		headers["x-ms-documentdb-partitionkey"] = partitionKey
+	}
+	return headers
+}
@@ -0,0 +1,279 @@
+package expanders
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/lawrencegripper/azbrowse/pkg/armclient"
+	"github.com/nbio/st"
+	"gopkg.in/h2non/gock.v1"
+)
+
+const (
+	cosmosDBTemplateURL    string = "/subscriptions/{subscriptionId}/resourceGroups/{resourceGroupName}/providers/Microsoft.DocumentDB/databaseAccounts/{accountName}"
+	cosmosDBARMAPIVersion  string = "2019-08-01"
+	cosmosDBSQLAPIKind     string = "GlobalDocumentDB"
+	cosmosDBNamespace      string = "cosmosDB"
+	cosmosDBAPISetIDSuffix string = "/<data>"
+)
+
+type cosmosDBAccountResponse struct {
+	Kind       string `json:"kind"`
+	Properties struct {
+		DocumentEndpoint string `json:"documentEndpoint"`
+		Capabilities     []struct {
+			Name string `json:"name"`
+		} `json:"capabilities"`
+	} `json:"properties"`
+}
+
+type cosmosDBListKeysResponse struct {
+	PrimaryMasterKey string `json:"primaryMasterKey"`
+}
+
+// Check interface
+var _ Expander = &CosmosDBExpander{}
+
+// CosmosDBExpander expands the data-plane aspects of a Cosmos DB (SQL API) account
+type CosmosDBExpander struct {
+	ExpanderBase
+	client *armclient.Client
+}
+
+func (e *CosmosDBExpander) setClient(c *armclient.Client) {
+	e.client = c
+}
+
+// Name returns the name of the expander
+func (e *CosmosDBExpander) Name() string {
+	return "CosmosDBExpander"
+}
+
+// DoesExpand checks if this is a Cosmos DB account
+func (e *CosmosDBExpander) DoesExpand(ctx context.Context, currentItem *TreeNode) (bool, error) {
+	swaggerResourceType := currentItem.SwaggerResourceType
+	if currentItem.ItemType == ResourceType && swaggerResourceType != nil {
+		if swaggerResourceType.Endpoint.TemplateURL == cosmosDBTemplateURL {
+			return true, nil
+		}
+	}
+	if currentItem.Namespace == cosmosDBNamespace {
+		return true, nil
+	}
+	return false, nil
+}
+
+// Expand returns the data-plane nodes for the account
+func (e *CosmosDBExpander) Expand(ctx context.Context, currentItem *TreeNode) ExpanderResult {
+
+	swaggerResourceType := currentItem.SwaggerResourceType
+	if currentItem.Namespace != cosmosDBNamespace &&
+		swaggerResourceType != nil &&
+		swaggerResourceType.Endpoint.TemplateURL == cosmosDBTemplateURL {
+		newItems := []*TreeNode{}
+		newItems = append(newItems, &TreeNode{
+			ID:        currentItem.ID + cosmosDBAPISetIDSuffix,
+			Parentid:  currentItem.ID,
+			Namespace: cosmosDBNamespace,
+			Name:      "Data Explorer",
+			Display:   "Data Explorer",
+			ItemType:  SubResourceType,
+			ExpandURL: ExpandURLNotSupported,
+			Metadata: map[string]string{
+				"AccountID":             currentItem.ID,
+				"SuppressSwaggerExpand": "true",
+				"SuppressGenericExpand": "true",
+			},
+		})
+
+		return ExpanderResult{
+			Err:               nil,
+			Response:          ExpanderResponse{Response: ""}, // Swagger expander will supply the response
+			SourceDescription: "CosmosDBExpander request",
+			Nodes:             newItems,
+			IsPrimaryResponse: false,
+		}
+	}
+
+	if currentItem.Namespace == cosmosDBNamespace && currentItem.ItemType == SubResourceType {
+		return e.expandAccountRoot(ctx, currentItem)
+	}
+
+	return ExpanderResult{
+		Err:               fmt.Errorf("Error - unhandled Expand"),
+		Response:          ExpanderResponse{Response: "Error!"},
+		SourceDescription: "CosmosDBExpander request",
+	}
+}
+
+func (e *CosmosDBExpander) expandAccountRoot(ctx context.Context, currentItem *TreeNode) ExpanderResult {
+
+	accountID := currentItem.Metadata["AccountID"]
+
+	// Check for existing config for the account
+	swaggerAPISet := GetSwaggerResourceExpander().GetAPISet(accountID + cosmosDBAPISetIDSuffix)
+	var apiSet SwaggerAPISet
+	if swaggerAPISet != nil {
+		apiSet = *swaggerAPISet
+	} else {
+		cosmosAPISet, err := e.createAPISetForAccount(ctx, accountID)
+		if err != nil {
+			return ExpanderResult{
+				Err:               err,
+				Response:          ExpanderResponse{Response: err.Error()},
+				SourceDescription: "CosmosDBExpander request",
+			}
+		}
+		apiSet = *cosmosAPISet
+		GetSwaggerResourceExpander().AddAPISet(apiSet)
+	}
+
+	newItems := []*TreeNode{}
+	for _, child := range apiSet.GetResourceTypes() {
+		resourceType := child
+		display := resourceType.Display
+		newItems = append(newItems, &TreeNode{
+			Parentid:            currentItem.ID,
+			ID:                  currentItem.ID + "/" + display,
+			Namespace:           "swagger",
+			Name:                display,
+			Display:             display,
+			ExpandURL:           resourceType.Endpoint.TemplateURL, // all fixed template URLs
+			ItemType:            SubResourceType,
+			SwaggerResourceType: &resourceType,
+			Metadata: map[string]string{
+				"SwaggerAPISetID": apiSet.ID(),
+			},
+		})
+	}
+
+	return ExpanderResult{
+		Err:               nil,
+		Response:          ExpanderResponse{Response: ""},
+		SourceDescription: "CosmosDBExpander request",
+		Nodes:             newItems,
+		IsPrimaryResponse: true,
+	}
+}
+
+func (e *CosmosDBExpander) createAPISetForAccount(ctx context.Context, accountID string) (*SwaggerAPISetCosmosDB, error) {
+	data, err := e.client.DoRequest(ctx, "GET", accountID+"?api-version="+cosmosDBARMAPIVersion)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get account: " + err.Error() + accountID)
+	}
+
+	var account cosmosDBAccountResponse
+	err = json.Unmarshal([]byte(data), &account)
+	if err != nil {
+		return nil, fmt.Errorf("Error unmarshalling response: %s\nURL:%s", err, accountID)
+	}
+	if account.Kind != cosmosDBSQLAPIKind {
+		return nil, fmt.Errorf("Only SQL API accounts are supported (account kind is '%s')", account.Kind)
+	}
+	for _, capability := range account.Properties.Capabilities {
+		switch capability.Name {
+		case "EnableCassandra", "EnableGremlin", "EnableTable":
+			return nil, fmt.Errorf("Only SQL API accounts are supported (account has capability '%s')", capability.Name)
+		}
+	}
+	if account.Properties.DocumentEndpoint == "" {
+		return nil, fmt.Errorf("Document endpoint lookup failed")
+	}
+
+	data, err = e.client.DoRequest(ctx, "POST", accountID+"/listKeys?api-version="+cosmosDBARMAPIVersion)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get account keys: %s", err)
+	}
+	var keys cosmosDBListKeysResponse
+	err = json.Unmarshal([]byte(data), &keys)
+	if err != nil {
+		return nil, fmt.Errorf("Error unmarshalling keys: %s", err)
+	}
+	masterKey, err := base64.StdEncoding.DecodeString(keys.PrimaryMasterKey)
+	if err != nil {
+		return nil, fmt.Errorf("Error decoding account key: %s", err)
+	}
+
+	// Register the swagger config so that the swagger expander can take over
+	apiSet := NewSwaggerAPISetCosmosDB(e.loadResourceTypes(), accountID+cosmosDBAPISetIDSuffix, account.Properties.DocumentEndpoint, masterKey)
+	return &apiSet, nil
+}
+
+func (e *CosmosDBExpander) testCases() (bool, *[]expanderTestCase) {
+	const accountID = "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/stable/providers/Microsoft.DocumentDB/databaseAccounts/testcosmos"
+	const documentsURL = "/dbs/orders/colls/items/docs"
+
+	documentsGockConfig := func(t *testing.T) {
+		gock.New("https://management.azure.com").
+			Get(accountID).
+			Reply(200).
+			File("./testdata/armsamples/cosmosdb/account.json")
+		gock.New("https://management.azure.com").
+			Post(accountID + "/listKeys").
+			Reply(200).
+			JSON(`{"primaryMasterKey": "dGVzdGtleQ=="}`)
+		gock.New("https://testcosmos.documents.azure.com").
+			Get(documentsURL).
+			MatchHeader("Authorization", "^type%3Dmaster%26ver%3D1.0%26sig%3D").
+			MatchHeader("x-ms-version", cosmosDBAPIVersion).
+			MatchHeader("x-ms-max-item-count", "50").
+			Reply(200).
+			SetHeader("x-ms-continuation", "+RID:abc#RT:1").
+			File("./testdata/armsamples/cosmosdb/documents.json")
+	}
+
+	return true, &[]expanderTestCase{
+		{
+			name: "Account->Documents",
+			nodeToExpand: &TreeNode{
+				ID:        accountID + cosmosDBAPISetIDSuffix,
+				Namespace: cosmosDBNamespace,
+				ItemType:  SubResourceType,
+				ExpandURL: ExpandURLNotSupported,
+				Metadata:  map[string]string{"AccountID": accountID},
+			},
+			configureGockFunc: &documentsGockConfig,
+			treeNodeCheckerFunc: func(t *testing.T, r ExpanderResult) {
+				st.Expect(t, r.Err, nil)
+				st.Expect(t, len(r.Nodes), 1)
+				st.Expect(t, r.Nodes[0].Name, "databases")
+				st.Expect(t, r.Nodes[0].ExpandURL, "/dbs")
+				st.Expect(t, r.Nodes[0].Metadata["SwaggerAPISetID"], accountID+cosmosDBAPISetIDSuffix)
+
+				// Expand the documents in a container through the registered API set
+				databases := r.Nodes[0].SwaggerResourceType
+				containers := databases.SubResources[0].Children[0]
+				documents := containers.SubResources[0].Children[0]
+				documentsNode := &TreeNode{
+					ID:                  accountID + cosmosDBAPISetIDSuffix + documentsURL,
+					Namespace:           "swagger",
+					ItemType:            SubResourceType,
+					ExpandURL:           documentsURL,
+					SwaggerResourceType: &documents,
+					Metadata: map[string]string{
+						"SwaggerAPISetID":   accountID + cosmosDBAPISetIDSuffix,
+						"PartitionKeyPaths": `["/customer/id"]`,
+					},
+				}
+				result := GetSwaggerResourceExpander().Expand(context.Background(), documentsNode)
+				st.Expect(t, result.Err, nil)
+				st.Expect(t, len(result.Nodes), 3)
+
+				st.Expect(t, result.Nodes[0].Name, "order 1")
+				st.Expect(t, result.Nodes[0].ExpandURL, documentsURL+"/order%201")
+				st.Expect(t, result.Nodes[0].Metadata["PartitionKey"], `["customer-1"]`)
+				st.Expect(t, result.Nodes[0].SwaggerResourceType.PutEndpoint != nil, true)
+
+				st.Expect(t, result.Nodes[1].Name, "order-2")
+				st.Expect(t, result.Nodes[1].Metadata["PartitionKey"], `[{}]`)
+
+				st.Expect(t, result.Nodes[2].Name, "more...")
+				st.Expect(t, result.Nodes[2].Metadata["Continuation"], "+RID:abc#RT:1")
+				st.Expect(t, result.Nodes[2].SwaggerResourceType.Endpoint.TemplateURL, cosmosDBDocumentsTemplateURL)
+			},
+		},
+	}
+}
@@ -0,0 +1,186 @@
+package expanders
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+
+	"github.com/lawrencegripper/azbrowse/internal/pkg/style"
+	"github.com/lawrencegripper/azbrowse/pkg/endpoints"
+	"github.com/lawrencegripper/azbrowse/pkg/swagger"
+)
+
+const appConfigurationKeyValueContentType = "application/vnd.microsoft.appconfig.kv+json"
+
+type appConfigurationKeyValue struct {
+	Key         string            `json:"key"`
+	Label       *string           `json:"label"`
+	Value       *string           `json:"value"`
+	ContentType *string           `json:"content_type"`
+	Tags        map[string]string `json:"tags"`
+	Locked      bool              `json:"locked"`
+}
+
+type appConfigurationKeyValueList struct {
+	Items    []appConfigurationKeyValue `json:"items"`
+	NextLink string                     `json:"@nextLink"`
+}
+
+// appConfigurationDataPlaneHandler handles the App Configuration data-plane. Key-values are listed in "items"
+// and identified by their key and label, so the label is carried on the URL and in the metadata for the node
+type appConfigurationDataPlaneHandler struct {
+	defaultDataPlaneAPIHandler
+}
+
+// ExpandResource returns metadata about child resources of the specified resource node
+func (h appConfigurationDataPlaneHandler) ExpandResource(ctx context.Context, c SwaggerAPISetDataPlane, currentItem *TreeNode, resourceType swagger.ResourceType) (APISetExpandResponse, error) {
+	data, err := c.DoRequestWithBody(ctx, "GET", currentItem.ExpandURL, "")
+	if err != nil {
+		return APISetExpandResponse{}, fmt.Errorf("Failed to make request: %s", err)
+	}
+
+	if len(resourceType.SubResources) == 0 {
+		return APISetExpandResponse{
+			Response:     data,
+			ResponseType: ResponseJSON,
+		}, nil
+	}
+	if len(resourceType.SubResources) > 1 {
+		return APISetExpandResponse{}, fmt.Errorf("Only expecting a single SubResource type")
+	}
+
+	// The only list with sub resources is /kv, a key can have a value for each label so the label is
+	// carried on the URL and in the metadata for the sub resource
+	subResourceType := resourceType.SubResources[0]
+	subResources := []SubResource{}
+	items := []appConfigurationKeyValue{}
+	nextData := data
+	for nextData != "" {
+		var list appConfigurationKeyValueList
+		err = json.Unmarshal([]byte(nextData), &list)
+		if err != nil {
+			return APISetExpandResponse{Response: data}, fmt.Errorf("Error parsing response: %s", err)
+		}
+		items = append(items, list.Items...)
+
+		for _, item := range list.Items {
+			label := ""
+			if item.Label != nil {
+				label = *item.Label
+			}
+			subResourceURL, err := h.buildKeyValueURL(subResourceType.Endpoint, item.Key, label)
+			if err != nil {
+				return APISetExpandResponse{}, fmt.Errorf("Error building subresource URL: %s", err)
+			}
+			deleteURL, err := h.buildKeyValueURL(subResourceType.DeleteEndpoint, item.Key, label)
+			if err != nil {
+				return APISetExpandResponse{}, fmt.Errorf("Error building subresource delete url: %s", err)
+			}
+
+			display := item.Key
+			if label != "" {
+				display += " " + style.Subtle("["+label+"]")
+			}
+			if item.Locked {
+				display += " " + style.Subtle("(locked)")
+			}
+			subResources = append(subResources, SubResource{
+				ID:           c.id + subResourceURL,
+				Name:         item.Key,
+				Display:      display,
+				ResourceType: subResourceType,
+				ExpandURL:    subResourceURL,
+				DeleteURL:    deleteURL,
+				Metadata: map[string]string{
+					"Label": label,
+				},
+			})
+		}
+
+		nextData = ""
+		if list.NextLink != "" {
+			nextData, err = c.DoRequestWithBody(ctx, "GET", list.NextLink, "")
+			if err != nil {
+				return APISetExpandResponse{}, fmt.Errorf("Failed to make request: %s", err)
+			}
+		}
+	}
+
+	// Return all pages in the response rather than just the first
+	buf, err := json.Marshal(items)
+	if err != nil {
+		return APISetExpandResponse{}, err
+	}
+	return APISetExpandResponse{
+		Response:     string(buf),
+		ResponseType: ResponseJSON,
+		SubResources: subResources,
+	}, nil
+}
+
+// buildKeyValueURL builds the URL for a key-value from the endpoint for the operation. Keys commonly
+// contain '/' so are escaped, and the label is only added when set as no label means the null label
+func (h appConfigurationDataPlaneHandler) buildKeyValueURL(endpoint *endpoints.EndpointInfo, key string, label string) (string, error) {
+	result, err := endpoint.BuildURL(map[string]string{"key": url.PathEscape(key)})
+	if err != nil {
+		return "", err
+	}
+	if label != "" {
+		result += "&label=" + url.QueryEscape(label)
+	}
+	return result, nil
+}
+
+// getKeyValueURL returns the URL for the operation on the key-value for the item
+func (h appConfigurationDataPlaneHandler) getKeyValueURL(item *TreeNode, endpoint *endpoints.EndpointInfo) (string, error) {
+	matchResult := item.SwaggerResourceType.Endpoint.Match(item.ExpandURL)
+	if !matchResult.IsMatch {
+		return "", fmt.Errorf("item.ExpandURL didn't match current Endpoint")
+	}
+	key, err := url.PathUnescape(matchResult.Values["key"])
+	if err != nil {
+		return "", fmt.Errorf("Error unescaping key: %s", err)
+	}
+	return h.buildKeyValueURL(endpoint, key, item.Metadata["Label"])
+}
+
+// Update sets the value, content type and tags of the key-value from the edited content.
+// The key and label identify the key-value so changes to them are ignored
+func (h appConfigurationDataPlaneHandler) Update(ctx context.Context, c SwaggerAPISetDataPlane, item *TreeNode, content string) error {
+	if item.SwaggerResourceType.PutEndpoint == nil {
+		return fmt.Errorf("Item cannot be updated (No PutEndpoint)")
+	}
+
+	var keyValue appConfigurationKeyValue
+	err := json.Unmarshal([]byte(content), &keyValue)
+	if err != nil {
+		return fmt.Errorf("Error parsing key-value: %s", err)
+	}
+	body, err := json.Marshal(struct {
+		Value       *string           `json:"value"`
+		ContentType *string           `json:"content_type,omitempty"`
+		Tags        map[string]string `json:"tags,omitempty"`
+	}{
+		Value:       keyValue.Value,
+		ContentType: keyValue.ContentType,
+		Tags:        keyValue.Tags,
+	})
+	if err != nil {
+		return fmt.Errorf("Error marshalling key-value: %s", err)
+	}
+
+	url, err := h.getKeyValueURL(item, item.SwaggerResourceType.PutEndpoint)
+	if err != nil {
+		return fmt.Errorf("Error building PUT url: %s", err)
+	}
+
+	headers := map[string]string{
+		"Content-Type": appConfigurationKeyValueContentType,
+	}
+	_, err = c.DoRequestWithBodyAndHeaders(ctx, "PUT", url, string(body), headers)
+	if err != nil {
+		return fmt.Errorf("Error from PUT: %s", err)
+	}
+	return nil
+}
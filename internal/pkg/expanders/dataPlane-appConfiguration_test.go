@@ -0,0 +1,99 @@
+package expanders
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/lawrencegripper/azbrowse/internal/pkg/style"
+	"github.com/nbio/st"
+	"gopkg.in/h2non/gock.v1"
+)
+
+const (
+	testAppConfigurationEndpoint   = "https://testconfig.azconfig.io"
+	testAppConfigurationCredential = "Abcd-l0-s0:7GAnfZ6hTphVVoSdMgTp"
+	testAppConfigurationSecret     = "c2VjcmV0LXZhbHVlLWZvci10ZXN0cw=="
+)
+
+func newTestAppConfigurationAPISet(t *testing.T) SwaggerAPISetDataPlane {
+	expander := getTestDataPlaneExpander(t, nil, "AppConfiguration")
+	return NewSwaggerAPISetDataPlane(expander.loadResourceTypes(), nil, http.Client{},
+		"/subscriptions/1/resourceGroups/test/providers/Microsoft.AppConfiguration/configurationStores/testconfig/<dataplane>",
+		testAppConfigurationEndpoint, expander.config, testAppConfigurationCredential, testAppConfigurationSecret)
+}
+
+func Test_DataPlane_AppConfigurationSignRequest(t *testing.T) {
+	apiSet := newTestAppConfigurationAPISet(t)
+	request, err := http.NewRequest("GET", testAppConfigurationEndpoint+"/kv?api-version=1.0", nil)
+	st.Expect(t, err, nil)
+
+	err = apiSet.signRequest(request, []byte{}, time.Date(2020, 5, 1, 10, 0, 0, 0, time.UTC))
+	st.Expect(t, err, nil)
+	st.Expect(t, request.Header.Get("x-ms-date"), "Fri, 01 May 2020 10:00:00 GMT")
+	st.Expect(t, request.Header.Get("x-ms-content-sha256"), "47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU=")
+	st.Expect(t, request.Header.Get("Authorization"), "HMAC-SHA256 Credential="+testAppConfigurationCredential+
+		"&SignedHeaders=x-ms-date;host;x-ms-content-sha256&Signature=uTYaFH6x2vAtrqTsxB0vgz0sVJl3nE9reHqlDQMCUiQ=")
+}
+
+func Test_DataPlane_AppConfigurationExpandKeyValues(t *testing.T) {
+	defer gock.Off()
+	gock.New(testAppConfigurationEndpoint).
+		Get("/kv").
+		MatchParam("api-version", "1.0").
+		MatchHeader("Authorization", "^HMAC-SHA256 Credential="+testAppConfigurationCredential+"&").
+		Reply(200).
+		File("./testdata/armsamples/appconfiguration/kv.json")
+
+	apiSet := newTestAppConfigurationAPISet(t)
+	resourceType := apiSet.GetResourceTypes()[1]
+	node := &TreeNode{
+		ExpandURL:           "/kv?api-version=1.0",
+		SwaggerResourceType: &resourceType,
+	}
+	result, err := apiSet.ExpandResource(context.Background(), node, resourceType)
+	st.Expect(t, err, nil)
+	st.Expect(t, len(result.SubResources), 3)
+
+	st.Expect(t, result.SubResources[0].Display, "Settings:BackgroundColor")
+	st.Expect(t, result.SubResources[0].ExpandURL, "/kv/Settings:BackgroundColor?api-version=1.0")
+	st.Expect(t, result.SubResources[1].Display, "Settings:BackgroundColor "+style.Subtle("[production]")+" "+style.Subtle("(locked)"))
+	st.Expect(t, result.SubResources[1].ExpandURL, "/kv/Settings:BackgroundColor?api-version=1.0&label=production")
+	st.Expect(t, result.SubResources[2].Name, "feature/beta")
+	st.Expect(t, result.SubResources[2].ExpandURL, "/kv/feature%2Fbeta?api-version=1.0&label=test+env")
+	st.Expect(t, result.SubResources[2].DeleteURL, "/kv/feature%2Fbeta?api-version=1.0&label=test+env")
+	st.Expect(t, result.SubResources[2].Metadata["Label"], "test env")
+	st.Expect(t, gock.IsDone(), true)
+}
+
+func Test_DataPlane_AppConfigurationUpdateKeyValue(t *testing.T) {
+	defer gock.Off()
+	gock.New(testAppConfigurationEndpoint).
+		Put("/kv/feature").
+		MatchParam("label", "^test env$").
+		AddMatcher(matchPatchBody(appConfigurationKeyValueContentType, `{"value":"{\"enabled\":false}","content_type":"application/json","tags":{"owner":"web"}}`)).
+		Reply(200)
+
+	apiSet := newTestAppConfigurationAPISet(t)
+	resourceType := apiSet.GetResourceTypes()[1].SubResources[0]
+	node := &TreeNode{
+		ExpandURL:           "/kv/feature%2Fbeta?api-version=1.0&label=test+env",
+		SwaggerResourceType: &resourceType,
+		Metadata: map[string]string{
+			"Label": "test env",
+		},
+	}
+	content := `{
+		"etag": "c3c231fd39a54fbd9d1f79b2f1b5e6a7",
+		"key": "feature/beta",
+		"label": "test env",
+		"content_type": "application/json",
+		"value": "{\"enabled\":false}",
+		"tags": {"owner": "web"},
+		"locked": false
+	}`
+	err := apiSet.Update(context.Background(), node, content)
+	st.Expect(t, err, nil)
+	st.Expect(t, gock.IsDone(), true)
+}
@@ -0,0 +1,258 @@
+package expanders
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/lawrencegripper/azbrowse/pkg/swagger"
+)
+
+const (
+	azureSearchIndexTemplateURL = "/indexes('{indexName}')"
+	azureSearchDocsTemplateURL  = "/indexes('{indexName}')/docs"
+	azureSearchDocTemplateURL   = "/indexes('{indexName}')/docs('{key}')"
+)
+
+type searchIndexResponse struct {
+	Fields []struct {
+		Name string `json:"name"`
+		Key  bool   `json:"key"`
+	} `json:"fields"`
+}
+
+type searchIndexDocumentList struct {
+	Value []map[string]interface{}
+}
+
+// azureSearchDataPlaneHandler handles the Azure Search data-plane. Documents are named by the key field of their
+// index, which is found when the index is expanded and passed down in the IndexKey metadata. Documents are updated
+// and deleted by POSTing a batch to the docs/index endpoint (see the overrides in dataplane-apisets.json)
+type azureSearchDataPlaneHandler struct {
+	defaultDataPlaneAPIHandler
+}
+
+// ExpandResource returns metadata about child resources of the specified resource node
+func (h azureSearchDataPlaneHandler) ExpandResource(ctx context.Context, c SwaggerAPISetDataPlane, currentItem *TreeNode, resourceType swagger.ResourceType) (APISetExpandResponse, error) {
+
+	subResources := []SubResource{}
+	data, err := c.DoRequestWithBody(ctx, "GET", currentItem.ExpandURL, "")
+	if err != nil {
+		err = fmt.Errorf("Failed to make request: %s", err)
+		return APISetExpandResponse{}, err
+	}
+
+	currentItemTemplateURL := currentItem.SwaggerResourceType.Endpoint.TemplateURL
+
+	indexKey := ""
+	if currentItemTemplateURL == azureSearchIndexTemplateURL {
+		indexKey, err = h.getIndexKey(data)
+		if err != nil {
+			return APISetExpandResponse{Response: data}, err
+		}
+	} else {
+		// propagate indexKey if set in metadata
+		indexKey = currentItem.Metadata["IndexKey"]
+	}
+
+	// expand if we have subresources. Also don't expand Docs as they are user-defined format
+	if len(resourceType.SubResources) > 0 {
+		if len(resourceType.SubResources) > 1 {
+			return APISetExpandResponse{}, fmt.Errorf("Only expecting a single SubResource type")
+		}
+
+		matchResult := resourceType.Endpoint.Match(currentItem.ExpandURL)
+		templateValues := matchResult.Values
+		subResourceType := resourceType.SubResources[0]
+
+		subResourceEndpoint := subResourceType.Endpoint
+		newURLSegment := subResourceEndpoint.URLSegments[len(subResourceEndpoint.URLSegments)-1]
+		newTemplateName := newURLSegment.Name
+
+		var extraIDs []string
+		if currentItemTemplateURL == azureSearchDocsTemplateURL {
+			extraIDs, err = h.getKeys(data, indexKey)
+		} else {
+			extraIDs, err = h.getNames(ctx, c, data)
+		}
+		if err != nil {
+			return APISetExpandResponse{Response: data}, err
+		}
+
+		for _, item := range extraIDs {
+
+			templateValues[newTemplateName] = item
+			subResourceURL, err := subResourceType.Endpoint.BuildURL(templateValues)
+			if err != nil {
+				return APISetExpandResponse{}, fmt.Errorf("Error building subresource URL: %s", err)
+			}
+
+			deleteURL := ""
+			if subResourceType.DeleteEndpoint != nil {
+				subResourceTemplateValues := subResourceType.Endpoint.Match(subResourceURL).Values
+				deleteURL, err = subResourceType.DeleteEndpoint.BuildURL(subResourceTemplateValues)
+				if err != nil {
+					err = fmt.Errorf("Error building subresource delete url '%s': %s", subResourceType.DeleteEndpoint.TemplateURL, err)
+					return APISetExpandResponse{Response: data}, err
+				}
+			}
+			subResource := SubResource{
+				ID:           c.id + subResourceURL,
+				Name:         item,
+				ResourceType: subResourceType,
+				ExpandURL:    subResourceURL,
+				DeleteURL:    deleteURL,
+				Metadata: map[string]string{
+					"IndexKey": indexKey,
+				},
+			}
+			subResources = append(subResources, subResource)
+		}
+	}
+
+	return APISetExpandResponse{
+		Response:     data,
+		ResponseType: ResponseJSON,
+		SubResources: subResources,
+		ChildMetadata: map[string]string{
+			"IndexKey": indexKey,
+		},
+	}, nil
+}
+
+func (h azureSearchDataPlaneHandler) getIndexKey(response string) (string, error) {
+
+	var indexResponse searchIndexResponse
+	err := json.Unmarshal([]byte(response), &indexResponse)
+	if err != nil {
+		err = fmt.Errorf("Error parsing index response: %s", err)
+		return "", err
+	}
+
+	for _, field := range indexResponse.Fields {
+		if field.Key {
+			return field.Name, nil
+		}
+	}
+
+	return "", fmt.Errorf("No key field found in index")
+}
+
+func (h azureSearchDataPlaneHandler) getKeys(response string, keyName string) ([]string, error) {
+
+	var listResponse searchIndexDocumentList
+	err := json.Unmarshal([]byte(response), &listResponse)
+	if err != nil {
+		err = fmt.Errorf("Error parsing response: %s", err)
+		return []string{}, err
+	}
+
+	keys := []string{}
+	for _, item := range listResponse.Value {
+		key, ok := item[keyName].(string)
+		if !ok {
+			return []string{}, fmt.Errorf("Document has no value for the key field %q", keyName)
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+// Delete attempts to delete the item. Returns true if deleted, false if not handled, an error if an error occurred attempting to delete
+func (h azureSearchDataPlaneHandler) Delete(ctx context.Context, c SwaggerAPISetDataPlane, item *TreeNode) (bool, error) {
+	if item.DeleteURL == "" {
+		return false, fmt.Errorf("Item cannot be deleted (No DeleteURL)")
+	}
+
+	if item.SwaggerResourceType.Endpoint.TemplateURL == azureSearchDocTemplateURL {
+		return h.deleteDoc(ctx, c, item)
+	}
+	return h.defaultDataPlaneAPIHandler.Delete(ctx, c, item)
+}
+
+func (h azureSearchDataPlaneHandler) deleteDoc(ctx context.Context, c SwaggerAPISetDataPlane, item *TreeNode) (bool, error) {
+	matchResult := item.SwaggerResourceType.Endpoint.Match(item.ExpandURL)
+	if !matchResult.IsMatch {
+		return false, fmt.Errorf("item.ExpandURL didn't match current Endpoint")
+	}
+
+	keyName := item.Metadata["IndexKey"]
+	key := matchResult.Values["key"]
+
+	doc := map[string]interface{}{
+		"@search.action": "delete",
+		keyName:          key,
+	}
+
+	var deleteBody struct {
+		Value []map[string]interface{} `json:"value"`
+	}
+
+	deleteBody.Value = []map[string]interface{}{doc}
+	deleteBodyBytes, err := json.Marshal(deleteBody)
+	if err != nil {
+		return false, fmt.Errorf("Error marshalling delete doc: %s", err) //nolint:misspell
+	}
+
+	url, err := item.SwaggerResourceType.DeleteEndpoint.BuildURL(matchResult.Values)
+	if err != nil {
+		return false, fmt.Errorf("Error building DELETE url: %s", err)
+	}
+
+	_, err = c.DoRequestWithBody(ctx, "POST", url, string(deleteBodyBytes))
+	if err != nil {
+		return false, fmt.Errorf("Error from POST: %s", err)
+	}
+	return true, nil
+}
+
+// Update attempts to update the specified item with new content
+func (h azureSearchDataPlaneHandler) Update(ctx context.Context, c SwaggerAPISetDataPlane, item *TreeNode, content string) error {
+	if item.SwaggerResourceType.Endpoint.TemplateURL != azureSearchDocTemplateURL {
+		return h.defaultDataPlaneAPIHandler.Update(ctx, c, item, content)
+	}
+
+	body, err := h.getBodyForDocUpdate(content)
+	if err != nil {
+		return fmt.Errorf("Error packaging doc update: %s", err)
+	}
+
+	matchResult := item.SwaggerResourceType.Endpoint.Match(item.ExpandURL)
+	if !matchResult.IsMatch {
+		return fmt.Errorf("item.ExpandURL didn't match current Endpoint")
+	}
+
+	url, err := item.SwaggerResourceType.PutEndpoint.BuildURL(matchResult.Values)
+	if err != nil {
+		return fmt.Errorf("Error building PUT url: %s", err)
+	}
+
+	_, err = c.DoRequestWithBody(ctx, "POST", url, body)
+	if err != nil {
+		return fmt.Errorf("Error from POST: %s", err)
+	}
+	return nil
+}
+
+func (h azureSearchDataPlaneHandler) getBodyForDocUpdate(content string) (string, error) {
+	var doc map[string]interface{}
+	err := json.Unmarshal([]byte(content), &doc)
+	if err != nil {
+		err = fmt.Errorf("Error parsing doc: %s", err)
+		return "", err
+	}
+
+	doc["@search.action"] = "upload"
+
+	var updateBody struct {
+		Value []map[string]interface{} `json:"value"`
+	}
+	updateBody.Value = []map[string]interface{}{doc}
+	updateBodyBytes, err := json.Marshal(updateBody)
+	if err != nil {
+		return "", fmt.Errorf("Error marshalling update doc: %s", err) //nolint:misspell
+	}
+
+	return string(updateBodyBytes), nil
+}
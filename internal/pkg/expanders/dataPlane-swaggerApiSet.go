@@ -0,0 +1,350 @@
+package expanders
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/lawrencegripper/azbrowse/pkg/armclient"
+	"github.com/lawrencegripper/azbrowse/pkg/swagger"
+)
+
+type dataPlaneListResponse struct {
+	Value    []map[string]interface{} `json:"value"`
+	NextLink string                   `json:"nextLink"`
+}
+
+// dataPlaneAPIHandler handles expanding, deleting and updating items for a SwaggerAPISetDataPlane. APIs that don't
+// follow the conventions handled by defaultDataPlaneAPIHandler (e.g. Azure Search documents are named by the key
+// field of the index) register a handler in dataPlaneAPIHandlers under the name of their swagger.DataPlaneConfig
+type dataPlaneAPIHandler interface {
+	ExpandResource(ctx context.Context, c SwaggerAPISetDataPlane, currentItem *TreeNode, resourceType swagger.ResourceType) (APISetExpandResponse, error)
+	Delete(ctx context.Context, c SwaggerAPISetDataPlane, item *TreeNode) (bool, error)
+	Update(ctx context.Context, c SwaggerAPISetDataPlane, item *TreeNode, content string) error
+}
+
+var dataPlaneAPIHandlers = map[string]dataPlaneAPIHandler{
+	"AzureSearch":      azureSearchDataPlaneHandler{},
+	"AppConfiguration": appConfigurationDataPlaneHandler{},
+}
+
+var _ SwaggerAPISet = SwaggerAPISetDataPlane{}
+
+// SwaggerAPISetDataPlane holds the config for working with a data-plane API generated from a swagger.DataPlaneConfig
+type SwaggerAPISetDataPlane struct {
+	resourceTypes []swagger.ResourceType
+	armClient     *armclient.Client
+	httpClient    http.Client
+	id            string // ARM resource ID for the resource hosting the data-plane + "/<dataplane>"
+	endpoint      string // base URL for the data-plane, e.g. https://<name>.search.windows.net
+	name          string // name of the swagger.DataPlaneConfig, used to look up the dataPlaneAPIHandler
+	auth          swagger.DataPlaneAuth
+	credential    string // the key ID for hmac auth
+	key           string // the key for listKeys and hmac auth (base64 encoded for hmac)
+}
+
+// NewSwaggerAPISetDataPlane creates a new SwaggerAPISetDataPlane
+func NewSwaggerAPISetDataPlane(resourceTypes []swagger.ResourceType, armClient *armclient.Client, httpClient http.Client, id string, endpoint string, config swagger.DataPlaneConfig, credential string, key string) SwaggerAPISetDataPlane {
+	c := SwaggerAPISetDataPlane{}
+	c.resourceTypes = resourceTypes
+	c.armClient = armClient
+	c.httpClient = httpClient
+	c.id = id
+	c.endpoint = endpoint
+	c.name = config.Name
+	c.auth = config.Auth
+	c.credential = credential
+	c.key = key
+	return c
+}
+
+func (c SwaggerAPISetDataPlane) handler() dataPlaneAPIHandler {
+	if handler, ok := dataPlaneAPIHandlers[c.name]; ok {
+		return handler
+	}
+	return defaultDataPlaneAPIHandler{}
+}
+
+// ID returns the ID for the APISet
+func (c SwaggerAPISetDataPlane) ID() string {
+	return c.id
+}
+
+// MatchChildNodesByName indicates whether child nodes should be matched by name (or position)
+func (c SwaggerAPISetDataPlane) MatchChildNodesByName() bool {
+	return true
+}
+
+// AppliesToNode is called by the Swagger exapnder to test whether the node applies to this APISet
+func (c SwaggerAPISetDataPlane) AppliesToNode(node *TreeNode) bool {
+	// this function is only called for nodes that don't have the SwaggerAPISetID set
+	// this should never happen for data-plane nodes
+	return false
+}
+
+// GetResourceTypes returns the ResourceTypes for the API Set
+func (c SwaggerAPISetDataPlane) GetResourceTypes() []swagger.ResourceType {
+	return c.resourceTypes
+}
+
+// DoRequestWithBody makes a request against the data-plane endpoint, sending any body as JSON
+func (c SwaggerAPISetDataPlane) DoRequestWithBody(ctx context.Context, verb string, url string, body string) (string, error) {
+	headers := map[string]string{}
+	if body != "" {
+		headers["Content-Type"] = "application/json"
+	}
+	return c.DoRequestWithBodyAndHeaders(ctx, verb, url, body, headers)
+}
+
+// DoRequestWithBodyAndHeaders makes a request against the data-plane endpoint
+func (c SwaggerAPISetDataPlane) DoRequestWithBodyAndHeaders(ctx context.Context, verb string, url string, body string, headers map[string]string) (string, error) {
+	if !strings.HasPrefix(url, "https://") {
+		url = c.endpoint + url
+	}
+
+	response, err := c.doRequestWithAuth(ctx, verb, url, body, headers, false)
+	if err == nil && response.StatusCode == http.StatusUnauthorized && c.auth.Type == swagger.DataPlaneAuthAAD {
+		// The cached token may have expired so retry with a fresh token
+		response.Body.Close() //nolint: errcheck
+		response, err = c.doRequestWithAuth(ctx, verb, url, body, headers, true)
+	}
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close() //nolint: errcheck
+	buf, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		err = fmt.Errorf("Failed to read body: %s", err)
+		return "", err
+	}
+	data := string(buf)
+	if 200 <= response.StatusCode && response.StatusCode < 300 {
+		return data, nil
+	}
+	return "", fmt.Errorf("Response failed with %s (%s): %s", response.Status, url, data)
+}
+
+func (c SwaggerAPISetDataPlane) doRequestWithAuth(ctx context.Context, verb string, url string, body string, headers map[string]string, refreshToken bool) (*http.Response, error) {
+	request, err := http.NewRequest(verb, url, bytes.NewReader([]byte(body)))
+	if err != nil {
+		err = fmt.Errorf("Failed to create request" + err.Error() + url)
+		return nil, err
+	}
+	request = request.WithContext(ctx)
+	for name, value := range headers {
+		request.Header.Set(name, value)
+	}
+
+	switch c.auth.Type {
+	case swagger.DataPlaneAuthAAD:
+		var token armclient.AzCLIToken
+		if refreshToken {
+			token, err = c.armClient.RefreshTokenForResource(ctx, c.auth.Audience)
+		} else {
+			token, err = c.armClient.GetTokenForResource(ctx, c.auth.Audience)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Failed to get token for %s: %s", c.auth.Audience, err)
+		}
+		request.Header.Set("Authorization", token.TokenType+" "+token.AccessToken)
+	case swagger.DataPlaneAuthListKeys:
+		request.Header.Set(c.auth.Header, c.key)
+	case swagger.DataPlaneAuthHMAC:
+		err = c.signRequest(request, []byte(body), time.Now())
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("Unsupported data-plane auth type %q", c.auth.Type)
+	}
+
+	response, err := c.httpClient.Do(request)
+	if err != nil {
+		err = fmt.Errorf("Failed" + err.Error() + url)
+		return nil, err
+	}
+	return response, nil
+}
+
+// signRequest adds the HMAC-SHA256 authentication headers for the key, see
+// https://docs.microsoft.com/en-us/azure/azure-app-configuration/rest-api-authentication-hmac
+func (c SwaggerAPISetDataPlane) signRequest(request *http.Request, body []byte, now time.Time) error {
+	secret, err := base64.StdEncoding.DecodeString(c.key)
+	if err != nil {
+		return fmt.Errorf("Error decoding access key: %s", err)
+	}
+
+	date := now.UTC().Format(http.TimeFormat)
+	contentHash := sha256.Sum256(body)
+	encodedContentHash := base64.StdEncoding.EncodeToString(contentHash[:])
+	stringToSign := strings.ToUpper(request.Method) + "\n" +
+		request.URL.RequestURI() + "\n" +
+		date + ";" + request.URL.Host + ";" + encodedContentHash
+
+	mac := hmac.New(sha256.New, secret)
+	_, _ = mac.Write([]byte(stringToSign))
+	signature := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+
+	request.Header.Set("x-ms-date", date)
+	request.Header.Set("x-ms-content-sha256", encodedContentHash)
+	request.Header.Set("Authorization", "HMAC-SHA256 Credential="+c.credential+"&SignedHeaders=x-ms-date;host;x-ms-content-sha256&Signature="+signature)
+	return nil
+}
+
+// ExpandResource returns metadata about child resources of the specified resource node
+func (c SwaggerAPISetDataPlane) ExpandResource(ctx context.Context, currentItem *TreeNode, resourceType swagger.ResourceType) (APISetExpandResponse, error) {
+	return c.handler().ExpandResource(ctx, c, currentItem, resourceType)
+}
+
+// Delete attempts to delete the item. Returns true if deleted, false if not handled, an error if an error occurred attempting to delete
+func (c SwaggerAPISetDataPlane) Delete(ctx context.Context, item *TreeNode) (bool, error) {
+	return c.handler().Delete(ctx, c, item)
+}
+
+// Update attempts to update the specified item with new content
+func (c SwaggerAPISetDataPlane) Update(ctx context.Context, item *TreeNode, content string) error {
+	return c.handler().Update(ctx, c, item, content)
+}
+
+// defaultDataPlaneAPIHandler handles APIs that list items in a "value" array (identified by name or id and
+// paged with nextLink) and are updated and deleted with PUT and DELETE requests to the item
+type defaultDataPlaneAPIHandler struct{}
+
+// ExpandResource lists the names of the sub resources in the response
+func (h defaultDataPlaneAPIHandler) ExpandResource(ctx context.Context, c SwaggerAPISetDataPlane, currentItem *TreeNode, resourceType swagger.ResourceType) (APISetExpandResponse, error) {
+
+	data, err := c.DoRequestWithBody(ctx, "GET", currentItem.ExpandURL, "")
+	if err != nil {
+		err = fmt.Errorf("Failed to make request: %s", err)
+		return APISetExpandResponse{}, err
+	}
+
+	subResources := []SubResource{}
+	if len(resourceType.SubResources) > 0 {
+		if len(resourceType.SubResources) > 1 {
+			return APISetExpandResponse{}, fmt.Errorf("Only expecting a single SubResource type")
+		}
+
+		matchResult := resourceType.Endpoint.Match(currentItem.ExpandURL)
+		templateValues := matchResult.Values
+		subResourceType := resourceType.SubResources[0]
+
+		subResourceEndpoint := subResourceType.Endpoint
+		newURLSegment := subResourceEndpoint.URLSegments[len(subResourceEndpoint.URLSegments)-1]
+		newTemplateName := newURLSegment.Name
+
+		names, err := h.getNames(ctx, c, data)
+		if err != nil {
+			return APISetExpandResponse{Response: data}, err
+		}
+
+		for _, name := range names {
+			templateValues[newTemplateName] = name
+			subResourceURL, err := subResourceType.Endpoint.BuildURL(templateValues)
+			if err != nil {
+				return APISetExpandResponse{}, fmt.Errorf("Error building subresource URL: %s", err)
+			}
+
+			deleteURL := ""
+			if subResourceType.DeleteEndpoint != nil {
+				subResourceTemplateValues := subResourceType.Endpoint.Match(subResourceURL).Values
+				deleteURL, err = subResourceType.DeleteEndpoint.BuildURL(subResourceTemplateValues)
+				if err != nil {
+					err = fmt.Errorf("Error building subresource delete url '%s': %s", subResourceType.DeleteEndpoint.TemplateURL, err)
+					return APISetExpandResponse{Response: data}, err
+				}
+			}
+			subResources = append(subResources, SubResource{
+				ID:           c.id + subResourceURL,
+				Name:         name,
+				ResourceType: subResourceType,
+				ExpandURL:    subResourceURL,
+				DeleteURL:    deleteURL,
+			})
+		}
+	}
+
+	return APISetExpandResponse{
+		Response:     data,
+		ResponseType: ResponseJSON,
+		SubResources: subResources,
+	}, nil
+}
+
+// getNames returns the name (or last segment of the id) of each item in a list response, following nextLink to get all pages
+func (h defaultDataPlaneAPIHandler) getNames(ctx context.Context, c SwaggerAPISetDataPlane, response string) ([]string, error) {
+	names := []string{}
+	for {
+		var listResponse dataPlaneListResponse
+		err := json.Unmarshal([]byte(response), &listResponse)
+		if err != nil {
+			err = fmt.Errorf("Error parsing response: %s", err)
+			return []string{}, err
+		}
+
+		for _, item := range listResponse.Value {
+			name, _ := item["name"].(string)
+			if name == "" {
+				id, _ := item["id"].(string)
+				name = id[strings.LastIndex(id, "/")+1:]
+			}
+			if name == "" {
+				return []string{}, fmt.Errorf("List item has no name or id")
+			}
+			names = append(names, name)
+		}
+
+		if listResponse.NextLink == "" {
+			return names, nil
+		}
+		response, err = c.DoRequestWithBody(ctx, "GET", listResponse.NextLink, "")
+		if err != nil {
+			return []string{}, fmt.Errorf("Failed to get next page: %s", err)
+		}
+	}
+}
+
+// Delete sends a DELETE request to the DeleteURL for the item
+func (h defaultDataPlaneAPIHandler) Delete(ctx context.Context, c SwaggerAPISetDataPlane, item *TreeNode) (bool, error) {
+	if item.DeleteURL == "" {
+		return false, fmt.Errorf("Item cannot be deleted (No DeleteURL)")
+	}
+
+	_, err := c.DoRequestWithBody(ctx, "DELETE", item.DeleteURL, "")
+	if err != nil {
+		err = fmt.Errorf("Failed to delete: %s (%s)", err.Error(), item.DeleteURL)
+		return false, err
+	}
+	return true, nil
+}
+
+// Update sends a PUT request with the content to the PUT endpoint for the item
+func (h defaultDataPlaneAPIHandler) Update(ctx context.Context, c SwaggerAPISetDataPlane, item *TreeNode, content string) error {
+	if item.SwaggerResourceType.PutEndpoint == nil {
+		return fmt.Errorf("Item cannot be updated (No PUT endpoint)")
+	}
+
+	matchResult := item.SwaggerResourceType.Endpoint.Match(item.ExpandURL)
+	if !matchResult.IsMatch {
+		return fmt.Errorf("item.ExpandURL didn't match current Endpoint")
+	}
+
+	url, err := item.SwaggerResourceType.PutEndpoint.BuildURL(matchResult.Values)
+	if err != nil {
+		return fmt.Errorf("Error building PUT url: %s", err)
+	}
+
+	_, err = c.DoRequestWithBody(ctx, "PUT", url, content)
+	if err != nil {
+		return fmt.Errorf("Error from PUT: %s", err)
+	}
+	return nil
+}
@@ -1,11 +1,46 @@
 package expanders
 
 import (
+	"github.com/lawrencegripper/azbrowse/pkg/armclient"
 	"github.com/lawrencegripper/azbrowse/pkg/endpoints"
 	"github.com/lawrencegripper/azbrowse/pkg/swagger"
 )
 
-func (e *AzureSearchServiceExpander) loadResourceTypes() []swagger.ResourceType {
+// getDataPlaneExpanders returns an expander for each of the data-plane API sets in cmd/swagger-codegen/dataplane-apisets.json
+func getDataPlaneExpanders(client *armclient.Client) []Expander {
+	return []Expander{
+		NewDataPlaneExpander(client, swagger.DataPlaneConfig{
+			Name:                "AzureSearch",
+			SpecFolder:          "swagger-specs/search/data-plane",
+			ResourceTemplateURL: "/subscriptions/{subscriptionId}/resourceGroups/{resourceGroupName}/providers/Microsoft.Search/searchServices/{searchServiceName}",
+			ResourceAPIVersion:  "2015-08-19",
+			NodeName:            "Search Service",
+			Endpoint:            "https://{searchServiceName}.search.windows.net",
+			Auth: swagger.DataPlaneAuth{
+				Type:         "listKeys",
+				ListKeysPath: "/listAdminKeys",
+				KeyProperty:  "primaryKey",
+				Header:       "api-key",
+			},
+		}, loadAzureSearchDataPlaneResourceTypes),
+		NewDataPlaneExpander(client, swagger.DataPlaneConfig{
+			Name:                "AppConfiguration",
+			SpecFolder:          "swagger-specs/appconfiguration/data-plane",
+			ResourceTemplateURL: "/subscriptions/{subscriptionId}/resourceGroups/{resourceGroupName}/providers/Microsoft.AppConfiguration/configurationStores/{configStoreName}",
+			ResourceAPIVersion:  "2019-11-01-preview",
+			NodeName:            "Configuration Explorer",
+			Endpoint:            "{properties.endpoint}",
+			Auth: swagger.DataPlaneAuth{
+				Type:               "hmac",
+				ListKeysPath:       "/ListKeys",
+				KeyProperty:        "value.0.value",
+				CredentialProperty: "value.0.id",
+			},
+		}, loadAppConfigurationDataPlaneResourceTypes),
+	}
+}
+
+func loadAzureSearchDataPlaneResourceTypes() []swagger.ResourceType {
 	return []swagger.ResourceType{
 		{
 			Display:  "datasources",
@@ -100,5 +135,31 @@ func (e *AzureSearchServiceExpander) loadResourceTypes() []swagger.ResourceType
 					PutEndpoint:    endpoints.MustGetEndpointInfoFromURL("/synonymmaps('{synonymMapName}')", "2019-05-06"),
 				}},
 		}}
+}
 
+func loadAppConfigurationDataPlaneResourceTypes() []swagger.ResourceType {
+	return []swagger.ResourceType{
+		{
+			Display:  "keys",
+			Endpoint: endpoints.MustGetEndpointInfoFromURL("/keys", "1.0"),
+		},
+		{
+			Display:  "kv",
+			Endpoint: endpoints.MustGetEndpointInfoFromURL("/kv", "1.0"),
+			SubResources: []swagger.ResourceType{
+				{
+					Display:        "{key}",
+					Endpoint:       endpoints.MustGetEndpointInfoFromURL("/kv/{key}", "1.0"),
+					DeleteEndpoint: endpoints.MustGetEndpointInfoFromURL("/kv/{key}", "1.0"),
+					PutEndpoint:    endpoints.MustGetEndpointInfoFromURL("/kv/{key}", "1.0"),
+				}},
+		},
+		{
+			Display:  "labels",
+			Endpoint: endpoints.MustGetEndpointInfoFromURL("/labels", "1.0"),
+		},
+		{
+			Display:  "revisions",
+			Endpoint: endpoints.MustGetEndpointInfoFromURL("/revisions", "1.0"),
+		}}
 }
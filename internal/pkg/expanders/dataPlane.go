@@ -0,0 +1,363 @@
+package expanders
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/lawrencegripper/azbrowse/pkg/armclient"
+	"github.com/lawrencegripper/azbrowse/pkg/endpoints"
+	"github.com/lawrencegripper/azbrowse/pkg/swagger"
+	"github.com/nbio/st"
+	"gopkg.in/h2non/gock.v1"
+)
+
+// dataPlaneEndpointPlaceholderRegex matches the {placeholders} in a swagger.DataPlaneConfig Endpoint
+var dataPlaneEndpointPlaceholderRegex = regexp.MustCompile(`{([^}]+)}`)
+
+// Check interface
+var _ Expander = &DataPlaneExpander{}
+
+// DataPlaneExpander adds a data-plane node to the ARM resources matching a swagger.DataPlaneConfig and
+// hands the generated APIs over to the swagger expander. Instances are created by getDataPlaneExpanders
+// in dataPlane.generated.go from cmd/swagger-codegen/dataplane-apisets.json (e.g. Azure Search and App Configuration)
+type DataPlaneExpander struct {
+	ExpanderBase
+	client            *armclient.Client
+	httpClient        http.Client
+	config            swagger.DataPlaneConfig
+	loadResourceTypes func() []swagger.ResourceType
+}
+
+// NewDataPlaneExpander creates a new DataPlaneExpander for the config
+func NewDataPlaneExpander(client *armclient.Client, config swagger.DataPlaneConfig, loadResourceTypes func() []swagger.ResourceType) *DataPlaneExpander {
+	return &DataPlaneExpander{
+		client:            client,
+		config:            config,
+		loadResourceTypes: loadResourceTypes,
+	}
+}
+
+func (e *DataPlaneExpander) setClient(c *armclient.Client) {
+	e.client = c
+}
+
+// Name returns the name of the expander
+func (e *DataPlaneExpander) Name() string {
+	return e.config.Name + "DataPlaneExpander"
+}
+
+// DoesExpand checks if this is a resource for the data-plane API set
+func (e *DataPlaneExpander) DoesExpand(ctx context.Context, currentItem *TreeNode) (bool, error) {
+	swaggerResourceType := currentItem.SwaggerResourceType
+	if currentItem.ItemType == ResourceType && swaggerResourceType != nil {
+		if swaggerResourceType.Endpoint.TemplateURL == e.config.ResourceTemplateURL {
+			return true, nil
+		}
+	}
+	if currentItem.Namespace == e.Name() {
+		return true, nil
+	}
+	return false, nil
+}
+
+// Expand adds the data-plane node to the resource and expands it to the data-plane APIs
+func (e *DataPlaneExpander) Expand(ctx context.Context, currentItem *TreeNode) ExpanderResult {
+
+	swaggerResourceType := currentItem.SwaggerResourceType
+	if currentItem.Namespace != e.Name() &&
+		swaggerResourceType != nil &&
+		swaggerResourceType.Endpoint.TemplateURL == e.config.ResourceTemplateURL {
+		newItems := []*TreeNode{}
+		newItems = append(newItems, &TreeNode{
+			ID:        currentItem.ID + "/<dataplane>",
+			Parentid:  currentItem.ID,
+			Namespace: e.Name(),
+			Name:      e.config.NodeName,
+			Display:   e.config.NodeName,
+			ItemType:  SubResourceType,
+			ExpandURL: ExpandURLNotSupported,
+			Metadata: map[string]string{
+				"ResourceID":            currentItem.ID,
+				"SuppressSwaggerExpand": "true",
+				"SuppressGenericExpand": "true",
+			},
+		})
+
+		return ExpanderResult{
+			Err:               nil,
+			Response:          ExpanderResponse{Response: ""}, // Swagger expander will supply the response
+			SourceDescription: e.Name() + " request",
+			Nodes:             newItems,
+			IsPrimaryResponse: false,
+		}
+	}
+
+	if currentItem.Namespace == e.Name() && currentItem.ItemType == SubResourceType {
+		return e.expandDataPlaneRoot(ctx, currentItem)
+	}
+
+	return ExpanderResult{
+		Err:               fmt.Errorf("Error - unhandled Expand"),
+		Response:          ExpanderResponse{Response: "Error!"},
+		SourceDescription: e.Name() + " request",
+	}
+}
+
+func (e *DataPlaneExpander) expandDataPlaneRoot(ctx context.Context, currentItem *TreeNode) ExpanderResult {
+
+	resourceID := currentItem.Metadata["ResourceID"]
+
+	// Check for existing config for the resource
+	apiSet := e.getAPISetForResource(resourceID)
+	var err error
+	if apiSet == nil {
+		apiSet, err = e.createAPISetForResource(ctx, resourceID)
+		if err != nil {
+			return ExpanderResult{
+				Err:               err,
+				Response:          ExpanderResponse{Response: "Error!"},
+				SourceDescription: e.Name() + " request",
+			}
+		}
+		GetSwaggerResourceExpander().AddAPISet(*apiSet)
+	}
+
+	newItems := []*TreeNode{}
+	for _, child := range apiSet.GetResourceTypes() {
+		resourceType := child
+		display := resourceType.Display
+		if display == "{}" {
+			display = resourceType.Endpoint.TemplateURL
+		}
+		newItems = append(newItems, &TreeNode{
+			Parentid:            currentItem.ID,
+			ID:                  currentItem.ID + "/" + display,
+			Namespace:           "swagger",
+			Name:                display,
+			Display:             display,
+			ExpandURL:           resourceType.Endpoint.TemplateURL + "?api-version=" + resourceType.Endpoint.APIVersion, // all fixed template URLs
+			ItemType:            SubResourceType,
+			SwaggerResourceType: &resourceType,
+			Metadata: map[string]string{
+				"SwaggerAPISetID": apiSet.ID(),
+			},
+		})
+	}
+
+	return ExpanderResult{
+		Err:               nil,
+		Response:          ExpanderResponse{Response: ""},
+		SourceDescription: e.Name() + " request",
+		Nodes:             newItems,
+		IsPrimaryResponse: true,
+	}
+}
+
+func (e *DataPlaneExpander) createAPISetForResource(ctx context.Context, resourceID string) (*SwaggerAPISetDataPlane, error) {
+	endpoint, err := e.getEndpoint(ctx, resourceID)
+	if err != nil {
+		return nil, err
+	}
+
+	credential, key := "", ""
+	if e.config.Auth.Type == swagger.DataPlaneAuthListKeys || e.config.Auth.Type == swagger.DataPlaneAuthHMAC {
+		credential, key, err = e.getKeys(ctx, resourceID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Register the swagger config so that the swagger expander can take over
+	apiSet := NewSwaggerAPISetDataPlane(e.loadResourceTypes(), e.client, e.httpClient, resourceID+"/<dataplane>", endpoint, e.config, credential, key)
+	return &apiSet, nil
+}
+
+func (e *DataPlaneExpander) getAPISetForResource(resourceID string) *SwaggerAPISetDataPlane {
+	swaggerAPISet := GetSwaggerResourceExpander().GetAPISet(resourceID + "/<dataplane>")
+	if swaggerAPISet == nil {
+		return nil
+	}
+	apiSet := (*swaggerAPISet).(SwaggerAPISetDataPlane)
+	return &apiSet
+}
+
+// getEndpoint replaces the placeholders in the configured endpoint with the values matched from the
+// resource ID, falling back to properties of the ARM resource
+func (e *DataPlaneExpander) getEndpoint(ctx context.Context, resourceID string) (string, error) {
+	resourceEndpoint, err := endpoints.GetEndpointInfoFromURL(e.config.ResourceTemplateURL, e.config.ResourceAPIVersion)
+	if err != nil {
+		return "", err
+	}
+	matchResult := resourceEndpoint.Match(resourceID)
+	if !matchResult.IsMatch {
+		return "", fmt.Errorf("Resource ID %q doesn't match %q", resourceID, e.config.ResourceTemplateURL)
+	}
+
+	var resource interface{}
+	endpoint := e.config.Endpoint
+	for _, match := range dataPlaneEndpointPlaceholderRegex.FindAllStringSubmatch(e.config.Endpoint, -1) {
+		value, ok := matchResult.Values[match[1]]
+		if !ok {
+			if resource == nil {
+				data, err := e.client.DoRequest(ctx, "GET", resourceID+"?api-version="+e.config.ResourceAPIVersion)
+				if err != nil {
+					return "", fmt.Errorf("Failed to get resource: %s", err)
+				}
+				err = json.Unmarshal([]byte(data), &resource)
+				if err != nil {
+					return "", fmt.Errorf("Error unmarshalling response: %s\nURL:%s", err, resourceID)
+				}
+			}
+			value, err = getJSONPathValue(resource, match[1])
+			if err != nil {
+				return "", fmt.Errorf("Failed to get %s for the data-plane endpoint: %s", match[1], err)
+			}
+		}
+		endpoint = strings.Replace(endpoint, match[0], value, 1)
+	}
+	return strings.TrimRight(endpoint, "/"), nil
+}
+
+// getKeys calls the list keys action for the resource and returns the configured credential (hmac only) and key properties
+func (e *DataPlaneExpander) getKeys(ctx context.Context, resourceID string) (string, string, error) {
+	data, err := e.client.DoRequest(ctx, "POST", resourceID+e.config.Auth.ListKeysPath+"?api-version="+e.config.ResourceAPIVersion)
+	if err != nil {
+		return "", "", fmt.Errorf("Failed to list keys: %s", err)
+	}
+
+	var response interface{}
+	err = json.Unmarshal([]byte(data), &response)
+	if err != nil {
+		return "", "", fmt.Errorf("Error unmarshalling response: %s\nURL:%s", err, resourceID)
+	}
+	key, err := getJSONPathValue(response, e.config.Auth.KeyProperty)
+	if err != nil {
+		return "", "", fmt.Errorf("Failed to get key: %s", err)
+	}
+	credential := ""
+	if e.config.Auth.CredentialProperty != "" {
+		credential, err = getJSONPathValue(response, e.config.Auth.CredentialProperty)
+		if err != nil {
+			return "", "", fmt.Errorf("Failed to get key credential: %s", err)
+		}
+	}
+	return credential, key, nil
+}
+
+// getJSONPathValue returns the string value at a dotted path (e.g. "properties.hostName" or "keys.0.value")
+// in unmarshalled JSON. Numeric segments index into arrays
+func getJSONPathValue(value interface{}, path string) (string, error) {
+	for _, segment := range strings.Split(path, ".") {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			child, ok := v[segment]
+			if !ok {
+				return "", fmt.Errorf("Property %q not found in %q", segment, path)
+			}
+			value = child
+		case []interface{}:
+			index, err := strconv.Atoi(segment)
+			if err != nil || index < 0 || index >= len(v) {
+				return "", fmt.Errorf("Invalid array index %q in %q", segment, path)
+			}
+			value = v[index]
+		default:
+			return "", fmt.Errorf("Property %q not found in %q", segment, path)
+		}
+	}
+	switch v := value.(type) {
+	case string:
+		if v == "" {
+			return "", fmt.Errorf("Property %q is empty", path)
+		}
+		return v, nil
+	case nil:
+		return "", fmt.Errorf("Property %q is null", path)
+	default:
+		return fmt.Sprintf("%v", v), nil
+	}
+}
+
+// Delete attempts to delete the item. Returns true if deleted, false if not handled, an error if an error occurred attempting to delete
+func (e *DataPlaneExpander) Delete(ctx context.Context, item *TreeNode) (bool, error) {
+	return false, nil
+}
+
+func (e *DataPlaneExpander) testCases() (bool, *[]expanderTestCase) {
+	switch e.config.Name {
+	case "AzureSearch":
+		const searchID = "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/stable/providers/Microsoft.Search/searchServices/testsearch"
+		noRequestsGockConfig := func(t *testing.T) {}
+
+		return true, &[]expanderTestCase{
+			{
+				name: "AzureSearch->DataPlaneNode",
+				nodeToExpand: &TreeNode{
+					ID:        searchID,
+					ItemType:  ResourceType,
+					ExpandURL: searchID + "?api-version=2015-08-19",
+					SwaggerResourceType: &swagger.ResourceType{
+						Endpoint: endpoints.MustGetEndpointInfoFromURL(e.config.ResourceTemplateURL, "2015-08-19"),
+					},
+				},
+				configureGockFunc: &noRequestsGockConfig,
+				treeNodeCheckerFunc: func(t *testing.T, r ExpanderResult) {
+					st.Expect(t, r.Err, nil)
+					st.Expect(t, len(r.Nodes), 1)
+
+					st.Expect(t, r.Nodes[0].Name, "Search Service")
+					st.Expect(t, r.Nodes[0].Namespace, "AzureSearchDataPlaneExpander")
+					st.Expect(t, r.Nodes[0].Metadata["ResourceID"], searchID)
+				},
+			},
+		}
+	case "AppConfiguration":
+		const storeID = "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/stable/providers/Microsoft.AppConfiguration/configurationStores/testconfig"
+		rootGockConfig := func(t *testing.T) {
+			gock.New("https://management.azure.com").
+				Get(storeID).
+				Reply(200).
+				JSON(`{"properties": {"endpoint": "https://testconfig.azconfig.io"}}`)
+			gock.New("https://management.azure.com").
+				Post(storeID + "/ListKeys").
+				Reply(200).
+				File("./testdata/armsamples/appconfiguration/listKeys.json")
+		}
+
+		return true, &[]expanderTestCase{
+			{
+				name: "AppConfiguration->Explorer",
+				nodeToExpand: &TreeNode{
+					ID:        storeID + "/<dataplane>",
+					Namespace: e.Name(),
+					ItemType:  SubResourceType,
+					ExpandURL: ExpandURLNotSupported,
+					Metadata: map[string]string{
+						"ResourceID": storeID,
+					},
+				},
+				configureGockFunc: &rootGockConfig,
+				treeNodeCheckerFunc: func(t *testing.T, r ExpanderResult) {
+					st.Expect(t, r.Err, nil)
+					st.Expect(t, len(r.Nodes), 4)
+
+					st.Expect(t, r.Nodes[1].Name, "kv")
+					st.Expect(t, r.Nodes[1].ExpandURL, "/kv?api-version=1.0")
+					st.Expect(t, r.Nodes[1].Metadata["SwaggerAPISetID"], storeID+"/<dataplane>")
+
+					apiSet := e.getAPISetForResource(storeID)
+					st.Expect(t, apiSet != nil, true)
+					st.Expect(t, apiSet.endpoint, "https://testconfig.azconfig.io")
+					st.Expect(t, apiSet.credential, "Abcd-l0-s0:7GAnfZ6hTphVVoSdMgTp")
+				},
+			},
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,236 @@
+package expanders
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/lawrencegripper/azbrowse/pkg/armclient"
+	"github.com/lawrencegripper/azbrowse/pkg/endpoints"
+	"github.com/lawrencegripper/azbrowse/pkg/swagger"
+	"github.com/nbio/st"
+	"gopkg.in/h2non/gock.v1"
+)
+
+const (
+	testIoTCentralAppID = "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/stable/providers/Microsoft.IoTCentral/IoTApps/testapp"
+	testSearchID        = "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/stable/providers/Microsoft.Search/searchServices/testsearch"
+)
+
+func newTestDataPlaneClient() *armclient.Client {
+	httpClient := &http.Client{Transport: &http.Transport{}}
+	gock.InterceptClient(httpClient)
+	return armclient.NewClientFromConfig(httpClient, DummyTokenFunc(), 5000)
+}
+
+// getTestDataPlaneExpander returns the generated expander for the data-plane API set with the name
+func getTestDataPlaneExpander(t *testing.T, client *armclient.Client, name string) *DataPlaneExpander {
+	for _, expander := range getDataPlaneExpanders(client) {
+		dataPlaneExpander := expander.(*DataPlaneExpander)
+		if dataPlaneExpander.config.Name == name {
+			return dataPlaneExpander
+		}
+	}
+	t.Fatalf("Data-plane API set %s not found", name)
+	return nil
+}
+
+func newTestSearchAPISet(t *testing.T) *SwaggerAPISetDataPlane {
+	gock.New("https://management.azure.com").
+		Post(testSearchID+"/listAdminKeys").
+		MatchParam("api-version", "2015-08-19").
+		Reply(200).
+		JSON(`{"primaryKey": "testkey", "secondaryKey": "testkey2"}`)
+
+	expander := getTestDataPlaneExpander(t, newTestDataPlaneClient(), "AzureSearch")
+	// the endpoint only uses values from the resource ID so the resource isn't requested
+	apiSet, err := expander.createAPISetForResource(context.Background(), testSearchID)
+	st.Expect(t, err, nil)
+	st.Expect(t, apiSet.endpoint, "https://testsearch.search.windows.net")
+	return apiSet
+}
+
+func Test_DataPlane_AADAuth(t *testing.T) {
+	defer gock.Off()
+	gock.New("https://management.azure.com").
+		Get(testIoTCentralAppID).
+		MatchParam("api-version", "2018-09-01").
+		Reply(200).
+		JSON(`{"name": "testapp", "properties": {"subdomain": "test-subdomain"}}`)
+	gock.New("https://test-subdomain.azureiotcentral.com").
+		Get("/api/preview/devices").
+		MatchParam("api-version", "preview").
+		MatchHeader("Authorization", "^Bearer expiredtoken$").
+		Reply(401)
+	gock.New("https://test-subdomain.azureiotcentral.com").
+		Get("/api/preview/devices").
+		MatchParam("api-version", "preview").
+		MatchHeader("Authorization", "^Bearer testtoken$").
+		Reply(200).
+		JSON(`{"value": [{"id": "thermostat1", "displayName": "Thermostat"}, {"id": "thermostat2"}], "nextLink": "https://test-subdomain.azureiotcentral.com/api/preview/devices?api-version=preview&page=2"}`)
+	gock.New("https://test-subdomain.azureiotcentral.com").
+		Get("/api/preview/devices").
+		MatchParam("page", "2").
+		MatchHeader("Authorization", "^Bearer testtoken$").
+		Reply(200).
+		JSON(`{"value": [{"id": "thermostat3"}]}`)
+
+	client := newTestDataPlaneClient()
+	cachedToken := "expiredtoken"
+	client.SetAquireResourceToken(func(clearCache bool, tenantID, resource string) (armclient.AzCLIToken, error) {
+		st.Expect(t, resource, "https://apps.azureiotcentral.com")
+		if clearCache {
+			cachedToken = "testtoken"
+		}
+		return armclient.AzCLIToken{TokenType: "Bearer", AccessToken: cachedToken}, nil
+	})
+	expander := NewDataPlaneExpander(client, swagger.DataPlaneConfig{
+		Name:                "Test",
+		ResourceTemplateURL: "/subscriptions/{subscriptionId}/resourceGroups/{resourceGroupName}/providers/Microsoft.IoTCentral/IoTApps/{resourceName}",
+		ResourceAPIVersion:  "2018-09-01",
+		Endpoint:            "https://{properties.subdomain}.azureiotcentral.com/api/preview",
+		Auth: swagger.DataPlaneAuth{
+			Type:     swagger.DataPlaneAuthAAD,
+			Audience: "https://apps.azureiotcentral.com",
+		},
+	}, func() []swagger.ResourceType {
+		return []swagger.ResourceType{
+			{
+				Display:  "devices",
+				Endpoint: endpoints.MustGetEndpointInfoFromURL("/devices", "preview"),
+				SubResources: []swagger.ResourceType{
+					{
+						Display:        "{device_id}",
+						Endpoint:       endpoints.MustGetEndpointInfoFromURL("/devices/{device_id}", "preview"),
+						DeleteEndpoint: endpoints.MustGetEndpointInfoFromURL("/devices/{device_id}", "preview"),
+					}},
+			},
+		}
+	})
+	expander.httpClient = http.Client{}
+
+	apiSet, err := expander.createAPISetForResource(context.Background(), testIoTCentralAppID)
+	st.Expect(t, err, nil)
+	st.Expect(t, apiSet.ID(), testIoTCentralAppID+"/<dataplane>")
+	st.Expect(t, apiSet.endpoint, "https://test-subdomain.azureiotcentral.com/api/preview")
+
+	var resourceType swagger.ResourceType
+	for _, rt := range apiSet.GetResourceTypes() {
+		if rt.Display == "devices" {
+			resourceType = rt
+		}
+	}
+	node := &TreeNode{
+		ExpandURL:           "/devices?api-version=preview",
+		SwaggerResourceType: &resourceType,
+	}
+	result, err := apiSet.ExpandResource(context.Background(), node, resourceType)
+	st.Expect(t, err, nil)
+	st.Expect(t, len(result.SubResources), 3)
+	st.Expect(t, result.SubResources[0].Name, "thermostat1")
+	st.Expect(t, result.SubResources[0].ExpandURL, "/devices/thermostat1?api-version=preview")
+	st.Expect(t, result.SubResources[0].DeleteURL, "/devices/thermostat1?api-version=preview")
+	st.Expect(t, result.SubResources[2].ID, testIoTCentralAppID+"/<dataplane>/devices/thermostat3?api-version=preview")
+	st.Expect(t, gock.IsDone(), true)
+}
+
+func Test_DataPlane_ListKeysAuth(t *testing.T) {
+	defer gock.Off()
+	gock.New("https://testsearch.search.windows.net").
+		Put("/indexes\\('hotels'\\)").
+		MatchHeader("api-key", "^testkey$").
+		MatchType("json").
+		BodyString(`{"name": "hotels"}`).
+		Reply(200)
+
+	apiSet := newTestSearchAPISet(t)
+	resourceType := apiSet.GetResourceTypes()[2].SubResources[0]
+	st.Expect(t, resourceType.Endpoint.TemplateURL, "/indexes('{indexName}')")
+	node := &TreeNode{
+		ExpandURL:           "/indexes('hotels')?api-version=2019-05-06",
+		SwaggerResourceType: &resourceType,
+	}
+	err := apiSet.Update(context.Background(), node, `{"name": "hotels"}`)
+	st.Expect(t, err, nil)
+	st.Expect(t, gock.IsDone(), true)
+}
+
+func Test_DataPlane_AzureSearchDocs(t *testing.T) {
+	defer gock.Off()
+	gock.New("https://testsearch.search.windows.net").
+		Get("/indexes\\('hotels'\\)/docs").
+		MatchHeader("api-key", "^testkey$").
+		Reply(200).
+		JSON(`{"value": [{"hotelId": "1", "name": "Seaview"}, {"hotelId": "2", "name": "Hilltop"}]}`)
+	gock.New("https://testsearch.search.windows.net").
+		Post("/indexes\\('hotels'\\)/docs/index").
+		MatchHeader("api-key", "^testkey$").
+		MatchType("json").
+		BodyString(`{"value":[{"@search.action":"upload","hotelId":"2","name":"Hilltop Lodge"}]}`).
+		Reply(200)
+
+	apiSet := newTestSearchAPISet(t)
+	var docsType swagger.ResourceType
+	for _, resourceType := range apiSet.GetResourceTypes()[2].SubResources[0].Children {
+		if resourceType.Endpoint.TemplateURL == azureSearchDocsTemplateURL {
+			docsType = resourceType
+		}
+	}
+	node := &TreeNode{
+		ExpandURL:           "/indexes('hotels')/docs?api-version=2019-05-06",
+		SwaggerResourceType: &docsType,
+		Metadata: map[string]string{
+			"IndexKey": "hotelId",
+		},
+	}
+	result, err := apiSet.ExpandResource(context.Background(), node, docsType)
+	st.Expect(t, err, nil)
+	st.Expect(t, len(result.SubResources), 2)
+	st.Expect(t, result.SubResources[1].Name, "2")
+	st.Expect(t, result.SubResources[1].ExpandURL, "/indexes('hotels')/docs('2')?api-version=2019-05-06")
+
+	// documents are updated by POSTing a batch to docs/index
+	docType := result.SubResources[1].ResourceType
+	docNode := &TreeNode{
+		ExpandURL:           result.SubResources[1].ExpandURL,
+		SwaggerResourceType: &docType,
+		Metadata:            result.SubResources[1].Metadata,
+	}
+	err = apiSet.Update(context.Background(), docNode, `{"hotelId": "2", "name": "Hilltop Lodge"}`)
+	st.Expect(t, err, nil)
+	st.Expect(t, gock.IsDone(), true)
+}
+
+func Test_getJSONPathValue(t *testing.T) {
+	var value interface{} = map[string]interface{}{
+		"properties": map[string]interface{}{
+			"hostName": "test.example.com",
+			"port":     float64(443),
+			"empty":    "",
+		},
+		"keys": []interface{}{
+			map[string]interface{}{"value": "key1"},
+		},
+	}
+
+	result, err := getJSONPathValue(value, "properties.hostName")
+	st.Expect(t, err, nil)
+	st.Expect(t, result, "test.example.com")
+
+	result, err = getJSONPathValue(value, "properties.port")
+	st.Expect(t, err, nil)
+	st.Expect(t, result, "443")
+
+	result, err = getJSONPathValue(value, "keys.0.value")
+	st.Expect(t, err, nil)
+	st.Expect(t, result, "key1")
+
+	_, err = getJSONPathValue(value, "keys.1.value")
+	st.Expect(t, err != nil, true)
+
+	_, err = getJSONPathValue(value, "properties.missing")
+	st.Expect(t, err != nil, true)
+
+	_, err = getJSONPathValue(value, "properties.empty")
+	st.Expect(t, err != nil, true)
+}
@@ -63,9 +63,10 @@ func (e *DefaultExpander) Expand(ctx context.Context, currentItem *TreeNode) Exp
 		}
 	}
 
-	// Update the existing state as we have more up-to-date info
+	// Update the existing state as we have more up-to-date info.
+	// Virtual machines show their power state instead, which the VirtualMachineExpander updates
 	newStatus := DrawStatus(resource.Properties.ProvisioningState)
-	if newStatus != currentItem.StatusIndicator {
+	if newStatus != currentItem.StatusIndicator && !IsVirtualMachine(currentItem) {
 		eventing.SendStatusEvent(&eventing.StatusEvent{
 			InProgress: true,
 			Message:    "Updated resource status -> " + DrawStatus(resource.Properties.ProvisioningState),
@@ -0,0 +1,419 @@
+package expanders
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/lawrencegripper/azbrowse/internal/pkg/style"
+	"github.com/lawrencegripper/azbrowse/pkg/armclient"
+	"github.com/nbio/st"
+	"gopkg.in/h2non/gock.v1"
+)
+
+const (
+	eventGridNamespace  = "eventGrid"
+	eventGridAPIVersion = "2020-04-01-preview"
+
+	eventGridSubscriptionsType = "eventGrid.subscriptions"
+	eventGridSubscriptionType  = "eventGrid.subscription"
+
+	// eventGridDeliveryMetrics are totalled over eventGridMetricsTimespan for each subscription
+	eventGridDeliveryMetrics = "MatchedEventCount,DeliverySuccessCount,DeliveryAttemptFailCount,DeadLetteredCount,DroppedEventCount"
+	eventGridMetricsTimespan = "P1D"
+)
+
+// eventGridTopicIDRegex matches custom topics, system topics and domains, with the domain topic name for topics in a domain
+var eventGridTopicIDRegex = regexp.MustCompile(`(?i)^(/subscriptions/[^/]+/resourceGroups/[^/]+/providers/Microsoft\.EventGrid/(topics|systemTopics|domains)/[^/]+)(/topics/[^/]+)?$`)
+
+type eventGridDestination struct {
+	EndpointType string `json:"endpointType"`
+	Properties   struct {
+		EndpointBaseURL   string `json:"endpointBaseUrl"`
+		ResourceID        string `json:"resourceId"`
+		QueueName         string `json:"queueName"`
+		BlobContainerName string `json:"blobContainerName"`
+	} `json:"properties"`
+}
+
+type eventGridSubscription struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	Properties struct {
+		ProvisioningState     string                `json:"provisioningState"`
+		Destination           *eventGridDestination `json:"destination"`
+		DeadLetterDestination *eventGridDestination `json:"deadLetterDestination"`
+		// Destinations for subscriptions that deliver using the topic's managed identity
+		DeliveryWithResourceIdentity *struct {
+			Destination *eventGridDestination `json:"destination"`
+		} `json:"deliveryWithResourceIdentity"`
+		DeadLetterWithResourceIdentity *struct {
+			DeadLetterDestination *eventGridDestination `json:"deadLetterDestination"`
+		} `json:"deadLetterWithResourceIdentity"`
+	} `json:"properties"`
+}
+
+func (s eventGridSubscription) destination() *eventGridDestination {
+	if s.Properties.Destination == nil && s.Properties.DeliveryWithResourceIdentity != nil {
+		return s.Properties.DeliveryWithResourceIdentity.Destination
+	}
+	return s.Properties.Destination
+}
+
+func (s eventGridSubscription) deadLetterDestination() *eventGridDestination {
+	if s.Properties.DeadLetterDestination == nil && s.Properties.DeadLetterWithResourceIdentity != nil {
+		return s.Properties.DeadLetterWithResourceIdentity.DeadLetterDestination
+	}
+	return s.Properties.DeadLetterDestination
+}
+
+// describe returns the type of the destination and where it delivers to, e.g. "WebHook https://example.com/api"
+func (d *eventGridDestination) describe() string {
+	if d == nil {
+		return "none"
+	}
+	target := d.Properties.EndpointBaseURL
+	if d.Properties.ResourceID != "" {
+		target = d.Properties.ResourceID[strings.LastIndex(d.Properties.ResourceID, "/")+1:]
+	}
+	if d.Properties.QueueName != "" {
+		target += "/" + d.Properties.QueueName
+	}
+	if d.Properties.BlobContainerName != "" {
+		target += "/" + d.Properties.BlobContainerName
+	}
+	return strings.TrimSpace(d.EndpointType + " " + target)
+}
+
+// Check interface
+var _ Expander = &EventGridExpander{}
+
+// EventGridExpander adds the event subscriptions with their delivery metrics and dead-letter locations
+// to Event Grid topics, system topics, domains and domain topics
+type EventGridExpander struct {
+	ExpanderBase
+	client *armclient.Client
+}
+
+func (e *EventGridExpander) setClient(c *armclient.Client) {
+	e.client = c
+}
+
+// Name returns the name of the expander
+func (e *EventGridExpander) Name() string {
+	return "EventGridExpander"
+}
+
+func isEventGridTopic(node *TreeNode) bool {
+	return node != nil &&
+		(node.ItemType == ResourceType || node.ItemType == SubResourceType) &&
+		eventGridTopicIDRegex.MatchString(node.ID)
+}
+
+// DoesExpand checks if this is an Event Grid topic or domain or one of the nodes added for them
+func (e *EventGridExpander) DoesExpand(ctx context.Context, currentItem *TreeNode) (bool, error) {
+	if currentItem.Namespace == eventGridNamespace {
+		return true, nil
+	}
+	return isEventGridTopic(currentItem), nil
+}
+
+// Expand adds the event subscriptions node to a topic or expands it
+func (e *EventGridExpander) Expand(ctx context.Context, currentItem *TreeNode) ExpanderResult {
+	switch currentItem.ItemType {
+	case eventGridSubscriptionsType:
+		return e.expandSubscriptions(ctx, currentItem)
+	case eventGridSubscriptionType:
+		data, err := e.client.DoRequest(ctx, "GET", currentItem.ExpandURL)
+		if err != nil {
+			return ExpanderResult{
+				Err:               err,
+				SourceDescription: "EventGridExpander request",
+			}
+		}
+		return ExpanderResult{
+			Response:          ExpanderResponse{Response: data, ResponseType: ResponseJSON},
+			SourceDescription: "EventGridExpander request",
+			IsPrimaryResponse: true,
+		}
+	}
+
+	return ExpanderResult{
+		Nodes: []*TreeNode{
+			{
+				Parentid:       currentItem.ID,
+				ID:             currentItem.ID + "/<" + eventGridSubscriptionsType + ">",
+				Namespace:      eventGridNamespace,
+				Name:           "Event subscriptions",
+				Display:        "Event subscriptions",
+				ItemType:       eventGridSubscriptionsType,
+				ExpandURL:      ExpandURLNotSupported,
+				SubscriptionID: currentItem.SubscriptionID,
+				Metadata: map[string]string{
+					"ResourceID":            currentItem.ID,
+					"SuppressSwaggerExpand": "true",
+					"SuppressGenericExpand": "true",
+				},
+			},
+		},
+		SourceDescription: "EventGridExpander request",
+	}
+}
+
+// getEventGridSubscriptionsURL returns the URL to list the event subscriptions for the topic and
+// the ID of the resource that the delivery metrics are reported against
+func getEventGridSubscriptionsURL(topicID string) (string, string) {
+	match := eventGridTopicIDRegex.FindStringSubmatch(topicID)
+	if match == nil {
+		return "", ""
+	}
+	if strings.EqualFold(match[2], "systemTopics") {
+		return topicID + "/eventSubscriptions", topicID
+	}
+	// subscriptions to custom topics, domains and domain topics are extension resources,
+	// for domain topics the metrics are reported by the domain
+	return topicID + "/providers/Microsoft.EventGrid/eventSubscriptions", match[1]
+}
+
+// expandSubscriptions lists the event subscriptions for the topic with their destination, dead-letter location
+// and the delivery metrics over the last day
+func (e *EventGridExpander) expandSubscriptions(ctx context.Context, currentItem *TreeNode) ExpanderResult {
+	topicID := currentItem.Metadata["ResourceID"]
+	listURL, metricsResourceID := getEventGridSubscriptionsURL(topicID)
+	data, err := e.client.DoRequest(ctx, "GET", listURL+"?api-version="+eventGridAPIVersion)
+	if err != nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Failed to list event subscriptions: %s", err),
+			SourceDescription: "EventGridExpander request",
+		}
+	}
+
+	subscriptions := []eventGridSubscription{}
+	nextData := data
+	for nextData != "" {
+		var response struct {
+			Value    []eventGridSubscription `json:"value"`
+			NextLink string                  `json:"nextLink"`
+		}
+		err = json.Unmarshal([]byte(nextData), &response)
+		if err != nil {
+			return ExpanderResult{
+				Err:               fmt.Errorf("Error unmarshalling event subscriptions: %s", err),
+				SourceDescription: "EventGridExpander request",
+			}
+		}
+		subscriptions = append(subscriptions, response.Value...)
+
+		nextData = ""
+		if response.NextLink != "" {
+			nextData, err = e.client.DoRequest(ctx, "GET", response.NextLink)
+			if err != nil {
+				return ExpanderResult{
+					Err:               fmt.Errorf("Failed to list event subscriptions: %s", err),
+					SourceDescription: "EventGridExpander request",
+				}
+			}
+		}
+	}
+
+	// Subscriptions are still useful without the metrics so a failure to get them is shown in the response
+	metrics, metricsErr := e.getDeliveryMetrics(ctx, metricsResourceID)
+
+	newItems := []*TreeNode{}
+	lines := []string{
+		style.Title("Event subscriptions") + style.Subtle(" (delivery metrics for the last 24 hours)"),
+		"",
+	}
+	if metricsErr != nil {
+		lines = append(lines, style.Warning("Failed to get delivery metrics: "+metricsErr.Error()), "")
+	}
+	if len(subscriptions) == 0 {
+		lines = append(lines, "No event subscriptions")
+	}
+	for _, subscription := range subscriptions {
+		subscriptionMetrics := metrics[strings.ToLower(subscription.Name)]
+
+		display := subscription.Name + " " + style.Subtle("("+subscription.destination().describe()+")")
+		if subscriptionMetrics["DeadLetteredCount"] > 0 || subscriptionMetrics["DroppedEventCount"] > 0 {
+			display += " " + style.Warning(fmt.Sprintf("dead-lettered: %.0f dropped: %.0f",
+				subscriptionMetrics["DeadLetteredCount"], subscriptionMetrics["DroppedEventCount"]))
+		}
+		statusIndicator := ""
+		if subscription.Properties.ProvisioningState != "Succeeded" {
+			statusIndicator = DrawStatus(subscription.Properties.ProvisioningState)
+		}
+		newItems = append(newItems, &TreeNode{
+			Parentid:        currentItem.ID,
+			ID:              subscription.ID,
+			Namespace:       eventGridNamespace,
+			Name:            subscription.Name,
+			Display:         display,
+			StatusIndicator: statusIndicator,
+			ItemType:        eventGridSubscriptionType,
+			ExpandURL:       subscription.ID + "?api-version=" + eventGridAPIVersion,
+			DeleteURL:       subscription.ID + "?api-version=" + eventGridAPIVersion,
+			SubscriptionID:  currentItem.SubscriptionID,
+			Metadata: map[string]string{
+				"SuppressSwaggerExpand": "true",
+				"SuppressGenericExpand": "true",
+			},
+		})
+
+		lines = append(lines,
+			style.Highlight(subscription.Name),
+			"  Destination:  "+subscription.destination().describe(),
+			"  Dead-letter:  "+subscription.deadLetterDestination().describe())
+		if metricsErr == nil {
+			lines = append(lines, fmt.Sprintf("  Matched: %.0f  Delivered: %.0f  Failed attempts: %.0f  Dead-lettered: %.0f  Dropped: %.0f",
+				subscriptionMetrics["MatchedEventCount"],
+				subscriptionMetrics["DeliverySuccessCount"],
+				subscriptionMetrics["DeliveryAttemptFailCount"],
+				subscriptionMetrics["DeadLetteredCount"],
+				subscriptionMetrics["DroppedEventCount"]))
+		}
+		lines = append(lines, "")
+	}
+
+	return ExpanderResult{
+		Response:          ExpanderResponse{Response: strings.Join(lines, "\n"), ResponseType: ResponsePlainText},
+		SourceDescription: "EventGridExpander request",
+		Nodes:             newItems,
+		IsPrimaryResponse: true,
+	}
+}
+
+// getDeliveryMetrics returns the totals of the delivery metrics keyed by the lower-cased subscription name and then the metric name
+func (e *EventGridExpander) getDeliveryMetrics(ctx context.Context, resourceID string) (map[string]map[string]float64, error) {
+	query := url.Values{}
+	query.Set("metricnames", eventGridDeliveryMetrics)
+	query.Set("aggregation", "Total")
+	query.Set("timespan", eventGridMetricsTimespan)
+	query.Set("interval", eventGridMetricsTimespan)
+	query.Set("$filter", "EventSubscriptionName eq '*'")
+	query.Set("api-version", "2018-01-01")
+	data, err := e.client.DoRequest(ctx, "GET", resourceID+"/providers/microsoft.insights/metrics?"+query.Encode())
+	if err != nil {
+		return nil, err
+	}
+
+	var response armclient.MetricResponse
+	err = json.Unmarshal([]byte(data), &response)
+	if err != nil {
+		return nil, fmt.Errorf("Error unmarshalling metrics: %s", err)
+	}
+
+	result := map[string]map[string]float64{}
+	for _, metric := range response.Value {
+		for _, timeseries := range metric.Timeseries {
+			subscriptionName := ""
+			for _, metadataValue := range timeseries.Metadatavalues {
+				if strings.EqualFold(metadataValue.Name.Value, "EventSubscriptionName") {
+					subscriptionName = strings.ToLower(metadataValue.Value)
+				}
+			}
+			if result[subscriptionName] == nil {
+				result[subscriptionName] = map[string]float64{}
+			}
+			for _, datapoint := range timeseries.Data {
+				if total, ok := datapoint["total"].(float64); ok {
+					result[subscriptionName][metric.Name.Value] += total
+				}
+			}
+		}
+	}
+	return result, nil
+}
+
+func (e *EventGridExpander) testCases() (bool, *[]expanderTestCase) {
+	const topicID = "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/stable/providers/Microsoft.EventGrid/topics/testtopic"
+	const domainTopicID = "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/stable/providers/Microsoft.EventGrid/domains/testdomain/topics/orders"
+
+	topicNode := &TreeNode{ID: topicID, Name: "testtopic", ItemType: ResourceType}
+	subscriptionsNode := &TreeNode{
+		ID:        topicID + "/<" + eventGridSubscriptionsType + ">",
+		Namespace: eventGridNamespace,
+		ItemType:  eventGridSubscriptionsType,
+		ExpandURL: ExpandURLNotSupported,
+		Metadata:  map[string]string{"ResourceID": topicID},
+	}
+	domainSubscriptionsNode := &TreeNode{
+		ID:        domainTopicID + "/<" + eventGridSubscriptionsType + ">",
+		Namespace: eventGridNamespace,
+		ItemType:  eventGridSubscriptionsType,
+		ExpandURL: ExpandURLNotSupported,
+		Metadata:  map[string]string{"ResourceID": domainTopicID},
+	}
+
+	noRequestsGockConfig := func(t *testing.T) {}
+	subscriptionsGockConfig := func(t *testing.T) {
+		gock.New("https://management.azure.com").
+			Get(topicID + "/providers/Microsoft.EventGrid/eventSubscriptions").
+			Reply(200).
+			File("./testdata/armsamples/eventgrid/eventSubscriptions.json")
+		gock.New("https://management.azure.com").
+			Get(topicID+"/providers/microsoft.insights/metrics").
+			MatchParam("$filter", "EventSubscriptionName eq '\\*'").
+			MatchParam("aggregation", "Total").
+			Reply(200).
+			File("./testdata/armsamples/eventgrid/metrics.json")
+	}
+	domainSubscriptionsGockConfig := func(t *testing.T) {
+		gock.New("https://management.azure.com").
+			Get(domainTopicID + "/providers/Microsoft.EventGrid/eventSubscriptions").
+			Reply(200).
+			JSON(`{"value": []}`)
+		gock.New("https://management.azure.com").
+			Get("/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/stable/providers/Microsoft.EventGrid/domains/testdomain/providers/microsoft.insights/metrics").
+			Reply(403).
+			JSON(`{"error": {"code": "AuthorizationFailed"}}`)
+	}
+
+	return true, &[]expanderTestCase{
+		{
+			name:              "EventGrid->Topic",
+			nodeToExpand:      topicNode,
+			configureGockFunc: &noRequestsGockConfig,
+			treeNodeCheckerFunc: func(t *testing.T, r ExpanderResult) {
+				st.Expect(t, r.Err, nil)
+				st.Expect(t, len(r.Nodes), 1)
+				st.Expect(t, r.Nodes[0].ItemType, eventGridSubscriptionsType)
+				st.Expect(t, r.Nodes[0].Metadata["ResourceID"], topicID)
+			},
+		},
+		{
+			name:              "EventGrid->Subscriptions",
+			nodeToExpand:      subscriptionsNode,
+			configureGockFunc: &subscriptionsGockConfig,
+			treeNodeCheckerFunc: func(t *testing.T, r ExpanderResult) {
+				st.Expect(t, r.Err, nil)
+				st.Expect(t, len(r.Nodes), 2)
+
+				st.Expect(t, r.Nodes[0].Name, "orders-webhook")
+				st.Expect(t, r.Nodes[0].Display, "orders-webhook "+style.Subtle("(WebHook https://example.com/api/orders)")+" "+style.Warning("dead-lettered: 2 dropped: 0"))
+				st.Expect(t, r.Nodes[0].StatusIndicator, "")
+				st.Expect(t, r.Nodes[1].Display, "orders-queue "+style.Subtle("(StorageQueue teststorage/orders)"))
+				st.Expect(t, r.Nodes[1].StatusIndicator, DrawStatus("Failed"))
+
+				st.Expect(t, strings.Contains(r.Response.Response, "  Dead-letter:  StorageBlob teststorage/deadletters"), true)
+				st.Expect(t, strings.Contains(r.Response.Response, "  Matched: 120  Delivered: 118  Failed attempts: 4  Dead-lettered: 2  Dropped: 0"), true)
+				st.Expect(t, strings.Contains(r.Response.Response, "  Dead-letter:  none"), true)
+				st.Expect(t, strings.Contains(r.Response.Response, "  Matched: 35  Delivered: 0  Failed attempts: 0  Dead-lettered: 0  Dropped: 0"), true)
+			},
+		},
+		{
+			name:              "EventGrid->DomainTopicSubscriptions",
+			nodeToExpand:      domainSubscriptionsNode,
+			configureGockFunc: &domainSubscriptionsGockConfig,
+			treeNodeCheckerFunc: func(t *testing.T, r ExpanderResult) {
+				st.Expect(t, r.Err, nil)
+				st.Expect(t, len(r.Nodes), 0)
+				st.Expect(t, strings.Contains(r.Response.Response, "Failed to get delivery metrics"), true)
+				st.Expect(t, strings.Contains(r.Response.Response, "No event subscriptions"), true)
+			},
+		},
+	}
+}
@@ -0,0 +1,368 @@
+package expanders
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"testing"
+
+	"github.com/lawrencegripper/azbrowse/internal/pkg/style"
+	"github.com/lawrencegripper/azbrowse/pkg/armclient"
+	"github.com/nbio/st"
+	"gopkg.in/h2non/gock.v1"
+)
+
+const (
+	eventHubsTemplateURL = "/subscriptions/{subscriptionId}/resourceGroups/{resourceGroupName}/providers/Microsoft.EventHub/namespaces/{namespaceName}"
+	eventHubsNamespace   = "eventHubs"
+	eventHubsResource    = "https://eventhubs.azure.net"
+
+	eventHubsHubType       = "eventHubs.hub"
+	eventHubsPartitionType = "eventHubs.partition"
+
+	// the runtime information for a partition is the same for all consumer groups
+	eventHubsPartitionsPath = "consumergroups/$Default/partitions"
+	eventHubsAPIVersion     = "2014-01"
+)
+
+type eventHubsListResponse struct {
+	Value []struct {
+		ID         string `json:"id"`
+		Name       string `json:"name"`
+		Properties struct {
+			PartitionCount int    `json:"partitionCount"`
+			Status         string `json:"status"`
+		} `json:"properties"`
+	} `json:"value"`
+	NextLink string `json:"nextLink"`
+}
+
+type eventHubsPartition struct {
+	PartitionID            string `xml:"-" json:"partitionId"`
+	SizeInBytes            int64  `xml:"SizeInBytes" json:"sizeInBytes"`
+	BeginSequenceNumber    int64  `xml:"BeginSequenceNumber" json:"beginSequenceNumber"`
+	EndSequenceNumber      int64  `xml:"EndSequenceNumber" json:"endSequenceNumber"`
+	IncomingBytesPerSecond int64  `xml:"IncomingBytesPerSecond" json:"incomingBytesPerSecond"`
+	OutgoingBytesPerSecond int64  `xml:"OutgoingBytesPerSecond" json:"outgoingBytesPerSecond"`
+	LastEnqueuedOffset     string `xml:"LastEnqueuedOffset" json:"lastEnqueuedOffset"`
+	LastEnqueuedTimeUtc    string `xml:"LastEnqueuedTimeUtc" json:"lastEnqueuedTimeUtc"`
+}
+
+type eventHubsPartitionFeed struct {
+	Entries []struct {
+		Title     string             `xml:"title"`
+		Partition eventHubsPartition `xml:"content>PartitionDescription"`
+	} `xml:"entry"`
+}
+
+// NewEventHubsExpander creates a new instance of EventHubsExpander
+func NewEventHubsExpander(armclient *armclient.Client) *EventHubsExpander {
+	return &EventHubsExpander{
+		httpClient: &http.Client{},
+		armClient:  armclient,
+		clients:    map[string]*messagingNamespaceClient{},
+	}
+}
+
+// Check interface
+var _ Expander = &EventHubsExpander{}
+
+// EventHubsExpander expands the event hubs in a namespace to show the runtime information for their partitions
+type EventHubsExpander struct {
+	ExpanderBase
+	httpClient *http.Client
+	armClient  *armclient.Client
+
+	clientsLock sync.Mutex
+	clients     map[string]*messagingNamespaceClient
+}
+
+func (e *EventHubsExpander) setClient(c *armclient.Client) {
+	e.armClient = c
+	e.clientsLock.Lock()
+	e.clients = map[string]*messagingNamespaceClient{}
+	e.clientsLock.Unlock()
+}
+
+// Name returns the name of the expander
+func (e *EventHubsExpander) Name() string {
+	return "EventHubsExpander"
+}
+
+// DoesExpand checks if this is an Event Hubs namespace
+func (e *EventHubsExpander) DoesExpand(ctx context.Context, currentItem *TreeNode) (bool, error) {
+	swaggerResourceType := currentItem.SwaggerResourceType
+	if currentItem.ItemType == ResourceType && swaggerResourceType != nil {
+		if swaggerResourceType.Endpoint.TemplateURL == eventHubsTemplateURL {
+			return true, nil
+		}
+	}
+	if currentItem.Namespace == eventHubsNamespace {
+		return true, nil
+	}
+	return false, nil
+}
+
+// Expand returns the event hubs and partitions in the namespace
+func (e *EventHubsExpander) Expand(ctx context.Context, currentItem *TreeNode) ExpanderResult {
+
+	swaggerResourceType := currentItem.SwaggerResourceType
+	if currentItem.Namespace != eventHubsNamespace &&
+		swaggerResourceType != nil &&
+		swaggerResourceType.Endpoint.TemplateURL == eventHubsTemplateURL {
+		newItems := []*TreeNode{}
+		newItems = append(newItems, &TreeNode{
+			Parentid:  currentItem.ID,
+			ID:        currentItem.ID + "/<explorer>",
+			Namespace: eventHubsNamespace,
+			Name:      "Event Hubs Explorer",
+			Display:   "Event Hubs Explorer",
+			ItemType:  SubResourceType,
+			ExpandURL: ExpandURLNotSupported,
+			Metadata: map[string]string{
+				"NamespaceID":           currentItem.ID,
+				"SuppressSwaggerExpand": "true",
+				"SuppressGenericExpand": "true",
+			},
+		})
+
+		return ExpanderResult{
+			Err:               nil,
+			Response:          ExpanderResponse{Response: ""}, // Swagger expander will supply the response
+			SourceDescription: "EventHubsExpander request",
+			Nodes:             newItems,
+			IsPrimaryResponse: false,
+		}
+	}
+
+	switch currentItem.ItemType {
+	case SubResourceType:
+		return e.expandEventHubs(ctx, currentItem)
+	case eventHubsHubType:
+		return e.expandPartitions(ctx, currentItem)
+	case eventHubsPartitionType:
+		return e.expandPartition(ctx, currentItem)
+	}
+
+	return ExpanderResult{
+		Err:               fmt.Errorf("Error - unhandled Expand"),
+		Response:          ExpanderResponse{Response: "Error!"},
+		SourceDescription: "EventHubsExpander request",
+	}
+}
+
+func (e *EventHubsExpander) getNamespaceClient(ctx context.Context, namespaceID string) (*messagingNamespaceClient, error) {
+	e.clientsLock.Lock()
+	defer e.clientsLock.Unlock()
+
+	if client, ok := e.clients[namespaceID]; ok {
+		return client, nil
+	}
+	client, err := newMessagingNamespaceClient(ctx, e.httpClient, e.armClient, namespaceID, eventHubsResource)
+	if err != nil {
+		return nil, err
+	}
+	e.clients[namespaceID] = client
+	return client, nil
+}
+
+func (e *EventHubsExpander) expandEventHubs(ctx context.Context, currentItem *TreeNode) ExpanderResult {
+	listID := currentItem.Metadata["NamespaceID"] + "/eventhubs"
+	data, err := e.armClient.DoRequest(ctx, "GET", listID+"?api-version="+messagingAPIVersion)
+	if err != nil {
+		return e.errorResult(err)
+	}
+
+	newItems := []*TreeNode{}
+	nextData := data
+	for nextData != "" {
+		var response eventHubsListResponse
+		err = json.Unmarshal([]byte(nextData), &response)
+		if err != nil {
+			return e.errorResult(fmt.Errorf("Error unmarshalling response: %s\nURL:%s", err, listID))
+		}
+
+		for _, hub := range response.Value {
+			display := hub.Name + " " + style.Subtle(fmt.Sprintf("(partitions: %d)", hub.Properties.PartitionCount))
+			node := e.newNode(currentItem, hub.Name, display, eventHubsHubType, map[string]string{
+				"EventHub": hub.Name,
+			})
+			if hub.Properties.Status != "Active" {
+				node.StatusIndicator = DrawStatus(hub.Properties.Status)
+			}
+			newItems = append(newItems, node)
+		}
+
+		nextData = ""
+		if response.NextLink != "" {
+			nextData, err = e.armClient.DoRequest(ctx, "GET", response.NextLink)
+			if err != nil {
+				return e.errorResult(err)
+			}
+		}
+	}
+
+	return ExpanderResult{
+		Response:          ExpanderResponse{Response: data, ResponseType: ResponseJSON},
+		SourceDescription: "EventHubsExpander request",
+		Nodes:             newItems,
+		IsPrimaryResponse: true,
+	}
+}
+
+// expandPartitions lists the partitions in an event hub with their sequence numbers and offsets
+func (e *EventHubsExpander) expandPartitions(ctx context.Context, currentItem *TreeNode) ExpanderResult {
+	client, err := e.getNamespaceClient(ctx, currentItem.Metadata["NamespaceID"])
+	if err != nil {
+		return e.errorResult(err)
+	}
+
+	eventHub := currentItem.Metadata["EventHub"]
+	buf, _, err := client.doRequest(ctx, "GET", url.PathEscape(eventHub)+"/"+eventHubsPartitionsPath+"?api-version="+eventHubsAPIVersion, nil, nil)
+	if err != nil {
+		return e.errorResult(err)
+	}
+	var feed eventHubsPartitionFeed
+	err = xml.Unmarshal(buf, &feed)
+	if err != nil {
+		return e.errorResult(fmt.Errorf("Error unmarshalling partitions: %s", err))
+	}
+
+	partitions := []eventHubsPartition{}
+	newItems := []*TreeNode{}
+	for _, entry := range feed.Entries {
+		partition := entry.Partition
+		partition.PartitionID = entry.Title
+		partitions = append(partitions, partition)
+
+		display := "Partition " + partition.PartitionID + " " + style.Subtle(fmt.Sprintf("(seq: %d-%d, last offset: %s)",
+			partition.BeginSequenceNumber, partition.EndSequenceNumber, partition.LastEnqueuedOffset))
+		newItems = append(newItems, e.newNode(currentItem, partition.PartitionID, display, eventHubsPartitionType, map[string]string{
+			"EventHub":    eventHub,
+			"PartitionID": partition.PartitionID,
+		}))
+	}
+
+	return e.jsonResult(partitions, newItems)
+}
+
+func (e *EventHubsExpander) expandPartition(ctx context.Context, currentItem *TreeNode) ExpanderResult {
+	client, err := e.getNamespaceClient(ctx, currentItem.Metadata["NamespaceID"])
+	if err != nil {
+		return e.errorResult(err)
+	}
+
+	partitionID := currentItem.Metadata["PartitionID"]
+	path := fmt.Sprintf("%s/%s/%s?api-version=%s", url.PathEscape(currentItem.Metadata["EventHub"]), eventHubsPartitionsPath, url.PathEscape(partitionID), eventHubsAPIVersion)
+	buf, _, err := client.doRequest(ctx, "GET", path, nil, nil)
+	if err != nil {
+		return e.errorResult(err)
+	}
+	var entry struct {
+		Partition eventHubsPartition `xml:"content>PartitionDescription"`
+	}
+	err = xml.Unmarshal(buf, &entry)
+	if err != nil {
+		return e.errorResult(fmt.Errorf("Error unmarshalling partition: %s", err))
+	}
+	entry.Partition.PartitionID = partitionID
+
+	return e.jsonResult(entry.Partition, []*TreeNode{})
+}
+
+// newNode creates a child node, carrying the namespace metadata from the parent
+func (e *EventHubsExpander) newNode(currentItem *TreeNode, name string, display string, itemType string, metadata map[string]string) *TreeNode {
+	nodeMetadata := map[string]string{
+		"NamespaceID":           currentItem.Metadata["NamespaceID"],
+		"SuppressSwaggerExpand": "true",
+		"SuppressGenericExpand": "true",
+	}
+	for key, value := range metadata {
+		nodeMetadata[key] = value
+	}
+	return &TreeNode{
+		Parentid:  currentItem.ID,
+		ID:        currentItem.ID + "/" + name,
+		Namespace: eventHubsNamespace,
+		Name:      name,
+		Display:   display,
+		ItemType:  itemType,
+		ExpandURL: ExpandURLNotSupported,
+		Metadata:  nodeMetadata,
+	}
+}
+
+func (e *EventHubsExpander) jsonResult(response interface{}, nodes []*TreeNode) ExpanderResult {
+	buf, err := json.Marshal(response)
+	if err != nil {
+		return e.errorResult(err)
+	}
+	return ExpanderResult{
+		Response:          ExpanderResponse{Response: string(buf), ResponseType: ResponseJSON},
+		SourceDescription: "EventHubsExpander request",
+		Nodes:             nodes,
+		IsPrimaryResponse: true,
+	}
+}
+
+func (e *EventHubsExpander) errorResult(err error) ExpanderResult {
+	return ExpanderResult{
+		Err:               err,
+		SourceDescription: "EventHubsExpander request",
+	}
+}
+
+func (e *EventHubsExpander) testCases() (bool, *[]expanderTestCase) {
+	const namespaceID = "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/stable/providers/Microsoft.EventHub/namespaces/testhubs"
+
+	gockConfig := func(t *testing.T) {
+		gock.New("https://management.azure.com").
+			Get(namespaceID).
+			Reply(200).
+			JSON(`{"properties": {"serviceBusEndpoint": "https://testhubs.servicebus.windows.net:443/"}}`)
+		gock.New("https://management.azure.com").
+			Post(namespaceID + "/AuthorizationRules/RootManageSharedAccessKey/listKeys").
+			Reply(200).
+			JSON(`{"keyName": "RootManageSharedAccessKey", "primaryKey": "dGVzdGtleQ=="}`)
+		gock.New("https://testhubs.servicebus.windows.net").
+			Get("/telemetry/consumergroups/\\$Default/partitions").
+			MatchHeader("Authorization", "^SharedAccessSignature sr=").
+			Reply(200).
+			File("./testdata/armsamples/eventhubs/partitions.xml")
+	}
+
+	return true, &[]expanderTestCase{
+		{
+			name: "EventHub->Partitions",
+			nodeToExpand: &TreeNode{
+				ID:        namespaceID + "/<explorer>/telemetry",
+				Namespace: eventHubsNamespace,
+				ItemType:  eventHubsHubType,
+				ExpandURL: ExpandURLNotSupported,
+				Metadata: map[string]string{
+					"NamespaceID": namespaceID,
+					"EventHub":    "telemetry",
+				},
+			},
+			configureGockFunc: &gockConfig,
+			treeNodeCheckerFunc: func(t *testing.T, r ExpanderResult) {
+				st.Expect(t, r.Err, nil)
+				st.Expect(t, len(r.Nodes), 2)
+
+				st.Expect(t, r.Nodes[0].Name, "0")
+				st.Expect(t, r.Nodes[0].Display, "Partition 0 "+style.Subtle("(seq: 100-250, last offset: 52428)"))
+				st.Expect(t, r.Nodes[1].Name, "1")
+				st.Expect(t, r.Nodes[1].Metadata["PartitionID"], "1")
+
+				var partitions []eventHubsPartition
+				err := json.Unmarshal([]byte(r.Response.Response), &partitions)
+				st.Expect(t, err, nil)
+				st.Expect(t, partitions[1].EndSequenceNumber, int64(-1))
+			},
+		},
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/lawrencegripper/azbrowse/internal/pkg/errorhandling"
 	"github.com/lawrencegripper/azbrowse/internal/pkg/eventing"
 	"github.com/lawrencegripper/azbrowse/internal/pkg/tracing"
+	"github.com/lawrencegripper/azbrowse/pkg/armclient"
 )
 
 type expanderAndResponse struct {
@@ -15,9 +16,51 @@ type expanderAndResponse struct {
 	ExpanderResult ExpanderResult
 }
 
+// WithNodeTenant returns a context which makes requests against the tenant the node belongs to
+func WithNodeTenant(ctx context.Context, node *TreeNode) context.Context {
+	if node == nil || node.TenantID == "" {
+		return ctx
+	}
+	return armclient.WithTenantID(ctx, node.TenantID)
+}
+
+// DeleteHandledByExpander reports whether the node is deleted by its Expander rather than through a DeleteURL.
+// Nodes set "DeleteHandledByExpander" in their Metadata when the delete isn't a request to a URL (e.g. storage blobs)
+func DeleteHandledByExpander(node *TreeNode) bool {
+	return node.Metadata["DeleteHandledByExpander"] == "true"
+}
+
+// ListActions finds the actions that the registered expanders provide for the item
+func ListActions(ctx context.Context, currentItem *TreeNode) ([]*TreeNode, error) {
+	ctx = WithNodeTenant(ctx, currentItem)
+	actions := []*TreeNode{}
+	for _, h := range getRegisteredExpanders() {
+		hasActions, err := h.HasActions(ctx, currentItem)
+		if err != nil {
+			return []*TreeNode{}, err
+		}
+		if !hasActions {
+			continue
+		}
+		result := h.ListActions(ctx, currentItem)
+		if result.Err != nil {
+			return []*TreeNode{}, fmt.Errorf("Expander '%s' failed listing actions: %s", result.SourceDescription, result.Err)
+		}
+		for _, node := range result.Nodes {
+			node.Expander = h
+			if node.TenantID == "" {
+				node.TenantID = currentItem.TenantID
+			}
+		}
+		actions = append(actions, result.Nodes...)
+	}
+	return actions, nil
+}
+
 // ExpandItem finds child nodes of the item and their content
 func ExpandItem(ctx context.Context, currentItem *TreeNode) (*ExpanderResponse, []*TreeNode, error) {
 	newItems := []*TreeNode{}
+	ctx = WithNodeTenant(ctx, currentItem)
 
 	_, done := eventing.SendStatusEvent(&eventing.StatusEvent{
 		Message:    "Opening: " + currentItem.ID,
@@ -94,6 +137,10 @@ func ExpandItem(ctx context.Context, currentItem *TreeNode) (*ExpanderResponse,
 			}
 			for _, node := range result.Nodes {
 				node.Expander = done.Expander
+				// Child nodes live in the same tenant as their parent
+				if node.TenantID == "" {
+					node.TenantID = currentItem.TenantID
+				}
 			}
 			// Add the items it found
 			if result.IsPrimaryResponse {
@@ -0,0 +1,113 @@
+package expanders
+
+import (
+	"github.com/lawrencegripper/azbrowse/pkg/endpoints"
+	"github.com/lawrencegripper/azbrowse/pkg/swagger"
+)
+
+// There is no data-plane spec for Key Vault in swagger-specs so the
+// resource types are declared here in the same shape as the generated code
+const keyVaultAPIVersion = "7.1"
+
+func (e *KeyVaultExpander) loadResourceTypes() []swagger.ResourceType {
+	return []swagger.ResourceType{
+		{
+			Display:  "secrets",
+			Endpoint: endpoints.MustGetEndpointInfoFromURL("/secrets", keyVaultAPIVersion),
+			SubResources: []swagger.ResourceType{
+				{
+					Display:        "{secretName}",
+					Endpoint:       endpoints.MustGetEndpointInfoFromURL("/secrets/{secretName}", keyVaultAPIVersion),
+					DeleteEndpoint: endpoints.MustGetEndpointInfoFromURL("/secrets/{secretName}", keyVaultAPIVersion),
+					PutEndpoint:    endpoints.MustGetEndpointInfoFromURL("/secrets/{secretName}", keyVaultAPIVersion),
+					Children: []swagger.ResourceType{
+						{
+							Display:  "versions",
+							Endpoint: endpoints.MustGetEndpointInfoFromURL("/secrets/{secretName}/versions", keyVaultAPIVersion),
+							SubResources: []swagger.ResourceType{
+								{
+									Display:     "{secretVersion}",
+									Endpoint:    endpoints.MustGetEndpointInfoFromURL("/secrets/{secretName}/{secretVersion}", keyVaultAPIVersion),
+									PutEndpoint: endpoints.MustGetEndpointInfoFromURL("/secrets/{secretName}/{secretVersion}", keyVaultAPIVersion),
+								}},
+						}},
+				}},
+		},
+		{
+			Display:  "keys",
+			Endpoint: endpoints.MustGetEndpointInfoFromURL("/keys", keyVaultAPIVersion),
+			SubResources: []swagger.ResourceType{
+				{
+					Display:        "{keyName}",
+					Endpoint:       endpoints.MustGetEndpointInfoFromURL("/keys/{keyName}", keyVaultAPIVersion),
+					DeleteEndpoint: endpoints.MustGetEndpointInfoFromURL("/keys/{keyName}", keyVaultAPIVersion),
+					Children: []swagger.ResourceType{
+						{
+							Display:  "versions",
+							Endpoint: endpoints.MustGetEndpointInfoFromURL("/keys/{keyName}/versions", keyVaultAPIVersion),
+							SubResources: []swagger.ResourceType{
+								{
+									Display:     "{keyVersion}",
+									Endpoint:    endpoints.MustGetEndpointInfoFromURL("/keys/{keyName}/{keyVersion}", keyVaultAPIVersion),
+									PutEndpoint: endpoints.MustGetEndpointInfoFromURL("/keys/{keyName}/{keyVersion}", keyVaultAPIVersion),
+								}},
+						}},
+				}},
+		},
+		{
+			Display:  "certificates",
+			Endpoint: endpoints.MustGetEndpointInfoFromURL("/certificates", keyVaultAPIVersion),
+			SubResources: []swagger.ResourceType{
+				{
+					Display:        "{certificateName}",
+					Endpoint:       endpoints.MustGetEndpointInfoFromURL("/certificates/{certificateName}", keyVaultAPIVersion),
+					DeleteEndpoint: endpoints.MustGetEndpointInfoFromURL("/certificates/{certificateName}", keyVaultAPIVersion),
+					Children: []swagger.ResourceType{
+						{
+							Display:  "policy",
+							Endpoint: endpoints.MustGetEndpointInfoFromURL("/certificates/{certificateName}/policy", keyVaultAPIVersion),
+						},
+						{
+							Display:  "versions",
+							Endpoint: endpoints.MustGetEndpointInfoFromURL("/certificates/{certificateName}/versions", keyVaultAPIVersion),
+							SubResources: []swagger.ResourceType{
+								{
+									Display:     "{certificateVersion}",
+									Endpoint:    endpoints.MustGetEndpointInfoFromURL("/certificates/{certificateName}/{certificateVersion}", keyVaultAPIVersion),
+									PutEndpoint: endpoints.MustGetEndpointInfoFromURL("/certificates/{certificateName}/{certificateVersion}", keyVaultAPIVersion),
+								}},
+						}},
+				}},
+		},
+		{
+			Display:  "deletedsecrets",
+			Endpoint: endpoints.MustGetEndpointInfoFromURL("/deletedsecrets", keyVaultAPIVersion),
+			SubResources: []swagger.ResourceType{
+				{
+					Display:        "{secretName}",
+					Endpoint:       endpoints.MustGetEndpointInfoFromURL("/deletedsecrets/{secretName}", keyVaultAPIVersion),
+					DeleteEndpoint: endpoints.MustGetEndpointInfoFromURL("/deletedsecrets/{secretName}", keyVaultAPIVersion),
+				}},
+		},
+		{
+			Display:  "deletedkeys",
+			Endpoint: endpoints.MustGetEndpointInfoFromURL("/deletedkeys", keyVaultAPIVersion),
+			SubResources: []swagger.ResourceType{
+				{
+					Display:        "{keyName}",
+					Endpoint:       endpoints.MustGetEndpointInfoFromURL("/deletedkeys/{keyName}", keyVaultAPIVersion),
+					DeleteEndpoint: endpoints.MustGetEndpointInfoFromURL("/deletedkeys/{keyName}", keyVaultAPIVersion),
+				}},
+		},
+		{
+			Display:  "deletedcertificates",
+			Endpoint: endpoints.MustGetEndpointInfoFromURL("/deletedcertificates", keyVaultAPIVersion),
+			SubResources: []swagger.ResourceType{
+				{
+					Display:        "{certificateName}",
+					Endpoint:       endpoints.MustGetEndpointInfoFromURL("/deletedcertificates/{certificateName}", keyVaultAPIVersion),
+					DeleteEndpoint: endpoints.MustGetEndpointInfoFromURL("/deletedcertificates/{certificateName}", keyVaultAPIVersion),
+				}},
+		},
+	}
+}
@@ -0,0 +1,394 @@
+package expanders
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/lawrencegripper/azbrowse/internal/pkg/style"
+	"github.com/lawrencegripper/azbrowse/pkg/armclient"
+	"github.com/lawrencegripper/azbrowse/pkg/swagger"
+)
+
+const (
+	keyVaultResource     = "https://vault.azure.net"
+	keyVaultMaskedValue  = "<masked: use the 'Reveal secret value' action to show the value>"
+	keyVaultExpiryWindow = 30 * 24 * time.Hour
+)
+
+type keyVaultListResponse struct {
+	Value    []json.RawMessage `json:"value"`
+	NextLink string            `json:"nextLink"`
+}
+
+type keyVaultItem struct {
+	ID                 string             `json:"id"`
+	Kid                string             `json:"kid"`
+	RecoveryID         string             `json:"recoveryId"`
+	ContentType        string             `json:"contentType"`
+	Attributes         keyVaultAttributes `json:"attributes"`
+	DeletedDate        int64              `json:"deletedDate"`
+	ScheduledPurgeDate int64              `json:"scheduledPurgeDate"`
+}
+
+type keyVaultAttributes struct {
+	Enabled   *bool  `json:"enabled"`
+	Expires   *int64 `json:"exp"`
+	NotBefore *int64 `json:"nbf"`
+	Created   int64  `json:"created"`
+	Updated   int64  `json:"updated"`
+}
+
+var _ SwaggerAPISet = SwaggerAPISetKeyVault{}
+
+// SwaggerAPISetKeyVault holds the config for working with the data-plane of a Key Vault
+type SwaggerAPISetKeyVault struct {
+	resourceTypes []swagger.ResourceType
+	httpClient    http.Client
+	armClient     *armclient.Client
+	vaultID       string // ARM resource ID for the vault (/subscriptions/....)
+	vaultURI      string // https://<name>.vault.azure.net
+}
+
+// NewSwaggerAPISetKeyVault creates a new SwaggerAPISetKeyVault
+func NewSwaggerAPISetKeyVault(resourceTypes []swagger.ResourceType, armClient *armclient.Client, vaultID string, vaultURI string) SwaggerAPISetKeyVault {
+	c := SwaggerAPISetKeyVault{}
+	c.resourceTypes = resourceTypes
+	c.httpClient = http.Client{}
+	c.armClient = armClient
+	c.vaultID = vaultID
+	c.vaultURI = strings.TrimSuffix(vaultURI, "/")
+	return c
+}
+
+// ID returns the ID for the APISet
+func (c SwaggerAPISetKeyVault) ID() string {
+	return c.vaultID
+}
+
+// MatchChildNodesByName indicates whether child nodes should be matched by name (or position)
+func (c SwaggerAPISetKeyVault) MatchChildNodesByName() bool {
+	return true
+}
+
+// AppliesToNode is called by the Swagger exapnder to test whether the node applies to this APISet
+func (c SwaggerAPISetKeyVault) AppliesToNode(node *TreeNode) bool {
+	// this function is only called for nodes that don't have the SwaggerAPISetID set
+	// this should never happen for key vault nodes
+	return false
+}
+
+// GetResourceTypes returns the ResourceTypes for the API Set
+func (c SwaggerAPISetKeyVault) GetResourceTypes() []swagger.ResourceType {
+	return c.resourceTypes
+}
+
+// DoRequest makes a request against the vault endpoint
+func (c SwaggerAPISetKeyVault) DoRequest(ctx context.Context, verb string, url string) (string, error) {
+	return c.DoRequestWithBody(ctx, verb, url, "")
+}
+
+// DoRequestWithBody makes a request against the vault endpoint
+func (c SwaggerAPISetKeyVault) DoRequestWithBody(ctx context.Context, verb string, url string, body string) (string, error) {
+	if !strings.HasPrefix(url, "https://") {
+		url = c.vaultURI + url
+	}
+
+	response, err := c.doRequestWithToken(ctx, verb, url, body, false)
+	if err == nil && response.StatusCode == http.StatusUnauthorized {
+		// The cached token may have expired so retry with a fresh token
+		response.Body.Close() //nolint: errcheck
+		response, err = c.doRequestWithToken(ctx, verb, url, body, true)
+	}
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close() //nolint: errcheck
+	buf, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		err = fmt.Errorf("Failed to read body: %s", err)
+		return "", err
+	}
+	data := string(buf)
+	if 200 <= response.StatusCode && response.StatusCode < 300 {
+		return data, nil
+	}
+	return "", fmt.Errorf("Response failed with %s (%s): %s", response.Status, url, data)
+}
+
+func (c SwaggerAPISetKeyVault) doRequestWithToken(ctx context.Context, verb string, url string, body string, refreshToken bool) (*http.Response, error) {
+	var token armclient.AzCLIToken
+	var err error
+	if refreshToken {
+		token, err = c.armClient.RefreshTokenForResource(ctx, keyVaultResource)
+	} else {
+		token, err = c.armClient.GetTokenForResource(ctx, keyVaultResource)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get Key Vault token: %s", err)
+	}
+
+	request, err := http.NewRequest(verb, url, bytes.NewReader([]byte(body)))
+	if err != nil {
+		err = fmt.Errorf("Failed to create request" + err.Error() + url)
+		return nil, err
+	}
+	request.Header.Set("Authorization", token.TokenType+" "+token.AccessToken)
+	request.Header.Set("Content-Type", "application/json")
+
+	response, err := c.httpClient.Do(request.WithContext(ctx))
+	if err != nil {
+		err = fmt.Errorf("Failed" + err.Error() + url)
+		return nil, err
+	}
+	return response, nil
+}
+
+// ExpandResource returns metadata about child resources of the specified resource node
+func (c SwaggerAPISetKeyVault) ExpandResource(ctx context.Context, currentItem *TreeNode, resourceType swagger.ResourceType) (APISetExpandResponse, error) {
+
+	data, err := c.DoRequest(ctx, "GET", currentItem.ExpandURL)
+	if err != nil {
+		err = fmt.Errorf("Failed to make request: %s", err)
+		return APISetExpandResponse{}, err
+	}
+
+	if len(resourceType.SubResources) == 0 {
+		// Single item - secret values are only shown via the reveal action
+		return APISetExpandResponse{
+			Response:     maskKeyVaultSecretValue(data),
+			ResponseType: ResponseJSON,
+		}, nil
+	}
+
+	if len(resourceType.SubResources) > 1 {
+		return APISetExpandResponse{}, fmt.Errorf("Only expecting a single SubResource type")
+	}
+
+	items, err := c.getAllPages(ctx, data)
+	if err != nil {
+		return APISetExpandResponse{Response: data, ResponseType: ResponseJSON}, err
+	}
+
+	subResourceType := resourceType.SubResources[0]
+	subResourceEndpoint := subResourceType.Endpoint
+	newTemplateName := subResourceEndpoint.URLSegments[len(subResourceEndpoint.URLSegments)-1].Name
+	templateValues := resourceType.Endpoint.Match(currentItem.ExpandURL).Values
+
+	now := time.Now()
+	subResources := []SubResource{}
+	for _, itemJSON := range items {
+		var item keyVaultItem
+		err = json.Unmarshal(itemJSON, &item)
+		if err != nil {
+			return APISetExpandResponse{Response: data, ResponseType: ResponseJSON}, fmt.Errorf("Error parsing item: %s", err)
+		}
+
+		name := item.getName()
+		templateValues[newTemplateName] = name
+		subResourceURL, err := subResourceEndpoint.BuildURL(templateValues)
+		if err != nil {
+			return APISetExpandResponse{}, fmt.Errorf("Error building subresource URL: %s", err)
+		}
+		deleteURL := ""
+		if subResourceType.DeleteEndpoint != nil {
+			deleteURL, err = subResourceType.DeleteEndpoint.BuildURL(templateValues)
+			if err != nil {
+				return APISetExpandResponse{}, fmt.Errorf("Error building subresource delete url '%s': %s", subResourceType.DeleteEndpoint.TemplateURL, err)
+			}
+		}
+
+		subResources = append(subResources, SubResource{
+			ID:              c.vaultID + subResourceURL,
+			Name:            name,
+			Display:         name + "\n  " + style.Subtle(item.describe()),
+			StatusIndicator: item.Attributes.drawStatus(now),
+			ResourceType:    subResourceType,
+			ExpandURL:       subResourceURL,
+			DeleteURL:       deleteURL,
+		})
+	}
+
+	// Return all pages as a single response
+	allItems, err := json.Marshal(keyVaultListResponse{Value: items})
+	if err != nil {
+		return APISetExpandResponse{}, fmt.Errorf("Error marshalling response: %s", err)
+	}
+
+	return APISetExpandResponse{
+		Response:     string(allItems),
+		ResponseType: ResponseJSON,
+		SubResources: subResources,
+	}, nil
+}
+
+// getAllPages follows the nextLink values in a list response to get all the items
+func (c SwaggerAPISetKeyVault) getAllPages(ctx context.Context, data string) ([]json.RawMessage, error) {
+	items := []json.RawMessage{}
+	for {
+		var listResponse keyVaultListResponse
+		err := json.Unmarshal([]byte(data), &listResponse)
+		if err != nil {
+			return []json.RawMessage{}, fmt.Errorf("Error parsing response: %s", err)
+		}
+		items = append(items, listResponse.Value...)
+		if listResponse.NextLink == "" {
+			return items, nil
+		}
+		data, err = c.DoRequest(ctx, "GET", listResponse.NextLink)
+		if err != nil {
+			return []json.RawMessage{}, fmt.Errorf("Failed to get next page: %s", err)
+		}
+	}
+}
+
+// getName returns the last segment of the item ID, i.e. the name or version
+func (i keyVaultItem) getName() string {
+	id := i.RecoveryID // deleted items
+	if id == "" {
+		id = i.Kid // keys
+	}
+	if id == "" {
+		id = i.ID
+	}
+	id = strings.TrimSuffix(id, "/")
+	return id[strings.LastIndex(id, "/")+1:]
+}
+
+func (i keyVaultItem) describe() string {
+	if i.DeletedDate != 0 {
+		return "Deleted: " + formatUnixTime(i.DeletedDate) + " Purge: " + formatUnixTime(i.ScheduledPurgeDate)
+	}
+	description := "Enabled: "
+	if i.Attributes.Enabled == nil || *i.Attributes.Enabled {
+		description += "true"
+	} else {
+		description += "false"
+	}
+	if i.Attributes.Expires != nil {
+		description += " Expires: " + formatUnixTime(*i.Attributes.Expires)
+	}
+	return description
+}
+
+// drawStatus returns an indicator for disabled, expired and soon to expire items
+func (a keyVaultAttributes) drawStatus(now time.Time) string {
+	if a.Enabled != nil && !*a.Enabled {
+		return "⛔"
+	}
+	if a.Expires == nil {
+		return ""
+	}
+	expires := time.Unix(*a.Expires, 0)
+	if expires.Before(now) {
+		return "⛈"
+	}
+	if expires.Before(now.Add(keyVaultExpiryWindow)) {
+		return "⌛"
+	}
+	return ""
+}
+
+func formatUnixTime(t int64) string {
+	return time.Unix(t, 0).UTC().Format("2006-01-02")
+}
+
+// maskKeyVaultSecretValue replaces the value in a secret bundle
+func maskKeyVaultSecretValue(data string) string {
+	var bundle map[string]interface{}
+	err := json.Unmarshal([]byte(data), &bundle)
+	if err != nil {
+		return data
+	}
+	if _, ok := bundle["value"]; !ok {
+		return data
+	}
+	bundle["value"] = keyVaultMaskedValue
+	// don't escape the <> in the masked value
+	var masked bytes.Buffer
+	encoder := json.NewEncoder(&masked)
+	encoder.SetEscapeHTML(false)
+	err = encoder.Encode(bundle)
+	if err != nil {
+		return data
+	}
+	return strings.TrimSuffix(masked.String(), "\n")
+}
+
+// Delete attempts to delete the item. Returns true if deleted, false if not handled, an error if an error occurred attempting to delete
+// Deleting a secret, key or certificate performs a soft-delete, deleting a deleted item purges it
+func (c SwaggerAPISetKeyVault) Delete(ctx context.Context, item *TreeNode) (bool, error) {
+	if item.DeleteURL == "" {
+		return false, fmt.Errorf("Item cannot be deleted (No DeleteURL)")
+	}
+
+	_, err := c.DoRequest(ctx, "DELETE", item.DeleteURL)
+	if err != nil {
+		err = fmt.Errorf("Failed to delete: %s (%s)", err.Error(), item.DeleteURL)
+		return false, err
+	}
+	return true, nil
+}
+
+// Update attempts to update the specified item with new content
+// Updating a secret creates a new version with the updated value,
+// updating a version updates its attributes (e.g. enabled, exp, contentType and tags)
+func (c SwaggerAPISetKeyVault) Update(ctx context.Context, item *TreeNode, content string) error {
+	matchResult := item.SwaggerResourceType.Endpoint.Match(item.ExpandURL)
+	if !matchResult.IsMatch {
+		return fmt.Errorf("item.ExpandURL didn't match current Endpoint")
+	}
+
+	url, err := item.SwaggerResourceType.PutEndpoint.BuildURL(matchResult.Values)
+	if err != nil {
+		return fmt.Errorf("Error building PUT url: %s", err)
+	}
+
+	var bundle map[string]interface{}
+	err = json.Unmarshal([]byte(content), &bundle)
+	if err != nil {
+		return fmt.Errorf("Error parsing content: %s", err)
+	}
+
+	verb := "PATCH"
+	allowedProperties := []string{"attributes", "contentType", "tags"}
+	if item.SwaggerResourceType.Endpoint.TemplateURL == "/secrets/{secretName}" {
+		if bundle["value"] == keyVaultMaskedValue {
+			return fmt.Errorf("Set a new value for the secret to create a new version")
+		}
+		verb = "PUT"
+		allowedProperties = append(allowedProperties, "value")
+	}
+
+	// Only send the properties which can be set (e.g. attributes.created is read-only)
+	body := map[string]interface{}{}
+	for _, property := range allowedProperties {
+		if value, ok := bundle[property]; ok {
+			body[property] = value
+		}
+	}
+	if attributes, ok := body["attributes"].(map[string]interface{}); ok {
+		updatableAttributes := map[string]interface{}{}
+		for _, attribute := range []string{"enabled", "exp", "nbf"} {
+			if value, ok := attributes[attribute]; ok {
+				updatableAttributes[attribute] = value
+			}
+		}
+		body["attributes"] = updatableAttributes
+	}
+	bodyBytes, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("Error marshalling update: %s", err)
+	}
+
+	_, err = c.DoRequestWithBody(ctx, verb, url, string(bodyBytes))
+	if err != nil {
+		return fmt.Errorf("Error from %s: %s", verb, err)
+	}
+	return nil
+}
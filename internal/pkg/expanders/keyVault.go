@@ -0,0 +1,553 @@
+package expanders
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lawrencegripper/azbrowse/internal/pkg/eventing"
+	"github.com/lawrencegripper/azbrowse/pkg/armclient"
+	"github.com/lawrencegripper/azbrowse/pkg/swagger"
+	"github.com/nbio/st"
+	"gopkg.in/h2non/gock.v1"
+)
+
+const keyVaultTemplateURL string = "/subscriptions/{subscriptionId}/resourceGroups/{resourceGroupName}/providers/Microsoft.KeyVault/vaults/{vaultName}"
+
+type keyVaultResponse struct {
+	Properties struct {
+		VaultURI string `json:"vaultUri"`
+	} `json:"properties"`
+}
+
+type keyVaultAction struct {
+	id      string
+	display string
+}
+
+var (
+	keyVaultActionReveal     = keyVaultAction{id: "reveal", display: "Reveal secret value"}
+	keyVaultActionNewVersion = keyVaultAction{id: "newversion", display: "Create new version"}
+	keyVaultActionEnable     = keyVaultAction{id: "enable", display: "Enable"}
+	keyVaultActionDisable    = keyVaultAction{id: "disable", display: "Disable"}
+	keyVaultActionSoftDelete = keyVaultAction{id: "softdelete", display: "Soft-delete"}
+	keyVaultActionRecover    = keyVaultAction{id: "recover", display: "Recover deleted item"}
+)
+
+// keyVaultActionsByTemplate maps the resource types in a vault to the actions available for them
+var keyVaultActionsByTemplate = map[string][]keyVaultAction{
+	"/secrets/{secretName}":                                {keyVaultActionReveal, keyVaultActionNewVersion, keyVaultActionEnable, keyVaultActionDisable, keyVaultActionSoftDelete},
+	"/secrets/{secretName}/{secretVersion}":                {keyVaultActionReveal, keyVaultActionEnable, keyVaultActionDisable},
+	"/keys/{keyName}":                                      {keyVaultActionNewVersion, keyVaultActionEnable, keyVaultActionDisable, keyVaultActionSoftDelete},
+	"/keys/{keyName}/{keyVersion}":                         {keyVaultActionEnable, keyVaultActionDisable},
+	"/certificates/{certificateName}":                      {keyVaultActionNewVersion, keyVaultActionEnable, keyVaultActionDisable, keyVaultActionSoftDelete},
+	"/certificates/{certificateName}/{certificateVersion}": {keyVaultActionEnable, keyVaultActionDisable},
+	"/deletedsecrets/{secretName}":                         {keyVaultActionRecover},
+	"/deletedkeys/{keyName}":                               {keyVaultActionRecover},
+	"/deletedcertificates/{certificateName}":               {keyVaultActionRecover},
+}
+
+// Check interface
+var _ Expander = &KeyVaultExpander{}
+
+// KeyVaultExpander expands the data-plane aspects of a Key Vault
+type KeyVaultExpander struct {
+	ExpanderBase
+	client *armclient.Client
+}
+
+func (e *KeyVaultExpander) setClient(c *armclient.Client) {
+	e.client = c
+}
+
+// Name returns the name of the expander
+func (e *KeyVaultExpander) Name() string {
+	return "KeyVaultExpander"
+}
+
+// DoesExpand checks if this is a key vault
+func (e *KeyVaultExpander) DoesExpand(ctx context.Context, currentItem *TreeNode) (bool, error) {
+	swaggerResourceType := currentItem.SwaggerResourceType
+	if currentItem.ItemType == ResourceType && swaggerResourceType != nil {
+		if swaggerResourceType.Endpoint.TemplateURL == keyVaultTemplateURL {
+			return true, nil
+		}
+	}
+	if currentItem.Namespace == "keyVault" {
+		return true, nil
+	}
+	return false, nil
+}
+
+// Expand returns the data-plane nodes for the vault
+func (e *KeyVaultExpander) Expand(ctx context.Context, currentItem *TreeNode) ExpanderResult {
+
+	swaggerResourceType := currentItem.SwaggerResourceType
+	if currentItem.Namespace != "keyVault" &&
+		swaggerResourceType != nil &&
+		swaggerResourceType.Endpoint.TemplateURL == keyVaultTemplateURL {
+		newItems := []*TreeNode{}
+		newItems = append(newItems, &TreeNode{
+			ID:        currentItem.ID + "/<vault>",
+			Parentid:  currentItem.ID,
+			Namespace: "keyVault",
+			Name:      "Vault Contents",
+			Display:   "Vault Contents",
+			ItemType:  SubResourceType,
+			ExpandURL: ExpandURLNotSupported,
+			Metadata: map[string]string{
+				"VaultID":               currentItem.ID,
+				"SuppressSwaggerExpand": "true",
+				"SuppressGenericExpand": "true",
+			},
+		})
+
+		return ExpanderResult{
+			Err:               nil,
+			Response:          ExpanderResponse{Response: ""}, // Swagger expander will supply the response
+			SourceDescription: "KeyVaultExpander request",
+			Nodes:             newItems,
+			IsPrimaryResponse: false,
+		}
+	}
+
+	if currentItem.Namespace == "keyVault" && currentItem.ItemType == SubResourceType {
+		return e.expandVaultRoot(ctx, currentItem)
+	}
+	if currentItem.Namespace == "keyVault" && currentItem.ItemType == ActionType {
+		return e.executeAction(ctx, currentItem)
+	}
+
+	return ExpanderResult{
+		Err:               fmt.Errorf("Error - unhandled Expand"),
+		Response:          ExpanderResponse{Response: "Error!"},
+		SourceDescription: "KeyVaultExpander request",
+	}
+}
+
+func (e *KeyVaultExpander) expandVaultRoot(ctx context.Context, currentItem *TreeNode) ExpanderResult {
+
+	vaultID := currentItem.Metadata["VaultID"]
+
+	// Check for existing config for the vault
+	apiSet := e.getAPISetForVault(vaultID)
+	var err error
+	if apiSet == nil {
+		apiSet, err = e.createAPISetForVault(ctx, vaultID)
+		if err != nil {
+			return ExpanderResult{
+				Err:               err,
+				Response:          ExpanderResponse{Response: "Error!"},
+				SourceDescription: "KeyVaultExpander request",
+			}
+		}
+		GetSwaggerResourceExpander().AddAPISet(*apiSet)
+	}
+
+	swaggerResourceTypes := apiSet.GetResourceTypes()
+
+	newItems := []*TreeNode{}
+	for _, child := range swaggerResourceTypes {
+		resourceType := child
+		display := resourceType.Display
+		newItems = append(newItems, &TreeNode{
+			Parentid:            currentItem.ID,
+			ID:                  currentItem.ID + "/" + display,
+			Namespace:           "swagger",
+			Name:                display,
+			Display:             display,
+			ExpandURL:           resourceType.Endpoint.TemplateURL + "?api-version=" + resourceType.Endpoint.APIVersion, // all fixed template URLs
+			ItemType:            SubResourceType,
+			SwaggerResourceType: &resourceType,
+			Metadata: map[string]string{
+				"SwaggerAPISetID": apiSet.ID(),
+			},
+		})
+	}
+
+	return ExpanderResult{
+		Err:               nil,
+		Response:          ExpanderResponse{Response: ""},
+		SourceDescription: "KeyVaultExpander request",
+		Nodes:             newItems,
+		IsPrimaryResponse: true,
+	}
+}
+
+func (e *KeyVaultExpander) createAPISetForVault(ctx context.Context, vaultID string) (*SwaggerAPISetKeyVault, error) {
+	data, err := e.client.DoRequest(ctx, "GET", vaultID+"?api-version=2019-09-01")
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get vault: " + err.Error() + vaultID)
+	}
+
+	var response keyVaultResponse
+	err = json.Unmarshal([]byte(data), &response)
+	if err != nil {
+		return nil, fmt.Errorf("Error unmarshalling response: %s\nURL:%s", err, vaultID)
+	}
+	if response.Properties.VaultURI == "" {
+		return nil, fmt.Errorf("Vault URI lookup failed")
+	}
+
+	// Register the swagger config so that the swagger expander can take over
+	apiSet := NewSwaggerAPISetKeyVault(e.loadResourceTypes(), e.client, vaultID+"/<vault>", response.Properties.VaultURI)
+	return &apiSet, nil
+}
+
+func (e *KeyVaultExpander) getAPISetForVault(vaultID string) *SwaggerAPISetKeyVault {
+	return e.getAPISet(vaultID + "/<vault>")
+}
+
+func (e *KeyVaultExpander) getAPISet(apiSetID string) *SwaggerAPISetKeyVault {
+	swaggerAPISet := GetSwaggerResourceExpander().GetAPISet(apiSetID)
+	if swaggerAPISet == nil {
+		return nil
+	}
+	apiSet, ok := (*swaggerAPISet).(SwaggerAPISetKeyVault)
+	if !ok {
+		return nil
+	}
+	return &apiSet
+}
+
+// HasActions checks if the item is a secret, key or certificate in a vault
+func (e *KeyVaultExpander) HasActions(ctx context.Context, currentItem *TreeNode) (bool, error) {
+	if currentItem.SwaggerResourceType == nil || currentItem.Metadata == nil {
+		return false, nil
+	}
+	if e.getAPISet(currentItem.Metadata["SwaggerAPISetID"]) == nil {
+		return false, nil
+	}
+	_, ok := keyVaultActionsByTemplate[currentItem.SwaggerResourceType.Endpoint.TemplateURL]
+	return ok, nil
+}
+
+// ListActions returns the actions for a secret, key or certificate
+func (e *KeyVaultExpander) ListActions(ctx context.Context, currentItem *TreeNode) ListActionsResult {
+	actions := keyVaultActionsByTemplate[currentItem.SwaggerResourceType.Endpoint.TemplateURL]
+
+	nodes := []*TreeNode{}
+	for _, action := range actions {
+		nodes = append(nodes, &TreeNode{
+			Parentid:  currentItem.ID,
+			ID:        currentItem.ID + "/<action:" + action.id + ">",
+			Namespace: "keyVault",
+			Name:      action.display,
+			Display:   action.display,
+			ItemType:  ActionType,
+			ExpandURL: ExpandURLNotSupported,
+			Metadata: map[string]string{
+				"SwaggerAPISetID":       currentItem.Metadata["SwaggerAPISetID"],
+				"ActionID":              action.id,
+				"ItemURL":               currentItem.ExpandURL,
+				"ItemTemplateURL":       currentItem.SwaggerResourceType.Endpoint.TemplateURL,
+				"SuppressSwaggerExpand": "true",
+				"SuppressGenericExpand": "true",
+			},
+		})
+	}
+
+	return ListActionsResult{
+		Nodes:             nodes,
+		SourceDescription: "KeyVaultExpander request",
+	}
+}
+
+func (e *KeyVaultExpander) executeAction(ctx context.Context, currentItem *TreeNode) ExpanderResult {
+	apiSet := e.getAPISet(currentItem.Metadata["SwaggerAPISetID"])
+	if apiSet == nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Key Vault not found for action"),
+			SourceDescription: "KeyVaultExpander request",
+		}
+	}
+
+	itemURL := currentItem.Metadata["ItemURL"]
+	resourceType := swagger.GetResourceTypeForURL(ctx, currentItem.Metadata["ItemTemplateURL"], apiSet.GetResourceTypes())
+	if resourceType == nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Unable to find resource type for '%s'", itemURL),
+			SourceDescription: "KeyVaultExpander request",
+		}
+	}
+	templateValues := resourceType.Endpoint.Match(itemURL).Values
+
+	status, _ := eventing.SendStatusEvent(&eventing.StatusEvent{
+		InProgress: true,
+		IsToast:    true,
+		Message:    "Action: " + currentItem.Name + " @ " + itemURL,
+	})
+
+	var data string
+	var err error
+	switch currentItem.Metadata["ActionID"] {
+	case keyVaultActionReveal.id:
+		data, err = apiSet.DoRequest(ctx, "GET", itemURL)
+	case keyVaultActionEnable.id:
+		data, err = e.setEnabled(ctx, apiSet, itemURL, true)
+	case keyVaultActionDisable.id:
+		data, err = e.setEnabled(ctx, apiSet, itemURL, false)
+	case keyVaultActionSoftDelete.id:
+		data, err = apiSet.DoRequest(ctx, "DELETE", itemURL)
+	case keyVaultActionRecover.id:
+		url := stripQueryString(itemURL) + "/recover?api-version=" + keyVaultAPIVersion
+		data, err = apiSet.DoRequest(ctx, "POST", url)
+	case keyVaultActionNewVersion.id:
+		if _, isSecret := templateValues["secretName"]; isSecret {
+			data, err = e.createSecretVersion(ctx, apiSet, itemURL)
+		} else if _, isKey := templateValues["keyName"]; isKey {
+			data, err = e.createKeyVersion(ctx, apiSet, itemURL)
+		} else {
+			data, err = e.createCertificateVersion(ctx, apiSet, itemURL)
+		}
+	default:
+		err = fmt.Errorf("Unhandled action: %s", currentItem.Metadata["ActionID"])
+	}
+
+	if err != nil {
+		status.Failure = true
+		status.Message = "Action failed: " + currentItem.Name + ": " + err.Error()
+	} else {
+		status.Message = "Action completed: " + currentItem.Name
+	}
+	status.Done()
+
+	return ExpanderResult{
+		Err:               err,
+		Response:          ExpanderResponse{Response: data, ResponseType: ResponseJSON},
+		SourceDescription: "KeyVaultExpander request",
+		IsPrimaryResponse: true,
+	}
+}
+
+// setEnabled updates the enabled attribute for the latest (or specified) version of an item
+func (e *KeyVaultExpander) setEnabled(ctx context.Context, apiSet *SwaggerAPISetKeyVault, itemURL string, enabled bool) (string, error) {
+	// Get the item to find the version to update
+	data, err := apiSet.DoRequest(ctx, "GET", itemURL)
+	if err != nil {
+		return "", err
+	}
+	value, err := fastJSONParser.Parse(data)
+	if err != nil {
+		return "", fmt.Errorf("Error parsing item: %s", err)
+	}
+	id := string(value.GetStringBytes("id"))
+	if kid := string(value.GetStringBytes("key", "kid")); kid != "" {
+		id = kid
+	}
+	if id == "" {
+		return "", fmt.Errorf("Unable to determine the item version")
+	}
+
+	body := fmt.Sprintf(`{"attributes": {"enabled": %t}}`, enabled)
+	return apiSet.DoRequestWithBody(ctx, "PATCH", id+"?api-version="+keyVaultAPIVersion, body)
+}
+
+// createSecretVersion creates a new version of a secret with the value, content type and tags of the current version.
+// The value can be changed by editing the secret, which also creates a new version
+func (e *KeyVaultExpander) createSecretVersion(ctx context.Context, apiSet *SwaggerAPISetKeyVault, itemURL string) (string, error) {
+	data, err := apiSet.DoRequest(ctx, "GET", itemURL)
+	if err != nil {
+		return "", err
+	}
+	var secretBundle struct {
+		Value       string            `json:"value"`
+		ContentType string            `json:"contentType,omitempty"`
+		Tags        map[string]string `json:"tags,omitempty"`
+	}
+	err = json.Unmarshal([]byte(data), &secretBundle)
+	if err != nil {
+		return "", fmt.Errorf("Error parsing secret: %s", err)
+	}
+	body, err := json.Marshal(secretBundle)
+	if err != nil {
+		return "", fmt.Errorf("Error marshalling request: %s", err)
+	}
+
+	data, err = apiSet.DoRequestWithBody(ctx, "PUT", itemURL, string(body))
+	if err != nil {
+		return "", err
+	}
+	return maskKeyVaultSecretValue(data), nil
+}
+
+// createKeyVersion creates a new version of a key with the same type and size as the current version
+func (e *KeyVaultExpander) createKeyVersion(ctx context.Context, apiSet *SwaggerAPISetKeyVault, itemURL string) (string, error) {
+	data, err := apiSet.DoRequest(ctx, "GET", itemURL)
+	if err != nil {
+		return "", err
+	}
+	var keyBundle struct {
+		Key struct {
+			Kty    string   `json:"kty"`
+			KeyOps []string `json:"key_ops"`
+			Crv    string   `json:"crv"`
+			N      string   `json:"n"`
+		} `json:"key"`
+	}
+	err = json.Unmarshal([]byte(data), &keyBundle)
+	if err != nil {
+		return "", fmt.Errorf("Error parsing key: %s", err)
+	}
+
+	request := map[string]interface{}{
+		"kty":     keyBundle.Key.Kty,
+		"key_ops": keyBundle.Key.KeyOps,
+	}
+	if keyBundle.Key.Crv != "" {
+		request["crv"] = keyBundle.Key.Crv
+	}
+	if keyBundle.Key.N != "" {
+		modulus, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(keyBundle.Key.N, "="))
+		if err == nil {
+			request["key_size"] = len(modulus) * 8
+		}
+	}
+	body, err := json.Marshal(request)
+	if err != nil {
+		return "", fmt.Errorf("Error marshalling request: %s", err)
+	}
+
+	url := stripQueryString(itemURL) + "/create?api-version=" + keyVaultAPIVersion
+	return apiSet.DoRequestWithBody(ctx, "POST", url, string(body))
+}
+
+// createCertificateVersion creates a new version of a certificate using the current policy
+func (e *KeyVaultExpander) createCertificateVersion(ctx context.Context, apiSet *SwaggerAPISetKeyVault, itemURL string) (string, error) {
+	baseURL := stripQueryString(itemURL)
+	policy, err := apiSet.DoRequest(ctx, "GET", baseURL+"/policy?api-version="+keyVaultAPIVersion)
+	if err != nil {
+		return "", err
+	}
+	body := `{"policy": ` + policy + `}`
+	return apiSet.DoRequestWithBody(ctx, "POST", baseURL+"/create?api-version="+keyVaultAPIVersion, body)
+}
+
+func stripQueryString(url string) string {
+	if i := strings.Index(url, "?"); i >= 0 {
+		return url[:i]
+	}
+	return url
+}
+
+func (e *KeyVaultExpander) testCases() (bool, *[]expanderTestCase) {
+	const vaultID = "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/stable/providers/Microsoft.KeyVault/vaults/testvault"
+	const vaultURI = "https://testvault.vault.azure.net"
+
+	now := time.Now()
+	secretsResponse := fmt.Sprintf(`{"value": [
+		{"id": "%[1]s/secrets/disabled", "attributes": {"enabled": false}},
+		{"id": "%[1]s/secrets/expired", "attributes": {"enabled": true, "exp": %[2]d}},
+		{"id": "%[1]s/secrets/expiring", "attributes": {"enabled": true, "exp": %[3]d}},
+		{"id": "%[1]s/secrets/valid", "attributes": {"enabled": true, "exp": %[4]d}}
+	]}`, vaultURI, now.Add(-24*time.Hour).Unix(), now.Add(7*24*time.Hour).Unix(), now.Add(365*24*time.Hour).Unix())
+	secretResponse := `{"value": "s3cret", "contentType": "text/plain", "id": "` + vaultURI + `/secrets/valid/v2", "attributes": {"enabled": true}}`
+
+	vaultGockConfig := func(t *testing.T) {
+		gock.New("https://management.azure.com").
+			Get(vaultID).
+			Reply(200).
+			JSON(`{"properties": {"vaultUri": "` + vaultURI + `/"}}`)
+		gock.New(vaultURI).
+			Get("^/secrets$").
+			MatchHeader("Authorization", "^Bearer testtoken$").
+			Reply(200).
+			JSON(secretsResponse)
+		gock.New(vaultURI).
+			Get("^/secrets/valid$").
+			Reply(200).
+			JSON(secretResponse)
+		gock.New(vaultURI).
+			Get("^/secrets/valid/versions$").
+			Reply(200).
+			JSON(`{"value": [{"id": "` + vaultURI + `/secrets/valid/v1", "attributes": {"enabled": false}}, {"id": "` + vaultURI + `/secrets/valid/v2", "attributes": {"enabled": true}}]}`)
+		// reveal
+		gock.New(vaultURI).
+			Get("^/secrets/valid$").
+			Reply(200).
+			JSON(secretResponse)
+		// new version
+		gock.New(vaultURI).
+			Get("^/secrets/valid$").
+			Reply(200).
+			JSON(secretResponse)
+		gock.New(vaultURI).
+			Put("^/secrets/valid$").
+			JSON(map[string]string{"value": "s3cret", "contentType": "text/plain"}).
+			Reply(200).
+			JSON(`{"value": "s3cret", "id": "` + vaultURI + `/secrets/valid/v3", "attributes": {"enabled": true}}`)
+	}
+
+	return true, &[]expanderTestCase{
+		{
+			name: "KeyVault->Secrets",
+			nodeToExpand: &TreeNode{
+				ID:        vaultID + "/<vault>",
+				Namespace: "keyVault",
+				ItemType:  SubResourceType,
+				ExpandURL: ExpandURLNotSupported,
+				Metadata:  map[string]string{"VaultID": vaultID},
+			},
+			configureGockFunc: &vaultGockConfig,
+			treeNodeCheckerFunc: func(t *testing.T, r ExpanderResult) {
+				st.Expect(t, r.Err, nil)
+				st.Expect(t, r.Nodes[0].Name, "secrets")
+				st.Expect(t, r.Nodes[0].Metadata["SwaggerAPISetID"], vaultID+"/<vault>")
+
+				previousTokenFunc := e.client.GetAquireResourceToken()
+				defer e.client.SetAquireResourceToken(previousTokenFunc)
+				e.client.SetAquireResourceToken(func(clearCache bool, tenantID, resource string) (armclient.AzCLIToken, error) {
+					st.Expect(t, resource, keyVaultResource)
+					return armclient.AzCLIToken{TokenType: "Bearer", AccessToken: "testtoken"}, nil
+				})
+				ctx := context.Background()
+
+				// Secrets show a status for disabled, expired and soon to expire items
+				secrets := GetSwaggerResourceExpander().Expand(ctx, r.Nodes[0])
+				st.Expect(t, secrets.Err, nil)
+				st.Expect(t, len(secrets.Nodes), 4)
+				st.Expect(t, secrets.Nodes[0].StatusIndicator, "⛔")
+				st.Expect(t, secrets.Nodes[1].StatusIndicator, "⛈")
+				st.Expect(t, secrets.Nodes[2].StatusIndicator, "⌛")
+				st.Expect(t, secrets.Nodes[3].StatusIndicator, "")
+				st.Expect(t, secrets.Nodes[3].Name, "valid")
+
+				// The secret value is masked until it is revealed
+				secretNode := secrets.Nodes[3]
+				secret := GetSwaggerResourceExpander().Expand(ctx, secretNode)
+				st.Expect(t, secret.Err, nil)
+				st.Expect(t, strings.Contains(secret.Response.Response, "s3cret"), false)
+				st.Expect(t, strings.Contains(secret.Response.Response, keyVaultMaskedValue), true)
+				st.Expect(t, len(secret.Nodes), 1)
+				st.Expect(t, secret.Nodes[0].Name, "versions")
+
+				versions := GetSwaggerResourceExpander().Expand(ctx, secret.Nodes[0])
+				st.Expect(t, versions.Err, nil)
+				st.Expect(t, len(versions.Nodes), 2)
+				st.Expect(t, versions.Nodes[0].Name, "v1")
+				st.Expect(t, versions.Nodes[0].StatusIndicator, "⛔")
+				st.Expect(t, versions.Nodes[1].Name, "v2")
+
+				actions := e.ListActions(ctx, secretNode).Nodes
+				actionIDs := []string{}
+				for _, action := range actions {
+					actionIDs = append(actionIDs, action.Metadata["ActionID"])
+				}
+				st.Expect(t, actionIDs, []string{"reveal", "newversion", "enable", "disable", "softdelete"})
+
+				reveal := e.Expand(ctx, actions[0])
+				st.Expect(t, reveal.Err, nil)
+				st.Expect(t, strings.Contains(reveal.Response.Response, "s3cret"), true)
+
+				newVersion := e.Expand(ctx, actions[1])
+				st.Expect(t, newVersion.Err, nil)
+				st.Expect(t, strings.Contains(newVersion.Response.Response, "s3cret"), false)
+				st.Expect(t, strings.Contains(newVersion.Response.Response, "/secrets/valid/v3"), true)
+			},
+		},
+	}
+}
@@ -0,0 +1,84 @@
+package expanders
+
+import (
+	"context"
+	"fmt"
+)
+
+// LogFollowOptions controls which logs are streamed when following a container's logs
+type LogFollowOptions struct {
+	// Previous streams the logs of the previous instance of the container (e.g. after a crash)
+	Previous bool
+}
+
+// LogFollower is implemented by expanders that can stream the logs for a node as they are written
+type LogFollower interface {
+	CanFollowLogs(node *TreeNode) bool
+	CanFollowPreviousLogs(node *TreeNode) bool
+	// FollowLogs sends log lines on the channel until the context is cancelled or the log stream ends
+	FollowLogs(ctx context.Context, node *TreeNode, options LogFollowOptions, lines chan<- string) error
+}
+
+// CanFollowLogs returns true if the logs for the node can be streamed
+func CanFollowLogs(node *TreeNode) bool {
+	return getLogFollower(node) != nil
+}
+
+// CanFollowPreviousLogs returns true if the logs for the previous instance of the node's container can be shown
+func CanFollowPreviousLogs(node *TreeNode) bool {
+	follower := getLogFollower(node)
+	return follower != nil && follower.CanFollowPreviousLogs(node)
+}
+
+// FollowLogs sends the logs for the node on the channel until the context is cancelled or the log stream ends
+func FollowLogs(ctx context.Context, node *TreeNode, options LogFollowOptions, lines chan<- string) error {
+	follower := getLogFollower(node)
+	if follower == nil {
+		return fmt.Errorf("Logs can't be followed for %s", node.Name)
+	}
+	if options.Previous && !follower.CanFollowPreviousLogs(node) {
+		return fmt.Errorf("Logs for the previous container instance aren't available for %s", node.Name)
+	}
+	return follower.FollowLogs(ctx, node, options, lines)
+}
+
+func getLogFollower(node *TreeNode) LogFollower {
+	if node == nil {
+		return nil
+	}
+	for _, h := range getRegisteredExpanders() {
+		if follower, ok := h.(LogFollower); ok && follower.CanFollowLogs(node) {
+			return follower
+		}
+	}
+	return nil
+}
+
+// newLogLines returns the lines in current that follow the lines already seen in previous.
+// Polled log APIs return the tail of the log so the last lines seen are located in the new tail
+func newLogLines(previous []string, current []string) []string {
+	if len(previous) == 0 {
+		return current
+	}
+	anchorLength := 10
+	if len(previous) < anchorLength {
+		anchorLength = len(previous)
+	}
+	anchor := previous[len(previous)-anchorLength:]
+
+	// search from the end so that repeated lines match the most recent occurrence
+	for end := len(current); end >= anchorLength; end-- {
+		matched := true
+		for i := range anchor {
+			if current[end-anchorLength+i] != anchor[i] {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return current[end:]
+		}
+	}
+	// the lines seen have scrolled out of the tail (or the container restarted)
+	return current
+}
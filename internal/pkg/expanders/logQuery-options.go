@@ -0,0 +1,306 @@
+package expanders
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/lawrencegripper/azbrowse/internal/pkg/storage"
+)
+
+const (
+	defaultLogQueryTimespan       = "P1D"
+	defaultLogQueryMaxColumnWidth = 40
+	maxLogQueryHistory            = 20
+)
+
+var logQueryColumnWidthPresets = []int{20, 40, 80, 0}
+
+// LogQueryOption is an option that can be applied to a log query result node
+type LogQueryOption struct {
+	ID          string
+	DisplayText string
+}
+
+// NewLogQueryNode returns a node which runs the query against the App Insights component or Log Analytics workspace
+func NewLogQueryNode(resourceID string, query string) *TreeNode {
+	firstLine := strings.SplitN(strings.TrimSpace(query), "\n", 2)[0]
+	return &TreeNode{
+		ID:        resourceID + "/<logs>/<query>",
+		Namespace: logQueryNamespace,
+		Name:      firstLine,
+		Display:   firstLine,
+		ItemType:  logQueryResultType,
+		ExpandURL: ExpandURLNotSupported,
+		Metadata: map[string]string{
+			"ResourceID":            resourceID,
+			"Query":                 query,
+			"Timespan":              defaultLogQueryTimespan,
+			"SuppressSwaggerExpand": "true",
+			"SuppressGenericExpand": "true",
+		},
+	}
+}
+
+// GetLogQueryResourceID returns the ID of the App Insights component or Log Analytics workspace
+// that queries for the node should be run against or "" if logs can't be queried for the node
+func GetLogQueryResourceID(node *TreeNode) string {
+	if node == nil {
+		return ""
+	}
+	if node.Namespace == logQueryNamespace {
+		return node.Metadata["ResourceID"]
+	}
+	if node.Namespace == "AppInsights" {
+		return node.Metadata["AppInsightsID"]
+	}
+	if node.ItemType == ResourceType && isLogQueryResourceType(node) {
+		return node.ID
+	}
+	return ""
+}
+
+// IsLogQueryResult returns true if the node shows the results of a log query
+func IsLogQueryResult(node *TreeNode) bool {
+	return node != nil && node.ItemType == logQueryResultType
+}
+
+// GetLogQueryHistory returns the queries previously run against the resource, most recent first
+func GetLogQueryHistory(resourceID string) []string {
+	history := []string{}
+	data, err := storage.GetQueryHistory(strings.ToLower(resourceID))
+	if err != nil || data == "" {
+		return history
+	}
+	err = json.Unmarshal([]byte(data), &history)
+	if err != nil {
+		return []string{}
+	}
+	return history
+}
+
+func addLogQueryHistory(resourceID string, query string) error {
+	history := []string{query}
+	for _, previous := range GetLogQueryHistory(resourceID) {
+		if previous != query && len(history) < maxLogQueryHistory {
+			history = append(history, previous)
+		}
+	}
+	data, err := json.Marshal(history)
+	if err != nil {
+		return err
+	}
+	return storage.PutQueryHistory(strings.ToLower(resourceID), string(data))
+}
+
+// GetLogQueryOptions returns the time range and column width options for a log query result node
+func GetLogQueryOptions(node *TreeNode) []LogQueryOption {
+	current := func(selected bool) string {
+		if selected {
+			return " (current)"
+		}
+		return ""
+	}
+
+	options := []LogQueryOption{}
+	for _, preset := range metricsTimespanPresets {
+		options = append(options, LogQueryOption{
+			ID:          "timespan:" + preset.value,
+			DisplayText: "Time range: " + preset.display + current(node.Metadata["Timespan"] == preset.value),
+		})
+	}
+	maxColumnWidth := getLogQueryMaxColumnWidth(node)
+	for _, width := range logQueryColumnWidthPresets {
+		display := fmt.Sprintf("Column width: %d characters", width)
+		if width == 0 {
+			display = "Column width: don't truncate"
+		}
+		options = append(options, LogQueryOption{
+			ID:          fmt.Sprintf("width:%d", width),
+			DisplayText: display + current(maxColumnWidth == width),
+		})
+	}
+	return options
+}
+
+// ApplyLogQueryOption updates a log query result node using either the selected option ID
+// or the text entered by the user. Text can be a duration (e.g. PT6H or P3D) or a custom range (start/end)
+func ApplyLogQueryOption(node *TreeNode, optionID string, text string) error {
+	if optionID == "" {
+		text = strings.TrimSpace(text)
+		if _, _, err := (metricsQuery{Timespan: text}).timeRange(time.Now()); err != nil {
+			return err
+		}
+		optionID = "timespan:" + text
+	}
+
+	parts := strings.SplitN(optionID, ":", 2)
+	if len(parts) != 2 {
+		return fmt.Errorf("Unknown option '%s'", optionID)
+	}
+	switch parts[0] {
+	case "timespan":
+		node.Metadata["Timespan"] = parts[1]
+	case "width":
+		node.Metadata["MaxColumnWidth"] = parts[1]
+	default:
+		return fmt.Errorf("Unknown option '%s'", optionID)
+	}
+	return nil
+}
+
+// GetLogQueryExportPath returns a default file name for exporting log query results
+func GetLogQueryExportPath() string {
+	return "query-results-" + time.Now().Format("20060102-150405") + ".csv"
+}
+
+// ExportLogQueryResult writes the results of the log query node to the file as JSON
+// if the file has a .json extension or as CSV otherwise
+func ExportLogQueryResult(node *TreeNode, path string) error {
+	data := node.Metadata["Result"]
+	if data == "" {
+		return fmt.Errorf("The query has not been run")
+	}
+	path = strings.TrimSpace(path)
+	if path == "" {
+		return fmt.Errorf("A file name is required")
+	}
+
+	if strings.ToLower(filepath.Ext(path)) == ".json" {
+		return ioutil.WriteFile(path, []byte(data), 0600)
+	}
+
+	var response logQueryResponse
+	decoder := json.NewDecoder(strings.NewReader(data))
+	decoder.UseNumber()
+	err := decoder.Decode(&response)
+	if err != nil {
+		return fmt.Errorf("Error unmarshalling query response: %s", err)
+	}
+	if len(response.Tables) < 1 {
+		return fmt.Errorf("The query returned no tables")
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close() //nolint: errcheck
+
+	// Only the primary table is exported as CSV
+	table := response.Tables[0]
+	writer := csv.NewWriter(file)
+	header := []string{}
+	for _, column := range table.Columns {
+		header = append(header, column.Name)
+	}
+	if err = writer.Write(header); err != nil {
+		return err
+	}
+	for _, row := range table.Rows {
+		record := []string{}
+		for _, value := range row {
+			record = append(record, formatLogQueryValue(value))
+		}
+		if err = writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func getLogQueryMaxColumnWidth(node *TreeNode) int {
+	width, err := strconv.Atoi(node.Metadata["MaxColumnWidth"])
+	if err != nil || width < 0 {
+		return defaultLogQueryMaxColumnWidth
+	}
+	return width
+}
+
+func describeLogQueryTimespan(timespan string) string {
+	for _, preset := range metricsTimespanPresets {
+		if preset.value == timespan {
+			return preset.display
+		}
+	}
+	return timespan
+}
+
+// renderLogQueryTable aligns the columns of the table, truncating values longer than maxColumnWidth (0 for no limit).
+// Column names aren't truncated so that they can be used in the next query
+func renderLogQueryTable(table logQueryTable, maxColumnWidth int) string {
+	truncate := func(value string) string {
+		value = strings.NewReplacer("\r\n", " ", "\n", " ", "\t", " ").Replace(value)
+		if maxColumnWidth > 0 && utf8.RuneCountInString(value) > maxColumnWidth {
+			return string([]rune(value)[:maxColumnWidth-1]) + "…"
+		}
+		return value
+	}
+
+	cells := [][]string{{}}
+	for _, column := range table.Columns {
+		cells[0] = append(cells[0], column.Name)
+	}
+	for _, row := range table.Rows {
+		rowCells := []string{}
+		for _, value := range row {
+			rowCells = append(rowCells, truncate(formatLogQueryValue(value)))
+		}
+		cells = append(cells, rowCells)
+	}
+
+	widths := make([]int, len(table.Columns))
+	for _, row := range cells {
+		for i, cell := range row {
+			if i < len(widths) && utf8.RuneCountInString(cell) > widths[i] {
+				widths[i] = utf8.RuneCountInString(cell)
+			}
+		}
+	}
+
+	lines := []string{}
+	for rowIndex, row := range cells {
+		line := ""
+		for i, cell := range row {
+			if i < len(row)-1 && i < len(widths) {
+				cell += strings.Repeat(" ", widths[i]-utf8.RuneCountInString(cell)+2)
+			}
+			line += cell
+		}
+		lines = append(lines, line)
+		if rowIndex == 0 {
+			separator := []string{}
+			for _, width := range widths {
+				separator = append(separator, strings.Repeat("─", width))
+			}
+			lines = append(lines, strings.Join(separator, "  "))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func formatLogQueryValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%v", value)
+	}
+	return string(data)
+}
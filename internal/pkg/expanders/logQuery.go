@@ -0,0 +1,263 @@
+package expanders
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/lawrencegripper/azbrowse/internal/pkg/style"
+	"github.com/lawrencegripper/azbrowse/pkg/armclient"
+	"github.com/nbio/st"
+	"gopkg.in/h2non/gock.v1"
+)
+
+const (
+	appInsightsTemplateURL      = "/subscriptions/{subscriptionId}/resourceGroups/{resourceGroupName}/providers/Microsoft.Insights/components/{resourceName}"
+	logAnalyticsTemplateURL     = "/subscriptions/{subscriptionId}/resourcegroups/{resourceGroupName}/providers/Microsoft.OperationalInsights/workspaces/{workspaceName}"
+	appInsightsQueryAPIVersion  = "2018-04-20"
+	logAnalyticsQueryAPIVersion = "2017-01-01-preview"
+	logQueryNamespace           = "logQuery"
+	logQueryRootType            = "logQuery.root"
+	logQueryResultType          = "logQuery.result"
+)
+
+type logQueryResponse struct {
+	Tables []logQueryTable `json:"tables"`
+}
+
+type logQueryTable struct {
+	Name    string `json:"name"`
+	Columns []struct {
+		Name string `json:"name"`
+		Type string `json:"type"`
+	} `json:"columns"`
+	Rows [][]interface{} `json:"rows"`
+}
+
+type logQueryErrorResponse struct {
+	Error struct {
+		Message    string `json:"message"`
+		InnerError *struct {
+			Message string `json:"message"`
+		} `json:"innererror"`
+	} `json:"error"`
+}
+
+// Check interface
+var _ Expander = &LogQueryExpander{}
+
+// LogQueryExpander runs KQL queries against App Insights components and Log Analytics workspaces
+type LogQueryExpander struct {
+	ExpanderBase
+	client *armclient.Client
+}
+
+func (e *LogQueryExpander) setClient(c *armclient.Client) {
+	e.client = c
+}
+
+// Name returns the name of the expander
+func (e *LogQueryExpander) Name() string {
+	return "LogQueryExpander"
+}
+
+// DoesExpand checks if this is an App Insights component, Log Analytics workspace or a log query node
+func (e *LogQueryExpander) DoesExpand(ctx context.Context, currentItem *TreeNode) (bool, error) {
+	if currentItem.ItemType == ResourceType && isLogQueryResourceType(currentItem) {
+		return true, nil
+	}
+	if currentItem.Namespace == logQueryNamespace {
+		return true, nil
+	}
+	return false, nil
+}
+
+// Expand adds the logs node to the resource, lists the query history and runs queries
+func (e *LogQueryExpander) Expand(ctx context.Context, currentItem *TreeNode) ExpanderResult {
+	if currentItem.Namespace != logQueryNamespace {
+		return ExpanderResult{
+			Response:          ExpanderResponse{Response: ""}, // Swagger expander will supply the response
+			SourceDescription: "LogQueryExpander request",
+			Nodes: []*TreeNode{
+				{
+					Parentid:  currentItem.ID,
+					ID:        currentItem.ID + "/<logs>",
+					Namespace: logQueryNamespace,
+					Name:      "Logs",
+					Display:   "Logs",
+					ItemType:  logQueryRootType,
+					ExpandURL: ExpandURLNotSupported,
+					Metadata: map[string]string{
+						"ResourceID":            currentItem.ID,
+						"SuppressSwaggerExpand": "true",
+						"SuppressGenericExpand": "true",
+					},
+				},
+			},
+			IsPrimaryResponse: false,
+		}
+	}
+
+	switch currentItem.ItemType {
+	case logQueryRootType:
+		return e.expandHistory(currentItem)
+	case logQueryResultType:
+		return e.expandQuery(ctx, currentItem)
+	}
+
+	return ExpanderResult{
+		Err:               fmt.Errorf("Error - unhandled Expand"),
+		Response:          ExpanderResponse{Response: "Error!"},
+		SourceDescription: "LogQueryExpander request",
+	}
+}
+
+// expandHistory lists the previous queries for the resource so that they can be run again
+func (e *LogQueryExpander) expandHistory(currentItem *TreeNode) ExpanderResult {
+	resourceID := currentItem.Metadata["ResourceID"]
+	newItems := []*TreeNode{}
+	for i, query := range GetLogQueryHistory(resourceID) {
+		node := NewLogQueryNode(resourceID, query)
+		node.ID = fmt.Sprintf("%s/%d", currentItem.ID, i)
+		node.Parentid = currentItem.ID
+		newItems = append(newItems, node)
+	}
+
+	return ExpanderResult{
+		Response: ExpanderResponse{
+			Response:     "Use the 'Run log query' command to query " + resourceID + "\nPrevious queries are listed below and can be expanded to run them again",
+			ResponseType: ResponsePlainText,
+		},
+		SourceDescription: "LogQueryExpander request",
+		Nodes:             newItems,
+		IsPrimaryResponse: true,
+	}
+}
+
+// expandQuery runs the query and renders the results as a table
+func (e *LogQueryExpander) expandQuery(ctx context.Context, currentItem *TreeNode) ExpanderResult {
+	resourceID := currentItem.Metadata["ResourceID"]
+	query := currentItem.Metadata["Query"]
+	timespan := currentItem.Metadata["Timespan"]
+
+	apiVersion := appInsightsQueryAPIVersion
+	if strings.Contains(strings.ToLower(resourceID), "/microsoft.operationalinsights/workspaces/") {
+		apiVersion = logAnalyticsQueryAPIVersion
+	}
+	body, err := json.Marshal(map[string]string{
+		"query":    query,
+		"timespan": timespan,
+	})
+	if err != nil {
+		return e.errorResult(err)
+	}
+
+	data, err := e.client.DoRequestWithBody(ctx, "POST", resourceID+"/api/query?api-version="+apiVersion, string(body))
+	if err != nil {
+		var errorResponse logQueryErrorResponse
+		if jsonErr := json.Unmarshal([]byte(data), &errorResponse); jsonErr == nil && errorResponse.Error.Message != "" {
+			message := errorResponse.Error.Message
+			if errorResponse.Error.InnerError != nil && errorResponse.Error.InnerError.Message != "" {
+				message += ": " + errorResponse.Error.InnerError.Message
+			}
+			err = fmt.Errorf("Query failed: %s", message)
+		}
+		return e.errorResult(err)
+	}
+
+	var response logQueryResponse
+	decoder := json.NewDecoder(strings.NewReader(data))
+	decoder.UseNumber() // keep numbers as they were returned rather than converting to float
+	err = decoder.Decode(&response)
+	if err != nil {
+		return e.errorResult(fmt.Errorf("Error unmarshalling query response: %s", err))
+	}
+
+	// Keep the results for exporting
+	currentItem.Metadata["Result"] = data
+	addLogQueryHistory(resourceID, query) //nolint: errcheck
+
+	maxColumnWidth := getLogQueryMaxColumnWidth(currentItem)
+	tables := []string{}
+	for _, table := range response.Tables {
+		caption := style.Subtle(fmt.Sprintf("%s: %d rows", table.Name, len(table.Rows)))
+		tables = append(tables, caption+"\n\n"+renderLogQueryTable(table, maxColumnWidth))
+	}
+	caption := style.Title(query) + "\n" + style.Subtle("Time range: "+describeLogQueryTimespan(timespan))
+
+	return ExpanderResult{
+		Response:          ExpanderResponse{Response: caption + "\n\n" + strings.Join(tables, "\n\n"), ResponseType: ResponsePlainText},
+		SourceDescription: "LogQueryExpander request",
+		IsPrimaryResponse: true,
+	}
+}
+
+func (e *LogQueryExpander) errorResult(err error) ExpanderResult {
+	return ExpanderResult{
+		Err:               err,
+		Response:          ExpanderResponse{Response: err.Error(), ResponseType: ResponsePlainText},
+		SourceDescription: "LogQueryExpander request",
+		IsPrimaryResponse: true,
+	}
+}
+
+func isLogQueryResourceType(node *TreeNode) bool {
+	if node.SwaggerResourceType == nil {
+		return false
+	}
+	templateURL := node.SwaggerResourceType.Endpoint.TemplateURL
+	return templateURL == appInsightsTemplateURL || templateURL == logAnalyticsTemplateURL
+}
+
+func (e *LogQueryExpander) testCases() (bool, *[]expanderTestCase) {
+	const workspaceID = "/subscriptions/00000000-0000-0000-0000-000000000000/resourcegroups/stable/providers/Microsoft.OperationalInsights/workspaces/testworkspace"
+
+	queryGockConfig := func(t *testing.T) {
+		gock.New("https://management.azure.com").
+			Post(workspaceID+"/api/query").
+			MatchParam("api-version", logAnalyticsQueryAPIVersion).
+			JSON(map[string]string{"query": "Heartbeat | take 3", "timespan": "PT4H"}).
+			Reply(200).
+			File("./testdata/armsamples/logquery/heartbeat.json")
+	}
+	errorGockConfig := func(t *testing.T) {
+		gock.New("https://management.azure.com").
+			Post(workspaceID + "/api/query").
+			Reply(400).
+			JSON(`{"error":{"message":"The request had some invalid properties","code":"BadArgumentError","innererror":{"code":"SyntaxError","message":"Query could not be parsed at 'tak'"}}}`)
+	}
+
+	queryNode := NewLogQueryNode(workspaceID, "Heartbeat | take 3")
+	queryNode.Metadata["Timespan"] = "PT4H"
+	queryNode.Metadata["MaxColumnWidth"] = "12"
+
+	return true, &[]expanderTestCase{
+		{
+			name:              "Query->Table",
+			nodeToExpand:      queryNode,
+			configureGockFunc: &queryGockConfig,
+			treeNodeCheckerFunc: func(t *testing.T, r ExpanderResult) {
+				st.Expect(t, r.Err, nil)
+				lines := strings.Split(r.Response.Response, "\n")
+				st.Expect(t, strings.Contains(r.Response.Response, "PrimaryResult: 3 rows"), true)
+				st.Expect(t, strings.Contains(r.Response.Response, "Computer      TimeGenerated  Count"), true)
+				// long values are truncated to the column width
+				st.Expect(t, strings.Contains(r.Response.Response, "2020-04-09T…"), true)
+				st.Expect(t, strings.Contains(r.Response.Response, "web-01-long…"), true)
+				// numbers keep the format returned by the API
+				st.Expect(t, strings.HasSuffix(lines[len(lines)-1], "1234567890"), true)
+				st.Expect(t, queryNode.Metadata["Result"] != "", true)
+			},
+		},
+		{
+			name:              "Query->Error",
+			nodeToExpand:      NewLogQueryNode(workspaceID, "Heartbeat | tak 3"),
+			configureGockFunc: &errorGockConfig,
+			treeNodeCheckerFunc: func(t *testing.T, r ExpanderResult) {
+				st.Expect(t, r.Err.Error(), "Query failed: The request had some invalid properties: Query could not be parsed at 'tak'")
+			},
+		},
+	}
+}
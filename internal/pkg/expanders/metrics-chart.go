@@ -0,0 +1,176 @@
+package expanders
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/lawrencegripper/azbrowse/internal/pkg/style"
+)
+
+// metricsSeries is a named set of values to plot
+type metricsSeries struct {
+	Name   string
+	Values []float64
+}
+
+type metricsChartCell struct {
+	r      rune
+	series int
+}
+
+// plotMetricsSeries draws several series on a shared y-axis using the same characters as asciigraph
+// so that the time axis can be added in the same way. Each series is drawn in its own colour
+func plotMetricsSeries(series []metricsSeries, height int, width int) string {
+	if height < 2 {
+		height = 10
+	}
+
+	interpolated := [][]float64{}
+	minimum, maximum := math.Inf(1), math.Inf(-1)
+	for _, s := range series {
+		if len(s.Values) == 0 {
+			interpolated = append(interpolated, []float64{})
+			continue
+		}
+		values := s.Values
+		if width > 0 {
+			values = interpolateMetricsValues(s.Values, width)
+		}
+		for _, value := range values {
+			minimum = math.Min(minimum, value)
+			maximum = math.Max(maximum, value)
+		}
+		interpolated = append(interpolated, values)
+	}
+	if math.IsInf(minimum, 0) {
+		return ""
+	}
+	columns := 0
+	for _, values := range interpolated {
+		if len(values) > columns {
+			columns = len(values)
+		}
+	}
+
+	interval := maximum - minimum
+	ratio := float64(1)
+	if interval != 0 {
+		ratio = float64(height) / interval
+	}
+	min2 := int(math.Round(minimum * ratio))
+	max2 := int(math.Round(maximum * ratio))
+	rows := max2 - min2
+
+	grid := make([][]metricsChartCell, rows+1)
+	for i := range grid {
+		grid[i] = make([]metricsChartCell, columns)
+		for j := range grid[i] {
+			grid[i][j] = metricsChartCell{r: ' ', series: -1}
+		}
+	}
+	set := func(row int, column int, r rune, seriesIndex int) {
+		grid[rows-row][column] = metricsChartCell{r: r, series: seriesIndex}
+	}
+
+	for seriesIndex, values := range interpolated {
+		if len(values) == 1 {
+			set(int(math.Round(values[0]*ratio))-min2, 0, '─', seriesIndex)
+		}
+		for x := 0; x < len(values)-1; x++ {
+			y0 := int(math.Round(values[x]*ratio)) - min2
+			y1 := int(math.Round(values[x+1]*ratio)) - min2
+			if y0 == y1 {
+				set(y0, x, '─', seriesIndex)
+				continue
+			}
+			if y0 > y1 {
+				set(y1, x, '╰', seriesIndex)
+				set(y0, x, '╮', seriesIndex)
+			} else {
+				set(y1, x, '╭', seriesIndex)
+				set(y0, x, '╯', seriesIndex)
+			}
+			start := int(math.Min(float64(y0), float64(y1))) + 1
+			end := int(math.Max(float64(y0), float64(y1)))
+			for y := start; y < end; y++ {
+				set(y, x, '│', seriesIndex)
+			}
+		}
+	}
+
+	precision := 2
+	largest := math.Max(math.Abs(maximum), math.Abs(minimum))
+	if largest >= 1000 {
+		precision = 0
+	} else if largest != 0 && largest < 0.1 {
+		precision = 4
+	}
+	labelWidth := int(math.Max(float64(len(fmt.Sprintf("%.*f", precision, maximum))), float64(len(fmt.Sprintf("%.*f", precision, minimum)))))
+
+	var lines strings.Builder
+	for row := 0; row <= rows; row++ {
+		magnitude := float64(maximum)
+		if rows > 0 {
+			magnitude = maximum - float64(row)*interval/float64(rows)
+		}
+		if row > 0 {
+			lines.WriteRune('\n')
+		}
+		lines.WriteString(fmt.Sprintf("%*.*f ┤", labelWidth+1, precision, magnitude))
+
+		// write runs of cells for the same series together to keep the colour codes down
+		run := ""
+		runSeries := -1
+		flush := func() {
+			if runSeries < 0 {
+				lines.WriteString(run)
+			} else {
+				lines.WriteString(style.Series(runSeries, run))
+			}
+			run = ""
+		}
+		for _, cell := range grid[row] {
+			if cell.series != runSeries {
+				flush()
+				runSeries = cell.series
+			}
+			run += string(cell.r)
+		}
+		flush()
+	}
+	return lines.String()
+}
+
+// metricsLegend returns a line per series with the name in the series colour
+func metricsLegend(series []metricsSeries) string {
+	legend := []string{}
+	for i, s := range series {
+		legend = append(legend, style.Series(i, "■ "+s.Name))
+	}
+	return strings.Join(legend, "\n")
+}
+
+// interpolateMetricsValues stretches or squashes the values to fit the number of columns
+func interpolateMetricsValues(values []float64, columns int) []float64 {
+	if len(values) == 1 || columns < 2 {
+		result := make([]float64, columns)
+		for i := range result {
+			result[i] = values[0]
+		}
+		return result
+	}
+	result := make([]float64, columns)
+	step := float64(len(values)-1) / float64(columns-1)
+	for i := 0; i < columns; i++ {
+		position := float64(i) * step
+		index := int(math.Floor(position))
+		if index >= len(values)-1 {
+			result[i] = values[len(values)-1]
+			continue
+		}
+		fraction := position - float64(index)
+		result[i] = values[index] + (values[index+1]-values[index])*fraction
+	}
+	return result
+}
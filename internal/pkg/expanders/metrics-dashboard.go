@@ -0,0 +1,239 @@
+package expanders
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/guptarohit/asciigraph"
+	"github.com/lawrencegripper/azbrowse/internal/pkg/storage"
+	"github.com/lawrencegripper/azbrowse/internal/pkg/style"
+)
+
+// DefaultMetricsDashboardRefresh is how often a dashboard is redrawn unless the dashboard specifies otherwise
+const DefaultMetricsDashboardRefresh = time.Minute
+
+// MetricsDashboard is a named set of metric graphs which are drawn as a grid
+type MetricsDashboard struct {
+	Name           string                 `json:"name"`
+	RefreshSeconds int                    `json:"refreshSeconds,omitempty"`
+	Metrics        []MetricsDashboardItem `json:"metrics"`
+}
+
+// MetricsDashboardItem holds the details needed to redraw a metric graph node
+type MetricsDashboardItem struct {
+	Name      string            `json:"name"`
+	ExpandURL string            `json:"expandURL"`
+	TenantID  string            `json:"tenantID,omitempty"`
+	Metadata  map[string]string `json:"metadata"`
+}
+
+// SaveMetricsDashboard stores the pinned metrics as a named dashboard
+func SaveMetricsDashboard(name string) error {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return fmt.Errorf("A dashboard name is required")
+	}
+	pinned := GetPinnedMetrics()
+	if len(pinned) < 1 {
+		return fmt.Errorf("No metrics have been pinned")
+	}
+
+	dashboard := MetricsDashboard{Name: name}
+	for _, node := range pinned {
+		dashboard.Metrics = append(dashboard.Metrics, MetricsDashboardItem{
+			Name:      node.Name,
+			ExpandURL: node.ExpandURL,
+			TenantID:  node.TenantID,
+			Metadata:  node.Metadata,
+		})
+	}
+	data, err := json.Marshal(dashboard)
+	if err != nil {
+		return err
+	}
+	return storage.PutMetricsDashboard(name, string(data))
+}
+
+// GetMetricsDashboardNames returns the names of the saved dashboards
+func GetMetricsDashboardNames() []string {
+	names, err := storage.ListMetricsDashboards()
+	if err != nil {
+		return []string{}
+	}
+	return names
+}
+
+// NewMetricsDashboardNode loads a saved dashboard and returns a node which draws it
+func NewMetricsDashboardNode(name string) (*TreeNode, error) {
+	data, err := storage.GetMetricsDashboard(name)
+	if err != nil {
+		return nil, err
+	}
+	if data == "" {
+		return nil, fmt.Errorf("Dashboard '%s' not found", name)
+	}
+	return &TreeNode{
+		ID:        "metrics/dashboards/" + name,
+		Name:      name,
+		Display:   name,
+		ItemType:  metricsDashboardType,
+		ExpandURL: ExpandURLNotSupported,
+		Metadata: map[string]string{
+			"SuppressSwaggerExpand": "true",
+			"SuppressGenericExpand": "true",
+			"Dashboard":             data,
+		},
+	}, nil
+}
+
+// IsMetricsDashboard returns true if the node draws a metrics dashboard
+func IsMetricsDashboard(node *TreeNode) bool {
+	return node != nil && node.ItemType == metricsDashboardType
+}
+
+// GetMetricsDashboardRefresh returns how often the dashboard node should be redrawn
+func GetMetricsDashboardRefresh(node *TreeNode) time.Duration {
+	dashboard, err := getMetricsDashboard(node)
+	if err != nil || dashboard.RefreshSeconds <= 0 {
+		return DefaultMetricsDashboardRefresh
+	}
+	return time.Duration(dashboard.RefreshSeconds) * time.Second
+}
+
+func getMetricsDashboard(node *TreeNode) (MetricsDashboard, error) {
+	var dashboard MetricsDashboard
+	err := json.Unmarshal([]byte(node.Metadata["Dashboard"]), &dashboard)
+	if err != nil {
+		return dashboard, fmt.Errorf("Failed to deserialise dashboard: %s", err)
+	}
+	return dashboard, nil
+}
+
+func (e *MetricsExpander) expandDashboard(ctx context.Context, currentItem *TreeNode) ExpanderResult {
+	dashboard, err := getMetricsDashboard(currentItem)
+	if err != nil {
+		return ExpanderResult{
+			Err:               err,
+			SourceDescription: "MetricsExpander build dashboard",
+		}
+	}
+	if len(dashboard.Metrics) < 1 {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Dashboard '%s' has no metrics", dashboard.Name),
+			SourceDescription: "MetricsExpander build dashboard",
+		}
+	}
+
+	// Use two columns when there's room so that up to four charts fit on screen
+	columns := 1
+	if len(dashboard.Metrics) > 1 && ItemWidgetWidth >= 100 {
+		columns = 2
+	}
+	rows := (len(dashboard.Metrics) + columns - 1) / columns
+	cellWidth := ItemWidgetWidth/columns - 2
+	cellHeight := (ItemWidgetHeight-4)/rows - 4
+
+	cells := []string{}
+	for _, item := range dashboard.Metrics {
+		node := &TreeNode{
+			Name:      item.Name,
+			ExpandURL: item.ExpandURL,
+			ItemType:  metricsGraphType,
+			TenantID:  item.TenantID,
+			Metadata:  item.Metadata,
+		}
+		// An error in one chart is shown in its cell rather than failing the whole dashboard
+		cell, err := e.drawDashboardCell(ctx, node, cellWidth, cellHeight)
+		if err != nil {
+			cell = style.Title(describePinnedMetric(node)) + "\n" + err.Error()
+		}
+		cells = append(cells, cell)
+	}
+
+	caption := style.Title(dashboard.Name) + style.Subtle(" (updated "+time.Now().Local().Format("15:04:05")+")")
+	return ExpanderResult{
+		Response:          ExpanderResponse{Response: "\n" + caption + "\n\n" + layoutDashboardCells(cells, columns, cellWidth)},
+		IsPrimaryResponse: true,
+		SourceDescription: "MetricsExpander build dashboard",
+	}
+}
+
+// drawDashboardCell draws a small chart for the metric with its name above
+func (e *MetricsExpander) drawDashboardCell(ctx context.Context, node *TreeNode, width int, height int) (string, error) {
+	query := getMetricsQuery(node)
+	start, end, err := query.timeRange(time.Now())
+	if err != nil {
+		return "", err
+	}
+	metricResponse, err := e.getMetric(WithNodeTenant(ctx, node), node, query, start, end)
+	if err != nil {
+		return "", err
+	}
+	series := metricResponse.getSeries(query.Aggregation)
+	if len(series) < 1 {
+		return "", fmt.Errorf("No data returned for metric")
+	}
+	if height < 3 {
+		height = 3
+	}
+
+	// leave room for the y-axis labels
+	graphWidth := width - 12
+	if graphWidth <= 0 {
+		graphWidth = len(series[0].Values)
+	}
+
+	var graph string
+	if len(series) == 1 {
+		graph = style.Graph(asciigraph.Plot(series[0].Values,
+			asciigraph.Height(height),
+			asciigraph.Width(graphWidth)))
+	} else {
+		graph = plotMetricsSeries(series, height, graphWidth)
+	}
+
+	title := style.Title(describePinnedMetric(node)) + style.Subtle(" ("+query.Aggregation+")")
+	return title + "\n" + graph + "\n" + style.Subtle(renderTimeAxis(graph, graphWidth, start, end)), nil
+}
+
+// layoutDashboardCells places the cells side by side in rows, padding each line of a cell to the cell width
+func layoutDashboardCells(cells []string, columns int, cellWidth int) string {
+	rows := []string{}
+	for i := 0; i < len(cells); i += columns {
+		rowCells := [][]string{}
+		rowHeight := 0
+		for j := i; j < i+columns && j < len(cells); j++ {
+			lines := strings.Split(cells[j], "\n")
+			if len(lines) > rowHeight {
+				rowHeight = len(lines)
+			}
+			rowCells = append(rowCells, lines)
+		}
+
+		lines := []string{}
+		for lineIndex := 0; lineIndex < rowHeight; lineIndex++ {
+			line := ""
+			for cellIndex, cellLines := range rowCells {
+				text := ""
+				if lineIndex < len(cellLines) {
+					text = cellLines[lineIndex]
+				}
+				if cellIndex < len(rowCells)-1 {
+					visibleWidth := utf8.RuneCountInString(ansiEscapeRegex.ReplaceAllString(text, ""))
+					if visibleWidth < cellWidth {
+						text += strings.Repeat(" ", cellWidth-visibleWidth)
+					}
+					text += "  "
+				}
+				line += text
+			}
+			lines = append(lines, strings.TrimRight(line, " "))
+		}
+		rows = append(rows, strings.Join(lines, "\n"))
+	}
+	return strings.Join(rows, "\n\n")
+}
@@ -0,0 +1,113 @@
+package expanders
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// MaxPinnedMetrics is the number of metrics that can be compared on one graph
+const MaxPinnedMetrics = 4
+
+var pinnedMetrics = struct {
+	sync.Mutex
+	nodes []*TreeNode
+}{}
+
+// PinMetric adds a metric graph node to the metrics compared by the comparison graph
+func PinMetric(node *TreeNode) error {
+	if !IsMetricsGraph(node) {
+		return fmt.Errorf("Only metric graphs can be pinned")
+	}
+	pinnedMetrics.Lock()
+	defer pinnedMetrics.Unlock()
+
+	for _, pinned := range pinnedMetrics.nodes {
+		if pinned.ExpandURL == node.ExpandURL {
+			return nil
+		}
+	}
+	if len(pinnedMetrics.nodes) >= MaxPinnedMetrics {
+		return fmt.Errorf("Up to %d metrics can be pinned", MaxPinnedMetrics)
+	}
+
+	// Take a copy so that the pinned query isn't changed by later changes to the graph
+	pinned := *node
+	pinned.Metadata = map[string]string{}
+	for key, value := range node.Metadata {
+		pinned.Metadata[key] = value
+	}
+	pinnedMetrics.nodes = append(pinnedMetrics.nodes, &pinned)
+	return nil
+}
+
+// UnpinMetric removes a metric graph node from the pinned metrics
+func UnpinMetric(node *TreeNode) {
+	pinnedMetrics.Lock()
+	defer pinnedMetrics.Unlock()
+
+	nodes := []*TreeNode{}
+	for _, pinned := range pinnedMetrics.nodes {
+		if pinned.ExpandURL != node.ExpandURL {
+			nodes = append(nodes, pinned)
+		}
+	}
+	pinnedMetrics.nodes = nodes
+}
+
+// IsMetricPinned returns true if the metric graph node has been pinned
+func IsMetricPinned(node *TreeNode) bool {
+	pinnedMetrics.Lock()
+	defer pinnedMetrics.Unlock()
+
+	for _, pinned := range pinnedMetrics.nodes {
+		if pinned.ExpandURL == node.ExpandURL {
+			return true
+		}
+	}
+	return false
+}
+
+// GetPinnedMetrics returns the pinned metric graph nodes
+func GetPinnedMetrics() []*TreeNode {
+	pinnedMetrics.Lock()
+	defer pinnedMetrics.Unlock()
+
+	nodes := make([]*TreeNode, len(pinnedMetrics.nodes))
+	copy(nodes, pinnedMetrics.nodes)
+	return nodes
+}
+
+// ClearPinnedMetrics removes all of the pinned metrics
+func ClearPinnedMetrics() {
+	pinnedMetrics.Lock()
+	defer pinnedMetrics.Unlock()
+
+	pinnedMetrics.nodes = []*TreeNode{}
+}
+
+// NewMetricsComparisonNode returns a node which plots the pinned metrics on one graph
+func NewMetricsComparisonNode() *TreeNode {
+	return &TreeNode{
+		ID:        "metrics/<pinned>",
+		Name:      "Pinned metrics",
+		Display:   "Pinned metrics",
+		ItemType:  metricsComparisonType,
+		ExpandURL: ExpandURLNotSupported,
+		Metadata: map[string]string{
+			"SuppressSwaggerExpand": "true",
+			"SuppressGenericExpand": "true",
+		},
+	}
+}
+
+// describePinnedMetric returns the resource and metric name for the legend, e.g. "mysite/CpuTime (seconds)"
+func describePinnedMetric(node *TreeNode) string {
+	resourceID := node.Metadata["ResourceID"]
+	resourceName := resourceID[strings.LastIndex(resourceID, "/")+1:]
+	description := resourceName + "/" + node.Name
+	if units := node.Metadata["Units"]; units != "" {
+		description += " (" + units + ")"
+	}
+	return description
+}
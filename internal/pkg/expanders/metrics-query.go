@@ -0,0 +1,367 @@
+package expanders
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lawrencegripper/azbrowse/internal/pkg/storage"
+)
+
+const (
+	metricsDefaultTimespan = "PT4H"
+	metricsDefaultInterval = "PT1M"
+	metricsAutoInterval    = "auto"
+	metricsTimeFormat      = "2006-01-02T15:04:05.000Z"
+)
+
+// metricsQuery holds the options used to query and plot a metric graph
+type metricsQuery struct {
+	Timespan    string `json:"timespan,omitempty"`    // ISO 8601 duration back from now (e.g. PT4H) or a custom start/end range
+	Interval    string `json:"interval,omitempty"`    // ISO 8601 duration or "auto"
+	Aggregation string `json:"aggregation,omitempty"` // average, minimum, maximum, total or count
+	Filter      string `json:"-"`                     // $filter used to split by dimension, e.g. "Instance eq '*'"
+}
+
+type metricsPreset struct {
+	value   string
+	display string
+}
+
+var metricsTimespanPresets = []metricsPreset{
+	{value: "PT30M", display: "Last 30 minutes"},
+	{value: "PT1H", display: "Last hour"},
+	{value: "PT4H", display: "Last 4 hours"},
+	{value: "PT12H", display: "Last 12 hours"},
+	{value: "P1D", display: "Last 24 hours"},
+	{value: "P7D", display: "Last 7 days"},
+	{value: "P30D", display: "Last 30 days"},
+}
+
+var metricsIntervalPresets = []metricsPreset{
+	{value: metricsAutoInterval, display: "Automatic"},
+	{value: "PT1M", display: "1 minute"},
+	{value: "PT5M", display: "5 minutes"},
+	{value: "PT15M", display: "15 minutes"},
+	{value: "PT30M", display: "30 minutes"},
+	{value: "PT1H", display: "1 hour"},
+	{value: "PT6H", display: "6 hours"},
+	{value: "PT12H", display: "12 hours"},
+	{value: "P1D", display: "1 day"},
+}
+
+var metricsAggregations = []string{"average", "minimum", "maximum", "total", "count"}
+
+// MetricsQueryOption is an option that can be applied to a metric graph node
+type MetricsQueryOption struct {
+	ID          string
+	DisplayText string
+}
+
+// IsMetricsGraph returns true if the node is a metric graph that can be configured
+func IsMetricsGraph(node *TreeNode) bool {
+	return node != nil && node.ItemType == metricsGraphType
+}
+
+// GetMetricsQueryOptions returns the time range, interval and aggregation options for a metric graph node
+func GetMetricsQueryOptions(node *TreeNode) []MetricsQueryOption {
+	query := getMetricsQuery(node)
+	current := func(selected bool) string {
+		if selected {
+			return " (current)"
+		}
+		return ""
+	}
+
+	options := []MetricsQueryOption{}
+	for _, preset := range metricsTimespanPresets {
+		options = append(options, MetricsQueryOption{
+			ID:          "timespan:" + preset.value,
+			DisplayText: "Time range: " + preset.display + current(query.Timespan == preset.value),
+		})
+	}
+	for _, preset := range metricsIntervalPresets {
+		options = append(options, MetricsQueryOption{
+			ID:          "interval:" + preset.value,
+			DisplayText: "Interval: " + preset.display + current(query.Interval == preset.value),
+		})
+	}
+	for _, aggregation := range supportedMetricsAggregations(node) {
+		options = append(options, MetricsQueryOption{
+			ID:          "aggregation:" + aggregation,
+			DisplayText: "Aggregation: " + strings.Title(aggregation) + current(query.Aggregation == aggregation),
+		})
+	}
+	if dimensions := node.Metadata["Dimensions"]; dimensions != "" {
+		options = append(options, MetricsQueryOption{
+			ID:          "filter:",
+			DisplayText: "Split by: none" + current(query.Filter == ""),
+		})
+		for _, dimension := range strings.Split(dimensions, ",") {
+			filter := dimension + " eq '*'"
+			options = append(options, MetricsQueryOption{
+				ID:          "filter:" + filter,
+				DisplayText: "Split by: " + dimension + current(query.Filter == filter),
+			})
+		}
+	}
+	return options
+}
+
+// ApplyMetricsQueryOption updates the query for a metric graph node using either the selected option ID
+// or the text entered by the user. Text can be a duration (e.g. PT6H or P3D), a custom range (start/end)
+// or a filter (e.g. $filter=StatusCode eq '500' or Instance eq '*')
+// The time range, interval and aggregation are saved as the defaults for the metric namespace
+func ApplyMetricsQueryOption(node *TreeNode, optionID string, text string) error {
+	query := getMetricsQuery(node)
+
+	if optionID == "" {
+		text = strings.TrimSpace(text)
+		if strings.HasPrefix(text, "$filter=") {
+			optionID = "filter:" + strings.TrimPrefix(text, "$filter=")
+		} else {
+			if _, _, err := (metricsQuery{Timespan: text}).timeRange(time.Now()); err != nil {
+				return err
+			}
+			optionID = "timespan:" + text
+		}
+	}
+
+	parts := strings.SplitN(optionID, ":", 2)
+	if len(parts) != 2 {
+		return fmt.Errorf("Unknown option '%s'", optionID)
+	}
+	switch parts[0] {
+	case "timespan":
+		query.Timespan = parts[1]
+	case "interval":
+		query.Interval = parts[1]
+	case "aggregation":
+		query.Aggregation = parts[1]
+	case "filter":
+		query.Filter = parts[1]
+	default:
+		return fmt.Errorf("Unknown option '%s'", optionID)
+	}
+
+	node.Metadata["Timespan"] = query.Timespan
+	node.Metadata["Interval"] = query.Interval
+	node.Metadata["AggregationType"] = query.Aggregation
+	node.Metadata["Filter"] = query.Filter
+
+	// Custom ranges are specific to this graph so aren't saved as the default
+	defaults := query
+	if strings.Contains(defaults.Timespan, "/") {
+		defaults.Timespan = ""
+	}
+	saveMetricsDefaults(node.Metadata["MetricNamespace"], defaults) //nolint: errcheck
+	return nil
+}
+
+// getMetricsQuery returns the current query for a metric graph node
+func getMetricsQuery(node *TreeNode) metricsQuery {
+	query := metricsQuery{
+		Timespan:    node.Metadata["Timespan"],
+		Interval:    node.Metadata["Interval"],
+		Aggregation: node.Metadata["AggregationType"],
+		Filter:      node.Metadata["Filter"],
+	}
+	if query.Timespan == "" {
+		query.Timespan = metricsDefaultTimespan
+	}
+	if query.Interval == "" {
+		query.Interval = metricsDefaultInterval
+	}
+	return query
+}
+
+// loadMetricsDefaults returns the saved defaults for a metric namespace, falling back to the built-in defaults
+func loadMetricsDefaults(namespace string) metricsQuery {
+	defaults := metricsQuery{
+		Timespan: metricsDefaultTimespan,
+		Interval: metricsDefaultInterval,
+	}
+	data, err := storage.GetMetricsDefaults(strings.ToLower(namespace))
+	if err != nil || data == "" {
+		return defaults
+	}
+	var saved metricsQuery
+	if err := json.Unmarshal([]byte(data), &saved); err != nil {
+		return defaults
+	}
+	if saved.Timespan != "" {
+		defaults.Timespan = saved.Timespan
+	}
+	if saved.Interval != "" {
+		defaults.Interval = saved.Interval
+	}
+	defaults.Aggregation = saved.Aggregation
+	return defaults
+}
+
+func saveMetricsDefaults(namespace string, defaults metricsQuery) error {
+	if namespace == "" {
+		return nil
+	}
+	if defaults.Timespan == "" {
+		// keep the saved time range when a custom range is used
+		defaults.Timespan = loadMetricsDefaults(namespace).Timespan
+	}
+	data, err := json.Marshal(defaults)
+	if err != nil {
+		return err
+	}
+	return storage.PutMetricsDefaults(strings.ToLower(namespace), string(data))
+}
+
+// supportedMetricsAggregations returns the aggregations that can be used for the metric
+func supportedMetricsAggregations(node *TreeNode) []string {
+	supported := node.Metadata["SupportedAggregations"]
+	if supported == "" {
+		return metricsAggregations
+	}
+	aggregations := []string{}
+	for _, aggregation := range metricsAggregations {
+		for _, value := range strings.Split(supported, ",") {
+			if value == aggregation {
+				aggregations = append(aggregations, aggregation)
+			}
+		}
+	}
+	return aggregations
+}
+
+// timeRange returns the start and end times for the query
+func (q metricsQuery) timeRange(now time.Time) (time.Time, time.Time, error) {
+	if parts := strings.Split(q.Timespan, "/"); len(parts) == 2 {
+		start, err := parseMetricsTime(parts[0])
+		if err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+		end, err := parseMetricsTime(parts[1])
+		if err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+		if !start.Before(end) {
+			return time.Time{}, time.Time{}, fmt.Errorf("The start of the time range must be before the end")
+		}
+		return start, end, nil
+	}
+
+	duration, err := parseISO8601Duration(q.Timespan)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("Time range should be a duration (e.g. PT6H or P3D) or start/end (e.g. 2020-01-01T00:00Z/2020-01-01T06:00Z): %s", err)
+	}
+	end := now.UTC()
+	return end.Add(-duration), end, nil
+}
+
+// queryString returns the timespan, interval and aggregation parameters for the metrics API
+func (q metricsQuery) queryString(start time.Time, end time.Time) string {
+	query := "timespan=" + start.UTC().Format(metricsTimeFormat) + "/" + end.UTC().Format(metricsTimeFormat)
+	if q.Interval != metricsAutoInterval {
+		query += "&interval=" + q.Interval
+	}
+	if q.Aggregation != "" {
+		query += "&aggregation=" + q.Aggregation
+	}
+	if q.Filter != "" {
+		// the API returns the top 10 series by default when splitting
+		query += "&$filter=" + url.QueryEscape(q.Filter)
+	}
+	// autoadjusttimegrain lets the API pick a larger interval if there would be too many points
+	return query + "&autoadjusttimegrain=true"
+}
+
+func parseMetricsTime(value string) (time.Time, error) {
+	formats := []string{time.RFC3339, "2006-01-02T15:04Z", "2006-01-02T15:04", "2006-01-02"}
+	for _, format := range formats {
+		if t, err := time.Parse(format, strings.TrimSpace(value)); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("Unable to parse time '%s' (use e.g. 2020-01-01T06:00Z)", value)
+}
+
+var ansiEscapeRegex = regexp.MustCompile("\x1b\\[[0-9;]*m")
+
+var iso8601DurationRegex = regexp.MustCompile(`^P(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?)?$`)
+
+// parseISO8601Duration parses the day and time parts of an ISO 8601 duration, e.g. P1DT12H
+func parseISO8601Duration(value string) (time.Duration, error) {
+	matches := iso8601DurationRegex.FindStringSubmatch(strings.ToUpper(value))
+	if matches == nil || value == "P" || strings.HasSuffix(value, "T") {
+		return 0, fmt.Errorf("Invalid duration '%s'", value)
+	}
+	units := []time.Duration{24 * time.Hour, time.Hour, time.Minute, time.Second}
+	var duration time.Duration
+	for i, unit := range units {
+		if matches[i+1] == "" {
+			continue
+		}
+		count, err := strconv.Atoi(matches[i+1])
+		if err != nil {
+			return 0, err
+		}
+		duration += time.Duration(count) * unit
+	}
+	if duration <= 0 {
+		return 0, fmt.Errorf("Invalid duration '%s'", value)
+	}
+	return duration, nil
+}
+
+// renderTimeAxis returns tick and label lines to draw under an asciigraph plot with the specified number of columns
+func renderTimeAxis(graph string, columns int, start time.Time, end time.Time) string {
+	if columns < 2 {
+		return ""
+	}
+	// The plot is drawn after the y-axis labels, find the axis to line up with the data
+	firstLine := []rune(ansiEscapeRegex.ReplaceAllString(strings.SplitN(graph, "\n", 2)[0], ""))
+	axisIndex := 0
+	for i, r := range firstLine {
+		if r == '┤' || r == '┼' {
+			axisIndex = i
+			break
+		}
+	}
+
+	layout := "15:04"
+	span := end.Sub(start)
+	if span > 7*24*time.Hour {
+		layout = "Jan 02"
+	} else if span > 24*time.Hour {
+		layout = "Jan 02 15:04"
+	}
+	labelWidth := len(start.Format(layout))
+
+	labelCount := columns / (labelWidth + 4)
+	if labelCount < 2 {
+		labelCount = 2
+	}
+
+	ticks := []rune(strings.Repeat("─", columns))
+	labels := []rune(strings.Repeat(" ", columns+labelWidth))
+	for i := 0; i < labelCount; i++ {
+		column := i * (columns - 1) / (labelCount - 1)
+		ticks[column] = '┬'
+
+		labelTime := start.Add(time.Duration(float64(span) * float64(column) / float64(columns-1)))
+		label := labelTime.Local().Format(layout)
+		position := column - len(label)/2
+		if position < 0 {
+			position = 0
+		}
+		if position+len(label) > columns && columns > len(label) {
+			position = columns - len(label)
+		}
+		copy(labels[position:], []rune(label))
+	}
+
+	padding := strings.Repeat(" ", axisIndex)
+	return padding + "└" + string(ticks) + "\n" + padding + " " + strings.TrimRight(string(labels), " ")
+}
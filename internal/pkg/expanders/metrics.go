@@ -3,14 +3,18 @@ package expanders
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"net/url"
 	"strings"
+	"testing"
 	"time"
 
 	"github.com/guptarohit/asciigraph"
 	"github.com/lawrencegripper/azbrowse/internal/pkg/style"
 
 	"github.com/lawrencegripper/azbrowse/pkg/armclient"
+	"github.com/nbio/st"
+	"gopkg.in/h2non/gock.v1"
 )
 
 // HACK: To draw the graph this handler has to know how big the display area is...
@@ -24,6 +28,12 @@ var ItemWidgetHeight int
 // ItemWidgetWidth track width of item widget
 var ItemWidgetWidth int
 
+const (
+	metricsGraphType      = "metrics.graph"
+	metricsComparisonType = "metrics.comparison"
+	metricsDashboardType  = "metrics.dashboard"
+)
+
 // Check interface
 var _ Expander = &MetricsExpander{}
 
@@ -60,10 +70,20 @@ func (e *MetricsExpander) Expand(ctx context.Context, currentItem *TreeNode) Exp
 	}
 
 	// We have a metric definition lets draw the graph
-	if currentItem.ItemType == "metrics.graph" {
+	if currentItem.ItemType == metricsGraphType {
 		return e.expandGraph(ctx, currentItem)
 	}
 
+	// We have pinned metrics to draw on one graph
+	if currentItem.ItemType == metricsComparisonType {
+		return e.expandComparison(ctx, currentItem)
+	}
+
+	// We have a saved dashboard to draw as a grid of graphs
+	if currentItem.ItemType == metricsDashboardType {
+		return e.expandDashboard(ctx, currentItem)
+	}
+
 	// We're looking at a top level resource, lets see if it has a metric namespace
 	return e.expandMetricNamespace(ctx, currentItem)
 }
@@ -135,26 +155,52 @@ func (e *MetricsExpander) expandMetricDefinition(ctx context.Context, currentIte
 
 	newItems := []*TreeNode{}
 
+	defaultsByNamespace := map[string]metricsQuery{}
 	for _, metric := range metricsListResponse.Value {
+		defaults, ok := defaultsByNamespace[metric.Namespace]
+		if !ok {
+			defaults = loadMetricsDefaults(metric.Namespace)
+			defaultsByNamespace[metric.Namespace] = defaults
+		}
+
+		dimensions := []string{}
+		for _, dimension := range metric.Dimensions {
+			dimensions = append(dimensions, dimension.Value)
+		}
+
+		supportedAggregations := []string{}
+		aggregation := strings.ToLower(metric.PrimaryAggregationType)
+		for _, supportedAggregation := range metric.SupportedAggregationTypes {
+			supportedAggregation = strings.ToLower(supportedAggregation)
+			supportedAggregations = append(supportedAggregations, supportedAggregation)
+			if supportedAggregation == defaults.Aggregation {
+				aggregation = defaults.Aggregation
+			}
+		}
+
 		newItems = append(newItems, &TreeNode{
 			Name:     metric.Name.Value,
 			Display:  metric.Name.Value + "\n  " + style.Subtle("Unit: "+metric.Unit),
 			ID:       currentItem.Metadata["ResourceID"] + "/providers/microsoft.Insights/metrics",
 			Parentid: currentItem.ID,
-			ExpandURL: currentItem.Metadata["ResourceID"] + "/providers/microsoft.Insights/metrics?timespan=" +
-				time.Now().UTC().Add(-4*time.Hour).Format("2006-01-02T15:04:05.000Z") + "/" +
-				time.Now().UTC().Format("2006-01-02T15:04:05.000Z") + "&interval=PT1M&metricnames=" +
-				url.QueryEscape(metric.Name.Value) + "&aggregation=" +
-				url.QueryEscape(metric.PrimaryAggregationType) +
+			// The time range, interval and aggregation are added from the metadata when the graph is expanded
+			ExpandURL: currentItem.Metadata["ResourceID"] + "/providers/microsoft.Insights/metrics?metricnames=" +
+				url.QueryEscape(metric.Name.Value) +
 				"&metricNamespace=" + url.QueryEscape(metric.Namespace) +
-				"&autoadjusttimegrain=true&validatedimensions=false&api-version=2018-01-01",
-			ItemType:       "metrics.graph",
+				"&validatedimensions=false&api-version=2018-01-01",
+			ItemType:       metricsGraphType,
 			SubscriptionID: currentItem.SubscriptionID,
 			Metadata: map[string]string{
 				"SuppressSwaggerExpand": "true",
 				"SuppressGenericExpand": "true",
-				"AggregationType":       strings.ToLower(metric.PrimaryAggregationType),
+				"AggregationType":       aggregation,
+				"SupportedAggregations": strings.Join(supportedAggregations, ","),
 				"Units":                 strings.ToLower(metric.Unit),
+				"MetricNamespace":       metric.Namespace,
+				"ResourceID":            currentItem.Metadata["ResourceID"],
+				"Dimensions":            strings.Join(dimensions, ","),
+				"Timespan":              defaults.Timespan,
+				"Interval":              defaults.Interval,
 			},
 		})
 	}
@@ -168,58 +214,275 @@ func (e *MetricsExpander) expandMetricDefinition(ctx context.Context, currentIte
 }
 
 func (e *MetricsExpander) expandGraph(ctx context.Context, currentItem *TreeNode) ExpanderResult {
-	data, err := e.client.DoRequest(ctx, "GET", currentItem.ExpandURL)
+	query := getMetricsQuery(currentItem)
+	start, end, err := query.timeRange(time.Now())
 	if err != nil {
 		return ExpanderResult{
 			Err:               err,
-			SourceDescription: "MetricsExpander request metricDefinitions",
+			SourceDescription: "MetricsExpander build metrics query",
 		}
 	}
 
-	var metricResponse armclient.MetricResponse
-	err = json.Unmarshal([]byte(data), &metricResponse)
+	metricResponse, err := e.getMetric(ctx, currentItem, query, start, end)
 	if err != nil {
 		return ExpanderResult{
 			Err:               err,
+			SourceDescription: "MetricsExpander request metrics",
+		}
+	}
+
+	series := metricResponse.getSeries(query.Aggregation)
+
+	// handle empty response
+	if len(series) < 1 {
+		return ExpanderResult{
+			Err:               fmt.Errorf("No data returned for metric"),
 			SourceDescription: "MetricsExpander graphdata failed to deserialise",
 		}
 	}
 
-	caption := style.Title(currentItem.Name) +
-		style.Subtle(" (Aggregate: '"+currentItem.Metadata["AggregationType"]+"' Unit: '"+
-			currentItem.Metadata["Units"]+"')")
+	details := "Aggregate: '" + query.Aggregation + "' Unit: '" + currentItem.Metadata["Units"] + "' Interval: '" + metricResponse.Interval + "'"
+	if query.Filter != "" {
+		details += " Filter: '" + query.Filter + "'"
+	}
+	caption := style.Title(currentItem.Name) + style.Subtle(" ("+details+")")
+
+	// Only show a legend if the metric has been split by a dimension
+	var graph string
+	if query.Filter == "" && len(series) == 1 {
+		graph = drawMetricsGraph(series[0:1], start, end)
+	} else {
+		graph = drawMetricsGraph(series, start, end) + "\n\n" + metricsLegend(series)
+	}
+
+	return ExpanderResult{
+		Response:          ExpanderResponse{Response: "\n\n" + caption + "\n\n" + graph},
+		IsPrimaryResponse: true,
+		SourceDescription: "MetricsExpander build graph",
+	}
+}
 
-	graphData := []float64{}
+func (e *MetricsExpander) expandComparison(ctx context.Context, currentItem *TreeNode) ExpanderResult {
+	pinned := GetPinnedMetrics()
+	if len(pinned) < 1 {
+		return ExpanderResult{
+			Err:               fmt.Errorf("No metrics have been pinned"),
+			SourceDescription: "MetricsExpander build comparison",
+		}
+	}
 
-	// handle empty response
-	if len(metricResponse.Value) < 1 || len(metricResponse.Value[0].Timeseries) < 1 {
+	// All the metrics are plotted over the time range of the first pinned metric
+	start, end, err := getMetricsQuery(pinned[0]).timeRange(time.Now())
+	if err != nil {
 		return ExpanderResult{
 			Err:               err,
-			SourceDescription: "MetricsExpander graphdata failed to deserialise",
+			SourceDescription: "MetricsExpander build metrics query",
 		}
 	}
 
-	for _, datapoint := range metricResponse.Value[0].Timeseries[0].Data {
-		value, success := datapoint[currentItem.Metadata["AggregationType"]].(float64)
-		if success {
-			graphData = append(graphData, value)
-		} else {
-			graphData = append(graphData, float64(0))
+	series := []metricsSeries{}
+	for _, node := range pinned {
+		query := getMetricsQuery(node)
+		query.Filter = "" // compare the metric totals rather than each dimension value
+		metricResponse, err := e.getMetric(WithNodeTenant(ctx, node), node, query, start, end)
+		if err != nil {
+			return ExpanderResult{
+				Err:               err,
+				SourceDescription: "MetricsExpander request metrics",
+			}
+		}
+		metricSeries := metricResponse.getSeries(query.Aggregation)
+		if len(metricSeries) < 1 {
+			continue
+		}
+		series = append(series, metricsSeries{
+			Name:   describePinnedMetric(node),
+			Values: metricSeries[0].Values,
+		})
+	}
+	if len(series) < 1 {
+		return ExpanderResult{
+			Err:               fmt.Errorf("No data returned for pinned metrics"),
+			SourceDescription: "MetricsExpander build comparison",
 		}
 	}
 
-	graph := asciigraph.Plot(graphData,
-		asciigraph.Height(ItemWidgetHeight-6),
-		asciigraph.Width(ItemWidgetWidth-15),
-		asciigraph.Caption("time: 4hrs ago ----> now"))
+	caption := style.Title("Pinned metrics") + style.Subtle(" ("+start.Local().Format("2006-01-02 15:04")+" - "+end.Local().Format("2006-01-02 15:04")+")")
+	graph := drawMetricsGraph(series, start, end) + "\n\n" + metricsLegend(series)
 
 	return ExpanderResult{
-		Response:          ExpanderResponse{Response: "\n\n" + caption + "\n\n" + style.Graph(graph)},
+		Response:          ExpanderResponse{Response: "\n\n" + caption + "\n\n" + graph},
 		IsPrimaryResponse: true,
-		SourceDescription: "MetricsExpander build graph",
+		SourceDescription: "MetricsExpander build comparison",
+	}
+}
+
+type metricResponse struct {
+	armclient.MetricResponse
+}
+
+// getMetric queries the metric for the graph node
+func (e *MetricsExpander) getMetric(ctx context.Context, node *TreeNode, query metricsQuery, start time.Time, end time.Time) (metricResponse, error) {
+	data, err := e.client.DoRequest(ctx, "GET", node.ExpandURL+"&"+query.queryString(start, end))
+	if err != nil {
+		return metricResponse{}, err
+	}
+
+	var response metricResponse
+	err = json.Unmarshal([]byte(data), &response)
+	if err != nil {
+		return metricResponse{}, fmt.Errorf("Failed to deserialise metrics: %s", err)
+	}
+	return response, nil
+}
+
+// getSeries returns a series for each timeseries in the response, named after its dimension values
+func (r metricResponse) getSeries(aggregation string) []metricsSeries {
+	series := []metricsSeries{}
+	if len(r.Value) < 1 {
+		return series
+	}
+	for _, timeseries := range r.Value[0].Timeseries {
+		names := []string{}
+		for _, metadataValue := range timeseries.Metadatavalues {
+			names = append(names, metadataValue.Name.Value+"="+metadataValue.Value)
+		}
+		name := strings.Join(names, ", ")
+		if name == "" {
+			name = r.Value[0].Name.Value
+		}
+
+		values := []float64{}
+		for _, datapoint := range timeseries.Data {
+			value, success := datapoint[aggregation].(float64)
+			if success {
+				values = append(values, value)
+			} else {
+				values = append(values, float64(0))
+			}
+		}
+		series = append(series, metricsSeries{Name: name, Values: values})
 	}
+	return series
+}
+
+// drawMetricsGraph plots the series sized to fit the item widget with a time axis underneath
+func drawMetricsGraph(series []metricsSeries, start time.Time, end time.Time) string {
+	graphWidth := ItemWidgetWidth - 15
+	if graphWidth <= 0 {
+		graphWidth = len(series[0].Values)
+	}
+	graphHeight := ItemWidgetHeight - 8 - len(series)
+
+	var graph string
+	if len(series) == 1 {
+		graph = style.Graph(asciigraph.Plot(series[0].Values,
+			asciigraph.Height(graphHeight),
+			asciigraph.Width(graphWidth)))
+	} else {
+		graph = plotMetricsSeries(series, graphHeight, graphWidth)
+	}
+	return graph + "\n" + style.Subtle(renderTimeAxis(graph, graphWidth, start, end))
 }
 
 func (e *MetricsExpander) testCases() (bool, *[]expanderTestCase) {
-	return false, nil
+	const resourceID = "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/stable/providers/Microsoft.Web/sites/testsite"
+
+	graphGockConfig := func(t *testing.T) {
+		gock.New("https://management.azure.com").
+			Get(resourceID+"/providers/microsoft.Insights/metrics").
+			MatchParam("metricnames", "CpuTime").
+			MatchParam("timespan", "^2020-04-09T10:00:00.000Z/2020-04-09T10:20:00.000Z$").
+			MatchParam("interval", "PT5M").
+			MatchParam("aggregation", "maximum").
+			Reply(200).
+			File("./testdata/armsamples/metrics/cpu.json")
+	}
+	splitGraphGockConfig := func(t *testing.T) {
+		gock.New("https://management.azure.com").
+			Get(resourceID+"/providers/microsoft.Insights/metrics").
+			MatchParam("metricnames", "CpuTime").
+			MatchParam("$filter", "^Instance eq '\\*'$").
+			Reply(200).
+			File("./testdata/armsamples/metrics/cpu-split.json")
+	}
+	dashboardGockConfig := func(t *testing.T) {
+		graphGockConfig(t)
+		gock.New("https://management.azure.com").
+			Get(resourceID+"/providers/microsoft.Insights/metrics").
+			MatchParam("metricnames", "Requests").
+			Reply(500)
+	}
+	dashboard := `{"name":"ops","metrics":[` +
+		`{"name":"CpuTime","expandURL":"` + resourceID + `/providers/microsoft.Insights/metrics?metricnames=CpuTime&metricNamespace=Microsoft.Web%2Fsites&validatedimensions=false&api-version=2018-01-01",` +
+		`"metadata":{"AggregationType":"maximum","Units":"seconds","ResourceID":"` + resourceID + `","Timespan":"2020-04-09T10:00Z/2020-04-09T10:20Z","Interval":"PT5M"}},` +
+		`{"name":"Requests","expandURL":"` + resourceID + `/providers/microsoft.Insights/metrics?metricnames=Requests&metricNamespace=Microsoft.Web%2Fsites&validatedimensions=false&api-version=2018-01-01",` +
+		`"metadata":{"AggregationType":"total","Units":"count","ResourceID":"` + resourceID + `"}}]}`
+
+	return true, &[]expanderTestCase{
+		{
+			name: "Graph->CustomRange",
+			nodeToExpand: &TreeNode{
+				Name:      "CpuTime",
+				ID:        resourceID + "/providers/microsoft.Insights/metrics",
+				ItemType:  metricsGraphType,
+				ExpandURL: resourceID + "/providers/microsoft.Insights/metrics?metricnames=CpuTime&metricNamespace=Microsoft.Web%2Fsites&validatedimensions=false&api-version=2018-01-01",
+				Metadata: map[string]string{
+					"AggregationType": "maximum",
+					"Units":           "seconds",
+					"Timespan":        "2020-04-09T10:00Z/2020-04-09T10:20Z",
+					"Interval":        "PT5M",
+				},
+			},
+			configureGockFunc: &graphGockConfig,
+			treeNodeCheckerFunc: func(t *testing.T, r ExpanderResult) {
+				st.Expect(t, r.Err, nil)
+				st.Expect(t, strings.Contains(r.Response.Response, "Interval: 'PT5M'"), true)
+				// time axis is drawn with a tick at each end of the range
+				st.Expect(t, strings.Contains(r.Response.Response, "└┬"), true)
+				st.Expect(t, strings.Contains(r.Response.Response, "┬\n"), true)
+			},
+		},
+		{
+			name: "Graph->SplitByDimension",
+			nodeToExpand: &TreeNode{
+				Name:      "CpuTime",
+				ID:        resourceID + "/providers/microsoft.Insights/metrics",
+				ItemType:  metricsGraphType,
+				ExpandURL: resourceID + "/providers/microsoft.Insights/metrics?metricnames=CpuTime&metricNamespace=Microsoft.Web%2Fsites&validatedimensions=false&api-version=2018-01-01",
+				Metadata: map[string]string{
+					"AggregationType": "maximum",
+					"Units":           "seconds",
+					"Dimensions":      "Instance",
+					"Filter":          "Instance eq '*'",
+				},
+			},
+			configureGockFunc: &splitGraphGockConfig,
+			treeNodeCheckerFunc: func(t *testing.T, r ExpanderResult) {
+				st.Expect(t, r.Err, nil)
+				// each series is listed in the legend
+				st.Expect(t, strings.Contains(r.Response.Response, "■ instance=RD0001"), true)
+				st.Expect(t, strings.Contains(r.Response.Response, "■ instance=RD0002"), true)
+				st.Expect(t, strings.Contains(r.Response.Response, "6.00 ┤"), true)
+			},
+		},
+		{
+			name: "Dashboard->Grid",
+			nodeToExpand: &TreeNode{
+				ID:        "metrics/dashboards/ops",
+				Name:      "ops",
+				ItemType:  metricsDashboardType,
+				ExpandURL: ExpandURLNotSupported,
+				Metadata:  map[string]string{"Dashboard": dashboard},
+			},
+			configureGockFunc: &dashboardGockConfig,
+			treeNodeCheckerFunc: func(t *testing.T, r ExpanderResult) {
+				st.Expect(t, r.Err, nil)
+				st.Expect(t, strings.Contains(r.Response.Response, "testsite/CpuTime (seconds)"), true)
+				st.Expect(t, strings.Contains(r.Response.Response, "└┬"), true)
+				// a failing metric is reported in its cell without failing the dashboard
+				st.Expect(t, strings.Contains(r.Response.Response, "testsite/Requests (count)"), true)
+			},
+		},
+	}
 }
@@ -0,0 +1,212 @@
+package expanders
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/lawrencegripper/azbrowse/internal/pkg/style"
+)
+
+// NetworkIPFlowFormat describes the 5-tuple accepted by VerifyNetworkIPFlow
+const NetworkIPFlowFormat = "<Inbound|Outbound> <TCP|UDP> <local ip>:<port> <remote ip>:<port>"
+
+type networkIPFlow struct {
+	Direction       string `json:"direction"`
+	Protocol        string `json:"protocol"`
+	LocalIPAddress  string `json:"localIPAddress"`
+	LocalPort       string `json:"localPort"`
+	RemoteIPAddress string `json:"remoteIPAddress"`
+	RemotePort      string `json:"remotePort"`
+}
+
+type networkIPFlowRequest struct {
+	TargetResourceID    string `json:"targetResourceId"`
+	TargetNicResourceID string `json:"targetNicResourceId,omitempty"`
+	networkIPFlow
+}
+
+// parseNetworkIPFlow parses a 5-tuple in the form described by NetworkIPFlowFormat, e.g. "Inbound TCP 10.0.0.4:22 203.0.113.5:50000"
+func parseNetworkIPFlow(flow string) (networkIPFlow, error) {
+	var result networkIPFlow
+	parts := strings.Fields(flow)
+	if len(parts) != 4 {
+		return result, fmt.Errorf("Flow must be in the form %s", NetworkIPFlowFormat)
+	}
+
+	switch strings.ToLower(parts[0]) {
+	case "inbound":
+		result.Direction = "Inbound"
+	case "outbound":
+		result.Direction = "Outbound"
+	default:
+		return result, fmt.Errorf("Direction must be Inbound or Outbound, got %q", parts[0])
+	}
+	result.Protocol = strings.ToUpper(parts[1])
+	if result.Protocol != "TCP" && result.Protocol != "UDP" {
+		return result, fmt.Errorf("Protocol must be TCP or UDP, got %q", parts[1])
+	}
+
+	var err error
+	result.LocalIPAddress, result.LocalPort, err = parseNetworkEndpoint(parts[2])
+	if err != nil {
+		return result, fmt.Errorf("Invalid local address: %s", err)
+	}
+	result.RemoteIPAddress, result.RemotePort, err = parseNetworkEndpoint(parts[3])
+	if err != nil {
+		return result, fmt.Errorf("Invalid remote address: %s", err)
+	}
+	return result, nil
+}
+
+func parseNetworkEndpoint(endpoint string) (string, string, error) {
+	host, port, err := net.SplitHostPort(endpoint)
+	if err != nil {
+		return "", "", err
+	}
+	if net.ParseIP(host) == nil {
+		return "", "", fmt.Errorf("%q isn't an IP address", host)
+	}
+	portNumber, err := strconv.Atoi(port)
+	if err != nil || portNumber < 0 || portNumber > 65535 {
+		return "", "", fmt.Errorf("%q isn't a valid port", port)
+	}
+	return host, port, nil
+}
+
+func getNetworkExpander() *NetworkExpander {
+	for _, h := range getRegisteredExpanders() {
+		if expander, ok := h.(*NetworkExpander); ok {
+			return expander
+		}
+	}
+	return nil
+}
+
+// CanVerifyNetworkIPFlow returns true for virtual machines and network interfaces
+func CanVerifyNetworkIPFlow(node *TreeNode) bool {
+	if getNetworkExpander() == nil {
+		return false
+	}
+	return isNetworkInterface(node) || (IsVirtualMachine(node) && node.ItemType == ResourceType)
+}
+
+// VerifyNetworkIPFlow uses Network Watcher to check whether the flow is allowed to or from the VM or NIC for the node
+// and returns the result with the rule that allowed or denied it. This blocks until Network Watcher returns the result
+func VerifyNetworkIPFlow(ctx context.Context, node *TreeNode, flow string) (string, error) {
+	expander := getNetworkExpander()
+	if expander == nil || !CanVerifyNetworkIPFlow(node) {
+		return "", fmt.Errorf("IP flow can't be verified for %s", node.Name)
+	}
+	parsedFlow, err := parseNetworkIPFlow(flow)
+	if err != nil {
+		return "", err
+	}
+	return expander.verifyIPFlow(WithNodeTenant(ctx, node), node.ID, parsedFlow)
+}
+
+func (e *NetworkExpander) verifyIPFlow(ctx context.Context, resourceID string, flow networkIPFlow) (string, error) {
+	request := networkIPFlowRequest{
+		TargetResourceID: resourceID,
+		networkIPFlow:    flow,
+	}
+
+	// Network Watcher verifies flows for a VM, so for a NIC the request targets the NIC's VM
+	var location string
+	if networkInterfaceIDRegex.MatchString(resourceID) {
+		data, err := e.client.DoRequest(ctx, "GET", resourceID+"?api-version="+networkAPIVersion)
+		if err != nil {
+			return "", fmt.Errorf("Failed to get network interface: %s", err)
+		}
+		var nic networkInterface
+		err = json.Unmarshal([]byte(data), &nic)
+		if err != nil {
+			return "", fmt.Errorf("Error unmarshalling network interface: %s", err)
+		}
+		if nic.Properties.VirtualMachine == nil {
+			return "", fmt.Errorf("IP flow can only be verified for network interfaces attached to a virtual machine")
+		}
+		request.TargetResourceID = nic.Properties.VirtualMachine.ID
+		request.TargetNicResourceID = resourceID
+		location = nic.Location
+	} else {
+		data, err := e.client.DoRequest(ctx, "GET", resourceID+"?api-version="+virtualMachineAPIVersion)
+		if err != nil {
+			return "", fmt.Errorf("Failed to get virtual machine: %s", err)
+		}
+		var vm struct {
+			Location string `json:"location"`
+		}
+		err = json.Unmarshal([]byte(data), &vm)
+		if err != nil {
+			return "", fmt.Errorf("Error unmarshalling virtual machine: %s", err)
+		}
+		location = vm.Location
+	}
+
+	watcherID, err := e.getNetworkWatcher(ctx, getSubscriptionIDFromResourceID(resourceID), location)
+	if err != nil {
+		return "", err
+	}
+
+	body, err := json.Marshal(request)
+	if err != nil {
+		return "", err
+	}
+	data, err := doARMRequestAndWait(ctx, e.client, "POST", watcherID+"/ipFlowVerify?api-version="+networkAPIVersion, body, networkPollInterval)
+	if err != nil {
+		return "", fmt.Errorf("Failed to verify IP flow: %s", err)
+	}
+	var result struct {
+		Access   string `json:"access"`
+		RuleName string `json:"ruleName"`
+	}
+	err = json.Unmarshal(data, &result)
+	if err != nil {
+		return "", fmt.Errorf("Error unmarshalling IP flow verify result: %s", err)
+	}
+
+	access := result.Access
+	if access == "Deny" {
+		access = style.Warning(access)
+	}
+	lines := []string{
+		style.Title("IP flow verify"),
+		"",
+		fmt.Sprintf("%s %s %s:%s %s:%s", flow.Direction, flow.Protocol, flow.LocalIPAddress, flow.LocalPort, flow.RemoteIPAddress, flow.RemotePort),
+		"",
+		"Access: " + access,
+		"Rule:   " + result.RuleName,
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// getNetworkWatcher returns the ID of the Network Watcher for the region
+func (e *NetworkExpander) getNetworkWatcher(ctx context.Context, subscriptionID string, location string) (string, error) {
+	data, err := e.client.DoRequest(ctx, "GET", subscriptionID+"/providers/Microsoft.Network/networkWatchers?api-version="+networkAPIVersion)
+	if err != nil {
+		return "", fmt.Errorf("Failed to list network watchers: %s", err)
+	}
+	var response struct {
+		Value []struct {
+			ID       string `json:"id"`
+			Location string `json:"location"`
+		} `json:"value"`
+	}
+	err = json.Unmarshal([]byte(data), &response)
+	if err != nil {
+		return "", fmt.Errorf("Error unmarshalling network watchers: %s", err)
+	}
+	normalizeLocation := func(location string) string {
+		return strings.ToLower(strings.Replace(location, " ", "", -1))
+	}
+	for _, watcher := range response.Value {
+		if normalizeLocation(watcher.Location) == normalizeLocation(location) {
+			return watcher.ID, nil
+		}
+	}
+	return "", fmt.Errorf("Network Watcher isn't enabled in %s", location)
+}
@@ -0,0 +1,176 @@
+package expanders
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+type networkVirtualNetwork struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	Location   string `json:"location"`
+	Properties struct {
+		AddressSpace struct {
+			AddressPrefixes []string `json:"addressPrefixes"`
+		} `json:"addressSpace"`
+		Subnets []struct {
+			ID         string `json:"id"`
+			Name       string `json:"name"`
+			Properties struct {
+				AddressPrefix        string               `json:"addressPrefix"`
+				NetworkSecurityGroup *networkSubResource  `json:"networkSecurityGroup"`
+				RouteTable           *networkSubResource  `json:"routeTable"`
+				PrivateEndpoints     []networkSubResource `json:"privateEndpoints"`
+			} `json:"properties"`
+		} `json:"subnets"`
+		VirtualNetworkPeerings []networkPeering `json:"virtualNetworkPeerings"`
+	} `json:"properties"`
+}
+
+type networkInterface struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	Location   string `json:"location"`
+	Properties struct {
+		IPConfigurations []struct {
+			Properties struct {
+				PrivateIPAddress string             `json:"privateIPAddress"`
+				Subnet           networkSubResource `json:"subnet"`
+			} `json:"properties"`
+		} `json:"ipConfigurations"`
+		NetworkSecurityGroup *networkSubResource `json:"networkSecurityGroup"`
+		VirtualMachine       *networkSubResource `json:"virtualMachine"`
+		PrivateEndpoint      *networkSubResource `json:"privateEndpoint"`
+	} `json:"properties"`
+}
+
+func (e *NetworkExpander) getVirtualNetwork(ctx context.Context, vnetID string) (networkVirtualNetwork, error) {
+	var vnet networkVirtualNetwork
+	data, err := e.client.DoRequest(ctx, "GET", vnetID+"?api-version="+networkAPIVersion)
+	if err != nil {
+		return vnet, fmt.Errorf("Failed to get virtual network: %s", err)
+	}
+	err = json.Unmarshal([]byte(data), &vnet)
+	if err != nil {
+		return vnet, fmt.Errorf("Error unmarshalling virtual network: %s", err)
+	}
+	return vnet, nil
+}
+
+// expandTopology renders the VNet's subnets with the NICs and private endpoints attached to them and their NSGs
+func (e *NetworkExpander) expandTopology(ctx context.Context, currentItem *TreeNode) ExpanderResult {
+	vnetID := currentItem.Metadata["ResourceID"]
+	vnet, err := e.getVirtualNetwork(ctx, vnetID)
+	if err != nil {
+		return ExpanderResult{
+			Err:               err,
+			SourceDescription: "NetworkExpander request",
+		}
+	}
+
+	// Subnets only reference the IP configurations attached to them so the NICs in the subscription are
+	// listed in a single request to find the NICs in each subnet with their NSGs and VMs
+	nics, err := e.listNetworkInterfaces(ctx, getSubscriptionIDFromResourceID(vnetID))
+	if err != nil {
+		return ExpanderResult{
+			Err:               err,
+			SourceDescription: "NetworkExpander request",
+		}
+	}
+
+	return ExpanderResult{
+		Response:          ExpanderResponse{Response: renderNetworkTopology(vnet, nics), ResponseType: ResponsePlainText},
+		SourceDescription: "NetworkExpander request",
+		IsPrimaryResponse: true,
+	}
+}
+
+func (e *NetworkExpander) listNetworkInterfaces(ctx context.Context, subscriptionID string) ([]networkInterface, error) {
+	nics := []networkInterface{}
+	url := subscriptionID + "/providers/Microsoft.Network/networkInterfaces?api-version=" + networkAPIVersion
+	for url != "" {
+		data, err := e.client.DoRequest(ctx, "GET", url)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to list network interfaces: %s", err)
+		}
+		var response struct {
+			Value    []networkInterface `json:"value"`
+			NextLink string             `json:"nextLink"`
+		}
+		err = json.Unmarshal([]byte(data), &response)
+		if err != nil {
+			return nil, fmt.Errorf("Error unmarshalling network interfaces: %s", err)
+		}
+		nics = append(nics, response.Value...)
+		url, err = getNextLinkPath(response.NextLink)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return nics, nil
+}
+
+// renderNetworkTopology draws the VNet as a tree of subnets with the NICs and private endpoints in each subnet
+func renderNetworkTopology(vnet networkVirtualNetwork, nics []networkInterface) string {
+	lines := []string{vnet.Name + " (" + strings.Join(vnet.Properties.AddressSpace.AddressPrefixes, ", ") + ")"}
+
+	subnets := vnet.Properties.Subnets
+	for i, subnet := range subnets {
+		branch, indent := "├── ", "│   "
+		if i == len(subnets)-1 {
+			branch, indent = "└── ", "    "
+		}
+		line := "subnet " + subnet.Name + " (" + subnet.Properties.AddressPrefix + ")"
+		if subnet.Properties.NetworkSecurityGroup != nil {
+			line += " [nsg: " + networkResourceName(subnet.Properties.NetworkSecurityGroup.ID) + "]"
+		}
+		if subnet.Properties.RouteTable != nil {
+			line += " [routes: " + networkResourceName(subnet.Properties.RouteTable.ID) + "]"
+		}
+		lines = append(lines, branch+line)
+
+		children := []string{}
+		for _, nic := range nics {
+			// Private endpoint NICs are shown as the private endpoint
+			if nic.Properties.PrivateEndpoint != nil {
+				continue
+			}
+			for _, ipConfiguration := range nic.Properties.IPConfigurations {
+				if !strings.EqualFold(ipConfiguration.Properties.Subnet.ID, subnet.ID) {
+					continue
+				}
+				child := "nic " + nic.Name + " " + ipConfiguration.Properties.PrivateIPAddress
+				if nic.Properties.NetworkSecurityGroup != nil {
+					child += " [nsg: " + networkResourceName(nic.Properties.NetworkSecurityGroup.ID) + "]"
+				}
+				if nic.Properties.VirtualMachine != nil {
+					child += " [vm: " + networkResourceName(nic.Properties.VirtualMachine.ID) + "]"
+				}
+				children = append(children, child)
+			}
+		}
+		for _, privateEndpoint := range subnet.Properties.PrivateEndpoints {
+			children = append(children, "private endpoint "+networkResourceName(privateEndpoint.ID))
+		}
+		if len(children) == 0 {
+			children = append(children, "(empty)")
+		}
+		for j, child := range children {
+			childBranch := "├── "
+			if j == len(children)-1 {
+				childBranch = "└── "
+			}
+			lines = append(lines, indent+childBranch+child)
+		}
+	}
+
+	if len(vnet.Properties.VirtualNetworkPeerings) > 0 {
+		lines = append(lines, "")
+		for _, peering := range vnet.Properties.VirtualNetworkPeerings {
+			lines = append(lines, "peered with: "+networkResourceName(peering.Properties.RemoteVirtualNetwork.ID)+" ("+peering.Properties.PeeringState+")")
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,633 @@
+package expanders
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lawrencegripper/azbrowse/internal/pkg/style"
+	"github.com/lawrencegripper/azbrowse/pkg/armclient"
+	"github.com/nbio/st"
+	"gopkg.in/h2non/gock.v1"
+)
+
+const (
+	networkNamespace  = "network"
+	networkAPIVersion = "2019-12-01"
+
+	networkEffectiveSecurityRulesType = "network.effectiveSecurityRules"
+	networkEffectiveRoutesType        = "network.effectiveRoutes"
+	networkPeeringsType               = "network.peerings"
+	networkPrivateEndpointsType       = "network.privateEndpoints"
+	networkTopologyType               = "network.topology"
+	networkInterfacesType             = "network.networkInterfaces"
+
+	// networkPollInterval is used when polling Network Watcher and effective rule operations that don't return Retry-After
+	networkPollInterval = time.Second * 2
+)
+
+var networkInterfaceIDRegex = regexp.MustCompile(`(?i)^/subscriptions/[^/]+/resourceGroups/[^/]+/providers/Microsoft\.Network/networkInterfaces/[^/]+$`)
+var virtualNetworkIDRegex = regexp.MustCompile(`(?i)^/subscriptions/[^/]+/resourceGroups/[^/]+/providers/Microsoft\.Network/virtualNetworks/[^/]+$`)
+
+type networkSubResource struct {
+	ID string `json:"id"`
+}
+
+type networkEffectiveSecurityGroupsResponse struct {
+	Value []struct {
+		NetworkSecurityGroup *networkSubResource `json:"networkSecurityGroup"`
+		Association          struct {
+			Subnet           *networkSubResource `json:"subnet"`
+			NetworkInterface *networkSubResource `json:"networkInterface"`
+		} `json:"association"`
+		EffectiveSecurityRules []struct {
+			Name                        string   `json:"name"`
+			Protocol                    string   `json:"protocol"`
+			SourcePortRange             string   `json:"sourcePortRange"`
+			DestinationPortRange        string   `json:"destinationPortRange"`
+			SourceAddressPrefix         string   `json:"sourceAddressPrefix"`
+			DestinationAddressPrefix    string   `json:"destinationAddressPrefix"`
+			ExpandedSourceAddressPrefix []string `json:"expandedSourceAddressPrefix"`
+			Access                      string   `json:"access"`
+			Priority                    int      `json:"priority"`
+			Direction                   string   `json:"direction"`
+		} `json:"effectiveSecurityRules"`
+	} `json:"value"`
+}
+
+type networkEffectiveRoutesResponse struct {
+	Value []struct {
+		Name             string   `json:"name"`
+		Source           string   `json:"source"`
+		State            string   `json:"state"`
+		AddressPrefix    []string `json:"addressPrefix"`
+		NextHopIPAddress []string `json:"nextHopIpAddress"`
+		NextHopType      string   `json:"nextHopType"`
+	} `json:"value"`
+}
+
+type networkPeering struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	Properties struct {
+		PeeringState              string             `json:"peeringState"`
+		ProvisioningState         string             `json:"provisioningState"`
+		RemoteVirtualNetwork      networkSubResource `json:"remoteVirtualNetwork"`
+		AllowVirtualNetworkAccess bool               `json:"allowVirtualNetworkAccess"`
+		AllowForwardedTraffic     bool               `json:"allowForwardedTraffic"`
+		AllowGatewayTransit       bool               `json:"allowGatewayTransit"`
+		UseRemoteGateways         bool               `json:"useRemoteGateways"`
+	} `json:"properties"`
+}
+
+type networkPrivateLinkServiceConnection struct {
+	Name       string `json:"name"`
+	Properties struct {
+		PrivateLinkServiceID              string   `json:"privateLinkServiceId"`
+		GroupIDs                          []string `json:"groupIds"`
+		PrivateLinkServiceConnectionState struct {
+			Status      string `json:"status"`
+			Description string `json:"description"`
+		} `json:"privateLinkServiceConnectionState"`
+	} `json:"properties"`
+}
+
+type networkPrivateEndpoint struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	Properties struct {
+		Subnet                              networkSubResource                    `json:"subnet"`
+		PrivateLinkServiceConnections       []networkPrivateLinkServiceConnection `json:"privateLinkServiceConnections"`
+		ManualPrivateLinkServiceConnections []networkPrivateLinkServiceConnection `json:"manualPrivateLinkServiceConnections"`
+	} `json:"properties"`
+}
+
+// Check interface
+var _ Expander = &NetworkExpander{}
+
+// NetworkExpander adds views of the effective network configuration to network interfaces, virtual networks
+// and virtual machines: effective security rules and routes, peerings, private endpoints and the subnet topology
+type NetworkExpander struct {
+	ExpanderBase
+	client *armclient.Client
+}
+
+func (e *NetworkExpander) setClient(c *armclient.Client) {
+	e.client = c
+}
+
+// Name returns the name of the expander
+func (e *NetworkExpander) Name() string {
+	return "NetworkExpander"
+}
+
+func isNetworkInterface(node *TreeNode) bool {
+	return node != nil && node.ItemType == ResourceType && networkInterfaceIDRegex.MatchString(node.ID)
+}
+
+func isVirtualNetwork(node *TreeNode) bool {
+	return node != nil && node.ItemType == ResourceType && virtualNetworkIDRegex.MatchString(node.ID)
+}
+
+// DoesExpand checks if this is a network interface, virtual network, virtual machine or one of the nodes added for them
+func (e *NetworkExpander) DoesExpand(ctx context.Context, currentItem *TreeNode) (bool, error) {
+	if currentItem.Namespace == networkNamespace {
+		return true, nil
+	}
+	return isNetworkInterface(currentItem) || isVirtualNetwork(currentItem) || (IsVirtualMachine(currentItem) && currentItem.ItemType == ResourceType), nil
+}
+
+// Expand adds the network nodes to a resource or expands them
+func (e *NetworkExpander) Expand(ctx context.Context, currentItem *TreeNode) ExpanderResult {
+	switch currentItem.ItemType {
+	case networkEffectiveSecurityRulesType:
+		return e.expandEffectiveSecurityRules(ctx, currentItem)
+	case networkEffectiveRoutesType:
+		return e.expandEffectiveRoutes(ctx, currentItem)
+	case networkPeeringsType:
+		return e.expandPeerings(ctx, currentItem)
+	case networkPrivateEndpointsType:
+		return e.expandPrivateEndpoints(ctx, currentItem)
+	case networkTopologyType:
+		return e.expandTopology(ctx, currentItem)
+	case networkInterfacesType:
+		return e.expandVirtualMachineNetworkInterfaces(ctx, currentItem)
+	}
+
+	newItems := []*TreeNode{}
+	switch {
+	case isNetworkInterface(currentItem):
+		newItems = append(newItems,
+			e.newNode(currentItem, networkEffectiveSecurityRulesType, "Effective security rules"),
+			e.newNode(currentItem, networkEffectiveRoutesType, "Effective routes"))
+	case isVirtualNetwork(currentItem):
+		newItems = append(newItems,
+			e.newNode(currentItem, networkTopologyType, "Topology"),
+			e.newNode(currentItem, networkPeeringsType, "Peerings"),
+			e.newNode(currentItem, networkPrivateEndpointsType, "Private endpoints"))
+	case IsVirtualMachine(currentItem):
+		newItems = append(newItems, e.newNode(currentItem, networkInterfacesType, "Network interfaces"))
+	}
+	return ExpanderResult{
+		Nodes:             newItems,
+		SourceDescription: "NetworkExpander request",
+	}
+}
+
+func (e *NetworkExpander) newNode(currentItem *TreeNode, itemType string, name string) *TreeNode {
+	return &TreeNode{
+		Parentid:       currentItem.ID,
+		ID:             currentItem.ID + "/<" + itemType + ">",
+		Namespace:      networkNamespace,
+		Name:           name,
+		Display:        name,
+		ItemType:       itemType,
+		ExpandURL:      ExpandURLNotSupported,
+		SubscriptionID: currentItem.SubscriptionID,
+		Metadata: map[string]string{
+			"ResourceID":            currentItem.ID,
+			"SuppressSwaggerExpand": "true",
+			"SuppressGenericExpand": "true",
+		},
+	}
+}
+
+// newResourceNode creates a node for a related resource (e.g. a remote VNet or a NIC) so that it can be browsed to
+func (e *NetworkExpander) newResourceNode(currentItem *TreeNode, id string, armType string, display string) *TreeNode {
+	name := id[strings.LastIndex(id, "/")+1:]
+	return &TreeNode{
+		Parentid:       currentItem.ID,
+		ID:             id,
+		Namespace:      "Microsoft.Network",
+		ArmType:        armType,
+		Name:           name,
+		Display:        style.Subtle("["+armType+"] \n  ") + name + display,
+		ItemType:       ResourceType,
+		ExpandURL:      id + "?api-version=" + networkAPIVersion,
+		SubscriptionID: currentItem.SubscriptionID,
+	}
+}
+
+func networkResourceName(id string) string {
+	if id == "" {
+		return ""
+	}
+	return id[strings.LastIndex(id, "/")+1:]
+}
+
+// expandEffectiveSecurityRules shows the security rules applied to a NIC from its own NSG and its subnet's NSG
+func (e *NetworkExpander) expandEffectiveSecurityRules(ctx context.Context, currentItem *TreeNode) ExpanderResult {
+	// Effective rules are calculated by the host so the operation is polled until it completes
+	data, err := doARMRequestAndWait(ctx, e.client, "POST", currentItem.Metadata["ResourceID"]+"/effectiveNetworkSecurityGroups?api-version="+networkAPIVersion, nil, networkPollInterval)
+	if err != nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Failed to get effective security rules (the NIC must be attached to a running VM): %s", err),
+			SourceDescription: "NetworkExpander request",
+		}
+	}
+	var response networkEffectiveSecurityGroupsResponse
+	err = json.Unmarshal(data, &response)
+	if err != nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Error unmarshalling effective security rules: %s", err),
+			SourceDescription: "NetworkExpander request",
+		}
+	}
+
+	lines := []string{}
+	for _, group := range response.Value {
+		title := "Default rules"
+		if group.NetworkSecurityGroup != nil {
+			title = "NSG " + networkResourceName(group.NetworkSecurityGroup.ID)
+		}
+		if group.Association.Subnet != nil {
+			title += " (subnet " + networkResourceName(group.Association.Subnet.ID) + ")"
+		} else if group.Association.NetworkInterface != nil {
+			title += " (network interface)"
+		}
+		lines = append(lines, style.Title(title), "")
+		lines = append(lines, fmt.Sprintf("%-9s %-8s %-45s %-8s %-20s %-20s %-8s %s", "Direction", "Priority", "Name", "Protocol", "Source", "Destination", "Ports", "Access"))
+
+		rules := group.EffectiveSecurityRules
+		sort.SliceStable(rules, func(i, j int) bool {
+			if rules[i].Direction != rules[j].Direction {
+				return rules[i].Direction < rules[j].Direction
+			}
+			return rules[i].Priority < rules[j].Priority
+		})
+		for _, rule := range rules {
+			line := fmt.Sprintf("%-9s %-8d %-45s %-8s %-20s %-20s %-8s %s",
+				rule.Direction, rule.Priority, rule.Name, rule.Protocol,
+				rule.SourceAddressPrefix+":"+rule.SourcePortRange, rule.DestinationAddressPrefix, rule.DestinationPortRange, rule.Access)
+			if rule.Access == "Deny" {
+				line = style.Warning(line)
+			}
+			lines = append(lines, line)
+		}
+		lines = append(lines, "")
+	}
+	if len(response.Value) == 0 {
+		lines = append(lines, "No network security groups are applied")
+	}
+
+	return ExpanderResult{
+		Response:          ExpanderResponse{Response: strings.Join(lines, "\n"), ResponseType: ResponsePlainText},
+		SourceDescription: "NetworkExpander request",
+		IsPrimaryResponse: true,
+	}
+}
+
+// expandEffectiveRoutes shows the routes applied to a NIC from the system, route tables and gateways
+func (e *NetworkExpander) expandEffectiveRoutes(ctx context.Context, currentItem *TreeNode) ExpanderResult {
+	data, err := doARMRequestAndWait(ctx, e.client, "POST", currentItem.Metadata["ResourceID"]+"/effectiveRouteTable?api-version="+networkAPIVersion, nil, networkPollInterval)
+	if err != nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Failed to get effective routes (the NIC must be attached to a running VM): %s", err),
+			SourceDescription: "NetworkExpander request",
+		}
+	}
+	var response networkEffectiveRoutesResponse
+	err = json.Unmarshal(data, &response)
+	if err != nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Error unmarshalling effective routes: %s", err),
+			SourceDescription: "NetworkExpander request",
+		}
+	}
+
+	lines := []string{
+		style.Title("Effective routes"),
+		"",
+		fmt.Sprintf("%-10s %-8s %-20s %-24s %s", "Source", "State", "Address prefix", "Next hop type", "Next hop"),
+	}
+	for _, route := range response.Value {
+		line := fmt.Sprintf("%-10s %-8s %-20s %-24s %s",
+			route.Source, route.State, strings.Join(route.AddressPrefix, ","), route.NextHopType, strings.Join(route.NextHopIPAddress, ","))
+		if route.State != "Active" {
+			line = style.Subtle(line)
+		}
+		lines = append(lines, line)
+	}
+
+	return ExpanderResult{
+		Response:          ExpanderResponse{Response: strings.Join(lines, "\n"), ResponseType: ResponsePlainText},
+		SourceDescription: "NetworkExpander request",
+		IsPrimaryResponse: true,
+	}
+}
+
+func drawPeeringStatus(peeringState string) string {
+	switch peeringState {
+	case "Connected":
+		return DrawStatus("Succeeded")
+	case "Initiated":
+		return DrawStatus("Provisioning")
+	case "Disconnected":
+		return DrawStatus("Failed")
+	}
+	return ""
+}
+
+// expandPeerings lists the VNet's peerings with their state and the remote VNets so that they can be browsed to
+func (e *NetworkExpander) expandPeerings(ctx context.Context, currentItem *TreeNode) ExpanderResult {
+	data, err := e.client.DoRequest(ctx, "GET", currentItem.Metadata["ResourceID"]+"/virtualNetworkPeerings?api-version="+networkAPIVersion)
+	if err != nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Failed to list peerings: %s", err),
+			SourceDescription: "NetworkExpander request",
+		}
+	}
+	var response struct {
+		Value []networkPeering `json:"value"`
+	}
+	err = json.Unmarshal([]byte(data), &response)
+	if err != nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Error unmarshalling peerings: %s", err),
+			SourceDescription: "NetworkExpander request",
+		}
+	}
+
+	newItems := []*TreeNode{}
+	lines := []string{style.Title("Peerings"), ""}
+	for _, peering := range response.Value {
+		properties := peering.Properties
+		remoteName := networkResourceName(properties.RemoteVirtualNetwork.ID)
+		node := e.newResourceNode(currentItem, properties.RemoteVirtualNetwork.ID, "Microsoft.Network/virtualNetworks", "\n  "+style.Subtle("Peering: "+peering.Name+" ("+properties.PeeringState+")"))
+		node.StatusIndicator = drawPeeringStatus(properties.PeeringState)
+		newItems = append(newItems, node)
+
+		lines = append(lines, fmt.Sprintf("%s -> %s: %s", peering.Name, remoteName, properties.PeeringState))
+		lines = append(lines, style.Subtle(fmt.Sprintf("  VNet access: %t, forwarded traffic: %t, gateway transit: %t, remote gateways: %t",
+			properties.AllowVirtualNetworkAccess, properties.AllowForwardedTraffic, properties.AllowGatewayTransit, properties.UseRemoteGateways)))
+	}
+	if len(response.Value) == 0 {
+		lines = append(lines, "No peerings")
+	}
+
+	return ExpanderResult{
+		Nodes:             newItems,
+		Response:          ExpanderResponse{Response: strings.Join(lines, "\n"), ResponseType: ResponsePlainText},
+		SourceDescription: "NetworkExpander request",
+		IsPrimaryResponse: true,
+	}
+}
+
+// expandPrivateEndpoints lists the private endpoints in the VNet's subnets with the state of their connections
+func (e *NetworkExpander) expandPrivateEndpoints(ctx context.Context, currentItem *TreeNode) ExpanderResult {
+	vnet, err := e.getVirtualNetwork(ctx, currentItem.Metadata["ResourceID"])
+	if err != nil {
+		return ExpanderResult{
+			Err:               err,
+			SourceDescription: "NetworkExpander request",
+		}
+	}
+
+	newItems := []*TreeNode{}
+	lines := []string{style.Title("Private endpoints"), ""}
+	for _, subnet := range vnet.Properties.Subnets {
+		for _, privateEndpointRef := range subnet.Properties.PrivateEndpoints {
+			data, err := e.client.DoRequest(ctx, "GET", privateEndpointRef.ID+"?api-version="+networkAPIVersion)
+			if err != nil {
+				return ExpanderResult{
+					Err:               fmt.Errorf("Failed to get private endpoint: %s", err),
+					SourceDescription: "NetworkExpander request",
+				}
+			}
+			var privateEndpoint networkPrivateEndpoint
+			err = json.Unmarshal([]byte(data), &privateEndpoint)
+			if err != nil {
+				return ExpanderResult{
+					Err:               fmt.Errorf("Error unmarshalling private endpoint: %s", err),
+					SourceDescription: "NetworkExpander request",
+				}
+			}
+
+			lines = append(lines, privateEndpoint.Name+" "+style.Subtle("(subnet "+subnet.Name+")"))
+			status := ""
+			connections := append(privateEndpoint.Properties.PrivateLinkServiceConnections, privateEndpoint.Properties.ManualPrivateLinkServiceConnections...)
+			for _, connection := range connections {
+				state := connection.Properties.PrivateLinkServiceConnectionState
+				line := fmt.Sprintf("  %s -> %s [%s]: %s", connection.Name, networkResourceName(connection.Properties.PrivateLinkServiceID), strings.Join(connection.Properties.GroupIDs, ","), state.Status)
+				if state.Status != "Approved" {
+					line = style.Warning(line)
+				}
+				lines = append(lines, line)
+				if state.Description != "" {
+					lines = append(lines, "    "+style.Subtle(state.Description))
+				}
+				status = state.Status
+			}
+
+			node := e.newResourceNode(currentItem, privateEndpoint.ID, "Microsoft.Network/privateEndpoints", "\n  "+style.Subtle("Connection: "+status))
+			switch status {
+			case "Approved":
+				node.StatusIndicator = DrawStatus("Succeeded")
+			case "Pending":
+				node.StatusIndicator = DrawStatus("Provisioning")
+			case "Rejected", "Disconnected":
+				node.StatusIndicator = DrawStatus("Failed")
+			}
+			newItems = append(newItems, node)
+		}
+	}
+	if len(newItems) == 0 {
+		lines = append(lines, "No private endpoints")
+	}
+
+	return ExpanderResult{
+		Nodes:             newItems,
+		Response:          ExpanderResponse{Response: strings.Join(lines, "\n"), ResponseType: ResponsePlainText},
+		SourceDescription: "NetworkExpander request",
+		IsPrimaryResponse: true,
+	}
+}
+
+// expandVirtualMachineNetworkInterfaces lists the NICs of a VM so that their effective rules and routes can be viewed
+func (e *NetworkExpander) expandVirtualMachineNetworkInterfaces(ctx context.Context, currentItem *TreeNode) ExpanderResult {
+	data, err := e.client.DoRequest(ctx, "GET", currentItem.Metadata["ResourceID"]+"?api-version="+virtualMachineAPIVersion)
+	if err != nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Failed to get virtual machine: %s", err),
+			SourceDescription: "NetworkExpander request",
+		}
+	}
+	var vm struct {
+		Properties struct {
+			NetworkProfile struct {
+				NetworkInterfaces []networkSubResource `json:"networkInterfaces"`
+			} `json:"networkProfile"`
+		} `json:"properties"`
+	}
+	err = json.Unmarshal([]byte(data), &vm)
+	if err != nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Error unmarshalling virtual machine: %s", err),
+			SourceDescription: "NetworkExpander request",
+		}
+	}
+
+	newItems := []*TreeNode{}
+	for _, nic := range vm.Properties.NetworkProfile.NetworkInterfaces {
+		newItems = append(newItems, e.newResourceNode(currentItem, nic.ID, "Microsoft.Network/networkInterfaces", ""))
+	}
+	return ExpanderResult{
+		Nodes:             newItems,
+		SourceDescription: "NetworkExpander request",
+	}
+}
+
+func (e *NetworkExpander) testCases() (bool, *[]expanderTestCase) {
+	const resourceGroupID = "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/stable/providers/Microsoft.Network"
+	const nicID = resourceGroupID + "/networkInterfaces/testvm-nic"
+	const vnetID = resourceGroupID + "/virtualNetworks/testvnet"
+	const vmID = "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/stable/providers/Microsoft.Compute/virtualMachines/testvm"
+	const pollURI = "https://management.azure.com/subscriptions/00000000-0000-0000-0000-000000000000/providers/Microsoft.Network/locations/westeurope/operationResults/1"
+
+	nicNode := &TreeNode{ID: nicID, Name: "testvm-nic", ItemType: ResourceType}
+	vnetNode := &TreeNode{ID: vnetID, Name: "testvnet", ItemType: ResourceType}
+	vmNode := &TreeNode{ID: vmID, Name: "testvm", ItemType: ResourceType}
+
+	noRequestsGockConfig := func(t *testing.T) {}
+	effectiveRulesGockConfig := func(t *testing.T) {
+		gock.New("https://management.azure.com").
+			Post(nicID+"/effectiveNetworkSecurityGroups").
+			Reply(202).
+			SetHeader("Location", pollURI).
+			SetHeader("Retry-After", "0")
+		gock.New(pollURI).
+			Reply(200).
+			File("./testdata/armsamples/network/effectiveNetworkSecurityGroups.json")
+	}
+	effectiveRoutesGockConfig := func(t *testing.T) {
+		gock.New("https://management.azure.com").
+			Post(nicID + "/effectiveRouteTable").
+			Reply(200).
+			File("./testdata/armsamples/network/effectiveRouteTable.json")
+	}
+	peeringsGockConfig := func(t *testing.T) {
+		gock.New("https://management.azure.com").
+			Get(vnetID + "/virtualNetworkPeerings").
+			Reply(200).
+			File("./testdata/armsamples/network/peerings.json")
+	}
+	privateEndpointsGockConfig := func(t *testing.T) {
+		gock.New("https://management.azure.com").
+			Get(vnetID).
+			Reply(200).
+			File("./testdata/armsamples/network/vnet.json")
+		gock.New("https://management.azure.com").
+			Get(resourceGroupID + "/privateEndpoints/teststorage-pe").
+			Reply(200).
+			File("./testdata/armsamples/network/privateEndpoint.json")
+	}
+	topologyGockConfig := func(t *testing.T) {
+		gock.New("https://management.azure.com").
+			Get(vnetID).
+			Reply(200).
+			File("./testdata/armsamples/network/vnet.json")
+		gock.New("https://management.azure.com").
+			Get("/subscriptions/00000000-0000-0000-0000-000000000000/providers/Microsoft.Network/networkInterfaces").
+			Reply(200).
+			File("./testdata/armsamples/network/networkInterfaces.json")
+	}
+	vmNetworkGockConfig := func(t *testing.T) {
+		gock.New("https://management.azure.com").
+			Get(vmID).
+			Reply(200).
+			File("./testdata/armsamples/virtualmachines/vm.json")
+	}
+
+	return true, &[]expanderTestCase{
+		{
+			name:              "Network->NetworkInterface",
+			nodeToExpand:      nicNode,
+			configureGockFunc: &noRequestsGockConfig,
+			treeNodeCheckerFunc: func(t *testing.T, r ExpanderResult) {
+				st.Expect(t, r.Err, nil)
+				st.Expect(t, len(r.Nodes), 2)
+				st.Expect(t, r.Nodes[0].ItemType, networkEffectiveSecurityRulesType)
+				st.Expect(t, r.Nodes[1].ItemType, networkEffectiveRoutesType)
+			},
+		},
+		{
+			name:              "Network->EffectiveSecurityRules",
+			nodeToExpand:      e.newNode(nicNode, networkEffectiveSecurityRulesType, "Effective security rules"),
+			configureGockFunc: &effectiveRulesGockConfig,
+			treeNodeCheckerFunc: func(t *testing.T, r ExpanderResult) {
+				st.Expect(t, r.Err, nil)
+				st.Expect(t, strings.Contains(r.Response.Response, "NSG testvm-nsg (network interface)"), true)
+				st.Expect(t, strings.Contains(r.Response.Response, "securityRules/SSH"), true)
+				// Rules are sorted by direction then priority
+				st.Expect(t, strings.Index(r.Response.Response, "securityRules/SSH") < strings.Index(r.Response.Response, "defaultSecurityRules/DenyAllInBound"), true)
+			},
+		},
+		{
+			name:              "Network->EffectiveRoutes",
+			nodeToExpand:      e.newNode(nicNode, networkEffectiveRoutesType, "Effective routes"),
+			configureGockFunc: &effectiveRoutesGockConfig,
+			treeNodeCheckerFunc: func(t *testing.T, r ExpanderResult) {
+				st.Expect(t, r.Err, nil)
+				st.Expect(t, strings.Contains(r.Response.Response, "10.0.0.0/16"), true)
+				st.Expect(t, strings.Contains(r.Response.Response, "VirtualAppliance"), true)
+			},
+		},
+		{
+			name:              "Network->Peerings",
+			nodeToExpand:      e.newNode(vnetNode, networkPeeringsType, "Peerings"),
+			configureGockFunc: &peeringsGockConfig,
+			treeNodeCheckerFunc: func(t *testing.T, r ExpanderResult) {
+				st.Expect(t, r.Err, nil)
+				st.Expect(t, len(r.Nodes), 1)
+				st.Expect(t, r.Nodes[0].Name, "hubvnet")
+				st.Expect(t, r.Nodes[0].StatusIndicator, DrawStatus("Succeeded"))
+				st.Expect(t, strings.Contains(r.Response.Response, "testvnet-to-hub -> hubvnet: Connected"), true)
+			},
+		},
+		{
+			name:              "Network->PrivateEndpoints",
+			nodeToExpand:      e.newNode(vnetNode, networkPrivateEndpointsType, "Private endpoints"),
+			configureGockFunc: &privateEndpointsGockConfig,
+			treeNodeCheckerFunc: func(t *testing.T, r ExpanderResult) {
+				st.Expect(t, r.Err, nil)
+				st.Expect(t, len(r.Nodes), 1)
+				st.Expect(t, r.Nodes[0].Name, "teststorage-pe")
+				st.Expect(t, strings.Contains(r.Response.Response, "teststorage [blob]: Approved"), true)
+			},
+		},
+		{
+			name:              "Network->Topology",
+			nodeToExpand:      e.newNode(vnetNode, networkTopologyType, "Topology"),
+			configureGockFunc: &topologyGockConfig,
+			treeNodeCheckerFunc: func(t *testing.T, r ExpanderResult) {
+				st.Expect(t, r.Err, nil)
+				st.Expect(t, r.Response.Response, strings.Join([]string{
+					"testvnet (10.0.0.0/16)",
+					"├── subnet default (10.0.0.0/24) [nsg: default-nsg]",
+					"│   ├── nic testvm-nic 10.0.0.4 [nsg: testvm-nsg] [vm: testvm]",
+					"│   └── private endpoint teststorage-pe",
+					"└── subnet AzureBastionSubnet (10.0.1.0/26)",
+					"    └── (empty)",
+					"",
+					"peered with: hubvnet (Connected)",
+				}, "\n"))
+			},
+		},
+		{
+			name:              "Network->VirtualMachineNetworkInterfaces",
+			nodeToExpand:      e.newNode(vmNode, networkInterfacesType, "Network interfaces"),
+			configureGockFunc: &vmNetworkGockConfig,
+			treeNodeCheckerFunc: func(t *testing.T, r ExpanderResult) {
+				st.Expect(t, r.Err, nil)
+				st.Expect(t, len(r.Nodes), 1)
+				st.Expect(t, r.Nodes[0].ArmType, "Microsoft.Network/networkInterfaces")
+				st.Expect(t, r.Nodes[0].Name, "testvm-nic")
+			},
+		},
+	}
+}
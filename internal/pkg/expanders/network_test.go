@@ -0,0 +1,79 @@
+package expanders
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/lawrencegripper/azbrowse/pkg/armclient"
+	"github.com/nbio/st"
+	"gopkg.in/h2non/gock.v1"
+)
+
+func Test_NetworkExpander_VerifyIPFlow(t *testing.T) {
+	const nicID = "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/stable/providers/Microsoft.Network/networkInterfaces/testvm-nic"
+	const vmID = "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/stable/providers/Microsoft.Compute/virtualMachines/testvm"
+	const watcherID = "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/NetworkWatcherRG/providers/Microsoft.Network/networkWatchers/NetworkWatcher_westeurope"
+	const pollURI = "https://management.azure.com/subscriptions/00000000-0000-0000-0000-000000000000/providers/Microsoft.Network/locations/westeurope/operationResults/2"
+	defer gock.Off()
+	gock.New("https://management.azure.com").
+		Get(nicID).
+		Reply(200).
+		File("./testdata/armsamples/network/networkInterface.json")
+	gock.New("https://management.azure.com").
+		Get("/subscriptions/00000000-0000-0000-0000-000000000000/providers/Microsoft.Network/networkWatchers").
+		Reply(200).
+		File("./testdata/armsamples/network/networkWatchers.json")
+	gock.New("https://management.azure.com").
+		Post(watcherID+"/ipFlowVerify").
+		JSON(map[string]string{
+			"targetResourceId":    vmID,
+			"targetNicResourceId": nicID,
+			"direction":           "Inbound",
+			"protocol":            "TCP",
+			"localIPAddress":      "10.0.0.4",
+			"localPort":           "22",
+			"remoteIPAddress":     "203.0.113.5",
+			"remotePort":          "50000",
+		}).
+		Reply(202).
+		SetHeader("Location", pollURI).
+		SetHeader("Retry-After", "0")
+	gock.New(pollURI).
+		Reply(200).
+		JSON(map[string]string{"access": "Allow", "ruleName": "securityRules/SSH"})
+
+	httpClient := &http.Client{Transport: &http.Transport{}}
+	gock.InterceptClient(httpClient)
+	expander := &NetworkExpander{client: armclient.NewClientFromConfig(httpClient, DummyTokenFunc(), 5000)}
+
+	flow, err := parseNetworkIPFlow("inbound tcp 10.0.0.4:22 203.0.113.5:50000")
+	st.Expect(t, err, nil)
+	output, err := expander.verifyIPFlow(context.Background(), nicID, flow)
+	st.Expect(t, err, nil)
+	st.Expect(t, strings.Contains(output, "Access: Allow"), true)
+	st.Expect(t, strings.Contains(output, "Rule:   securityRules/SSH"), true)
+	st.Expect(t, gock.IsDone(), true)
+}
+
+func Test_parseNetworkIPFlow(t *testing.T) {
+	tests := []struct {
+		flow    string
+		wantErr bool
+	}{
+		{flow: "Outbound UDP 10.0.0.4:5000 8.8.8.8:53"},
+		{flow: "Inbound TCP [fd00::4]:443 [2001:db8::1]:50000"},
+		{flow: "Inbound TCP 10.0.0.4:22", wantErr: true},
+		{flow: "Sideways TCP 10.0.0.4:22 203.0.113.5:50000", wantErr: true},
+		{flow: "Inbound ICMP 10.0.0.4:22 203.0.113.5:50000", wantErr: true},
+		{flow: "Inbound TCP myhost:22 203.0.113.5:50000", wantErr: true},
+		{flow: "Inbound TCP 10.0.0.4:70000 203.0.113.5:50000", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.flow, func(t *testing.T) {
+			_, err := parseNetworkIPFlow(tt.flow)
+			st.Expect(t, err != nil, tt.wantErr)
+		})
+	}
+}
@@ -0,0 +1,141 @@
+package expanders
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// redisTimeout is used for connections to the cache when the context has no deadline
+const redisTimeout = 30 * time.Second
+
+// redisDialFunc opens a connection to the cache at the host:port address
+type redisDialFunc func(ctx context.Context, address string) (net.Conn, error)
+
+// dialRedisTLS connects to the SSL port of the cache
+func dialRedisTLS(ctx context.Context, address string) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: redisTimeout}
+	return tls.DialWithDialer(dialer, "tcp", address, &tls.Config{
+		ServerName: address[:strings.LastIndex(address, ":")],
+	})
+}
+
+// redisError is an error reply from the cache
+type redisError string
+
+func (e redisError) Error() string {
+	return string(e)
+}
+
+// redisConn is a minimal client for the Redis protocol (RESP), enough to run the diagnostic commands
+// that aren't exposed through ARM. Replies are returned as string, int64, []interface{} or nil
+type redisConn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// newRedisConn connects and authenticates to the cache
+func newRedisConn(ctx context.Context, dial redisDialFunc, address string, password string) (*redisConn, error) {
+	conn, err := dial(ctx, address)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to connect to %s: %s", address, err)
+	}
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(redisTimeout)
+	}
+	err = conn.SetDeadline(deadline)
+	if err != nil {
+		conn.Close() //nolint: errcheck
+		return nil, err
+	}
+
+	c := &redisConn{conn: conn, reader: bufio.NewReader(conn)}
+	_, err = c.do("AUTH", password)
+	if err != nil {
+		c.Close() //nolint: errcheck
+		return nil, fmt.Errorf("Failed to authenticate to %s: %s", address, err)
+	}
+	return c, nil
+}
+
+// Close closes the connection
+func (c *redisConn) Close() error {
+	return c.conn.Close()
+}
+
+// do sends the command and returns the reply
+func (c *redisConn) do(args ...string) (interface{}, error) {
+	var command strings.Builder
+	command.WriteString("*" + strconv.Itoa(len(args)) + "\r\n")
+	for _, arg := range args {
+		command.WriteString("$" + strconv.Itoa(len(arg)) + "\r\n" + arg + "\r\n")
+	}
+	_, err := io.WriteString(c.conn, command.String())
+	if err != nil {
+		return nil, err
+	}
+	return readRedisReply(c.reader)
+}
+
+// readRedisReply reads a single (possibly nested) reply. Error replies are returned as a redisError
+func readRedisReply(reader *bufio.Reader) (interface{}, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if len(line) == 0 {
+		return nil, fmt.Errorf("Unexpected empty reply")
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, redisError(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		length, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("Invalid bulk string length %q", line[1:])
+		}
+		if length < 0 {
+			return nil, nil
+		}
+		buf := make([]byte, length+2) // include the trailing \r\n
+		_, err = io.ReadFull(reader, buf)
+		if err != nil {
+			return nil, err
+		}
+		return string(buf[:length]), nil
+	case '*':
+		count, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("Invalid array length %q", line[1:])
+		}
+		if count < 0 {
+			return nil, nil
+		}
+		items := make([]interface{}, count)
+		for i := range items {
+			items[i], err = readRedisReply(reader)
+			if err != nil {
+				// an error reply inside an array is a value rather than a failure of the whole reply
+				if _, ok := err.(redisError); !ok {
+					return nil, err
+				}
+				items[i] = err
+			}
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("Unexpected reply %q", line)
+}
@@ -0,0 +1,387 @@
+package expanders
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lawrencegripper/azbrowse/internal/pkg/style"
+	"github.com/lawrencegripper/azbrowse/pkg/armclient"
+	"github.com/nbio/st"
+	"gopkg.in/h2non/gock.v1"
+)
+
+const (
+	redisNamespace  = "redis"
+	redisAPIVersion = "2019-07-01"
+
+	redisInfoType          = "redis.info"
+	redisSlowLogType       = "redis.slowLog"
+	redisPatchScheduleType = "redis.patchSchedule"
+
+	// redisSlowLogCount is the number of slow log entries requested, the cache keeps 128 by default
+	redisSlowLogCount = 128
+	// redisMaxCommandLength truncates long commands (e.g. MSET with large values) in the slow log
+	redisMaxCommandLength = 100
+)
+
+var redisIDRegex = regexp.MustCompile(`(?i)^/subscriptions/[^/]+/resourceGroups/[^/]+/providers/Microsoft\.Cache/Redis/[^/]+$`)
+
+// Check interface
+var _ Expander = &RedisExpander{}
+
+// RedisExpander adds the cache info, slow log and patch schedule to Redis caches
+type RedisExpander struct {
+	ExpanderBase
+	client *armclient.Client
+	dial   redisDialFunc
+}
+
+// NewRedisExpander creates a new instance of RedisExpander
+func NewRedisExpander(armclient *armclient.Client) *RedisExpander {
+	return &RedisExpander{
+		client: armclient,
+		dial:   dialRedisTLS,
+	}
+}
+
+func (e *RedisExpander) setClient(c *armclient.Client) {
+	e.client = c
+}
+
+// Name returns the name of the expander
+func (e *RedisExpander) Name() string {
+	return "RedisExpander"
+}
+
+func isRedisCache(node *TreeNode) bool {
+	return node != nil && node.ItemType == ResourceType && redisIDRegex.MatchString(node.ID)
+}
+
+// DoesExpand checks if this is a Redis cache or one of the nodes added for it
+func (e *RedisExpander) DoesExpand(ctx context.Context, currentItem *TreeNode) (bool, error) {
+	if currentItem.Namespace == redisNamespace {
+		return true, nil
+	}
+	return isRedisCache(currentItem), nil
+}
+
+// Expand adds the Redis nodes to a cache or expands them
+func (e *RedisExpander) Expand(ctx context.Context, currentItem *TreeNode) ExpanderResult {
+	var response string
+	var err error
+	switch currentItem.ItemType {
+	case redisInfoType:
+		response, err = e.getInfo(ctx, currentItem.Metadata["ResourceID"])
+	case redisSlowLogType:
+		response, err = e.getSlowLog(ctx, currentItem.Metadata["ResourceID"])
+	case redisPatchScheduleType:
+		response, err = e.getPatchSchedule(ctx, currentItem.Metadata["ResourceID"])
+	default:
+		return ExpanderResult{
+			Nodes: []*TreeNode{
+				e.newNode(currentItem, redisInfoType, "Cache info"),
+				e.newNode(currentItem, redisSlowLogType, "Slow log"),
+				e.newNode(currentItem, redisPatchScheduleType, "Patch schedule"),
+			},
+			SourceDescription: "RedisExpander request",
+		}
+	}
+	if err != nil {
+		return ExpanderResult{
+			Err:               err,
+			SourceDescription: "RedisExpander request",
+		}
+	}
+	return ExpanderResult{
+		Response:          ExpanderResponse{Response: response, ResponseType: ResponsePlainText},
+		SourceDescription: "RedisExpander request",
+		IsPrimaryResponse: true,
+	}
+}
+
+func (e *RedisExpander) newNode(currentItem *TreeNode, itemType string, name string) *TreeNode {
+	return &TreeNode{
+		Parentid:       currentItem.ID,
+		ID:             currentItem.ID + "/<" + itemType + ">",
+		Namespace:      redisNamespace,
+		Name:           name,
+		Display:        name,
+		ItemType:       itemType,
+		ExpandURL:      ExpandURLNotSupported,
+		SubscriptionID: currentItem.SubscriptionID,
+		Metadata: map[string]string{
+			"ResourceID":            currentItem.ID,
+			"SuppressSwaggerExpand": "true",
+			"SuppressGenericExpand": "true",
+		},
+	}
+}
+
+// connect opens an authenticated connection to the SSL port of the cache using the primary access key
+func (e *RedisExpander) connect(ctx context.Context, cacheID string) (*redisConn, error) {
+	data, err := e.client.DoRequest(ctx, "GET", cacheID+"?api-version="+redisAPIVersion)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get cache: %s", err)
+	}
+	var cache struct {
+		Properties struct {
+			HostName string `json:"hostName"`
+			SSLPort  int    `json:"sslPort"`
+		} `json:"properties"`
+	}
+	err = json.Unmarshal([]byte(data), &cache)
+	if err != nil {
+		return nil, fmt.Errorf("Error unmarshalling cache: %s", err)
+	}
+
+	data, err = e.client.DoRequest(ctx, "POST", cacheID+"/listKeys?api-version="+redisAPIVersion)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to list access keys: %s", err)
+	}
+	var keys struct {
+		PrimaryKey string `json:"primaryKey"`
+	}
+	err = json.Unmarshal([]byte(data), &keys)
+	if err != nil {
+		return nil, fmt.Errorf("Error unmarshalling access keys: %s", err)
+	}
+
+	address := net.JoinHostPort(cache.Properties.HostName, strconv.Itoa(cache.Properties.SSLPort))
+	return newRedisConn(ctx, e.dial, address, keys.PrimaryKey)
+}
+
+// getInfo runs INFO and returns a summary of the key values followed by the full output
+func (e *RedisExpander) getInfo(ctx context.Context, cacheID string) (string, error) {
+	conn, err := e.connect(ctx, cacheID)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close() //nolint: errcheck
+
+	reply, err := conn.do("INFO")
+	if err != nil {
+		return "", fmt.Errorf("Failed to get cache info: %s", err)
+	}
+	info, ok := reply.(string)
+	if !ok {
+		return "", fmt.Errorf("Unexpected reply to INFO: %v", reply)
+	}
+
+	values := map[string]string{}
+	keyspace := []string{}
+	details := []string{}
+	for _, line := range strings.Split(info, "\r\n") {
+		if strings.HasPrefix(line, "#") {
+			details = append(details, "", style.Header(strings.TrimSpace(line[1:])))
+			continue
+		}
+		details = append(details, line)
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) == 2 {
+			values[parts[0]] = parts[1]
+			if strings.HasPrefix(parts[0], "db") {
+				keyspace = append(keyspace, parts[0]+" "+parts[1])
+			}
+		}
+	}
+
+	hitRatio := "n/a"
+	hits, _ := strconv.ParseFloat(values["keyspace_hits"], 64)
+	misses, _ := strconv.ParseFloat(values["keyspace_misses"], 64)
+	if hits+misses > 0 {
+		hitRatio = fmt.Sprintf("%.1f%%", hits*100/(hits+misses))
+	}
+	uptime, _ := strconv.Atoi(values["uptime_in_seconds"])
+	if len(keyspace) == 0 {
+		keyspace = append(keyspace, "empty")
+	}
+
+	lines := []string{
+		style.Title("Cache info"),
+		"",
+		"Version:           " + values["redis_version"],
+		"Role:              " + values["role"],
+		"Uptime:            " + (time.Duration(uptime) * time.Second).String(),
+		"Connected clients: " + values["connected_clients"],
+		"Used memory:       " + values["used_memory_human"] + style.Subtle(" (peak "+values["used_memory_peak_human"]+")"),
+		"Hit ratio:         " + hitRatio + style.Subtle(fmt.Sprintf(" (hits %.0f, misses %.0f)", hits, misses)),
+		"Evicted keys:      " + values["evicted_keys"],
+		"Keyspace:          " + strings.Join(keyspace, "\n                   "),
+	}
+	return strings.Join(append(lines, details...), "\n"), nil
+}
+
+// getSlowLog runs SLOWLOG GET and returns the entries with the slowest first
+func (e *RedisExpander) getSlowLog(ctx context.Context, cacheID string) (string, error) {
+	conn, err := e.connect(ctx, cacheID)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close() //nolint: errcheck
+
+	reply, err := conn.do("SLOWLOG", "GET", strconv.Itoa(redisSlowLogCount))
+	if err != nil {
+		return "", fmt.Errorf("Failed to get slow log: %s", err)
+	}
+	entries, ok := reply.([]interface{})
+	if !ok {
+		return "", fmt.Errorf("Unexpected reply to SLOWLOG GET: %v", reply)
+	}
+
+	type slowLogEntry struct {
+		id       int64
+		time     time.Time
+		duration time.Duration
+		command  string
+		client   string
+	}
+	slowLog := []slowLogEntry{}
+	for _, entry := range entries {
+		// each entry is [id, unix time, duration in microseconds, [command args...], client address, client name]
+		// with the client fields only returned by Redis 4.0 and later
+		fields, ok := entry.([]interface{})
+		if !ok || len(fields) < 4 {
+			return "", fmt.Errorf("Unexpected slow log entry: %v", entry)
+		}
+		id, _ := fields[0].(int64)
+		timestamp, _ := fields[1].(int64)
+		duration, _ := fields[2].(int64)
+		args := []string{}
+		if commandArgs, ok := fields[3].([]interface{}); ok {
+			for _, arg := range commandArgs {
+				args = append(args, fmt.Sprint(arg))
+			}
+		}
+		command := strings.Join(args, " ")
+		if len(command) > redisMaxCommandLength {
+			command = command[:redisMaxCommandLength] + "..."
+		}
+		client := ""
+		if len(fields) > 4 {
+			client, _ = fields[4].(string)
+		}
+		slowLog = append(slowLog, slowLogEntry{
+			id:       id,
+			time:     time.Unix(timestamp, 0),
+			duration: time.Duration(duration) * time.Microsecond,
+			command:  command,
+			client:   client,
+		})
+	}
+
+	lines := []string{
+		style.Title("Slow log") + style.Subtle(fmt.Sprintf(" (%d entries)", len(slowLog))),
+		"",
+	}
+	if len(slowLog) == 0 {
+		return strings.Join(append(lines, "The slow log is empty"), "\n"), nil
+	}
+	lines = append(lines, style.Header(fmt.Sprintf("%-6s %-19s %12s  %-21s %s", "ID", "TIME", "DURATION", "CLIENT", "COMMAND")))
+	for _, entry := range slowLog {
+		lines = append(lines, fmt.Sprintf("%-6d %-19s %12s  %-21s %s",
+			entry.id, entry.time.UTC().Format("2006-01-02 15:04:05"), entry.duration.String(), entry.client, entry.command))
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// getPatchSchedule returns the maintenance windows for the cache
+func (e *RedisExpander) getPatchSchedule(ctx context.Context, cacheID string) (string, error) {
+	data, err := e.client.DoRequest(ctx, "GET", cacheID+"/patchSchedules?api-version="+redisAPIVersion)
+	if err != nil {
+		return "", fmt.Errorf("Failed to get patch schedule: %s", err)
+	}
+	var response struct {
+		Value []struct {
+			Properties struct {
+				ScheduleEntries []struct {
+					DayOfWeek         string `json:"dayOfWeek"`
+					StartHourUtc      int    `json:"startHourUtc"`
+					MaintenanceWindow string `json:"maintenanceWindow"`
+				} `json:"scheduleEntries"`
+			} `json:"properties"`
+		} `json:"value"`
+	}
+	err = json.Unmarshal([]byte(data), &response)
+	if err != nil {
+		return "", fmt.Errorf("Error unmarshalling patch schedule: %s", err)
+	}
+
+	lines := []string{
+		style.Title("Patch schedule"),
+		"",
+	}
+	if len(response.Value) == 0 || len(response.Value[0].Properties.ScheduleEntries) == 0 {
+		return strings.Join(append(lines, "No patch schedule, updates can be applied at any time"), "\n"), nil
+	}
+	for _, entry := range response.Value[0].Properties.ScheduleEntries {
+		window := entry.MaintenanceWindow
+		if window == "" {
+			window = "PT5H" // the default maintenance window
+		}
+		lines = append(lines, fmt.Sprintf("%-10s %02d:00 UTC  %s", entry.DayOfWeek, entry.StartHourUtc, style.Subtle("(window "+window+")")))
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+func (e *RedisExpander) testCases() (bool, *[]expanderTestCase) {
+	const cacheID = "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/stable/providers/Microsoft.Cache/Redis/testcache"
+
+	cacheNode := &TreeNode{ID: cacheID, Name: "testcache", ItemType: ResourceType}
+	patchScheduleNode := e.newNode(cacheNode, redisPatchScheduleType, "Patch schedule")
+
+	noRequestsGockConfig := func(t *testing.T) {}
+	patchScheduleGockConfig := func(t *testing.T) {
+		gock.New("https://management.azure.com").
+			Get(cacheID + "/patchSchedules").
+			Reply(200).
+			File("./testdata/armsamples/redis/patchSchedules.json")
+	}
+	noPatchScheduleGockConfig := func(t *testing.T) {
+		gock.New("https://management.azure.com").
+			Get(cacheID + "/patchSchedules").
+			Reply(200).
+			JSON(`{"value": []}`)
+	}
+
+	return true, &[]expanderTestCase{
+		{
+			name:              "Redis->Cache",
+			nodeToExpand:      cacheNode,
+			configureGockFunc: &noRequestsGockConfig,
+			treeNodeCheckerFunc: func(t *testing.T, r ExpanderResult) {
+				st.Expect(t, r.Err, nil)
+				st.Expect(t, len(r.Nodes), 3)
+				st.Expect(t, r.Nodes[0].ItemType, redisInfoType)
+				st.Expect(t, r.Nodes[1].ItemType, redisSlowLogType)
+				st.Expect(t, r.Nodes[2].ItemType, redisPatchScheduleType)
+				st.Expect(t, r.Nodes[2].Metadata["ResourceID"], cacheID)
+			},
+		},
+		{
+			name:              "Redis->PatchSchedule",
+			nodeToExpand:      patchScheduleNode,
+			configureGockFunc: &patchScheduleGockConfig,
+			treeNodeCheckerFunc: func(t *testing.T, r ExpanderResult) {
+				st.Expect(t, r.Err, nil)
+				st.Expect(t, strings.Contains(r.Response.Response, "Monday     02:00 UTC  "+style.Subtle("(window PT5H)")), true)
+				st.Expect(t, strings.Contains(r.Response.Response, "Saturday   22:00 UTC  "+style.Subtle("(window PT6H)")), true)
+			},
+		},
+		{
+			name:              "Redis->NoPatchSchedule",
+			nodeToExpand:      patchScheduleNode,
+			configureGockFunc: &noPatchScheduleGockConfig,
+			treeNodeCheckerFunc: func(t *testing.T, r ExpanderResult) {
+				st.Expect(t, r.Err, nil)
+				st.Expect(t, strings.Contains(r.Response.Response, "No patch schedule"), true)
+			},
+		},
+	}
+}
@@ -0,0 +1,146 @@
+package expanders
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/lawrencegripper/azbrowse/internal/pkg/style"
+	"github.com/lawrencegripper/azbrowse/pkg/armclient"
+	"github.com/nbio/st"
+	"gopkg.in/h2non/gock.v1"
+)
+
+const testRedisCacheID = "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/stable/providers/Microsoft.Cache/Redis/testcache"
+
+// newTestRedisExpander returns an expander connected to a fake cache that sends the reply for each command
+func newTestRedisExpander(t *testing.T, replies map[string]string) *RedisExpander {
+	gock.New("https://management.azure.com").
+		Get(testRedisCacheID).
+		Reply(200).
+		File("./testdata/armsamples/redis/cache.json")
+	gock.New("https://management.azure.com").
+		Post(testRedisCacheID + "/listKeys").
+		Reply(200).
+		JSON(`{"primaryKey": "testkey", "secondaryKey": "othertestkey"}`)
+
+	httpClient := &http.Client{Transport: &http.Transport{}}
+	gock.InterceptClient(httpClient)
+	expander := NewRedisExpander(armclient.NewClientFromConfig(httpClient, DummyTokenFunc(), 5000))
+	expander.dial = func(ctx context.Context, address string) (net.Conn, error) {
+		st.Expect(t, address, "testcache.redis.cache.windows.net:6380")
+		client, server := net.Pipe()
+		go func() {
+			defer server.Close() //nolint: errcheck
+			reader := bufio.NewReader(server)
+			for {
+				command, err := readRedisReply(reader)
+				if err != nil {
+					return
+				}
+				args := []string{}
+				for _, arg := range command.([]interface{}) {
+					args = append(args, arg.(string))
+				}
+				reply, ok := replies[strings.Join(args, " ")]
+				if !ok {
+					reply = "-ERR unknown command\r\n"
+				}
+				_, err = server.Write([]byte(reply))
+				if err != nil {
+					return
+				}
+			}
+		}()
+		return client, nil
+	}
+	return expander
+}
+
+func Test_RedisExpander_Info(t *testing.T) {
+	defer gock.Off()
+	info := strings.Join([]string{
+		"# Server",
+		"redis_version:4.0.14",
+		"uptime_in_seconds:93784",
+		"",
+		"# Clients",
+		"connected_clients:12",
+		"",
+		"# Memory",
+		"used_memory_human:1.20M",
+		"used_memory_peak_human:2.50M",
+		"",
+		"# Stats",
+		"evicted_keys:0",
+		"keyspace_hits:1900",
+		"keyspace_misses:100",
+		"",
+		"# Replication",
+		"role:master",
+		"",
+		"# Keyspace",
+		"db0:keys=120,expires=5,avg_ttl=0",
+		"",
+	}, "\r\n")
+	expander := newTestRedisExpander(t, map[string]string{
+		"AUTH testkey": "+OK\r\n",
+		"INFO":         fmt.Sprintf("$%d\r\n%s\r\n", len(info), info),
+	})
+
+	output, err := expander.getInfo(context.Background(), testRedisCacheID)
+	st.Expect(t, err, nil)
+	st.Expect(t, strings.Contains(output, "Version:           4.0.14\n"), true)
+	st.Expect(t, strings.Contains(output, "Role:              master\n"), true)
+	st.Expect(t, strings.Contains(output, "Uptime:            26h3m4s\n"), true)
+	st.Expect(t, strings.Contains(output, "Used memory:       1.20M"+style.Subtle(" (peak 2.50M)")), true)
+	st.Expect(t, strings.Contains(output, "Hit ratio:         95.0%"+style.Subtle(" (hits 1900, misses 100)")), true)
+	st.Expect(t, strings.Contains(output, "Keyspace:          db0 keys=120,expires=5,avg_ttl=0\n"), true)
+	st.Expect(t, strings.Contains(output, style.Header("Memory")), true)
+	st.Expect(t, gock.IsDone(), true)
+}
+
+func Test_RedisExpander_SlowLog(t *testing.T) {
+	defer gock.Off()
+	expander := newTestRedisExpander(t, map[string]string{
+		"AUTH testkey": "+OK\r\n",
+		"SLOWLOG GET 128": "*2\r\n" +
+			"*6\r\n:14\r\n:1588327200\r\n:15320\r\n*2\r\n$4\r\nKEYS\r\n$1\r\n*\r\n$15\r\n10.0.0.4:512345\r\n$0\r\n\r\n" +
+			"*4\r\n:13\r\n:1588327100\r\n:10250\r\n*2\r\n$3\r\nGET\r\n$5\r\norder\r\n",
+	})
+
+	output, err := expander.getSlowLog(context.Background(), testRedisCacheID)
+	st.Expect(t, err, nil)
+	lines := strings.Split(output, "\n")
+	st.Expect(t, lines[0], style.Title("Slow log")+style.Subtle(" (2 entries)"))
+	st.Expect(t, lines[3], "14     2020-05-01 10:00:00      15.32ms  10.0.0.4:512345       KEYS *")
+	st.Expect(t, lines[4], "13     2020-05-01 09:58:20      10.25ms                        GET order")
+	st.Expect(t, gock.IsDone(), true)
+}
+
+func Test_RedisExpander_AuthFailure(t *testing.T) {
+	defer gock.Off()
+	expander := newTestRedisExpander(t, map[string]string{
+		"AUTH testkey": "-ERR invalid password\r\n",
+	})
+
+	_, err := expander.getSlowLog(context.Background(), testRedisCacheID)
+	st.Expect(t, err != nil, true)
+	st.Expect(t, strings.Contains(err.Error(), "ERR invalid password"), true)
+}
+
+func Test_readRedisReply(t *testing.T) {
+	reader := bufio.NewReader(strings.NewReader("*4\r\n+OK\r\n:-3\r\n-ERR nested\r\n*-1\r\n"))
+	reply, err := readRedisReply(reader)
+	st.Expect(t, err, nil)
+	items := reply.([]interface{})
+	st.Expect(t, len(items), 4)
+	st.Expect(t, items[0], "OK")
+	st.Expect(t, items[1], int64(-3))
+	st.Expect(t, items[2], redisError("ERR nested"))
+	st.Expect(t, items[3], nil)
+}
@@ -48,6 +48,9 @@ func InitializeExpanders(client *armclient.Client) {
 		&MetricsExpander{
 			client: client,
 		},
+		&AlertsExpander{
+			client: client,
+		},
 		swaggerResourceExpander,
 		&DeploymentsExpander{
 			client: client,
@@ -64,13 +67,37 @@ func InitializeExpanders(client *armclient.Client) {
 		&AppInsightsExpander{
 			client: client,
 		},
+		&LogQueryExpander{
+			client: client,
+		},
 		&AzureKubernetesServiceExpander{
 			client: client,
 		},
-		&AzureSearchServiceExpander{
+		NewStorageDataPlaneExpander(client),
+		NewServiceBusExpander(client),
+		NewEventHubsExpander(client),
+		&KeyVaultExpander{
+			client: client,
+		},
+		&CosmosDBExpander{
+			client: client,
+		},
+		NewVirtualMachineExpander(client),
+		&VirtualMachineScaleSetExpander{
+			client: client,
+		},
+		NewAppServiceExpander(client),
+		&NetworkExpander{
+			client: client,
+		},
+		NewSQLServerExpander(client),
+		&EventGridExpander{
 			client: client,
 		},
+		NewRedisExpander(client),
 	}
+	// Generated from cmd/swagger-codegen/dataplane-apisets.json
+	register = append(register, getDataPlaneExpanders(client)...)
 }
 
 func getRegisteredExpanders() []Expander {
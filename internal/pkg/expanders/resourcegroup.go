@@ -59,7 +59,7 @@ func (e *ResourceGroupResourceExpander) Expand(ctx context.Context, currentItem
 		defer errorhandling.RecoveryWithCleanup()
 
 		// Use resource graph to enrich response
-		query := "where resourceGroup=='" + currentItem.Name + "' | project name, id, sku, kind, location, tags, properties.provisioningState"
+		query := "where resourceGroup=='" + currentItem.Name + "' | project name, id, sku, kind, location, tags, properties.provisioningState, properties.extended.instanceView.powerState.code"
 		queryData, err := e.client.DoResourceGraphQuery(ctx, currentItem.SubscriptionID, query)
 		span.SetTag("queryResponse", queryData)
 		span.SetTag("queryError", err)
@@ -91,6 +91,10 @@ func (e *ResourceGroupResourceExpander) Expand(ctx context.Context, currentItem
 				panic(err)
 			}
 			currentState := string(rowValues[6].GetStringBytes())
+			// Virtual machines show their power state, e.g. PowerState/running, rather than the provisioning state
+			if len(rowValues) > 7 && len(rowValues[7].GetStringBytes()) > 0 {
+				currentState = string(rowValues[7].GetStringBytes())
+			}
 			itemID := string(rowValues[1].GetStringBytes())
 			stateMap[itemID] = currentState
 		}
@@ -0,0 +1,309 @@
+package expanders
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lawrencegripper/azbrowse/internal/pkg/tracing"
+	"github.com/lawrencegripper/azbrowse/pkg/armclient"
+)
+
+// Service Bus and Event Hubs share the same namespace infrastructure
+// so the same client is used for the data-plane of both
+
+const (
+	messagingAPIVersion  = "2017-04-01"
+	messagingSASLifetime = time.Hour
+	messagingKeyName     = "RootManageSharedAccessKey"
+)
+
+type messagingNamespaceResponse struct {
+	Properties struct {
+		ServiceBusEndpoint string `json:"serviceBusEndpoint"`
+	} `json:"properties"`
+}
+
+type messagingListKeysResponse struct {
+	PrimaryKey string `json:"primaryKey"`
+	KeyName    string `json:"keyName"`
+}
+
+// messagingNamespaceClient makes data-plane requests against a Service Bus or Event Hubs namespace.
+// A SAS token generated from the root key is used where the keys can be listed,
+// otherwise requests fall back to an AAD token for the service audience
+type messagingNamespaceClient struct {
+	httpClient  *http.Client
+	armClient   *armclient.Client
+	namespaceID string
+	endpoint    string // e.g. https://mynamespace.servicebus.windows.net/
+	aadResource string
+
+	lock      sync.Mutex
+	sasToken  string
+	sasExpiry time.Time
+	useAAD    bool
+}
+
+func newMessagingNamespaceClient(ctx context.Context, httpClient *http.Client, armClient *armclient.Client, namespaceID string, aadResource string) (*messagingNamespaceClient, error) {
+	data, err := armClient.DoRequest(ctx, "GET", namespaceID+"?api-version="+messagingAPIVersion)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get namespace: " + err.Error() + namespaceID)
+	}
+
+	var response messagingNamespaceResponse
+	err = json.Unmarshal([]byte(data), &response)
+	if err != nil {
+		return nil, fmt.Errorf("Error unmarshalling response: %s\nURL:%s", err, namespaceID)
+	}
+	endpointURL, err := url.Parse(response.Properties.ServiceBusEndpoint)
+	if err != nil || endpointURL.Hostname() == "" {
+		return nil, fmt.Errorf("Namespace endpoint lookup failed")
+	}
+
+	return &messagingNamespaceClient{
+		httpClient:  httpClient,
+		armClient:   armClient,
+		namespaceID: namespaceID,
+		endpoint:    "https://" + endpointURL.Hostname() + "/",
+		aadResource: aadResource,
+	}, nil
+}
+
+// authorize adds either the SAS token or the AAD bearer token to the request
+func (c *messagingNamespaceClient) authorize(ctx context.Context, req *http.Request) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if !c.useAAD && time.Now().After(c.sasExpiry) {
+		data, err := c.armClient.DoRequest(ctx, "POST", c.namespaceID+"/AuthorizationRules/"+messagingKeyName+"/listKeys?api-version="+messagingAPIVersion)
+		var response messagingListKeysResponse
+		if err == nil {
+			err = json.Unmarshal([]byte(data), &response)
+		}
+		if err != nil || response.PrimaryKey == "" {
+			// Unable to list keys (e.g. no permission or local auth disabled) so use AAD instead
+			c.useAAD = true
+		} else {
+			expiry := time.Now().Add(messagingSASLifetime)
+			c.sasToken = generateMessagingSAS(c.endpoint, response.KeyName, response.PrimaryKey, expiry)
+			c.sasExpiry = expiry.Add(-5 * time.Minute) // refresh a little before the token expires
+		}
+	}
+
+	if c.useAAD {
+		token, err := c.armClient.GetTokenForResource(ctx, c.aadResource)
+		if err != nil {
+			return fmt.Errorf("Failed to get token for %s: %s", c.aadResource, err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token.AccessToken)
+		return nil
+	}
+
+	req.Header.Set("Authorization", c.sasToken)
+	return nil
+}
+
+// generateMessagingSAS creates a SAS token for the namespace
+// See https://docs.microsoft.com/azure/service-bus-messaging/service-bus-sas
+func generateMessagingSAS(resourceURI string, keyName string, key string, expiry time.Time) string {
+	encodedURI := url.QueryEscape(resourceURI)
+	expiryString := strconv.FormatInt(expiry.Unix(), 10)
+
+	mac := hmac.New(sha256.New, []byte(key))
+	_, _ = mac.Write([]byte(encodedURI + "\n" + expiryString))
+	signature := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+
+	return fmt.Sprintf("SharedAccessSignature sr=%s&sig=%s&se=%s&skn=%s",
+		encodedURI, url.QueryEscape(signature), expiryString, url.QueryEscape(keyName))
+}
+
+// doRequest makes a request to the namespace. Paths are relative to the namespace endpoint.
+// A 204 (No Content) response is returned as a nil body and no error
+func (c *messagingNamespaceClient) doRequest(ctx context.Context, verb string, path string, headers map[string]string, body []byte) ([]byte, http.Header, error) {
+	requestURL := c.endpoint + path
+	span, ctx := tracing.StartSpanFromContext(ctx, "doRequest(messaging):"+verb+":"+requestURL, tracing.SetTag("url", requestURL))
+	defer span.Finish()
+
+	req, err := http.NewRequest(verb, requestURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, nil, fmt.Errorf("Failed to create request: %s", err)
+	}
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	err = c.authorize(ctx, req)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	response, err := c.httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, nil, fmt.Errorf("Request failed: %s", err)
+	}
+	defer response.Body.Close() //nolint: errcheck
+
+	buf, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Failed to read body: %s", err)
+	}
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return nil, nil, fmt.Errorf("DoRequest failed %v for '%s': %s", response.StatusCode, requestURL, string(buf))
+	}
+	if response.StatusCode == http.StatusNoContent {
+		return nil, response.Header, nil
+	}
+	return buf, response.Header, nil
+}
+
+// serviceBusMessage is a message read from a queue or subscription
+type serviceBusMessage struct {
+	BrokerProperties serviceBusBrokerProperties `json:"brokerProperties"`
+	Properties       map[string]string          `json:"properties"`
+	ContentType      string                     `json:"contentType,omitempty"`
+	Body             string                     `json:"body"`
+
+	rawProperties map[string]string // the custom properties as returned by the service, used when resending
+}
+
+type serviceBusBrokerProperties struct {
+	MessageID               string  `json:"MessageId,omitempty"`
+	SequenceNumber          int64   `json:"SequenceNumber"`
+	LockToken               string  `json:"LockToken,omitempty"`
+	DeliveryCount           int     `json:"DeliveryCount"`
+	EnqueuedTimeUtc         string  `json:"EnqueuedTimeUtc,omitempty"`
+	ScheduledEnqueueTimeUtc string  `json:"ScheduledEnqueueTimeUtc,omitempty"`
+	CorrelationID           string  `json:"CorrelationId,omitempty"`
+	SessionID               string  `json:"SessionId,omitempty"`
+	Label                   string  `json:"Label,omitempty"`
+	ReplyTo                 string  `json:"ReplyTo,omitempty"`
+	To                      string  `json:"To,omitempty"`
+	TimeToLive              float64 `json:"TimeToLive,omitempty"`
+	PartitionKey            string  `json:"PartitionKey,omitempty"`
+}
+
+// headers that are part of the HTTP response rather than custom message properties
+var serviceBusStandardHeaders = map[string]bool{
+	"Brokerproperties":          true,
+	"Content-Type":              true,
+	"Content-Length":            true,
+	"Date":                      true,
+	"Server":                    true,
+	"Location":                  true,
+	"Transfer-Encoding":         true,
+	"Strict-Transport-Security": true,
+}
+
+// headers added by the service when a message is dead-lettered that shouldn't be resubmitted
+var serviceBusDeadLetterHeaders = map[string]bool{
+	"Deadletterreason":           true,
+	"Deadlettererrordescription": true,
+}
+
+// lockMessage peek-locks the message at the head of the entity, returning nil if there are no messages
+func (c *messagingNamespaceClient) lockMessage(ctx context.Context, entityPath string) (*serviceBusMessage, error) {
+	buf, headers, err := c.doRequest(ctx, "POST", entityPath+"/messages/head?timeout=1", nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if headers == nil || headers.Get("BrokerProperties") == "" {
+		return nil, nil
+	}
+	return parseServiceBusMessage(buf, headers)
+}
+
+func parseServiceBusMessage(body []byte, headers http.Header) (*serviceBusMessage, error) {
+	message := serviceBusMessage{
+		Properties:    map[string]string{},
+		rawProperties: map[string]string{},
+		ContentType:   headers.Get("Content-Type"),
+		Body:          string(body),
+	}
+	err := json.Unmarshal([]byte(headers.Get("BrokerProperties")), &message.BrokerProperties)
+	if err != nil {
+		return nil, fmt.Errorf("Error unmarshalling BrokerProperties: %s", err)
+	}
+	for key := range headers {
+		if serviceBusStandardHeaders[key] || strings.HasPrefix(key, "X-Ms-") || strings.HasPrefix(key, "Access-Control") {
+			continue
+		}
+		// custom properties are returned as JSON values, e.g. strings are quoted
+		value := headers.Get(key)
+		message.rawProperties[key] = value
+		if unquoted, err := strconv.Unquote(value); err == nil {
+			value = unquoted
+		}
+		message.Properties[key] = value
+	}
+	return &message, nil
+}
+
+// unlockMessage abandons the lock on a message so it is available to receivers again
+func (c *messagingNamespaceClient) unlockMessage(ctx context.Context, entityPath string, message *serviceBusMessage) error {
+	_, _, err := c.doRequest(ctx, "PUT", c.messageLockPath(entityPath, message), nil, nil)
+	return err
+}
+
+// completeMessage deletes a locked message
+func (c *messagingNamespaceClient) completeMessage(ctx context.Context, entityPath string, message *serviceBusMessage) error {
+	_, _, err := c.doRequest(ctx, "DELETE", c.messageLockPath(entityPath, message), nil, nil)
+	return err
+}
+
+func (c *messagingNamespaceClient) messageLockPath(entityPath string, message *serviceBusMessage) string {
+	return fmt.Sprintf("%s/messages/%d/%s", entityPath, message.BrokerProperties.SequenceNumber, url.PathEscape(message.BrokerProperties.LockToken))
+}
+
+// sendMessage sends a copy of the message (body, broker and custom properties) to the entity
+func (c *messagingNamespaceClient) sendMessage(ctx context.Context, entityPath string, message *serviceBusMessage) error {
+	brokerProperties := message.BrokerProperties
+	brokerPropertiesJSON, err := json.Marshal(struct {
+		MessageID     string  `json:"MessageId,omitempty"`
+		CorrelationID string  `json:"CorrelationId,omitempty"`
+		SessionID     string  `json:"SessionId,omitempty"`
+		Label         string  `json:"Label,omitempty"`
+		ReplyTo       string  `json:"ReplyTo,omitempty"`
+		To            string  `json:"To,omitempty"`
+		TimeToLive    float64 `json:"TimeToLive,omitempty"`
+		PartitionKey  string  `json:"PartitionKey,omitempty"`
+	}{
+		brokerProperties.MessageID,
+		brokerProperties.CorrelationID,
+		brokerProperties.SessionID,
+		brokerProperties.Label,
+		brokerProperties.ReplyTo,
+		brokerProperties.To,
+		brokerProperties.TimeToLive,
+		brokerProperties.PartitionKey,
+	})
+	if err != nil {
+		return fmt.Errorf("Error marshalling BrokerProperties: %s", err)
+	}
+
+	headers := map[string]string{
+		"BrokerProperties": string(brokerPropertiesJSON),
+	}
+	if message.ContentType != "" {
+		headers["Content-Type"] = message.ContentType
+	}
+	for key, value := range message.rawProperties {
+		if serviceBusDeadLetterHeaders[key] {
+			continue
+		}
+		headers[key] = value
+	}
+
+	_, _, err = c.doRequest(ctx, "POST", entityPath+"/messages", headers, []byte(message.Body))
+	return err
+}
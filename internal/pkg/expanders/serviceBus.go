@@ -0,0 +1,672 @@
+package expanders
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/lawrencegripper/azbrowse/internal/pkg/eventing"
+	"github.com/lawrencegripper/azbrowse/internal/pkg/style"
+	"github.com/lawrencegripper/azbrowse/pkg/armclient"
+	"github.com/nbio/st"
+	"gopkg.in/h2non/gock.v1"
+)
+
+const (
+	serviceBusTemplateURL = "/subscriptions/{subscriptionId}/resourceGroups/{resourceGroupName}/providers/Microsoft.ServiceBus/namespaces/{namespaceName}"
+	serviceBusNamespace   = "serviceBus"
+	serviceBusResource    = "https://servicebus.azure.net"
+
+	serviceBusQueuesType       = "serviceBus.queues"
+	serviceBusQueueType        = "serviceBus.queue"
+	serviceBusTopicsType       = "serviceBus.topics"
+	serviceBusTopicType        = "serviceBus.topic"
+	serviceBusSubscriptionType = "serviceBus.subscription"
+	serviceBusMessagesType     = "serviceBus.messages"
+	serviceBusDeadLettersType  = "serviceBus.deadLetters"
+
+	serviceBusPeekCount     = 10
+	serviceBusMaxBatchCount = 1000
+)
+
+type serviceBusEntityListResponse struct {
+	Value []struct {
+		ID         string `json:"id"`
+		Name       string `json:"name"`
+		Properties struct {
+			Status            string                 `json:"status"`
+			SubscriptionCount int                    `json:"subscriptionCount"`
+			CountDetails      serviceBusCountDetails `json:"countDetails"`
+		} `json:"properties"`
+	} `json:"value"`
+	NextLink string `json:"nextLink"`
+}
+
+type serviceBusCountDetails struct {
+	ActiveMessageCount     int64 `json:"activeMessageCount"`
+	DeadLetterMessageCount int64 `json:"deadLetterMessageCount"`
+	ScheduledMessageCount  int64 `json:"scheduledMessageCount"`
+}
+
+// NewServiceBusExpander creates a new instance of ServiceBusExpander
+func NewServiceBusExpander(armclient *armclient.Client) *ServiceBusExpander {
+	return &ServiceBusExpander{
+		httpClient: &http.Client{},
+		armClient:  armclient,
+		clients:    map[string]*messagingNamespaceClient{},
+	}
+}
+
+// Check interface
+var _ Expander = &ServiceBusExpander{}
+
+// ServiceBusExpander expands the queues, topics and subscriptions in a Service Bus namespace
+// and allows messages to be peeked and dead-letter queues to be resubmitted or purged
+type ServiceBusExpander struct {
+	ExpanderBase
+	httpClient *http.Client
+	armClient  *armclient.Client
+
+	clientsLock sync.Mutex
+	clients     map[string]*messagingNamespaceClient
+}
+
+func (e *ServiceBusExpander) setClient(c *armclient.Client) {
+	e.armClient = c
+	e.clientsLock.Lock()
+	e.clients = map[string]*messagingNamespaceClient{}
+	e.clientsLock.Unlock()
+}
+
+// Name returns the name of the expander
+func (e *ServiceBusExpander) Name() string {
+	return "ServiceBusExpander"
+}
+
+// DoesExpand checks if this is a Service Bus namespace
+func (e *ServiceBusExpander) DoesExpand(ctx context.Context, currentItem *TreeNode) (bool, error) {
+	swaggerResourceType := currentItem.SwaggerResourceType
+	if currentItem.ItemType == ResourceType && swaggerResourceType != nil {
+		if swaggerResourceType.Endpoint.TemplateURL == serviceBusTemplateURL {
+			return true, nil
+		}
+	}
+	if currentItem.Namespace == serviceBusNamespace {
+		return true, nil
+	}
+	return false, nil
+}
+
+// Expand returns the entities in the namespace
+func (e *ServiceBusExpander) Expand(ctx context.Context, currentItem *TreeNode) ExpanderResult {
+
+	swaggerResourceType := currentItem.SwaggerResourceType
+	if currentItem.Namespace != serviceBusNamespace &&
+		swaggerResourceType != nil &&
+		swaggerResourceType.Endpoint.TemplateURL == serviceBusTemplateURL {
+		newItems := []*TreeNode{}
+		newItems = append(newItems, &TreeNode{
+			Parentid:  currentItem.ID,
+			ID:        currentItem.ID + "/<explorer>",
+			Namespace: serviceBusNamespace,
+			Name:      "Service Bus Explorer",
+			Display:   "Service Bus Explorer",
+			ItemType:  SubResourceType,
+			ExpandURL: ExpandURLNotSupported,
+			Metadata: map[string]string{
+				"NamespaceID":           currentItem.ID,
+				"SuppressSwaggerExpand": "true",
+				"SuppressGenericExpand": "true",
+			},
+		})
+
+		return ExpanderResult{
+			Err:               nil,
+			Response:          ExpanderResponse{Response: ""}, // Swagger expander will supply the response
+			SourceDescription: "ServiceBusExpander request",
+			Nodes:             newItems,
+			IsPrimaryResponse: false,
+		}
+	}
+
+	switch currentItem.ItemType {
+	case SubResourceType:
+		return ExpanderResult{
+			Response:          ExpanderResponse{Response: ""},
+			SourceDescription: "ServiceBusExpander request",
+			Nodes: []*TreeNode{
+				e.newNode(currentItem, "Queues", "Queues", serviceBusQueuesType, nil),
+				e.newNode(currentItem, "Topics", "Topics", serviceBusTopicsType, nil),
+			},
+			IsPrimaryResponse: true,
+		}
+	case ActionType:
+		return e.executeAction(ctx, currentItem)
+	case serviceBusQueuesType:
+		return e.expandEntityList(ctx, currentItem, currentItem.Metadata["NamespaceID"]+"/queues", serviceBusQueueType)
+	case serviceBusTopicsType:
+		return e.expandEntityList(ctx, currentItem, currentItem.Metadata["NamespaceID"]+"/topics", serviceBusTopicType)
+	case serviceBusTopicType:
+		return e.expandEntityList(ctx, currentItem, currentItem.Metadata["EntityID"]+"/subscriptions", serviceBusSubscriptionType)
+	case serviceBusQueueType, serviceBusSubscriptionType:
+		return e.expandEntity(ctx, currentItem)
+	case serviceBusMessagesType, serviceBusDeadLettersType:
+		return e.expandMessages(ctx, currentItem)
+	}
+
+	return ExpanderResult{
+		Err:               fmt.Errorf("Error - unhandled Expand"),
+		Response:          ExpanderResponse{Response: "Error!"},
+		SourceDescription: "ServiceBusExpander request",
+	}
+}
+
+func (e *ServiceBusExpander) getNamespaceClient(ctx context.Context, namespaceID string) (*messagingNamespaceClient, error) {
+	e.clientsLock.Lock()
+	defer e.clientsLock.Unlock()
+
+	if client, ok := e.clients[namespaceID]; ok {
+		return client, nil
+	}
+	client, err := newMessagingNamespaceClient(ctx, e.httpClient, e.armClient, namespaceID, serviceBusResource)
+	if err != nil {
+		return nil, err
+	}
+	e.clients[namespaceID] = client
+	return client, nil
+}
+
+// expandEntityList lists queues, topics or subscriptions from ARM, including their message counts
+func (e *ServiceBusExpander) expandEntityList(ctx context.Context, currentItem *TreeNode, listID string, itemType string) ExpanderResult {
+	data, err := e.armClient.DoRequest(ctx, "GET", listID+"?api-version="+messagingAPIVersion)
+	if err != nil {
+		return e.errorResult(err)
+	}
+
+	newItems := []*TreeNode{}
+	nextData := data
+	for nextData != "" {
+		var response serviceBusEntityListResponse
+		err = json.Unmarshal([]byte(nextData), &response)
+		if err != nil {
+			return e.errorResult(fmt.Errorf("Error unmarshalling response: %s\nURL:%s", err, listID))
+		}
+
+		for _, entity := range response.Value {
+			counts := entity.Properties.CountDetails
+			var description string
+			var entityPath string
+			var resubmitPath string
+			switch itemType {
+			case serviceBusTopicType:
+				description = fmt.Sprintf("subscriptions: %d, scheduled: %d", entity.Properties.SubscriptionCount, counts.ScheduledMessageCount)
+				entityPath = entity.Name
+			case serviceBusSubscriptionType:
+				description = fmt.Sprintf("active: %d, dead-letter: %d", counts.ActiveMessageCount, counts.DeadLetterMessageCount)
+				entityPath = currentItem.Metadata["EntityPath"] + "/subscriptions/" + entity.Name
+				resubmitPath = currentItem.Metadata["EntityPath"]
+			default:
+				description = fmt.Sprintf("active: %d, dead-letter: %d, scheduled: %d", counts.ActiveMessageCount, counts.DeadLetterMessageCount, counts.ScheduledMessageCount)
+				entityPath = entity.Name
+				resubmitPath = entity.Name
+			}
+
+			node := e.newNode(currentItem, entity.Name, entity.Name+" "+style.Subtle("("+description+")"), itemType, map[string]string{
+				"EntityID":     entity.ID,
+				"EntityPath":   entityPath,
+				"ResubmitPath": resubmitPath,
+			})
+			if counts.DeadLetterMessageCount > 0 {
+				node.StatusIndicator = "⚠"
+			} else if entity.Properties.Status != "Active" {
+				node.StatusIndicator = DrawStatus(entity.Properties.Status)
+			}
+			newItems = append(newItems, node)
+		}
+
+		nextData = ""
+		if response.NextLink != "" {
+			nextData, err = e.armClient.DoRequest(ctx, "GET", response.NextLink)
+			if err != nil {
+				return e.errorResult(err)
+			}
+		}
+	}
+
+	return ExpanderResult{
+		Response:          ExpanderResponse{Response: data, ResponseType: ResponseJSON},
+		SourceDescription: "ServiceBusExpander request",
+		Nodes:             newItems,
+		IsPrimaryResponse: true,
+	}
+}
+
+// expandEntity shows the ARM properties of a queue or subscription with nodes to peek the messages
+func (e *ServiceBusExpander) expandEntity(ctx context.Context, currentItem *TreeNode) ExpanderResult {
+	data, err := e.armClient.DoRequest(ctx, "GET", currentItem.Metadata["EntityID"]+"?api-version="+messagingAPIVersion)
+	if err != nil {
+		return e.errorResult(err)
+	}
+
+	entityPath := currentItem.Metadata["EntityPath"]
+	newItems := []*TreeNode{
+		e.newNode(currentItem, "Active messages", "Active messages", serviceBusMessagesType, map[string]string{
+			"EntityPath": entityPath,
+		}),
+		e.newNode(currentItem, "Dead-letter messages", "Dead-letter messages", serviceBusDeadLettersType, map[string]string{
+			"EntityPath":   entityPath + "/$DeadLetterQueue",
+			"ResubmitPath": currentItem.Metadata["ResubmitPath"],
+		}),
+	}
+
+	return ExpanderResult{
+		Response:          ExpanderResponse{Response: data, ResponseType: ResponseJSON},
+		SourceDescription: "ServiceBusExpander request",
+		Nodes:             newItems,
+		IsPrimaryResponse: true,
+	}
+}
+
+// expandMessages explains why messages aren't read until asked for, with an action to read them.
+// The REST API doesn't support a non-destructive peek (that is only available over AMQP) so
+// reading messages would change them for the applications consuming the entity
+func (e *ServiceBusExpander) expandMessages(ctx context.Context, currentItem *TreeNode) ExpanderResult {
+	warning := "Service Bus only supports a non-destructive peek over AMQP, so messages are read by peek-locking them and then abandoning the locks. This:\n" +
+		"  - increments the delivery count of each message read\n" +
+		"  - hides the messages from other receivers until the locks are abandoned\n"
+	if currentItem.ItemType == serviceBusMessagesType {
+		warning += "  - dead-letters any message that reaches the max delivery count of the entity\n"
+	}
+	warning += fmt.Sprintf("\nSelect 'Peek-lock messages' to read up to %d messages from '%s'.", serviceBusPeekCount, currentItem.Metadata["EntityPath"])
+
+	action := e.newActionNode(currentItem, "peek-lock", "Peek-lock messages")
+	action.Metadata["MessagesType"] = currentItem.ItemType
+	return ExpanderResult{
+		Response:          ExpanderResponse{Response: warning, ResponseType: ResponsePlainText},
+		SourceDescription: "ServiceBusExpander request",
+		Nodes:             []*TreeNode{action},
+		IsPrimaryResponse: true,
+	}
+}
+
+// peekLockMessages reads the messages at the head of the entity by peek-locking them and then unlocking them.
+// Unlocking increments the delivery count so a message in the active queue that is at the max delivery count
+// will be dead-lettered by the service. Dead-letter queues have no max delivery count so are unaffected.
+func (e *ServiceBusExpander) peekLockMessages(ctx context.Context, currentItem *TreeNode) ExpanderResult {
+	client, err := e.getNamespaceClient(ctx, currentItem.Metadata["NamespaceID"])
+	if err != nil {
+		return e.errorResult(err)
+	}
+	entityPath := currentItem.Metadata["EntityPath"]
+
+	// Hold the locks until all messages are read so that the same message isn't returned twice
+	messages := []*serviceBusMessage{}
+	for i := 0; i < serviceBusPeekCount; i++ {
+		message, err := client.lockMessage(ctx, entityPath)
+		if err != nil {
+			err = e.unlockMessages(ctx, client, entityPath, messages, err)
+			return e.errorResult(err)
+		}
+		if message == nil {
+			break
+		}
+		messages = append(messages, message)
+	}
+	err = e.unlockMessages(ctx, client, entityPath, messages, nil)
+	if err != nil {
+		return e.errorResult(err)
+	}
+
+	for _, message := range messages {
+		message.BrokerProperties.LockToken = ""
+	}
+	response := struct {
+		Note     string               `json:"note,omitempty"`
+		Messages []*serviceBusMessage `json:"messages"`
+	}{
+		Messages: messages,
+	}
+	if currentItem.Metadata["MessagesType"] == serviceBusMessagesType {
+		response.Note = "Messages are read with peek-lock and then unlocked, which increments their delivery count"
+	}
+	buf, err := json.Marshal(response)
+	if err != nil {
+		return e.errorResult(err)
+	}
+
+	return ExpanderResult{
+		Response:          ExpanderResponse{Response: string(buf), ResponseType: ResponseJSON},
+		SourceDescription: "ServiceBusExpander request",
+		Nodes:             []*TreeNode{},
+		IsPrimaryResponse: true,
+	}
+}
+
+func (e *ServiceBusExpander) unlockMessages(ctx context.Context, client *messagingNamespaceClient, entityPath string, messages []*serviceBusMessage, err error) error {
+	for _, message := range messages {
+		unlockErr := client.unlockMessage(ctx, entityPath, message)
+		if unlockErr != nil && err == nil {
+			err = unlockErr
+		}
+	}
+	return err
+}
+
+// HasActions checks if the item is a dead-letter queue
+func (e *ServiceBusExpander) HasActions(ctx context.Context, currentItem *TreeNode) (bool, error) {
+	return currentItem.Namespace == serviceBusNamespace && currentItem.ItemType == serviceBusDeadLettersType, nil
+}
+
+// ListActions returns the resubmit and purge actions for a dead-letter queue
+func (e *ServiceBusExpander) ListActions(ctx context.Context, currentItem *TreeNode) ListActionsResult {
+	resubmitDisplay := "Resubmit dead-letter messages"
+	if isServiceBusSubscriptionPath(currentItem.Metadata["EntityPath"]) {
+		resubmitDisplay += " (to topic, all subscriptions)"
+	}
+	return ListActionsResult{
+		Nodes: []*TreeNode{
+			e.newActionNode(currentItem, "resubmit", resubmitDisplay),
+			e.newActionNode(currentItem, "purge", "Purge dead-letter messages"),
+		},
+		SourceDescription: "ServiceBusExpander request",
+	}
+}
+
+// isServiceBusSubscriptionPath checks if the entity path is a subscription (or its dead-letter queue) rather than a queue
+func isServiceBusSubscriptionPath(entityPath string) bool {
+	return strings.Contains(entityPath, "/subscriptions/")
+}
+
+func (e *ServiceBusExpander) newActionNode(currentItem *TreeNode, actionID string, display string) *TreeNode {
+	node := e.newNode(currentItem, display, display, ActionType, map[string]string{
+		"ActionID":     actionID,
+		"EntityPath":   currentItem.Metadata["EntityPath"],
+		"ResubmitPath": currentItem.Metadata["ResubmitPath"],
+	})
+	node.ID = currentItem.ID + "/<action:" + actionID + ">"
+	return node
+}
+
+func (e *ServiceBusExpander) executeAction(ctx context.Context, currentItem *TreeNode) ExpanderResult {
+	switch currentItem.Metadata["ActionID"] {
+	case "resubmit":
+		if !isServiceBusSubscriptionPath(currentItem.Metadata["EntityPath"]) {
+			return e.processDeadLetters(ctx, currentItem, true)
+		}
+		// Messages can't be sent to a single subscription, only to its topic, so make sure that's understood first
+		return ExpanderResult{
+			Response: ExpanderResponse{
+				Response: "Dead-letter messages can't be resubmitted to just this subscription, they are sent to the topic '" + currentItem.Metadata["ResubmitPath"] + "'.\n" +
+					"Every subscription on the topic whose rules match a message will receive it again, not only the subscription it was dead-lettered from.\n" +
+					"Select 'Confirm resubmit to all subscriptions' to continue.",
+				ResponseType: ResponsePlainText,
+			},
+			SourceDescription: "ServiceBusExpander request",
+			Nodes:             []*TreeNode{e.newActionNode(currentItem, "resubmit-confirmed", "Confirm resubmit to all subscriptions")},
+			IsPrimaryResponse: true,
+		}
+	case "resubmit-confirmed":
+		return e.processDeadLetters(ctx, currentItem, true)
+	case "peek-lock":
+		return e.peekLockMessages(ctx, currentItem)
+	case "purge":
+		// Purging can't be undone so ask for confirmation first
+		return ExpanderResult{
+			Response: ExpanderResponse{
+				Response:     "Purging permanently deletes all messages in the dead-letter queue '" + currentItem.Metadata["EntityPath"] + "'.\nSelect 'Confirm purge' to continue.",
+				ResponseType: ResponsePlainText,
+			},
+			SourceDescription: "ServiceBusExpander request",
+			Nodes:             []*TreeNode{e.newActionNode(currentItem, "purge-confirmed", "Confirm purge")},
+			IsPrimaryResponse: true,
+		}
+	case "purge-confirmed":
+		return e.processDeadLetters(ctx, currentItem, false)
+	}
+	return e.errorResult(fmt.Errorf("Unhandled action: %s", currentItem.Metadata["ActionID"]))
+}
+
+// processDeadLetters removes each message from the dead-letter queue, sending a copy to the
+// original entity first if resubmit is set
+func (e *ServiceBusExpander) processDeadLetters(ctx context.Context, currentItem *TreeNode, resubmit bool) ExpanderResult {
+	client, err := e.getNamespaceClient(ctx, currentItem.Metadata["NamespaceID"])
+	if err != nil {
+		return e.errorResult(err)
+	}
+	deadLetterPath := currentItem.Metadata["EntityPath"]
+	resubmitPath := currentItem.Metadata["ResubmitPath"]
+
+	verb := "Purged"
+	if resubmit {
+		verb = "Resubmitted"
+	}
+	status, _ := eventing.SendStatusEvent(&eventing.StatusEvent{
+		InProgress: true,
+		IsToast:    true,
+		Message:    "Processing dead-letter messages: " + deadLetterPath,
+	})
+
+	count := 0
+	for ; count < serviceBusMaxBatchCount; count++ {
+		if resubmit {
+			var message *serviceBusMessage
+			message, err = client.lockMessage(ctx, deadLetterPath)
+			if err != nil || message == nil {
+				break
+			}
+			err = client.sendMessage(ctx, resubmitPath, message)
+			if err != nil {
+				_ = client.unlockMessage(ctx, deadLetterPath, message)
+				break
+			}
+			err = client.completeMessage(ctx, deadLetterPath, message)
+			if err != nil {
+				break
+			}
+		} else {
+			// receive and delete
+			var headers http.Header
+			_, headers, err = client.doRequest(ctx, "DELETE", deadLetterPath+"/messages/head?timeout=1", nil, nil)
+			if err != nil || headers.Get("BrokerProperties") == "" {
+				break
+			}
+		}
+		status.Message = fmt.Sprintf("%s %d messages: %s", verb, count+1, deadLetterPath)
+		status.Update()
+	}
+
+	message := fmt.Sprintf("%s %d messages from '%s'", verb, count, deadLetterPath)
+	if count == serviceBusMaxBatchCount {
+		message += fmt.Sprintf(" (stopped after %d messages, run the action again to continue)", serviceBusMaxBatchCount)
+	}
+	if err != nil {
+		status.Failure = true
+		status.Message = message + ": " + err.Error()
+	} else {
+		status.Message = message
+	}
+	status.Done()
+
+	return ExpanderResult{
+		Err:               err,
+		Response:          ExpanderResponse{Response: message, ResponseType: ResponsePlainText},
+		SourceDescription: "ServiceBusExpander request",
+		Nodes:             []*TreeNode{},
+		IsPrimaryResponse: true,
+	}
+}
+
+// newNode creates a child node, carrying the namespace metadata from the parent
+func (e *ServiceBusExpander) newNode(currentItem *TreeNode, name string, display string, itemType string, metadata map[string]string) *TreeNode {
+	nodeMetadata := map[string]string{
+		"NamespaceID":           currentItem.Metadata["NamespaceID"],
+		"SuppressSwaggerExpand": "true",
+		"SuppressGenericExpand": "true",
+	}
+	for key, value := range metadata {
+		nodeMetadata[key] = value
+	}
+	return &TreeNode{
+		Parentid:  currentItem.ID,
+		ID:        currentItem.ID + "/" + name,
+		Namespace: serviceBusNamespace,
+		Name:      name,
+		Display:   display,
+		ItemType:  itemType,
+		ExpandURL: ExpandURLNotSupported,
+		Metadata:  nodeMetadata,
+	}
+}
+
+func (e *ServiceBusExpander) errorResult(err error) ExpanderResult {
+	return ExpanderResult{
+		Err:               err,
+		SourceDescription: "ServiceBusExpander request",
+	}
+}
+
+func (e *ServiceBusExpander) testCases() (bool, *[]expanderTestCase) {
+	const namespaceID = "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/stable/providers/Microsoft.ServiceBus/namespaces/testbus"
+
+	queuesGockConfig := func(t *testing.T) {
+		gock.New("https://management.azure.com").
+			Get(namespaceID + "/queues").
+			Reply(200).
+			File("./testdata/armsamples/servicebus/queues.json")
+	}
+	messagesGockConfig := func(t *testing.T) {
+		gock.New("https://management.azure.com").
+			Get(namespaceID).
+			Reply(200).
+			File("./testdata/armsamples/servicebus/namespace.json")
+		gock.New("https://management.azure.com").
+			Post(namespaceID + "/AuthorizationRules/RootManageSharedAccessKey/listKeys").
+			Reply(200).
+			JSON(`{"keyName": "RootManageSharedAccessKey", "primaryKey": "dGVzdGtleQ=="}`)
+		gock.New("https://testbus.servicebus.windows.net").
+			Post("/orders/\\$DeadLetterQueue/messages/head").
+			MatchHeader("Authorization", "^SharedAccessSignature sr=").
+			Reply(201).
+			SetHeader("BrokerProperties", `{"MessageId":"order-1","SequenceNumber":12,"LockToken":"1b4c5a7e-1111-2222-3333-444455556666","DeliveryCount":10}`).
+			SetHeader("DeadLetterReason", `"MaxDeliveryCountExceeded"`).
+			SetHeader("Content-Type", "application/json").
+			BodyString(`{"orderId": 1}`)
+		gock.New("https://testbus.servicebus.windows.net").
+			Post("/orders/\\$DeadLetterQueue/messages/head").
+			Reply(204)
+		gock.New("https://testbus.servicebus.windows.net").
+			Put("/orders/\\$DeadLetterQueue/messages/12/1b4c5a7e-1111-2222-3333-444455556666").
+			Reply(200)
+	}
+
+	noRequestsGockConfig := func(t *testing.T) {}
+
+	return true, &[]expanderTestCase{
+		{
+			name: "Queues->Counts",
+			nodeToExpand: &TreeNode{
+				ID:        namespaceID + "/<explorer>/Queues",
+				Namespace: serviceBusNamespace,
+				ItemType:  serviceBusQueuesType,
+				ExpandURL: ExpandURLNotSupported,
+				Metadata:  map[string]string{"NamespaceID": namespaceID},
+			},
+			configureGockFunc: &queuesGockConfig,
+			treeNodeCheckerFunc: func(t *testing.T, r ExpanderResult) {
+				st.Expect(t, r.Err, nil)
+				st.Expect(t, len(r.Nodes), 2)
+
+				st.Expect(t, r.Nodes[0].Name, "orders")
+				st.Expect(t, r.Nodes[0].Display, "orders "+style.Subtle("(active: 5, dead-letter: 2, scheduled: 1)"))
+				st.Expect(t, r.Nodes[0].StatusIndicator, "⚠")
+				st.Expect(t, r.Nodes[0].Metadata["EntityPath"], "orders")
+
+				st.Expect(t, r.Nodes[1].Name, "payments")
+				st.Expect(t, r.Nodes[1].StatusIndicator, "")
+			},
+		},
+		{
+			name: "Messages->PeekLockWarning",
+			nodeToExpand: &TreeNode{
+				ID:        namespaceID + "/<explorer>/Queues/orders/Active messages",
+				Namespace: serviceBusNamespace,
+				ItemType:  serviceBusMessagesType,
+				ExpandURL: ExpandURLNotSupported,
+				Metadata: map[string]string{
+					"NamespaceID": namespaceID,
+					"EntityPath":  "orders",
+				},
+			},
+			configureGockFunc: &noRequestsGockConfig,
+			treeNodeCheckerFunc: func(t *testing.T, r ExpanderResult) {
+				// messages are only read once the peek-lock action is selected
+				st.Expect(t, r.Err, nil)
+				st.Expect(t, strings.Contains(r.Response.Response, "dead-letters any message that reaches the max delivery count"), true)
+				st.Expect(t, len(r.Nodes), 1)
+				st.Expect(t, r.Nodes[0].ItemType, ActionType)
+				st.Expect(t, r.Nodes[0].Metadata["ActionID"], "peek-lock")
+				st.Expect(t, r.Nodes[0].Metadata["EntityPath"], "orders")
+				st.Expect(t, r.Nodes[0].Metadata["MessagesType"], serviceBusMessagesType)
+			},
+		},
+		{
+			name: "Subscription->ResubmitConfirmation",
+			nodeToExpand: &TreeNode{
+				ID:        namespaceID + "/<explorer>/Topics/events/audit/Dead-letter messages/<action:resubmit>",
+				Namespace: serviceBusNamespace,
+				ItemType:  ActionType,
+				ExpandURL: ExpandURLNotSupported,
+				Metadata: map[string]string{
+					"NamespaceID":  namespaceID,
+					"ActionID":     "resubmit",
+					"EntityPath":   "events/subscriptions/audit/$DeadLetterQueue",
+					"ResubmitPath": "events",
+				},
+			},
+			configureGockFunc: &noRequestsGockConfig,
+			treeNodeCheckerFunc: func(t *testing.T, r ExpanderResult) {
+				// resubmitting goes to the topic so needs confirming before anything is sent
+				st.Expect(t, r.Err, nil)
+				st.Expect(t, strings.Contains(r.Response.Response, "Every subscription on the topic"), true)
+				st.Expect(t, len(r.Nodes), 1)
+				st.Expect(t, r.Nodes[0].Metadata["ActionID"], "resubmit-confirmed")
+				st.Expect(t, r.Nodes[0].Metadata["ResubmitPath"], "events")
+			},
+		},
+		{
+			name: "DeadLetters->PeekLock",
+			nodeToExpand: &TreeNode{
+				ID:        namespaceID + "/<explorer>/Queues/orders/Dead-letter messages/<action:peek-lock>",
+				Namespace: serviceBusNamespace,
+				ItemType:  ActionType,
+				ExpandURL: ExpandURLNotSupported,
+				Metadata: map[string]string{
+					"NamespaceID":  namespaceID,
+					"ActionID":     "peek-lock",
+					"MessagesType": serviceBusDeadLettersType,
+					"EntityPath":   "orders/$DeadLetterQueue",
+					"ResubmitPath": "orders",
+				},
+			},
+			configureGockFunc: &messagesGockConfig,
+			treeNodeCheckerFunc: func(t *testing.T, r ExpanderResult) {
+				st.Expect(t, r.Err, nil)
+
+				var response struct {
+					Messages []serviceBusMessage `json:"messages"`
+				}
+				err := json.Unmarshal([]byte(r.Response.Response), &response)
+				st.Expect(t, err, nil)
+				st.Expect(t, len(response.Messages), 1)
+				st.Expect(t, response.Messages[0].BrokerProperties.MessageID, "order-1")
+				st.Expect(t, response.Messages[0].BrokerProperties.LockToken, "")
+				st.Expect(t, response.Messages[0].Properties["Deadletterreason"], "MaxDeliveryCountExceeded")
+				st.Expect(t, response.Messages[0].Body, `{"orderId": 1}`)
+			},
+		},
+	}
+}
@@ -0,0 +1,427 @@
+package expanders
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/lawrencegripper/azbrowse/internal/pkg/style"
+)
+
+type sqlDatabase struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	Sku  struct {
+		Name     string `json:"name"`
+		Tier     string `json:"tier"`
+		Capacity int    `json:"capacity"`
+	} `json:"sku"`
+	Properties struct {
+		Status string `json:"status"`
+	} `json:"properties"`
+}
+
+type sqlUsage struct {
+	Name         string  `json:"name"`
+	DisplayName  string  `json:"displayName"`
+	CurrentValue float64 `json:"currentValue"`
+	Limit        float64 `json:"limit"`
+	Unit         string  `json:"unit"`
+}
+
+type sqlOperation struct {
+	Properties struct {
+		DatabaseName          string `json:"databaseName"`
+		OperationFriendlyName string `json:"operationFriendlyName"`
+		PercentComplete       int    `json:"percentComplete"`
+		StartTime             string `json:"startTime"`
+		State                 string `json:"state"`
+		ErrorDescription      string `json:"errorDescription"`
+	} `json:"properties"`
+}
+
+type sqlReplicationLink struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	Properties struct {
+		PartnerServer    string `json:"partnerServer"`
+		PartnerDatabase  string `json:"partnerDatabase"`
+		PartnerLocation  string `json:"partnerLocation"`
+		Role             string `json:"role"`
+		PartnerRole      string `json:"partnerRole"`
+		ReplicationMode  string `json:"replicationMode"`
+		ReplicationState string `json:"replicationState"`
+		PercentComplete  int    `json:"percentComplete"`
+	} `json:"properties"`
+}
+
+type sqlTopQueriesResponse struct {
+	Value []struct {
+		Properties struct {
+			Queries []struct {
+				QueryID   string `json:"queryId"`
+				Intervals []struct {
+					ExecutionCount int `json:"executionCount"`
+					Metrics        []struct {
+						Name  string  `json:"name"`
+						Unit  string  `json:"unit"`
+						Value float64 `json:"value"`
+					} `json:"metrics"`
+				} `json:"intervals"`
+			} `json:"queries"`
+		} `json:"properties"`
+	} `json:"value"`
+}
+
+// listDatabases returns the databases on the server, excluding the master database
+func (e *SQLServerExpander) listDatabases(ctx context.Context, serverID string) ([]sqlDatabase, error) {
+	data, err := e.armClient.DoRequest(ctx, "GET", serverID+"/databases?api-version="+sqlDatabaseAPIVersion)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to list databases: %s", err)
+	}
+	var response struct {
+		Value []sqlDatabase `json:"value"`
+	}
+	err = json.Unmarshal([]byte(data), &response)
+	if err != nil {
+		return nil, fmt.Errorf("Error unmarshalling databases: %s", err)
+	}
+	databases := []sqlDatabase{}
+	for _, database := range response.Value {
+		if !strings.EqualFold(database.Name, "master") {
+			databases = append(databases, database)
+		}
+	}
+	return databases, nil
+}
+
+// getDatabaseIDs returns the database for a database node or all the databases on the server for a server node
+func (e *SQLServerExpander) getDatabaseIDs(ctx context.Context, resourceID string) ([]string, error) {
+	if sqlDatabaseIDRegex.MatchString(resourceID) {
+		return []string{resourceID}, nil
+	}
+	databases, err := e.listDatabases(ctx, resourceID)
+	if err != nil {
+		return nil, err
+	}
+	ids := []string{}
+	for _, database := range databases {
+		ids = append(ids, database.ID)
+	}
+	return ids, nil
+}
+
+func (e *SQLServerExpander) listUsages(ctx context.Context, resourceID string) ([]sqlUsage, error) {
+	data, err := e.armClient.DoRequest(ctx, "GET", resourceID+"/usages?api-version="+sqlAPIVersion)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get usages: %s", err)
+	}
+	var response struct {
+		Value []sqlUsage `json:"value"`
+	}
+	err = json.Unmarshal([]byte(data), &response)
+	if err != nil {
+		return nil, fmt.Errorf("Error unmarshalling usages: %s", err)
+	}
+	return response.Value, nil
+}
+
+// formatSQLUsage formats the usage against its limit, highlighting usage over 80% of the limit
+func formatSQLUsage(usage sqlUsage) string {
+	var value string
+	if strings.EqualFold(usage.Unit, "Bytes") {
+		const gigabyte = 1 << 30
+		value = fmt.Sprintf("%.1f GB / %.1f GB", usage.CurrentValue/gigabyte, usage.Limit/gigabyte)
+	} else {
+		value = fmt.Sprintf("%.0f / %.0f", usage.CurrentValue, usage.Limit)
+	}
+	if usage.Limit <= 0 {
+		return value
+	}
+	percent := usage.CurrentValue / usage.Limit * 100
+	value = fmt.Sprintf("%-24s %3.0f%%", value, percent)
+	if percent >= 80 {
+		value = style.Warning(value)
+	}
+	return value
+}
+
+// expandDatabaseUsage shows the server quota usage and the size of each database against its maximum size
+func (e *SQLServerExpander) expandDatabaseUsage(ctx context.Context, currentItem *TreeNode) ExpanderResult {
+	serverID := currentItem.Metadata["ResourceID"]
+	serverUsages, err := e.listUsages(ctx, serverID)
+	if err != nil {
+		return ExpanderResult{
+			Err:               err,
+			SourceDescription: "SQLServerExpander request",
+		}
+	}
+	databases, err := e.listDatabases(ctx, serverID)
+	if err != nil {
+		return ExpanderResult{
+			Err:               err,
+			SourceDescription: "SQLServerExpander request",
+		}
+	}
+
+	lines := []string{style.Title("Server quota"), ""}
+	for _, usage := range serverUsages {
+		lines = append(lines, fmt.Sprintf("%-40s %s", usage.DisplayName, formatSQLUsage(usage)))
+	}
+	lines = append(lines, "", style.Title("Databases"), "")
+	lines = append(lines, fmt.Sprintf("%-30s %-20s %-10s %s", "Database", "SKU", "Status", "Size"))
+	for _, database := range databases {
+		usages, err := e.listUsages(ctx, database.ID)
+		if err != nil {
+			return ExpanderResult{
+				Err:               err,
+				SourceDescription: "SQLServerExpander request",
+			}
+		}
+		size := ""
+		for _, usage := range usages {
+			if usage.Name == "database_size" {
+				size = formatSQLUsage(usage)
+			}
+		}
+		sku := database.Sku.Name
+		if database.Sku.Capacity > 0 {
+			sku += fmt.Sprintf(" (%d)", database.Sku.Capacity)
+		}
+		lines = append(lines, fmt.Sprintf("%-30s %-20s %-10s %s", database.Name, sku, database.Properties.Status, size))
+	}
+
+	return ExpanderResult{
+		Response:          ExpanderResponse{Response: strings.Join(lines, "\n"), ResponseType: ResponsePlainText},
+		SourceDescription: "SQLServerExpander request",
+		IsPrimaryResponse: true,
+	}
+}
+
+// expandOperations lists the long running operations (e.g. scaling or restores) for the database or the server's databases
+func (e *SQLServerExpander) expandOperations(ctx context.Context, currentItem *TreeNode) ExpanderResult {
+	databaseIDs, err := e.getDatabaseIDs(ctx, currentItem.Metadata["ResourceID"])
+	if err != nil {
+		return ExpanderResult{
+			Err:               err,
+			SourceDescription: "SQLServerExpander request",
+		}
+	}
+
+	lines := []string{
+		style.Title("Operations"),
+		"",
+		fmt.Sprintf("%-25s %-30s %-12s %8s  %s", "Database", "Operation", "State", "Complete", "Started"),
+	}
+	count := 0
+	for _, databaseID := range databaseIDs {
+		data, err := e.armClient.DoRequest(ctx, "GET", databaseID+"/operations?api-version="+sqlDatabaseAPIVersion)
+		if err != nil {
+			return ExpanderResult{
+				Err:               fmt.Errorf("Failed to list operations: %s", err),
+				SourceDescription: "SQLServerExpander request",
+			}
+		}
+		var response struct {
+			Value []sqlOperation `json:"value"`
+		}
+		err = json.Unmarshal([]byte(data), &response)
+		if err != nil {
+			return ExpanderResult{
+				Err:               fmt.Errorf("Error unmarshalling operations: %s", err),
+				SourceDescription: "SQLServerExpander request",
+			}
+		}
+		for _, operation := range response.Value {
+			properties := operation.Properties
+			lines = append(lines, fmt.Sprintf("%-25s %-30s %-12s %7d%%  %s", properties.DatabaseName, properties.OperationFriendlyName, properties.State, properties.PercentComplete, properties.StartTime))
+			if properties.ErrorDescription != "" {
+				lines = append(lines, "  "+style.Warning(properties.ErrorDescription))
+			}
+			count++
+		}
+	}
+	if count == 0 {
+		lines = append(lines, "No operations")
+	}
+
+	return ExpanderResult{
+		Response:          ExpanderResponse{Response: strings.Join(lines, "\n"), ResponseType: ResponsePlainText},
+		SourceDescription: "SQLServerExpander request",
+		IsPrimaryResponse: true,
+	}
+}
+
+func drawSQLReplicationStatus(replicationState string) string {
+	switch replicationState {
+	case "CATCH_UP":
+		return DrawStatus("Succeeded")
+	case "PENDING", "SEEDING":
+		return DrawStatus("Provisioning")
+	case "SUSPENDED":
+		return DrawStatus("Failed")
+	}
+	return ""
+}
+
+// expandReplicationLinks lists the geo-replication links of the database or the server's databases
+func (e *SQLServerExpander) expandReplicationLinks(ctx context.Context, currentItem *TreeNode) ExpanderResult {
+	databaseIDs, err := e.getDatabaseIDs(ctx, currentItem.Metadata["ResourceID"])
+	if err != nil {
+		return ExpanderResult{
+			Err:               err,
+			SourceDescription: "SQLServerExpander request",
+		}
+	}
+
+	newItems := []*TreeNode{}
+	lines := []string{style.Title("Geo-replication"), ""}
+	for _, databaseID := range databaseIDs {
+		databaseName := databaseID[strings.LastIndex(databaseID, "/")+1:]
+		data, err := e.armClient.DoRequest(ctx, "GET", databaseID+"/replicationLinks?api-version="+sqlAPIVersion)
+		if err != nil {
+			return ExpanderResult{
+				Err:               fmt.Errorf("Failed to list replication links: %s", err),
+				SourceDescription: "SQLServerExpander request",
+			}
+		}
+		var response struct {
+			Value []sqlReplicationLink `json:"value"`
+		}
+		err = json.Unmarshal([]byte(data), &response)
+		if err != nil {
+			return ExpanderResult{
+				Err:               fmt.Errorf("Error unmarshalling replication links: %s", err),
+				SourceDescription: "SQLServerExpander request",
+			}
+		}
+		for _, link := range response.Value {
+			properties := link.Properties
+			partner := properties.PartnerServer + "/" + properties.PartnerDatabase
+			node := e.newNode(currentItem, sqlReplicationLinkType, databaseName+" -> "+partner)
+			node.ID = link.ID
+			node.ExpandURL = link.ID + "?api-version=" + sqlAPIVersion
+			node.Display = databaseName + " -> " + partner + "\n  " + style.Subtle(properties.Role+" (partner "+properties.PartnerRole+" in "+properties.PartnerLocation+"), "+properties.ReplicationState)
+			node.StatusIndicator = drawSQLReplicationStatus(properties.ReplicationState)
+			node.Metadata["Role"] = properties.Role
+			node.Metadata["DatabaseName"] = databaseName
+			newItems = append(newItems, node)
+
+			lines = append(lines, fmt.Sprintf("%-25s %-10s -> %-40s %-10s %-10s %3d%%", databaseName, properties.Role, partner, properties.PartnerRole, properties.ReplicationState, properties.PercentComplete))
+		}
+	}
+	if len(newItems) == 0 {
+		lines = append(lines, "No geo-replication links")
+	} else {
+		lines = append(lines, "", style.Subtle("Failover actions are available on the links of secondary databases"))
+	}
+
+	return ExpanderResult{
+		Nodes:             newItems,
+		Response:          ExpanderResponse{Response: strings.Join(lines, "\n"), ResponseType: ResponsePlainText},
+		SourceDescription: "SQLServerExpander request",
+		IsPrimaryResponse: true,
+	}
+}
+
+// expandTopQueries shows the queries using the most CPU from query performance insight
+func (e *SQLServerExpander) expandTopQueries(ctx context.Context, currentItem *TreeNode) ExpanderResult {
+	databaseID := currentItem.Metadata["ResourceID"]
+	params := url.Values{}
+	params.Set("api-version", sqlAPIVersion)
+	params.Set("numberOfQueries", "10")
+	params.Set("aggregationFunction", "sum")
+	params.Set("observationMetric", "cpu")
+	params.Set("interval", "PT1H")
+	data, err := e.armClient.DoRequest(ctx, "GET", databaseID+"/topQueries?"+params.Encode())
+	if err != nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Failed to get top queries (query store must be enabled): %s", err),
+			SourceDescription: "SQLServerExpander request",
+		}
+	}
+	var response sqlTopQueriesResponse
+	err = json.Unmarshal([]byte(data), &response)
+	if err != nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Error unmarshalling top queries: %s", err),
+			SourceDescription: "SQLServerExpander request",
+		}
+	}
+
+	newItems := []*TreeNode{}
+	cpuUnit, durationUnit := "", ""
+	lines := []string{
+		style.Title("Top queries by CPU"),
+		"",
+		fmt.Sprintf("%-10s %10s %13s %10s", "Query ID", "CPU", "Duration", "Executions"),
+	}
+	for _, topQueries := range response.Value {
+		for _, query := range topQueries.Properties.Queries {
+			var cpu, duration float64
+			executions := 0
+			for _, interval := range query.Intervals {
+				executions += interval.ExecutionCount
+				for _, metric := range interval.Metrics {
+					switch metric.Name {
+					case "cpu":
+						cpu += metric.Value
+						cpuUnit = metric.Unit
+					case "duration":
+						duration += metric.Value
+						durationUnit = metric.Unit
+					}
+				}
+			}
+			lines = append(lines, fmt.Sprintf("%-10s %10.2f %13.2f %10d", query.QueryID, cpu, duration, executions))
+
+			node := e.newNode(currentItem, sqlQueryType, query.QueryID)
+			node.ID = databaseID + "/queries/" + query.QueryID
+			node.Display = query.QueryID + " " + style.Subtle(fmt.Sprintf("cpu: %.2f, executions: %d", cpu, executions))
+			node.Metadata["QueryID"] = query.QueryID
+			newItems = append(newItems, node)
+		}
+	}
+	lines = append(lines, "", style.Subtle("CPU ("+cpuUnit+") and duration ("+durationUnit+") are summed over the returned intervals. Expand a query to see its text"))
+
+	return ExpanderResult{
+		Nodes:             newItems,
+		Response:          ExpanderResponse{Response: strings.Join(lines, "\n"), ResponseType: ResponsePlainText},
+		SourceDescription: "SQLServerExpander request",
+		IsPrimaryResponse: true,
+	}
+}
+
+// expandQuery shows the text of a query from query performance insight
+func (e *SQLServerExpander) expandQuery(ctx context.Context, currentItem *TreeNode) ExpanderResult {
+	data, err := e.armClient.DoRequest(ctx, "GET", currentItem.Metadata["ResourceID"]+"/queries/"+currentItem.Metadata["QueryID"]+"/texts?api-version="+sqlAPIVersion)
+	if err != nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Failed to get query text: %s", err),
+			SourceDescription: "SQLServerExpander request",
+		}
+	}
+	var response struct {
+		Value []struct {
+			QueryText string `json:"queryText"`
+		} `json:"value"`
+	}
+	err = json.Unmarshal([]byte(data), &response)
+	if err != nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Error unmarshalling query text: %s", err),
+			SourceDescription: "SQLServerExpander request",
+		}
+	}
+	texts := []string{}
+	for _, text := range response.Value {
+		texts = append(texts, text.QueryText)
+	}
+	return ExpanderResult{
+		Response:          ExpanderResponse{Response: strings.Join(texts, "\n\n"), ResponseType: ResponsePlainText},
+		SourceDescription: "SQLServerExpander request",
+		IsPrimaryResponse: true,
+	}
+}
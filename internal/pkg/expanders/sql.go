@@ -0,0 +1,556 @@
+package expanders
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/lawrencegripper/azbrowse/internal/pkg/style"
+	"github.com/lawrencegripper/azbrowse/pkg/armclient"
+	"github.com/nbio/st"
+	"gopkg.in/h2non/gock.v1"
+)
+
+const (
+	sqlNamespace = "sql"
+	// sqlAPIVersion is used for the firewall, usage, replication link and query performance APIs
+	sqlAPIVersion = "2014-04-01"
+	// sqlDatabaseAPIVersion is used for the database and database operation APIs
+	sqlDatabaseAPIVersion = "2017-10-01-preview"
+
+	sqlFirewallRulesType    = "sql.firewallRules"
+	sqlFirewallRuleType     = "sql.firewallRule"
+	sqlDatabaseUsageType    = "sql.databaseUsage"
+	sqlOperationsType       = "sql.operations"
+	sqlReplicationLinksType = "sql.replicationLinks"
+	sqlReplicationLinkType  = "sql.replicationLink"
+	sqlTopQueriesType       = "sql.topQueries"
+	sqlQueryType            = "sql.query"
+
+	// sqlPublicIPURL returns the caller's public IP address as plain text
+	sqlPublicIPURL = "https://api.ipify.org"
+)
+
+var sqlServerIDRegex = regexp.MustCompile(`(?i)^/subscriptions/[^/]+/resourceGroups/[^/]+/providers/Microsoft\.Sql/servers/[^/]+$`)
+var sqlDatabaseIDRegex = regexp.MustCompile(`(?i)^(/subscriptions/[^/]+/resourceGroups/[^/]+/providers/Microsoft\.Sql/servers/[^/]+)/databases/([^/]+)$`)
+
+type sqlFirewallRule struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	Properties struct {
+		StartIPAddress string `json:"startIpAddress"`
+		EndIPAddress   string `json:"endIpAddress"`
+	} `json:"properties"`
+}
+
+// Check interface
+var _ Expander = &SQLServerExpander{}
+var _ ConfirmedActionRunner = &SQLServerExpander{}
+
+// SQLServerExpander adds operational views to SQL servers and databases: firewall rules, usage against quota,
+// long running operations, geo-replication links and the top queries from query performance insight
+type SQLServerExpander struct {
+	ExpanderBase
+	client    *http.Client
+	armClient *armclient.Client
+}
+
+// NewSQLServerExpander creates a new instance of SQLServerExpander
+func NewSQLServerExpander(armclient *armclient.Client) *SQLServerExpander {
+	return &SQLServerExpander{
+		client:    &http.Client{},
+		armClient: armclient,
+	}
+}
+
+func (e *SQLServerExpander) setClient(c *armclient.Client) {
+	e.armClient = c
+}
+
+// Name returns the name of the expander
+func (e *SQLServerExpander) Name() string {
+	return "SQLServerExpander"
+}
+
+func isSQLServer(node *TreeNode) bool {
+	return node != nil && node.ItemType == ResourceType && sqlServerIDRegex.MatchString(node.ID)
+}
+
+func isSQLDatabase(node *TreeNode) bool {
+	if node == nil || (node.ItemType != ResourceType && node.ItemType != SubResourceType) {
+		return false
+	}
+	match := sqlDatabaseIDRegex.FindStringSubmatch(node.ID)
+	return match != nil && !strings.EqualFold(match[2], "master")
+}
+
+// DoesExpand checks if this is a SQL server or database or one of the nodes added for them
+func (e *SQLServerExpander) DoesExpand(ctx context.Context, currentItem *TreeNode) (bool, error) {
+	if currentItem.Namespace == sqlNamespace {
+		return true, nil
+	}
+	return isSQLServer(currentItem) || isSQLDatabase(currentItem), nil
+}
+
+// Expand adds the SQL nodes to a server or database or expands them
+func (e *SQLServerExpander) Expand(ctx context.Context, currentItem *TreeNode) ExpanderResult {
+	switch currentItem.ItemType {
+	case sqlFirewallRulesType:
+		return e.expandFirewallRules(ctx, currentItem)
+	case sqlFirewallRuleType, sqlReplicationLinkType:
+		data, err := e.armClient.DoRequest(ctx, "GET", currentItem.ExpandURL)
+		if err != nil {
+			return ExpanderResult{
+				Err:               err,
+				SourceDescription: "SQLServerExpander request",
+			}
+		}
+		return ExpanderResult{
+			Response:          ExpanderResponse{Response: data, ResponseType: ResponseJSON},
+			SourceDescription: "SQLServerExpander request",
+			IsPrimaryResponse: true,
+		}
+	case sqlDatabaseUsageType:
+		return e.expandDatabaseUsage(ctx, currentItem)
+	case sqlOperationsType:
+		return e.expandOperations(ctx, currentItem)
+	case sqlReplicationLinksType:
+		return e.expandReplicationLinks(ctx, currentItem)
+	case sqlTopQueriesType:
+		return e.expandTopQueries(ctx, currentItem)
+	case sqlQueryType:
+		return e.expandQuery(ctx, currentItem)
+	case ActionType:
+		return ExpanderResult{
+			Response:          RequestActionConfirmation(e, currentItem),
+			SourceDescription: "SQLServerExpander action",
+			IsPrimaryResponse: true,
+		}
+	}
+
+	newItems := []*TreeNode{}
+	if isSQLServer(currentItem) {
+		newItems = append(newItems,
+			e.newNode(currentItem, sqlFirewallRulesType, "Firewall rules"),
+			e.newNode(currentItem, sqlDatabaseUsageType, "Database usage"),
+			e.newNode(currentItem, sqlOperationsType, "Operations"),
+			e.newNode(currentItem, sqlReplicationLinksType, "Geo-replication"))
+	} else {
+		newItems = append(newItems,
+			e.newNode(currentItem, sqlTopQueriesType, "Top queries (query performance insight)"),
+			e.newNode(currentItem, sqlOperationsType, "Operations"),
+			e.newNode(currentItem, sqlReplicationLinksType, "Geo-replication"))
+	}
+	return ExpanderResult{
+		Nodes:             newItems,
+		SourceDescription: "SQLServerExpander request",
+	}
+}
+
+func (e *SQLServerExpander) newNode(currentItem *TreeNode, itemType string, name string) *TreeNode {
+	return &TreeNode{
+		Parentid:       currentItem.ID,
+		ID:             currentItem.ID + "/<" + itemType + ">",
+		Namespace:      sqlNamespace,
+		Name:           name,
+		Display:        name,
+		ItemType:       itemType,
+		ExpandURL:      ExpandURLNotSupported,
+		SubscriptionID: currentItem.SubscriptionID,
+		Metadata: map[string]string{
+			"ResourceID":            currentItem.ID,
+			"SuppressSwaggerExpand": "true",
+			"SuppressGenericExpand": "true",
+		},
+	}
+}
+
+func (e *SQLServerExpander) listFirewallRules(ctx context.Context, serverID string) ([]sqlFirewallRule, error) {
+	data, err := e.armClient.DoRequest(ctx, "GET", serverID+"/firewallRules?api-version="+sqlAPIVersion)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to list firewall rules: %s", err)
+	}
+	var response struct {
+		Value []sqlFirewallRule `json:"value"`
+	}
+	err = json.Unmarshal([]byte(data), &response)
+	if err != nil {
+		return nil, fmt.Errorf("Error unmarshalling firewall rules: %s", err)
+	}
+	return response.Value, nil
+}
+
+// expandFirewallRules lists the server's firewall rules, the rules can be deleted and the actions on this node
+// add or remove a rule for the caller's public IP
+func (e *SQLServerExpander) expandFirewallRules(ctx context.Context, currentItem *TreeNode) ExpanderResult {
+	rules, err := e.listFirewallRules(ctx, currentItem.Metadata["ResourceID"])
+	if err != nil {
+		return ExpanderResult{
+			Err:               err,
+			SourceDescription: "SQLServerExpander request",
+		}
+	}
+
+	newItems := []*TreeNode{}
+	lines := []string{style.Title("Firewall rules"), ""}
+	for _, rule := range rules {
+		ipRange := rule.Properties.StartIPAddress
+		if rule.Properties.EndIPAddress != rule.Properties.StartIPAddress {
+			ipRange += " - " + rule.Properties.EndIPAddress
+		}
+		// 0.0.0.0 is the special rule allowing access from Azure services
+		if ipRange == "0.0.0.0" {
+			ipRange += " (Azure services)"
+		}
+		node := e.newNode(currentItem, sqlFirewallRuleType, rule.Name)
+		node.ID = rule.ID
+		node.Display = rule.Name + " " + style.Subtle(ipRange)
+		node.ExpandURL = rule.ID + "?api-version=" + sqlAPIVersion
+		node.DeleteURL = node.ExpandURL
+		newItems = append(newItems, node)
+		lines = append(lines, fmt.Sprintf("%-40s %s", rule.Name, ipRange))
+	}
+	lines = append(lines, "", style.Subtle("Use the actions on this node to add or remove a rule for your public IP"))
+
+	return ExpanderResult{
+		Nodes:             newItems,
+		Response:          ExpanderResponse{Response: strings.Join(lines, "\n"), ResponseType: ResponsePlainText},
+		SourceDescription: "SQLServerExpander request",
+		IsPrimaryResponse: true,
+	}
+}
+
+// getPublicIP detects the caller's public IPv4 address, which is the address SQL firewall rules need to allow
+func (e *SQLServerExpander) getPublicIP(ctx context.Context) (string, error) {
+	req, err := http.NewRequest("GET", sqlPublicIPURL, nil)
+	if err != nil {
+		return "", err
+	}
+	response, err := e.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return "", fmt.Errorf("Failed to detect public IP: %s", err)
+	}
+	defer response.Body.Close() //nolint: errcheck
+	buf, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return "", fmt.Errorf("Failed to read public IP: %s", err)
+	}
+	ip := net.ParseIP(strings.TrimSpace(string(buf)))
+	if response.StatusCode != http.StatusOK || ip == nil || ip.To4() == nil {
+		return "", fmt.Errorf("Failed to detect public IPv4 address: %s %s", response.Status, string(buf))
+	}
+	return ip.String(), nil
+}
+
+// sqlFirewallRuleName returns the name used for the rule added for the IP address
+func sqlFirewallRuleName(ip string) string {
+	return "azbrowse-" + strings.Replace(ip, ".", "-", -1)
+}
+
+// HasActions checks if the item is a SQL server, its firewall rules or a replication link
+func (e *SQLServerExpander) HasActions(ctx context.Context, currentItem *TreeNode) (bool, error) {
+	switch {
+	case isSQLServer(currentItem):
+		return true, nil
+	case currentItem.Namespace != sqlNamespace:
+		return false, nil
+	case currentItem.ItemType == sqlFirewallRulesType:
+		return true, nil
+	case currentItem.ItemType == sqlReplicationLinkType:
+		// Failover is run from the secondary database
+		return currentItem.Metadata["Role"] == "Secondary", nil
+	}
+	return false, nil
+}
+
+// ListActions returns the firewall actions for the caller's IP or the failover actions for a replication link
+func (e *SQLServerExpander) ListActions(ctx context.Context, currentItem *TreeNode) ListActionsResult {
+	if currentItem.ItemType == sqlReplicationLinkType {
+		return ListActionsResult{
+			Nodes: []*TreeNode{
+				e.newAction(currentItem, "failover", "Failover (planned, no data loss)", "Fail over to make "+currentItem.Metadata["DatabaseName"]+" the primary"),
+				e.newAction(currentItem, "forceFailoverAllowDataLoss", "Forced failover (allow data loss)", "Force fail over to make "+currentItem.Metadata["DatabaseName"]+" the primary, allowing data loss"),
+			},
+			SourceDescription: "SQLServerExpander actions",
+		}
+	}
+
+	serverID := currentItem.ID
+	if currentItem.ItemType == sqlFirewallRulesType {
+		serverID = currentItem.Metadata["ResourceID"]
+	}
+	ip, err := e.getPublicIP(ctx)
+	if err != nil {
+		return ListActionsResult{
+			Err:               err,
+			SourceDescription: "SQLServerExpander actions",
+		}
+	}
+	rules, err := e.listFirewallRules(ctx, serverID)
+	if err != nil {
+		return ListActionsResult{
+			Err:               err,
+			SourceDescription: "SQLServerExpander actions",
+		}
+	}
+
+	nodes := []*TreeNode{}
+	serverName := serverID[strings.LastIndex(serverID, "/")+1:]
+	existingRule := ""
+	for _, rule := range rules {
+		if rule.Properties.StartIPAddress == ip && rule.Properties.EndIPAddress == ip {
+			existingRule = rule.Name
+			break
+		}
+	}
+	if existingRule == "" {
+		action := e.newAction(currentItem, "addFirewallRule", "Add firewall rule for my IP ("+ip+")", "Add firewall rule "+sqlFirewallRuleName(ip)+" for "+ip+" to "+serverName)
+		action.Metadata["ServerID"] = serverID
+		action.Metadata["IPAddress"] = ip
+		nodes = append(nodes, action)
+	} else {
+		action := e.newAction(currentItem, "removeFirewallRule", "Remove firewall rule for my IP ("+ip+")", "Remove firewall rule "+existingRule+" for "+ip+" from "+serverName)
+		action.Metadata["ServerID"] = serverID
+		action.Metadata["RuleName"] = existingRule
+		nodes = append(nodes, action)
+	}
+	return ListActionsResult{
+		Nodes:             nodes,
+		SourceDescription: "SQLServerExpander actions",
+	}
+}
+
+func (e *SQLServerExpander) newAction(currentItem *TreeNode, actionID string, display string, description string) *TreeNode {
+	action := e.newNode(currentItem, ActionType, description)
+	action.ID = currentItem.ID + "/<actions>/" + actionID
+	action.Display = display
+	action.Metadata["ActionID"] = actionID
+	action.Metadata["LinkID"] = currentItem.ID
+	return action
+}
+
+// RunConfirmedAction changes the firewall rules or fails over a replication link once confirmed in the notifications panel
+func (e *SQLServerExpander) RunConfirmedAction(ctx context.Context, action *TreeNode) error {
+	var err error
+	switch action.Metadata["ActionID"] {
+	case "addFirewallRule":
+		ip := action.Metadata["IPAddress"]
+		rule := sqlFirewallRule{}
+		rule.Properties.StartIPAddress = ip
+		rule.Properties.EndIPAddress = ip
+		body, marshalErr := json.Marshal(map[string]interface{}{"properties": rule.Properties})
+		if marshalErr != nil {
+			return marshalErr
+		}
+		_, err = e.armClient.DoRequestWithBody(ctx, "PUT", action.Metadata["ServerID"]+"/firewallRules/"+sqlFirewallRuleName(ip)+"?api-version="+sqlAPIVersion, string(body))
+	case "removeFirewallRule":
+		_, err = e.armClient.DoRequest(ctx, "DELETE", action.Metadata["ServerID"]+"/firewallRules/"+action.Metadata["RuleName"]+"?api-version="+sqlAPIVersion)
+	case "failover", "forceFailoverAllowDataLoss":
+		_, err = e.armClient.DoRequest(ctx, "POST", action.Metadata["LinkID"]+"/"+action.Metadata["ActionID"]+"?api-version="+sqlAPIVersion)
+	default:
+		return fmt.Errorf("Unhandled action: %s", action.Metadata["ActionID"])
+	}
+	return err
+}
+
+func (e *SQLServerExpander) testCases() (bool, *[]expanderTestCase) {
+	const serverID = "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/stable/providers/Microsoft.Sql/servers/testsql"
+	const databaseID = serverID + "/databases/testdb"
+	const linkID = databaseID + "/replicationLinks/5b0e9fcd-7c9a-4a25-b3b7-8b6b7a5e1a43"
+
+	serverNode := &TreeNode{ID: serverID, Name: "testsql", ItemType: ResourceType}
+	databaseNode := &TreeNode{ID: databaseID, Name: "testdb", ItemType: SubResourceType}
+	firewallRulesNode := e.newNode(serverNode, sqlFirewallRulesType, "Firewall rules")
+	linkNode := e.newNode(databaseNode, sqlReplicationLinkType, "link")
+	linkNode.ID = linkID
+	linkNode.Metadata["Role"] = "Secondary"
+	linkNode.Metadata["DatabaseName"] = "testdb"
+
+	noRequestsGockConfig := func(t *testing.T) {}
+	firewallRulesGockConfig := func(t *testing.T) {
+		gock.New("https://management.azure.com").
+			Get(serverID + "/firewallRules").
+			Reply(200).
+			File("./testdata/armsamples/sql/firewallRules.json")
+	}
+	addFirewallRuleGockConfig := func(t *testing.T) {
+		firewallRulesGockConfig(t)
+		gock.New(sqlPublicIPURL).
+			Reply(200).
+			BodyString("203.0.113.7\n")
+		firewallRulesGockConfig(t)
+		gock.New("https://management.azure.com").
+			Put(serverID + "/firewallRules/azbrowse-203-0-113-7").
+			JSON(map[string]interface{}{"properties": map[string]string{"startIpAddress": "203.0.113.7", "endIpAddress": "203.0.113.7"}}).
+			Reply(200)
+	}
+	removeFirewallRuleGockConfig := func(t *testing.T) {
+		gock.New(sqlPublicIPURL).
+			Reply(200).
+			BodyString("198.51.100.20")
+		firewallRulesGockConfig(t)
+		gock.New("https://management.azure.com").
+			Delete(serverID + "/firewallRules/office").
+			Reply(200)
+	}
+	usageGockConfig := func(t *testing.T) {
+		gock.New("https://management.azure.com").
+			Get(serverID + "/usages").
+			Reply(200).
+			File("./testdata/armsamples/sql/serverUsages.json")
+		gock.New("https://management.azure.com").
+			Get(serverID + "/databases").
+			Reply(200).
+			File("./testdata/armsamples/sql/databases.json")
+		gock.New("https://management.azure.com").
+			Get(databaseID + "/usages").
+			Reply(200).
+			File("./testdata/armsamples/sql/databaseUsages.json")
+	}
+	operationsGockConfig := func(t *testing.T) {
+		gock.New("https://management.azure.com").
+			Get(databaseID + "/operations").
+			Reply(200).
+			File("./testdata/armsamples/sql/operations.json")
+	}
+	replicationLinksGockConfig := func(t *testing.T) {
+		gock.New("https://management.azure.com").
+			Get(serverID + "/databases").
+			Reply(200).
+			File("./testdata/armsamples/sql/databases.json")
+		gock.New("https://management.azure.com").
+			Get(databaseID + "/replicationLinks").
+			Reply(200).
+			File("./testdata/armsamples/sql/replicationLinks.json")
+	}
+	failoverGockConfig := func(t *testing.T) {
+		gock.New("https://management.azure.com").
+			Post(linkID + "/failover").
+			Reply(202)
+	}
+	topQueriesGockConfig := func(t *testing.T) {
+		gock.New("https://management.azure.com").
+			Get(databaseID+"/topQueries").
+			MatchParam("observationMetric", "cpu").
+			Reply(200).
+			File("./testdata/armsamples/sql/topQueries.json")
+	}
+
+	return true, &[]expanderTestCase{
+		{
+			name:              "SQL->Server",
+			nodeToExpand:      serverNode,
+			configureGockFunc: &noRequestsGockConfig,
+			treeNodeCheckerFunc: func(t *testing.T, r ExpanderResult) {
+				st.Expect(t, r.Err, nil)
+				st.Expect(t, len(r.Nodes), 4)
+				st.Expect(t, r.Nodes[0].ItemType, sqlFirewallRulesType)
+			},
+		},
+		{
+			name:              "SQL->FirewallRules",
+			nodeToExpand:      firewallRulesNode,
+			configureGockFunc: &firewallRulesGockConfig,
+			treeNodeCheckerFunc: func(t *testing.T, r ExpanderResult) {
+				st.Expect(t, r.Err, nil)
+				st.Expect(t, len(r.Nodes), 2)
+				st.Expect(t, r.Nodes[0].Name, "AllowAllWindowsAzureIps")
+				st.Expect(t, strings.Contains(r.Nodes[0].Display, "Azure services"), true)
+				st.Expect(t, r.Nodes[1].DeleteURL, serverID+"/firewallRules/office?api-version="+sqlAPIVersion)
+			},
+		},
+		{
+			name:              "SQL->AddFirewallRuleForMyIP",
+			nodeToExpand:      firewallRulesNode,
+			configureGockFunc: &addFirewallRuleGockConfig,
+			treeNodeCheckerFunc: func(t *testing.T, r ExpanderResult) {
+				st.Expect(t, r.Err, nil)
+				actions := e.ListActions(context.Background(), firewallRulesNode)
+				st.Expect(t, actions.Err, nil)
+				st.Expect(t, len(actions.Nodes), 1)
+				st.Expect(t, actions.Nodes[0].Display, "Add firewall rule for my IP (203.0.113.7)")
+
+				err := e.RunConfirmedAction(context.Background(), actions.Nodes[0])
+				st.Expect(t, err, nil)
+			},
+		},
+		{
+			name:              "SQL->RemoveFirewallRuleForMyIP",
+			nodeToExpand:      serverNode,
+			configureGockFunc: &removeFirewallRuleGockConfig,
+			treeNodeCheckerFunc: func(t *testing.T, r ExpanderResult) {
+				actions := e.ListActions(context.Background(), serverNode)
+				st.Expect(t, actions.Err, nil)
+				st.Expect(t, actions.Nodes[0].Metadata["RuleName"], "office")
+
+				err := e.RunConfirmedAction(context.Background(), actions.Nodes[0])
+				st.Expect(t, err, nil)
+			},
+		},
+		{
+			name:              "SQL->DatabaseUsage",
+			nodeToExpand:      e.newNode(serverNode, sqlDatabaseUsageType, "Database usage"),
+			configureGockFunc: &usageGockConfig,
+			treeNodeCheckerFunc: func(t *testing.T, r ExpanderResult) {
+				st.Expect(t, r.Err, nil)
+				st.Expect(t, strings.Contains(r.Response.Response, "Database Throughput Unit Quota"), true)
+				st.Expect(t, strings.Contains(r.Response.Response, "master"), false)
+				st.Expect(t, strings.Contains(r.Response.Response, "1.6 GB / 2.0 GB"), true)
+				st.Expect(t, strings.Contains(r.Response.Response, "80%"), true)
+			},
+		},
+		{
+			name:              "SQL->Operations",
+			nodeToExpand:      e.newNode(databaseNode, sqlOperationsType, "Operations"),
+			configureGockFunc: &operationsGockConfig,
+			treeNodeCheckerFunc: func(t *testing.T, r ExpanderResult) {
+				st.Expect(t, r.Err, nil)
+				st.Expect(t, strings.Contains(r.Response.Response, "UPDATE SLO"), true)
+				st.Expect(t, strings.Contains(r.Response.Response, "45%"), true)
+			},
+		},
+		{
+			name:              "SQL->ReplicationLinks",
+			nodeToExpand:      e.newNode(serverNode, sqlReplicationLinksType, "Geo-replication"),
+			configureGockFunc: &replicationLinksGockConfig,
+			treeNodeCheckerFunc: func(t *testing.T, r ExpanderResult) {
+				st.Expect(t, r.Err, nil)
+				st.Expect(t, len(r.Nodes), 1)
+				st.Expect(t, r.Nodes[0].ID, linkID)
+				st.Expect(t, r.Nodes[0].Metadata["Role"], "Primary")
+				st.Expect(t, r.Nodes[0].StatusIndicator, DrawStatus("Succeeded"))
+				st.Expect(t, strings.Contains(r.Nodes[0].Display, "testsql-dr/testdb"), true)
+				hasActions, _ := e.HasActions(context.Background(), r.Nodes[0])
+				st.Expect(t, hasActions, false)
+			},
+		},
+		{
+			name:              "SQL->FailoverConfirmed",
+			nodeToExpand:      e.ListActions(context.Background(), linkNode).Nodes[0],
+			configureGockFunc: &failoverGockConfig,
+			treeNodeCheckerFunc: func(t *testing.T, r ExpanderResult) {
+				st.Expect(t, r.Err, nil)
+				err := e.RunConfirmedAction(context.Background(), e.ListActions(context.Background(), linkNode).Nodes[0])
+				st.Expect(t, err, nil)
+			},
+		},
+		{
+			name:              "SQL->TopQueries",
+			nodeToExpand:      e.newNode(databaseNode, sqlTopQueriesType, "Top queries"),
+			configureGockFunc: &topQueriesGockConfig,
+			treeNodeCheckerFunc: func(t *testing.T, r ExpanderResult) {
+				st.Expect(t, r.Err, nil)
+				st.Expect(t, len(r.Nodes), 2)
+				st.Expect(t, r.Nodes[0].Name, "1021")
+				// CPU is summed across the intervals
+				st.Expect(t, strings.Contains(r.Response.Response, "1021             7.50       1250.00        120"), true)
+			},
+		},
+	}
+}
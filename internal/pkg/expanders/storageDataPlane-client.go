@@ -0,0 +1,365 @@
+package expanders
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lawrencegripper/azbrowse/internal/pkg/tracing"
+	"github.com/lawrencegripper/azbrowse/pkg/armclient"
+)
+
+const (
+	storageAPIVersion     = "2019-12-12"
+	storageResource       = "https://storage.azure.com/"
+	storageSASLifetime    = time.Hour
+	storageSASPermissions = "rdl"
+)
+
+type storageAccountResponse struct {
+	Properties struct {
+		PrimaryEndpoints storageEndpoints `json:"primaryEndpoints"`
+	} `json:"properties"`
+}
+
+type storageEndpoints struct {
+	Blob  string `json:"blob"`
+	Queue string `json:"queue"`
+	Table string `json:"table"`
+	File  string `json:"file"`
+}
+
+type storageListKeysResponse struct {
+	Keys []struct {
+		KeyName string `json:"keyName"`
+		Value   string `json:"value"`
+	} `json:"keys"`
+}
+
+// storageAccountClient makes data-plane requests against a storage account.
+// An account SAS generated from the account keys is used where the keys can be listed,
+// otherwise requests fall back to an AAD token for the storage audience
+type storageAccountClient struct {
+	httpClient  *http.Client
+	armClient   *armclient.Client
+	accountID   string
+	accountName string
+	endpoints   storageEndpoints
+
+	lock      sync.Mutex
+	sasToken  string
+	sasExpiry time.Time
+	useAAD    bool
+	keysErr   error // the reason the account keys couldn't be used
+}
+
+func newStorageAccountClient(ctx context.Context, httpClient *http.Client, armClient *armclient.Client, accountID string) (*storageAccountClient, error) {
+	data, err := armClient.DoRequest(ctx, "GET", accountID+"?api-version=2019-06-01")
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get storage account: " + err.Error() + accountID)
+	}
+
+	var response storageAccountResponse
+	err = json.Unmarshal([]byte(data), &response)
+	if err != nil {
+		return nil, fmt.Errorf("Error unmarshalling response: %s\nURL:%s", err, accountID)
+	}
+
+	return &storageAccountClient{
+		httpClient:  httpClient,
+		armClient:   armClient,
+		accountID:   accountID,
+		accountName: accountID[strings.LastIndex(accountID, "/")+1:],
+		endpoints:   response.Properties.PrimaryEndpoints,
+	}, nil
+}
+
+// authorize adds either the account SAS or the AAD bearer token to the request
+func (c *storageAccountClient) authorize(ctx context.Context, req *http.Request) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if !c.useAAD && time.Now().After(c.sasExpiry) {
+		sasToken, expiry, err := c.createAccountSAS(ctx)
+		if err != nil {
+			// Unable to list keys (e.g. no permission or shared key access disabled) so use AAD instead
+			c.useAAD = true
+			c.keysErr = err
+		} else {
+			c.sasToken = sasToken
+			c.sasExpiry = expiry
+		}
+	}
+
+	if c.useAAD {
+		// The Files REST API only accepts bearer tokens for some operations (not including listing shares)
+		// and only with the backup intent header, so fail with a clear error rather than an opaque 403
+		if c.endpoints.File != "" && strings.HasPrefix(req.URL.String(), c.endpoints.File) {
+			return fmt.Errorf("File shares can only be browsed with account key access: %s", c.keysErr)
+		}
+		token, err := c.armClient.GetTokenForResource(ctx, storageResource)
+		if err != nil {
+			return fmt.Errorf("Failed to get token for storage: %s", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token.AccessToken)
+		return nil
+	}
+
+	if req.URL.RawQuery == "" {
+		req.URL.RawQuery = c.sasToken
+	} else {
+		req.URL.RawQuery += "&" + c.sasToken
+	}
+	return nil
+}
+
+// createAccountSAS creates a short lived account SAS covering all services using the account key
+func (c *storageAccountClient) createAccountSAS(ctx context.Context) (string, time.Time, error) {
+	data, err := c.armClient.DoRequest(ctx, "POST", c.accountID+"/listKeys?api-version=2019-06-01")
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("Failed to list keys: %s", err)
+	}
+	var response storageListKeysResponse
+	err = json.Unmarshal([]byte(data), &response)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("Error unmarshalling keys: %s", err)
+	}
+	if len(response.Keys) == 0 {
+		return "", time.Time{}, fmt.Errorf("No keys returned for storage account")
+	}
+
+	now := time.Now().UTC()
+	expiry := now.Add(storageSASLifetime)
+	sas, err := generateAccountSAS(c.accountName, response.Keys[0].Value, now.Add(-5*time.Minute), expiry)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	// refresh a little before the token expires
+	return sas, expiry.Add(-5 * time.Minute), nil
+}
+
+// generateAccountSAS builds the query string for an account SAS
+// See https://docs.microsoft.com/rest/api/storageservices/create-account-sas
+func generateAccountSAS(accountName string, accountKey string, start time.Time, expiry time.Time) (string, error) {
+	key, err := base64.StdEncoding.DecodeString(accountKey)
+	if err != nil {
+		return "", fmt.Errorf("Failed to decode account key: %s", err)
+	}
+
+	const services = "bqtf"
+	const resourceTypes = "sco"
+	const protocol = "https"
+	startString := start.UTC().Format("2006-01-02T15:04:05Z")
+	expiryString := expiry.UTC().Format("2006-01-02T15:04:05Z")
+
+	stringToSign := strings.Join([]string{
+		accountName,
+		storageSASPermissions,
+		services,
+		resourceTypes,
+		startString,
+		expiryString,
+		"", // signed IP
+		protocol,
+		storageAPIVersion,
+		"",
+	}, "\n")
+
+	mac := hmac.New(sha256.New, key)
+	_, _ = mac.Write([]byte(stringToSign))
+	signature := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+
+	values := url.Values{}
+	values.Set("sv", storageAPIVersion)
+	values.Set("ss", services)
+	values.Set("srt", resourceTypes)
+	values.Set("sp", storageSASPermissions)
+	values.Set("st", startString)
+	values.Set("se", expiryString)
+	values.Set("spr", protocol)
+	values.Set("sig", signature)
+	return values.Encode(), nil
+}
+
+// doRequest makes a request to the data-plane and returns the response for the caller to close
+func (c *storageAccountClient) doRequest(ctx context.Context, verb string, requestURL string, headers map[string]string) (*http.Response, error) {
+	span, ctx := tracing.StartSpanFromContext(ctx, "doRequest(storage):"+verb+":"+requestURL, tracing.SetTag("url", requestURL))
+	defer span.Finish()
+
+	req, err := http.NewRequest(verb, requestURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create request: %s", err)
+	}
+	req.Header.Set("x-ms-version", storageAPIVersion)
+	req.Header.Set("x-ms-date", time.Now().UTC().Format(http.TimeFormat))
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	err = c.authorize(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := c.httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("Request failed: %s", err)
+	}
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		defer response.Body.Close() //nolint: errcheck
+		buf, _ := ioutil.ReadAll(response.Body)
+		return nil, fmt.Errorf("DoRequest failed %v for '%s': %s", response.StatusCode, requestURL, string(buf))
+	}
+	return response, nil
+}
+
+// getBytes makes a request and reads up to maxBytes of the body (or all of it if maxBytes is 0)
+func (c *storageAccountClient) getBytes(ctx context.Context, verb string, requestURL string, headers map[string]string, maxBytes int64) ([]byte, http.Header, error) {
+	response, err := c.doRequest(ctx, verb, requestURL, headers)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer response.Body.Close() //nolint: errcheck
+
+	var reader io.Reader = response.Body
+	if maxBytes > 0 {
+		reader = io.LimitReader(response.Body, maxBytes)
+	}
+	buf, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Failed to read body: %s", err)
+	}
+	return buf, response.Header, nil
+}
+
+// itemURL returns the URL for a blob or file from the node metadata
+func (c *storageAccountClient) itemURL(itemType string, metadata map[string]string) string {
+	if itemType == storageFileType {
+		return c.endpoints.File + escapeStoragePath(metadata["Share"]+"/"+metadata["Path"])
+	}
+	return c.endpoints.Blob + escapeStoragePath(metadata["Container"]+"/"+metadata["Blob"])
+}
+
+// getXML makes a GET request and unmarshals the XML response into result
+func (c *storageAccountClient) getXML(ctx context.Context, requestURL string, result interface{}) error {
+	buf, _, err := c.getBytes(ctx, "GET", requestURL, nil, 0)
+	if err != nil {
+		return err
+	}
+	err = xml.Unmarshal(buf, result)
+	if err != nil {
+		return fmt.Errorf("Error unmarshalling response: %s\nURL:%s", err, requestURL)
+	}
+	return nil
+}
+
+// storageMetadata captures the arbitrary elements in a Metadata node
+type storageMetadata struct {
+	Items []struct {
+		XMLName xml.Name
+		Value   string `xml:",chardata"`
+	} `xml:",any"`
+}
+
+// MarshalJSON renders the metadata as a simple map
+func (m storageMetadata) MarshalJSON() ([]byte, error) {
+	values := map[string]string{}
+	for _, item := range m.Items {
+		values[item.XMLName.Local] = item.Value
+	}
+	return json.Marshal(values)
+}
+
+type storageContainerList struct {
+	Containers []struct {
+		Name       string `xml:"Name" json:"name"`
+		Properties struct {
+			LastModified string `xml:"Last-Modified" json:"lastModified"`
+			LeaseState   string `xml:"LeaseState" json:"leaseState"`
+			PublicAccess string `xml:"PublicAccess" json:"publicAccess,omitempty"`
+		} `xml:"Properties" json:"properties"`
+		Metadata storageMetadata `xml:"Metadata" json:"metadata"`
+	} `xml:"Containers>Container" json:"containers"`
+	NextMarker string `xml:"NextMarker" json:"nextMarker,omitempty"`
+}
+
+type storageBlobList struct {
+	Prefix string `xml:"Prefix" json:"prefix"`
+	Blobs  []struct {
+		Name       string `xml:"Name" json:"name"`
+		Properties struct {
+			CreationTime  string `xml:"Creation-Time" json:"creationTime"`
+			LastModified  string `xml:"Last-Modified" json:"lastModified"`
+			ContentLength int64  `xml:"Content-Length" json:"contentLength"`
+			ContentType   string `xml:"Content-Type" json:"contentType"`
+			BlobType      string `xml:"BlobType" json:"blobType"`
+			AccessTier    string `xml:"AccessTier" json:"accessTier,omitempty"`
+		} `xml:"Properties" json:"properties"`
+		Metadata storageMetadata `xml:"Metadata" json:"metadata"`
+	} `xml:"Blobs>Blob" json:"blobs"`
+	BlobPrefixes []struct {
+		Name string `xml:"Name" json:"name"`
+	} `xml:"Blobs>BlobPrefix" json:"blobPrefixes"`
+	NextMarker string `xml:"NextMarker" json:"nextMarker,omitempty"`
+}
+
+type storageQueueList struct {
+	Queues []struct {
+		Name     string          `xml:"Name" json:"name"`
+		Metadata storageMetadata `xml:"Metadata" json:"metadata"`
+	} `xml:"Queues>Queue" json:"queues"`
+	NextMarker string `xml:"NextMarker" json:"nextMarker,omitempty"`
+}
+
+type storageQueueMessageList struct {
+	Messages []struct {
+		MessageID      string `xml:"MessageId" json:"messageId"`
+		InsertionTime  string `xml:"InsertionTime" json:"insertionTime"`
+		ExpirationTime string `xml:"ExpirationTime" json:"expirationTime"`
+		DequeueCount   int    `xml:"DequeueCount" json:"dequeueCount"`
+		MessageText    string `xml:"MessageText" json:"messageText"`
+		DecodedText    string `xml:"-" json:"decodedText,omitempty"`
+	} `xml:"QueueMessage" json:"messages"`
+}
+
+type storageShareList struct {
+	Shares []struct {
+		Name       string `xml:"Name" json:"name"`
+		Properties struct {
+			LastModified string `xml:"Last-Modified" json:"lastModified"`
+			Quota        int    `xml:"Quota" json:"quota"`
+		} `xml:"Properties" json:"properties"`
+		Metadata storageMetadata `xml:"Metadata" json:"metadata"`
+	} `xml:"Shares>Share" json:"shares"`
+	NextMarker string `xml:"NextMarker" json:"nextMarker,omitempty"`
+}
+
+type storageFileList struct {
+	DirectoryPath string `xml:"DirectoryPath,attr" json:"directoryPath"`
+	Files         []struct {
+		Name       string `xml:"Name" json:"name"`
+		Properties struct {
+			ContentLength int64 `xml:"Content-Length" json:"contentLength"`
+		} `xml:"Properties" json:"properties"`
+	} `xml:"Entries>File" json:"files"`
+	Directories []struct {
+		Name string `xml:"Name" json:"name"`
+	} `xml:"Entries>Directory" json:"directories"`
+	NextMarker string `xml:"NextMarker" json:"nextMarker,omitempty"`
+}
+
+type storageTableList struct {
+	Value []struct {
+		TableName string `json:"TableName"`
+	} `json:"value"`
+}
@@ -0,0 +1,856 @@
+package expanders
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/lawrencegripper/azbrowse/internal/pkg/eventing"
+	"github.com/lawrencegripper/azbrowse/internal/pkg/style"
+	"github.com/lawrencegripper/azbrowse/pkg/armclient"
+	"github.com/nbio/st"
+	"gopkg.in/h2non/gock.v1"
+)
+
+const (
+	storageAccountTemplateURL = "/subscriptions/{subscriptionId}/resourceGroups/{resourceGroupName}/providers/Microsoft.Storage/storageAccounts/{accountName}"
+	storageNamespace          = "storageDataPlane"
+
+	storageContainersType = "storage.containers"
+	storageContainerType  = "storage.container" // also used for virtual directories within a container
+	storageBlobType       = "storage.blob"
+	storageQueuesType     = "storage.queues"
+	storageQueueType      = "storage.queue"
+	storageTablesType     = "storage.tables"
+	storageTableType      = "storage.table"
+	storageSharesType     = "storage.shares"
+	storageShareType      = "storage.share" // also used for directories within a share
+	storageFileType       = "storage.file"
+
+	storagePreviewBytes    = 64 * 1024
+	storageHexPreviewBytes = 1024
+	storageQueuePeekCount  = 32
+	storageTablePageSize   = 100
+)
+
+// NewStorageDataPlaneExpander creates a new instance of StorageDataPlaneExpander
+func NewStorageDataPlaneExpander(armclient *armclient.Client) *StorageDataPlaneExpander {
+	return &StorageDataPlaneExpander{
+		httpClient: &http.Client{},
+		armClient:  armclient,
+		clients:    map[string]*storageAccountClient{},
+	}
+}
+
+// Check interface
+var _ Expander = &StorageDataPlaneExpander{}
+
+// StorageDataPlaneExpander expands the blobs, queues, tables and files in a storage account
+type StorageDataPlaneExpander struct {
+	ExpanderBase
+	httpClient *http.Client
+	armClient  *armclient.Client
+
+	clientsLock sync.Mutex
+	clients     map[string]*storageAccountClient
+}
+
+func (e *StorageDataPlaneExpander) setClient(c *armclient.Client) {
+	e.armClient = c
+	e.clientsLock.Lock()
+	e.clients = map[string]*storageAccountClient{}
+	e.clientsLock.Unlock()
+}
+
+// Name returns the name of the expander
+func (e *StorageDataPlaneExpander) Name() string {
+	return "StorageDataPlaneExpander"
+}
+
+// DoesExpand checks if this is a storage account
+func (e *StorageDataPlaneExpander) DoesExpand(ctx context.Context, currentItem *TreeNode) (bool, error) {
+	swaggerResourceType := currentItem.SwaggerResourceType
+	if currentItem.ItemType == ResourceType && swaggerResourceType != nil {
+		if swaggerResourceType.Endpoint.TemplateURL == storageAccountTemplateURL {
+			return true, nil
+		}
+	}
+	if currentItem.Namespace == storageNamespace {
+		return true, nil
+	}
+	return false, nil
+}
+
+// Expand returns the data-plane nodes for the storage account
+func (e *StorageDataPlaneExpander) Expand(ctx context.Context, currentItem *TreeNode) ExpanderResult {
+
+	swaggerResourceType := currentItem.SwaggerResourceType
+	if currentItem.Namespace != storageNamespace &&
+		swaggerResourceType != nil &&
+		swaggerResourceType.Endpoint.TemplateURL == storageAccountTemplateURL {
+		newItems := []*TreeNode{}
+		newItems = append(newItems, &TreeNode{
+			Parentid:  currentItem.ID,
+			ID:        currentItem.ID + "/<data>",
+			Namespace: storageNamespace,
+			Name:      "Storage Data",
+			Display:   "Storage Data",
+			ItemType:  SubResourceType,
+			ExpandURL: ExpandURLNotSupported,
+			Metadata: map[string]string{
+				"StorageAccountID":      currentItem.ID,
+				"SuppressSwaggerExpand": "true",
+				"SuppressGenericExpand": "true",
+			},
+		})
+
+		return ExpanderResult{
+			Err:               nil,
+			Response:          ExpanderResponse{Response: ""}, // Swagger expander will supply the response
+			SourceDescription: "StorageDataPlaneExpander request",
+			Nodes:             newItems,
+			IsPrimaryResponse: false,
+		}
+	}
+
+	switch currentItem.ItemType {
+	case SubResourceType:
+		return e.expandAccount(ctx, currentItem)
+	case ActionType:
+		return e.executeAction(ctx, currentItem)
+	case storageContainersType:
+		return e.expandContainers(ctx, currentItem)
+	case storageContainerType:
+		return e.expandContainer(ctx, currentItem)
+	case storageBlobType, storageFileType:
+		return e.expandProperties(ctx, currentItem)
+	case storageQueuesType:
+		return e.expandQueues(ctx, currentItem)
+	case storageQueueType:
+		return e.expandQueue(ctx, currentItem)
+	case storageTablesType:
+		return e.expandTables(ctx, currentItem)
+	case storageTableType:
+		return e.expandTable(ctx, currentItem)
+	case storageSharesType:
+		return e.expandShares(ctx, currentItem)
+	case storageShareType:
+		return e.expandShare(ctx, currentItem)
+	}
+
+	return ExpanderResult{
+		Err:               fmt.Errorf("Error - unhandled Expand"),
+		Response:          ExpanderResponse{Response: "Error!"},
+		SourceDescription: "StorageDataPlaneExpander request",
+	}
+}
+
+// Delete attempts to delete the item. Returns true if deleted, false if not handled, an error if an error occurred attempting to delete
+func (e *StorageDataPlaneExpander) Delete(ctx context.Context, currentItem *TreeNode) (bool, error) {
+	headers := map[string]string{}
+	switch currentItem.ItemType {
+	case storageBlobType:
+		headers["x-ms-delete-snapshots"] = "include"
+	case storageFileType:
+	default:
+		return false, nil
+	}
+
+	client, err := e.getAccountClient(ctx, currentItem.Metadata["StorageAccountID"])
+	if err != nil {
+		return false, err
+	}
+	_, _, err = client.getBytes(ctx, "DELETE", client.itemURL(currentItem.ItemType, currentItem.Metadata), headers, 0)
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// HasActions checks if the item is a blob or file
+func (e *StorageDataPlaneExpander) HasActions(ctx context.Context, currentItem *TreeNode) (bool, error) {
+	return currentItem.Namespace == storageNamespace &&
+		(currentItem.ItemType == storageBlobType || currentItem.ItemType == storageFileType), nil
+}
+
+// ListActions returns the preview and download actions for a blob or file
+func (e *StorageDataPlaneExpander) ListActions(ctx context.Context, currentItem *TreeNode) ListActionsResult {
+	nodes := []*TreeNode{}
+	for _, action := range []struct{ id, display string }{
+		{"preview", "Preview content"},
+		{"download", "Download"},
+	} {
+		metadata := map[string]string{
+			"ActionID":              action.id,
+			"ItemType":              currentItem.ItemType,
+			"SuppressSwaggerExpand": "true",
+			"SuppressGenericExpand": "true",
+		}
+		e.copyLocationMetadata(metadata, currentItem.Metadata)
+		nodes = append(nodes, &TreeNode{
+			Parentid:  currentItem.ID,
+			ID:        currentItem.ID + "/<action:" + action.id + ">",
+			Namespace: storageNamespace,
+			Name:      action.display,
+			Display:   action.display,
+			ItemType:  ActionType,
+			ExpandURL: ExpandURLNotSupported,
+			Metadata:  metadata,
+		})
+	}
+	return ListActionsResult{
+		Nodes:             nodes,
+		SourceDescription: "StorageDataPlaneExpander request",
+	}
+}
+
+func (e *StorageDataPlaneExpander) getAccountClient(ctx context.Context, accountID string) (*storageAccountClient, error) {
+	e.clientsLock.Lock()
+	defer e.clientsLock.Unlock()
+
+	if client, ok := e.clients[accountID]; ok {
+		return client, nil
+	}
+	client, err := newStorageAccountClient(ctx, e.httpClient, e.armClient, accountID)
+	if err != nil {
+		return nil, err
+	}
+	e.clients[accountID] = client
+	return client, nil
+}
+
+func (e *StorageDataPlaneExpander) expandAccount(ctx context.Context, currentItem *TreeNode) ExpanderResult {
+	client, err := e.getAccountClient(ctx, currentItem.Metadata["StorageAccountID"])
+	if err != nil {
+		return e.errorResult(err)
+	}
+
+	newItems := []*TreeNode{}
+	for _, service := range []struct {
+		name, itemType, endpoint string
+	}{
+		{"Blob containers", storageContainersType, client.endpoints.Blob},
+		{"Queues", storageQueuesType, client.endpoints.Queue},
+		{"Tables", storageTablesType, client.endpoints.Table},
+		{"File shares", storageSharesType, client.endpoints.File},
+	} {
+		if service.endpoint == "" {
+			continue // e.g. BlobStorage accounts only have a blob endpoint
+		}
+		newItems = append(newItems, e.newNode(currentItem, service.name, service.name, service.itemType, nil))
+	}
+
+	response, err := json.Marshal(client.endpoints)
+	if err != nil {
+		return e.errorResult(err)
+	}
+
+	return ExpanderResult{
+		Response:          ExpanderResponse{Response: string(response), ResponseType: ResponseJSON},
+		SourceDescription: "StorageDataPlaneExpander request",
+		Nodes:             newItems,
+		IsPrimaryResponse: true,
+	}
+}
+
+func (e *StorageDataPlaneExpander) expandContainers(ctx context.Context, currentItem *TreeNode) ExpanderResult {
+	client, err := e.getAccountClient(ctx, currentItem.Metadata["StorageAccountID"])
+	if err != nil {
+		return e.errorResult(err)
+	}
+
+	var list storageContainerList
+	err = client.getXML(ctx, client.endpoints.Blob+"?comp=list&include=metadata"+markerQuery(currentItem), &list)
+	if err != nil {
+		return e.errorResult(err)
+	}
+
+	newItems := []*TreeNode{}
+	for _, container := range list.Containers {
+		newItems = append(newItems, e.newNode(currentItem, container.Name, container.Name, storageContainerType, map[string]string{
+			"Container": container.Name,
+		}))
+	}
+	if list.NextMarker != "" {
+		newItems = append(newItems, e.newMoreNode(currentItem, list.NextMarker))
+	}
+
+	return e.jsonResult(list, newItems)
+}
+
+func (e *StorageDataPlaneExpander) expandContainer(ctx context.Context, currentItem *TreeNode) ExpanderResult {
+	client, err := e.getAccountClient(ctx, currentItem.Metadata["StorageAccountID"])
+	if err != nil {
+		return e.errorResult(err)
+	}
+
+	container := currentItem.Metadata["Container"]
+	prefix := currentItem.Metadata["Prefix"]
+	requestURL := fmt.Sprintf("%s%s?restype=container&comp=list&delimiter=%%2F&include=metadata&prefix=%s%s",
+		client.endpoints.Blob, url.PathEscape(container), url.QueryEscape(prefix), markerQuery(currentItem))
+
+	var list storageBlobList
+	err = client.getXML(ctx, requestURL, &list)
+	if err != nil {
+		return e.errorResult(err)
+	}
+
+	// Virtual directories first, then blobs
+	newItems := []*TreeNode{}
+	for _, blobPrefix := range list.BlobPrefixes {
+		name := strings.TrimPrefix(blobPrefix.Name, prefix)
+		newItems = append(newItems, e.newNode(currentItem, name, name, storageContainerType, map[string]string{
+			"Container": container,
+			"Prefix":    blobPrefix.Name,
+		}))
+	}
+	for _, blob := range list.Blobs {
+		name := strings.TrimPrefix(blob.Name, prefix)
+		display := name + " " + style.Subtle("("+formatBytes(blob.Properties.ContentLength)+")")
+		newItems = append(newItems, e.newNode(currentItem, name, display, storageBlobType, map[string]string{
+			"Container": container,
+			"Blob":      blob.Name,
+		}))
+	}
+	if list.NextMarker != "" {
+		newItems = append(newItems, e.newMoreNode(currentItem, list.NextMarker))
+	}
+
+	return e.jsonResult(list, newItems)
+}
+
+func (e *StorageDataPlaneExpander) expandQueues(ctx context.Context, currentItem *TreeNode) ExpanderResult {
+	client, err := e.getAccountClient(ctx, currentItem.Metadata["StorageAccountID"])
+	if err != nil {
+		return e.errorResult(err)
+	}
+
+	var list storageQueueList
+	err = client.getXML(ctx, client.endpoints.Queue+"?comp=list&include=metadata"+markerQuery(currentItem), &list)
+	if err != nil {
+		return e.errorResult(err)
+	}
+
+	newItems := []*TreeNode{}
+	for _, queue := range list.Queues {
+		newItems = append(newItems, e.newNode(currentItem, queue.Name, queue.Name, storageQueueType, map[string]string{
+			"Queue": queue.Name,
+		}))
+	}
+	if list.NextMarker != "" {
+		newItems = append(newItems, e.newMoreNode(currentItem, list.NextMarker))
+	}
+
+	return e.jsonResult(list, newItems)
+}
+
+// expandQueue peeks at the messages at the front of the queue without changing their visibility
+func (e *StorageDataPlaneExpander) expandQueue(ctx context.Context, currentItem *TreeNode) ExpanderResult {
+	client, err := e.getAccountClient(ctx, currentItem.Metadata["StorageAccountID"])
+	if err != nil {
+		return e.errorResult(err)
+	}
+
+	requestURL := fmt.Sprintf("%s%s/messages?peekonly=true&numofmessages=%d", client.endpoints.Queue, url.PathEscape(currentItem.Metadata["Queue"]), storageQueuePeekCount)
+	var list storageQueueMessageList
+	err = client.getXML(ctx, requestURL, &list)
+	if err != nil {
+		return e.errorResult(err)
+	}
+
+	// Messages are commonly base64 encoded by the SDKs so show the decoded text where it is readable
+	for i := range list.Messages {
+		decoded, err := base64.StdEncoding.DecodeString(list.Messages[i].MessageText)
+		if err == nil && utf8.Valid(decoded) {
+			list.Messages[i].DecodedText = string(decoded)
+		}
+	}
+
+	return e.jsonResult(list, []*TreeNode{})
+}
+
+func (e *StorageDataPlaneExpander) expandTables(ctx context.Context, currentItem *TreeNode) ExpanderResult {
+	client, err := e.getAccountClient(ctx, currentItem.Metadata["StorageAccountID"])
+	if err != nil {
+		return e.errorResult(err)
+	}
+
+	requestURL := client.endpoints.Table + "Tables"
+	if nextTableName := currentItem.Metadata["NextTableName"]; nextTableName != "" {
+		requestURL += "?NextTableName=" + url.QueryEscape(nextTableName)
+	}
+	buf, headers, err := client.getBytes(ctx, "GET", requestURL, map[string]string{"Accept": "application/json;odata=nometadata"}, 0)
+	if err != nil {
+		return e.errorResult(err)
+	}
+
+	var list storageTableList
+	err = json.Unmarshal(buf, &list)
+	if err != nil {
+		return e.errorResult(fmt.Errorf("Error unmarshalling response: %s\nURL:%s", err, requestURL))
+	}
+
+	newItems := []*TreeNode{}
+	for _, table := range list.Value {
+		newItems = append(newItems, e.newNode(currentItem, table.TableName, table.TableName, storageTableType, map[string]string{
+			"Table": table.TableName,
+		}))
+	}
+	if nextTableName := headers.Get("x-ms-continuation-NextTableName"); nextTableName != "" {
+		newItems = append(newItems, e.newNode(currentItem, "more...", "more...", storageTablesType, map[string]string{
+			"NextTableName": nextTableName,
+		}))
+	}
+
+	return ExpanderResult{
+		Response:          ExpanderResponse{Response: string(buf), ResponseType: ResponseJSON},
+		SourceDescription: "StorageDataPlaneExpander request",
+		Nodes:             newItems,
+		IsPrimaryResponse: true,
+	}
+}
+
+// expandTable shows a page of entities, with a node to load the next page if there are more
+func (e *StorageDataPlaneExpander) expandTable(ctx context.Context, currentItem *TreeNode) ExpanderResult {
+	client, err := e.getAccountClient(ctx, currentItem.Metadata["StorageAccountID"])
+	if err != nil {
+		return e.errorResult(err)
+	}
+
+	table := currentItem.Metadata["Table"]
+	requestURL := fmt.Sprintf("%s%s()?$top=%d", client.endpoints.Table, url.PathEscape(table), storageTablePageSize)
+	if nextPartitionKey := currentItem.Metadata["NextPartitionKey"]; nextPartitionKey != "" {
+		requestURL += "&NextPartitionKey=" + url.QueryEscape(nextPartitionKey)
+	}
+	if nextRowKey := currentItem.Metadata["NextRowKey"]; nextRowKey != "" {
+		requestURL += "&NextRowKey=" + url.QueryEscape(nextRowKey)
+	}
+	buf, headers, err := client.getBytes(ctx, "GET", requestURL, map[string]string{"Accept": "application/json;odata=nometadata"}, 0)
+	if err != nil {
+		return e.errorResult(err)
+	}
+
+	newItems := []*TreeNode{}
+	nextPartitionKey := headers.Get("x-ms-continuation-NextPartitionKey")
+	if nextPartitionKey != "" {
+		newItems = append(newItems, e.newNode(currentItem, "more...", "more...", storageTableType, map[string]string{
+			"Table":            table,
+			"NextPartitionKey": nextPartitionKey,
+			"NextRowKey":       headers.Get("x-ms-continuation-NextRowKey"),
+		}))
+	}
+
+	return ExpanderResult{
+		Response:          ExpanderResponse{Response: string(buf), ResponseType: ResponseJSON},
+		SourceDescription: "StorageDataPlaneExpander request",
+		Nodes:             newItems,
+		IsPrimaryResponse: true,
+	}
+}
+
+func (e *StorageDataPlaneExpander) expandShares(ctx context.Context, currentItem *TreeNode) ExpanderResult {
+	client, err := e.getAccountClient(ctx, currentItem.Metadata["StorageAccountID"])
+	if err != nil {
+		return e.errorResult(err)
+	}
+
+	var list storageShareList
+	err = client.getXML(ctx, client.endpoints.File+"?comp=list&include=metadata"+markerQuery(currentItem), &list)
+	if err != nil {
+		return e.errorResult(err)
+	}
+
+	newItems := []*TreeNode{}
+	for _, share := range list.Shares {
+		newItems = append(newItems, e.newNode(currentItem, share.Name, share.Name, storageShareType, map[string]string{
+			"Share": share.Name,
+		}))
+	}
+	if list.NextMarker != "" {
+		newItems = append(newItems, e.newMoreNode(currentItem, list.NextMarker))
+	}
+
+	return e.jsonResult(list, newItems)
+}
+
+func (e *StorageDataPlaneExpander) expandShare(ctx context.Context, currentItem *TreeNode) ExpanderResult {
+	client, err := e.getAccountClient(ctx, currentItem.Metadata["StorageAccountID"])
+	if err != nil {
+		return e.errorResult(err)
+	}
+
+	share := currentItem.Metadata["Share"]
+	directory := currentItem.Metadata["Path"]
+	requestURL := fmt.Sprintf("%s%s?restype=directory&comp=list%s",
+		client.endpoints.File, escapeStoragePath(share+"/"+directory), markerQuery(currentItem))
+
+	var list storageFileList
+	err = client.getXML(ctx, requestURL, &list)
+	if err != nil {
+		return e.errorResult(err)
+	}
+
+	newItems := []*TreeNode{}
+	for _, dir := range list.Directories {
+		newItems = append(newItems, e.newNode(currentItem, dir.Name+"/", dir.Name+"/", storageShareType, map[string]string{
+			"Share": share,
+			"Path":  directory + dir.Name + "/",
+		}))
+	}
+	for _, file := range list.Files {
+		display := file.Name + " " + style.Subtle("("+formatBytes(file.Properties.ContentLength)+")")
+		newItems = append(newItems, e.newNode(currentItem, file.Name, display, storageFileType, map[string]string{
+			"Share": share,
+			"Path":  directory + file.Name,
+		}))
+	}
+	if list.NextMarker != "" {
+		newItems = append(newItems, e.newMoreNode(currentItem, list.NextMarker))
+	}
+
+	return e.jsonResult(list, newItems)
+}
+
+// expandProperties shows the system properties and user metadata of a blob or file
+func (e *StorageDataPlaneExpander) expandProperties(ctx context.Context, currentItem *TreeNode) ExpanderResult {
+	client, err := e.getAccountClient(ctx, currentItem.Metadata["StorageAccountID"])
+	if err != nil {
+		return e.errorResult(err)
+	}
+
+	itemURL := client.itemURL(currentItem.ItemType, currentItem.Metadata)
+
+	_, headers, err := client.getBytes(ctx, "HEAD", itemURL, nil, 0)
+	if err != nil {
+		return e.errorResult(err)
+	}
+
+	properties := map[string]string{}
+	metadata := map[string]string{}
+	for key := range headers {
+		lowerKey := strings.ToLower(key)
+		switch {
+		case strings.HasPrefix(lowerKey, "x-ms-meta-"):
+			metadata[strings.TrimPrefix(lowerKey, "x-ms-meta-")] = headers.Get(key)
+		case lowerKey == "x-ms-request-id" || lowerKey == "x-ms-version" || lowerKey == "date" || lowerKey == "server":
+			// request specific headers
+		case strings.HasPrefix(lowerKey, "content-") || strings.HasPrefix(lowerKey, "x-ms-") ||
+			lowerKey == "etag" || lowerKey == "last-modified":
+			properties[key] = headers.Get(key)
+		}
+	}
+
+	return e.jsonResult(struct {
+		Name       string            `json:"name"`
+		URL        string            `json:"url"`
+		Properties map[string]string `json:"properties"`
+		Metadata   map[string]string `json:"metadata"`
+	}{
+		Name:       currentItem.Name,
+		URL:        itemURL,
+		Properties: properties,
+		Metadata:   metadata,
+	}, []*TreeNode{})
+}
+
+func (e *StorageDataPlaneExpander) executeAction(ctx context.Context, currentItem *TreeNode) ExpanderResult {
+	client, err := e.getAccountClient(ctx, currentItem.Metadata["StorageAccountID"])
+	if err != nil {
+		return e.errorResult(err)
+	}
+
+	itemURL := client.itemURL(currentItem.Metadata["ItemType"], currentItem.Metadata)
+
+	switch currentItem.Metadata["ActionID"] {
+	case "preview":
+		return e.previewContent(ctx, client, itemURL)
+	case "download":
+		return e.downloadContent(ctx, client, itemURL)
+	}
+	return e.errorResult(fmt.Errorf("Unhandled action: %s", currentItem.Metadata["ActionID"]))
+}
+
+// previewContent shows the start of the content as text, falling back to a hex dump for binary content
+func (e *StorageDataPlaneExpander) previewContent(ctx context.Context, client *storageAccountClient, itemURL string) ExpanderResult {
+	buf, headers, err := client.getBytes(ctx, "GET", itemURL,
+		map[string]string{"x-ms-range": fmt.Sprintf("bytes=0-%d", storagePreviewBytes-1)}, storagePreviewBytes)
+	if err != nil {
+		return e.errorResult(err)
+	}
+
+	response := ExpanderResponse{Response: string(buf), ResponseType: ResponsePlainText}
+	if strings.Contains(headers.Get("Content-Type"), "json") && json.Valid(buf) {
+		response.ResponseType = ResponseJSON
+	} else if !utf8.Valid(buf) {
+		if len(buf) > storageHexPreviewBytes {
+			buf = buf[:storageHexPreviewBytes]
+		}
+		response.Response = fmt.Sprintf("Binary content (%s), showing the first %d bytes\n\n%s",
+			headers.Get("Content-Type"), len(buf), hex.Dump(buf))
+	}
+
+	return ExpanderResult{
+		Response:          response,
+		SourceDescription: "StorageDataPlaneExpander request",
+		IsPrimaryResponse: true,
+	}
+}
+
+// downloadContent saves the content to the user's Downloads folder
+func (e *StorageDataPlaneExpander) downloadContent(ctx context.Context, client *storageAccountClient, itemURL string) ExpanderResult {
+	status, _ := eventing.SendStatusEvent(&eventing.StatusEvent{
+		InProgress: true,
+		IsToast:    true,
+		Message:    "Downloading " + itemURL,
+	})
+
+	path, written, err := e.saveContent(ctx, client, itemURL)
+	if err != nil {
+		status.Failure = true
+		status.Message = "Download failed: " + err.Error()
+		status.Done()
+		return e.errorResult(err)
+	}
+	status.Message = "Downloaded to " + path
+	status.Done()
+
+	return e.jsonResult(struct {
+		URL          string `json:"url"`
+		DownloadedTo string `json:"downloadedTo"`
+		Size         int64  `json:"size"`
+	}{itemURL, path, written}, []*TreeNode{})
+}
+
+func (e *StorageDataPlaneExpander) saveContent(ctx context.Context, client *storageAccountClient, itemURL string) (string, int64, error) {
+	response, err := client.doRequest(ctx, "GET", itemURL, nil)
+	if err != nil {
+		return "", 0, err
+	}
+	defer response.Body.Close() //nolint: errcheck
+
+	itemPath, err := url.PathUnescape(itemURL[strings.LastIndex(itemURL, "/")+1:])
+	if err != nil {
+		return "", 0, err
+	}
+	path := getAvailableFilePath(filepath.Join(getDownloadDir(), itemPath))
+	file, err := os.Create(path)
+	if err != nil {
+		return "", 0, fmt.Errorf("Failed to create file: %s", err)
+	}
+	defer file.Close() //nolint: errcheck
+
+	written, err := io.Copy(file, response.Body)
+	if err != nil {
+		return "", 0, fmt.Errorf("Failed to write file: %s", err)
+	}
+	return path, written, nil
+}
+
+// getDownloadDir returns ~/Downloads if it exists, otherwise the home directory
+func getDownloadDir() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return os.TempDir()
+	}
+	downloads := filepath.Join(home, "Downloads")
+	if info, err := os.Stat(downloads); err == nil && info.IsDir() {
+		return downloads
+	}
+	return home
+}
+
+// getAvailableFilePath adds a numeric suffix to the path to avoid overwriting existing files
+func getAvailableFilePath(path string) string {
+	ext := filepath.Ext(path)
+	base := strings.TrimSuffix(path, ext)
+	candidate := path
+	for i := 1; ; i++ {
+		if _, err := os.Stat(candidate); os.IsNotExist(err) {
+			return candidate
+		}
+		candidate = fmt.Sprintf("%s (%d)%s", base, i, ext)
+	}
+}
+
+// escapeStoragePath escapes each segment of a path, keeping the separators
+func escapeStoragePath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+func markerQuery(currentItem *TreeNode) string {
+	if marker := currentItem.Metadata["Marker"]; marker != "" {
+		return "&marker=" + url.QueryEscape(marker)
+	}
+	return ""
+}
+
+// newNode creates a child node, carrying the account and location metadata from the parent
+func (e *StorageDataPlaneExpander) newNode(currentItem *TreeNode, name string, display string, itemType string, metadata map[string]string) *TreeNode {
+	nodeMetadata := map[string]string{
+		"StorageAccountID":      currentItem.Metadata["StorageAccountID"],
+		"SuppressSwaggerExpand": "true",
+		"SuppressGenericExpand": "true",
+	}
+	for key, value := range metadata {
+		nodeMetadata[key] = value
+	}
+
+	if itemType == storageBlobType || itemType == storageFileType {
+		nodeMetadata["DeleteHandledByExpander"] = "true"
+	}
+
+	node := &TreeNode{
+		Parentid:  currentItem.ID,
+		ID:        currentItem.ID + "/" + name,
+		Namespace: storageNamespace,
+		Name:      name,
+		Display:   display,
+		ItemType:  itemType,
+		ExpandURL: ExpandURLNotSupported,
+		Metadata:  nodeMetadata,
+	}
+	return node
+}
+
+// newMoreNode creates a node to load the next page of the current listing
+func (e *StorageDataPlaneExpander) newMoreNode(currentItem *TreeNode, marker string) *TreeNode {
+	metadata := map[string]string{}
+	e.copyLocationMetadata(metadata, currentItem.Metadata)
+	metadata["Marker"] = marker
+	return e.newNode(currentItem, "more...", "more...", currentItem.ItemType, metadata)
+}
+
+func (e *StorageDataPlaneExpander) copyLocationMetadata(target map[string]string, source map[string]string) {
+	for _, key := range []string{"StorageAccountID", "Container", "Prefix", "Blob", "Share", "Path"} {
+		if value, ok := source[key]; ok {
+			target[key] = value
+		}
+	}
+}
+
+func (e *StorageDataPlaneExpander) jsonResult(response interface{}, nodes []*TreeNode) ExpanderResult {
+	buf, err := json.Marshal(response)
+	if err != nil {
+		return e.errorResult(err)
+	}
+	return ExpanderResult{
+		Response:          ExpanderResponse{Response: string(buf), ResponseType: ResponseJSON},
+		SourceDescription: "StorageDataPlaneExpander request",
+		Nodes:             nodes,
+		IsPrimaryResponse: true,
+	}
+}
+
+func (e *StorageDataPlaneExpander) errorResult(err error) ExpanderResult {
+	return ExpanderResult{
+		Err:               err,
+		SourceDescription: "StorageDataPlaneExpander request",
+	}
+}
+
+func (e *StorageDataPlaneExpander) testCases() (bool, *[]expanderTestCase) {
+	const accountID = "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/stable/providers/Microsoft.Storage/storageAccounts/teststorage"
+	itemToExpand := &TreeNode{
+		ID:        accountID + "/<data>/Blob containers/container1/logs/",
+		Namespace: storageNamespace,
+		ItemType:  storageContainerType,
+		ExpandURL: ExpandURLNotSupported,
+		Metadata: map[string]string{
+			"StorageAccountID": accountID,
+			"Container":        "container1",
+			"Prefix":           "logs/",
+		},
+	}
+
+	gockConfig := func(t *testing.T) {
+		gock.New("https://management.azure.com").
+			Get(accountID).
+			Reply(200).
+			File("./testdata/armsamples/storage/account.json")
+		gock.New("https://management.azure.com").
+			Post(accountID + "/listKeys").
+			Reply(200).
+			File("./testdata/armsamples/storage/listKeys.json")
+		gock.New("https://teststorage.blob.core.windows.net").
+			Get("/container1").
+			MatchParam("restype", "container").
+			MatchParam("comp", "list").
+			MatchParam("prefix", "logs/").
+			MatchParam("sig", ".+").
+			Reply(200).
+			File("./testdata/armsamples/storage/bloblist.xml")
+	}
+
+	// Without access to the keys, file shares can't be listed as the Files REST API doesn't accept AAD tokens for it
+	const noKeysAccountID = "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/stable/providers/Microsoft.Storage/storageAccounts/nokeysstorage"
+	sharesToExpand := &TreeNode{
+		ID:        noKeysAccountID + "/<data>/File shares",
+		Namespace: storageNamespace,
+		ItemType:  storageSharesType,
+		ExpandURL: ExpandURLNotSupported,
+		Metadata: map[string]string{
+			"StorageAccountID": noKeysAccountID,
+		},
+	}
+	noKeysGockConfig := func(t *testing.T) {
+		gock.New("https://management.azure.com").
+			Get(noKeysAccountID).
+			Reply(200).
+			File("./testdata/armsamples/storage/account.json")
+		gock.New("https://management.azure.com").
+			Post(noKeysAccountID + "/listKeys").
+			Reply(403).
+			JSON(`{"error": {"code": "AuthorizationFailed"}}`)
+	}
+
+	return true, &[]expanderTestCase{
+		{
+			name:              "Shares->NoKeys",
+			nodeToExpand:      sharesToExpand,
+			configureGockFunc: &noKeysGockConfig,
+			treeNodeCheckerFunc: func(t *testing.T, r ExpanderResult) {
+				st.Expect(t, r.Err != nil, true)
+				st.Expect(t, strings.Contains(r.Err.Error(), "File shares can only be browsed with account key access"), true)
+			},
+		},
+		{
+			name:              "Container->Blobs",
+			nodeToExpand:      itemToExpand,
+			configureGockFunc: &gockConfig,
+			treeNodeCheckerFunc: func(t *testing.T, r ExpanderResult) {
+				st.Expect(t, r.Err, nil)
+				st.Expect(t, len(r.Nodes), 3)
+
+				// virtual directories are listed before blobs
+				st.Expect(t, r.Nodes[0].Name, "2020/")
+				st.Expect(t, r.Nodes[0].ItemType, storageContainerType)
+				st.Expect(t, r.Nodes[0].Metadata["Prefix"], "logs/2020/")
+
+				st.Expect(t, r.Nodes[1].Name, "app.log")
+				st.Expect(t, r.Nodes[1].ItemType, storageBlobType)
+				st.Expect(t, r.Nodes[1].Metadata["Blob"], "logs/app.log")
+				st.Expect(t, r.Nodes[1].DeleteURL, "")
+				st.Expect(t, DeleteHandledByExpander(r.Nodes[1]), true)
+
+				st.Expect(t, r.Nodes[2].Name, "more...")
+				st.Expect(t, r.Nodes[2].Metadata["Marker"], "2!88!MDAwMDE")
+				st.Expect(t, r.Nodes[2].Metadata["Prefix"], "logs/")
+			},
+		},
+	}
+}
@@ -98,7 +98,7 @@ func (c SwaggerAPISetARMResources) ExpandResource(ctx context.Context, currentIt
 				ID:           subResourceURL,
 				Name:         name,
 				ResourceType: *subResourceType,
-				ExpandURL:    subResourceURL + "?api-version=" + subResourceType.Endpoint.APIVersion,
+				ExpandURL:    applyAPIVersionOverride(subResourceURL + "?api-version=" + subResourceType.Endpoint.APIVersion),
 				DeleteURL:    deleteURL,
 			}
 			subResources = append(subResources, subResource)
@@ -0,0 +1,207 @@
+package expanders
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/lawrencegripper/azbrowse/internal/pkg/config"
+	"github.com/lawrencegripper/azbrowse/internal/pkg/storage"
+	"github.com/lawrencegripper/azbrowse/pkg/armclient"
+	"github.com/lawrencegripper/azbrowse/pkg/swagger"
+)
+
+// specBundleDownloadTimeout limits how long downloading a spec bundle can take. Bundles can be large so this
+// allows for a slow connection, but stops startup hanging indefinitely on an unresponsive server
+const specBundleDownloadTimeout = 5 * time.Minute
+
+// LoadRuntimeSwaggerSpecs loads the swagger specs configured in the user's settings (see config.SwaggerConfig)
+// and merges them with the compiled-in ARM resource types. This allows new resource providers and api-versions
+// to be browsed without a new release. The compiled-in resource types are kept if the specs fail to load
+func LoadRuntimeSwaggerSpecs(client *armclient.Client) error {
+	userConfig, err := config.Load()
+	if err != nil {
+		return err
+	}
+	swaggerConfig := userConfig.Swagger
+	if swaggerConfig.SpecFolder == "" && swaggerConfig.BundleURL == "" {
+		return nil
+	}
+
+	folders := []string{}
+	if swaggerConfig.SpecFolder != "" {
+		folders = append(folders, swaggerConfig.SpecFolder)
+	}
+	if swaggerConfig.BundleURL != "" {
+		fmt.Println("Downloading swagger spec bundle ...")
+		bundleFolder, err := downloadSpecBundle(&http.Client{Timeout: specBundleDownloadTimeout}, swaggerConfig.BundleURL, getSpecBundleRoot())
+		if err != nil {
+			return fmt.Errorf("Failed to download swagger spec bundle: %s", err)
+		}
+		folders = append(folders, bundleFolder)
+	}
+
+	apiSet := NewSwaggerAPISetARMResources(client)
+	for _, folder := range folders {
+		fmt.Printf("Loading swagger specs from %s ...\n", folder)
+		resourceTypes, err := loadSpecFolderResourceTypes(folder)
+		if err != nil {
+			return fmt.Errorf("Failed to load swagger specs from %s: %s", folder, err)
+		}
+		apiSet.resourceTypes = swagger.MergeResourceTypes(apiSet.resourceTypes, resourceTypes)
+	}
+	GetSwaggerResourceExpander().AddAPISet(apiSet)
+	fmt.Println("Loading swagger specs complete")
+	return nil
+}
+
+// loadSpecFolderResourceTypes returns the resource types for the specs in the folder. As processing the specs is slow
+// the result is cached in the db, keyed by a hash of the spec files
+func loadSpecFolderResourceTypes(folder string) ([]swagger.ResourceType, error) {
+	folder, err := filepath.Abs(folder)
+	if err != nil {
+		return []swagger.ResourceType{}, err
+	}
+	hash, err := swagger.HashSpecFolder(folder)
+	if err != nil {
+		return []swagger.ResourceType{}, err
+	}
+
+	var resourceTypes []swagger.ResourceType
+	cached, err := storage.GetSwaggerSpecs(folder, hash)
+	if err == nil && cached != "" {
+		err = json.Unmarshal([]byte(cached), &resourceTypes)
+		if err == nil {
+			return resourceTypes, nil
+		}
+	}
+
+	resourceTypes, err = swagger.LoadResourceTypesFromFolder(folder, swagger.GetARMConfig())
+	if err != nil {
+		return []swagger.ResourceType{}, err
+	}
+	data, err := json.Marshal(resourceTypes)
+	if err != nil {
+		return []swagger.ResourceType{}, err
+	}
+	storage.PutSwaggerSpecs(folder, hash, string(data)) //nolint: errcheck
+	return resourceTypes, nil
+}
+
+// getSpecBundleRoot returns the folder that spec bundles are downloaded to
+func getSpecBundleRoot() string {
+	root := "/root/.azbrowse-specs"
+	user, err := user.Current()
+	if err == nil {
+		root = user.HomeDir + "/.azbrowse-specs"
+	}
+	return root
+}
+
+// downloadSpecBundle downloads and extracts the zip at bundleURL to a folder under root and returns the folder.
+// Bundles are only downloaded once per URL: delete the folder to download the bundle again
+func downloadSpecBundle(httpClient *http.Client, bundleURL string, root string) (string, error) {
+	urlHash := sha256.Sum256([]byte(bundleURL))
+	folder := filepath.Join(root, hex.EncodeToString(urlHash[:])[:16])
+	if _, err := os.Stat(folder); err == nil {
+		return folder, nil
+	}
+
+	err := os.MkdirAll(root, 0700)
+	if err != nil {
+		return "", err
+	}
+	zipFile, err := ioutil.TempFile(root, "bundle-*.zip")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(zipFile.Name()) //nolint: errcheck
+	defer zipFile.Close()           //nolint: errcheck
+
+	response, err := httpClient.Get(bundleURL)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close() //nolint: errcheck
+	if response.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("Response failed with %s (%s)", response.Status, bundleURL)
+	}
+	size, err := io.Copy(zipFile, response.Body)
+	if err != nil {
+		return "", err
+	}
+
+	// Extract to a temp folder and rename so that a failed extract isn't treated as downloaded
+	extractFolder, err := ioutil.TempDir(root, "extract-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(extractFolder) //nolint: errcheck
+	err = extractZip(zipFile, size, extractFolder)
+	if err != nil {
+		return "", err
+	}
+	err = os.Rename(extractFolder, folder)
+	if err != nil {
+		return "", err
+	}
+	return folder, nil
+}
+
+func extractZip(reader io.ReaderAt, size int64, folder string) error {
+	zipReader, err := zip.NewReader(reader, size)
+	if err != nil {
+		return fmt.Errorf("Error reading zip: %s", err)
+	}
+	for _, file := range zipReader.File {
+		path := filepath.Join(folder, file.Name)
+		if path == filepath.Clean(folder) {
+			continue
+		}
+		// guard against paths in the zip escaping the folder (e.g. "../../.bashrc")
+		if !strings.HasPrefix(path, filepath.Clean(folder)+string(os.PathSeparator)) {
+			return fmt.Errorf("Invalid file path in zip: %s", file.Name)
+		}
+		if file.FileInfo().IsDir() {
+			err = os.MkdirAll(path, 0700)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		err = os.MkdirAll(filepath.Dir(path), 0700)
+		if err != nil {
+			return err
+		}
+		err = extractZipFile(file, path)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func extractZipFile(file *zip.File, path string) error {
+	source, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer source.Close() //nolint: errcheck
+	destination, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer destination.Close() //nolint: errcheck
+	_, err = io.Copy(destination, source)
+	return err
+}
@@ -0,0 +1,177 @@
+package expanders
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/lawrencegripper/azbrowse/internal/pkg/storage"
+	"github.com/lawrencegripper/azbrowse/pkg/endpoints"
+	"github.com/lawrencegripper/azbrowse/pkg/swagger"
+	"github.com/nbio/st"
+	"gopkg.in/h2non/gock.v1"
+)
+
+func createTestZip(t *testing.T, files map[string]string) []byte {
+	var buf bytes.Buffer
+	writer := zip.NewWriter(&buf)
+	for name, content := range files {
+		fileWriter, err := writer.Create(name)
+		st.Expect(t, err, nil)
+		_, err = fileWriter.Write([]byte(content))
+		st.Expect(t, err, nil)
+	}
+	st.Expect(t, writer.Close(), nil)
+	return buf.Bytes()
+}
+
+func Test_downloadSpecBundle(t *testing.T) {
+	defer gock.Off()
+	root, err := ioutil.TempDir("", "azbrowse-specs")
+	st.Expect(t, err, nil)
+	defer os.RemoveAll(root) //nolint: errcheck
+
+	gock.New("https://example.com").
+		Get("/specs.zip").
+		Reply(200).
+		Body(bytes.NewReader(createTestZip(t, map[string]string{
+			"specs/test/resource-manager/api-set.json": `{"input_files": ["test.json"]}`,
+			"specs/test/resource-manager/test.json":    `{"swagger": "2.0"}`,
+		})))
+
+	folder, err := downloadSpecBundle(&http.Client{}, "https://example.com/specs.zip", root)
+	st.Expect(t, err, nil)
+	st.Expect(t, filepath.Dir(folder), root)
+	content, err := ioutil.ReadFile(filepath.Join(folder, "specs/test/resource-manager/test.json"))
+	st.Expect(t, err, nil)
+	st.Expect(t, string(content), `{"swagger": "2.0"}`)
+	st.Expect(t, gock.IsDone(), true)
+
+	// the bundle isn't downloaded again
+	folder2, err := downloadSpecBundle(&http.Client{}, "https://example.com/specs.zip", root)
+	st.Expect(t, err, nil)
+	st.Expect(t, folder2, folder)
+
+	// only the extracted bundle is left in the root
+	entries, err := ioutil.ReadDir(root)
+	st.Expect(t, err, nil)
+	st.Expect(t, len(entries), 1)
+}
+
+func Test_downloadSpecBundle_InvalidPath(t *testing.T) {
+	defer gock.Off()
+	root, err := ioutil.TempDir("", "azbrowse-specs")
+	st.Expect(t, err, nil)
+	defer os.RemoveAll(root) //nolint: errcheck
+
+	gock.New("https://example.com").
+		Get("/specs.zip").
+		Reply(200).
+		Body(bytes.NewReader(createTestZip(t, map[string]string{
+			"../escaped.json": `{}`,
+		})))
+
+	_, err = downloadSpecBundle(&http.Client{}, "https://example.com/specs.zip", root)
+	st.Expect(t, err != nil, true)
+	st.Expect(t, strings.Contains(err.Error(), "Invalid file path in zip"), true)
+	_, err = os.Stat(filepath.Join(filepath.Dir(root), "escaped.json"))
+	st.Expect(t, os.IsNotExist(err), true)
+
+	entries, err := ioutil.ReadDir(root)
+	st.Expect(t, err, nil)
+	st.Expect(t, len(entries), 0)
+}
+
+const runtimeTestSpec = `{
+	"swagger": "2.0",
+	"info": { "title": "TestClient", "version": "2030-01-01-preview" },
+	"host": "management.azure.com",
+	"paths": {
+		"/subscriptions/{subscriptionId}/providers/Test.RP/widgets": { "get": {} },
+		"/subscriptions/{subscriptionId}/providers/Test.RP/widgets/{widgetName}": { "get": {}, "delete": {} }
+	}
+}`
+
+func Test_loadSpecFolderResourceTypes_Cache(t *testing.T) {
+	dbFolder, err := ioutil.TempDir("", "azbrowse-db")
+	st.Expect(t, err, nil)
+	defer os.RemoveAll(dbFolder) //nolint: errcheck
+	st.Expect(t, storage.LoadDBFromPath(filepath.Join(dbFolder, "test.db")), nil)
+	defer storage.CloseDB()
+
+	folder, err := ioutil.TempDir("", "azbrowse-specs")
+	st.Expect(t, err, nil)
+	defer os.RemoveAll(folder) //nolint: errcheck
+	st.Expect(t, ioutil.WriteFile(filepath.Join(folder, "test.json"), []byte(runtimeTestSpec), 0600), nil)
+
+	resourceTypes, err := loadSpecFolderResourceTypes(folder)
+	st.Expect(t, err, nil)
+	st.Expect(t, len(resourceTypes), 1)
+	st.Expect(t, resourceTypes[0].Endpoint.TemplateURL, "/subscriptions/{subscriptionId}/providers/Test.RP/widgets")
+
+	// the parsed resource types are cached and round-trip through JSON
+	hash, err := swagger.HashSpecFolder(folder)
+	st.Expect(t, err, nil)
+	cached, err := storage.GetSwaggerSpecs(folder, hash)
+	st.Expect(t, err, nil)
+	var cachedResourceTypes []swagger.ResourceType
+	st.Expect(t, json.Unmarshal([]byte(cached), &cachedResourceTypes), nil)
+	st.Expect(t, cachedResourceTypes, resourceTypes)
+
+	// a cache hit is returned without parsing the specs again
+	st.Expect(t, storage.PutSwaggerSpecs(folder, hash, `[{"Display": "from-cache"}]`), nil)
+	resourceTypes, err = loadSpecFolderResourceTypes(folder)
+	st.Expect(t, err, nil)
+	st.Expect(t, len(resourceTypes), 1)
+	st.Expect(t, resourceTypes[0].Display, "from-cache")
+
+	// changing the specs changes the hash so they are parsed again
+	st.Expect(t, ioutil.WriteFile(filepath.Join(folder, "other.json"), []byte(`{"swagger": "2.0", "info": {"version": "2021-01-01"}, "paths": {"/subscriptions/{subscriptionId}/providers/Other.RP/things": {"get": {}}}}`), 0600), nil)
+	resourceTypes, err = loadSpecFolderResourceTypes(folder)
+	st.Expect(t, err, nil)
+	st.Expect(t, len(resourceTypes), 2)
+	st.Expect(t, resourceTypes[0].Display != "from-cache", true)
+}
+
+func Test_MergeResourceTypes_RuntimeSpecsOverrideARMResources(t *testing.T) {
+	storageAccountTemplateURL := "/subscriptions/{subscriptionId}/resourceGroups/{resourceGroupName}/providers/Microsoft.Storage/storageAccounts/{accountName}"
+	storageAccountURL := "/subscriptions/1/resourceGroups/rg/providers/Microsoft.Storage/storageAccounts/acc"
+
+	compiled := NewSwaggerAPISetARMResources(nil).resourceTypes
+	compiledType := swagger.GetResourceTypeForURL(context.Background(), storageAccountURL, compiled)
+	st.Expect(t, compiledType != nil, true)
+	st.Expect(t, compiledType.Endpoint.TemplateURL, storageAccountTemplateURL)
+
+	runtime := []swagger.ResourceType{
+		{
+			Display:  "storageAccounts",
+			Endpoint: endpoints.MustGetEndpointInfoFromURL("/subscriptions/{subscriptionId}/resourceGroups/{resourceGroupName}/providers/Microsoft.Storage/storageAccounts", "2030-01-01"),
+			SubResources: []swagger.ResourceType{
+				{
+					Display:  "{accountName}",
+					Endpoint: endpoints.MustGetEndpointInfoFromURL(storageAccountTemplateURL, "2030-01-01"),
+				},
+			},
+		},
+	}
+	merged := swagger.MergeResourceTypes(compiled, runtime)
+
+	// the runtime resource type replaces the compiled-in one with the same template URL rather than being added
+	st.Expect(t, len(merged), len(compiled))
+	mergedType := swagger.GetResourceTypeForURL(context.Background(), storageAccountURL, merged)
+	st.Expect(t, mergedType != nil, true)
+	st.Expect(t, mergedType.Endpoint.APIVersion, "2030-01-01")
+	// children from the compiled-in specs are kept
+	st.Expect(t, len(mergedType.Children), len(compiledType.Children))
+
+	// the compiled-in resource types are unchanged
+	compiledType = swagger.GetResourceTypeForURL(context.Background(), storageAccountURL, compiled)
+	st.Expect(t, compiledType.Endpoint.APIVersion != "2030-01-01", true)
+}
@@ -4,9 +4,13 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"testing"
 
 	"github.com/lawrencegripper/azbrowse/pkg/armclient"
+	"github.com/lawrencegripper/azbrowse/pkg/endpoints"
 	"github.com/lawrencegripper/azbrowse/pkg/swagger"
+	"github.com/nbio/st"
+	"gopkg.in/h2non/gock.v1"
 
 	"github.com/lawrencegripper/azbrowse/internal/pkg/tracing"
 )
@@ -27,12 +31,14 @@ type SwaggerAPISet interface {
 
 // SubResource is used to pass sub resource information from SwaggerAPISet to the expander
 type SubResource struct {
-	ID           string
-	Name         string
-	ResourceType swagger.ResourceType
-	ExpandURL    string
-	DeleteURL    string
-	Metadata     map[string]string
+	ID              string
+	Name            string
+	Display         string // Optional, Name is displayed if not set
+	StatusIndicator string // Optional
+	ResourceType    swagger.ResourceType
+	ExpandURL       string
+	DeleteURL       string
+	Metadata        map[string]string
 }
 
 // APISetExpandResponse returns the result of expanding a Resource
@@ -150,20 +156,26 @@ func (e *SwaggerResourceExpander) Expand(ctx context.Context, currentItem *TreeN
 
 		if len(expandResult.SubResources) > 0 {
 			for _, subResource := range expandResult.SubResources {
+				loopSubResource := subResource // capture so each node gets its own ResourceType
 				metadata := map[string]string{
 					"SwaggerAPISetID": apiSet.ID(),
 				}
 				e.copyMetadata(metadata, subResource.Metadata)
+				display := subResource.Display
+				if display == "" {
+					display = subResource.Name
+				}
 				newItems = append(newItems, &TreeNode{
 					Parentid:            currentItem.ID,
 					Namespace:           "swagger",
 					Name:                subResource.Name,
-					Display:             subResource.Name,
+					Display:             display,
 					ID:                  subResource.ID,
 					ExpandURL:           subResource.ExpandURL,
 					ItemType:            SubResourceType,
 					DeleteURL:           subResource.DeleteURL,
-					SwaggerResourceType: &subResource.ResourceType,
+					StatusIndicator:     subResource.StatusIndicator,
+					SwaggerResourceType: &loopSubResource.ResourceType,
 					Metadata:            metadata,
 				})
 			}
@@ -197,6 +209,11 @@ func (e *SwaggerResourceExpander) Expand(ctx context.Context, currentItem *TreeN
 			}
 		}
 
+		if _, isARM := apiSet.(SwaggerAPISetARMResources); isARM {
+			// use the api-version selected by the user in place of the api-version from the spec
+			url = applyAPIVersionOverride(url)
+		}
+
 		display := substituteValues(child.Display, templateValues)
 		deleteURL := ""
 		if child.DeleteEndpoint != nil {
@@ -273,5 +290,53 @@ func (e *SwaggerResourceExpander) setClient(c *armclient.Client) {
 }
 
 func (e *SwaggerResourceExpander) testCases() (bool, *[]expanderTestCase) {
-	return false, nil
+	podsResourceType := swagger.ResourceType{
+		Display:  "pods",
+		Endpoint: endpoints.MustGetEndpointInfoFromURL("/api/v1/namespaces/{namespace}/pods", ""),
+		SubResources: []swagger.ResourceType{
+			{
+				Display:  "{name}",
+				Endpoint: endpoints.MustGetEndpointInfoFromURL("/api/v1/namespaces/{namespace}/pods/{name}", ""),
+			},
+		},
+	}
+	podsGockConfig := func(t *testing.T) {
+		addTestKubernetesAPISet()
+		gock.New(testKubernetesServer).
+			Get("/api/v1/namespaces/default/pods").
+			Reply(200).
+			File("./testdata/armsamples/kubernetes/pods.yaml")
+	}
+
+	return true, &[]expanderTestCase{
+		{
+			name: "Kubernetes->PodSummaries",
+			nodeToExpand: &TreeNode{
+				ID:                  testKubernetesAPISetID + "/api/v1/namespaces/default/pods",
+				Namespace:           "swagger",
+				Name:                "pods",
+				ItemType:            SubResourceType,
+				ExpandURL:           "/api/v1/namespaces/default/pods",
+				SwaggerResourceType: &podsResourceType,
+				Metadata: map[string]string{
+					"SwaggerAPISetID": testKubernetesAPISetID,
+				},
+			},
+			configureGockFunc: &podsGockConfig,
+			treeNodeCheckerFunc: func(t *testing.T, r ExpanderResult) {
+				st.Expect(t, r.Err, nil)
+				st.Expect(t, len(r.Nodes), 3)
+
+				st.Expect(t, r.Nodes[0].StatusIndicator, "")
+				st.Expect(t, strings.Contains(r.Nodes[0].Display, "Status: Running  Ready: 1/1  Restarts: 0"), true)
+				st.Expect(t, strings.Contains(r.Nodes[0].Display, "Node: aks-nodepool1-12345678-0"), true)
+
+				st.Expect(t, r.Nodes[1].StatusIndicator, "⛈")
+				st.Expect(t, strings.Contains(r.Nodes[1].Display, "Status: CrashLoopBackOff  Ready: 0/1  Restarts: 7"), true)
+
+				st.Expect(t, r.Nodes[2].StatusIndicator, "⌛")
+				st.Expect(t, strings.Contains(r.Nodes[2].Display, "Node:"), false)
+			},
+		},
+	}
 }
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"testing"
 
+	"github.com/lawrencegripper/azbrowse/internal/pkg/style"
 	"github.com/lawrencegripper/azbrowse/internal/pkg/tracing"
 	"github.com/lawrencegripper/azbrowse/pkg/armclient"
 	"github.com/nbio/st"
@@ -14,12 +15,14 @@ import (
 const (
 	// TentantItemType a TreeNode item representing a tenant
 	TentantItemType = "tentantItemType"
+	// TenantListItemType a TreeNode item representing the tenants the user can access
+	TenantListItemType = "tenantListItemType"
 )
 
 // Check interface
 var _ Expander = &TenantExpander{}
 
-// TenantExpander expands the subscriptions under a tenant
+// TenantExpander expands the tenants available to the user and the subscriptions under a tenant
 type TenantExpander struct {
 	ExpanderBase
 	client *armclient.Client
@@ -34,17 +37,22 @@ func (e *TenantExpander) Name() string {
 	return "TenantExpander"
 }
 
-// DoesExpand checks if this is an RG
+// DoesExpand checks if this is a tenant or the list of tenants
 func (e *TenantExpander) DoesExpand(ctx context.Context, currentItem *TreeNode) (bool, error) {
-	if currentItem.ItemType == TentantItemType {
+	switch currentItem.ItemType {
+	case TentantItemType, TenantListItemType:
 		return true, nil
 	}
 
 	return false, nil
 }
 
-// Expand returns Resources in the RG
+// Expand returns the tenants or the subscriptions in the tenant
 func (e *TenantExpander) Expand(ctx context.Context, currentItem *TreeNode) ExpanderResult {
+	if currentItem.ItemType == TenantListItemType {
+		return e.expandTenants(ctx, currentItem)
+	}
+
 	span, ctx := tracing.StartSpanFromContext(ctx, "expand:subs")
 	defer span.Finish()
 
@@ -83,9 +91,11 @@ func (e *TenantExpander) Expand(ctx context.Context, currentItem *TreeNode) Expa
 			Display:        sub.DisplayName,
 			Name:           sub.DisplayName,
 			ID:             sub.ID,
+			Parentid:       currentItem.ID,
 			ExpandURL:      sub.ID + "/resourceGroups?api-version=2018-05-01",
 			ItemType:       SubscriptionType,
 			SubscriptionID: sub.SubscriptionID,
+			TenantID:       currentItem.TenantID,
 		})
 	}
 
@@ -110,6 +120,81 @@ func (e *TenantExpander) Expand(ctx context.Context, currentItem *TreeNode) Expa
 	}
 }
 
+func (e *TenantExpander) expandTenants(ctx context.Context, currentItem *TreeNode) ExpanderResult {
+	span, ctx := tracing.StartSpanFromContext(ctx, "expand:tenants")
+	defer span.Finish()
+
+	data, err := e.client.DoRequest(ctx, "GET", "/tenants?api-version=2020-01-01")
+	if err != nil {
+		return ExpanderResult{
+			SourceDescription: e.Name(),
+			Err:               err,
+			Response: ExpanderResponse{
+				Response:     data,
+				ResponseType: ResponsePlainText,
+			},
+			IsPrimaryResponse: true,
+		}
+	}
+
+	var tenantResponse TenantResponse
+	err = json.Unmarshal([]byte(data), &tenantResponse)
+	if err != nil {
+		return ExpanderResult{
+			SourceDescription: e.Name(),
+			Err:               fmt.Errorf("Failed to load tenants: %s", err),
+			Response: ExpanderResponse{
+				Response:     data,
+				ResponseType: ResponsePlainText,
+			},
+			IsPrimaryResponse: true,
+		}
+	}
+
+	newList := []*TreeNode{}
+	for _, tenant := range tenantResponse.Tenants {
+		name := tenant.DisplayName
+		if name == "" {
+			name = tenant.TenantID
+		}
+		display := name
+		if tenant.DefaultDomain != "" {
+			display += "\n   " + style.Subtle(tenant.DefaultDomain)
+		}
+		newList = append(newList, &TreeNode{
+			Display:   display,
+			Name:      name,
+			ID:        tenant.ID,
+			Parentid:  currentItem.ID,
+			ExpandURL: ExpandURLNotSupported,
+			ItemType:  TentantItemType,
+			TenantID:  tenant.TenantID,
+		})
+	}
+
+	return ExpanderResult{
+		SourceDescription: e.Name(),
+		IsPrimaryResponse: true,
+		Nodes:             newList,
+		Response: ExpanderResponse{
+			Response:     data,
+			ResponseType: ResponseJSON,
+		},
+	}
+}
+
+// TenantResponse Tenants REST type
+type TenantResponse struct {
+	Tenants []struct {
+		ID             string   `json:"id"`
+		TenantID       string   `json:"tenantId"`
+		DisplayName    string   `json:"displayName"`
+		DefaultDomain  string   `json:"defaultDomain"`
+		TenantCategory string   `json:"tenantCategory"`
+		Domains        []string `json:"domains"`
+	} `json:"value"`
+}
+
 // SubResponse Subscriptions REST type
 type SubResponse struct {
 	Subs []struct {
@@ -147,6 +232,28 @@ func (e *TenantExpander) testCases() (bool, *[]expanderTestCase) {
 				st.Expect(t, r.Nodes[0].ExpandURL, "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups?api-version=2018-05-01")
 			},
 		},
+		{
+			name: "TenantList->Tenants",
+			nodeToExpand: &TreeNode{
+				ItemType:  TenantListItemType,
+				ID:        "AvailableTenants",
+				ExpandURL: ExpandURLNotSupported,
+			},
+			urlPath:      "tenants",
+			responseFile: "./testdata/armsamples/tenants/response.json",
+			statusCode:   200,
+			treeNodeCheckerFunc: func(t *testing.T, r ExpanderResult) {
+				st.Expect(t, r.Err, nil)
+				st.Expect(t, len(r.Nodes), 2)
+
+				// Validate content
+				st.Expect(t, r.Nodes[0].Name, "1testtenant")
+				st.Expect(t, r.Nodes[0].ItemType, TentantItemType)
+				st.Expect(t, r.Nodes[0].TenantID, "00000000-0000-0000-0000-000000000001")
+				// Tenants without a display name fall back to the tenant ID
+				st.Expect(t, r.Nodes[1].Name, "00000000-0000-0000-0000-000000000002")
+			},
+		},
 		{
 			name: "Tenant->Subs500Response",
 			nodeToExpand: &TreeNode{
@@ -1,7 +1,17 @@
 package expanders
 
 import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+
 	"github.com/lawrencegripper/azbrowse/pkg/armclient"
+	"gopkg.in/h2non/gock.v1"
+)
+
+const (
+	testKubernetesAPISetID = "/subscriptions/1/resourceGroups/test/providers/Microsoft.ContainerService/managedClusters/testcluster/<k8sapi>"
+	testKubernetesServer   = "https://testcluster.hcp.westeurope.azmk8s.io:443"
 )
 
 // DummyTokenFunc is used in the mock armclient
@@ -15,3 +25,24 @@ func DummyTokenFunc() func(clearCache bool) (armclient.AzCLIToken, error) {
 		}, nil
 	}
 }
+
+// addTestKubernetesAPISet registers a Kubernetes API set which uses the default http transport so that requests can be mocked
+func addTestKubernetesAPISet() {
+	GetSwaggerResourceExpander().AddAPISet(NewSwaggerAPISetContainerService(nil, http.Client{}, testKubernetesAPISetID, testKubernetesServer))
+}
+
+// matchPatchBody matches the body of a patch request. gock only matches bodies for a fixed set of
+// content types, which doesn't include the patch types used by the Kubernetes API
+func matchPatchBody(contentType string, body string) gock.MatchFunc {
+	return func(req *http.Request, ereq *gock.Request) (bool, error) {
+		if req.Header.Get("Content-Type") != contentType {
+			return false, nil
+		}
+		data, err := ioutil.ReadAll(req.Body)
+		if err != nil {
+			return false, err
+		}
+		req.Body = ioutil.NopCloser(bytes.NewReader(data))
+		return string(data) == body, nil
+	}
+}
@@ -19,6 +19,9 @@ type Expander interface {
 	Name() string
 	Delete(context context.Context, item *TreeNode) (bool, error)
 
+	HasActions(context context.Context, item *TreeNode) (bool, error)
+	ListActions(context context.Context, item *TreeNode) ListActionsResult
+
 	// Used for testing the expanders
 	testCases() (bool, *[]expanderTestCase)
 	setClient(c *armclient.Client)
@@ -32,6 +35,24 @@ func (e *ExpanderBase) Delete(context context.Context, item *TreeNode) (bool, er
 	return false, nil
 }
 
+// HasActions returns true if the expander can list actions for the item
+func (e *ExpanderBase) HasActions(context context.Context, item *TreeNode) (bool, error) {
+	return false, nil
+}
+
+// ListActions returns the actions for the item. Actions are TreeNodes which
+// perform the action when they are expanded
+func (e *ExpanderBase) ListActions(context context.Context, item *TreeNode) ListActionsResult {
+	return ListActionsResult{}
+}
+
+// ListActionsResult used to wrap the result of listing actions for an item
+type ListActionsResult struct {
+	Nodes             []*TreeNode
+	Err               error
+	SourceDescription string
+}
+
 // ExpanderResponseType is used to indicate the text format of a response
 type ExpanderResponseType string
 
@@ -75,6 +96,7 @@ type TreeNode struct {
 	ArmType             string                // The ARM type of the item eg Microsoft.Storage/StorageAccount
 	Metadata            map[string]string     // Metadata is used to pass arbritray data between `Expander`'s
 	SubscriptionID      string                // The SubId of this item
+	TenantID            string                // The tenant the item belongs to, empty for the default tenant
 	StatusIndicator     string                // Displays the resources status
 	SwaggerResourceType *swagger.ResourceType // caches the swagger ResourceType to avoid repeated lookups
 	Expander            Expander              // The Expander that created the node (set automatically by the list)
@@ -1,9 +1,11 @@
 package expanders
 
 import (
-	"github.com/valyala/fastjson"
-
+	"fmt"
+	"net/url"
 	"strings"
+
+	"github.com/valyala/fastjson"
 )
 
 var fastJSONParser fastjson.Parser
@@ -41,6 +43,15 @@ func DrawStatus(s string) string {
 		return "⛔"
 	case "Succeeded":
 		return "☼"
+	// Virtual machine power states
+	case "PowerState/running":
+		return "☼"
+	case "PowerState/starting":
+		return "⛅"
+	case "PowerState/stopping", "PowerState/deallocating":
+		return "⌛"
+	case "PowerState/stopped", "PowerState/deallocated":
+		return "⛔"
 	}
 	return ""
 }
@@ -48,3 +59,33 @@ func DrawStatus(s string) string {
 func getNamespaceFromARMType(s string) string {
 	return strings.Split(s, "/")[0]
 }
+
+// formatBytes returns a human readable size, e.g. 1.5 KB
+func formatBytes(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(size)/float64(div), "KMGTPE"[exp])
+}
+
+// getNextLinkPath returns the path and query of the nextLink from a paged ARM response so that the next page
+// is requested through the ARM client, whichever cloud's endpoint the nextLink points at
+func getNextLinkPath(nextLink string) (string, error) {
+	if nextLink == "" {
+		return "", nil
+	}
+	nextURL, err := url.Parse(nextLink)
+	if err != nil {
+		return "", fmt.Errorf("Error parsing nextLink '%s': %s", nextLink, err)
+	}
+	if nextURL.RawQuery == "" {
+		return nextURL.EscapedPath(), nil
+	}
+	return nextURL.EscapedPath() + "?" + nextURL.RawQuery, nil
+}
@@ -0,0 +1,27 @@
+package expanders
+
+import (
+	"testing"
+
+	"github.com/nbio/st"
+)
+
+func Test_getNextLinkPath(t *testing.T) {
+	tests := []struct {
+		name     string
+		nextLink string
+		want     string
+	}{
+		{"empty", "", ""},
+		{"public cloud", "https://management.azure.com/subscriptions/1/providers/Microsoft.AlertsManagement/alerts?api-version=2019-05-05-preview&$skiptoken=abc%3D", "/subscriptions/1/providers/Microsoft.AlertsManagement/alerts?api-version=2019-05-05-preview&$skiptoken=abc%3D"},
+		{"other cloud", "https://management.chinacloudapi.cn/subscriptions/1/resources?api-version=2019-05-10", "/subscriptions/1/resources?api-version=2019-05-10"},
+		{"no query", "https://management.usgovcloudapi.net/subscriptions/1/resources", "/subscriptions/1/resources"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := getNextLinkPath(tt.nextLink)
+			st.Expect(t, err, nil)
+			st.Expect(t, got, tt.want)
+		})
+	}
+}
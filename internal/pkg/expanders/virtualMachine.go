@@ -0,0 +1,578 @@
+package expanders
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lawrencegripper/azbrowse/internal/pkg/style"
+	"github.com/lawrencegripper/azbrowse/pkg/armclient"
+	"github.com/nbio/st"
+	"gopkg.in/h2non/gock.v1"
+)
+
+const (
+	virtualMachineNamespace           = "virtualMachine"
+	virtualMachineInstanceViewType    = "virtualMachine.instanceView"
+	virtualMachineBootDiagnosticsType = "virtualMachine.bootDiagnostics"
+
+	// virtualMachineAPIVersion is the first version with the retrieveBootDiagnosticsData action
+	virtualMachineAPIVersion = "2020-06-01"
+
+	// virtualMachineRunCommandPollInterval is used when polling for run command completion if ARM doesn't return a Retry-After header
+	virtualMachineRunCommandPollInterval = time.Second * 5
+)
+
+// virtualMachineIDRegex matches the IDs of virtual machines
+var virtualMachineIDRegex = regexp.MustCompile(`(?i)^/subscriptions/[^/]+/resourceGroups/[^/]+/providers/Microsoft\.Compute/virtualMachines/[^/]+$`)
+
+// virtualMachinePowerActions maps the ARM actions to start and stop a VM to their display names
+var virtualMachinePowerActions = []struct {
+	action  string
+	display string
+}{
+	{"start", "Start"},
+	{"powerOff", "Stop (power off)"},
+	{"deallocate", "Stop (deallocate)"},
+	{"restart", "Restart"},
+}
+
+type virtualMachineStatus struct {
+	Code          string `json:"code"`
+	Level         string `json:"level"`
+	DisplayStatus string `json:"displayStatus"`
+	Message       string `json:"message"`
+	Time          string `json:"time"`
+}
+
+type virtualMachineInstanceView struct {
+	ComputerName string `json:"computerName"`
+	OSName       string `json:"osName"`
+	OSVersion    string `json:"osVersion"`
+	VMAgent      *struct {
+		VMAgentVersion string                 `json:"vmAgentVersion"`
+		Statuses       []virtualMachineStatus `json:"statuses"`
+	} `json:"vmAgent"`
+	Disks []struct {
+		Name     string                 `json:"name"`
+		Statuses []virtualMachineStatus `json:"statuses"`
+	} `json:"disks"`
+	Extensions []struct {
+		Name               string                 `json:"name"`
+		Type               string                 `json:"type"`
+		TypeHandlerVersion string                 `json:"typeHandlerVersion"`
+		Statuses           []virtualMachineStatus `json:"statuses"`
+	} `json:"extensions"`
+	Statuses []virtualMachineStatus `json:"statuses"`
+	// VMHealth is reported for scale set instances using the application health extension
+	VMHealth *struct {
+		Status virtualMachineStatus `json:"status"`
+	} `json:"vmHealth"`
+}
+
+// powerState returns the PowerState status of the VM, e.g. PowerState/running
+func (v virtualMachineInstanceView) powerState() virtualMachineStatus {
+	for _, status := range v.Statuses {
+		if strings.HasPrefix(status.Code, "PowerState/") {
+			return status
+		}
+	}
+	return virtualMachineStatus{Code: "PowerState/unknown", DisplayStatus: "Unknown"}
+}
+
+type virtualMachineBootDiagnostics struct {
+	ConsoleScreenshotBlobURI string `json:"consoleScreenshotBlobUri"`
+	SerialConsoleLogBlobURI  string `json:"serialConsoleLogBlobUri"`
+}
+
+type virtualMachineRunCommandRequest struct {
+	CommandID string   `json:"commandId"`
+	Script    []string `json:"script"`
+}
+
+type virtualMachineRunCommandResult struct {
+	Value []virtualMachineStatus `json:"value"`
+}
+
+// Check interface
+var _ Expander = &VirtualMachineExpander{}
+var _ ConfirmedActionRunner = &VirtualMachineExpander{}
+
+// VirtualMachineExpander shows the power state, instance view and boot diagnostics of virtual machines
+// and provides actions to start and stop them
+type VirtualMachineExpander struct {
+	ExpanderBase
+	client    *http.Client
+	armClient *armclient.Client
+}
+
+// NewVirtualMachineExpander creates a new instance of VirtualMachineExpander
+func NewVirtualMachineExpander(armclient *armclient.Client) *VirtualMachineExpander {
+	return &VirtualMachineExpander{
+		client:    &http.Client{},
+		armClient: armclient,
+	}
+}
+
+func (e *VirtualMachineExpander) setClient(c *armclient.Client) {
+	e.armClient = c
+}
+
+// Name returns the name of the expander
+func (e *VirtualMachineExpander) Name() string {
+	return "VirtualMachineExpander"
+}
+
+// IsVirtualMachine returns true if the node is a virtual machine
+func IsVirtualMachine(node *TreeNode) bool {
+	if node == nil {
+		return false
+	}
+	if node.ItemType != ResourceType && node.ItemType != SubResourceType {
+		return false
+	}
+	return virtualMachineIDRegex.MatchString(node.ID)
+}
+
+// DoesExpand checks if this is a virtual machine or one of the nodes added for it
+func (e *VirtualMachineExpander) DoesExpand(ctx context.Context, currentItem *TreeNode) (bool, error) {
+	if currentItem.Namespace == virtualMachineNamespace {
+		return true, nil
+	}
+	return IsVirtualMachine(currentItem), nil
+}
+
+// Expand adds the instance view and boot diagnostics nodes to a virtual machine and expands them
+func (e *VirtualMachineExpander) Expand(ctx context.Context, currentItem *TreeNode) ExpanderResult {
+	switch currentItem.ItemType {
+	case virtualMachineInstanceViewType:
+		return e.expandInstanceView(ctx, currentItem)
+	case virtualMachineBootDiagnosticsType:
+		return e.expandBootDiagnostics(ctx, currentItem)
+	case ActionType:
+		return ExpanderResult{
+			Response:          RequestActionConfirmation(e, currentItem),
+			SourceDescription: "VirtualMachineExpander action",
+			IsPrimaryResponse: true,
+		}
+	}
+
+	if IsVirtualMachine(currentItem) {
+		return e.expandVirtualMachine(ctx, currentItem)
+	}
+
+	return ExpanderResult{
+		Err:               fmt.Errorf("Error - unhandled Expand"),
+		Response:          ExpanderResponse{Response: "Error!"},
+		SourceDescription: "VirtualMachineExpander request",
+	}
+}
+
+func (e *VirtualMachineExpander) newNode(currentItem *TreeNode, itemType string, name string, display string) *TreeNode {
+	return &TreeNode{
+		Parentid:       currentItem.ID,
+		ID:             currentItem.ID + "/<" + itemType + ">",
+		Namespace:      virtualMachineNamespace,
+		Name:           name,
+		Display:        display,
+		ItemType:       itemType,
+		ExpandURL:      ExpandURLNotSupported,
+		SubscriptionID: currentItem.SubscriptionID,
+		Metadata: map[string]string{
+			"VirtualMachineID":      currentItem.ID,
+			"SuppressSwaggerExpand": "true",
+			"SuppressGenericExpand": "true",
+		},
+	}
+}
+
+// expandVirtualMachine adds nodes for the instance view and boot diagnostics, using the power state as the status
+// indicator of both the virtual machine and the instance view
+func (e *VirtualMachineExpander) expandVirtualMachine(ctx context.Context, currentItem *TreeNode) ExpanderResult {
+	instanceView, err := e.getInstanceView(ctx, currentItem.ID)
+	if err != nil {
+		return ExpanderResult{
+			Err:               err,
+			SourceDescription: "VirtualMachineExpander request",
+		}
+	}
+	powerState := instanceView.powerState()
+
+	instanceViewNode := e.newNode(currentItem, virtualMachineInstanceViewType, "Instance view", "Instance view\n  "+style.Subtle("Power state: "+powerState.DisplayStatus))
+	instanceViewNode.StatusIndicator = DrawStatus(powerState.Code)
+	// Update the virtual machine as the power state is more up-to-date (and useful) than the one from the resource group
+	currentItem.StatusIndicator = DrawStatus(powerState.Code)
+
+	return ExpanderResult{
+		Nodes: []*TreeNode{
+			instanceViewNode,
+			e.newNode(currentItem, virtualMachineBootDiagnosticsType, "Boot diagnostics", "Boot diagnostics"),
+		},
+		SourceDescription: "VirtualMachineExpander request",
+	}
+}
+
+func (e *VirtualMachineExpander) expandInstanceView(ctx context.Context, currentItem *TreeNode) ExpanderResult {
+	instanceView, err := e.getInstanceView(ctx, currentItem.Metadata["VirtualMachineID"])
+	if err != nil {
+		return ExpanderResult{
+			Err:               err,
+			SourceDescription: "VirtualMachineExpander request",
+		}
+	}
+	return ExpanderResult{
+		Response:          ExpanderResponse{Response: renderVirtualMachineInstanceView(instanceView), ResponseType: ResponsePlainText},
+		SourceDescription: "VirtualMachineExpander request",
+		IsPrimaryResponse: true,
+	}
+}
+
+func (e *VirtualMachineExpander) getInstanceView(ctx context.Context, vmID string) (virtualMachineInstanceView, error) {
+	var instanceView virtualMachineInstanceView
+	data, err := e.armClient.DoRequest(ctx, "GET", vmID+"/instanceView?api-version="+virtualMachineAPIVersion)
+	if err != nil {
+		return instanceView, fmt.Errorf("Failed to get instance view: %s", err)
+	}
+	err = json.Unmarshal([]byte(data), &instanceView)
+	if err != nil {
+		return instanceView, fmt.Errorf("Error unmarshalling instance view: %s", err)
+	}
+	return instanceView, nil
+}
+
+// expandBootDiagnostics shows the serial console log and the link to the screenshot of the VM's console
+func (e *VirtualMachineExpander) expandBootDiagnostics(ctx context.Context, currentItem *TreeNode) ExpanderResult {
+	data, err := e.armClient.DoRequest(ctx, "POST", currentItem.Metadata["VirtualMachineID"]+"/retrieveBootDiagnosticsData?api-version="+virtualMachineAPIVersion)
+	if err != nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Failed to get boot diagnostics, check boot diagnostics are enabled for the VM: %s", err),
+			Response:          ExpanderResponse{Response: data, ResponseType: ResponseJSON},
+			SourceDescription: "VirtualMachineExpander request",
+			IsPrimaryResponse: true,
+		}
+	}
+	var bootDiagnostics virtualMachineBootDiagnostics
+	err = json.Unmarshal([]byte(data), &bootDiagnostics)
+	if err != nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Error unmarshalling boot diagnostics: %s", err),
+			SourceDescription: "VirtualMachineExpander request",
+		}
+	}
+
+	lines := []string{
+		style.Title("Boot diagnostics"),
+		"",
+		"Screenshot: " + bootDiagnostics.ConsoleScreenshotBlobURI,
+		"",
+		style.Title("Serial console log:"),
+	}
+	if bootDiagnostics.SerialConsoleLogBlobURI == "" {
+		lines = append(lines, style.Subtle("  not available"))
+	} else {
+		// The blob URIs include a SAS token so the log is downloaded without ARM authentication
+		serialLog, err := e.getBlob(ctx, bootDiagnostics.SerialConsoleLogBlobURI)
+		if err != nil {
+			return ExpanderResult{
+				Err:               err,
+				SourceDescription: "VirtualMachineExpander request",
+			}
+		}
+		lines = append(lines, strings.Replace(serialLog, "\r\n", "\n", -1))
+	}
+
+	return ExpanderResult{
+		Response:          ExpanderResponse{Response: strings.Join(lines, "\n"), ResponseType: ResponsePlainText},
+		SourceDescription: "VirtualMachineExpander request",
+		IsPrimaryResponse: true,
+	}
+}
+
+func (e *VirtualMachineExpander) getBlob(ctx context.Context, blobURI string) (string, error) {
+	req, err := http.NewRequest("GET", blobURI, nil)
+	if err != nil {
+		return "", fmt.Errorf("Failed to create request: %s", err)
+	}
+	response, err := e.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return "", fmt.Errorf("Request failed: %s", err)
+	}
+	defer response.Body.Close() //nolint: errcheck
+	if response.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("Failed to get serial console log: %s", response.Status)
+	}
+	buf, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return "", fmt.Errorf("Failed to read body: %s", err)
+	}
+	return string(buf), nil
+}
+
+// HasActions checks if the item is a virtual machine
+func (e *VirtualMachineExpander) HasActions(ctx context.Context, currentItem *TreeNode) (bool, error) {
+	return IsVirtualMachine(currentItem), nil
+}
+
+// ListActions returns the actions to start, stop and restart the virtual machine
+func (e *VirtualMachineExpander) ListActions(ctx context.Context, currentItem *TreeNode) ListActionsResult {
+	nodes := []*TreeNode{}
+	for _, powerAction := range virtualMachinePowerActions {
+		node := e.newNode(currentItem, ActionType, powerAction.display+" virtual machine "+currentItem.Name, powerAction.display)
+		node.ID = currentItem.ID + "/<actions>/" + powerAction.action
+		node.Metadata["ActionID"] = powerAction.action
+		nodes = append(nodes, node)
+	}
+	return ListActionsResult{
+		Nodes:             nodes,
+		SourceDescription: "VirtualMachineExpander actions",
+	}
+}
+
+// RunConfirmedAction starts the power action. The action completes asynchronously and its progress
+// is tracked through the ARM async notifications
+func (e *VirtualMachineExpander) RunConfirmedAction(ctx context.Context, action *TreeNode) error {
+	actionID := action.Metadata["ActionID"]
+	for _, powerAction := range virtualMachinePowerActions {
+		if powerAction.action == actionID {
+			_, err := e.armClient.DoRequest(ctx, "POST", action.Metadata["VirtualMachineID"]+"/"+actionID+"?api-version="+virtualMachineAPIVersion)
+			return err
+		}
+	}
+	return fmt.Errorf("Unhandled action: %s", actionID)
+}
+
+// CanRunVirtualMachineCommand returns true if scripts can be run on the node with run command
+func CanRunVirtualMachineCommand(node *TreeNode) bool {
+	return IsVirtualMachine(node) && getVirtualMachineExpander() != nil
+}
+
+// RunVirtualMachineCommand runs the script on the virtual machine for the node and returns the output.
+// Linux VMs run the script with the shell and Windows VMs with PowerShell. This blocks until the command
+// completes, which can take several minutes
+func RunVirtualMachineCommand(ctx context.Context, node *TreeNode, script string) (string, error) {
+	expander := getVirtualMachineExpander()
+	if expander == nil || !IsVirtualMachine(node) {
+		return "", fmt.Errorf("Commands can't be run on %s", node.Name)
+	}
+	return expander.runCommand(WithNodeTenant(ctx, node), node.ID, script)
+}
+
+func getVirtualMachineExpander() *VirtualMachineExpander {
+	for _, h := range getRegisteredExpanders() {
+		if expander, ok := h.(*VirtualMachineExpander); ok {
+			return expander
+		}
+	}
+	return nil
+}
+
+func (e *VirtualMachineExpander) runCommand(ctx context.Context, vmID string, script string) (string, error) {
+	data, err := e.armClient.DoRequest(ctx, "GET", vmID+"?api-version="+virtualMachineAPIVersion)
+	if err != nil {
+		return "", fmt.Errorf("Failed to get virtual machine: %s", err)
+	}
+	var vm struct {
+		Properties struct {
+			StorageProfile struct {
+				OSDisk struct {
+					OSType string `json:"osType"`
+				} `json:"osDisk"`
+			} `json:"storageProfile"`
+		} `json:"properties"`
+	}
+	err = json.Unmarshal([]byte(data), &vm)
+	if err != nil {
+		return "", fmt.Errorf("Error unmarshalling virtual machine: %s", err)
+	}
+	commandID := "RunShellScript"
+	if strings.EqualFold(vm.Properties.StorageProfile.OSDisk.OSType, "Windows") {
+		commandID = "RunPowerShellScript"
+	}
+
+	body, err := json.Marshal(virtualMachineRunCommandRequest{
+		CommandID: commandID,
+		Script:    strings.Split(script, "\n"),
+	})
+	if err != nil {
+		return "", err
+	}
+	// The command runs asynchronously, the operation is polled until the output is returned
+	responseBody, err := doARMRequestAndWait(ctx, e.armClient, "POST", vmID+"/runCommand?api-version="+virtualMachineAPIVersion, body, virtualMachineRunCommandPollInterval)
+	if err != nil {
+		return "", fmt.Errorf("Failed to run command: %s", err)
+	}
+
+	var result virtualMachineRunCommandResult
+	err = json.Unmarshal(responseBody, &result)
+	if err != nil {
+		return "", fmt.Errorf("Error unmarshalling run command result: %s", err)
+	}
+	return renderVirtualMachineRunCommandResult(result), nil
+}
+
+func renderVirtualMachineStatuses(lines []string, indent string, statuses []virtualMachineStatus) []string {
+	for _, status := range statuses {
+		line := indent + status.DisplayStatus
+		if status.Time != "" {
+			line += " " + style.Subtle("("+status.Time+")")
+		}
+		if status.Level == "Error" || status.Level == "Warning" {
+			line = style.Warning(line)
+		}
+		lines = append(lines, line)
+		if status.Message != "" {
+			lines = append(lines, indent+"  "+style.Subtle(status.Message))
+		}
+	}
+	return lines
+}
+
+// renderVirtualMachineInstanceView shows the statuses of the VM, its agent, disks and extensions as text
+func renderVirtualMachineInstanceView(instanceView virtualMachineInstanceView) string {
+	lines := []string{
+		style.Title("Instance view"),
+		"",
+		"Power state:   " + instanceView.powerState().DisplayStatus,
+		"Computer name: " + instanceView.ComputerName,
+		"OS:            " + strings.TrimSpace(instanceView.OSName+" "+instanceView.OSVersion),
+	}
+
+	lines = append(lines, "", style.Title("Statuses:"))
+	lines = renderVirtualMachineStatuses(lines, "  ", instanceView.Statuses)
+
+	lines = append(lines, "", style.Title("VM agent:"))
+	if instanceView.VMAgent == nil {
+		lines = append(lines, style.Subtle("  not reporting, the VM may be stopped"))
+	} else {
+		lines = append(lines, "  Version: "+instanceView.VMAgent.VMAgentVersion)
+		lines = renderVirtualMachineStatuses(lines, "  ", instanceView.VMAgent.Statuses)
+	}
+
+	lines = append(lines, "", style.Title("Disks:"))
+	for _, disk := range instanceView.Disks {
+		lines = append(lines, "  "+disk.Name)
+		lines = renderVirtualMachineStatuses(lines, "    ", disk.Statuses)
+	}
+
+	lines = append(lines, "", style.Title("Extensions:"))
+	if len(instanceView.Extensions) == 0 {
+		lines = append(lines, style.Subtle("  none"))
+	}
+	for _, extension := range instanceView.Extensions {
+		lines = append(lines, "  "+extension.Name+" "+style.Subtle("("+extension.Type+" "+extension.TypeHandlerVersion+")"))
+		lines = renderVirtualMachineStatuses(lines, "    ", extension.Statuses)
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// renderVirtualMachineRunCommandResult shows the output of a run command. Linux VMs return stdout and
+// stderr in a single message and Windows VMs return a status for each
+func renderVirtualMachineRunCommandResult(result virtualMachineRunCommandResult) string {
+	lines := []string{}
+	for _, status := range result.Value {
+		lines = append(lines, style.Title(status.DisplayStatus)+" "+style.Subtle("("+status.Code+")"), status.Message, "")
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (e *VirtualMachineExpander) testCases() (bool, *[]expanderTestCase) {
+	const vmID = "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/stable/providers/Microsoft.Compute/virtualMachines/testvm"
+	const serialLogURI = "https://teststorage.blob.core.windows.net/bootdiagnostics-testvm/testvm.serialconsole.log"
+
+	vmNode := &TreeNode{
+		ID:        vmID,
+		Name:      "testvm",
+		ItemType:  ResourceType,
+		ExpandURL: vmID + "?api-version=2019-12-01",
+	}
+	instanceViewNode := e.newNode(vmNode, virtualMachineInstanceViewType, "Instance view", "Instance view")
+	bootDiagnosticsNode := e.newNode(vmNode, virtualMachineBootDiagnosticsType, "Boot diagnostics", "Boot diagnostics")
+	deallocateAction := e.ListActions(context.Background(), vmNode).Nodes[2]
+
+	instanceViewGockConfig := func(t *testing.T) {
+		gock.New("https://management.azure.com").
+			Get(vmID+"/instanceView").
+			MatchParam("api-version", virtualMachineAPIVersion).
+			Reply(200).
+			File("./testdata/armsamples/virtualmachines/instanceView.json")
+	}
+	bootDiagnosticsGockConfig := func(t *testing.T) {
+		gock.New("https://management.azure.com").
+			Post(vmID + "/retrieveBootDiagnosticsData").
+			Reply(200).
+			JSON(map[string]string{
+				"consoleScreenshotBlobUri": "https://teststorage.blob.core.windows.net/bootdiagnostics-testvm/testvm.screenshot.bmp?sv=sas",
+				"serialConsoleLogBlobUri":  serialLogURI + "?sv=sas",
+			})
+		gock.New(serialLogURI).
+			MatchParam("sv", "sas").
+			Reply(200).
+			BodyString("[    0.000000] Linux version 5.4.0-1031-azure\r\nUbuntu 18.04.5 LTS testvm ttyS0\r\n")
+	}
+	deallocateGockConfig := func(t *testing.T) {
+		gock.New("https://management.azure.com").
+			Post(vmID+"/deallocate").
+			MatchParam("api-version", virtualMachineAPIVersion).
+			Reply(202).
+			SetHeader("Azure-AsyncOperation", "https://management.azure.com/subscriptions/00000000-0000-0000-0000-000000000000/providers/Microsoft.Compute/locations/westeurope/operations/1")
+	}
+
+	return true, &[]expanderTestCase{
+		{
+			name:              "VirtualMachine->PowerState",
+			nodeToExpand:      vmNode,
+			configureGockFunc: &instanceViewGockConfig,
+			treeNodeCheckerFunc: func(t *testing.T, r ExpanderResult) {
+				st.Expect(t, r.Err, nil)
+				st.Expect(t, len(r.Nodes), 2)
+				st.Expect(t, r.Nodes[0].ItemType, virtualMachineInstanceViewType)
+				st.Expect(t, r.Nodes[0].StatusIndicator, DrawStatus("PowerState/running"))
+				st.Expect(t, vmNode.StatusIndicator, DrawStatus("PowerState/running"))
+				st.Expect(t, strings.Contains(r.Nodes[0].Display, "VM running"), true)
+				st.Expect(t, r.Nodes[1].ItemType, virtualMachineBootDiagnosticsType)
+			},
+		},
+		{
+			name:              "VirtualMachine->InstanceView",
+			nodeToExpand:      instanceViewNode,
+			configureGockFunc: &instanceViewGockConfig,
+			treeNodeCheckerFunc: func(t *testing.T, r ExpanderResult) {
+				st.Expect(t, r.Err, nil)
+				st.Expect(t, r.IsPrimaryResponse, true)
+				st.Expect(t, strings.Contains(r.Response.Response, "Computer name: testvm"), true)
+				st.Expect(t, strings.Contains(r.Response.Response, "Version: 2.2.52"), true)
+				st.Expect(t, strings.Contains(r.Response.Response, "testvm_OsDisk_1"), true)
+				st.Expect(t, strings.Contains(r.Response.Response, "OmsAgentForLinux"), true)
+			},
+		},
+		{
+			name:              "VirtualMachine->BootDiagnostics",
+			nodeToExpand:      bootDiagnosticsNode,
+			configureGockFunc: &bootDiagnosticsGockConfig,
+			treeNodeCheckerFunc: func(t *testing.T, r ExpanderResult) {
+				st.Expect(t, r.Err, nil)
+				st.Expect(t, strings.Contains(r.Response.Response, "Screenshot: https://teststorage.blob.core.windows.net/bootdiagnostics-testvm/testvm.screenshot.bmp?sv=sas"), true)
+				st.Expect(t, strings.Contains(r.Response.Response, "Linux version 5.4.0-1031-azure\nUbuntu 18.04.5 LTS"), true)
+			},
+		},
+		{
+			name:              "VirtualMachine->DeallocateConfirmed",
+			nodeToExpand:      deallocateAction,
+			configureGockFunc: &deallocateGockConfig,
+			treeNodeCheckerFunc: func(t *testing.T, r ExpanderResult) {
+				st.Expect(t, r.Err, nil)
+				st.Expect(t, strings.Contains(r.Response.Response, "Stop (deallocate) virtual machine testvm"), true)
+
+				err := RunConfirmedAction(context.Background(), deallocateAction)
+				st.Expect(t, err, nil)
+			},
+		},
+	}
+}
@@ -0,0 +1,584 @@
+package expanders
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/lawrencegripper/azbrowse/internal/pkg/style"
+	"github.com/lawrencegripper/azbrowse/pkg/armclient"
+	"github.com/nbio/st"
+	"gopkg.in/h2non/gock.v1"
+)
+
+const (
+	virtualMachineScaleSetNamespace    = "virtualMachineScaleSet"
+	virtualMachineScaleSetInstanceType = "virtualMachineScaleSet.instance"
+
+	// virtualMachineScaleSetNetworkAPIVersion is the Microsoft.Network version with the scale set network interface APIs
+	virtualMachineScaleSetNetworkAPIVersion = "2018-10-01"
+	virtualMachineDiskAPIVersion            = "2020-06-30"
+
+	// virtualMachineScaleSetScaleRange is the number of capacities above and below the current capacity offered when scaling
+	virtualMachineScaleSetScaleRange = 10
+)
+
+// virtualMachineScaleSetIDRegex matches the IDs of virtual machine scale sets
+var virtualMachineScaleSetIDRegex = regexp.MustCompile(`(?i)^/subscriptions/[^/]+/resourceGroups/[^/]+/providers/Microsoft\.Compute/virtualMachineScaleSets/[^/]+$`)
+
+// virtualMachineScaleSetInstanceActions maps the scale set actions run against selected instances to their display names
+var virtualMachineScaleSetInstanceActions = []struct {
+	action  string
+	display string
+}{
+	{"manualupgrade", "Upgrade to latest model"},
+	{"reimage", "Reimage"},
+	{"restart", "Restart"},
+}
+
+type virtualMachineScaleSetInstanceListResponse struct {
+	Value    []virtualMachineScaleSetInstance `json:"value"`
+	NextLink string                           `json:"nextLink"`
+}
+
+type virtualMachineScaleSetManagedDisk struct {
+	Name        string `json:"name"`
+	ManagedDisk *struct {
+		ID string `json:"id"`
+	} `json:"managedDisk"`
+}
+
+type virtualMachineScaleSetInstance struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	InstanceID string `json:"instanceId"`
+	Properties struct {
+		LatestModelApplied bool                        `json:"latestModelApplied"`
+		InstanceView       *virtualMachineInstanceView `json:"instanceView"`
+		StorageProfile     struct {
+			OSDisk    virtualMachineScaleSetManagedDisk   `json:"osDisk"`
+			DataDisks []virtualMachineScaleSetManagedDisk `json:"dataDisks"`
+		} `json:"storageProfile"`
+	} `json:"properties"`
+}
+
+type virtualMachineScaleSetNetworkInterfaceListResponse struct {
+	Value []struct {
+		ID         string `json:"id"`
+		Name       string `json:"name"`
+		Properties struct {
+			IPConfigurations []struct {
+				Properties struct {
+					PrivateIPAddress string `json:"privateIPAddress"`
+				} `json:"properties"`
+			} `json:"ipConfigurations"`
+		} `json:"properties"`
+	} `json:"value"`
+}
+
+// health returns the application health of a scale set instance, or an empty string if it isn't reported
+func (v virtualMachineInstanceView) health() string {
+	if v.VMHealth == nil {
+		return ""
+	}
+	return strings.TrimPrefix(v.VMHealth.Status.Code, "HealthState/")
+}
+
+// Check interface
+var _ Expander = &VirtualMachineScaleSetExpander{}
+var _ ConfirmedActionRunner = &VirtualMachineScaleSetExpander{}
+
+// VirtualMachineScaleSetExpander lists the instances of a virtual machine scale set with their state
+// and provides actions to scale the scale set and upgrade, reimage or restart instances
+type VirtualMachineScaleSetExpander struct {
+	ExpanderBase
+	client *armclient.Client
+}
+
+func (e *VirtualMachineScaleSetExpander) setClient(c *armclient.Client) {
+	e.client = c
+}
+
+// Name returns the name of the expander
+func (e *VirtualMachineScaleSetExpander) Name() string {
+	return "VirtualMachineScaleSetExpander"
+}
+
+func isVirtualMachineScaleSet(node *TreeNode) bool {
+	if node.ItemType != ResourceType && node.ItemType != SubResourceType {
+		return false
+	}
+	return virtualMachineScaleSetIDRegex.MatchString(node.ID)
+}
+
+// DoesExpand checks if this is a scale set or one of its instances
+func (e *VirtualMachineScaleSetExpander) DoesExpand(ctx context.Context, currentItem *TreeNode) (bool, error) {
+	if currentItem.Namespace == virtualMachineScaleSetNamespace {
+		return true, nil
+	}
+	return isVirtualMachineScaleSet(currentItem), nil
+}
+
+// Expand lists the instances of a scale set and the network interfaces and disks of an instance
+func (e *VirtualMachineScaleSetExpander) Expand(ctx context.Context, currentItem *TreeNode) ExpanderResult {
+	switch currentItem.ItemType {
+	case virtualMachineScaleSetInstanceType:
+		return e.expandInstance(ctx, currentItem)
+	case ActionType:
+		if currentItem.Metadata["ActionID"] == "scale" {
+			return e.expandScaleAction(ctx, currentItem)
+		}
+		return ExpanderResult{
+			Response:          RequestActionConfirmation(e, currentItem),
+			SourceDescription: "VirtualMachineScaleSetExpander action",
+			IsPrimaryResponse: true,
+		}
+	}
+
+	if isVirtualMachineScaleSet(currentItem) {
+		return e.expandInstances(ctx, currentItem)
+	}
+
+	return ExpanderResult{
+		Err:               fmt.Errorf("Error - unhandled Expand"),
+		Response:          ExpanderResponse{Response: "Error!"},
+		SourceDescription: "VirtualMachineScaleSetExpander request",
+	}
+}
+
+func (e *VirtualMachineScaleSetExpander) listInstances(ctx context.Context, scaleSetID string) ([]virtualMachineScaleSetInstance, error) {
+	instances := []virtualMachineScaleSetInstance{}
+	nextURL := scaleSetID + "/virtualMachines?$expand=instanceView&api-version=" + virtualMachineAPIVersion
+	for nextURL != "" {
+		data, err := e.client.DoRequest(ctx, "GET", nextURL)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to list instances: %s", err)
+		}
+		var response virtualMachineScaleSetInstanceListResponse
+		err = json.Unmarshal([]byte(data), &response)
+		if err != nil {
+			return nil, fmt.Errorf("Error unmarshalling instances: %s", err)
+		}
+		instances = append(instances, response.Value...)
+		nextURL, err = getNextLinkPath(response.NextLink)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return instances, nil
+}
+
+// expandInstances lists the instances with their power state, health and whether they run the latest scale set model
+func (e *VirtualMachineScaleSetExpander) expandInstances(ctx context.Context, currentItem *TreeNode) ExpanderResult {
+	instances, err := e.listInstances(ctx, currentItem.ID)
+	if err != nil {
+		return ExpanderResult{
+			Err:               err,
+			SourceDescription: "VirtualMachineScaleSetExpander request",
+		}
+	}
+
+	newItems := []*TreeNode{}
+	for _, instance := range instances {
+		powerState := virtualMachineStatus{DisplayStatus: "Unknown"}
+		health := ""
+		if instance.Properties.InstanceView != nil {
+			powerState = instance.Properties.InstanceView.powerState()
+			health = instance.Properties.InstanceView.health()
+		}
+		status := "Power: " + powerState.DisplayStatus
+		if health != "" {
+			status += "  Health: " + health
+		}
+		latestModel := style.Subtle("Latest model: yes")
+		if !instance.Properties.LatestModelApplied {
+			latestModel = style.Warning("Latest model: no")
+		}
+
+		newItems = append(newItems, &TreeNode{
+			Parentid:        currentItem.ID,
+			ID:              instance.ID,
+			Namespace:       virtualMachineScaleSetNamespace,
+			Name:            instance.Name,
+			Display:         instance.Name + "\n  " + style.Subtle(status) + "\n  " + latestModel,
+			ItemType:        virtualMachineScaleSetInstanceType,
+			ExpandURL:       instance.ID + "?api-version=" + virtualMachineAPIVersion,
+			SubscriptionID:  currentItem.SubscriptionID,
+			StatusIndicator: DrawStatus(powerState.Code),
+			Metadata: map[string]string{
+				"ScaleSetID":         currentItem.ID,
+				"InstanceID":         instance.InstanceID,
+				"LatestModelApplied": strconv.FormatBool(instance.Properties.LatestModelApplied),
+			},
+		})
+	}
+
+	return ExpanderResult{
+		Nodes:             newItems,
+		SourceDescription: "VirtualMachineScaleSetExpander request",
+	}
+}
+
+// expandInstance lists the network interfaces and disks of an instance. The instance itself is shown by the SwaggerResourceExpander
+func (e *VirtualMachineScaleSetExpander) expandInstance(ctx context.Context, currentItem *TreeNode) ExpanderResult {
+	data, err := e.client.DoRequest(ctx, "GET", currentItem.ID+"?api-version="+virtualMachineAPIVersion)
+	if err != nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Failed to get instance: %s", err),
+			SourceDescription: "VirtualMachineScaleSetExpander request",
+		}
+	}
+	var instance virtualMachineScaleSetInstance
+	err = json.Unmarshal([]byte(data), &instance)
+	if err != nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Error unmarshalling instance: %s", err),
+			SourceDescription: "VirtualMachineScaleSetExpander request",
+		}
+	}
+
+	data, err = e.client.DoRequest(ctx, "GET", currentItem.ID+"/networkInterfaces?api-version="+virtualMachineScaleSetNetworkAPIVersion)
+	if err != nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Failed to list network interfaces: %s", err),
+			SourceDescription: "VirtualMachineScaleSetExpander request",
+		}
+	}
+	var networkInterfaces virtualMachineScaleSetNetworkInterfaceListResponse
+	err = json.Unmarshal([]byte(data), &networkInterfaces)
+	if err != nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Error unmarshalling network interfaces: %s", err),
+			SourceDescription: "VirtualMachineScaleSetExpander request",
+		}
+	}
+
+	newItems := []*TreeNode{}
+	for _, nic := range networkInterfaces.Value {
+		ipAddresses := []string{}
+		for _, ipConfiguration := range nic.Properties.IPConfigurations {
+			ipAddresses = append(ipAddresses, ipConfiguration.Properties.PrivateIPAddress)
+		}
+		newItems = append(newItems, &TreeNode{
+			Parentid:       currentItem.ID,
+			ID:             nic.ID,
+			Namespace:      "Microsoft.Network",
+			ArmType:        "Microsoft.Network/networkInterfaces",
+			Name:           nic.Name,
+			Display:        style.Subtle("[Microsoft.Network/networkInterfaces] \n  ") + nic.Name + "\n  " + style.Subtle("IP: "+strings.Join(ipAddresses, ", ")),
+			ItemType:       ResourceType,
+			ExpandURL:      nic.ID + "?api-version=" + virtualMachineScaleSetNetworkAPIVersion,
+			SubscriptionID: currentItem.SubscriptionID,
+		})
+	}
+
+	disks := append([]virtualMachineScaleSetManagedDisk{instance.Properties.StorageProfile.OSDisk}, instance.Properties.StorageProfile.DataDisks...)
+	for _, disk := range disks {
+		// Unmanaged disks are VHD blobs in a storage account rather than ARM resources
+		if disk.ManagedDisk == nil || disk.ManagedDisk.ID == "" {
+			continue
+		}
+		newItems = append(newItems, &TreeNode{
+			Parentid:       currentItem.ID,
+			ID:             disk.ManagedDisk.ID,
+			Namespace:      "Microsoft.Compute",
+			ArmType:        "Microsoft.Compute/disks",
+			Name:           disk.Name,
+			Display:        style.Subtle("[Microsoft.Compute/disks] \n  ") + disk.Name,
+			ItemType:       ResourceType,
+			ExpandURL:      disk.ManagedDisk.ID + "?api-version=" + virtualMachineDiskAPIVersion,
+			SubscriptionID: currentItem.SubscriptionID,
+		})
+	}
+
+	return ExpanderResult{
+		Nodes:             newItems,
+		SourceDescription: "VirtualMachineScaleSetExpander request",
+	}
+}
+
+func (e *VirtualMachineScaleSetExpander) newAction(currentItem *TreeNode, scaleSetID string, actionID string, display string, description string) *TreeNode {
+	return &TreeNode{
+		Parentid:       currentItem.ID,
+		ID:             currentItem.ID + "/<actions>/" + actionID,
+		Namespace:      virtualMachineScaleSetNamespace,
+		Name:           description,
+		Display:        display,
+		ItemType:       ActionType,
+		ExpandURL:      ExpandURLNotSupported,
+		SubscriptionID: currentItem.SubscriptionID,
+		Metadata: map[string]string{
+			"ActionID":              actionID,
+			"ScaleSetID":            scaleSetID,
+			"ScaleSetName":          scaleSetID[strings.LastIndex(scaleSetID, "/")+1:],
+			"InstanceID":            currentItem.Metadata["InstanceID"],
+			"SuppressSwaggerExpand": "true",
+			"SuppressGenericExpand": "true",
+		},
+	}
+}
+
+// HasActions checks if the item is a scale set or one of its instances
+func (e *VirtualMachineScaleSetExpander) HasActions(ctx context.Context, currentItem *TreeNode) (bool, error) {
+	return currentItem.ItemType == virtualMachineScaleSetInstanceType || isVirtualMachineScaleSet(currentItem), nil
+}
+
+// ListActions returns the scale and upgrade actions for a scale set and the upgrade, reimage and restart actions for an instance.
+// Actions for several instances can be queued in the notifications panel to be run together
+func (e *VirtualMachineScaleSetExpander) ListActions(ctx context.Context, currentItem *TreeNode) ListActionsResult {
+	nodes := []*TreeNode{}
+	if currentItem.ItemType == virtualMachineScaleSetInstanceType {
+		for _, instanceAction := range virtualMachineScaleSetInstanceActions {
+			if instanceAction.action == "manualupgrade" && currentItem.Metadata["LatestModelApplied"] == "true" {
+				continue
+			}
+			nodes = append(nodes, e.newAction(currentItem, currentItem.Metadata["ScaleSetID"], instanceAction.action,
+				instanceAction.display, instanceAction.display+" instance "+currentItem.Name))
+		}
+	} else {
+		nodes = append(nodes,
+			e.newAction(currentItem, currentItem.ID, "scale", "Scale", "Scale "+currentItem.Name),
+			e.newAction(currentItem, currentItem.ID, "upgradeAll", "Upgrade all instances to latest model", "Upgrade all instances of "+currentItem.Name+" to the latest model"))
+	}
+	return ListActionsResult{
+		Nodes:             nodes,
+		SourceDescription: "VirtualMachineScaleSetExpander actions",
+	}
+}
+
+// expandScaleAction lists the capacities around the current capacity of the scale set
+func (e *VirtualMachineScaleSetExpander) expandScaleAction(ctx context.Context, currentItem *TreeNode) ExpanderResult {
+	scaleSetID := currentItem.Metadata["ScaleSetID"]
+	data, err := e.client.DoRequest(ctx, "GET", scaleSetID+"?api-version="+virtualMachineAPIVersion)
+	if err != nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Failed to get scale set: %s", err),
+			SourceDescription: "VirtualMachineScaleSetExpander action",
+		}
+	}
+	var scaleSet struct {
+		Sku struct {
+			Capacity int `json:"capacity"`
+		} `json:"sku"`
+	}
+	err = json.Unmarshal([]byte(data), &scaleSet)
+	if err != nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Error unmarshalling scale set: %s", err),
+			SourceDescription: "VirtualMachineScaleSetExpander action",
+		}
+	}
+
+	current := scaleSet.Sku.Capacity
+	first := current - virtualMachineScaleSetScaleRange
+	if first < 0 {
+		first = 0
+	}
+	scaleSetName := currentItem.Metadata["ScaleSetName"]
+	nodes := []*TreeNode{}
+	for capacity := first; capacity <= current+virtualMachineScaleSetScaleRange; capacity++ {
+		display := fmt.Sprintf("%d instances", capacity)
+		if capacity == current {
+			display += " (current)"
+		}
+		nodes = append(nodes, e.newAction(currentItem, scaleSetID, fmt.Sprintf("scale:%d", capacity), display,
+			fmt.Sprintf("Scale %s to %d instances", scaleSetName, capacity)))
+	}
+	return ExpanderResult{
+		Response:          ExpanderResponse{Response: fmt.Sprintf("Select the number of instances for %s (currently %d)", scaleSetName, current), ResponseType: ResponsePlainText},
+		Nodes:             nodes,
+		SourceDescription: "VirtualMachineScaleSetExpander action",
+		IsPrimaryResponse: true,
+	}
+}
+
+// RunConfirmedAction runs a scale set action once it has been confirmed in the notifications panel. The actions
+// complete asynchronously and their progress is tracked through the ARM async notifications
+func (e *VirtualMachineScaleSetExpander) RunConfirmedAction(ctx context.Context, action *TreeNode) error {
+	scaleSetID := action.Metadata["ScaleSetID"]
+	parts := strings.SplitN(action.Metadata["ActionID"], ":", 2)
+	switch parts[0] {
+	case "scale":
+		if len(parts) != 2 {
+			return fmt.Errorf("Number of instances not specified")
+		}
+		capacity, err := strconv.Atoi(parts[1])
+		if err != nil {
+			return fmt.Errorf("Invalid number of instances: %s", parts[1])
+		}
+		body, err := json.Marshal(map[string]interface{}{
+			"sku": map[string]int{"capacity": capacity},
+		})
+		if err != nil {
+			return err
+		}
+		_, err = e.client.DoRequestWithBody(ctx, "PATCH", scaleSetID+"?api-version="+virtualMachineAPIVersion, string(body))
+		return err
+	case "upgradeAll":
+		instances, err := e.listInstances(ctx, scaleSetID)
+		if err != nil {
+			return err
+		}
+		instanceIDs := []string{}
+		for _, instance := range instances {
+			if !instance.Properties.LatestModelApplied {
+				instanceIDs = append(instanceIDs, instance.InstanceID)
+			}
+		}
+		if len(instanceIDs) == 0 {
+			return fmt.Errorf("All instances of %s are running the latest model", action.Metadata["ScaleSetName"])
+		}
+		return e.runInstanceAction(ctx, scaleSetID, "manualupgrade", instanceIDs)
+	case "manualupgrade", "reimage", "restart":
+		return e.runInstanceAction(ctx, scaleSetID, parts[0], []string{action.Metadata["InstanceID"]})
+	}
+	return fmt.Errorf("Unhandled action: %s", action.Metadata["ActionID"])
+}
+
+func (e *VirtualMachineScaleSetExpander) runInstanceAction(ctx context.Context, scaleSetID string, action string, instanceIDs []string) error {
+	body, err := json.Marshal(map[string][]string{"instanceIds": instanceIDs})
+	if err != nil {
+		return err
+	}
+	_, err = e.client.DoRequestWithBody(ctx, "POST", scaleSetID+"/"+action+"?api-version="+virtualMachineAPIVersion, string(body))
+	return err
+}
+
+func (e *VirtualMachineScaleSetExpander) testCases() (bool, *[]expanderTestCase) {
+	const scaleSetID = "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/stable/providers/Microsoft.Compute/virtualMachineScaleSets/testvmss"
+
+	scaleSetNode := &TreeNode{
+		ID:        scaleSetID,
+		Name:      "testvmss",
+		ItemType:  ResourceType,
+		ExpandURL: scaleSetID + "?api-version=2019-12-01",
+	}
+	instanceNode := &TreeNode{
+		ID:        scaleSetID + "/virtualMachines/1",
+		Name:      "testvmss_1",
+		Namespace: virtualMachineScaleSetNamespace,
+		ItemType:  virtualMachineScaleSetInstanceType,
+		Metadata: map[string]string{
+			"ScaleSetID":         scaleSetID,
+			"InstanceID":         "1",
+			"LatestModelApplied": "false",
+		},
+	}
+	scaleSetActions := e.ListActions(context.Background(), scaleSetNode).Nodes
+	scaleAction := scaleSetActions[0]
+	upgradeAllAction := scaleSetActions[1]
+	instanceActions := e.ListActions(context.Background(), instanceNode).Nodes
+	reimageAction := instanceActions[1]
+
+	instancesGockConfig := func(t *testing.T) {
+		gock.New("https://management.azure.com").
+			Get(scaleSetID+"/virtualMachines").
+			MatchParam("$expand", "instanceView").
+			Reply(200).
+			File("./testdata/armsamples/virtualmachinescalesets/instances.json")
+	}
+	instanceGockConfig := func(t *testing.T) {
+		gock.New("https://management.azure.com").
+			Get(scaleSetID + "/virtualMachines/1").
+			Reply(200).
+			File("./testdata/armsamples/virtualmachinescalesets/instance.json")
+		gock.New("https://management.azure.com").
+			Get(scaleSetID+"/virtualMachines/1/networkInterfaces").
+			MatchParam("api-version", virtualMachineScaleSetNetworkAPIVersion).
+			Reply(200).
+			File("./testdata/armsamples/virtualmachinescalesets/networkInterfaces.json")
+	}
+	scaleGockConfig := func(t *testing.T) {
+		gock.New("https://management.azure.com").
+			Get(scaleSetID).
+			Reply(200).
+			JSON(map[string]interface{}{"name": "testvmss", "sku": map[string]interface{}{"name": "Standard_B2s", "capacity": 2}})
+	}
+	upgradeAllGockConfig := func(t *testing.T) {
+		instancesGockConfig(t)
+		gock.New("https://management.azure.com").
+			Post(scaleSetID + "/manualupgrade").
+			JSON(map[string][]string{"instanceIds": {"1"}}).
+			Reply(202)
+	}
+	reimageGockConfig := func(t *testing.T) {
+		gock.New("https://management.azure.com").
+			Post(scaleSetID + "/reimage").
+			JSON(map[string][]string{"instanceIds": {"1"}}).
+			Reply(202)
+	}
+
+	return true, &[]expanderTestCase{
+		{
+			name:              "VirtualMachineScaleSet->Instances",
+			nodeToExpand:      scaleSetNode,
+			configureGockFunc: &instancesGockConfig,
+			treeNodeCheckerFunc: func(t *testing.T, r ExpanderResult) {
+				st.Expect(t, r.Err, nil)
+				st.Expect(t, len(r.Nodes), 2)
+
+				st.Expect(t, r.Nodes[0].Name, "testvmss_0")
+				st.Expect(t, r.Nodes[0].StatusIndicator, DrawStatus("PowerState/running"))
+				st.Expect(t, strings.Contains(r.Nodes[0].Display, "Health: healthy"), true)
+				st.Expect(t, r.Nodes[0].Metadata["LatestModelApplied"], "true")
+
+				st.Expect(t, r.Nodes[1].StatusIndicator, DrawStatus("PowerState/deallocated"))
+				st.Expect(t, strings.Contains(r.Nodes[1].Display, "Latest model: no"), true)
+				st.Expect(t, r.Nodes[1].Metadata["InstanceID"], "1")
+			},
+		},
+		{
+			name:              "VirtualMachineScaleSet->InstanceNicsAndDisks",
+			nodeToExpand:      instanceNode,
+			configureGockFunc: &instanceGockConfig,
+			treeNodeCheckerFunc: func(t *testing.T, r ExpanderResult) {
+				st.Expect(t, r.Err, nil)
+				st.Expect(t, len(r.Nodes), 3)
+				st.Expect(t, r.Nodes[0].ArmType, "Microsoft.Network/networkInterfaces")
+				st.Expect(t, strings.Contains(r.Nodes[0].Display, "IP: 10.0.0.5"), true)
+				st.Expect(t, r.Nodes[1].Name, "testvmss_testvmss_1_OsDisk_1")
+				st.Expect(t, r.Nodes[1].ExpandURL, "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/STABLE/providers/Microsoft.Compute/disks/testvmss_testvmss_1_OsDisk_1?api-version="+virtualMachineDiskAPIVersion)
+				st.Expect(t, r.Nodes[2].Name, "testvmss_testvmss_1_disk2")
+			},
+		},
+		{
+			name:              "VirtualMachineScaleSet->ScaleCapacities",
+			nodeToExpand:      scaleAction,
+			configureGockFunc: &scaleGockConfig,
+			treeNodeCheckerFunc: func(t *testing.T, r ExpanderResult) {
+				st.Expect(t, r.Err, nil)
+				st.Expect(t, len(r.Nodes), 13)
+				st.Expect(t, r.Nodes[2].Display, "2 instances (current)")
+				st.Expect(t, r.Nodes[5].Metadata["ActionID"], "scale:5")
+			},
+		},
+		{
+			name:              "VirtualMachineScaleSet->UpgradeAllConfirmed",
+			nodeToExpand:      upgradeAllAction,
+			configureGockFunc: &upgradeAllGockConfig,
+			treeNodeCheckerFunc: func(t *testing.T, r ExpanderResult) {
+				st.Expect(t, r.Err, nil)
+				st.Expect(t, strings.Contains(r.Response.Response, "Upgrade all instances of testvmss to the latest model"), true)
+
+				err := RunConfirmedAction(context.Background(), upgradeAllAction)
+				st.Expect(t, err, nil)
+			},
+		},
+		{
+			name:              "VirtualMachineScaleSet->ReimageConfirmed",
+			nodeToExpand:      reimageAction,
+			configureGockFunc: &reimageGockConfig,
+			treeNodeCheckerFunc: func(t *testing.T, r ExpanderResult) {
+				st.Expect(t, r.Err, nil)
+				st.Expect(t, strings.Contains(r.Response.Response, "Reimage instance testvmss_1"), true)
+
+				err := RunConfirmedAction(context.Background(), reimageAction)
+				st.Expect(t, err, nil)
+			},
+		},
+	}
+}
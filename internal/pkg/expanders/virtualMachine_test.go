@@ -0,0 +1,44 @@
+package expanders
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/lawrencegripper/azbrowse/pkg/armclient"
+	"github.com/nbio/st"
+	"gopkg.in/h2non/gock.v1"
+)
+
+func Test_VirtualMachineExpander_RunCommand(t *testing.T) {
+	const vmID = "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/stable/providers/Microsoft.Compute/virtualMachines/testvm"
+	const pollURI = "https://management.azure.com/subscriptions/00000000-0000-0000-0000-000000000000/providers/Microsoft.Compute/locations/westeurope/operations/1"
+	defer gock.Off()
+	gock.New("https://management.azure.com").
+		Get(vmID).
+		Reply(200).
+		File("./testdata/armsamples/virtualmachines/vm.json")
+	gock.New("https://management.azure.com").
+		Post(vmID+"/runCommand").
+		MatchParam("api-version", virtualMachineAPIVersion).
+		JSON(map[string]interface{}{"commandId": "RunShellScript", "script": []string{"uname -a"}}).
+		Reply(202).
+		SetHeader("Location", pollURI).
+		SetHeader("Retry-After", "0")
+	gock.New(pollURI).
+		Reply(202).
+		SetHeader("Retry-After", "0")
+	gock.New(pollURI).
+		Reply(200).
+		File("./testdata/armsamples/virtualmachines/runCommandResult.json")
+
+	httpClient := &http.Client{Transport: &http.Transport{}}
+	gock.InterceptClient(httpClient)
+	expander := NewVirtualMachineExpander(armclient.NewClientFromConfig(httpClient, DummyTokenFunc(), 5000))
+
+	output, err := expander.runCommand(context.Background(), vmID, "uname -a")
+	st.Expect(t, err, nil)
+	st.Expect(t, strings.Contains(output, "[stdout]\nLinux testvm 5.4.0-1031-azure"), true)
+	st.Expect(t, gock.IsDone(), true)
+}
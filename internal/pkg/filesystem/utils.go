@@ -72,6 +72,14 @@ func createFS(mountpoint string, filterToSub string, editMode bool, demoMode boo
 	armclient.LegacyInstance = armClient
 
 	expanders.InitializeExpanders(armClient)
+	err = expanders.LoadRuntimeSwaggerSpecs(armClient)
+	if err != nil {
+		log.Println(err)
+	}
+	err = expanders.LoadAPIVersionOverrides()
+	if err != nil {
+		log.Println(err)
+	}
 	armClient.PopulateResourceAPILookup(ctx)
 
 	// print status messages
@@ -6,43 +6,62 @@ import "github.com/stuartleeks/gocui"
 type HandlerID string
 
 const (
-	HandlerIDQuit                    HandlerID = "quit"                  //nolint:golint
-	HandlerIDCopy                    HandlerID = "copy"                  //nolint:golint
-	HandlerIDListDelete              HandlerID = "listdelete"            //nolint:golint
-	HandlerIDFullScreen              HandlerID = "fullscreen"            //nolint:golint
-	HandlerIDHelp                    HandlerID = "help"                  //nolint:golint
-	HandlerIDItemBack                HandlerID = "itemback"              //nolint:golint
-	HandlerIDItemLeft                HandlerID = "itemleft"              //nolint:golint
-	HandlerIDListActions             HandlerID = "listactions"           //nolint:golint
-	HandlerIDListBack                HandlerID = "listback"              //nolint:golint
-	HandlerIDListBackLegacy          HandlerID = "listbacklegacy"        //nolint:golint
-	HandlerIDListDown                HandlerID = "listdown"              //nolint:golint
-	HandlerIDListUp                  HandlerID = "listup"                //nolint:golint
-	HandlerIDListRight               HandlerID = "listright"             //nolint:golint
-	HandlerIDListEdit                HandlerID = "listedit"              //nolint:golint
-	HandlerIDListExpand              HandlerID = "listexpand"            //nolint:golint
-	HandlerIDListOpen                HandlerID = "listopen"              //nolint:golint
-	HandlerIDListRefresh             HandlerID = "listrefresh"           //nolint:golint
-	HandlerIDListUpdate              HandlerID = "listupdate"            //nolint:golint
-	HandlerIDListPageDown            HandlerID = "listpagedown"          //nolint:golint
-	HandlerIDListPageUp              HandlerID = "listpageup"            //nolint:golint
-	HandlerIDListEnd                 HandlerID = "listend"               //nolint:golint
-	HandlerIDListHome                HandlerID = "listhome"              //nolint:golint
-	HandlerIDListClearFilter         HandlerID = "listclearfilter"       //nolint:golint
-	HandlerIDListCopyItemID          HandlerID = "listcopyitemid"        //nolint:golint
-	HandlerIDListDebugCopyItemData   HandlerID = "listdebugcopyitemdata" //nolint:golint
-	HandlerIDConfirmDelete           HandlerID = "confirmdelete"         //nolint:golint
-	HandlerIDClearPendingDeletes     HandlerID = "clearpendingdeletes"   //nolint:golint
-	HandlerIDItemPageDown            HandlerID = "itempagedown"          //nolint:golint
-	HandlerIDItemPageUp              HandlerID = "itempageup"            //nolint:golint
-	HandlerIDToggleOpenCommandPanel  HandlerID = "commandpanelopen"      //nolint:golint
-	HandlerIDToggleCloseCommandPanel HandlerID = "commandpanelclose"     //nolint:golint
-	HandlerIDCommandPanelDown        HandlerID = "commandpaneldown"      //nolint:golint
-	HandlerIDCommandPanelUp          HandlerID = "commandpanelup"        //nolint:golint
-	HandlerIDCommandPanelEnter       HandlerID = "commandpanelenter"     //nolint:golint
-	HandlerIDFilter                  HandlerID = "filter"                //nolint:golint
-	HandlerIDAzureSearchQuery        HandlerID = "azuresearchquery"      //nolist:golint
-	HandlerIDToggleDemoMode          HandlerID = "toggledemomode"        //nolist:golint
+	HandlerIDQuit                     HandlerID = "quit"                     //nolint:golint
+	HandlerIDCopy                     HandlerID = "copy"                     //nolint:golint
+	HandlerIDListDelete               HandlerID = "listdelete"               //nolint:golint
+	HandlerIDFullScreen               HandlerID = "fullscreen"               //nolint:golint
+	HandlerIDHelp                     HandlerID = "help"                     //nolint:golint
+	HandlerIDItemBack                 HandlerID = "itemback"                 //nolint:golint
+	HandlerIDItemLeft                 HandlerID = "itemleft"                 //nolint:golint
+	HandlerIDListActions              HandlerID = "listactions"              //nolint:golint
+	HandlerIDListBack                 HandlerID = "listback"                 //nolint:golint
+	HandlerIDListBackLegacy           HandlerID = "listbacklegacy"           //nolint:golint
+	HandlerIDListDown                 HandlerID = "listdown"                 //nolint:golint
+	HandlerIDListUp                   HandlerID = "listup"                   //nolint:golint
+	HandlerIDListRight                HandlerID = "listright"                //nolint:golint
+	HandlerIDListEdit                 HandlerID = "listedit"                 //nolint:golint
+	HandlerIDListExpand               HandlerID = "listexpand"               //nolint:golint
+	HandlerIDListOpen                 HandlerID = "listopen"                 //nolint:golint
+	HandlerIDListRefresh              HandlerID = "listrefresh"              //nolint:golint
+	HandlerIDListUpdate               HandlerID = "listupdate"               //nolint:golint
+	HandlerIDListPageDown             HandlerID = "listpagedown"             //nolint:golint
+	HandlerIDListPageUp               HandlerID = "listpageup"               //nolint:golint
+	HandlerIDListEnd                  HandlerID = "listend"                  //nolint:golint
+	HandlerIDListHome                 HandlerID = "listhome"                 //nolint:golint
+	HandlerIDListClearFilter          HandlerID = "listclearfilter"          //nolint:golint
+	HandlerIDListCopyItemID           HandlerID = "listcopyitemid"           //nolint:golint
+	HandlerIDListDebugCopyItemData    HandlerID = "listdebugcopyitemdata"    //nolint:golint
+	HandlerIDConfirmDelete            HandlerID = "confirmdelete"            //nolint:golint
+	HandlerIDClearPendingDeletes      HandlerID = "clearpendingdeletes"      //nolint:golint
+	HandlerIDItemPageDown             HandlerID = "itempagedown"             //nolint:golint
+	HandlerIDItemPageUp               HandlerID = "itempageup"               //nolint:golint
+	HandlerIDToggleOpenCommandPanel   HandlerID = "commandpanelopen"         //nolint:golint
+	HandlerIDToggleCloseCommandPanel  HandlerID = "commandpanelclose"        //nolint:golint
+	HandlerIDCommandPanelDown         HandlerID = "commandpaneldown"         //nolint:golint
+	HandlerIDCommandPanelUp           HandlerID = "commandpanelup"           //nolint:golint
+	HandlerIDCommandPanelEnter        HandlerID = "commandpanelenter"        //nolint:golint
+	HandlerIDFilter                   HandlerID = "filter"                   //nolint:golint
+	HandlerIDAzureSearchQuery         HandlerID = "azuresearchquery"         //nolist:golint
+	HandlerIDCosmosDBQuery            HandlerID = "cosmosdbquery"            //nolint:golint
+	HandlerIDMetricsQuery             HandlerID = "metricsquery"             //nolint:golint
+	HandlerIDListPinMetric            HandlerID = "listpinmetric"            //nolint:golint
+	HandlerIDListComparePinnedMetrics HandlerID = "listcomparepinnedmetrics" //nolint:golint
+	HandlerIDListClearPinnedMetrics   HandlerID = "listclearpinnedmetrics"   //nolint:golint
+	HandlerIDSaveMetricsDashboard     HandlerID = "savemetricsdashboard"     //nolint:golint
+	HandlerIDOpenMetricsDashboard     HandlerID = "openmetricsdashboard"     //nolint:golint
+	HandlerIDLogQuery                 HandlerID = "logquery"                 //nolint:golint
+	HandlerIDLogQueryOptions          HandlerID = "logqueryoptions"          //nolint:golint
+	HandlerIDLogQueryExport           HandlerID = "logqueryexport"           //nolint:golint
+	HandlerIDFollowLogs               HandlerID = "followlogs"               //nolint:golint
+	HandlerIDToggleFollowLogsPaused   HandlerID = "togglefollowlogspaused"   //nolint:golint
+	HandlerIDFilterFollowedLogs       HandlerID = "filterfollowedlogs"       //nolint:golint
+	HandlerIDPurgeRegistryTags        HandlerID = "purgeregistrytags"        //nolint:golint
+	HandlerIDContainerExec            HandlerID = "containerexec"            //nolint:golint
+	HandlerIDRunVirtualMachineCommand HandlerID = "runvirtualmachinecommand" //nolint:golint
+	HandlerIDSetAppServiceSetting     HandlerID = "setappservicesetting"     //nolint:golint
+	HandlerIDVerifyIPFlow             HandlerID = "verifyipflow"             //nolint:golint
+	HandlerIDSelectAPIVersion         HandlerID = "selectapiversion"         //nolint:golint
+	HandlerIDToggleDemoMode           HandlerID = "toggledemomode"           //nolist:golint
 )
 
 // KeyHandler is an interface that all key handlers must implement
@@ -8,9 +8,13 @@ import (
 	"io/ioutil"
 	"os"
 	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/lawrencegripper/azbrowse/internal/pkg/config"
+	"github.com/lawrencegripper/azbrowse/internal/pkg/errorhandling"
 	"github.com/lawrencegripper/azbrowse/internal/pkg/eventing"
 	"github.com/lawrencegripper/azbrowse/internal/pkg/expanders"
 	"github.com/lawrencegripper/azbrowse/internal/pkg/tracing"
@@ -362,7 +366,11 @@ func (h *ListOpenHandler) Invoke() error {
 	if portalURL == "" {
 		portalURL = "https://portal.azure.com"
 	}
-	url := portalURL + "/#@" + armclient.LegacyInstance.GetTenantID() + "/resource/" + item.ID
+	tenantID := item.TenantID
+	if tenantID == "" {
+		tenantID = armclient.LegacyInstance.GetTenantID()
+	}
+	url := portalURL + "/#@" + tenantID + "/resource/" + item.ID
 	span, _ := tracing.StartSpanFromContext(h.Context, "openportal:url")
 	var err error
 	if wsl.IsWSL() {
@@ -629,7 +637,7 @@ func (h *ListUpdateHandler) Invoke() error {
 	}
 	apiSet := *apiSetPtr
 
-	err = apiSet.Update(h.Context, item, updatedJSON)
+	err = apiSet.Update(expanders.WithNodeTenant(h.Context, item), item, updatedJSON)
 	if err != nil {
 		h.status.Status(fmt.Sprintf("Error updating: %s", err), false)
 		return nil
@@ -735,9 +743,12 @@ func (h *CommandPanelAzureSearchQueryHandler) CommandPanelNotification(state vie
 			return
 		}
 		apiSet := *apiSetPtr
-		searchApiSet := apiSet.(expanders.SwaggerAPISetSearch)
+		searchApiSet, ok := apiSet.(expanders.SwaggerAPISetDataPlane)
+		if !ok {
+			return
+		}
 
-		data, err := searchApiSet.DoRequest("GET", currentItem.ExpandURL+"&"+queryString)
+		data, err := searchApiSet.DoRequestWithBody(context.Background(), "GET", currentItem.ExpandURL+"&"+queryString, "")
 		if err != nil {
 			h.content.SetContent(nil, fmt.Sprintf("%s", err), expanders.ResponseJSON, queryString)
 		} else {
@@ -748,6 +759,1424 @@ func (h *CommandPanelAzureSearchQueryHandler) CommandPanelNotification(state vie
 
 ////////////////////////////////////////////////////////////////////
 
+////////////////////////////////////////////////////////////////////
+type CommandPanelCosmosDBQueryHandler struct {
+	ListHandler
+	commandPanelWidget *views.CommandPanelWidget
+	list               *views.ListWidget
+	content            *views.ItemWidget
+	ctx                context.Context
+}
+
+var _ Command = &CommandPanelCosmosDBQueryHandler{}
+
+func NewCommandPanelCosmosDBQueryHandler(commandPanelWidget *views.CommandPanelWidget, content *views.ItemWidget, list *views.ListWidget, ctx context.Context) *CommandPanelCosmosDBQueryHandler {
+	handler := &CommandPanelCosmosDBQueryHandler{
+		commandPanelWidget: commandPanelWidget,
+		content:            content,
+		list:               list,
+		ctx:                ctx,
+	}
+	handler.id = HandlerIDCosmosDBQuery
+
+	return handler
+}
+
+func (h *CommandPanelCosmosDBQueryHandler) Fn() func(g *gocui.Gui, v *gocui.View) error {
+	return func(g *gocui.Gui, v *gocui.View) error {
+		if h.IsEnabled() {
+			return h.Invoke()
+		}
+		return nil
+	}
+}
+
+func (h *CommandPanelCosmosDBQueryHandler) DisplayText() string {
+	return "Cosmos DB query"
+}
+
+func (h *CommandPanelCosmosDBQueryHandler) IsEnabled() bool {
+	currentItem := h.list.CurrentExpandedItem()
+	if currentItem != nil && currentItem.SwaggerResourceType != nil && currentItem.SwaggerResourceType.Endpoint.TemplateURL == "/dbs/{databaseId}/colls/{containerId}/docs" {
+		return true
+	}
+	return false
+}
+
+func (h *CommandPanelCosmosDBQueryHandler) Invoke() error {
+	h.commandPanelWidget.ShowWithText("SQL query:", "SELECT * FROM c", nil, h.CommandPanelNotification)
+	return nil
+}
+
+func (h *CommandPanelCosmosDBQueryHandler) CommandPanelNotification(state views.CommandPanelNotification) {
+
+	if state.EnterPressed {
+		query := state.CurrentText
+		currentItem := h.list.CurrentExpandedItem()
+
+		// Results are returned as document nodes so that they can be opened, updated and deleted
+		queryNode := expanders.NewCosmosDBQueryNode(currentItem, query)
+		newContent, newItems, err := expanders.ExpandItem(h.ctx, queryNode)
+		if err != nil { // Don't need to display error as expander emits status event on error
+			return
+		}
+		if len(newItems) > 0 {
+			h.list.SetNodes(newItems)
+		}
+		h.content.SetContent(queryNode, newContent.Response, newContent.ResponseType, query)
+	}
+}
+
+////////////////////////////////////////////////////////////////////
+
+////////////////////////////////////////////////////////////////////
+type CommandPanelMetricsQueryHandler struct {
+	ListHandler
+	commandPanelWidget *views.CommandPanelWidget
+	content            *views.ItemWidget
+	status             *views.StatusbarWidget
+	ctx                context.Context
+}
+
+var _ Command = &CommandPanelMetricsQueryHandler{}
+
+func NewCommandPanelMetricsQueryHandler(commandPanelWidget *views.CommandPanelWidget, content *views.ItemWidget, status *views.StatusbarWidget, ctx context.Context) *CommandPanelMetricsQueryHandler {
+	handler := &CommandPanelMetricsQueryHandler{
+		commandPanelWidget: commandPanelWidget,
+		content:            content,
+		status:             status,
+		ctx:                ctx,
+	}
+	handler.id = HandlerIDMetricsQuery
+
+	return handler
+}
+
+func (h *CommandPanelMetricsQueryHandler) Fn() func(g *gocui.Gui, v *gocui.View) error {
+	return func(g *gocui.Gui, v *gocui.View) error {
+		if h.IsEnabled() {
+			return h.Invoke()
+		}
+		return nil
+	}
+}
+
+func (h *CommandPanelMetricsQueryHandler) DisplayText() string {
+	return "Change metric time range, interval or aggregation"
+}
+
+func (h *CommandPanelMetricsQueryHandler) IsEnabled() bool {
+	return expanders.IsMetricsGraph(h.content.GetNode())
+}
+
+func (h *CommandPanelMetricsQueryHandler) Invoke() error {
+	options := []views.CommandPanelListOption{}
+	for _, option := range expanders.GetMetricsQueryOptions(h.content.GetNode()) {
+		options = append(options, views.CommandPanelListOption{
+			ID:          option.ID,
+			DisplayText: option.DisplayText,
+		})
+	}
+	h.commandPanelWidget.ShowWithText("metric options (or type a range e.g. P3D or 2020-01-01T00:00Z/2020-01-01T06:00Z):", "", &options, h.CommandPanelNotification)
+	return nil
+}
+
+func (h *CommandPanelMetricsQueryHandler) CommandPanelNotification(state views.CommandPanelNotification) {
+
+	if state.EnterPressed {
+		h.commandPanelWidget.Hide()
+		node := h.content.GetNode()
+		if !expanders.IsMetricsGraph(node) {
+			return
+		}
+
+		if err := expanders.ApplyMetricsQueryOption(node, state.SelectedID, state.CurrentText); err != nil {
+			h.status.Status(fmt.Sprintf("Failed to update metric options: %s", err), false)
+			return
+		}
+
+		newContent, _, err := expanders.ExpandItem(h.ctx, node)
+		if err != nil { // Don't need to display error as expander emits status event on error
+			return
+		}
+		h.content.SetContent(node, newContent.Response, newContent.ResponseType, node.Name)
+	}
+}
+
+////////////////////////////////////////////////////////////////////
+
+////////////////////////////////////////////////////////////////////
+type ListPinMetricHandler struct {
+	ListHandler
+	content *views.ItemWidget
+	status  *views.StatusbarWidget
+}
+
+var _ Command = &ListPinMetricHandler{}
+
+func NewListPinMetricHandler(content *views.ItemWidget, status *views.StatusbarWidget) *ListPinMetricHandler {
+	handler := &ListPinMetricHandler{
+		content: content,
+		status:  status,
+	}
+	handler.id = HandlerIDListPinMetric
+	return handler
+}
+
+func (h *ListPinMetricHandler) Fn() func(g *gocui.Gui, v *gocui.View) error {
+	return func(g *gocui.Gui, v *gocui.View) error {
+		if h.IsEnabled() {
+			return h.Invoke()
+		}
+		return nil
+	}
+}
+
+func (h *ListPinMetricHandler) DisplayText() string {
+	if h.IsEnabled() && expanders.IsMetricPinned(h.content.GetNode()) {
+		return "Unpin metric"
+	}
+	return fmt.Sprintf("Pin metric for comparison (%d/%d pinned)", len(expanders.GetPinnedMetrics()), expanders.MaxPinnedMetrics)
+}
+
+func (h *ListPinMetricHandler) IsEnabled() bool {
+	return expanders.IsMetricsGraph(h.content.GetNode())
+}
+
+func (h *ListPinMetricHandler) Invoke() error {
+	node := h.content.GetNode()
+	if expanders.IsMetricPinned(node) {
+		expanders.UnpinMetric(node)
+		h.status.Status("Unpinned metric "+node.Name, false)
+		return nil
+	}
+	if err := expanders.PinMetric(node); err != nil {
+		h.status.Status(fmt.Sprintf("Failed to pin metric: %s", err), false)
+		return nil
+	}
+	h.status.Status(fmt.Sprintf("Pinned metric %s (%d/%d pinned)", node.Name, len(expanders.GetPinnedMetrics()), expanders.MaxPinnedMetrics), false)
+	return nil
+}
+
+////////////////////////////////////////////////////////////////////
+
+////////////////////////////////////////////////////////////////////
+type ListComparePinnedMetricsHandler struct {
+	ListHandler
+	content *views.ItemWidget
+	ctx     context.Context
+}
+
+var _ Command = &ListComparePinnedMetricsHandler{}
+
+func NewListComparePinnedMetricsHandler(content *views.ItemWidget, ctx context.Context) *ListComparePinnedMetricsHandler {
+	handler := &ListComparePinnedMetricsHandler{
+		content: content,
+		ctx:     ctx,
+	}
+	handler.id = HandlerIDListComparePinnedMetrics
+	return handler
+}
+
+func (h *ListComparePinnedMetricsHandler) Fn() func(g *gocui.Gui, v *gocui.View) error {
+	return func(g *gocui.Gui, v *gocui.View) error {
+		if h.IsEnabled() {
+			return h.Invoke()
+		}
+		return nil
+	}
+}
+
+func (h *ListComparePinnedMetricsHandler) DisplayText() string {
+	return "Compare pinned metrics"
+}
+
+func (h *ListComparePinnedMetricsHandler) IsEnabled() bool {
+	return len(expanders.GetPinnedMetrics()) >= 2
+}
+
+func (h *ListComparePinnedMetricsHandler) Invoke() error {
+	node := expanders.NewMetricsComparisonNode()
+	newContent, _, err := expanders.ExpandItem(h.ctx, node)
+	if err != nil { // Don't need to display error as expander emits status event on error
+		return nil
+	}
+	h.content.SetContent(node, newContent.Response, newContent.ResponseType, node.Name)
+	return nil
+}
+
+////////////////////////////////////////////////////////////////////
+
+////////////////////////////////////////////////////////////////////
+type ListClearPinnedMetricsHandler struct {
+	ListHandler
+	status *views.StatusbarWidget
+}
+
+var _ Command = &ListClearPinnedMetricsHandler{}
+
+func NewListClearPinnedMetricsHandler(status *views.StatusbarWidget) *ListClearPinnedMetricsHandler {
+	handler := &ListClearPinnedMetricsHandler{
+		status: status,
+	}
+	handler.id = HandlerIDListClearPinnedMetrics
+	return handler
+}
+
+func (h *ListClearPinnedMetricsHandler) Fn() func(g *gocui.Gui, v *gocui.View) error {
+	return func(g *gocui.Gui, v *gocui.View) error {
+		return h.Invoke()
+	}
+}
+
+func (h *ListClearPinnedMetricsHandler) DisplayText() string {
+	return "Clear pinned metrics"
+}
+
+func (h *ListClearPinnedMetricsHandler) IsEnabled() bool {
+	return len(expanders.GetPinnedMetrics()) > 0
+}
+
+func (h *ListClearPinnedMetricsHandler) Invoke() error {
+	expanders.ClearPinnedMetrics()
+	h.status.Status("Cleared pinned metrics", false)
+	return nil
+}
+
+////////////////////////////////////////////////////////////////////
+
+////////////////////////////////////////////////////////////////////
+type CommandPanelSaveMetricsDashboardHandler struct {
+	ListHandler
+	commandPanelWidget *views.CommandPanelWidget
+	status             *views.StatusbarWidget
+}
+
+var _ Command = &CommandPanelSaveMetricsDashboardHandler{}
+
+func NewCommandPanelSaveMetricsDashboardHandler(commandPanelWidget *views.CommandPanelWidget, status *views.StatusbarWidget) *CommandPanelSaveMetricsDashboardHandler {
+	handler := &CommandPanelSaveMetricsDashboardHandler{
+		commandPanelWidget: commandPanelWidget,
+		status:             status,
+	}
+	handler.id = HandlerIDSaveMetricsDashboard
+	return handler
+}
+
+func (h *CommandPanelSaveMetricsDashboardHandler) Fn() func(g *gocui.Gui, v *gocui.View) error {
+	return func(g *gocui.Gui, v *gocui.View) error {
+		if h.IsEnabled() {
+			return h.Invoke()
+		}
+		return nil
+	}
+}
+
+func (h *CommandPanelSaveMetricsDashboardHandler) DisplayText() string {
+	return "Save pinned metrics as dashboard"
+}
+
+func (h *CommandPanelSaveMetricsDashboardHandler) IsEnabled() bool {
+	return len(expanders.GetPinnedMetrics()) > 0
+}
+
+func (h *CommandPanelSaveMetricsDashboardHandler) Invoke() error {
+	options := []views.CommandPanelListOption{}
+	for _, name := range expanders.GetMetricsDashboardNames() {
+		options = append(options, views.CommandPanelListOption{
+			ID:          name,
+			DisplayText: "Replace " + name,
+		})
+	}
+	h.commandPanelWidget.ShowWithText("dashboard name:", "", &options, h.CommandPanelNotification)
+	return nil
+}
+
+func (h *CommandPanelSaveMetricsDashboardHandler) CommandPanelNotification(state views.CommandPanelNotification) {
+	if state.EnterPressed {
+		h.commandPanelWidget.Hide()
+		name := state.CurrentText
+		if state.SelectedID != "" {
+			name = state.SelectedID
+		}
+		if err := expanders.SaveMetricsDashboard(name); err != nil {
+			h.status.Status(fmt.Sprintf("Failed to save dashboard: %s", err), false)
+			return
+		}
+		h.status.Status("Saved dashboard "+name, false)
+	}
+}
+
+////////////////////////////////////////////////////////////////////
+
+////////////////////////////////////////////////////////////////////
+type CommandPanelOpenMetricsDashboardHandler struct {
+	ListHandler
+	commandPanelWidget *views.CommandPanelWidget
+	content            *views.ItemWidget
+	status             *views.StatusbarWidget
+	ctx                context.Context
+}
+
+var _ Command = &CommandPanelOpenMetricsDashboardHandler{}
+
+func NewCommandPanelOpenMetricsDashboardHandler(commandPanelWidget *views.CommandPanelWidget, content *views.ItemWidget, status *views.StatusbarWidget, ctx context.Context) *CommandPanelOpenMetricsDashboardHandler {
+	handler := &CommandPanelOpenMetricsDashboardHandler{
+		commandPanelWidget: commandPanelWidget,
+		content:            content,
+		status:             status,
+		ctx:                ctx,
+	}
+	handler.id = HandlerIDOpenMetricsDashboard
+	return handler
+}
+
+func (h *CommandPanelOpenMetricsDashboardHandler) Fn() func(g *gocui.Gui, v *gocui.View) error {
+	return func(g *gocui.Gui, v *gocui.View) error {
+		if h.IsEnabled() {
+			return h.Invoke()
+		}
+		return nil
+	}
+}
+
+func (h *CommandPanelOpenMetricsDashboardHandler) DisplayText() string {
+	return "Open metrics dashboard"
+}
+
+func (h *CommandPanelOpenMetricsDashboardHandler) IsEnabled() bool {
+	return len(expanders.GetMetricsDashboardNames()) > 0
+}
+
+func (h *CommandPanelOpenMetricsDashboardHandler) Invoke() error {
+	options := []views.CommandPanelListOption{}
+	for _, name := range expanders.GetMetricsDashboardNames() {
+		options = append(options, views.CommandPanelListOption{
+			ID:          name,
+			DisplayText: name,
+		})
+	}
+	h.commandPanelWidget.ShowWithText("dashboard:", "", &options, h.CommandPanelNotification)
+	return nil
+}
+
+func (h *CommandPanelOpenMetricsDashboardHandler) CommandPanelNotification(state views.CommandPanelNotification) {
+	if state.EnterPressed {
+		h.commandPanelWidget.Hide()
+		name := state.SelectedID
+		if name == "" {
+			name = state.CurrentText
+		}
+		if err := views.ShowMetricsDashboard(h.ctx, h.content, name); err != nil {
+			h.status.Status(fmt.Sprintf("Failed to open dashboard: %s", err), false)
+		}
+	}
+}
+
+////////////////////////////////////////////////////////////////////
+
+////////////////////////////////////////////////////////////////////
+type CommandPanelLogQueryHandler struct {
+	ListHandler
+	commandPanelWidget *views.CommandPanelWidget
+	list               *views.ListWidget
+	content            *views.ItemWidget
+	ctx                context.Context
+	resourceID         string
+}
+
+var _ Command = &CommandPanelLogQueryHandler{}
+
+func NewCommandPanelLogQueryHandler(commandPanelWidget *views.CommandPanelWidget, list *views.ListWidget, content *views.ItemWidget, ctx context.Context) *CommandPanelLogQueryHandler {
+	handler := &CommandPanelLogQueryHandler{
+		commandPanelWidget: commandPanelWidget,
+		list:               list,
+		content:            content,
+		ctx:                ctx,
+	}
+	handler.id = HandlerIDLogQuery
+	return handler
+}
+
+func (h *CommandPanelLogQueryHandler) Fn() func(g *gocui.Gui, v *gocui.View) error {
+	return func(g *gocui.Gui, v *gocui.View) error {
+		if h.IsEnabled() {
+			return h.Invoke()
+		}
+		return nil
+	}
+}
+
+func (h *CommandPanelLogQueryHandler) DisplayText() string {
+	return "Run log query"
+}
+
+func (h *CommandPanelLogQueryHandler) IsEnabled() bool {
+	return h.getResourceID() != ""
+}
+
+// getResourceID returns the resource to query, preferring the selected item over the item being viewed
+func (h *CommandPanelLogQueryHandler) getResourceID() string {
+	if resourceID := expanders.GetLogQueryResourceID(h.list.CurrentItem()); resourceID != "" {
+		return resourceID
+	}
+	return expanders.GetLogQueryResourceID(h.content.GetNode())
+}
+
+func (h *CommandPanelLogQueryHandler) Invoke() error {
+	h.resourceID = h.getResourceID()
+
+	// Start from the query being viewed or the last query run against the resource
+	query := ""
+	if node := h.content.GetNode(); expanders.IsLogQueryResult(node) && node.Metadata["ResourceID"] == h.resourceID {
+		query = node.Metadata["Query"]
+	} else if history := expanders.GetLogQueryHistory(h.resourceID); len(history) > 0 {
+		query = history[0]
+	}
+	h.commandPanelWidget.ShowWithText("KQL query:", query, nil, h.CommandPanelNotification)
+	return nil
+}
+
+func (h *CommandPanelLogQueryHandler) CommandPanelNotification(state views.CommandPanelNotification) {
+	if state.EnterPressed {
+		h.commandPanelWidget.Hide()
+		query := strings.TrimSpace(state.CurrentText)
+		if query == "" {
+			return
+		}
+
+		queryNode := expanders.NewLogQueryNode(h.resourceID, query)
+		// Keep the time range and column width of the query being viewed
+		if node := h.content.GetNode(); expanders.IsLogQueryResult(node) && node.Metadata["ResourceID"] == h.resourceID {
+			queryNode.Metadata["Timespan"] = node.Metadata["Timespan"]
+			queryNode.Metadata["MaxColumnWidth"] = node.Metadata["MaxColumnWidth"]
+		}
+		newContent, _, err := expanders.ExpandItem(h.ctx, queryNode)
+		if err != nil { // Don't need to display error as expander emits status event on error
+			return
+		}
+		h.content.SetContent(queryNode, newContent.Response, newContent.ResponseType, queryNode.Name)
+	}
+}
+
+////////////////////////////////////////////////////////////////////
+
+////////////////////////////////////////////////////////////////////
+type CommandPanelLogQueryOptionsHandler struct {
+	ListHandler
+	commandPanelWidget *views.CommandPanelWidget
+	content            *views.ItemWidget
+	status             *views.StatusbarWidget
+	ctx                context.Context
+}
+
+var _ Command = &CommandPanelLogQueryOptionsHandler{}
+
+func NewCommandPanelLogQueryOptionsHandler(commandPanelWidget *views.CommandPanelWidget, content *views.ItemWidget, status *views.StatusbarWidget, ctx context.Context) *CommandPanelLogQueryOptionsHandler {
+	handler := &CommandPanelLogQueryOptionsHandler{
+		commandPanelWidget: commandPanelWidget,
+		content:            content,
+		status:             status,
+		ctx:                ctx,
+	}
+	handler.id = HandlerIDLogQueryOptions
+	return handler
+}
+
+func (h *CommandPanelLogQueryOptionsHandler) Fn() func(g *gocui.Gui, v *gocui.View) error {
+	return func(g *gocui.Gui, v *gocui.View) error {
+		if h.IsEnabled() {
+			return h.Invoke()
+		}
+		return nil
+	}
+}
+
+func (h *CommandPanelLogQueryOptionsHandler) DisplayText() string {
+	return "Change log query time range or column width"
+}
+
+func (h *CommandPanelLogQueryOptionsHandler) IsEnabled() bool {
+	return expanders.IsLogQueryResult(h.content.GetNode())
+}
+
+func (h *CommandPanelLogQueryOptionsHandler) Invoke() error {
+	options := []views.CommandPanelListOption{}
+	for _, option := range expanders.GetLogQueryOptions(h.content.GetNode()) {
+		options = append(options, views.CommandPanelListOption{
+			ID:          option.ID,
+			DisplayText: option.DisplayText,
+		})
+	}
+	h.commandPanelWidget.ShowWithText("query options (or type a range e.g. P3D or 2020-01-01T00:00Z/2020-01-01T06:00Z):", "", &options, h.CommandPanelNotification)
+	return nil
+}
+
+func (h *CommandPanelLogQueryOptionsHandler) CommandPanelNotification(state views.CommandPanelNotification) {
+	if state.EnterPressed {
+		h.commandPanelWidget.Hide()
+		node := h.content.GetNode()
+		if !expanders.IsLogQueryResult(node) {
+			return
+		}
+
+		if err := expanders.ApplyLogQueryOption(node, state.SelectedID, state.CurrentText); err != nil {
+			h.status.Status(fmt.Sprintf("Failed to update query options: %s", err), false)
+			return
+		}
+
+		newContent, _, err := expanders.ExpandItem(h.ctx, node)
+		if err != nil { // Don't need to display error as expander emits status event on error
+			return
+		}
+		h.content.SetContent(node, newContent.Response, newContent.ResponseType, node.Name)
+	}
+}
+
+////////////////////////////////////////////////////////////////////
+
+////////////////////////////////////////////////////////////////////
+type CommandPanelLogQueryExportHandler struct {
+	ListHandler
+	commandPanelWidget *views.CommandPanelWidget
+	content            *views.ItemWidget
+	status             *views.StatusbarWidget
+}
+
+var _ Command = &CommandPanelLogQueryExportHandler{}
+
+func NewCommandPanelLogQueryExportHandler(commandPanelWidget *views.CommandPanelWidget, content *views.ItemWidget, status *views.StatusbarWidget) *CommandPanelLogQueryExportHandler {
+	handler := &CommandPanelLogQueryExportHandler{
+		commandPanelWidget: commandPanelWidget,
+		content:            content,
+		status:             status,
+	}
+	handler.id = HandlerIDLogQueryExport
+	return handler
+}
+
+func (h *CommandPanelLogQueryExportHandler) Fn() func(g *gocui.Gui, v *gocui.View) error {
+	return func(g *gocui.Gui, v *gocui.View) error {
+		if h.IsEnabled() {
+			return h.Invoke()
+		}
+		return nil
+	}
+}
+
+func (h *CommandPanelLogQueryExportHandler) DisplayText() string {
+	return "Export log query results"
+}
+
+func (h *CommandPanelLogQueryExportHandler) IsEnabled() bool {
+	node := h.content.GetNode()
+	return expanders.IsLogQueryResult(node) && node.Metadata["Result"] != ""
+}
+
+func (h *CommandPanelLogQueryExportHandler) Invoke() error {
+	h.commandPanelWidget.ShowWithText("export to file (.csv or .json):", expanders.GetLogQueryExportPath(), nil, h.CommandPanelNotification)
+	return nil
+}
+
+func (h *CommandPanelLogQueryExportHandler) CommandPanelNotification(state views.CommandPanelNotification) {
+	if state.EnterPressed {
+		h.commandPanelWidget.Hide()
+		node := h.content.GetNode()
+		if !expanders.IsLogQueryResult(node) {
+			return
+		}
+		if err := expanders.ExportLogQueryResult(node, state.CurrentText); err != nil {
+			h.status.Status(fmt.Sprintf("Failed to export query results: %s", err), false)
+			return
+		}
+		h.status.Status("Exported query results to "+state.CurrentText, false)
+	}
+}
+
+////////////////////////////////////////////////////////////////////
+
+////////////////////////////////////////////////////////////////////
+type CommandPanelFollowLogsHandler struct {
+	ListHandler
+	commandPanelWidget *views.CommandPanelWidget
+	list               *views.ListWidget
+	content            *views.ItemWidget
+	logFollower        *views.LogFollower
+	ctx                context.Context
+	node               *expanders.TreeNode
+}
+
+var _ Command = &CommandPanelFollowLogsHandler{}
+
+func NewCommandPanelFollowLogsHandler(commandPanelWidget *views.CommandPanelWidget, list *views.ListWidget, content *views.ItemWidget, logFollower *views.LogFollower, ctx context.Context) *CommandPanelFollowLogsHandler {
+	handler := &CommandPanelFollowLogsHandler{
+		commandPanelWidget: commandPanelWidget,
+		list:               list,
+		content:            content,
+		logFollower:        logFollower,
+		ctx:                ctx,
+	}
+	handler.id = HandlerIDFollowLogs
+	return handler
+}
+
+func (h *CommandPanelFollowLogsHandler) Fn() func(g *gocui.Gui, v *gocui.View) error {
+	return func(g *gocui.Gui, v *gocui.View) error {
+		if h.IsEnabled() {
+			return h.Invoke()
+		}
+		return nil
+	}
+}
+
+func (h *CommandPanelFollowLogsHandler) DisplayText() string {
+	return "Follow logs"
+}
+
+func (h *CommandPanelFollowLogsHandler) IsEnabled() bool {
+	return h.getNode() != nil
+}
+
+// getNode returns the log node to follow, preferring the item being viewed over the selected item
+func (h *CommandPanelFollowLogsHandler) getNode() *expanders.TreeNode {
+	if node := h.content.GetNode(); expanders.CanFollowLogs(node) {
+		return node
+	}
+	if node := h.list.CurrentItem(); expanders.CanFollowLogs(node) {
+		return node
+	}
+	return nil
+}
+
+func (h *CommandPanelFollowLogsHandler) Invoke() error {
+	h.node = h.getNode()
+	if !expanders.CanFollowPreviousLogs(h.node) {
+		h.logFollower.Start(h.ctx, h.node, expanders.LogFollowOptions{})
+		return nil
+	}
+
+	options := []views.CommandPanelListOption{
+		{
+			ID:          "current",
+			DisplayText: "Follow logs",
+		},
+		{
+			ID:          "previous",
+			DisplayText: "Show logs from the previous container instance (e.g. after a crash)",
+		},
+	}
+	h.commandPanelWidget.ShowWithText("logs:", "", &options, h.CommandPanelNotification)
+	return nil
+}
+
+func (h *CommandPanelFollowLogsHandler) CommandPanelNotification(state views.CommandPanelNotification) {
+	if state.EnterPressed {
+		h.commandPanelWidget.Hide()
+		if state.SelectedID == "" {
+			return
+		}
+		h.logFollower.Start(h.ctx, h.node, expanders.LogFollowOptions{Previous: state.SelectedID == "previous"})
+	}
+}
+
+////////////////////////////////////////////////////////////////////
+
+////////////////////////////////////////////////////////////////////
+type ToggleFollowLogsPausedHandler struct {
+	ListHandler
+	logFollower *views.LogFollower
+	status      *views.StatusbarWidget
+}
+
+var _ Command = &ToggleFollowLogsPausedHandler{}
+
+func NewToggleFollowLogsPausedHandler(logFollower *views.LogFollower, status *views.StatusbarWidget) *ToggleFollowLogsPausedHandler {
+	handler := &ToggleFollowLogsPausedHandler{
+		logFollower: logFollower,
+		status:      status,
+	}
+	handler.id = HandlerIDToggleFollowLogsPaused
+	return handler
+}
+
+func (h *ToggleFollowLogsPausedHandler) Fn() func(g *gocui.Gui, v *gocui.View) error {
+	return func(g *gocui.Gui, v *gocui.View) error {
+		if h.IsEnabled() {
+			return h.Invoke()
+		}
+		return nil
+	}
+}
+
+func (h *ToggleFollowLogsPausedHandler) DisplayText() string {
+	if h.logFollower.IsPaused() {
+		return "Resume following logs"
+	}
+	return "Pause following logs"
+}
+
+func (h *ToggleFollowLogsPausedHandler) IsEnabled() bool {
+	return h.logFollower.IsFollowing()
+}
+
+func (h *ToggleFollowLogsPausedHandler) Invoke() error {
+	paused := !h.logFollower.IsPaused()
+	h.logFollower.SetPaused(paused)
+	if paused {
+		h.status.Status("Paused following logs, new lines will be shown when resumed", false)
+	} else {
+		h.status.Status("Resumed following logs", false)
+	}
+	return nil
+}
+
+////////////////////////////////////////////////////////////////////
+
+////////////////////////////////////////////////////////////////////
+type CommandPanelFilterFollowedLogsHandler struct {
+	ListHandler
+	commandPanelWidget *views.CommandPanelWidget
+	logFollower        *views.LogFollower
+}
+
+var _ Command = &CommandPanelFilterFollowedLogsHandler{}
+
+func NewCommandPanelFilterFollowedLogsHandler(commandPanelWidget *views.CommandPanelWidget, logFollower *views.LogFollower) *CommandPanelFilterFollowedLogsHandler {
+	handler := &CommandPanelFilterFollowedLogsHandler{
+		commandPanelWidget: commandPanelWidget,
+		logFollower:        logFollower,
+	}
+	handler.id = HandlerIDFilterFollowedLogs
+	return handler
+}
+
+func (h *CommandPanelFilterFollowedLogsHandler) Fn() func(g *gocui.Gui, v *gocui.View) error {
+	return func(g *gocui.Gui, v *gocui.View) error {
+		if h.IsEnabled() {
+			return h.Invoke()
+		}
+		return nil
+	}
+}
+
+func (h *CommandPanelFilterFollowedLogsHandler) DisplayText() string {
+	return "Filter followed logs"
+}
+
+func (h *CommandPanelFilterFollowedLogsHandler) IsEnabled() bool {
+	return h.logFollower.IsFollowing()
+}
+
+func (h *CommandPanelFilterFollowedLogsHandler) Invoke() error {
+	h.commandPanelWidget.ShowWithText("show lines containing (empty to show all):", h.logFollower.Filter(), nil, h.CommandPanelNotification)
+	return nil
+}
+
+func (h *CommandPanelFilterFollowedLogsHandler) CommandPanelNotification(state views.CommandPanelNotification) {
+	if state.EnterPressed {
+		h.commandPanelWidget.Hide()
+		h.logFollower.SetFilter(state.CurrentText)
+	}
+}
+
+////////////////////////////////////////////////////////////////////
+
+////////////////////////////////////////////////////////////////////
+type CommandPanelPurgeRegistryTagsHandler struct {
+	ListHandler
+	commandPanelWidget *views.CommandPanelWidget
+	list               *views.ListWidget
+	content            *views.ItemWidget
+	status             *views.StatusbarWidget
+	ctx                context.Context
+}
+
+var _ Command = &CommandPanelPurgeRegistryTagsHandler{}
+
+func NewCommandPanelPurgeRegistryTagsHandler(commandPanelWidget *views.CommandPanelWidget, list *views.ListWidget, content *views.ItemWidget, status *views.StatusbarWidget, ctx context.Context) *CommandPanelPurgeRegistryTagsHandler {
+	handler := &CommandPanelPurgeRegistryTagsHandler{
+		commandPanelWidget: commandPanelWidget,
+		list:               list,
+		content:            content,
+		status:             status,
+		ctx:                ctx,
+	}
+	handler.id = HandlerIDPurgeRegistryTags
+	return handler
+}
+
+func (h *CommandPanelPurgeRegistryTagsHandler) Fn() func(g *gocui.Gui, v *gocui.View) error {
+	return func(g *gocui.Gui, v *gocui.View) error {
+		if h.IsEnabled() {
+			return h.Invoke()
+		}
+		return nil
+	}
+}
+
+func (h *CommandPanelPurgeRegistryTagsHandler) DisplayText() string {
+	return "Purge container registry tags"
+}
+
+func (h *CommandPanelPurgeRegistryTagsHandler) IsEnabled() bool {
+	return expanders.IsContainerRegistryRepository(h.list.CurrentExpandedItem())
+}
+
+func (h *CommandPanelPurgeRegistryTagsHandler) Invoke() error {
+	h.commandPanelWidget.ShowWithText("purge tags older than <days> matching <regex> (e.g. 30 dev-.*):", "30 .*", nil, h.CommandPanelNotification)
+	return nil
+}
+
+func (h *CommandPanelPurgeRegistryTagsHandler) CommandPanelNotification(state views.CommandPanelNotification) {
+	if !state.EnterPressed {
+		return
+	}
+	h.commandPanelWidget.Hide()
+
+	fields := strings.SplitN(strings.TrimSpace(state.CurrentText), " ", 2)
+	days, err := strconv.Atoi(fields[0])
+	if err != nil {
+		h.status.Status("Purge requires the number of days followed by a tag pattern, e.g. 30 dev-.*", false)
+		return
+	}
+	pattern := ".*"
+	if len(fields) == 2 && strings.TrimSpace(fields[1]) != "" {
+		pattern = strings.TrimSpace(fields[1])
+	}
+
+	// The purge node previews the tags to delete and lists the actions to run the purge
+	purgeNode, err := expanders.NewContainerRegistryPurgeNode(h.list.CurrentExpandedItem(), days, pattern)
+	if err != nil {
+		h.status.Status(err.Error(), false)
+		return
+	}
+	newContent, newItems, err := expanders.ExpandItem(h.ctx, purgeNode)
+	if err != nil { // Don't need to display error as expander emits status event on error
+		return
+	}
+	h.list.SetNodes(newItems)
+	h.content.SetContent(purgeNode, newContent.Response, newContent.ResponseType, purgeNode.Name)
+}
+
+////////////////////////////////////////////////////////////////////
+
+////////////////////////////////////////////////////////////////////
+type CommandPanelContainerExecHandler struct {
+	ListHandler
+	commandPanelWidget *views.CommandPanelWidget
+	list               *views.ListWidget
+	status             *views.StatusbarWidget
+	gui                *gocui.Gui
+	ctx                context.Context
+	node               *expanders.TreeNode
+}
+
+var _ Command = &CommandPanelContainerExecHandler{}
+
+func NewCommandPanelContainerExecHandler(commandPanelWidget *views.CommandPanelWidget, list *views.ListWidget, status *views.StatusbarWidget, gui *gocui.Gui, ctx context.Context) *CommandPanelContainerExecHandler {
+	handler := &CommandPanelContainerExecHandler{
+		commandPanelWidget: commandPanelWidget,
+		list:               list,
+		status:             status,
+		gui:                gui,
+		ctx:                ctx,
+	}
+	handler.id = HandlerIDContainerExec
+	return handler
+}
+
+func (h *CommandPanelContainerExecHandler) Fn() func(g *gocui.Gui, v *gocui.View) error {
+	return func(g *gocui.Gui, v *gocui.View) error {
+		if h.IsEnabled() {
+			return h.Invoke()
+		}
+		return nil
+	}
+}
+
+func (h *CommandPanelContainerExecHandler) DisplayText() string {
+	return "Exec (run a command in the container)"
+}
+
+func (h *CommandPanelContainerExecHandler) IsEnabled() bool {
+	return expanders.CanExec(h.list.CurrentItem())
+}
+
+func (h *CommandPanelContainerExecHandler) Invoke() error {
+	h.node = h.list.CurrentItem()
+	h.commandPanelWidget.ShowWithText("command to run in "+h.node.Name+":", "/bin/sh", nil, h.CommandPanelNotification)
+	return nil
+}
+
+func (h *CommandPanelContainerExecHandler) CommandPanelNotification(state views.CommandPanelNotification) {
+	if !state.EnterPressed {
+		return
+	}
+	h.commandPanelWidget.Hide()
+	command := strings.TrimSpace(state.CurrentText)
+	if command == "" {
+		return
+	}
+	err := h.exec(command)
+	if err != nil {
+		h.status.Status(fmt.Sprintf("Exec failed: %s", err), false)
+	}
+}
+
+// exec suspends the UI while the user interacts with the command in the container
+func (h *CommandPanelContainerExecHandler) exec(command string) error {
+	cols, rows := termbox.Size()
+	session, err := expanders.StartExec(h.ctx, h.node, expanders.ContainerExecOptions{
+		Command: command,
+		Rows:    rows,
+		Cols:    cols,
+	})
+	if err != nil {
+		return err
+	}
+
+	// Close termbox to revert to normal buffer
+	termbox.Close()
+	fmt.Printf("Connecting to %s (exit the command to return to azbrowse)...\r\n", h.node.Name)
+	restoreTerminal, err := setTerminalRaw()
+	if err == nil {
+		err = session.Run(h.ctx, os.Stdin, os.Stdout)
+		restoreTerminal()
+	}
+
+	// Init termbox to switch back to alternate buffer and Flush content
+	if initErr := termbox.Init(); initErr != nil {
+		return fmt.Errorf("Failed to reinitialise termbox: %v", initErr)
+	}
+	if flushErr := h.gui.Flush(); flushErr != nil {
+		return fmt.Errorf("Failed to reinitialise termbox: %v", flushErr)
+	}
+	return err
+}
+
+// setTerminalRaw puts the terminal into raw mode so that key presses are sent to the container as they are typed
+// and returns a function to restore the previous mode. stty isn't available on Windows so input is sent by line
+func setTerminalRaw() (func(), error) {
+	if runtime.GOOS == "windows" {
+		return func() {}, nil
+	}
+	state, err := stty("-g")
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get terminal state: %s", err)
+	}
+	if _, err := stty("raw", "-echo"); err != nil {
+		return nil, fmt.Errorf("Failed to set terminal to raw mode: %s", err)
+	}
+	return func() {
+		_, _ = stty(strings.TrimSpace(state))
+	}, nil
+}
+
+func stty(args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = os.Stdin
+	output, err := cmd.Output()
+	return string(output), err
+}
+
+////////////////////////////////////////////////////////////////////
+
+////////////////////////////////////////////////////////////////////
+type CommandPanelRunVirtualMachineCommandHandler struct {
+	ListHandler
+	commandPanelWidget *views.CommandPanelWidget
+	list               *views.ListWidget
+	content            *views.ItemWidget
+	status             *views.StatusbarWidget
+	ctx                context.Context
+	node               *expanders.TreeNode
+}
+
+var _ Command = &CommandPanelRunVirtualMachineCommandHandler{}
+
+func NewCommandPanelRunVirtualMachineCommandHandler(commandPanelWidget *views.CommandPanelWidget, list *views.ListWidget, content *views.ItemWidget, status *views.StatusbarWidget, ctx context.Context) *CommandPanelRunVirtualMachineCommandHandler {
+	handler := &CommandPanelRunVirtualMachineCommandHandler{
+		commandPanelWidget: commandPanelWidget,
+		list:               list,
+		content:            content,
+		status:             status,
+		ctx:                ctx,
+	}
+	handler.id = HandlerIDRunVirtualMachineCommand
+	return handler
+}
+
+func (h *CommandPanelRunVirtualMachineCommandHandler) Fn() func(g *gocui.Gui, v *gocui.View) error {
+	return func(g *gocui.Gui, v *gocui.View) error {
+		if h.IsEnabled() {
+			return h.Invoke()
+		}
+		return nil
+	}
+}
+
+func (h *CommandPanelRunVirtualMachineCommandHandler) DisplayText() string {
+	return "Run command (run a script on the virtual machine)"
+}
+
+func (h *CommandPanelRunVirtualMachineCommandHandler) IsEnabled() bool {
+	return h.virtualMachine() != nil
+}
+
+// virtualMachine returns the selected virtual machine or the virtual machine that has been expanded
+func (h *CommandPanelRunVirtualMachineCommandHandler) virtualMachine() *expanders.TreeNode {
+	if item := h.list.CurrentItem(); expanders.CanRunVirtualMachineCommand(item) {
+		return item
+	}
+	if item := h.list.CurrentExpandedItem(); expanders.CanRunVirtualMachineCommand(item) {
+		return item
+	}
+	return nil
+}
+
+func (h *CommandPanelRunVirtualMachineCommandHandler) Invoke() error {
+	h.node = h.virtualMachine()
+	h.commandPanelWidget.ShowWithText("script to run on "+h.node.Name+" (shell on Linux, PowerShell on Windows):", "", nil, h.CommandPanelNotification)
+	return nil
+}
+
+func (h *CommandPanelRunVirtualMachineCommandHandler) CommandPanelNotification(state views.CommandPanelNotification) {
+	if !state.EnterPressed {
+		return
+	}
+	h.commandPanelWidget.Hide()
+	script := strings.TrimSpace(state.CurrentText)
+	if script == "" {
+		return
+	}
+
+	// Run commands take minutes to complete so the output is shown once the command finishes
+	node := h.node
+	title := "Run command: " + script
+	h.content.SetContent(node, "Running `"+script+"` on "+node.Name+"...", expanders.ResponsePlainText, title)
+	go func() {
+		// recover from panic, if one occurrs, and leave terminal usable
+		defer errorhandling.RecoveryWithCleanup()
+
+		done := h.status.Status("Running command on "+node.Name, true)
+		output, err := expanders.RunVirtualMachineCommand(h.ctx, node, script)
+		done()
+		if err != nil {
+			h.status.Status("Run command failed: "+err.Error(), false)
+			output = err.Error()
+		}
+		h.content.SetContent(node, output, expanders.ResponsePlainText, title)
+	}()
+}
+
+////////////////////////////////////////////////////////////////////
+
+////////////////////////////////////////////////////////////////////
+type CommandPanelSetAppServiceSettingHandler struct {
+	ListHandler
+	commandPanelWidget *views.CommandPanelWidget
+	list               *views.ListWidget
+	status             *views.StatusbarWidget
+	ctx                context.Context
+	node               *expanders.TreeNode
+}
+
+var _ Command = &CommandPanelSetAppServiceSettingHandler{}
+
+func NewCommandPanelSetAppServiceSettingHandler(commandPanelWidget *views.CommandPanelWidget, list *views.ListWidget, status *views.StatusbarWidget, ctx context.Context) *CommandPanelSetAppServiceSettingHandler {
+	handler := &CommandPanelSetAppServiceSettingHandler{
+		commandPanelWidget: commandPanelWidget,
+		list:               list,
+		status:             status,
+		ctx:                ctx,
+	}
+	handler.id = HandlerIDSetAppServiceSetting
+	return handler
+}
+
+func (h *CommandPanelSetAppServiceSettingHandler) Fn() func(g *gocui.Gui, v *gocui.View) error {
+	return func(g *gocui.Gui, v *gocui.View) error {
+		if h.IsEnabled() {
+			return h.Invoke()
+		}
+		return nil
+	}
+}
+
+func (h *CommandPanelSetAppServiceSettingHandler) DisplayText() string {
+	return "Set app service setting (NAME=value, or -NAME to remove)"
+}
+
+func (h *CommandPanelSetAppServiceSettingHandler) IsEnabled() bool {
+	return h.settingsNode() != nil
+}
+
+// settingsNode returns the selected setting or the app settings/connection strings node that has been expanded
+func (h *CommandPanelSetAppServiceSettingHandler) settingsNode() *expanders.TreeNode {
+	if item := h.list.CurrentItem(); expanders.CanSetAppServiceSetting(item) {
+		return item
+	}
+	if item := h.list.CurrentExpandedItem(); expanders.CanSetAppServiceSetting(item) {
+		return item
+	}
+	return nil
+}
+
+func (h *CommandPanelSetAppServiceSettingHandler) Invoke() error {
+	h.node = h.settingsNode()
+	// Pre-fill the name when a setting is selected so that it can be changed by typing the new value
+	text := ""
+	if h.node.Metadata["Value"] != "" {
+		text = h.node.Name + "="
+	}
+	h.commandPanelWidget.ShowWithText("setting to set (NAME=value) or remove (-NAME):", text, nil, h.CommandPanelNotification)
+	return nil
+}
+
+func (h *CommandPanelSetAppServiceSettingHandler) CommandPanelNotification(state views.CommandPanelNotification) {
+	if !state.EnterPressed {
+		return
+	}
+	h.commandPanelWidget.Hide()
+	text := strings.TrimSpace(state.CurrentText)
+	if text == "" {
+		return
+	}
+
+	var name, value string
+	remove := strings.HasPrefix(text, "-")
+	if remove {
+		name = strings.TrimPrefix(text, "-")
+	} else {
+		parts := strings.SplitN(text, "=", 2)
+		if len(parts) != 2 {
+			h.status.Status("Setting must be in the form NAME=value", false)
+			return
+		}
+		name, value = strings.TrimSpace(parts[0]), parts[1]
+	}
+
+	node := h.node
+	go func() {
+		// recover from panic, if one occurrs, and leave terminal usable
+		defer errorhandling.RecoveryWithCleanup()
+
+		done := h.status.Status("Updating setting "+name, true)
+		err := expanders.SetAppServiceSetting(h.ctx, node, name, value, remove)
+		done()
+		if err != nil {
+			h.status.Status("Failed to update setting: "+err.Error(), false)
+			return
+		}
+		h.status.Status("Updated setting "+name+" (refresh to see the change)", false)
+	}()
+}
+
+////////////////////////////////////////////////////////////////////
+
+////////////////////////////////////////////////////////////////////
+type CommandPanelVerifyIPFlowHandler struct {
+	ListHandler
+	commandPanelWidget *views.CommandPanelWidget
+	list               *views.ListWidget
+	content            *views.ItemWidget
+	status             *views.StatusbarWidget
+	ctx                context.Context
+	node               *expanders.TreeNode
+}
+
+var _ Command = &CommandPanelVerifyIPFlowHandler{}
+
+func NewCommandPanelVerifyIPFlowHandler(commandPanelWidget *views.CommandPanelWidget, list *views.ListWidget, content *views.ItemWidget, status *views.StatusbarWidget, ctx context.Context) *CommandPanelVerifyIPFlowHandler {
+	handler := &CommandPanelVerifyIPFlowHandler{
+		commandPanelWidget: commandPanelWidget,
+		list:               list,
+		content:            content,
+		status:             status,
+		ctx:                ctx,
+	}
+	handler.id = HandlerIDVerifyIPFlow
+	return handler
+}
+
+func (h *CommandPanelVerifyIPFlowHandler) Fn() func(g *gocui.Gui, v *gocui.View) error {
+	return func(g *gocui.Gui, v *gocui.View) error {
+		if h.IsEnabled() {
+			return h.Invoke()
+		}
+		return nil
+	}
+}
+
+func (h *CommandPanelVerifyIPFlowHandler) DisplayText() string {
+	return "IP flow verify (check if traffic is allowed with Network Watcher)"
+}
+
+func (h *CommandPanelVerifyIPFlowHandler) IsEnabled() bool {
+	return h.target() != nil
+}
+
+// target returns the selected VM or NIC or the VM or NIC that has been expanded
+func (h *CommandPanelVerifyIPFlowHandler) target() *expanders.TreeNode {
+	if item := h.list.CurrentItem(); expanders.CanVerifyNetworkIPFlow(item) {
+		return item
+	}
+	if item := h.list.CurrentExpandedItem(); expanders.CanVerifyNetworkIPFlow(item) {
+		return item
+	}
+	return nil
+}
+
+func (h *CommandPanelVerifyIPFlowHandler) Invoke() error {
+	h.node = h.target()
+	h.commandPanelWidget.ShowWithText("flow to verify for "+h.node.Name+" "+expanders.NetworkIPFlowFormat+":", "", nil, h.CommandPanelNotification)
+	return nil
+}
+
+func (h *CommandPanelVerifyIPFlowHandler) CommandPanelNotification(state views.CommandPanelNotification) {
+	if !state.EnterPressed {
+		return
+	}
+	h.commandPanelWidget.Hide()
+	flow := strings.TrimSpace(state.CurrentText)
+	if flow == "" {
+		return
+	}
+
+	node := h.node
+	title := "IP flow verify: " + flow
+	h.content.SetContent(node, "Verifying `"+flow+"` for "+node.Name+"...", expanders.ResponsePlainText, title)
+	go func() {
+		// recover from panic, if one occurrs, and leave terminal usable
+		defer errorhandling.RecoveryWithCleanup()
+
+		done := h.status.Status("Verifying IP flow for "+node.Name, true)
+		output, err := expanders.VerifyNetworkIPFlow(h.ctx, node, flow)
+		done()
+		if err != nil {
+			h.status.Status("IP flow verify failed: "+err.Error(), false)
+			output = err.Error()
+		}
+		h.content.SetContent(node, output, expanders.ResponsePlainText, title)
+	}()
+}
+
+////////////////////////////////////////////////////////////////////
+
+////////////////////////////////////////////////////////////////////
+type CommandPanelSelectAPIVersionHandler struct {
+	ListHandler
+	commandPanelWidget *views.CommandPanelWidget
+	list               *views.ListWidget
+	content            *views.ItemWidget
+	status             *views.StatusbarWidget
+	ctx                context.Context
+	node               *expanders.TreeNode
+}
+
+var _ Command = &CommandPanelSelectAPIVersionHandler{}
+
+func NewCommandPanelSelectAPIVersionHandler(commandPanelWidget *views.CommandPanelWidget, list *views.ListWidget, content *views.ItemWidget, status *views.StatusbarWidget, ctx context.Context) *CommandPanelSelectAPIVersionHandler {
+	handler := &CommandPanelSelectAPIVersionHandler{
+		commandPanelWidget: commandPanelWidget,
+		list:               list,
+		content:            content,
+		status:             status,
+		ctx:                ctx,
+	}
+	handler.id = HandlerIDSelectAPIVersion
+	return handler
+}
+
+func (h *CommandPanelSelectAPIVersionHandler) Fn() func(g *gocui.Gui, v *gocui.View) error {
+	return func(g *gocui.Gui, v *gocui.View) error {
+		if h.IsEnabled() {
+			return h.Invoke()
+		}
+		return nil
+	}
+}
+
+func (h *CommandPanelSelectAPIVersionHandler) DisplayText() string {
+	return "Select api-version (including previews)"
+}
+
+func (h *CommandPanelSelectAPIVersionHandler) IsEnabled() bool {
+	return h.target() != nil
+}
+
+// target returns the node shown in the content view or the node that has been expanded
+func (h *CommandPanelSelectAPIVersionHandler) target() *expanders.TreeNode {
+	if node := h.content.GetNode(); expanders.CanSelectAPIVersion(node) {
+		return node
+	}
+	if node := h.list.CurrentExpandedItem(); expanders.CanSelectAPIVersion(node) {
+		return node
+	}
+	return nil
+}
+
+func (h *CommandPanelSelectAPIVersionHandler) Invoke() error {
+	h.node = h.target()
+	apiVersionOptions, err := expanders.GetAPIVersionOptions(h.node)
+	if err != nil {
+		h.status.Status(fmt.Sprintf("Failed to get api-versions: %s", err), false)
+		return nil
+	}
+	options := []views.CommandPanelListOption{}
+	for _, option := range apiVersionOptions {
+		options = append(options, views.CommandPanelListOption{
+			ID:          option.ID,
+			DisplayText: option.DisplayText,
+		})
+	}
+	h.commandPanelWidget.ShowWithText("api-version for "+expanders.GetNodeARMType(h.node)+" (or type an api-version):", "", &options, h.CommandPanelNotification)
+	return nil
+}
+
+func (h *CommandPanelSelectAPIVersionHandler) CommandPanelNotification(state views.CommandPanelNotification) {
+	if !state.EnterPressed {
+		return
+	}
+	h.commandPanelWidget.Hide()
+	node := h.node
+
+	if state.SelectedID == expanders.APIVersionOptionRefresh {
+		go func() {
+			// recover from panic, if one occurrs, and leave terminal usable
+			defer errorhandling.RecoveryWithCleanup()
+
+			done := h.status.Status("Refreshing api-versions", true)
+			err := armclient.LegacyInstance.RefreshResourceAPILookup(h.ctx)
+			done()
+			if err != nil {
+				h.status.Status("Failed to refresh api-versions: "+err.Error(), false)
+				return
+			}
+			h.status.Status("Refreshed api-versions", false)
+		}()
+		return
+	}
+
+	if err := expanders.ApplyAPIVersionOption(node, state.SelectedID, state.CurrentText); err != nil {
+		h.status.Status(fmt.Sprintf("Failed to set api-version: %s", err), false)
+		return
+	}
+
+	// Refresh the list when the expanded node changed so that its children are updated too
+	if node == h.list.CurrentExpandedItem() {
+		h.list.Refresh()
+		return
+	}
+	newContent, _, err := expanders.ExpandItem(h.ctx, node)
+	if err != nil { // Don't need to display error as expander emits status event on error
+		return
+	}
+	h.content.SetContent(node, newContent.Response, newContent.ResponseType, node.Name)
+}
+
+////////////////////////////////////////////////////////////////////
+
 ////////////////////////////////////////////////////////////////////
 type ListCopyItemIDHandler struct {
 	ListHandler
@@ -1,6 +1,7 @@
 package storage
 
 import (
+	"bytes"
 	"fmt"
 	"log"
 	"os/user"
@@ -32,11 +33,21 @@ func LoadDB() {
 		fmt.Println("AzBrowse is waiting for access to '~/.azbrowse.db', do you have another instance of azbrowse open?")
 	})
 
-	dbCreate, err := bolt.Open(dbLocation, 0600, nil)
+	err = LoadDBFromPath(dbLocation)
 	waitingMessageTimer.Stop()
 	if err != nil {
 		log.Fatal(err)
 	}
+	fmt.Println("Loading db complete")
+
+}
+
+// LoadDBFromPath opens the DB at dbLocation and creates the buckets used by azbrowse
+func LoadDBFromPath(dbLocation string) error {
+	dbCreate, err := bolt.Open(dbLocation, 0600, nil)
+	if err != nil {
+		return err
+	}
 
 	db = dbCreate
 
@@ -49,18 +60,32 @@ func LoadDB() {
 		if err != nil {
 			return fmt.Errorf("create bucket: %s", err)
 		}
+		_, err = tx.CreateBucketIfNotExists([]byte("metrics"))
+		if err != nil {
+			return fmt.Errorf("create bucket: %s", err)
+		}
+		_, err = tx.CreateBucketIfNotExists([]byte("dashboards"))
+		if err != nil {
+			return fmt.Errorf("create bucket: %s", err)
+		}
+		_, err = tx.CreateBucketIfNotExists([]byte("queryhistory"))
+		if err != nil {
+			return fmt.Errorf("create bucket: %s", err)
+		}
+		_, err = tx.CreateBucketIfNotExists([]byte("swaggerspecs"))
+		if err != nil {
+			return fmt.Errorf("create bucket: %s", err)
+		}
 		return nil
 	})
-
-	if err != nil {
-		log.Fatal(err)
-	}
-	fmt.Println("Loading db complete")
-
+	return err
 }
 
 // PutCache puts an item in the cache bucket
 func PutCache(key, value string) error {
+	if db == nil {
+		return fmt.Errorf("DB not loaded")
+	}
 	return db.Update(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte("cache"))
 		err := b.Put([]byte(key), []byte(value))
@@ -70,6 +95,9 @@ func PutCache(key, value string) error {
 
 // GetCache gets an item from the cache bucket
 func GetCache(key string) (string, error) {
+	if db == nil {
+		return "", fmt.Errorf("DB not loaded")
+	}
 	var s []byte
 	err := db.View(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte("cache"))
@@ -82,3 +110,155 @@ func GetCache(key string) (string, error) {
 	}
 	return string(s), nil
 }
+
+// PutMetricsDefaults stores the metrics query defaults for a metric namespace
+func PutMetricsDefaults(namespace, value string) error {
+	if db == nil {
+		return fmt.Errorf("DB not loaded")
+	}
+	return db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte("metrics"))
+		err := b.Put([]byte(namespace), []byte(value))
+		return err
+	})
+}
+
+// GetMetricsDefaults gets the metrics query defaults for a metric namespace
+func GetMetricsDefaults(namespace string) (string, error) {
+	if db == nil {
+		return "", fmt.Errorf("DB not loaded")
+	}
+	var s []byte
+	err := db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte("metrics"))
+		v := b.Get([]byte(namespace))
+		s = v
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("Failed to find item: %v", err)
+	}
+	return string(s), nil
+}
+
+// PutMetricsDashboard stores a named metrics dashboard
+func PutMetricsDashboard(name, value string) error {
+	if db == nil {
+		return fmt.Errorf("DB not loaded")
+	}
+	return db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte("dashboards"))
+		err := b.Put([]byte(name), []byte(value))
+		return err
+	})
+}
+
+// GetMetricsDashboard gets a named metrics dashboard
+func GetMetricsDashboard(name string) (string, error) {
+	if db == nil {
+		return "", fmt.Errorf("DB not loaded")
+	}
+	var s []byte
+	err := db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte("dashboards"))
+		v := b.Get([]byte(name))
+		s = v
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("Failed to find item: %v", err)
+	}
+	return string(s), nil
+}
+
+// ListMetricsDashboards gets the names of the stored metrics dashboards
+func ListMetricsDashboards() ([]string, error) {
+	if db == nil {
+		return nil, fmt.Errorf("DB not loaded")
+	}
+	names := []string{}
+	err := db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte("dashboards"))
+		return b.ForEach(func(k, v []byte) error {
+			names = append(names, string(k))
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to list items: %v", err)
+	}
+	return names, nil
+}
+
+// PutQueryHistory stores the query history for a resource
+func PutQueryHistory(resourceID, value string) error {
+	if db == nil {
+		return fmt.Errorf("DB not loaded")
+	}
+	return db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte("queryhistory"))
+		err := b.Put([]byte(resourceID), []byte(value))
+		return err
+	})
+}
+
+// GetQueryHistory gets the query history for a resource
+func GetQueryHistory(resourceID string) (string, error) {
+	if db == nil {
+		return "", fmt.Errorf("DB not loaded")
+	}
+	var s []byte
+	err := db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte("queryhistory"))
+		v := b.Get([]byte(resourceID))
+		s = v
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("Failed to find item: %v", err)
+	}
+	return string(s), nil
+}
+
+// PutSwaggerSpecs stores the resource types loaded from a spec folder, keyed by the hash of the specs.
+// Entries for other hashes of the same folder are removed as they can no longer be used
+func PutSwaggerSpecs(folder, hash, value string) error {
+	if db == nil {
+		return fmt.Errorf("DB not loaded")
+	}
+	return db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte("swaggerspecs"))
+		prefix := []byte(folder + ":")
+		staleKeys := [][]byte{}
+		c := b.Cursor()
+		for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+			staleKeys = append(staleKeys, append([]byte{}, k...))
+		}
+		for _, k := range staleKeys {
+			err := b.Delete(k)
+			if err != nil {
+				return err
+			}
+		}
+		err := b.Put([]byte(folder+":"+hash), []byte(value))
+		return err
+	})
+}
+
+// GetSwaggerSpecs gets the resource types loaded from a spec folder for the hash of the specs
+func GetSwaggerSpecs(folder, hash string) (string, error) {
+	if db == nil {
+		return "", fmt.Errorf("DB not loaded")
+	}
+	var s []byte
+	err := db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte("swaggerspecs"))
+		v := b.Get([]byte(folder + ":" + hash))
+		s = v
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("Failed to find item: %v", err)
+	}
+	return string(s), nil
+}
@@ -0,0 +1,61 @@
+package storage
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/nbio/st"
+)
+
+func loadTestDB(t *testing.T) func() {
+	folder, err := ioutil.TempDir("", "azbrowse-db")
+	st.Expect(t, err, nil)
+	err = LoadDBFromPath(filepath.Join(folder, "test.db"))
+	st.Expect(t, err, nil)
+	return func() {
+		CloseDB()
+		db = nil
+		os.RemoveAll(folder) //nolint: errcheck
+	}
+}
+
+func Test_SwaggerSpecs(t *testing.T) {
+	defer loadTestDB(t)()
+
+	value, err := GetSwaggerSpecs("/specs", "hash1")
+	st.Expect(t, err, nil)
+	st.Expect(t, value, "")
+
+	err = PutSwaggerSpecs("/specs", "hash1", `[{"Display": "one"}]`)
+	st.Expect(t, err, nil)
+	err = PutSwaggerSpecs("/specs-other", "hash1", `[{"Display": "other"}]`)
+	st.Expect(t, err, nil)
+
+	value, err = GetSwaggerSpecs("/specs", "hash1")
+	st.Expect(t, err, nil)
+	st.Expect(t, value, `[{"Display": "one"}]`)
+
+	// storing a new hash for the folder removes the entry for the old hash
+	err = PutSwaggerSpecs("/specs", "hash2", `[{"Display": "two"}]`)
+	st.Expect(t, err, nil)
+	value, err = GetSwaggerSpecs("/specs", "hash1")
+	st.Expect(t, err, nil)
+	st.Expect(t, value, "")
+	value, err = GetSwaggerSpecs("/specs", "hash2")
+	st.Expect(t, err, nil)
+	st.Expect(t, value, `[{"Display": "two"}]`)
+
+	// other folders are unaffected
+	value, err = GetSwaggerSpecs("/specs-other", "hash1")
+	st.Expect(t, err, nil)
+	st.Expect(t, value, `[{"Display": "other"}]`)
+}
+
+func Test_SwaggerSpecs_DBNotLoaded(t *testing.T) {
+	_, err := GetSwaggerSpecs("/specs", "hash1")
+	st.Expect(t, err != nil, true)
+	err = PutSwaggerSpecs("/specs", "hash1", "[]")
+	st.Expect(t, err != nil, true)
+}
@@ -78,3 +78,10 @@ func Highlight(s string) string {
 func Graph(s string) string {
 	return color.New(color.FgBlue).Sprint(s)
 }
+
+var seriesColors = []color.Attribute{color.FgCyan, color.FgYellow, color.FgGreen, color.FgMagenta, color.FgRed, color.FgBlue, color.FgHiCyan, color.FgHiYellow, color.FgHiGreen, color.FgHiMagenta}
+
+// Series colours the text for the series at the index so that series in a graph can be told apart
+func Series(index int, s string) string {
+	return color.New(seriesColors[index%len(seriesColors)]).Sprint(s)
+}
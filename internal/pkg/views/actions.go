@@ -24,25 +24,35 @@ func LoadActionsView(ctx context.Context, list *ListWidget) error {
 	var namespace string
 	var armType string
 	currentItem := list.CurrentItem()
+	if currentItem == nil {
+		return nil
+	}
+
+	// Actions provided by the expanders (e.g. data-plane actions)
+	items, err := expanders.ListActions(ctx, currentItem)
+	if err != nil {
+		list.statusView.Status("Failed to get actions: "+err.Error(), false)
+	}
+
 	if currentItem.ItemType == expanders.ResourceType {
 		namespace = currentItem.Namespace
 		armType = currentItem.ArmType
 	}
 
 	currentExpandedItem := list.CurrentExpandedItem()
-	if currentExpandedItem == nil {
-		return nil
-	}
-	if currentExpandedItem.ItemType == expanders.ResourceType {
+	if currentExpandedItem != nil && currentExpandedItem.ItemType == expanders.ResourceType {
 		namespace = currentExpandedItem.Namespace
 		armType = currentExpandedItem.ArmType
 	}
 
 	if namespace == "" || armType == "" {
+		if len(items) > 0 {
+			list.SetNodes(items)
+		}
 		return nil
 	}
 
-	span, ctx := tracing.StartSpanFromContext(ctx, "actions:"+currentItem.Name, tracing.SetTag("item", currentItem))
+	span, ctx := tracing.StartSpanFromContext(expanders.WithNodeTenant(ctx, currentItem), "actions:"+currentItem.Name, tracing.SetTag("item", currentItem))
 	defer span.Finish()
 
 	data, err := armclient.LegacyInstance.DoRequest(ctx, "GET", "/providers/Microsoft.Authorization/providerOperations/"+namespace+"?api-version=2018-01-01-preview&$expand=resourceTypes")
@@ -55,7 +65,6 @@ func LoadActionsView(ctx context.Context, list *ListWidget) error {
 		panic(err)
 	}
 
-	items := []*expanders.TreeNode{}
 	for _, resOps := range opsRequest.ResourceTypes {
 		if resOps.Name == strings.Split(armType, "/")[1] {
 			for _, op := range resOps.Operations {
@@ -72,11 +81,12 @@ func LoadActionsView(ctx context.Context, list *ListWidget) error {
 					ExpandReturnType: expanders.ActionType,
 					ItemType:         "action",
 					ID:               currentItem.ID + "/" + actionURL,
+					TenantID:         currentItem.TenantID,
 				})
 			}
 		}
 	}
-	if len(items) > 1 {
+	if len(items) > 0 {
 		list.SetNodes(items)
 	}
 
@@ -0,0 +1,67 @@
+package views
+
+import (
+	"context"
+	"time"
+
+	"github.com/lawrencegripper/azbrowse/internal/pkg/errorhandling"
+	"github.com/lawrencegripper/azbrowse/internal/pkg/expanders"
+)
+
+// StartMetricsDashboardRefresh redraws the metrics dashboard shown in the item widget
+// each time the dashboard's refresh interval elapses
+func StartMetricsDashboardRefresh(ctx context.Context, content *ItemWidget) {
+	go func() {
+		// recover from panic, if one occurrs, and leave terminal usable
+		defer errorhandling.RecoveryWithCleanup()
+
+		var lastNode *expanders.TreeNode
+		var lastRefresh time.Time
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(time.Second * 5):
+			}
+
+			node := content.GetNode()
+			if !expanders.IsMetricsDashboard(node) {
+				lastNode = nil
+				continue
+			}
+			if node != lastNode {
+				// the dashboard has just been drawn
+				lastNode = node
+				lastRefresh = time.Now()
+				continue
+			}
+			if time.Since(lastRefresh) < expanders.GetMetricsDashboardRefresh(node) {
+				continue
+			}
+			lastRefresh = time.Now()
+
+			newContent, _, err := expanders.ExpandItem(ctx, node)
+			if err != nil { // Don't need to display error as expander emits status event on error
+				continue
+			}
+			// Don't overwrite the item view if the user has moved on
+			if content.GetNode() == node {
+				content.SetContent(node, newContent.Response, newContent.ResponseType, node.Name)
+			}
+		}
+	}()
+}
+
+// ShowMetricsDashboard draws the named metrics dashboard in the item widget
+func ShowMetricsDashboard(ctx context.Context, content *ItemWidget, name string) error {
+	node, err := expanders.NewMetricsDashboardNode(name)
+	if err != nil {
+		return err
+	}
+	newContent, _, err := expanders.ExpandItem(ctx, node)
+	if err != nil {
+		return err
+	}
+	content.SetContent(node, newContent.Response, newContent.ResponseType, node.Name)
+	return nil
+}
@@ -31,6 +31,14 @@ func getNameAndType(s string) (NameAndNodeType, bool) {
 
 // StripSecretVals removes secret values
 func StripSecretVals(s string) string {
+	// key vault secret values are identified by the secret id so must run before the id is hidden
+	// (the vault DNS suffix differs between clouds)
+	keyVaultSecretRegex := regexp.MustCompile(`"id":\s*"https://[^"/]+\.vault\.(?:azure\.net|azure\.cn|usgovcloudapi\.net|microsoftazure\.de)(?::443)?/(?:deleted)?secrets/`)
+	if keyVaultSecretRegex.MatchString(s) {
+		valueRegex := regexp.MustCompile(`"value":\s*"(?:\\.|[^"\\])*"`)
+		s = valueRegex.ReplaceAllString(s, `"value": "HIDDEN"`)
+	}
+
 	guidRegex := regexp.MustCompile(`[{(]?[0-9a-f]{8}[-]?([0-9a-f]{4}[-]?){3}[0-9a-f]{12}[)}]?`)
 	s = guidRegex.ReplaceAllString(s, "00000000-0000-0000-0000-HIDDEN000000")
 
@@ -73,7 +81,7 @@ func StripSecretVals(s string) string {
 	if nt, ok := getNameAndType(s); ok {
 		switch nt {
 		case globalConnectionStringsConfig:
-			valueRegex := regexp.MustCompile(`"value":\s*".+?(?:\\"|[^"])*"`)
+			valueRegex := regexp.MustCompile(`"value":\s*"(?:\\.|[^"\\])*"`)
 			s = valueRegex.ReplaceAllString(s, `"value": "HIDDEN"`)
 		}
 	}
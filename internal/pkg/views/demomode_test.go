@@ -31,6 +31,122 @@ var successfullyReplaceSecretsTestData = []struct {
 	input    string
 	expected string
 }{
+	{
+		desc: "key vault secret",
+		input: `
+		{
+			"value": "my-secret-value",
+			"id": "https://myvault.vault.azure.net/secrets/mysecret/4387e9f3d6e14c459867679a90fd0f79",
+			"attributes": {
+				"enabled": true
+			}
+		}`,
+		expected: `
+		{
+			"value": "HIDDEN",
+			"id": "HIDDEN",
+			"attributes": {
+				"enabled": true
+			}
+		}`,
+	},
+	{
+		desc: "key vault secret with empty value",
+		input: `
+		{
+			"value": "",
+			"contentType": "text/plain",
+			"id": "https://myvault.vault.azure.net/secrets/mysecret/4387e9f3d6e14c459867679a90fd0f79"
+		}`,
+		expected: `
+		{
+			"value": "HIDDEN",
+			"contentType": "text/plain",
+			"id": "HIDDEN"
+		}`,
+	},
+	{
+		desc: "key vault secret with escaped quotes",
+		input: `
+		{
+			"value": "{\"password\": \"my-secret-value\"}\\",
+			"contentType": "application/json",
+			"id": "https://myvault.vault.azure.net/secrets/mysecret/4387e9f3d6e14c459867679a90fd0f79"
+		}`,
+		expected: `
+		{
+			"value": "HIDDEN",
+			"contentType": "application/json",
+			"id": "HIDDEN"
+		}`,
+	},
+	{
+		desc: "key vault secret with value after id",
+		input: `
+		{
+			"id": "https://myvault.vault.azure.net/secrets/mysecret/4387e9f3d6e14c459867679a90fd0f79",
+			"value": "my-secret-value"
+		}`,
+		expected: `
+		{
+			"id": "HIDDEN",
+			"value": "HIDDEN"
+		}`,
+	},
+	{
+		desc: "key vault secret in azure china",
+		input: `
+		{
+			"value": "my-secret-value",
+			"id": "https://myvault.vault.azure.cn/secrets/mysecret/4387e9f3d6e14c459867679a90fd0f79"
+		}`,
+		expected: `
+		{
+			"value": "HIDDEN",
+			"id": "HIDDEN"
+		}`,
+	},
+	{
+		desc: "key vault secret in azure us government",
+		input: `
+		{
+			"value": "my-secret-value",
+			"id": "https://myvault.vault.usgovcloudapi.net:443/secrets/mysecret/4387e9f3d6e14c459867679a90fd0f79"
+		}`,
+		expected: `
+		{
+			"value": "HIDDEN",
+			"id": "HIDDEN"
+		}`,
+	},
+	{
+		desc: "key vault deleted secret",
+		input: `
+		{
+			"value": "my-secret-value",
+			"id": "https://myvault.vault.azure.net/deletedsecrets/mysecret",
+			"scheduledPurgeDate": 1593453453
+		}`,
+		expected: `
+		{
+			"value": "HIDDEN",
+			"id": "HIDDEN",
+			"scheduledPurgeDate": 1593453453
+		}`,
+	},
+	{
+		desc: "value that isn't a key vault secret",
+		input: `
+		{
+			"value": "public-value",
+			"id": "https://example.com/secrets/mysecret"
+		}`,
+		expected: `
+		{
+			"value": "public-value",
+			"id": "HIDDEN"
+		}`,
+	},
 	{
 		desc: "multi-node",
 		input: `
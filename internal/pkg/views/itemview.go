@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/lawrencegripper/azbrowse/internal/pkg/eventing"
 	"github.com/lawrencegripper/azbrowse/internal/pkg/expanders"
@@ -163,6 +164,41 @@ func (w *ItemWidget) SetContent(node *expanders.TreeNode, content string, conten
 	})
 }
 
+// SetStreamedContent displays the plain text content in the itemview without resetting the scroll position
+// so that streamed content can be updated in place. If scrollToEnd is set the last lines of content are shown
+func (w *ItemWidget) SetStreamedContent(node *expanders.TreeNode, content string, title string, scrollToEnd bool) {
+	w.g.Update(func(g *gocui.Gui) error {
+		w.node = node
+		w.content = content
+		w.contentType = expanders.ResponsePlainText
+		w.view.Title = title
+		if scrollToEnd {
+			width, height := w.view.Size()
+			y := countWrappedLines(content, width) - height
+			if y < 0 {
+				y = 0
+			}
+			w.view.SetCursor(0, 0) //nolint: errcheck
+			w.view.SetOrigin(0, y) //nolint: errcheck
+		}
+		return nil
+	})
+}
+
+// countWrappedLines returns the number of lines the content takes up when wrapped to the width
+func countWrappedLines(content string, width int) int {
+	count := 0
+	for _, line := range strings.Split(content, "\n") {
+		length := utf8.RuneCountInString(line)
+		if width <= 0 || length <= width {
+			count++
+			continue
+		}
+		count += (length + width - 1) / width
+	}
+	return count
+}
+
 // GetContent returns the current content
 func (w *ItemWidget) GetContent() string {
 	return w.content
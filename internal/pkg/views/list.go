@@ -228,7 +228,7 @@ func (w *ListWidget) GoBack() {
 // ExpandCurrentSelection opens the resource Sub->RG for example
 func (w *ListWidget) ExpandCurrentSelection() {
 
-	if w.title == "Subscriptions" {
+	if w.title == "Subscriptions" || w.title == "Tenants" {
 		w.title = ""
 	}
 
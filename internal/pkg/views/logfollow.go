@@ -0,0 +1,230 @@
+package views
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lawrencegripper/azbrowse/internal/pkg/errorhandling"
+	"github.com/lawrencegripper/azbrowse/internal/pkg/expanders"
+)
+
+const (
+	// maxFollowedLogLines limits the number of log lines kept while following logs
+	maxFollowedLogLines = 5000
+	// logFollowRedrawInterval limits how often the item view is redrawn as log lines arrive
+	logFollowRedrawInterval = time.Millisecond * 250
+)
+
+// LogFollower streams the logs for a container into the item widget
+type LogFollower struct {
+	content *ItemWidget
+	mutex   sync.Mutex
+	node    *expanders.TreeNode
+	cancel  context.CancelFunc
+	logs    *logBuffer
+	status  string
+}
+
+// NewLogFollower creates a LogFollower which writes to the item widget
+func NewLogFollower(content *ItemWidget) *LogFollower {
+	return &LogFollower{content: content}
+}
+
+// IsFollowing returns true if logs are being streamed into the item widget
+func (f *LogFollower) IsFollowing() bool {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.node != nil && f.content.GetNode() == f.node
+}
+
+// IsPaused returns true if the item widget isn't being updated as log lines arrive
+func (f *LogFollower) IsPaused() bool {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.logs != nil && f.logs.paused
+}
+
+// Filter returns the text that log lines must contain to be shown
+func (f *LogFollower) Filter() string {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if f.logs == nil {
+		return ""
+	}
+	return f.logs.filter
+}
+
+// Start streams the logs for the node into the item widget until the user moves to another item
+func (f *LogFollower) Start(ctx context.Context, node *expanders.TreeNode, options expanders.LogFollowOptions) {
+	f.Stop()
+
+	ctx, cancel := context.WithCancel(ctx)
+	f.mutex.Lock()
+	f.node = node
+	f.cancel = cancel
+	f.logs = &logBuffer{maxLines: maxFollowedLogLines}
+	f.status = "following"
+	if options.Previous {
+		f.status = "previous instance"
+	}
+	f.mutex.Unlock()
+	f.redraw(node)
+
+	lines := make(chan string, 100)
+	go func() {
+		// recover from panic, if one occurrs, and leave terminal usable
+		defer errorhandling.RecoveryWithCleanup()
+
+		err := expanders.FollowLogs(ctx, node, options, lines)
+		f.mutex.Lock()
+		if f.node == node {
+			if err != nil {
+				f.status = "ended: " + err.Error()
+			} else if !options.Previous {
+				f.status = "ended"
+			}
+		}
+		f.mutex.Unlock()
+		close(lines)
+	}()
+
+	go func() {
+		// recover from panic, if one occurrs, and leave terminal usable
+		defer errorhandling.RecoveryWithCleanup()
+		defer cancel()
+
+		changed := false
+		ticker := time.NewTicker(logFollowRedrawInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case line, ok := <-lines:
+				if !ok {
+					f.redraw(node)
+					return
+				}
+				f.mutex.Lock()
+				f.logs.add(line)
+				f.mutex.Unlock()
+				changed = true
+			case <-ticker.C:
+				// Stop following once the user has moved on
+				if f.content.GetNode() != node {
+					return
+				}
+				if changed && !f.IsPaused() {
+					f.redraw(node)
+					changed = false
+				}
+			}
+		}
+	}()
+}
+
+// Stop ends the current log stream
+func (f *LogFollower) Stop() {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if f.cancel != nil {
+		f.cancel()
+	}
+	f.node = nil
+	f.cancel = nil
+}
+
+// SetPaused stops or resumes updating the item widget as log lines arrive.
+// Lines received while paused are shown when resumed
+func (f *LogFollower) SetPaused(paused bool) {
+	f.mutex.Lock()
+	node := f.node
+	if f.logs != nil {
+		f.logs.setPaused(paused)
+	}
+	f.mutex.Unlock()
+	if node != nil {
+		f.redraw(node)
+	}
+}
+
+// SetFilter only shows log lines containing the text (case insensitive). An empty filter shows all lines
+func (f *LogFollower) SetFilter(filter string) {
+	f.mutex.Lock()
+	node := f.node
+	if f.logs != nil {
+		f.logs.filter = strings.TrimSpace(filter)
+	}
+	f.mutex.Unlock()
+	if node != nil {
+		f.redraw(node)
+	}
+}
+
+// redraw shows the log lines matching the filter, scrolling to the latest lines unless paused
+func (f *LogFollower) redraw(node *expanders.TreeNode) {
+	f.mutex.Lock()
+	if f.node != node || f.logs == nil {
+		f.mutex.Unlock()
+		return
+	}
+	status := f.status
+	if f.logs.paused {
+		status = "paused"
+	}
+	scrollToEnd := !f.logs.paused
+	filter := f.logs.filter
+	text := f.logs.text()
+	f.mutex.Unlock()
+
+	title := node.Name + " (" + status
+	if filter != "" {
+		title += ", filter: " + filter
+	}
+	title += ")"
+	f.content.SetStreamedContent(node, text, title, scrollToEnd)
+}
+
+// logBuffer holds the most recent log lines and applies the filter when they are displayed
+type logBuffer struct {
+	lines    []string
+	maxLines int
+	paused   bool
+	// pausedLines are the lines received before pausing, which are shown while paused
+	// so that the user can scroll through them
+	pausedLines []string
+	filter      string
+}
+
+func (b *logBuffer) setPaused(paused bool) {
+	b.paused = paused
+	b.pausedLines = nil
+	if paused {
+		b.pausedLines = append([]string{}, b.lines...)
+	}
+}
+
+func (b *logBuffer) add(line string) {
+	b.lines = append(b.lines, line)
+	if b.maxLines > 0 && len(b.lines) > b.maxLines {
+		b.lines = b.lines[len(b.lines)-b.maxLines:]
+	}
+}
+
+func (b *logBuffer) text() string {
+	lines := b.lines
+	if b.paused {
+		lines = b.pausedLines
+	}
+	if b.filter == "" {
+		return strings.Join(lines, "\n")
+	}
+	filter := strings.ToLower(b.filter)
+	matches := []string{}
+	for _, line := range lines {
+		if strings.Contains(strings.ToLower(line), filter) {
+			matches = append(matches, line)
+		}
+	}
+	return strings.Join(matches, "\n")
+}
@@ -0,0 +1,46 @@
+package views
+
+import (
+	"testing"
+)
+
+func Test_LogBuffer_FilterIsCaseInsensitive(t *testing.T) {
+	logs := &logBuffer{}
+	logs.add("INFO started")
+	logs.add("ERROR failed to connect")
+	logs.add("info retrying")
+
+	logs.filter = "info"
+
+	expected := "INFO started\ninfo retrying"
+	if text := logs.text(); text != expected {
+		t.Errorf("Expected %q, got %q", expected, text)
+	}
+}
+
+func Test_LogBuffer_PausedShowsLinesReceivedBeforePausing(t *testing.T) {
+	logs := &logBuffer{}
+	logs.add("line 1")
+	logs.setPaused(true)
+	logs.add("line 2")
+
+	if text := logs.text(); text != "line 1" {
+		t.Errorf("Expected only the line received before pausing, got %q", text)
+	}
+
+	logs.setPaused(false)
+	if text := logs.text(); text != "line 1\nline 2" {
+		t.Errorf("Expected all lines after resuming, got %q", text)
+	}
+}
+
+func Test_LogBuffer_KeepsMostRecentLines(t *testing.T) {
+	logs := &logBuffer{maxLines: 2}
+	logs.add("line 1")
+	logs.add("line 2")
+	logs.add("line 3")
+
+	if text := logs.text(); text != "line 2\nline 3" {
+		t.Errorf("Expected the last two lines, got %q", text)
+	}
+}
@@ -25,6 +25,7 @@ type NotificationWidget struct {
 	x, y                          int
 	w                             int
 	pendingDeletes                []*expanders.TreeNode
+	pendingActions                []*expanders.TreeNode
 	toastNotifications            map[string]*eventing.StatusEvent
 	deleteMutex                   sync.Mutex // ensure delete occurs only once
 	deleteInProgress              bool
@@ -44,7 +45,7 @@ func (w *NotificationWidget) AddPendingDelete(item *expanders.TreeNode) {
 		return
 	}
 
-	if item.DeleteURL == "" {
+	if item.DeleteURL == "" && !expanders.DeleteHandledByExpander(item) {
 		eventing.SendStatusEvent(&eventing.StatusEvent{
 			Failure: true,
 			Message: "Item `" + item.Name + "` doesn't support delete",
@@ -69,7 +70,7 @@ func (w *NotificationWidget) AddPendingDelete(item *expanders.TreeNode) {
 	defer w.deleteMutex.Unlock()
 
 	for _, i := range w.pendingDeletes {
-		if i.DeleteURL == item.DeleteURL {
+		if i.DeleteURL == item.DeleteURL && i.ID == item.ID {
 			eventing.SendStatusEvent(&eventing.StatusEvent{
 				Failure: true,
 				Message: "Item already `" + item.Name + "` in pending delete list",
@@ -82,7 +83,35 @@ func (w *NotificationWidget) AddPendingDelete(item *expanders.TreeNode) {
 	w.pendingDeletes = append(w.pendingDeletes, item)
 }
 
-// ConfirmDelete delete all queued/pending deletes
+// AddPendingAction queues an action to run once confirmed
+func (w *NotificationWidget) AddPendingAction(item *expanders.TreeNode) {
+	if w.deleteInProgress {
+		eventing.SendStatusEvent(&eventing.StatusEvent{
+			Failure: true,
+			Message: "Delete or action already in progress. Please wait for completion.",
+			Timeout: time.Second * 5,
+		})
+		return
+	}
+
+	w.deleteMutex.Lock()
+	defer w.deleteMutex.Unlock()
+
+	for _, i := range w.pendingActions {
+		if i.ID == item.ID {
+			eventing.SendStatusEvent(&eventing.StatusEvent{
+				Failure: true,
+				Message: "Action `" + item.Name + "` already pending",
+				Timeout: time.Second * 5,
+			})
+			return
+		}
+	}
+
+	w.pendingActions = append(w.pendingActions, item)
+}
+
+// ConfirmDelete delete all queued/pending deletes and then run any pending actions
 func (w *NotificationWidget) ConfirmDelete() {
 	if w.deleteInProgress {
 		eventing.SendStatusEvent(&eventing.StatusEvent{
@@ -96,9 +125,11 @@ func (w *NotificationWidget) ConfirmDelete() {
 	w.deleteMutex.Lock()
 	w.deleteInProgress = true
 
-	// Take a copy of the current pending deletes
+	// Take a copy of the current pending deletes and actions
 	pending := make([]*expanders.TreeNode, len(w.pendingDeletes))
 	copy(pending, w.pendingDeletes)
+	pendingActions := make([]*expanders.TreeNode, len(w.pendingActions))
+	copy(pendingActions, w.pendingActions)
 
 	w.deleteMutex.Unlock()
 
@@ -111,6 +142,11 @@ func (w *NotificationWidget) ConfirmDelete() {
 			w.deleteInProgress = false
 		}()
 
+		if len(pending) < 1 {
+			w.runPendingActions(pendingActions)
+			return
+		}
+
 		event, _ := eventing.SendStatusEvent(&eventing.StatusEvent{
 			InProgress: true,
 			Message:    "Starting to delete items",
@@ -125,14 +161,21 @@ func (w *NotificationWidget) ConfirmDelete() {
 
 		for _, i := range pending {
 			var err error
-			fallback := true
+			deleted := false
+			itemCtx := expanders.WithNodeTenant(ctx, i)
 			if i.Expander != nil {
-				deleted, err := i.Expander.Delete(ctx, i)
-				fallback = (err == nil && !deleted)
+				deleted, err = i.Expander.Delete(itemCtx, i)
 			}
-			if fallback {
-				// fallback to ARM request to delete
-				_, err = w.client.DoRequest(ctx, "DELETE", i.DeleteURL)
+			if err == nil && !deleted {
+				switch {
+				case expanders.DeleteHandledByExpander(i):
+					err = fmt.Errorf("the item wasn't deleted by its expander")
+				case !armclient.IsARMRequestURL(i.DeleteURL):
+					err = fmt.Errorf("'%s' isn't an ARM resource so can't be deleted through ARM", i.DeleteURL)
+				default:
+					// fallback to ARM request to delete
+					_, err = w.client.DoRequest(itemCtx, "DELETE", i.DeleteURL)
+				}
 			}
 			if err != nil {
 				event.Failure = true
@@ -141,10 +184,11 @@ func (w *NotificationWidget) ConfirmDelete() {
 				event.Update()
 
 				w.pendingDeletes = []*expanders.TreeNode{}
+				w.pendingActions = []*expanders.TreeNode{}
 				// In the event that a delete fails in the
 				// batch of pending deletes lets give up on the rest
-				// as something might have gone wrong and best
-				// to be cautious
+				// (and any pending actions) as something might have
+				// gone wrong and best to be cautious
 				return
 			}
 
@@ -158,9 +202,47 @@ func (w *NotificationWidget) ConfirmDelete() {
 		event.Update()
 
 		w.pendingDeletes = []*expanders.TreeNode{}
+
+		w.runPendingActions(pendingActions)
 	}()
 }
 
+// runPendingActions runs the confirmed actions in order, stopping at the first failure
+func (w *NotificationWidget) runPendingActions(pending []*expanders.TreeNode) {
+	if len(pending) < 1 {
+		return
+	}
+
+	event, _ := eventing.SendStatusEvent(&eventing.StatusEvent{
+		InProgress: true,
+		Message:    "Starting to run actions",
+		Timeout:    time.Second * 15,
+	})
+
+	for _, i := range pending {
+		// each action is run with its own timeout (see expanders.ConfirmedActionTimeoutProvider)
+		err := expanders.RunConfirmedAction(context.Background(), i)
+		if err != nil {
+			event.Failure = true
+			event.InProgress = false
+			event.Message = "Failed to run `" + i.Name + "` with error:" + err.Error()
+			event.Update()
+
+			w.pendingActions = []*expanders.TreeNode{}
+			return
+		}
+
+		event.Message = "Completed: " + i.Name
+		event.Update()
+	}
+
+	event.InProgress = false
+	event.SetTimeout(time.Second * 2)
+	event.Update()
+
+	w.pendingActions = []*expanders.TreeNode{}
+}
+
 // ClearPendingDeletes removes all pending deletes
 func (w *NotificationWidget) ClearPendingDeletes() {
 	w.deleteMutex.Lock()
@@ -173,6 +255,7 @@ func (w *NotificationWidget) ClearPendingDeletes() {
 		})
 
 		w.pendingDeletes = []*expanders.TreeNode{}
+		w.pendingActions = []*expanders.TreeNode{}
 		w.deleteMutex.Unlock()
 		done()
 
@@ -189,11 +272,13 @@ func NewNotificationWidget(x, y, w int, g *gocui.Gui, client *armclient.Client)
 		w:                  w,
 		gui:                g,
 		pendingDeletes:     []*expanders.TreeNode{},
+		pendingActions:     []*expanders.TreeNode{},
 		toastNotifications: map[string]*eventing.StatusEvent{},
 		client:             client,
 	}
 
 	newEvents := eventing.SubscribeToStatusEvents()
+	newActions := eventing.SubscribeToTopic(expanders.PendingActionTopic)
 	// Start loop for showing loading in statusbar
 	go func() {
 		// recover from panic, if one occurrs, and leave terminal usable
@@ -208,6 +293,8 @@ func NewNotificationWidget(x, y, w int, g *gocui.Gui, client *armclient.Client)
 				if eventObj.IsToast {
 					widget.toastNotifications[eventObj.ID()] = eventObj
 				}
+			case actionObjRaw := <-newActions:
+				widget.AddPendingAction(actionObjRaw.(*expanders.TreeNode))
 			case <-timeout:
 				// Update the UI
 			}
@@ -228,17 +315,20 @@ func NewNotificationWidget(x, y, w int, g *gocui.Gui, client *armclient.Client)
 
 // Layout draws the widget in the gocui view
 func (w *NotificationWidget) Layout(g *gocui.Gui) error {
-	// Don't draw anything if no pending deletes
-	if len(w.pendingDeletes) < 1 && len(w.toastNotifications) < 1 {
+	// Don't draw anything if no pending deletes or actions
+	if len(w.pendingDeletes) < 1 && len(w.pendingActions) < 1 && len(w.toastNotifications) < 1 {
 		g.DeleteView(w.name)
 		return nil
 	}
 
-	height := len(w.pendingDeletes) + len(w.toastNotifications)
+	height := len(w.pendingDeletes) + len(w.pendingActions) + len(w.toastNotifications)
 	if len(w.pendingDeletes) > 0 {
 		// Add padding for extra lines
 		height = height + 7
 	}
+	if len(w.pendingActions) > 0 {
+		height = height + 7
+	}
 	if len(w.toastNotifications) > 0 {
 		height = height + 3
 	}
@@ -257,6 +347,7 @@ func (w *NotificationWidget) Layout(g *gocui.Gui) error {
 
 func (w *NotificationWidget) layoutInternal(v io.Writer) error {
 	pending := w.pendingDeletes
+	pendingActions := w.pendingActions
 	toasts := w.toastNotifications
 
 	if len(toasts) > 0 {
@@ -278,5 +369,19 @@ func (w *NotificationWidget) layoutInternal(v io.Writer) error {
 		fmt.Fprintln(v, style.Highlight("Press "+strings.ToUpper(w.ClearPendingDeletesKeyBinding)+" to CANCEL"))
 	}
 
+	if len(pendingActions) > 0 {
+		if len(pending) > 0 {
+			fmt.Fprintln(v, "")
+		}
+		fmt.Fprintln(v, style.Title("Pending Actions:"))
+		for _, i := range pendingActions {
+			fmt.Fprintln(v, " - "+i.Name)
+		}
+		fmt.Fprintln(v, "")
+		fmt.Fprintln(v, "Do you want to run these actions?")
+		fmt.Fprintln(v, style.Warning("Press "+strings.ToUpper(w.ConfirmDeleteKeyBinding)+" to RUN"))
+		fmt.Fprintln(v, style.Highlight("Press "+strings.ToUpper(w.ClearPendingDeletesKeyBinding)+" to CANCEL"))
+	}
+
 	return nil
 }
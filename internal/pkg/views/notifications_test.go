@@ -1,6 +1,8 @@
 package views
 
 import (
+	"context"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -54,6 +56,40 @@ func Test_Delete_AddPendingDelete(t *testing.T) {
 	}
 }
 
+func Test_Action_AddPendingAction(t *testing.T) {
+	if testing.Short() {
+		t.Log("Skipping integration test")
+		return
+	}
+	g, err := gocui.NewGui(gocui.Output256)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	defer g.Close()
+
+	notView := NewNotificationWidget(0, 0, 47, g, nil)
+	g.SetManager(notView)
+
+	notView.AddPendingAction(&expanders.TreeNode{ID: "a1", Name: "Cordon node n1"})
+	notView.AddPendingAction(&expanders.TreeNode{ID: "a1", Name: "Cordon node n1"})
+
+	builder := &strings.Builder{}
+	err = notView.layoutInternal(builder)
+	if err != nil {
+		t.Error(err)
+	}
+
+	viewResult := builder.String()
+	if strings.Count(viewResult, "Cordon node n1") != 1 {
+		t.Error("Expected action to be listed once")
+	}
+
+	if !strings.Contains(viewResult, "Do you want to run these actions?") {
+		t.Error("Missing action message")
+	}
+}
+
 func Test_Delete_MessageSent(t *testing.T) {
 	if testing.Short() {
 		t.Log("Skipping integration test")
@@ -140,6 +176,57 @@ func Test_Delete_StopAfterFailure(t *testing.T) {
 	}
 }
 
+// failingDeleteExpander fails every delete, other Expander methods aren't used by the tests
+type failingDeleteExpander struct {
+	expanders.Expander
+}
+
+func (e *failingDeleteExpander) Delete(ctx context.Context, item *expanders.TreeNode) (bool, error) {
+	return false, errors.New("simulated delete failure")
+}
+
+func Test_Delete_ExpanderFailureReported(t *testing.T) {
+	if testing.Short() {
+		t.Log("Skipping integration test")
+		return
+	}
+	statusEvents := eventing.SubscribeToStatusEvents()
+	defer eventing.Unsubscribe(statusEvents)
+
+	count := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Logf("SEVER MESSAGE: received: %s method: %s", r.URL.String(), r.Method)
+		count = count + 1
+	}))
+	defer ts.Close()
+
+	client := armclient.NewClientFromConfig(ts.Client(), dummyTokenFunc(), 5000)
+
+	g, err := gocui.NewGui(gocui.Output256)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	defer g.Close()
+	notView := NewNotificationWidget(0, 0, 45, g, client)
+
+	notView.AddPendingDelete(&expanders.TreeNode{
+		Name:      "rg1",
+		DeleteURL: ts.URL + "/subscriptions/1/resourceGroups/rg1",
+		Expander:  &failingDeleteExpander{},
+	})
+	notView.ConfirmDelete()
+
+	// ConfirmDelete returns before it's finished
+	failureStatus := eventing.WaitForFailureStatusEvent(t, statusEvents, 5)
+	if !strings.Contains(failureStatus.Message, "simulated delete failure") {
+		t.Errorf("Expected the expander's delete error to be reported. Got: %s", failureStatus.Message)
+	}
+	if count != 0 {
+		t.Error("Expected no fallback delete to be sent after the expander failed")
+	}
+}
+
 func Test_Delete_AddPendingWhileDeleteInProgressRefused(t *testing.T) {
 	if testing.Short() {
 		t.Log("Skipping integration test")
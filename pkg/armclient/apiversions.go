@@ -0,0 +1,187 @@
+package armclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lawrencegripper/azbrowse/internal/pkg/storage"
+	"github.com/lawrencegripper/azbrowse/internal/pkg/tracing"
+)
+
+// providerCacheKey is the cache key for the api-versions. Data cached by earlier versions has no Updated time
+// so is treated as expired and refreshed
+const providerCacheKey = "providerCache"
+
+// ProviderCacheTTL is how long the api-versions read from the providers API are cached for before they are refreshed
+const ProviderCacheTTL = 24 * time.Hour
+
+// providerCache is the cached api-versions for each resource type (keyed on the lower-cased type)
+type providerCache struct {
+	Updated     time.Time           `json:"updated"`
+	APIVersions map[string][]string `json:"apiVersions"`
+}
+
+var (
+	resourceAPIVersionLock      sync.RWMutex
+	resourceAPIVersionLookup    map[string][]string
+	resourceAPIVersionOverrides = map[string]string{}
+)
+
+// GetAPIVersion returns the API version to use for a resource. This is the version selected by the user
+// (see SetAPIVersionOverride) or the most recent non-preview version
+func GetAPIVersion(armType string) (string, error) {
+	resourceAPIVersionLock.RLock()
+	defer resourceAPIVersionLock.RUnlock()
+
+	key := strings.ToLower(armType)
+	if override, exists := resourceAPIVersionOverrides[key]; exists {
+		return override, nil
+	}
+	apiVersions, exists := resourceAPIVersionLookup[key]
+	if !exists || len(apiVersions) == 0 {
+		return "MISSING", fmt.Errorf("API not found for the resource: %s", armType)
+	}
+	for _, apiVersion := range apiVersions {
+		if !IsPreviewAPIVersion(apiVersion) {
+			return apiVersion, nil
+		}
+	}
+	// only preview versions are available
+	return apiVersions[0], nil
+}
+
+// GetAPIVersions returns all the API versions available for a resource, most recent first
+func GetAPIVersions(armType string) ([]string, error) {
+	resourceAPIVersionLock.RLock()
+	defer resourceAPIVersionLock.RUnlock()
+
+	apiVersions, exists := resourceAPIVersionLookup[strings.ToLower(armType)]
+	if !exists {
+		return []string{}, fmt.Errorf("API not found for the resource: %s", armType)
+	}
+	return append([]string{}, apiVersions...), nil
+}
+
+// GetAPIVersionOverride returns the API version selected by the user for a resource type, or "" if none is selected
+func GetAPIVersionOverride(armType string) string {
+	resourceAPIVersionLock.RLock()
+	defer resourceAPIVersionLock.RUnlock()
+	return resourceAPIVersionOverrides[strings.ToLower(armType)]
+}
+
+// SetAPIVersionOverride sets the API version to use for a resource type in place of the default.
+// Pass an empty apiVersion to go back to the default
+func SetAPIVersionOverride(armType string, apiVersion string) {
+	resourceAPIVersionLock.Lock()
+	defer resourceAPIVersionLock.Unlock()
+	if apiVersion == "" {
+		delete(resourceAPIVersionOverrides, strings.ToLower(armType))
+		return
+	}
+	resourceAPIVersionOverrides[strings.ToLower(armType)] = apiVersion
+}
+
+// SetAPIVersionOverrides replaces the API versions selected by the user with the map of resource type to API version
+func SetAPIVersionOverrides(overrides map[string]string) {
+	resourceAPIVersionLock.Lock()
+	defer resourceAPIVersionLock.Unlock()
+	resourceAPIVersionOverrides = map[string]string{}
+	for armType, apiVersion := range overrides {
+		resourceAPIVersionOverrides[strings.ToLower(armType)] = apiVersion
+	}
+}
+
+// IsPreviewAPIVersion checks if the API version is a preview (or beta/alpha) version, i.e. has a suffix after the date
+func IsPreviewAPIVersion(apiVersion string) bool {
+	return strings.Contains(apiVersion, "preview") || strings.Count(apiVersion, "-") > 2
+}
+
+// PopulateResourceAPILookup is used to build a cache of resourcetypes -> api versions
+// this is needed when requesting details from a resource as APIVersion isn't known and is required.
+// The cache is refreshed from the providers API once it is older than ProviderCacheTTL
+func (c *Client) PopulateResourceAPILookup(ctx context.Context) {
+	resourceAPIVersionLock.RLock()
+	populated := resourceAPIVersionLookup != nil
+	resourceAPIVersionLock.RUnlock()
+	if populated {
+		return
+	}
+
+	span, ctx := tracing.StartSpanFromContext(ctx, "populateResCache")
+	defer span.Finish()
+
+	// Get data from cache
+	cache, err := loadProviderCache()
+	if err == nil && time.Since(cache.Updated) < ProviderCacheTTL {
+		span.SetTag("Data read from cache", true)
+		setResourceAPIVersionLookup(cache.APIVersions)
+		return
+	}
+	span.SetTag("error: failed getting cached data", err)
+
+	err = c.RefreshResourceAPILookup(ctx)
+	if err != nil {
+		if cache.APIVersions != nil {
+			// Use the expired data rather than failing
+			span.SetTag("error: failed refreshing data", err)
+			setResourceAPIVersionLookup(cache.APIVersions)
+			return
+		}
+		panic(err)
+	}
+}
+
+// RefreshResourceAPILookup gets the api versions for each resource type from the providers API and updates the cache
+func (c *Client) RefreshResourceAPILookup(ctx context.Context) error {
+	data, err := c.DoRequest(ctx, "GET", "/providers?api-version=2017-05-10")
+	if err != nil {
+		return fmt.Errorf("Failed to get providers: %s", err)
+	}
+	var providerResponse ProvidersResponse
+	err = json.Unmarshal([]byte(data), &providerResponse)
+	if err != nil {
+		return fmt.Errorf("Error unmarshalling providers response: %s", err)
+	}
+
+	lookup := map[string][]string{}
+	for _, provider := range providerResponse.Providers {
+		for _, resourceType := range provider.ResourceTypes {
+			apiVersions := append([]string{}, resourceType.APIVersions...)
+			// api-versions are dates (with an optional suffix) so sort them most recent first
+			sort.Sort(sort.Reverse(sort.StringSlice(apiVersions)))
+			lookup[strings.ToLower(provider.Namespace+"/"+resourceType.ResourceType)] = apiVersions
+		}
+	}
+	setResourceAPIVersionLookup(lookup)
+
+	bytes, err := json.Marshal(providerCache{Updated: time.Now().UTC(), APIVersions: lookup})
+	if err != nil {
+		return err
+	}
+	storage.PutCache(providerCacheKey, string(bytes)) //nolint: errcheck
+	return nil
+}
+
+func setResourceAPIVersionLookup(lookup map[string][]string) {
+	resourceAPIVersionLock.Lock()
+	defer resourceAPIVersionLock.Unlock()
+	resourceAPIVersionLookup = lookup
+}
+
+func loadProviderCache() (providerCache, error) {
+	var cache providerCache
+	providerData, err := storage.GetCache(providerCacheKey)
+	if err != nil {
+		return cache, err
+	}
+	if providerData == "" {
+		return cache, fmt.Errorf("No cached provider data")
+	}
+	err = json.Unmarshal([]byte(providerData), &cache)
+	return cache, err
+}
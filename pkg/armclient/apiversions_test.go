@@ -0,0 +1,80 @@
+package armclient
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"gopkg.in/h2non/gock.v1"
+)
+
+const testProvidersResponse = `{
+	"value": [
+		{
+			"namespace": "Microsoft.Web",
+			"resourceTypes": [
+				{ "resourceType": "sites", "apiVersions": ["2019-08-01", "2020-06-01", "2020-09-01-preview"] },
+				{ "resourceType": "previewOnly", "apiVersions": ["2020-01-01-preview", "2021-01-01-preview"] }
+			]
+		}
+	]
+}`
+
+func Test_ResourceAPILookup(t *testing.T) {
+	defer gock.Off()
+	defer setResourceAPIVersionLookup(nil)
+	defer SetAPIVersionOverrides(nil)
+
+	httpClient := &http.Client{Transport: &http.Transport{}}
+	gock.InterceptClient(httpClient)
+	gock.New("https://management.azure.com").
+		Get("/providers").
+		Reply(200).
+		BodyString(testProvidersResponse)
+
+	tokenFunc := func(clearCache bool) (AzCLIToken, error) {
+		return AzCLIToken{}, nil
+	}
+	client := NewClientFromConfig(httpClient, tokenFunc, 5000)
+	client.PopulateResourceAPILookup(context.Background())
+	if !gock.IsDone() {
+		t.Error("Expected the providers to be requested")
+	}
+
+	apiVersions, err := GetAPIVersions("microsoft.web/Sites")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"2020-09-01-preview", "2020-06-01", "2019-08-01"}
+	if len(apiVersions) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, apiVersions)
+	}
+	for i := range expected {
+		if apiVersions[i] != expected[i] {
+			t.Errorf("Expected %v, got %v", expected, apiVersions)
+		}
+	}
+
+	checkAPIVersion := func(armType string, expected string) {
+		apiVersion, err := GetAPIVersion(armType)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if apiVersion != expected {
+			t.Errorf("Expected api-version %s for %s, got %s", expected, armType, apiVersion)
+		}
+	}
+	// the latest non-preview version is the default
+	checkAPIVersion("Microsoft.Web/sites", "2020-06-01")
+	checkAPIVersion("Microsoft.Web/previewOnly", "2021-01-01-preview")
+
+	SetAPIVersionOverride("Microsoft.Web/Sites", "2020-09-01-preview")
+	checkAPIVersion("Microsoft.Web/sites", "2020-09-01-preview")
+	SetAPIVersionOverride("Microsoft.Web/sites", "")
+	checkAPIVersion("Microsoft.Web/sites", "2020-06-01")
+
+	_, err = GetAPIVersion("Microsoft.Unknown/things")
+	if err == nil {
+		t.Error("Expected an error for an unknown resource type")
+	}
+}
@@ -3,30 +3,34 @@ package armclient
 import (
 	"bytes"
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"golang.org/x/time/rate"
 	"io/ioutil"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/lawrencegripper/azbrowse/internal/pkg/errorhandling"
 	"github.com/lawrencegripper/azbrowse/internal/pkg/eventing"
-	"github.com/lawrencegripper/azbrowse/internal/pkg/storage"
 	"github.com/lawrencegripper/azbrowse/internal/pkg/tracing"
 	"github.com/opentracing/opentracing-go"
 )
 
 const (
-	userAgentStr     = "github.com/lawrencegripper/azbrowse"
-	providerCacheKey = "providerCache"
+	userAgentStr = "github.com/lawrencegripper/azbrowse"
 )
 
 // TokenFunc is the interface to meet for functions which retrieve tokens for the ARMClient
 type TokenFunc func(clearCache bool) (AzCLIToken, error)
 
+// TenantTokenFunc is the interface to meet for functions which retrieve tokens for a specific tenant
+type TenantTokenFunc func(clearCache bool, tenantID string) (AzCLIToken, error)
+
+// ResourceTokenFunc is the interface to meet for functions which retrieve tokens for a resource other than ARM (e.g. https://vault.azure.net)
+type ResourceTokenFunc func(clearCache bool, tenantID string, resource string) (AzCLIToken, error)
+
 // ResponseProcessor can be used to handle additional actions once a response is received
 type ResponseProcessor func(requestPath string, response *http.Response, responseBody string)
 
@@ -37,7 +41,17 @@ type Client struct {
 	responseProcessors []ResponseProcessor
 	limiter            *rate.Limiter
 
-	acquireToken TokenFunc
+	acquireToken         TokenFunc
+	acquireTenantToken   TenantTokenFunc
+	acquireResourceToken ResourceTokenFunc
+	tenantClients        *tenantClientCache
+}
+
+// tenantClientCache holds the clients created for each tenant
+// it is shared between a client and the tenant clients created from it
+type tenantClientCache struct {
+	clients map[string]*Client
+	lock    sync.Mutex
 }
 
 // LegacyInstance is a singleton ARMClient used while migrating to the
@@ -53,10 +67,14 @@ func NewClientFromCLI(tenantID string, responseProcessors ...ResponseProcessor)
 		return aquireTokenFromAzCLI(clearCache, tenantID)
 	}
 	return &Client{
-		responseProcessors: responseProcessors,
-		limiter:            rate.NewLimiter(requestPerSecLimit, requestPerSecBurst),
-		acquireToken:       aquireToken,
-		client:             &http.Client{},
+		tenantID:             tenantID,
+		responseProcessors:   responseProcessors,
+		limiter:              rate.NewLimiter(requestPerSecLimit, requestPerSecBurst),
+		acquireToken:         aquireToken,
+		acquireTenantToken:   aquireTokenFromAzCLI,
+		acquireResourceToken: aquireTokenForResourceFromAzCLI,
+		client:               &http.Client{},
+		tenantClients:        &tenantClientCache{clients: map[string]*Client{}},
 	}
 }
 
@@ -67,9 +85,101 @@ func NewClientFromConfig(client *http.Client, tokenFunc TokenFunc, reqPerSecLimi
 		acquireToken:       tokenFunc,
 		limiter:            rate.NewLimiter(rate.Limit(reqPerSecLimit), 10), // Keep the rate limitter but set high values for tests to complete quickly
 		client:             client,
+		tenantClients:      &tenantClientCache{clients: map[string]*Client{}},
 	}
 }
 
+// SetAquireTenantToken lets you override the token func used by the
+// clients returned from `ForTenant`
+func (c *Client) SetAquireTenantToken(aquireFunc TenantTokenFunc) {
+	c.acquireTenantToken = aquireFunc
+}
+
+// SetAquireResourceToken lets you override the token func used by `GetTokenForResource`
+func (c *Client) SetAquireResourceToken(aquireFunc ResourceTokenFunc) {
+	c.acquireResourceToken = aquireFunc
+}
+
+// GetAquireResourceToken returns the token func used by `GetTokenForResource`
+// so that it can be restored after being overridden
+func (c *Client) GetAquireResourceToken() ResourceTokenFunc {
+	return c.acquireResourceToken
+}
+
+// GetTokenForResource gets a token for a resource other than ARM (e.g. https://vault.azure.net)
+// using the tenant set on the context (see `WithTenantID`)
+func (c *Client) GetTokenForResource(ctx context.Context, resource string) (AzCLIToken, error) {
+	return c.getTokenForResource(ctx, resource, false)
+}
+
+// RefreshTokenForResource clears any cached token for the resource and gets a new one
+func (c *Client) RefreshTokenForResource(ctx context.Context, resource string) (AzCLIToken, error) {
+	return c.getTokenForResource(ctx, resource, true)
+}
+
+func (c *Client) getTokenForResource(ctx context.Context, resource string, clearCache bool) (AzCLIToken, error) {
+	if c.acquireResourceToken == nil {
+		return AzCLIToken{}, fmt.Errorf("Client doesn't support acquiring tokens for resource: %s", resource)
+	}
+	tenantID := TenantIDFromContext(ctx)
+	if tenantID == "" {
+		tenantID = c.ForContext(ctx).tenantID
+	}
+	return c.acquireResourceToken(clearCache, tenantID, resource)
+}
+
+// ForTenant returns a client which authenticates against the specified tenant.
+// Clients are cached so each tenant has a single client and token cache.
+// If the client doesn't support acquiring tokens per tenant the current client is returned
+func (c *Client) ForTenant(tenantID string) *Client {
+	if tenantID == "" || tenantID == c.tenantID || c.acquireTenantToken == nil {
+		return c
+	}
+
+	c.tenantClients.lock.Lock()
+	defer c.tenantClients.lock.Unlock()
+
+	tenantClient, exists := c.tenantClients.clients[tenantID]
+	if !exists {
+		aquireTenantToken := c.acquireTenantToken
+		tenantClient = &Client{
+			tenantID:           tenantID,
+			responseProcessors: c.responseProcessors,
+			limiter:            c.limiter,
+			acquireToken: func(clearCache bool) (AzCLIToken, error) {
+				return aquireTenantToken(clearCache, tenantID)
+			},
+			acquireTenantToken:   aquireTenantToken,
+			acquireResourceToken: c.acquireResourceToken,
+			client:               c.client,
+			tenantClients:        c.tenantClients,
+		}
+		c.tenantClients.clients[tenantID] = tenantClient
+	}
+	return tenantClient
+}
+
+// ForContext returns the client for the tenant set on the context (see `WithTenantID`)
+func (c *Client) ForContext(ctx context.Context) *Client {
+	return c.ForTenant(TenantIDFromContext(ctx))
+}
+
+type tenantIDContextKey struct{}
+
+// WithTenantID returns a context which causes requests made with it to use the specified tenant
+func WithTenantID(ctx context.Context, tenantID string) context.Context {
+	return context.WithValue(ctx, tenantIDContextKey{}, tenantID)
+}
+
+// TenantIDFromContext returns the tenant set on the context or "" if none is set
+func TenantIDFromContext(ctx context.Context) string {
+	tenantID, ok := ctx.Value(tenantIDContextKey{}).(string)
+	if !ok {
+		return ""
+	}
+	return tenantID
+}
+
 // SetClient is used to override the HTTP Client used.
 // This is useful when testing
 func (c *Client) SetClient(newClient *http.Client) {
@@ -121,6 +231,10 @@ func (c *Client) DoRequest(ctx context.Context, method, path string) (string, er
 
 // DoRawRequest makes a raw request with ARM authentication headers set
 func (c *Client) DoRawRequest(ctx context.Context, req *http.Request) (*http.Response, error) {
+	if tenantClient := c.ForContext(ctx); tenantClient != c {
+		return tenantClient.DoRawRequest(ctx, req)
+	}
+
 	cliToken, err := c.acquireToken(false)
 	if err != nil {
 		return nil, errors.New("Failed to acquire auth token: " + err.Error())
@@ -152,6 +266,10 @@ func (c *Client) DoRawRequest(ctx context.Context, req *http.Request) (*http.Res
 
 // DoRequestWithBody makes an ARM rest request
 func (c *Client) DoRequestWithBody(ctx context.Context, method, path, body string) (string, error) {
+	if tenantClient := c.ForContext(ctx); tenantClient != c {
+		return tenantClient.DoRequestWithBody(ctx, method, path, body)
+	}
+
 	span, _ := tracing.StartSpanFromContext(ctx, "request:"+method, tracing.SetTag("path", path))
 	defer span.Finish()
 
@@ -226,79 +344,6 @@ func (c *Client) DoResourceGraphQuery(ctx context.Context, subscription, query s
 	return c.DoRequestWithBody(ctx, "POST", "/providers/Microsoft.ResourceGraph/resources?api-version=2018-09-01-preview", messageBody)
 }
 
-var resourceAPIVersionLookup map[string]string
-
-// GetAPIVersion returns the most recent API version for a resource
-func GetAPIVersion(armType string) (string, error) {
-	value, exists := resourceAPIVersionLookup[armType]
-	if !exists {
-		return "MISSING", fmt.Errorf("API not found for the resource: %s", armType)
-	}
-	return value, nil
-}
-
-// PopulateResourceAPILookup is used to build a cache of resourcetypes -> api versions
-// this is needed when requesting details from a resource as APIVersion isn't known and is required
-func (c *Client) PopulateResourceAPILookup(ctx context.Context) {
-	// w.statusView.Status("Getting provider data from cache", true)
-	if resourceAPIVersionLookup == nil {
-		span, ctx := tracing.StartSpanFromContext(ctx, "populateResCache")
-		// Get data from cache
-		providerData, err := storage.GetCache(providerCacheKey)
-
-		// w.statusView.Status("Getting provider data from cache: Completed", false)
-
-		if err != nil || providerData == "" {
-			// w.statusView.Status("Getting provider data from API", true)
-			span.SetTag("error: failed getting cached data", err)
-			span.SetTag("cacheData", providerData)
-
-			// Get Subscriptions
-			data, err := c.DoRequest(ctx, "GET", "/providers?api-version=2017-05-10")
-			if err != nil {
-				panic(err)
-			}
-			var providerResponse ProvidersResponse
-			err = json.Unmarshal([]byte(data), &providerResponse)
-			if err != nil {
-				panic(err)
-			}
-
-			resourceAPIVersionLookup = make(map[string]string)
-			for _, provider := range providerResponse.Providers {
-				for _, resourceType := range provider.ResourceTypes {
-					for _, apiVersion := range resourceType.APIVersions {
-						if !strings.Contains(apiVersion, "preview") {
-							resourceAPIVersionLookup[provider.Namespace+"/"+resourceType.ResourceType] = apiVersion
-							break
-						}
-					}
-				}
-			}
-
-			bytes, err := json.Marshal(resourceAPIVersionLookup)
-			if err != nil {
-				panic(err)
-			}
-			providerData = string(bytes)
-
-			storage.PutCache(providerCacheKey, providerData) //nolint: errcheck
-
-		} else {
-			span.SetTag("Data read from cache", true)
-			var providerCache map[string]string
-			err = json.Unmarshal([]byte(providerData), &providerCache)
-			if err != nil {
-				span.SetTag("error: failed to read data from cache", err)
-				span.Finish()
-				panic(err)
-			}
-			resourceAPIVersionLookup = providerCache
-		}
-		span.Finish()
-	}
-}
-
 func truncateString(s string, i int) string {
 	runes := []rune(s)
 	if len(runes) > i {
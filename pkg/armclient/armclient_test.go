@@ -59,3 +59,38 @@ func Test_ArmClient_AzCliToken_DontRefresh(t *testing.T) {
 		t.Error("Expected cache not to be cleared for azcli token")
 	}
 }
+
+func Test_ArmClient_TenantFromContext_UsesTenantToken(t *testing.T) {
+	receivedAuth := ""
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		receivedAuth = r.Header.Get("Authorization")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	tokenFunc := func(clearCache bool) (AzCLIToken, error) {
+		return AzCLIToken{TokenType: "Bearer", AccessToken: "default"}, nil
+	}
+	client := NewClientFromConfig(ts.Client(), tokenFunc, 5000)
+	client.SetAquireTenantToken(func(clearCache bool, tenantID string) (AzCLIToken, error) {
+		return AzCLIToken{TokenType: "Bearer", AccessToken: "token-" + tenantID, Tenant: tenantID}, nil
+	})
+
+	client.DoRequest(context.Background(), "GET", ts.URL+"/subscriptions") //nolint: errcheck
+	if receivedAuth != "Bearer default" {
+		t.Errorf("Expected default token, got %q", receivedAuth)
+	}
+
+	ctx := WithTenantID(context.Background(), "tenant1")
+	client.DoRequest(ctx, "GET", ts.URL+"/subscriptions") //nolint: errcheck
+	if receivedAuth != "Bearer token-tenant1" {
+		t.Errorf("Expected tenant token, got %q", receivedAuth)
+	}
+
+	if client.ForTenant("tenant1") != client.ForTenant("tenant1") {
+		t.Error("Expected a single client per tenant")
+	}
+	if client.ForTenant("tenant1").GetTenantID() != "tenant1" {
+		t.Error("Expected tenant client to have the tenant ID set")
+	}
+}
@@ -4,7 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"os/exec"
-	"strings"
+	"sync"
 )
 
 // AzCLIToken contains token info from az cli
@@ -15,20 +15,29 @@ type AzCLIToken struct {
 	Subscription string `json:"subscription"`
 }
 
-var currentToken *AzCLIToken
+// tokenCache holds a token per tenant and resource. The default tenant of the
+// az cli and the ARM resource are stored as empty values in the key
+var tokenCache = map[string]*AzCLIToken{}
+var tokenCacheLock sync.Mutex
 
 func aquireTokenFromAzCLI(clearCache bool, tenantID string) (AzCLIToken, error) {
-	if currentToken == nil || clearCache {
+	return aquireTokenForResourceFromAzCLI(clearCache, tenantID, "")
+}
+
+func aquireTokenForResourceFromAzCLI(clearCache bool, tenantID string, resource string) (AzCLIToken, error) {
+	tokenCacheLock.Lock()
+	defer tokenCacheLock.Unlock()
+
+	cacheKey := tenantID + "|" + resource
+	currentToken, exists := tokenCache[cacheKey]
+	if !exists || currentToken == nil || clearCache {
 		args := []string{"account", "get-access-token", "--output", "json"}
 
 		if tenantID != "" {
-			query := fmt.Sprintf("[?tenantId=='%s'].id| [0] ", tenantID)
-			out, err := exec.Command("az", "account", "list", "--output", "tsv", "--query", query).Output()
-			if err != nil {
-				return AzCLIToken{}, fmt.Errorf("Error looking up subscription from tenant: %s", err)
-			}
-			subscription := strings.TrimSpace(string(out))
-			args = append(args, "--subscription", subscription)
+			args = append(args, "--tenant", tenantID)
+		}
+		if resource != "" {
+			args = append(args, "--resource", resource)
 		}
 
 		out, err := exec.Command("az", args...).Output()
@@ -41,7 +50,7 @@ func aquireTokenFromAzCLI(clearCache bool, tenantID string) (AzCLIToken, error)
 		if err != nil {
 			return AzCLIToken{}, err
 		}
-		currentToken = &r
+		tokenCache[cacheKey] = &r
 		return r, nil
 	}
 
@@ -46,3 +46,14 @@ func getRequestURL(path string) (string, error) {
 
 	return path, nil
 }
+
+// IsARMRequestURL returns true if the path (or URL) is one that ARM requests can be made to
+func IsARMRequestURL(path string) bool {
+	_, err := getRequestURL(path)
+	return err == nil
+}
+
+// GetRequestURL returns the full URL for an ARM path, or the URL itself if it is already an ARM URL
+func GetRequestURL(path string) (string, error) {
+	return getRequestURL(path)
+}
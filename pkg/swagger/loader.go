@@ -0,0 +1,189 @@
+package swagger
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-openapi/loads"
+	"github.com/go-openapi/spec"
+	"github.com/lawrencegripper/azbrowse/pkg/endpoints"
+)
+
+// apiSetFile is the api-set.json file listing the swagger files to load for a folder
+type apiSetFile struct {
+	Name       string   `json:"name"`
+	InputFiles []string `json:"input_files"`
+}
+
+// specFolderSkipDirs are folders in the spec layout that don't hold API definitions
+var specFolderSkipDirs = map[string]bool{
+	"common-types": true,
+	"examples":     true,
+}
+
+// GetSpecFiles returns the swagger files to load from the folder. Folders with an api-set.json load the listed
+// input files, otherwise any JSON file that is a swagger 2.0 document with paths is loaded
+func GetSpecFiles(folder string) ([]string, error) {
+	specFiles := []string{}
+	err := filepath.Walk(folder, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if specFolderSkipDirs[info.Name()] {
+				return filepath.SkipDir
+			}
+			buf, err := ioutil.ReadFile(filepath.Join(path, "api-set.json"))
+			if err != nil {
+				return nil // no api-set.json so keep walking
+			}
+			var apiSet apiSetFile
+			err = json.Unmarshal(buf, &apiSet)
+			if err != nil {
+				return fmt.Errorf("Error parsing %s: %s", filepath.Join(path, "api-set.json"), err)
+			}
+			for _, inputFile := range apiSet.InputFiles {
+				specFiles = append(specFiles, filepath.Join(path, inputFile))
+			}
+			return filepath.SkipDir
+		}
+		if strings.ToLower(filepath.Ext(path)) == ".json" && isSwaggerFile(path) {
+			specFiles = append(specFiles, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return []string{}, err
+	}
+	return specFiles, nil
+}
+
+func isSwaggerFile(path string) bool {
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return false
+	}
+	var doc struct {
+		Swagger string                     `json:"swagger"`
+		Paths   map[string]json.RawMessage `json:"paths"`
+	}
+	err = json.Unmarshal(buf, &doc)
+	return err == nil && doc.Swagger == "2.0" && len(doc.Paths) > 0
+}
+
+// HashSpecFolder returns a hash of the names and contents of all the JSON files under the folder (including
+// files referenced by the specs) that changes when the specs are added to or updated
+func HashSpecFolder(folder string) (string, error) {
+	hash := sha256.New()
+	err := filepath.Walk(folder, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || strings.ToLower(filepath.Ext(path)) != ".json" {
+			return nil
+		}
+		relativePath, err := filepath.Rel(folder, path)
+		if err != nil {
+			return err
+		}
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close() //nolint: errcheck
+		_, err = io.WriteString(hash, filepath.ToSlash(relativePath)+"\n")
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(hash, file)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// LoadResourceTypesFromFolder loads the swagger specs in the folder (see GetSpecFiles) and converts them to ResourceTypes
+func LoadResourceTypesFromFolder(folder string, config *Config) ([]ResourceType, error) {
+	specFiles, err := GetSpecFiles(folder)
+	if err != nil {
+		return []ResourceType{}, err
+	}
+
+	var paths []*Path
+	for _, specFile := range specFiles {
+		doc, err := loads.Spec(specFile)
+		if err != nil {
+			return []ResourceType{}, fmt.Errorf("Error opening %s: %s", specFile, err)
+		}
+		doc, err = doc.Expanded(&spec.ExpandOptions{RelativeBase: specFile})
+		if err != nil {
+			return []ResourceType{}, fmt.Errorf("Error expanding %s: %s", specFile, err)
+		}
+		paths, err = MergeSwaggerDoc(paths, config, doc, true, "")
+		if err != nil {
+			return []ResourceType{}, fmt.Errorf("Error processing %s: %s", specFile, err)
+		}
+	}
+	return ConvertToSwaggerResourceTypes(paths), nil
+}
+
+// MergeResourceTypes merges newResourceTypes into the resourceTypes hierarchy. A new type replaces the existing type
+// with the same template URL (ignoring case and segment names) wherever it is in the hierarchy, keeping any children
+// and sub-resources that it doesn't redefine. Types that don't already exist are added at the top level
+func MergeResourceTypes(resourceTypes []ResourceType, newResourceTypes []ResourceType) []ResourceType {
+	result := make([]ResourceType, len(resourceTypes))
+	copy(result, resourceTypes)
+	for _, newResourceType := range newResourceTypes {
+		existing := findResourceTypeByTemplateURL(result, newResourceType.Endpoint.TemplateURL)
+		if existing == nil {
+			result = append(result, newResourceType)
+			continue
+		}
+		merged := newResourceType
+		merged.Children = MergeResourceTypes(existing.Children, newResourceType.Children)
+		merged.SubResources = MergeResourceTypes(existing.SubResources, newResourceType.SubResources)
+		*existing = merged
+	}
+	return result
+}
+
+// findResourceTypeByTemplateURL returns a pointer to the matching ResourceType in the hierarchy (copying the
+// Children/SubResources slices along the path so that the caller can update it without affecting other hierarchies)
+func findResourceTypeByTemplateURL(resourceTypes []ResourceType, templateURL string) *ResourceType {
+	for i := range resourceTypes {
+		resourceType := &resourceTypes[i]
+		if strings.EqualFold(stripPathNames(resourceType.Endpoint.TemplateURL), stripPathNames(templateURL)) {
+			return resourceType
+		}
+		if !endpointIsPrefixOf(resourceType.Endpoint, templateURL) {
+			continue
+		}
+		children := make([]ResourceType, len(resourceType.Children))
+		copy(children, resourceType.Children)
+		if result := findResourceTypeByTemplateURL(children, templateURL); result != nil {
+			resourceType.Children = children
+			return result
+		}
+		subResources := make([]ResourceType, len(resourceType.SubResources))
+		copy(subResources, resourceType.SubResources)
+		if result := findResourceTypeByTemplateURL(subResources, templateURL); result != nil {
+			resourceType.SubResources = subResources
+			return result
+		}
+	}
+	return nil
+}
+
+// endpointIsPrefixOf checks whether templateURL is under the endpoint (children and sub-resources always extend their parent's URL)
+func endpointIsPrefixOf(endpoint *endpoints.EndpointInfo, templateURL string) bool {
+	return strings.HasPrefix(strings.ToLower(stripPathNames(templateURL)), strings.ToLower(stripPathNames(endpoint.TemplateURL)))
+}
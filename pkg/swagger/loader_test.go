@@ -0,0 +1,127 @@
+package swagger
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+
+	"github.com/lawrencegripper/azbrowse/pkg/endpoints"
+)
+
+const loaderTestSpec = `{
+	"swagger": "2.0",
+	"info": { "title": "TestClient", "version": "2030-01-01-preview" },
+	"host": "management.azure.com",
+	"paths": {
+		"/subscriptions/{subscriptionId}/providers/Test.RP/widgets": { "get": {} },
+		"/subscriptions/{subscriptionId}/providers/Test.RP/widgets/{widgetName}": { "get": {}, "delete": {} }
+	}
+}`
+
+func writeLoaderTestFile(t *testing.T, path string, content string) {
+	err := os.MkdirAll(filepath.Dir(path), 0700)
+	assert.NilError(t, err)
+	err = ioutil.WriteFile(path, []byte(content), 0600)
+	assert.NilError(t, err)
+}
+
+func Test_LoadResourceTypesFromFolder(t *testing.T) {
+	folder, err := ioutil.TempDir("", "azbrowse-specs")
+	assert.NilError(t, err)
+	defer os.RemoveAll(folder) //nolint: errcheck
+
+	// api-set.json controls which files are loaded in its folder
+	writeLoaderTestFile(t, filepath.Join(folder, "test/resource-manager/api-set.json"), `{"name": "test", "input_files": ["Test.RP/preview/2030-01-01-preview/test.json"]}`)
+	writeLoaderTestFile(t, filepath.Join(folder, "test/resource-manager/Test.RP/preview/2030-01-01-preview/test.json"), loaderTestSpec)
+	writeLoaderTestFile(t, filepath.Join(folder, "test/resource-manager/Test.RP/stable/2020-01-01/test.json"), `{"swagger": "2.0", "paths": {"/old": {"get": {}}}}`)
+	// without api-set.json any swagger file is loaded
+	writeLoaderTestFile(t, filepath.Join(folder, "other/other.json"), `{"swagger": "2.0", "info": {"version": "2021-01-01"}, "paths": {"/subscriptions/{subscriptionId}/providers/Other.RP/things": {"get": {}}}}`)
+	writeLoaderTestFile(t, filepath.Join(folder, "other/examples/example.json"), `{"swagger": "2.0", "paths": {"/example": {"get": {}}}}`)
+	writeLoaderTestFile(t, filepath.Join(folder, "other/notASpec.json"), `{"parameters": {}}`)
+
+	specFiles, err := GetSpecFiles(folder)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, specFiles, []string{
+		filepath.Join(folder, "other/other.json"),
+		filepath.Join(folder, "test/resource-manager/Test.RP/preview/2030-01-01-preview/test.json"),
+	})
+
+	resourceTypes, err := LoadResourceTypesFromFolder(folder, &Config{})
+	assert.NilError(t, err)
+	assert.Assert(t, is.Len(resourceTypes, 2))
+	assert.Equal(t, resourceTypes[0].Endpoint.TemplateURL, "/subscriptions/{subscriptionId}/providers/Other.RP/things")
+	assert.Equal(t, resourceTypes[1].Endpoint.TemplateURL, "/subscriptions/{subscriptionId}/providers/Test.RP/widgets")
+	assert.Equal(t, resourceTypes[1].Endpoint.APIVersion, "2030-01-01-preview")
+	assert.Assert(t, is.Len(resourceTypes[1].SubResources, 1))
+	assert.Assert(t, resourceTypes[1].SubResources[0].DeleteEndpoint != nil)
+}
+
+func Test_HashSpecFolder(t *testing.T) {
+	folder, err := ioutil.TempDir("", "azbrowse-specs")
+	assert.NilError(t, err)
+	defer os.RemoveAll(folder) //nolint: errcheck
+
+	writeLoaderTestFile(t, filepath.Join(folder, "test.json"), loaderTestSpec)
+	hash1, err := HashSpecFolder(folder)
+	assert.NilError(t, err)
+
+	// non-JSON files don't affect the hash
+	writeLoaderTestFile(t, filepath.Join(folder, "readme.md"), "# Specs")
+	hash2, err := HashSpecFolder(folder)
+	assert.NilError(t, err)
+	assert.Equal(t, hash1, hash2)
+
+	writeLoaderTestFile(t, filepath.Join(folder, "common/types.json"), `{"definitions": {}}`)
+	hash3, err := HashSpecFolder(folder)
+	assert.NilError(t, err)
+	assert.Assert(t, hash3 != hash1)
+}
+
+func Test_MergeResourceTypes(t *testing.T) {
+	resourceType := func(templateURL string, apiVersion string, children []ResourceType, subResources []ResourceType) ResourceType {
+		return ResourceType{
+			Endpoint:     endpoints.MustGetEndpointInfoFromURL(templateURL, apiVersion),
+			Children:     children,
+			SubResources: subResources,
+		}
+	}
+	compiled := []ResourceType{
+		resourceType("/subscriptions/{subscriptionId}/resourceGroups", "2019-01-01", nil, []ResourceType{
+			resourceType("/subscriptions/{subscriptionId}/resourceGroups/{resourceGroupName}", "2019-01-01", []ResourceType{
+				resourceType("/subscriptions/{subscriptionId}/resourceGroups/{resourceGroupName}/providers/Test.RP/widgets", "2019-01-01", nil, []ResourceType{
+					resourceType("/subscriptions/{subscriptionId}/resourceGroups/{resourceGroupName}/providers/Test.RP/widgets/{widgetName}", "2019-01-01", []ResourceType{
+						resourceType("/subscriptions/{subscriptionId}/resourceGroups/{resourceGroupName}/providers/Test.RP/widgets/{widgetName}/status", "2019-01-01", nil, nil),
+					}, nil),
+				}),
+			}, nil),
+		}),
+	}
+	runtime := []ResourceType{
+		resourceType("/subscriptions/{subscriptionId}/resourceGroups/{resourceGroupName}/providers/Test.RP/widgets", "2030-01-01", nil, []ResourceType{
+			resourceType("/subscriptions/{subscriptionId}/resourceGroups/{resourceGroupName}/providers/Test.RP/widgets/{name}", "2030-01-01", []ResourceType{
+				resourceType("/subscriptions/{subscriptionId}/resourceGroups/{resourceGroupName}/providers/Test.RP/widgets/{name}/parts", "2030-01-01", nil, nil),
+			}, nil),
+		}),
+		resourceType("/subscriptions/{subscriptionId}/providers/New.RP/things", "2030-01-01", nil, nil),
+	}
+
+	merged := MergeResourceTypes(compiled, runtime)
+	assert.Assert(t, is.Len(merged, 2))
+	assert.Equal(t, merged[1].Endpoint.TemplateURL, "/subscriptions/{subscriptionId}/providers/New.RP/things")
+
+	widgets := merged[0].SubResources[0].Children[0]
+	assert.Equal(t, widgets.Endpoint.APIVersion, "2030-01-01")
+	widget := widgets.SubResources[0]
+	assert.Equal(t, widget.Endpoint.TemplateURL, "/subscriptions/{subscriptionId}/resourceGroups/{resourceGroupName}/providers/Test.RP/widgets/{name}")
+	// children that aren't in the runtime specs are kept
+	assert.Assert(t, is.Len(widget.Children, 2))
+	assert.Equal(t, widget.Children[0].Endpoint.APIVersion, "2019-01-01")
+	assert.Equal(t, widget.Children[1].Endpoint.APIVersion, "2030-01-01")
+
+	// the compiled-in hierarchy is unchanged
+	assert.Equal(t, compiled[0].SubResources[0].Children[0].Endpoint.APIVersion, "2019-01-01")
+}
@@ -151,3 +151,62 @@ type AdditionalPath struct {
 	// SubPathRegesx holds regex info for modifying subpath URLs
 	SubPathRegex *RegexReplace
 }
+
+/////////////////////////////////////////////////////////////////////////////
+// Data-plane API sets
+
+// DataPlaneConfig describes a data-plane API that is generated from its swagger specs and browsed under
+// the ARM resource that hosts it. These are declared in cmd/swagger-codegen/dataplane-apisets.json
+type DataPlaneConfig struct {
+	// Name identifies the API set in generated code, e.g. "AzureSearch". It also selects the handler for APIs
+	// that need custom handling (see dataPlaneAPIHandlers in the expanders package)
+	Name string `json:"name"`
+	// SpecFolder is the data-plane folder for the service containing its api-set.json
+	SpecFolder string `json:"specFolder"`
+	// InputFiles optionally overrides the input_files from api-set.json (relative to SpecFolder). Files are merged in order
+	InputFiles []string `json:"inputFiles,omitempty"`
+	// PathPrefixes is prefixed to the paths from an input file (keyed on the entry in InputFiles). This is needed
+	// for specs that set the base URL with x-ms-parameterized-host, e.g. the Azure Search index operations
+	PathPrefixes map[string]string `json:"pathPrefixes,omitempty"`
+	// Overrides applies PathOverrides to the paths in the specs (keyed on the path)
+	Overrides map[string]PathOverride `json:"overrides,omitempty"`
+	// ResourceTemplateURL is the template URL of the ARM resource that the data-plane node is added to
+	ResourceTemplateURL string `json:"resourceTemplateURL"`
+	// ResourceAPIVersion is the api-version used to get the ARM resource and to list its keys
+	ResourceAPIVersion string `json:"resourceAPIVersion"`
+	// NodeName is the display name of the node added to the ARM resource
+	NodeName string `json:"nodeName"`
+	// Endpoint is the base URL for data-plane requests. Placeholders are replaced with the values matched by
+	// ResourceTemplateURL (e.g. {searchServiceName}) or with properties of the ARM resource (e.g. {properties.subdomain})
+	Endpoint string `json:"endpoint"`
+	// Auth describes how data-plane requests are authenticated
+	Auth DataPlaneAuth `json:"auth"`
+}
+
+// DataPlaneAuthType is the method used to authenticate data-plane requests
+type DataPlaneAuthType string
+
+const (
+	// DataPlaneAuthAAD sends an AAD token for the Audience as a bearer token
+	DataPlaneAuthAAD DataPlaneAuthType = "aad"
+	// DataPlaneAuthListKeys sends a key returned by the ARM resource's list keys action in the Header
+	DataPlaneAuthListKeys DataPlaneAuthType = "listKeys"
+	// DataPlaneAuthHMAC signs requests with HMAC-SHA256 using a key and credential returned by the ARM resource's
+	// list keys action, e.g. for App Configuration
+	DataPlaneAuthHMAC DataPlaneAuthType = "hmac"
+)
+
+// DataPlaneAuth describes how data-plane requests are authenticated
+type DataPlaneAuth struct {
+	Type DataPlaneAuthType `json:"type"`
+	// Audience is the AAD resource to get a token for (aad)
+	Audience string `json:"audience,omitempty"`
+	// ListKeysPath is appended to the ARM resource ID to POST for the keys, e.g. "/listAdminKeys" (listKeys, hmac)
+	ListKeysPath string `json:"listKeysPath,omitempty"`
+	// KeyProperty is the dotted path to the key in the list keys response, e.g. "primaryKey" or "keys.0.value" (listKeys, hmac)
+	KeyProperty string `json:"keyProperty,omitempty"`
+	// CredentialProperty is the dotted path to the ID of the key in the list keys response, sent with the signature (hmac)
+	CredentialProperty string `json:"credentialProperty,omitempty"`
+	// Header is the request header that the key is sent in, e.g. "api-key" (listKeys)
+	Header string `json:"header,omitempty"`
+}